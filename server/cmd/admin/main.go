@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"library/config"
+	"library/database"
+	"library/repository/mysql"
+	"library/service"
+)
+
+// admin 命令行工具：在系统中没有启用的管理员时创建初始管理员，
+// 用户名已存在时将其设为启用的管理员。
+//
+//	go run ./cmd/admin -username admin -password 'secret' -email admin@example.com
+func main() {
+	username := flag.String("username", "", "admin username")
+	password := flag.String("password", "", "admin password (required when the user does not exist)")
+	email := flag.String("email", "", "admin email")
+	flag.Parse()
+
+	if *username == "" {
+		log.Fatal("-username is required")
+	}
+
+	if err := config.InitConfig(); err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	if err := database.InitMySQL(); err != nil {
+		log.Fatalf("Error initializing MySQL: %v", err)
+	}
+	defer database.CloseDB()
+
+	factory := service.NewFactory(mysql.NewFactory(database.DB))
+	created, err := factory.GetUserService().BootstrapAdmin(*username, *password, *email)
+	if err != nil {
+		log.Fatalf("Error bootstrapping admin: %v", err)
+	}
+	if !created {
+		log.Printf("An active admin already exists, nothing to do")
+		return
+	}
+	log.Printf("Admin account %s is ready", *username)
+}
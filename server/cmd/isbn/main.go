@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"library/config"
+	"library/database"
+	"library/repository/mysql"
+	"library/service"
+)
+
+// isbn 命令行工具：将已有图书的ISBN规范化为ISBN-13，并合并规范化后ISBN相同的重复图书。
+// 建议先试运行检查将要进行的变更。
+//
+//	go run ./cmd/isbn -dry-run
+//	go run ./cmd/isbn
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only report the changes without writing")
+	flag.Parse()
+
+	if err := config.InitConfig(); err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	if err := database.InitMySQL(); err != nil {
+		log.Fatalf("Error initializing MySQL: %v", err)
+	}
+	defer database.CloseDB()
+
+	factory := service.NewFactory(mysql.NewFactory(database.DB))
+	report, err := factory.GetBookService().NormalizeISBNs(service.SystemActor, *dryRun)
+	if err != nil {
+		log.Fatalf("Error normalizing ISBNs: %v", err)
+	}
+
+	for _, c := range report.Changes {
+		if c.MergedInto != 0 {
+			log.Printf("book %d: %s -> %s, merged into book %d", c.BookID, c.From, c.To, c.MergedInto)
+		} else {
+			log.Printf("book %d: %s -> %s", c.BookID, c.From, c.To)
+		}
+	}
+	for _, issue := range report.Issues {
+		log.Printf("book %d: skipped %q: %s", issue.BookID, issue.ISBN, issue.Reason)
+	}
+	prefix := ""
+	if *dryRun {
+		prefix = "[dry run] "
+	}
+	log.Printf("%s%d books scanned, %d normalized, %d merged, %d skipped",
+		prefix, report.Scanned, report.Normalized, report.Merged, len(report.Issues))
+}
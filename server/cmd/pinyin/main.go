@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log"
+
+	"library/config"
+	"library/database"
+	"library/repository/mysql"
+	"library/service"
+)
+
+// pinyin 命令行工具：按当前的拼音表重新生成全部图书书名和作者的拼音检索串。
+// 拼音检索上线前已有的图书和拼音表更新后需要运行一次。
+//
+//	go run ./cmd/pinyin
+func main() {
+	if err := config.InitConfig(); err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	if err := database.InitMySQL(); err != nil {
+		log.Fatalf("Error initializing MySQL: %v", err)
+	}
+	defer database.CloseDB()
+
+	factory := service.NewFactory(mysql.NewFactory(database.DB))
+	scanned, updated, err := factory.GetBookService().RebuildPinyin()
+	if err != nil {
+		log.Fatalf("Error rebuilding pinyin: %v", err)
+	}
+	log.Printf("%d books scanned, %d updated", scanned, updated)
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"library/config"
+	"library/isbn"
 	"library/repository/mysql"
 	"library/database"
 	"library/router"
+	"library/scheduler"
 	"library/service"
+	"time"
 )
 
 func main() {
@@ -19,12 +23,60 @@ func main() {
 		log.Fatalf("Error initializing MySQL: %v", err)
 	}
 
+	// Initialize Redis (optional, used for distributed locks)
+	if err := database.InitRedis(); err != nil {
+		log.Printf("Redis unavailable, running without it: %v", err)
+	}
+	defer database.CloseRedis()
+
 	// Create MySQL factory
 	mysqlFactory := mysql.NewFactory(database.DB)
 
 	// Create service factory
 	factory := service.NewFactory(mysqlFactory)
 
+	// Seed default role permissions for roles that have none yet
+	if err := factory.GetPermissionService().SeedDefaults(); err != nil {
+		log.Fatalf("Error seeding role permissions: %v", err)
+	}
+
+	// Create the first admin from config when there is none
+	if cfg := config.GlobalConfig.Admin; cfg.Username != "" {
+		created, err := factory.GetUserService().BootstrapAdmin(cfg.Username, cfg.Password, cfg.Email)
+		if err != nil {
+			log.Fatalf("Error bootstrapping admin: %v", err)
+		}
+		if created {
+			log.Printf("Bootstrapped admin account %s", cfg.Username)
+		}
+	}
+
+	// Generate copies for books created before item-level tracking
+	if n, err := factory.GetCopyService().BackfillCopies(); err != nil {
+		log.Fatalf("Error backfilling book copies: %v", err)
+	} else if n > 0 {
+		log.Printf("Generated copies for %d books", n)
+	}
+
+	// Load the in-process search index; the MySQL full-text index is maintained by the database
+	if n, err := factory.GetBookSearchService().Rebuild(); err != nil {
+		log.Fatalf("Error building search index: %v", err)
+	} else if n > 0 {
+		log.Printf("Indexed %d books for search", n)
+	}
+
+	// Start background jobs
+	if config.GlobalConfig.Scheduler.Enabled {
+		s := newScheduler(factory)
+		s.Start()
+		defer s.Stop()
+	}
+
+	// Register custom request validators
+	if err := isbn.RegisterValidator(); err != nil {
+		log.Fatalf("Error registering validators: %v", err)
+	}
+
 	// Set up the router
 	r := router.SetupRouter(factory)
 
@@ -33,3 +85,57 @@ func main() {
 		log.Fatal("Failed to start the server:", err)
 	}
 }
+
+// newScheduler registers the background jobs
+func newScheduler(factory service.Factory) *scheduler.Scheduler {
+	cfg := config.GlobalConfig.Scheduler
+	s := scheduler.New()
+
+	s.Every(service.JobOverdueScan, time.Duration(cfg.OverdueInterval)*time.Minute, func() error {
+		_, err := factory.GetOverdueService().ScanOverdue()
+		return skipLocked(err)
+	})
+
+	// 以下任务在多实例部署时同样只由取得锁的实例执行
+	s.Every(service.JobHoldExpiry, time.Duration(cfg.HoldInterval)*time.Minute, func() error {
+		return skipLocked(service.RunExclusive(service.JobHoldExpiry, factory.GetReservationService().ProcessExpiredReservations))
+	})
+
+	s.Every(service.JobMailDelivery, time.Duration(cfg.MailInterval)*time.Minute, func() error {
+		return skipLocked(service.RunExclusive(service.JobMailDelivery, func() error {
+			_, err := factory.GetMailService().DeliverPending()
+			return err
+		}))
+	})
+
+	s.Every(service.JobBookImport, time.Duration(cfg.ImportInterval)*time.Minute, func() error {
+		return skipLocked(service.RunExclusive(service.JobBookImport, func() error {
+			_, err := factory.GetBookImportService().RunPending()
+			return err
+		}))
+	})
+
+	s.Every(service.JobAuditSeal, time.Duration(cfg.AuditInterval)*time.Second, func() error {
+		return skipLocked(service.RunExclusive(service.JobAuditSeal, func() error {
+			_, err := factory.GetAuditService().SealChain()
+			return err
+		}))
+	})
+
+	if config.GlobalConfig.LDAP.Enabled {
+		s.Every(service.JobLDAPSync, time.Duration(cfg.LDAPInterval)*time.Minute, func() error {
+			_, err := factory.GetLDAPService().SyncDirectory()
+			return skipLocked(err)
+		})
+	}
+
+	return s
+}
+
+// skipLocked 任务已在其他实例上执行时记为跳过而不是失败
+func skipLocked(err error) error {
+	if errors.Is(err, service.ErrJobLocked) {
+		return scheduler.ErrSkipped
+	}
+	return err
+}
@@ -2,15 +2,28 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Redis     RedisConfig     `mapstructure:"redis"`
+	JWT       JWTConfig       `mapstructure:"jwt"`
+	Borrow    BorrowConfig    `mapstructure:"borrow"`
+	Scheduler SchedulerConfig `mapstructure:"scheduler"`
+	Payment   PaymentConfig   `mapstructure:"payment"`
+	Password  PasswordConfig  `mapstructure:"password"`
+	Admin     AdminConfig     `mapstructure:"admin"`
+	Login     LoginConfig     `mapstructure:"login"`
+	Mail      MailConfig      `mapstructure:"mail"`
+	TwoFactor TwoFactorConfig `mapstructure:"two_factor"`
+	SSO       SSOConfig       `mapstructure:"sso"`
+	LDAP      LDAPConfig      `mapstructure:"ldap"`
+	APIKey    APIKeyConfig    `mapstructure:"api_key"`
+	Search    SearchConfig    `mapstructure:"search"`
 }
 
 type ServerConfig struct {
@@ -36,8 +49,201 @@ type RedisConfig struct {
 }
 
 type JWTConfig struct {
-	Secret     string `mapstructure:"secret"`
-	ExpireTime int    `mapstructure:"expire_time"` // 过期时间（小时）
+	Secret        string `mapstructure:"secret"`
+	AccessExpire  int    `mapstructure:"access_expire"`  // 访问令牌有效期（分钟）
+	RefreshExpire int    `mapstructure:"refresh_expire"` // 刷新令牌有效期（小时）
+}
+
+// AccessTTL 访问令牌有效期
+func (c JWTConfig) AccessTTL() time.Duration {
+	return time.Duration(c.AccessExpire) * time.Minute
+}
+
+// RefreshTTL 刷新令牌有效期
+func (c JWTConfig) RefreshTTL() time.Duration {
+	return time.Duration(c.RefreshExpire) * time.Hour
+}
+
+type BorrowConfig struct {
+	HoldPickupDays int              `mapstructure:"hold_pickup_days"` // 预约到书后的保留天数
+	DefaultPolicy  LoanPolicyConfig `mapstructure:"default_policy"`   // 没有匹配的借阅规则时使用的默认规则
+	MaxOverdue     int              `mapstructure:"max_overdue"`      // 允许的逾期未还数量，超过则禁止借阅
+	FineThreshold  float64          `mapstructure:"fine_threshold"`   // 未缴罚金超过该金额时禁止借阅
+	RenewOverdue   int              `mapstructure:"renew_overdue"`    // 逾期超过该天数后不允许续借
+}
+
+type LoanPolicyConfig struct {
+	LoanDays    int     `mapstructure:"loan_days"`    // 借期（天）
+	MaxRenewals int     `mapstructure:"max_renewals"` // 最多续借次数
+	MaxLoans    int     `mapstructure:"max_loans"`    // 最多同时借阅数量 0-不限
+	DailyFine   float64 `mapstructure:"daily_fine"`   // 逾期每日罚金
+	FineCap     float64 `mapstructure:"fine_cap"`     // 单次借阅罚金上限 0-不设上限
+	GraceDays   int     `mapstructure:"grace_days"`   // 逾期宽限天数
+}
+
+type SchedulerConfig struct {
+	Enabled         bool `mapstructure:"enabled"`          // 是否启动后台任务
+	OverdueInterval int  `mapstructure:"overdue_interval"` // 逾期扫描间隔（分钟）
+	HoldInterval    int  `mapstructure:"hold_interval"`    // 预约过期处理间隔（分钟）
+	MailInterval    int  `mapstructure:"mail_interval"`    // 发件箱投递间隔（分钟）
+	LDAPInterval    int  `mapstructure:"ldap_interval"`    // LDAP目录同步间隔（分钟）
+	ImportInterval  int  `mapstructure:"import_interval"`  // 图书导入任务检查间隔（分钟），任务提交后会立即开始处理，定时任务处理中断的任务
+	AuditInterval   int  `mapstructure:"audit_interval"`   // 审计记录封链间隔（秒），记录提交后最多延迟该时间串入哈希链
+}
+
+type PaymentConfig struct {
+	Provider string `mapstructure:"provider"` // 支付渠道 fake-本地测试
+}
+
+type PasswordConfig struct {
+	Algorithm   string `mapstructure:"algorithm"`   // 密码哈希算法 argon2id/bcrypt
+	Memory      uint32 `mapstructure:"memory"`      // argon2id 内存开销（KiB）
+	Iterations  uint32 `mapstructure:"iterations"`  // argon2id 迭代次数
+	Parallelism uint8  `mapstructure:"parallelism"` // argon2id 并行度
+	BcryptCost  int    `mapstructure:"bcrypt_cost"` // bcrypt 计算开销
+}
+
+// AdminConfig 初始管理员，系统中没有启用的管理员时在启动阶段创建
+type AdminConfig struct {
+	Username string `mapstructure:"username"` // 用户名，为空时不创建
+	Password string `mapstructure:"password"` // 初始密码
+	Email    string `mapstructure:"email"`    // 邮箱
+}
+
+// LoginConfig 登录保护，按用户名和IP统计失败次数
+type LoginConfig struct {
+	MaxFailures   int     `mapstructure:"max_failures"`    // 窗口内同一用户名失败多少次后锁定账号
+	Window        int     `mapstructure:"window"`          // 统计失败次数的窗口（分钟）
+	LockBase      int     `mapstructure:"lock_base"`       // 首次锁定时长（分钟），之后每次锁定翻倍
+	LockMax       int     `mapstructure:"lock_max"`        // 锁定时长上限（分钟）
+	IPMaxFailures int     `mapstructure:"ip_max_failures"` // 窗口内同一IP的失败次数上限，超过后该IP暂时不能登录
+	RateLimit     float64 `mapstructure:"rate_limit"`      // 登录、注册、找回密码等公开接口每个IP每秒允许的请求数
+	RateBurst     int     `mapstructure:"rate_burst"`      // 突发请求数
+}
+
+// WindowDuration 统计失败次数的窗口
+func (c LoginConfig) WindowDuration() time.Duration {
+	return time.Duration(c.Window) * time.Minute
+}
+
+// LockBaseDuration 首次锁定时长
+func (c LoginConfig) LockBaseDuration() time.Duration {
+	return time.Duration(c.LockBase) * time.Minute
+}
+
+// LockMaxDuration 锁定时长上限
+func (c LoginConfig) LockMaxDuration() time.Duration {
+	return time.Duration(c.LockMax) * time.Minute
+}
+
+// MailConfig 发信配置，邮件先写入发件箱，由后台任务投递
+type MailConfig struct {
+	Driver       string `mapstructure:"driver"`        // 发信方式 smtp/file/memory
+	Host         string `mapstructure:"host"`          // SMTP服务器
+	Port         int    `mapstructure:"port"`          // SMTP端口
+	Username     string `mapstructure:"username"`      // SMTP用户名
+	Password     string `mapstructure:"password"`      // SMTP密码
+	From         string `mapstructure:"from"`          // 发件人地址
+	Dir          string `mapstructure:"dir"`           // file 方式保存邮件的目录
+	Lang         string `mapstructure:"lang"`          // 默认邮件语言 zh/en
+	LinkBase     string `mapstructure:"link_base"`     // 邮件中链接指向的前端地址
+	ResetExpire  int    `mapstructure:"reset_expire"`  // 重置密码链接有效期（分钟）
+	VerifyExpire int    `mapstructure:"verify_expire"` // 验证邮箱链接有效期（分钟）
+	MaxAttempts  int    `mapstructure:"max_attempts"`  // 最多投递次数，超过后标记为发送失败
+	BatchSize    int    `mapstructure:"batch_size"`    // 每次投递的邮件数量
+}
+
+// TwoFactorConfig 双因素认证（TOTP）
+type TwoFactorConfig struct {
+	Issuer        string   `mapstructure:"issuer"`         // 验证器App中显示的发行方名称
+	RequiredRoles []string `mapstructure:"required_roles"` // 必须启用双因素认证的角色，未启用的账号登录时需先完成绑定
+	InterimExpire int      `mapstructure:"interim_expire"` // 登录第一步返回的临时令牌有效期（分钟）
+	SecretKey     string   `mapstructure:"secret_key"`     // 加密保存TOTP密钥的密钥，为空时由JWT密钥派生
+}
+
+// InterimTTL 临时令牌有效期
+func (c TwoFactorConfig) InterimTTL() time.Duration {
+	return time.Duration(c.InterimExpire) * time.Minute
+}
+
+// APIKeyConfig 机器客户端的API密钥
+type APIKeyConfig struct {
+	RateLimit     float64 `mapstructure:"rate_limit"`      // 未单独设置时每个密钥每秒允许的请求数
+	RateBurst     int     `mapstructure:"rate_burst"`      // 未单独设置时的突发请求数
+	MaxExpireDays int     `mapstructure:"max_expire_days"` // 密钥有效期上限（天），0 表示允许不过期的密钥
+	TouchInterval int     `mapstructure:"touch_interval"`  // 更新最近使用时间的最小间隔（秒），避免每个请求都写数据库
+}
+
+type SearchConfig struct {
+	Engine string `mapstructure:"engine"` // 检索引擎 mysql-全文索引（ngram解析器） memory-内存倒排索引，仅用于测试和单实例开发环境
+}
+
+// MaxTTL 密钥有效期上限，0 表示不限制
+func (c APIKeyConfig) MaxTTL() time.Duration {
+	return time.Duration(c.MaxExpireDays) * 24 * time.Hour
+}
+
+// TouchDuration 更新最近使用时间的最小间隔
+func (c APIKeyConfig) TouchDuration() time.Duration {
+	return time.Duration(c.TouchInterval) * time.Second
+}
+
+// SSOConfig 单点登录
+type SSOConfig struct {
+	StateExpire int                  `mapstructure:"state_expire"` // 发起登录到回调之间的有效期（分钟）
+	DefaultRole string               `mapstructure:"default_role"` // 首次登录自动创建的账号的角色
+	OIDC        []OIDCProviderConfig `mapstructure:"oidc"`         // OpenID Connect 身份提供方
+}
+
+// StateTTL 登录请求的有效期
+func (c SSOConfig) StateTTL() time.Duration {
+	return time.Duration(c.StateExpire) * time.Minute
+}
+
+// OIDCProviderConfig OpenID Connect 身份提供方
+type OIDCProviderConfig struct {
+	Name          string   `mapstructure:"name"`           // 提供方标识，用于接口路径和关联外部身份，配置后不要修改
+	DisplayName   string   `mapstructure:"display_name"`   // 登录页面显示的名称
+	Issuer        string   `mapstructure:"issuer"`         // 发行方地址，从 /.well-known/openid-configuration 读取端点和公钥
+	ClientID      string   `mapstructure:"client_id"`      // 客户端ID
+	ClientSecret  string   `mapstructure:"client_secret"`  // 客户端密钥，公开客户端留空，仅使用PKCE
+	RedirectURL   string   `mapstructure:"redirect_url"`   // 回调地址，通常为前端页面，由前端将 code 和 state 提交给回调接口
+	Scopes        []string `mapstructure:"scopes"`         // 申请的scope，openid 会自动加入
+	UsernameClaim string   `mapstructure:"username_claim"` // 自动创建账号时作为用户名的声明
+	LinkByEmail   bool     `mapstructure:"link_by_email"`  // 首次登录时按已验证的邮箱关联已有账号，只应对可信的提供方开启
+}
+
+// LDAPConfig LDAP目录认证
+type LDAPConfig struct {
+	Enabled            bool            `mapstructure:"enabled"`              // 是否启用LDAP登录
+	Name               string          `mapstructure:"name"`                 // 提供方标识，登录时 provider 传该值，配置后不要修改
+	DisplayName        string          `mapstructure:"display_name"`         // 登录页面显示的名称
+	URL                string          `mapstructure:"url"`                  // 目录地址 ldap://host:389 或 ldaps://host:636
+	StartTLS           bool            `mapstructure:"start_tls"`            // ldap:// 连接是否升级为TLS
+	InsecureSkipVerify bool            `mapstructure:"insecure_skip_verify"` // 跳过证书校验，仅用于测试环境
+	Timeout            int             `mapstructure:"timeout"`              // 连接和请求超时（秒）
+	BindDN             string          `mapstructure:"bind_dn"`              // 查找用户使用的服务账号
+	BindPassword       string          `mapstructure:"bind_password"`        // 服务账号密码
+	BaseDN             string          `mapstructure:"base_dn"`              // 查找用户的起始节点
+	UserFilter         string          `mapstructure:"user_filter"`          // 查找用户的过滤器，%s 替换为转义后的用户名
+	UsernameAttr       string          `mapstructure:"username_attr"`        // 用户名属性
+	NicknameAttr       string          `mapstructure:"nickname_attr"`        // 昵称属性
+	EmailAttr          string          `mapstructure:"email_attr"`           // 邮箱属性
+	PhoneAttr          string          `mapstructure:"phone_attr"`           // 电话属性
+	GroupAttr          string          `mapstructure:"group_attr"`           // 用户所属组的属性，值为组的DN
+	GroupRoles         []LDAPGroupRole `mapstructure:"group_roles"`          // 组到角色的映射，按顺序取第一个匹配的组
+	DefaultRole        string          `mapstructure:"default_role"`         // 不属于任何映射组的用户的角色
+}
+
+// LDAPGroupRole LDAP组到角色的映射
+type LDAPGroupRole struct {
+	Group string `mapstructure:"group"` // 组的DN，不区分大小写
+	Role  string `mapstructure:"role"`  // 角色
+}
+
+// TimeoutDuration 连接和请求超时
+func (c LDAPConfig) TimeoutDuration() time.Duration {
+	return time.Duration(c.Timeout) * time.Second
 }
 
 var GlobalConfig Config
@@ -48,6 +254,65 @@ func InitConfig() error {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath("./config")
 
+	viper.SetDefault("jwt.access_expire", 15)
+	viper.SetDefault("jwt.refresh_expire", 168)
+	viper.SetDefault("borrow.hold_pickup_days", 3)
+	viper.SetDefault("borrow.default_policy.loan_days", 30)
+	viper.SetDefault("borrow.default_policy.max_renewals", 1)
+	viper.SetDefault("borrow.default_policy.daily_fine", 0.5)
+	viper.SetDefault("borrow.max_overdue", 0)
+	viper.SetDefault("borrow.fine_threshold", 10)
+	viper.SetDefault("borrow.renew_overdue", 0)
+	viper.SetDefault("scheduler.enabled", true)
+	viper.SetDefault("scheduler.overdue_interval", 60)
+	viper.SetDefault("scheduler.hold_interval", 30)
+	viper.SetDefault("scheduler.mail_interval", 1)
+	viper.SetDefault("scheduler.ldap_interval", 60)
+	viper.SetDefault("scheduler.import_interval", 1)
+	viper.SetDefault("scheduler.audit_interval", 5)
+	viper.SetDefault("payment.provider", "fake")
+	viper.SetDefault("password.algorithm", "argon2id")
+	viper.SetDefault("password.memory", 64*1024)
+	viper.SetDefault("password.iterations", 3)
+	viper.SetDefault("password.parallelism", 2)
+	viper.SetDefault("password.bcrypt_cost", 12)
+	viper.SetDefault("login.max_failures", 5)
+	viper.SetDefault("login.window", 15)
+	viper.SetDefault("login.lock_base", 1)
+	viper.SetDefault("login.lock_max", 60)
+	viper.SetDefault("login.ip_max_failures", 50)
+	viper.SetDefault("login.rate_limit", 1)
+	viper.SetDefault("login.rate_burst", 5)
+	viper.SetDefault("mail.driver", "file")
+	viper.SetDefault("mail.port", 587)
+	viper.SetDefault("mail.from", "library@localhost")
+	viper.SetDefault("mail.dir", "./mail")
+	viper.SetDefault("mail.lang", "zh")
+	viper.SetDefault("mail.link_base", "http://localhost:3000")
+	viper.SetDefault("mail.reset_expire", 30)
+	viper.SetDefault("mail.verify_expire", 2880)
+	viper.SetDefault("mail.max_attempts", 5)
+	viper.SetDefault("mail.batch_size", 50)
+	viper.SetDefault("two_factor.issuer", "Library")
+	viper.SetDefault("two_factor.interim_expire", 5)
+	viper.SetDefault("api_key.rate_limit", 10)
+	viper.SetDefault("api_key.rate_burst", 20)
+	viper.SetDefault("api_key.max_expire_days", 365)
+	viper.SetDefault("api_key.touch_interval", 60)
+	viper.SetDefault("search.engine", "mysql")
+	viper.SetDefault("sso.state_expire", 10)
+	viper.SetDefault("sso.default_role", "user")
+	viper.SetDefault("ldap.name", "ldap")
+	viper.SetDefault("ldap.display_name", "LDAP")
+	viper.SetDefault("ldap.timeout", 10)
+	viper.SetDefault("ldap.user_filter", "(uid=%s)")
+	viper.SetDefault("ldap.username_attr", "uid")
+	viper.SetDefault("ldap.nickname_attr", "displayName")
+	viper.SetDefault("ldap.email_attr", "mail")
+	viper.SetDefault("ldap.phone_attr", "telephoneNumber")
+	viper.SetDefault("ldap.group_attr", "memberOf")
+	viper.SetDefault("ldap.default_role", "user")
+
 	if err := viper.ReadInConfig(); err != nil {
 		return err
 	}
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 登录保护状态在Redis中的键前缀
+const (
+	loginFailPrefix  = "login:fail:"    // 窗口内的失败次数，按用户名（user:）和IP（ip:）分别统计
+	loginLockPrefix  = "login:lock:"    // 账号锁定，键过期即解锁
+	loginLevelPrefix = "login:level:"   // 账号连续被锁定的次数，用于计算退避时长
+	loginFailureLog  = "login:failures" // 最近的失败记录
+)
+
+// loginFailureLogSize 保留的失败记录条数
+const loginFailureLogSize = 500
+
+// LoginFailure 一次失败的登录尝试
+type LoginFailure struct {
+	Username string    `json:"username"` // 尝试登录的用户名
+	IP       string    `json:"ip"`       // 客户端IP
+	Reason   string    `json:"reason"`   // 失败原因
+	At       time.Time `json:"at"`       // 发生时间
+}
+
+// LoginUserKey 按用户名统计失败次数的键，用户名不区分大小写
+func LoginUserKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+// LoginIPKey 按IP统计失败次数的键
+func LoginIPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// incr 计数加一。键不存在时以ttl为有效期新建，refresh为true时每次都重置有效期
+func (m *memoryTokenStore) incr(key string, ttl time.Duration, refresh bool) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.evict()
+	e, ok := m.keys[key]
+	if !ok {
+		e = memoryEntry{value: "0"}
+	}
+	if !ok || refresh {
+		e.expireAt = time.Now().Add(ttl)
+	}
+	n, _ := strconv.ParseInt(e.value, 10, 64)
+	n++
+	e.value = strconv.FormatInt(n, 10)
+	m.keys[key] = e
+	return n
+}
+
+func (m *memoryTokenStore) ttl(key string) (string, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.keys[key]
+	if !ok {
+		return "", 0
+	}
+	d := time.Until(e.expireAt)
+	if d <= 0 {
+		return "", 0
+	}
+	return e.value, d
+}
+
+func (m *memoryTokenStore) del(keys ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range keys {
+		delete(m.keys, k)
+	}
+}
+
+func (m *memoryTokenStore) pushFailure(data string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures = append([]string{data}, m.failures...)
+	if len(m.failures) > loginFailureLogSize {
+		m.failures = m.failures[:loginFailureLogSize]
+	}
+}
+
+// incrScript 计数加一并设置有效期，两步在Redis中原子执行，不会留下没有有效期的计数。
+// ARGV[2]为1时每次都重置有效期，否则只在新建计数（或计数没有有效期）时设置
+var incrScript = redis.NewScript(`
+local n = redis.call("incr", KEYS[1])
+if ARGV[2] == "1" or n == 1 or redis.call("pttl", KEYS[1]) < 0 then
+	redis.call("pexpire", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// incrWithTTL 计数加一并设置有效期，返回加一后的计数
+func incrWithTTL(ctx context.Context, key string, ttl time.Duration, refresh bool) (int64, error) {
+	if RedisClient == nil {
+		return memoryTokens.incr(key, ttl, refresh), nil
+	}
+	flag := "0"
+	if refresh {
+		flag = "1"
+	}
+	return incrScript.Run(ctx, RedisClient, []string{key}, ttl.Milliseconds(), flag).Int64()
+}
+
+// IncrLoginFailures 失败次数加一，返回窗口内的失败次数。窗口从第一次失败开始计算
+func IncrLoginFailures(ctx context.Context, key string, window time.Duration) (int64, error) {
+	n, err := incrWithTTL(ctx, loginFailPrefix+key, window, false)
+	if err != nil {
+		return 0, fmt.Errorf("incr login failures %s: %v", key, err)
+	}
+	return n, nil
+}
+
+// GetLoginFailures 获取窗口内的失败次数和窗口的剩余时间
+func GetLoginFailures(ctx context.Context, key string) (int64, time.Duration, error) {
+	if RedisClient == nil {
+		value, ttl := memoryTokens.ttl(loginFailPrefix + key)
+		n, _ := strconv.ParseInt(value, 10, 64)
+		return n, ttl, nil
+	}
+	n, err := RedisClient.Get(ctx, loginFailPrefix+key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("get login failures %s: %v", key, err)
+	}
+	ttl, err := RedisClient.PTTL(ctx, loginFailPrefix+key).Result()
+	if err != nil {
+		return 0, 0, fmt.Errorf("get login failures ttl %s: %v", key, err)
+	}
+	return n, ttl, nil
+}
+
+// ClearLoginFailures 清除失败次数
+func ClearLoginFailures(ctx context.Context, keys ...string) error {
+	full := make([]string, 0, len(keys))
+	for _, k := range keys {
+		full = append(full, loginFailPrefix+k)
+	}
+	if RedisClient == nil {
+		memoryTokens.del(full...)
+		return nil
+	}
+	if err := RedisClient.Del(ctx, full...).Err(); err != nil {
+		return fmt.Errorf("clear login failures: %v", err)
+	}
+	return nil
+}
+
+// IncrLoginLockLevel 账号被锁定的次数加一，levelTTL内没有再次被锁定时归零
+func IncrLoginLockLevel(ctx context.Context, username string, levelTTL time.Duration) (int64, error) {
+	n, err := incrWithTTL(ctx, loginLevelPrefix+strings.ToLower(username), levelTTL, true)
+	if err != nil {
+		return 0, fmt.Errorf("incr login lock level %s: %v", username, err)
+	}
+	return n, nil
+}
+
+// LockLogin 锁定账号的登录，ttl后自动解锁
+func LockLogin(ctx context.Context, username string, ttl time.Duration) error {
+	key := loginLockPrefix + strings.ToLower(username)
+	if RedisClient == nil {
+		memoryTokens.set(key, "1", ttl)
+		return nil
+	}
+	if err := RedisClient.Set(ctx, key, "1", ttl).Err(); err != nil {
+		return fmt.Errorf("lock login %s: %v", username, err)
+	}
+	return nil
+}
+
+// GetLoginLock 获取账号锁定的剩余时间，未锁定时为0
+func GetLoginLock(ctx context.Context, username string) (time.Duration, error) {
+	key := loginLockPrefix + strings.ToLower(username)
+	if RedisClient == nil {
+		_, ttl := memoryTokens.ttl(key)
+		return ttl, nil
+	}
+	ttl, err := RedisClient.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("get login lock %s: %v", username, err)
+	}
+	if ttl < 0 { // -2 键不存在，-1 没有过期时间（不应出现）
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// UnlockLogin 解除账号锁定，同时清除失败次数和退避等级
+func UnlockLogin(ctx context.Context, username string) error {
+	name := strings.ToLower(username)
+	keys := []string{loginLockPrefix + name, loginLevelPrefix + name, loginFailPrefix + LoginUserKey(username)}
+	if RedisClient == nil {
+		memoryTokens.del(keys...)
+		return nil
+	}
+	if err := RedisClient.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("unlock login %s: %v", username, err)
+	}
+	return nil
+}
+
+// AppendLoginFailure 记录一次失败的登录尝试，只保留最近的 loginFailureLogSize 条
+func AppendLoginFailure(ctx context.Context, f LoginFailure) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("marshal login failure: %v", err)
+	}
+	if RedisClient == nil {
+		memoryTokens.pushFailure(string(data))
+		return nil
+	}
+	_, err = RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, loginFailureLog, data)
+		pipe.LTrim(ctx, loginFailureLog, 0, loginFailureLogSize-1)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("append login failure: %v", err)
+	}
+	return nil
+}
+
+// ListLoginFailures 获取最近的失败记录，按时间倒序
+func ListLoginFailures(ctx context.Context) ([]LoginFailure, error) {
+	var items []string
+	if RedisClient == nil {
+		memoryTokens.mu.Lock()
+		items = append(items, memoryTokens.failures...)
+		memoryTokens.mu.Unlock()
+	} else {
+		var err error
+		items, err = RedisClient.LRange(ctx, loginFailureLog, 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("list login failures: %v", err)
+		}
+	}
+
+	list := make([]LoginFailure, 0, len(items))
+	for _, item := range items {
+		var f LoginFailure
+		if err := json.Unmarshal([]byte(item), &f); err != nil {
+			continue
+		}
+		list = append(list, f)
+	}
+	return list, nil
+}
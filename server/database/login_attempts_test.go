@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// 未配置Redis，测试进程内的实现
+func TestIncrWithTTL(t *testing.T) {
+	ctx := context.Background()
+
+	// 失败次数的窗口从第一次失败开始，之后的失败不延长窗口
+	for i := int64(1); i <= 3; i++ {
+		n, err := IncrLoginFailures(ctx, LoginUserKey("Window"), time.Hour)
+		if err != nil || n != i {
+			t.Fatalf("IncrLoginFailures = %d, %v, want %d", n, err, i)
+		}
+	}
+	n, ttl, err := GetLoginFailures(ctx, LoginUserKey("window"))
+	if err != nil || n != 3 || ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("GetLoginFailures = %d, %v, %v, want 3 within the window", n, ttl, err)
+	}
+
+	const key = "login:test:ttl"
+	memoryTokens.incr(key, time.Hour, false)
+	memoryTokens.incr(key, 2*time.Hour, false)
+	if _, ttl := memoryTokens.ttl(key); ttl > time.Hour {
+		t.Fatalf("ttl = %v, want the window of the first increment", ttl)
+	}
+	// 退避等级每次锁定都重置有效期
+	if n := memoryTokens.incr(key, 2*time.Hour, true); n != 3 {
+		t.Fatalf("incr = %d, want 3", n)
+	}
+	if _, ttl := memoryTokens.ttl(key); ttl <= time.Hour {
+		t.Fatalf("ttl = %v, want the refreshed ttl", ttl)
+	}
+
+	// 过期后重新计数
+	memoryTokens.set(key, "5", -time.Second)
+	if n := memoryTokens.incr(key, time.Hour, false); n != 1 {
+		t.Fatalf("incr after expiry = %d, want 1", n)
+	}
+}
+
+func TestLoginLockLevel(t *testing.T) {
+	ctx := context.Background()
+	for i := int64(1); i <= 2; i++ {
+		n, err := IncrLoginLockLevel(ctx, "Level", 24*time.Hour)
+		if err != nil || n != i {
+			t.Fatalf("IncrLoginLockLevel = %d, %v, want %d", n, err, i)
+		}
+	}
+	if err := LockLogin(ctx, "Level", time.Minute); err != nil {
+		t.Fatalf("LockLogin: %v", err)
+	}
+	if ttl, err := GetLoginLock(ctx, "level"); err != nil || ttl <= 0 {
+		t.Fatalf("GetLoginLock = %v, %v, want locked", ttl, err)
+	}
+
+	if err := UnlockLogin(ctx, "LEVEL"); err != nil {
+		t.Fatalf("UnlockLogin: %v", err)
+	}
+	if ttl, err := GetLoginLock(ctx, "level"); err != nil || ttl != 0 {
+		t.Fatalf("GetLoginLock after unlock = %v, %v, want not locked", ttl, err)
+	}
+	if n, err := IncrLoginLockLevel(ctx, "level", 24*time.Hour); err != nil || n != 1 {
+		t.Fatalf("IncrLoginLockLevel after unlock = %d, %v, want 1", n, err)
+	}
+}
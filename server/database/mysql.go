@@ -10,6 +10,7 @@ import (
 	"gorm.io/gorm/logger"
 
 	"library/model"
+	"library/search"
 
 	"library/config"
 )
@@ -52,23 +53,94 @@ func InitMySQL() error {
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
 	// 自动迁移数据库表
-	err = autoMigrate(db)
+	err = Migrate(db)
 	if err != nil {
 		return fmt.Errorf("failed to auto migrate: %v", err)
 	}
 
+	if config.GlobalConfig.Search.Engine == search.EngineMySQL {
+		if err := ensureFullTextIndex(db, "books", "idx_books_fulltext", "title, author, publisher, summary"); err != nil {
+			return fmt.Errorf("failed to create full-text index: %v", err)
+		}
+	}
+
 	DB = db
 	return nil
 }
 
-// autoMigrate 自动迁移数据库表
-func autoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+// Migrate 自动迁移数据库表
+func Migrate(db *gorm.DB) error {
+	err := db.AutoMigrate(
 		&model.User{},
 		&model.Book{},
 		&model.Borrow{},
 		&model.Review{},
+		&model.Reservation{},
+		&model.LoanPolicy{},
+		&model.JobRun{},
+		&model.FineTransaction{},
+		&model.Copy{},
+		&model.AuditLog{},
+		&model.AuditChainHead{},
+		&model.RolePermission{},
+		&model.RoleSeed{},
+		&model.MailOutbox{},
+		&model.RecoveryCode{},
+		&model.ExternalIdentity{},
+		&model.APIKey{},
+		&model.ImportJob{},
+		&model.ImportJobRow{},
 	)
+	if err != nil {
+		return err
+	}
+	if err := backfillAuditSeq(db); err != nil {
+		return err
+	}
+	if err := clearEmptyEmails(db); err != nil {
+		return err
+	}
+	// 按拼音检索图书依赖该索引，与检索引擎的配置无关。其他数据库（测试使用的SQLite）没有全文索引，按子串匹配
+	if db.Dialector.Name() == "mysql" {
+		return ensureFullTextIndex(db, "books", "idx_books_pinyin", "title_pinyin, author_pinyin")
+	}
+	return nil
+}
+
+// backfillAuditSeq 为引入序号之前已串链的审计记录补充序号。当时记录在链头的行锁下按ID顺序追加，序号取ID即可保持链的顺序
+func backfillAuditSeq(db *gorm.DB) error {
+	if err := db.Model(&model.AuditLog{}).Where("seq = ? AND hash <> ?", 0, "").
+		Update("seq", gorm.Expr("id")).Error; err != nil {
+		return err
+	}
+	return db.Model(&model.AuditChainHead{}).Where("last_seq = ? AND last_id > ?", 0, 0).
+		Update("last_seq", gorm.Expr("last_id")).Error
+}
+
+// clearEmptyEmails 将之前未填写邮箱时保存的空字符串改为NULL。邮箱有唯一索引，空字符串只能有一个账号使用
+func clearEmptyEmails(db *gorm.DB) error {
+	return db.Unscoped().Model(&model.User{}).Where("email = ?", "").Update("email", nil).Error
+}
+
+// ensureFullTextIndex 创建全文索引。索引使用ngram解析器，GORM的迁移无法声明，需单独创建。
+// 默认停用词表包含 a、i 等单个字母，ngram切分出的词包含停用词时不会被索引，拼音和英文的大部分两字母组合都会丢失，
+// 因此创建索引时关闭停用词（该设置在创建索引时生效）
+func ensureFullTextIndex(db *gorm.DB, table, index, columns string) error {
+	var count int64
+	err := db.Raw("SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?",
+		table, index).Scan(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SET SESSION innodb_ft_enable_stopword = 0").Error; err != nil {
+			return err
+		}
+		return conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD FULLTEXT INDEX %s (%s) WITH PARSER ngram", table, index, columns)).Error
+	})
 }
 
 // CloseDB 关闭数据库连接
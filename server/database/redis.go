@@ -3,6 +3,8 @@ package database
 import (
 	"context"
 	"fmt"
+	"log"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	
@@ -25,12 +27,45 @@ func InitRedis() error {
 	ctx := context.Background()
 	_, err := RedisClient.Ping(ctx).Result()
 	if err != nil {
+		RedisClient.Close()
+		RedisClient = nil
 		return fmt.Errorf("failed to connect to redis: %v", err)
 	}
 
 	return nil
 }
 
+// unlockScript 仅当锁仍由自己持有时才删除，避免误删其他实例的锁
+var unlockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+// AcquireLock 获取分布式锁，成功时返回释放函数。
+// 未配置Redis时视为单实例部署，直接获取成功。
+func AcquireLock(ctx context.Context, key, owner string, ttl time.Duration) (func(), bool, error) {
+	if RedisClient == nil {
+		return func() {}, true, nil
+	}
+
+	ok, err := RedisClient.SetNX(ctx, key, owner, ttl).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("acquire lock %s: %v", key, err)
+	}
+	if !ok {
+		return nil, false, nil
+	}
+
+	release := func() {
+		if err := unlockScript.Run(context.Background(), RedisClient, []string{key}, owner).Err(); err != nil {
+			log.Printf("Error releasing lock %s: %v", key, err)
+		}
+	}
+	return release, true, nil
+}
+
 // CloseRedis 关闭Redis连接
 func CloseRedis() {
 	if RedisClient != nil {
@@ -0,0 +1,245 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 令牌状态在Redis中的键前缀
+const (
+	revokedTokenPrefix = "token:revoked:" // 已注销的访问令牌 jti
+	refreshTokenPrefix = "token:refresh:" // 有效的刷新令牌 jti -> 用户ID
+	tokenVersionPrefix = "token:version:" // 用户的令牌版本号，递增后该用户之前签发的令牌全部失效
+	oneTimeTokenPrefix = "token:once:"    // 一次性令牌（重置密码、验证邮箱） 用途:jti -> 用户ID
+	oauthStatePrefix   = "token:oauth:"   // 单点登录的state -> 登录请求参数
+)
+
+// memoryTokens 未配置Redis时使用的进程内令牌和登录保护状态，仅适用于单实例部署，重启后丢失
+var memoryTokens = &memoryTokenStore{
+	keys:     make(map[string]memoryEntry),
+	versions: make(map[uint]int64),
+}
+
+type memoryEntry struct {
+	value    string
+	expireAt time.Time
+}
+
+type memoryTokenStore struct {
+	mu       sync.Mutex
+	keys     map[string]memoryEntry
+	versions map[uint]int64
+	failures []string // 最近的登录失败记录，最新的在前
+}
+
+func (m *memoryTokenStore) set(key, value string, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.evict()
+	m.keys[key] = memoryEntry{value: value, expireAt: time.Now().Add(ttl)}
+}
+
+func (m *memoryTokenStore) get(key string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.keys[key]
+	if !ok || time.Now().After(e.expireAt) {
+		return "", false
+	}
+	return e.value, true
+}
+
+func (m *memoryTokenStore) getDel(key string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.keys[key]
+	delete(m.keys, key)
+	if !ok || time.Now().After(e.expireAt) {
+		return "", false
+	}
+	return e.value, true
+}
+
+// evict 清理已过期的键，调用方需持有锁
+func (m *memoryTokenStore) evict() {
+	now := time.Now()
+	for k, e := range m.keys {
+		if now.After(e.expireAt) {
+			delete(m.keys, k)
+		}
+	}
+}
+
+// RevokeToken 注销访问令牌，ttl取令牌的剩余有效期，过期后记录自动清除
+func RevokeToken(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	if RedisClient == nil {
+		memoryTokens.set(revokedTokenPrefix+jti, "1", ttl)
+		return nil
+	}
+	if err := RedisClient.Set(ctx, revokedTokenPrefix+jti, "1", ttl).Err(); err != nil {
+		return fmt.Errorf("revoke token %s: %v", jti, err)
+	}
+	return nil
+}
+
+// IsTokenRevoked 访问令牌是否已注销
+func IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	if RedisClient == nil {
+		_, ok := memoryTokens.get(revokedTokenPrefix + jti)
+		return ok, nil
+	}
+	n, err := RedisClient.Exists(ctx, revokedTokenPrefix+jti).Result()
+	if err != nil {
+		return false, fmt.Errorf("check revoked token %s: %v", jti, err)
+	}
+	return n > 0, nil
+}
+
+// SaveRefreshToken 登记新签发的刷新令牌
+func SaveRefreshToken(ctx context.Context, jti string, userID uint, ttl time.Duration) error {
+	value := strconv.FormatUint(uint64(userID), 10)
+	if RedisClient == nil {
+		memoryTokens.set(refreshTokenPrefix+jti, value, ttl)
+		return nil
+	}
+	if err := RedisClient.Set(ctx, refreshTokenPrefix+jti, value, ttl).Err(); err != nil {
+		return fmt.Errorf("save refresh token %s: %v", jti, err)
+	}
+	return nil
+}
+
+// ConsumeRefreshToken 原子地取出并删除刷新令牌，每个刷新令牌只能使用一次。
+// 返回令牌登记的用户ID，令牌不存在（已使用、已注销或已过期）时ok为false
+func ConsumeRefreshToken(ctx context.Context, jti string) (userID uint, ok bool, err error) {
+	var value string
+	if RedisClient == nil {
+		value, ok = memoryTokens.getDel(refreshTokenPrefix + jti)
+		if !ok {
+			return 0, false, nil
+		}
+	} else {
+		value, err = RedisClient.GetDel(ctx, refreshTokenPrefix+jti).Result()
+		if errors.Is(err, redis.Nil) {
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, fmt.Errorf("consume refresh token %s: %v", jti, err)
+		}
+	}
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("parse refresh token owner %s: %v", jti, err)
+	}
+	return uint(id), true, nil
+}
+
+// SaveOneTimeToken 登记一次性令牌，purpose区分令牌用途
+func SaveOneTimeToken(ctx context.Context, purpose, jti string, userID uint, ttl time.Duration) error {
+	key := oneTimeTokenPrefix + purpose + ":" + jti
+	value := strconv.FormatUint(uint64(userID), 10)
+	if RedisClient == nil {
+		memoryTokens.set(key, value, ttl)
+		return nil
+	}
+	if err := RedisClient.Set(ctx, key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("save %s token %s: %v", purpose, jti, err)
+	}
+	return nil
+}
+
+// ConsumeOneTimeToken 原子地取出并删除一次性令牌，令牌不存在（已使用或已过期）时ok为false
+func ConsumeOneTimeToken(ctx context.Context, purpose, jti string) (userID uint, ok bool, err error) {
+	key := oneTimeTokenPrefix + purpose + ":" + jti
+	var value string
+	if RedisClient == nil {
+		value, ok = memoryTokens.getDel(key)
+		if !ok {
+			return 0, false, nil
+		}
+	} else {
+		value, err = RedisClient.GetDel(ctx, key).Result()
+		if errors.Is(err, redis.Nil) {
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, fmt.Errorf("consume %s token %s: %v", purpose, jti, err)
+		}
+	}
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("parse %s token owner %s: %v", purpose, jti, err)
+	}
+	return uint(id), true, nil
+}
+
+// SaveOAuthState 保存单点登录请求的state和对应的参数（PKCE verifier、nonce等）
+func SaveOAuthState(ctx context.Context, state, value string, ttl time.Duration) error {
+	key := oauthStatePrefix + state
+	if RedisClient == nil {
+		memoryTokens.set(key, value, ttl)
+		return nil
+	}
+	if err := RedisClient.Set(ctx, key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("save oauth state: %v", err)
+	}
+	return nil
+}
+
+// ConsumeOAuthState 原子地取出并删除state对应的参数，state不存在（已使用或已过期）时ok为false
+func ConsumeOAuthState(ctx context.Context, state string) (value string, ok bool, err error) {
+	key := oauthStatePrefix + state
+	if RedisClient == nil {
+		value, ok = memoryTokens.getDel(key)
+		return value, ok, nil
+	}
+	value, err = RedisClient.GetDel(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("consume oauth state: %v", err)
+	}
+	return value, true, nil
+}
+
+// GetTokenVersion 获取用户当前的令牌版本号，从未注销过会话的用户为0
+func GetTokenVersion(ctx context.Context, userID uint) (int64, error) {
+	if RedisClient == nil {
+		memoryTokens.mu.Lock()
+		defer memoryTokens.mu.Unlock()
+		return memoryTokens.versions[userID], nil
+	}
+	v, err := RedisClient.Get(ctx, tokenVersionPrefix+strconv.FormatUint(uint64(userID), 10)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get token version of user %d: %v", userID, err)
+	}
+	return v, nil
+}
+
+// RevokeUserTokens 递增用户的令牌版本号，使该用户之前签发的访问令牌和刷新令牌全部失效
+func RevokeUserTokens(ctx context.Context, userID uint) error {
+	if RedisClient == nil {
+		memoryTokens.mu.Lock()
+		defer memoryTokens.mu.Unlock()
+		memoryTokens.versions[userID]++
+		return nil
+	}
+	if err := RedisClient.Incr(ctx, tokenVersionPrefix+strconv.FormatUint(uint64(userID), 10)).Err(); err != nil {
+		return fmt.Errorf("revoke tokens of user %d: %v", userID, err)
+	}
+	return nil
+}
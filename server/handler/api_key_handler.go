@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"errors"
+	"library/handler/request"
+	"library/handler/response"
+	"library/model"
+	"library/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	apiKeyService service.APIKeyServiceInterface
+}
+
+func NewAPIKeyHandler(apiKeyService service.APIKeyServiceInterface) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// errorStatus 将服务层错误映射为HTTP状态码
+func (h *APIKeyHandler) errorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidParameter):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidStatus):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CreateAPIKey 签发API密钥（管理员接口）
+// @Summary 签发API密钥
+// @Description 为自助借还机、同步脚本等机器客户端签发API密钥。密钥明文只在响应中返回一次，服务端只保存摘要。
+// @Description 调用接口时通过 X-API-Key 请求头或 Authorization: Bearer lib_... 传递，请求以所属用户的身份执行，权限为作用域与该用户角色权限的交集
+// @Tags API密钥
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request body request.CreateAPIKeyRequest true "密钥信息"
+// @Success 200 {object} response.Response "data.key 为密钥明文，data.api_key 为密钥信息"
+// @Failure 400 {object} response.Response "作用域无效或超出所属用户的权限"
+// @Failure 404 {object} response.Response "所属用户不存在"
+// @Failure 409 {object} response.Response "所属用户已禁用"
+// @Router /api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req request.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	key := &model.APIKey{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		UserID:    req.UserID,
+		RateLimit: req.RateLimit,
+		RateBurst: req.RateBurst,
+		ExpiresAt: req.ExpiresAt,
+	}
+	plain, err := h.apiKeyService.CreateKey(actorFrom(c), key)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "API key created, store it now as it will not be shown again", gin.H{
+		"key":     plain,
+		"api_key": key,
+	}))
+}
+
+// ListAPIKeys 获取API密钥列表（管理员接口）
+// @Summary 获取API密钥列表
+// @Description 获取API密钥列表，包括已吊销和已过期的密钥，可按所属用户筛选
+// @Tags API密钥
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param user_id query int false "所属用户ID"
+// @Success 200 {object} response.Response{data=[]model.APIKey}
+// @Router /api-keys [get]
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	var req request.APIKeyListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	keys, err := h.apiKeyService.ListKeys(req.UserID)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", keys))
+}
+
+// GetAPIKey 获取API密钥详情（管理员接口）
+// @Summary 获取API密钥详情
+// @Description 获取API密钥的作用域、有效期和最近使用情况
+// @Tags API密钥
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "密钥ID"
+// @Success 200 {object} response.Response{data=model.APIKey}
+// @Failure 404 {object} response.Response "密钥不存在"
+// @Router /api-keys/{id} [get]
+func (h *APIKeyHandler) GetAPIKey(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid API key ID", nil))
+		return
+	}
+
+	key, err := h.apiKeyService.GetKey(uri.ID)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", key))
+}
+
+// RotateAPIKey 轮换API密钥（管理员接口）
+// @Summary 轮换API密钥
+// @Description 生成新的密钥明文，旧密钥立即失效，名称、作用域和有效期保持不变。新密钥明文只在响应中返回一次
+// @Tags API密钥
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "密钥ID"
+// @Success 200 {object} response.Response "data.key 为新的密钥明文，data.api_key 为密钥信息"
+// @Failure 404 {object} response.Response "密钥不存在"
+// @Failure 409 {object} response.Response "密钥已吊销或已过期"
+// @Router /api-keys/{id}/rotate [post]
+func (h *APIKeyHandler) RotateAPIKey(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid API key ID", nil))
+		return
+	}
+
+	key, plain, err := h.apiKeyService.RotateKey(actorFrom(c), uri.ID)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "API key rotated, store it now as it will not be shown again", gin.H{
+		"key":     plain,
+		"api_key": key,
+	}))
+}
+
+// RevokeAPIKey 吊销API密钥（管理员接口）
+// @Summary 吊销API密钥
+// @Description 吊销API密钥，立即生效且不可恢复。已吊销的密钥再次吊销返回成功
+// @Tags API密钥
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "密钥ID"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.Response "密钥不存在"
+// @Router /api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid API key ID", nil))
+		return
+	}
+
+	if err := h.apiKeyService.RevokeKey(actorFrom(c), uri.ID); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "API key revoked", nil))
+}
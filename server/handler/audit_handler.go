@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"errors"
+	"library/handler/request"
+	"library/handler/response"
+	"library/repository/mysql"
+	"library/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	auditService service.AuditServiceInterface
+}
+
+func NewAuditHandler(auditService service.AuditServiceInterface) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// actorFrom 从请求上下文取出审计所需的操作者、客户端IP和请求ID
+func actorFrom(c *gin.Context) service.Actor {
+	return service.Actor{
+		ID:        c.GetUint("userID"),
+		IP:        c.ClientIP(),
+		RequestID: c.GetString("requestID"),
+	}
+}
+
+// ListAuditLogs 查询审计记录（管理员接口）
+// @Summary 查询审计记录
+// @Description 按操作者、操作类型、操作对象、请求ID和时间范围查询审计记录，按时间倒序返回。
+// @Description 每条记录包含变更前后的字段差异、客户端IP和请求ID，请求ID与响应头 X-Request-ID 一致
+// @Tags 审计日志
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request query request.AuditLogSearchRequest true "查询条件"
+// @Success 200 {object} response.Response{data=[]model.AuditLog}
+// @Router /audit-logs [get]
+func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
+	var req request.AuditLogSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	logs, total, err := h.auditService.ListLogs(&mysql.AuditLogFilter{
+		ActorID:    req.ActorID,
+		Action:     req.Action,
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		RequestID:  req.RequestID,
+		StartTime:  req.StartTime,
+		EndTime:    req.EndTime,
+		Page:       req.Page,
+		PageSize:   req.PageSize,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidParameter) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewPaginationResponse(logs, total, req.Page, req.PageSize))
+}
+
+// VerifyAuditChain 校验审计链（管理员接口）
+// @Summary 校验审计链
+// @Description 按顺序重新计算已封链的审计记录的哈希链，检查记录是否被修改、删除或插入。data.valid 为 false 时 data.broken_at 为第一条异常记录的ID，data.pending 为等待封链任务处理的记录数
+// @Tags 审计日志
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Success 200 {object} response.Response{data=model.AuditChainReport}
+// @Router /audit-logs/verify [get]
+func (h *AuditHandler) VerifyAuditChain(c *gin.Context) {
+	report, err := h.auditService.VerifyChain()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", report))
+}
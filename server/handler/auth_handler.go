@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"errors"
+	"library/handler/request"
+	"library/handler/response"
+	"library/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthHandler struct {
+	authService      service.AuthServiceInterface
+	twoFactorService service.TwoFactorServiceInterface
+}
+
+func NewAuthHandler(authService service.AuthServiceInterface, twoFactorService service.TwoFactorServiceInterface) *AuthHandler {
+	return &AuthHandler{
+		authService:      authService,
+		twoFactorService: twoFactorService,
+	}
+}
+
+// errorStatus 将服务层错误映射为HTTP状态码
+func (h *AuthHandler) errorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUnknownProvider):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidToken):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrAccountDisabled):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrAlreadyExists):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrProviderUnavailable):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ListProviders 获取可用的登录方式
+// @Summary 获取登录方式
+// @Description 获取已配置的认证提供方。kind=password 的提供方调用 /users/login 登录，kind=redirect 的提供方跳转到身份提供方登录
+// @Tags 单点登录
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=[]service.ProviderInfo}
+// @Router /auth/providers [get]
+func (h *AuthHandler) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", h.authService.Providers()))
+}
+
+// Authorize 发起单点登录
+// @Summary 发起单点登录
+// @Description 生成state和PKCE参数，返回身份提供方的登录地址，前端跳转到该地址。登录完成后身份提供方重定向到配置的回调地址
+// @Tags 单点登录
+// @Accept json
+// @Produce json
+// @Param provider path string true "认证提供方标识"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.Response "认证提供方不存在"
+// @Failure 502 {object} response.Response "身份提供方无法访问"
+// @Router /auth/oidc/{provider}/authorize [get]
+func (h *AuthHandler) Authorize(c *gin.Context) {
+	var uri request.ProviderRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid provider", nil))
+		return
+	}
+
+	authURL, err := h.authService.StartLogin(uri.Provider)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", gin.H{
+		"auth_url": authURL,
+	}))
+}
+
+// Callback 完成单点登录
+// @Summary 完成单点登录
+// @Description 提交身份提供方回调中的 code 和 state，换取令牌对。首次登录时自动创建账号并关联外部身份。
+// @Description 已启用双因素认证的账号与用户名密码登录一样返回临时令牌
+// @Tags 单点登录
+// @Accept json
+// @Produce json
+// @Param provider path string true "认证提供方标识"
+// @Param request body request.SSOCallbackRequest true "授权码和state"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response "state或授权码无效"
+// @Failure 403 {object} response.Response "账号已禁用"
+// @Failure 502 {object} response.Response "身份提供方无法访问"
+// @Router /auth/oidc/{provider}/callback [post]
+func (h *AuthHandler) Callback(c *gin.Context) {
+	var uri request.ProviderRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid provider", nil))
+		return
+	}
+	var req request.SSOCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	user, err := h.authService.FinishLogin(uri.Provider, req.State, req.Code)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	completeLogin(c, h.twoFactorService, user)
+}
+
+// ListIdentities 获取当前用户关联的外部身份
+// @Summary 获取关联的外部身份
+// @Description 获取当前用户通过单点登录关联的外部身份
+// @Tags 单点登录
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Success 200 {object} response.Response{data=[]model.ExternalIdentity}
+// @Router /users/identities [get]
+func (h *AuthHandler) ListIdentities(c *gin.Context) {
+	identities, err := h.authService.ListIdentities(c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", identities))
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"library/handler/request"
 	"library/handler/response"
 	"library/model"
@@ -23,15 +24,6 @@ func NewBookHandler(bookService service.BookServiceInterface) *BookHandler {
 	}
 }
 
-func (h *BookHandler) authMiddleware(c *gin.Context) {
-	role, exists := c.Get("role")
-	if !exists || role.(string) != "admin" {
-		c.JSON(http.StatusForbidden, response.NewResponse(http.StatusForbidden, "Permission denied", nil))
-		c.Abort()
-		return
-	}
-}
-
 // CreateBook 创建图书 （管理员接口）
 // @Summary 创建新图书
 // @Description 管理员创建新图书，包含ISBN、书名、作者等基本信息
@@ -44,10 +36,11 @@ func (h *BookHandler) authMiddleware(c *gin.Context) {
 // @Success 200 {object} response.Response{data=model.Book} "成功创建图书"
 // @Failure 400 {object} response.Response "请求参数错误"
 // @Failure 403 {object} response.Response "权限不足"
+// @Failure 409 {object} response.Response "ISBN已存在（ISBN-10与对应的ISBN-13视为相同）"
 // @Failure 500 {object} response.Response "服务器内部错误"
 // @Example {json} Request-Example:
 //  {
-//    "isbn": "9787111111111",
+//    "isbn": "9787111111115",
 //    "title": "Go语言实战",
 //    "author": "张三",
 //    "publisher": "机械工业出版社",
@@ -56,7 +49,6 @@ func (h *BookHandler) authMiddleware(c *gin.Context) {
 //  }
 // @Router /books [post]
 func (h *BookHandler) CreateBook(c *gin.Context) {
-	h.authMiddleware(c)
 	var req request.CreateBookRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
@@ -79,8 +71,15 @@ func (h *BookHandler) CreateBook(c *gin.Context) {
 
 	book.CreatedAt = time.Now()
 
-	if err := h.bookService.CreateBook( book); err != nil {
-		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+	if err := h.bookService.CreateBook(actorFrom(c), book); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrInvalidParameter):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrAlreadyExists):
+			status = http.StatusConflict
+		}
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
 		return
 	}
 
@@ -100,7 +99,6 @@ func (h *BookHandler) CreateBook(c *gin.Context) {
 // @Success 200 {object} response.Response
 // @Router /books/{id} [put]
 func (h *BookHandler) UpdateBook(c *gin.Context) {
-	h.authMiddleware(c)
 	var uri request.IDRequest
 	if err := c.ShouldBindUri(&uri); err != nil {
 		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid book ID", nil))
@@ -148,7 +146,7 @@ func (h *BookHandler) UpdateBook(c *gin.Context) {
 		book.Summary = req.Summary
 	}
 
-	if err := h.bookService.UpdateBook( book); err != nil {
+	if err := h.bookService.UpdateBook(actorFrom(c), book); err != nil {
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
 		return
 	}
@@ -185,7 +183,7 @@ func (h *BookHandler) GetBook(c *gin.Context) {
 
 // ListBooks 获取图书列表
 // @Summary 获取图书列表
-// @Description 根据条件搜索图书
+// @Description 根据条件搜索图书。关键词匹配书名、作者、出版社和ISBN，没有匹配结果时再按书名或作者的拼音全拼或首字母（如 hlm、honglou）匹配，不区分声调
 // @Tags 图书管理
 // @Accept json
 // @Produce json
@@ -234,7 +232,6 @@ func (h *BookHandler) ListBooks(c *gin.Context) {
 // @Success 200 {object} response.Response
 // @Router /books/{id}/status [put]
 func (h *BookHandler) UpdateBookStatus(c *gin.Context) {
-	h.authMiddleware(c)
 	var uri request.IDRequest
 	if err := c.ShouldBindUri(&uri); err != nil {
 		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid book ID", nil))
@@ -249,7 +246,7 @@ func (h *BookHandler) UpdateBookStatus(c *gin.Context) {
 
 	log.Println(req)
 
-	if err := h.bookService.UpdateBookStatus( uri.ID, req.Status); err != nil {
+	if err := h.bookService.UpdateBookStatus(actorFrom(c), uri.ID, req.Status); err != nil {
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
 		return
 	}
@@ -259,7 +256,7 @@ func (h *BookHandler) UpdateBookStatus(c *gin.Context) {
 
 // UpdateBookStock 更新图书库存 （管理员接口）
 // @Summary 更新图书库存
-// @Description 管理员更新图书库存数量
+// @Description 管理员增减图书副本：正数按数量新增在架副本（自动生成条码），负数注销相应数量的在架副本
 // @Tags 图书管理
 // @Accept json
 // @Produce json
@@ -270,7 +267,6 @@ func (h *BookHandler) UpdateBookStatus(c *gin.Context) {
 // @Success 200 {object} response.Response
 // @Router /books/{id}/stock [put]
 func (h *BookHandler) UpdateBookStock(c *gin.Context) {
-	h.authMiddleware(c)
 	var uri request.IDRequest
 	if err := c.ShouldBindUri(&uri); err != nil {
 		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid book ID", nil))
@@ -283,7 +279,7 @@ func (h *BookHandler) UpdateBookStock(c *gin.Context) {
 		return
 	}
 
-	if err := h.bookService.UpdateBookStock( uri.ID, req.Change); err != nil {
+	if err := h.bookService.UpdateBookStock(actorFrom(c), uri.ID, req.Change); err != nil {
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
 		return
 	}
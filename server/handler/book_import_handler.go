@@ -0,0 +1,426 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"library/handler/request"
+	"library/handler/response"
+	"library/isbn"
+	"library/model"
+	"library/service"
+	"library/sheet"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	// maxImportUploadSize 导入文件的大小上限
+	maxImportUploadSize = 20 << 20
+	// maxImportRows 单个导入文件的数据行上限
+	maxImportRows = 10000
+)
+
+// importFields 可导入的字段，与 request.CreateBookRequest 的 json 字段名一致
+var importFields = []string{"isbn", "title", "author", "publisher", "category", "price", "total", "location", "cover", "summary"}
+
+// importRequiredFields 文件中必须有对应列的字段
+var importRequiredFields = []string{"isbn", "title", "author", "publisher", "category", "price", "total", "location"}
+
+type BookImportHandler struct {
+	bookImportService service.BookImportServiceInterface
+}
+
+func NewBookImportHandler(bookImportService service.BookImportServiceInterface) *BookImportHandler {
+	return &BookImportHandler{
+		bookImportService: bookImportService,
+	}
+}
+
+// CreateImport 创建图书导入任务（管理员接口）
+// @Summary 创建图书导入任务
+// @Description 上传CSV或XLSX文件批量导入图书，第一行为表头。每行按创建图书接口的规则校验，校验失败的行记录在任务的数据行中，其余行由后台任务按ISBN新建或更新图书。
+// @Description 默认按与字段名（isbn、title、author、publisher、category、price、total、location、cover、summary）相同的表头匹配列，不区分大小写，可通过 mapping 指定其他表头。
+// @Description 试运行时只比对，不写入图书，可在任务的数据行中查看将会新建或更新的图书
+// @Tags 图书导入
+// @Accept multipart/form-data
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param file formData file true "CSV或XLSX文件，不超过20MB、10000行"
+// @Param format formData string false "文件格式 csv 或 xlsx，为空时按扩展名判断"
+// @Param dry_run formData bool false "试运行"
+// @Param mapping formData string false "列映射（JSON），如 {\"isbn\":\"ISBN号\",\"title\":\"书名\"}"
+// @Success 202 {object} response.Response{data=model.ImportJob}
+// @Failure 400 {object} response.Response "文件无法解析或缺少必需的列"
+// @Router /imports [post]
+func (h *BookImportHandler) CreateImport(c *gin.Context) {
+	var req request.CreateImportRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Import file is required", nil))
+		return
+	}
+	if fileHeader.Size > maxImportUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, response.NewResponse(http.StatusRequestEntityTooLarge, "Import file is too large", nil))
+		return
+	}
+	format := req.Format
+	if format == "" {
+		format = sheet.FormatFromName(fileHeader.Filename)
+	}
+	if format == "" {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Unknown file format, expected csv or xlsx", nil))
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImportUploadSize))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+
+	records, err := sheet.Read(data, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+	if len(records)-1 > maxImportRows {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, fmt.Sprintf("Import file has more than %d rows", maxImportRows), nil))
+		return
+	}
+	columns, err := importColumns(records[0], req.Mapping)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, err.Error(), nil))
+		return
+	}
+
+	rows := buildImportRows(records[1:], columns)
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Import file has no data rows", nil))
+		return
+	}
+
+	job := &model.ImportJob{
+		FileName: fileHeader.Filename,
+		Format:   format,
+		DryRun:   req.DryRun,
+	}
+	if err := h.bookImportService.CreateJob(actorFrom(c), job, rows); err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, response.NewResponse(http.StatusAccepted, "Import job created", job))
+}
+
+// importColumns 根据表头和列映射确定每个字段所在的列
+func importColumns(header []string, mapping string) (map[string]int, error) {
+	names := make(map[string]string, len(importFields))
+	for _, field := range importFields {
+		names[field] = field
+	}
+	if mapping != "" {
+		var custom map[string]string
+		if err := json.Unmarshal([]byte(mapping), &custom); err != nil {
+			return nil, fmt.Errorf("invalid column mapping: %w", err)
+		}
+		for field, name := range custom {
+			field = strings.ToLower(strings.TrimSpace(field))
+			if _, ok := names[field]; !ok {
+				return nil, fmt.Errorf("unknown field %q in column mapping", field)
+			}
+			names[field] = strings.TrimSpace(name)
+		}
+	}
+
+	columns := make(map[string]int, len(importFields))
+	for field, name := range names {
+		for i, h := range header {
+			if strings.EqualFold(h, name) {
+				columns[field] = i
+				break
+			}
+		}
+	}
+	var missing []string
+	for _, field := range importRequiredFields {
+		if _, ok := columns[field]; !ok {
+			missing = append(missing, names[field])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing columns: %s", strings.Join(missing, ", "))
+	}
+	return columns, nil
+}
+
+// buildImportRows 按创建图书的规则逐行校验，空行跳过，文件内规范化后重复的ISBN只保留第一行
+func buildImportRows(records [][]string, columns map[string]int) []*model.ImportJobRow {
+	var rows []*model.ImportJobRow
+	seen := make(map[string]int)
+	for i, record := range records {
+		if len(record) == 0 {
+			continue
+		}
+		cell := func(field string) string {
+			idx, ok := columns[field]
+			if !ok || idx >= len(record) {
+				return ""
+			}
+			return record[idx]
+		}
+
+		// 表头为第1行
+		row := &model.ImportJobRow{
+			RowNo: i + 2,
+			ISBN:  isbn.Clean(cell("isbn")),
+			Title: cell("title"),
+		}
+		rows = append(rows, row)
+		if len([]rune(row.Title)) > 128 {
+			row.Title = string([]rune(row.Title)[:128])
+		}
+		if len(row.ISBN) > 20 {
+			row.ISBN = row.ISBN[:20]
+		}
+
+		book, err := parseImportRow(row.ISBN, cell)
+		if err == nil {
+			row.ISBN = book.ISBN
+			if first, ok := seen[book.ISBN]; ok {
+				err = fmt.Errorf("duplicate ISBN, first seen at row %d", first)
+			} else {
+				seen[book.ISBN] = row.RowNo
+			}
+		}
+		if err != nil {
+			row.Action = model.ImportActionError
+			row.Error = err.Error()
+			continue
+		}
+		data, err := json.Marshal(book)
+		if err != nil {
+			row.Action = model.ImportActionError
+			row.Error = err.Error()
+			continue
+		}
+		row.Data = string(data)
+	}
+	return rows
+}
+
+// parseImportRow 将一行转换为创建图书请求并校验
+func parseImportRow(code string, cell func(field string) string) (*model.Book, error) {
+	req := request.CreateBookRequest{
+		ISBN:      code,
+		Title:     cell("title"),
+		Author:    cell("author"),
+		Publisher: cell("publisher"),
+		Category:  cell("category"),
+		Location:  cell("location"),
+		Cover:     cell("cover"),
+		Summary:   cell("summary"),
+	}
+	if v := cell("price"); v != "" {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("price: invalid number %q", v)
+		}
+		req.Price = price
+	}
+	if v := cell("total"); v != "" {
+		total, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("total: invalid integer %q", v)
+		}
+		req.Total = total
+	}
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		return nil, importValidationError(err)
+	}
+	code, err := isbn.Normalize(req.ISBN)
+	if err != nil {
+		return nil, fmt.Errorf("isbn: %w", err)
+	}
+
+	return &model.Book{
+		ISBN:      code,
+		Title:     req.Title,
+		Author:    req.Author,
+		Publisher: req.Publisher,
+		Category:  req.Category,
+		Price:     req.Price,
+		Total:     req.Total,
+		Location:  req.Location,
+		Cover:     req.Cover,
+		Summary:   req.Summary,
+		Status:    1,
+	}, nil
+}
+
+// importValidationError 将校验错误转换为按字段名描述的错误信息
+func importValidationError(err error) error {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+	msgs := make([]string, 0, len(errs))
+	for _, fe := range errs {
+		msg := strings.ToLower(fe.Field()) + ": " + fe.Tag()
+		if fe.Param() != "" {
+			msg += "=" + fe.Param()
+		}
+		msgs = append(msgs, msg)
+	}
+	return errors.New("validation failed: " + strings.Join(msgs, ", "))
+}
+
+// ListImports 获取图书导入任务列表（管理员接口）
+// @Summary 获取图书导入任务列表
+// @Description 分页获取图书导入任务，最新的在前
+// @Tags 图书导入
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request query request.PaginationRequest true "分页参数"
+// @Success 200 {object} response.Response{data=[]model.ImportJob}
+// @Router /imports [get]
+func (h *BookImportHandler) ListImports(c *gin.Context) {
+	var req request.PaginationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	params := &model.SearchParams{}
+	params.Page = req.Page
+	params.PageSize = req.PageSize
+	jobs, total, err := h.bookImportService.ListJobs(params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewPaginationResponse(jobs, total, req.Page, req.PageSize))
+}
+
+// GetImport 获取图书导入任务（管理员接口）
+// @Summary 获取图书导入任务
+// @Description 获取导入任务的状态和进度
+// @Tags 图书导入
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "任务ID"
+// @Success 200 {object} response.Response{data=model.ImportJob}
+// @Failure 404 {object} response.Response "任务不存在"
+// @Router /imports/{id} [get]
+func (h *BookImportHandler) GetImport(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid import job ID", nil))
+		return
+	}
+
+	job, err := h.bookImportService.GetJob(uri.ID)
+	if err != nil {
+		writeImportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", job))
+}
+
+// ListImportRows 获取导入任务的数据行（管理员接口）
+// @Summary 获取导入任务的数据行
+// @Description 按行号分页获取导入任务的数据行及处理结果，可按 action 筛选，如 action=error 获取错误报告
+// @Tags 图书导入
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "任务ID"
+// @Param request query request.ImportRowSearchRequest true "查询条件"
+// @Success 200 {object} response.Response{data=[]model.ImportJobRow}
+// @Failure 404 {object} response.Response "任务不存在"
+// @Router /imports/{id}/rows [get]
+func (h *BookImportHandler) ListImportRows(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid import job ID", nil))
+		return
+	}
+	var req request.ImportRowSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	rows, total, err := h.bookImportService.ListRows(uri.ID, req.Action, req.Page, req.PageSize)
+	if err != nil {
+		writeImportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewPaginationResponse(rows, total, req.Page, req.PageSize))
+}
+
+// CancelImport 取消图书导入任务（管理员接口）
+// @Summary 取消图书导入任务
+// @Description 取消排队中或执行中的导入任务。执行中的任务在处理完当前批次后停止，已写入的图书不会回滚
+// @Tags 图书导入
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "任务ID"
+// @Success 200 {object} response.Response{data=model.ImportJob}
+// @Failure 404 {object} response.Response "任务不存在"
+// @Failure 409 {object} response.Response "任务已结束"
+// @Router /imports/{id}/cancel [post]
+func (h *BookImportHandler) CancelImport(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid import job ID", nil))
+		return
+	}
+
+	job, err := h.bookImportService.CancelJob(uri.ID)
+	if err != nil {
+		writeImportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Import job canceled", job))
+}
+
+func writeImportError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidStatus):
+		status = http.StatusConflict
+	}
+	c.JSON(status, response.NewResponse(status, err.Error(), nil))
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"library/isbn"
+	"library/model"
+)
+
+func TestMain(m *testing.M) {
+	if err := isbn.RegisterValidator(); err != nil {
+		panic(err)
+	}
+	m.Run()
+}
+
+var testImportHeader = []string{"ISBN", "Title", "Author", "Publisher", "Category", "Price", "Total", "Location", "Cover", "Summary"}
+
+func TestImportColumns(t *testing.T) {
+	cases := []struct {
+		name    string
+		header  []string
+		mapping string
+		want    map[string]int
+		err     string
+	}{
+		{
+			name:   "default names, case-insensitive",
+			header: testImportHeader,
+			want: map[string]int{"isbn": 0, "title": 1, "author": 2, "publisher": 3, "category": 4,
+				"price": 5, "total": 6, "location": 7, "cover": 8, "summary": 9},
+		},
+		{
+			name:   "optional columns absent",
+			header: []string{"location", "total", "price", "category", "publisher", "author", "title", "isbn"},
+			want: map[string]int{"isbn": 7, "title": 6, "author": 5, "publisher": 4, "category": 3,
+				"price": 2, "total": 1, "location": 0},
+		},
+		{
+			name:    "custom mapping",
+			header:  []string{"书号", "书名", "作者", "出版社", "分类", "定价", "册数", "馆藏位置"},
+			mapping: `{"isbn":"书号","Title":" 书名 ","author":"作者","publisher":"出版社","category":"分类","price":"定价","total":"册数","location":"馆藏位置"}`,
+			want: map[string]int{"isbn": 0, "title": 1, "author": 2, "publisher": 3, "category": 4,
+				"price": 5, "total": 6, "location": 7},
+		},
+		{
+			name:    "partial mapping keeps default names",
+			header:  []string{"书号", "title", "author", "publisher", "category", "price", "total", "location"},
+			mapping: `{"isbn":"书号"}`,
+			want: map[string]int{"isbn": 0, "title": 1, "author": 2, "publisher": 3, "category": 4,
+				"price": 5, "total": 6, "location": 7},
+		},
+		{
+			name:    "unknown field",
+			header:  testImportHeader,
+			mapping: `{"pages":"Pages"}`,
+			err:     `unknown field "pages" in column mapping`,
+		},
+		{
+			name:    "invalid mapping",
+			header:  testImportHeader,
+			mapping: `["isbn"]`,
+			err:     "invalid column mapping",
+		},
+		{
+			name:    "missing columns use mapped names",
+			header:  []string{"isbn", "title", "author", "publisher", "category", "price"},
+			mapping: `{"total":"册数"}`,
+			err:     "missing columns: 册数, location",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			columns, err := importColumns(c.header, c.mapping)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("err = %v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("importColumns: %v", err)
+			}
+			if !reflect.DeepEqual(columns, c.want) {
+				t.Fatalf("columns = %v, want %v", columns, c.want)
+			}
+		})
+	}
+}
+
+func TestBuildImportRows(t *testing.T) {
+	columns, err := importColumns(testImportHeader, "")
+	if err != nil {
+		t.Fatalf("importColumns: %v", err)
+	}
+	valid := func(code string) []string {
+		return []string{code, "Title", "Author", "Press", "TP", "12.5", "2", "A1"}
+	}
+	records := [][]string{
+		valid("0-306-40615-2"),
+		{},
+		append(valid("9787111213826"), "https://example.com/cover.jpg", "Summary"),
+		valid("978-0-306-40615-7"),
+		{"9787111213826", "Title", "Author", "Press", "TP", "abc", "2", "A1"},
+		{"9787111213826", "Title", "Author", "Press", "TP", "12", "1.5", "A1"},
+		valid("9780306406158"),
+		{"9787111213826", "Title", "", "Press", "TP", "12", "0", "A1"},
+		append(valid("9787111213826"), "not a url"),
+		{"9787111213826", strings.Repeat("长", 200), "Author", "Press", "TP", "12", "1", "A1"},
+	}
+	rows := buildImportRows(records, columns)
+
+	want := []struct {
+		rowNo int
+		isbn  string
+		error string
+	}{
+		{2, "9780306406157", ""},
+		{4, "9787111213826", ""},
+		// 文件内规范化后重复的ISBN
+		{5, "9780306406157", "duplicate ISBN, first seen at row 2"},
+		{6, "9787111213826", `price: invalid number "abc"`},
+		{7, "9787111213826", `total: invalid integer "1.5"`},
+		{8, "9780306406158", "validation failed: isbn: isbn"},
+		{9, "9787111213826", "validation failed: author: required, total: required"},
+		{10, "9787111213826", "validation failed: cover: url"},
+		{11, "9787111213826", "validation failed: title: max=128"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("built %d rows, want %d", len(rows), len(want))
+	}
+	for i, w := range want {
+		row := rows[i]
+		if row.RowNo != w.rowNo || row.ISBN != w.isbn || row.Error != w.error {
+			t.Errorf("row %d = %+v, want row %d, ISBN %s, error %q", i, row, w.rowNo, w.isbn, w.error)
+		}
+		if (w.error != "") != (row.Action == model.ImportActionError) || (w.error == "") != (row.Data != "") {
+			t.Errorf("row %d action = %q, data = %q", w.rowNo, row.Action, row.Data)
+		}
+	}
+	if n := len([]rune(rows[8].Title)); n != 128 {
+		t.Errorf("long title kept %d characters, want 128", n)
+	}
+
+	var book model.Book
+	if err := json.Unmarshal([]byte(rows[1].Data), &book); err != nil {
+		t.Fatalf("unmarshal row data: %v", err)
+	}
+	wantBook := model.Book{ISBN: "9787111213826", Title: "Title", Author: "Author", Publisher: "Press", Category: "TP",
+		Price: 12.5, Total: 2, Location: "A1", Cover: "https://example.com/cover.jpg", Summary: "Summary", Status: 1}
+	if !reflect.DeepEqual(book, wantBook) {
+		t.Fatalf("row data = %+v, want %+v", book, wantBook)
+	}
+}
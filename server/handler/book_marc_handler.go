@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"library/handler/request"
+	"library/handler/response"
+	"library/marc"
+	"library/model"
+	"library/service"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxMARCUploadSize MARC导入文件的大小上限
+const maxMARCUploadSize = 20 << 20
+
+// ImportMARC 批量导入MARC记录（管理员接口）
+// @Summary 导入MARC记录
+// @Description 上传MARC21（ISO 2709）或MARCXML文件批量创建图书，逐条返回导入结果，单条记录失败不影响其他记录。
+// @Description 字段对应：020 ISBN和价格，245 书名，100/110/700 作者，264/260 出版社，084/082/650 分类，520 简介，852$c 馆藏位置，856$u 封面。
+// @Description 与创建图书接口一样，出版社、分类、馆藏位置和价格是必需的，记录中缺少时使用请求中的默认值，都没有时该记录导入失败
+// @Tags 图书管理
+// @Accept multipart/form-data
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param file formData file true "MARC文件，不超过20MB"
+// @Param format formData string false "文件格式 iso2709 或 marcxml，为空时自动识别"
+// @Param copies formData int false "每种图书生成的副本数量，默认1"
+// @Param publisher formData string false "记录中没有出版社时使用的出版社"
+// @Param category formData string false "记录中没有分类时使用的分类"
+// @Param location formData string false "记录中没有馆藏位置时使用的馆藏位置"
+// @Param price formData number false "记录中没有价格时使用的价格"
+// @Success 200 {object} response.Response{data=model.BookImportReport}
+// @Failure 400 {object} response.Response "文件无法解析"
+// @Router /books/marc/import [post]
+func (h *BookHandler) ImportMARC(c *gin.Context) {
+	var req request.ImportMARCRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+	if req.Copies == 0 {
+		req.Copies = 1
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "MARC file is required", nil))
+		return
+	}
+	if fileHeader.Size > maxMARCUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, response.NewResponse(http.StatusRequestEntityTooLarge, "MARC file is too large", nil))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxMARCUploadSize))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+
+	report, err := h.bookService.ImportMARC(actorFrom(c), data, req.Format, service.MARCImportOptions{
+		Copies:    req.Copies,
+		Publisher: req.Publisher,
+		Category:  req.Category,
+		Location:  req.Location,
+		Price:     req.Price,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidParameter) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, fmt.Sprintf("%d of %d records imported", report.Created, report.Total), report))
+}
+
+// ExportMARC 导出MARC记录（管理员接口）
+// @Summary 导出MARC记录
+// @Description 按图书列表的查询条件导出MARC21（ISO 2709）或MARCXML文件。不指定页码时导出全部匹配的图书，单次最多10000条
+// @Tags 图书管理
+// @Accept json
+// @Produce application/marc
+// @Produce application/marcxml+xml
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request query request.ExportMARCRequest true "查询条件"
+// @Success 200 {file} file "MARC文件"
+// @Failure 400 {object} response.Response "匹配的图书超过导出上限"
+// @Router /books/marc/export [get]
+func (h *BookHandler) ExportMARC(c *gin.Context) {
+	var req request.ExportMARCRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+	if req.Format == "" {
+		req.Format = marc.FormatISO2709
+	}
+	if req.Page > 0 && req.PageSize == 0 {
+		req.PageSize = 100
+	}
+
+	searchParams := &model.SearchParams{
+		Keyword:  req.Keyword,
+		Category: req.Category,
+	}
+	searchParams.Page = req.Page
+	searchParams.PageSize = req.PageSize
+
+	// 先写入缓冲区，编码失败时仍可返回JSON错误
+	var buf bytes.Buffer
+	if _, err := h.bookService.ExportMARC(&buf, searchParams, req.Format); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidParameter) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	contentType, ext := marc.ContentType(req.Format)
+	filename := "books-" + time.Now().Format("20060102150405") + ext
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
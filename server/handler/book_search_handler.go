@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"errors"
+	"library/handler/request"
+	"library/handler/response"
+	"library/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BookSearchHandler struct {
+	bookSearchService service.BookSearchServiceInterface
+}
+
+func NewBookSearchHandler(bookSearchService service.BookSearchServiceInterface) *BookSearchHandler {
+	return &BookSearchHandler{
+		bookSearchService: bookSearchService,
+	}
+}
+
+// SearchBooks 全文检索图书
+// @Summary 全文检索图书
+// @Description 按书名、作者、出版社和简介检索图书，结果按相关度（BM25，书名和作者权重更高）排序，并返回命中字段的高亮文本。
+// @Description 空格分隔的词需全部匹配；"..." 为短语，词需连续出现；以 * 结尾为前缀查询，如 prog* 匹配 programming。中文按相邻两字切分检索。
+// @Description 查询为合法的ISBN-10或ISBN-13时直接按ISBN查找；没有检索结果且查询为拼音时，按书名和作者的全拼或首字母（如 hlm、honglou）检索，不区分声调。
+// @Description 使用MySQL检索引擎时只对全文索引相关度最高的前1000本图书排序，匹配更多时 total 按1000返回
+// @Tags 图书管理
+// @Accept json
+// @Produce json
+// @Param request query request.BookFullTextSearchRequest true "检索条件"
+// @Success 200 {object} response.Response{data=[]model.BookSearchHit}
+// @Failure 400 {object} response.Response "查询中没有可检索的词"
+// @Router /books/search [get]
+func (h *BookSearchHandler) SearchBooks(c *gin.Context) {
+	var req request.BookFullTextSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	hits, total, err := h.bookSearchService.Search(req.Q, req.Category, req.Page, req.PageSize)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidParameter) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewPaginationResponse(hits, total, req.Page, req.PageSize))
+}
@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"library/handler/request"
 	"library/handler/response"
+	"library/middleware"
 	"library/model"
 	"library/service"
 	"net/http"
@@ -11,12 +13,16 @@ import (
 )
 
 type BorrowHandler struct {
-	borrowService service.BorrowServiceInterface
+	borrowService  service.BorrowServiceInterface
+	overdueService service.OverdueServiceInterface
+	fineService    service.FineServiceInterface
 }
 
-func NewBorrowHandler(borrowService service.BorrowServiceInterface) *BorrowHandler {
+func NewBorrowHandler(borrowService service.BorrowServiceInterface, overdueService service.OverdueServiceInterface, fineService service.FineServiceInterface) *BorrowHandler {
 	return &BorrowHandler{
-		borrowService: borrowService,
+		borrowService:  borrowService,
+		overdueService: overdueService,
+		fineService:    fineService,
 	}
 }
 
@@ -29,13 +35,32 @@ func (h *BorrowHandler) authCheck(c *gin.Context) (uint, bool) {
 	return userID.(uint), true
 }
 
-func (h *BorrowHandler) adminCheck(c *gin.Context) bool {
-	role, exists := c.Get("role")
-	if !exists || role.(string) != "admin" {
-		c.JSON(http.StatusForbidden, response.NewResponse(http.StatusForbidden, "Permission denied", nil))
-		return false
+// errorResponse 将服务层错误转换为响应，借阅规则拒绝时返回原因代码
+func (h *BorrowHandler) errorResponse(c *gin.Context, err error) {
+	var limitErr *service.LimitError
+	if errors.As(err, &limitErr) {
+		c.JSON(http.StatusForbidden, response.NewResponse(http.StatusForbidden, err.Error(), gin.H{
+			"reason":  limitErr.Reason,
+			"limit":   limitErr.Limit,
+			"current": limitErr.Current,
+		}))
+		return
+	}
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrPermissionDenied):
+		status = http.StatusForbidden
+	case errors.Is(err, service.ErrAlreadyExists),
+		errors.Is(err, service.ErrBookNotAvailable),
+		errors.Is(err, service.ErrNotBorrowed),
+		errors.Is(err, service.ErrInvalidStatus),
+		errors.Is(err, service.ErrJobLocked):
+		status = http.StatusConflict
 	}
-	return true
+	c.JSON(status, response.NewResponse(status, err.Error(), nil))
 }
 
 // BorrowBook 借阅图书
@@ -48,6 +73,7 @@ func (h *BorrowHandler) adminCheck(c *gin.Context) bool {
 // @Param Authorization header string true "Bearer 用户的访问令牌"
 // @Param request body request.CreateBorrowRequest true "借阅信息"
 // @Success 200 {object} response.Response
+// @Failure 403 {object} response.Response "借阅被拒绝，data.reason 为原因代码：account_disabled/max_loans_reached/overdue_items/unpaid_fines"
 // @Router /borrows [post]
 func (h *BorrowHandler) BorrowBook(c *gin.Context) {
 	userID, ok := h.authCheck(c)
@@ -60,8 +86,8 @@ func (h *BorrowHandler) BorrowBook(c *gin.Context) {
 		return
 	}
 
-	if err := h.borrowService.BorrowBook( userID, req.BookID); err != nil {
-		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+	if err := h.borrowService.BorrowBook(actorFrom(c), userID, req.BookID); err != nil {
+		h.errorResponse(c, err)
 		return
 	}
 
@@ -70,14 +96,16 @@ func (h *BorrowHandler) BorrowBook(c *gin.Context) {
 
 // ReturnBook 归还图书
 // @Summary 归还图书
-// @Description 用户归还图书
+// @Description 用户归还图书，逾期罚金记入罚金账户；fine 大于0时同时缴纳该金额的罚金
 // @Tags 借阅管理
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
 // @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param Idempotency-Key header string false "缴纳罚金的幂等键，重试时使用相同的值，只扣款一次"
 // @Param request body request.ReturnBookRequest true "归还信息"
 // @Success 200 {object} response.Response
+// @Failure 402 {object} response.Response "图书已归还，但罚金缴纳失败"
 // @Router /borrows/return [post]
 func (h *BorrowHandler) ReturnBook(c *gin.Context) {
 	userID, ok := h.authCheck(c)
@@ -90,14 +118,116 @@ func (h *BorrowHandler) ReturnBook(c *gin.Context) {
 		return
 	}
 
-	if err := h.borrowService.ReturnBook( userID, req.BorrowID); err != nil {
+	if err := h.borrowService.ReturnBook(actorFrom(c), userID, req.BorrowID); err != nil {
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
 		return
 	}
 
+	// 归还时一并缴纳罚金
+	if req.Fine > 0 {
+		tx, err := h.fineService.Pay(userID, req.Fine, c.GetHeader("Idempotency-Key"), actorFrom(c))
+		if err != nil {
+			c.JSON(http.StatusPaymentRequired, response.NewResponse(http.StatusPaymentRequired, "Book returned but payment failed: "+err.Error(), nil))
+			return
+		}
+		c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Book returned successfully", tx))
+		return
+	}
+
 	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Book returned successfully", nil))
 }
 
+// Checkout 扫码借阅（管理员接口）
+// @Summary 扫码借阅
+// @Description 管理员扫描副本条码为读者办理借阅。副本须在架，或是为该读者预约保留的副本
+// @Tags 借阅管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request body request.CheckoutRequest true "读者和条码"
+// @Success 200 {object} response.Response{data=model.Borrow}
+// @Failure 403 {object} response.Response "借阅被拒绝，data.reason 为原因代码"
+// @Failure 409 {object} response.Response "副本不可借"
+// @Router /borrows/checkout [post]
+func (h *BorrowHandler) Checkout(c *gin.Context) {
+	var req request.CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	borrow, err := h.borrowService.CheckoutByBarcode(actorFrom(c), req.UserID, req.Barcode)
+	if err != nil {
+		h.errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Book borrowed successfully", borrow))
+}
+
+// Checkin 扫码归还（管理员接口）
+// @Summary 扫码归还
+// @Description 管理员扫描副本条码办理归还，逾期罚金记入读者的罚金账户，副本优先分配给预约队列
+// @Tags 借阅管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request body request.CheckinRequest true "条码"
+// @Success 200 {object} response.Response{data=model.Borrow}
+// @Failure 409 {object} response.Response "副本未借出"
+// @Router /borrows/checkin [post]
+func (h *BorrowHandler) Checkin(c *gin.Context) {
+	var req request.CheckinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	borrow, err := h.borrowService.CheckinByBarcode(actorFrom(c), req.Barcode)
+	if err != nil {
+		h.errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Book returned successfully", borrow))
+}
+
+// RenewBorrow 续借图书
+// @Summary 续借图书
+// @Description 读者续借自己的借阅，管理员可续借任意借阅。续借次数受借阅规则限制，有其他读者排队预约或逾期过久时不允许续借。逾期续借时，到续借为止的逾期罚金记入读者的罚金账户
+// @Tags 借阅管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "借阅ID"
+// @Success 200 {object} response.Response{data=model.Borrow}
+// @Failure 403 {object} response.Response "续借被拒绝，data.reason 为原因代码：max_renewals_reached/hold_pending/overdue_too_long"
+// @Router /borrows/{id}/renew [post]
+func (h *BorrowHandler) RenewBorrow(c *gin.Context) {
+	userID, ok := h.authCheck(c)
+	if !ok {
+		return
+	}
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid borrow ID", nil))
+		return
+	}
+
+	// 有柜台借阅权限的工作人员可以为读者续借
+	isStaff := middleware.HasPermission(c, model.PermBorrowCheckout)
+	borrow, err := h.borrowService.RenewBook(actorFrom(c), uri.ID, userID, isStaff)
+	if err != nil {
+		h.errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Book renewed successfully", borrow))
+}
+
 // GetBorrow 获取借阅详情
 // @Summary 获取借阅详情
 // @Description 获取指定借阅记录的详细信息
@@ -126,8 +256,8 @@ func (h *BorrowHandler) GetBorrow(c *gin.Context) {
 		return
 	}
 
-	// 检查是否是管理员或借阅者本人
-	if !h.adminCheck(c) && userID != borrow.UserID {
+	// 检查是否有查看借阅记录的权限或是借阅者本人
+	if !middleware.HasPermission(c, model.PermBorrowRead) && userID != borrow.UserID {
 		c.JSON(http.StatusForbidden, response.NewResponse(http.StatusForbidden, "Permission denied", nil))
 		return
 	}
@@ -157,8 +287,8 @@ func (h *BorrowHandler) ListBorrows(c *gin.Context) {
 		return
 	}
 
-	// 非管理员只能查看自己的借阅记录
-	if !h.adminCheck(c) {
+	// 没有查看借阅记录权限的用户只能查看自己的借阅记录
+	if !middleware.HasPermission(c, model.PermBorrowRead) {
 		req.UserID = userID
 	}
 
@@ -196,9 +326,6 @@ func (h *BorrowHandler) ListBorrows(c *gin.Context) {
 // @Success 200 {object} response.Response
 // @Router /borrows/{id} [put]
 func (h *BorrowHandler) UpdateBorrow(c *gin.Context) {
-	if !h.adminCheck(c) {
-		return
-	}
 	var uri request.IDRequest
 	if err := c.ShouldBindUri(&uri); err != nil {
 		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid borrow ID", nil))
@@ -216,6 +343,10 @@ func (h *BorrowHandler) UpdateBorrow(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
 		return
 	}
+	if borrow == nil {
+		c.JSON(http.StatusNotFound, response.NewResponse(http.StatusNotFound, "Borrow record not found", nil))
+		return
+	}
 
 	// 更新借阅信息
 	borrow.DueDate = req.DueDate
@@ -223,10 +354,52 @@ func (h *BorrowHandler) UpdateBorrow(c *gin.Context) {
 	borrow.Fine = req.Fine
 	borrow.Remark = req.Remark
 
-	if err := h.borrowService.UpdateBorrow( borrow); err != nil {
+	if err := h.borrowService.UpdateBorrow(actorFrom(c), borrow); err != nil {
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
 		return
 	}
 
 	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Borrow record updated successfully", borrow))
 }
+
+// GetOverdueScan 获取最近一次逾期扫描结果（管理员接口）
+// @Summary 获取逾期扫描结果
+// @Description 管理员查看后台逾期扫描任务最近一次的执行结果：scanned-扫描数 affected-新标记逾期数 updated-罚金更新数
+// @Tags 借阅管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Success 200 {object} response.Response{data=model.JobRun}
+// @Failure 404 {object} response.Response "尚未执行过"
+// @Router /borrows/overdue-scan [get]
+func (h *BorrowHandler) GetOverdueScan(c *gin.Context) {
+	run, err := h.overdueService.GetLastRun()
+	if err != nil {
+		h.errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", run))
+}
+
+// RunOverdueScan 立即执行逾期扫描（管理员接口）
+// @Summary 立即执行逾期扫描
+// @Description 管理员手动触发逾期扫描，如其他实例正在执行则返回409
+// @Tags 借阅管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Success 200 {object} response.Response{data=model.JobRun}
+// @Failure 409 {object} response.Response "扫描正在执行"
+// @Router /borrows/overdue-scan [post]
+func (h *BorrowHandler) RunOverdueScan(c *gin.Context) {
+	run, err := h.overdueService.ScanOverdue()
+	if err != nil {
+		h.errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Overdue scan finished", run))
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"errors"
+	"library/handler/request"
+	"library/handler/response"
+	"library/model"
+	"library/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CopyHandler struct {
+	copyService service.CopyServiceInterface
+}
+
+func NewCopyHandler(copyService service.CopyServiceInterface) *CopyHandler {
+	return &CopyHandler{
+		copyService: copyService,
+	}
+}
+
+// errorStatus 将服务层错误映射为HTTP状态码
+func (h *CopyHandler) errorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrAlreadyExists),
+		errors.Is(err, service.ErrInvalidStatus):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ListBookCopies 获取图书的副本列表
+// @Summary 获取图书副本列表
+// @Description 获取图书的全部副本及其条码、品相、分馆、架位和状态
+// @Tags 副本管理
+// @Accept json
+// @Produce json
+// @Param id path int true "图书ID"
+// @Param request query request.CopySearchRequest false "查询条件"
+// @Success 200 {object} response.Response{data=[]model.Copy}
+// @Router /books/{id}/copies [get]
+func (h *CopyHandler) ListBookCopies(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid book ID", nil))
+		return
+	}
+	var req request.CopySearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	copies, err := h.copyService.ListBookCopies(uri.ID, req.Status)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", copies))
+}
+
+// AddCopy 为图书添加副本（管理员接口）
+// @Summary 添加副本
+// @Description 管理员为图书添加一个实体副本，未填写条码时自动生成，图书的总数量和可借数量随之更新
+// @Tags 副本管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "图书ID"
+// @Param request body request.CreateCopyRequest true "副本信息"
+// @Success 200 {object} response.Response{data=model.Copy}
+// @Failure 409 {object} response.Response "条码已存在，已删除副本的条码也不能再使用"
+// @Router /books/{id}/copies [post]
+func (h *CopyHandler) AddCopy(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid book ID", nil))
+		return
+	}
+	var req request.CreateCopyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	item := &model.Copy{
+		BookID:    uri.ID,
+		Barcode:   req.Barcode,
+		Condition: req.Condition,
+		Branch:    req.Branch,
+		Location:  req.Location,
+		Status:    req.Status,
+		Remark:    req.Remark,
+	}
+	if err := h.copyService.AddCopy(actorFrom(c), item); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Copy added successfully", item))
+}
+
+// GetCopyByBarcode 根据条码查询副本（管理员接口）
+// @Summary 扫码查询副本
+// @Description 管理员扫描条码查询副本及所属图书
+// @Tags 副本管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param barcode path string true "条码"
+// @Success 200 {object} response.Response{data=model.Copy}
+// @Router /copies/barcode/{barcode} [get]
+func (h *CopyHandler) GetCopyByBarcode(c *gin.Context) {
+	var uri request.BarcodeRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid barcode", nil))
+		return
+	}
+
+	item, err := h.copyService.GetCopyByBarcode(uri.Barcode)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", item))
+}
+
+// GetCopy 获取副本详情（管理员接口）
+// @Summary 获取副本详情
+// @Description 管理员获取指定副本
+// @Tags 副本管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "副本ID"
+// @Success 200 {object} response.Response{data=model.Copy}
+// @Router /copies/{id} [get]
+func (h *CopyHandler) GetCopy(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid copy ID", nil))
+		return
+	}
+
+	item, err := h.copyService.GetCopy(uri.ID)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", item))
+}
+
+// UpdateCopy 更新副本信息（管理员接口）
+// @Summary 更新副本
+// @Description 管理员更新副本的条码、品相、分馆、架位或状态。借出和预约保留中的副本不能修改状态
+// @Tags 副本管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "副本ID"
+// @Param request body request.UpdateCopyRequest true "副本信息"
+// @Success 200 {object} response.Response
+// @Failure 409 {object} response.Response "条码已存在（包括已删除的副本）或当前状态不允许修改"
+// @Router /copies/{id} [put]
+func (h *CopyHandler) UpdateCopy(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid copy ID", nil))
+		return
+	}
+	var req request.UpdateCopyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	item := &model.Copy{
+		Barcode:   req.Barcode,
+		Condition: req.Condition,
+		Branch:    req.Branch,
+		Location:  req.Location,
+		Status:    req.Status,
+		Remark:    req.Remark,
+	}
+	item.ID = uri.ID
+	if err := h.copyService.UpdateCopy(actorFrom(c), item); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Copy updated successfully", nil))
+}
+
+// DeleteCopy 删除副本（管理员接口）
+// @Summary 删除副本
+// @Description 管理员删除副本，借出或预约保留中的副本不能删除
+// @Tags 副本管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "副本ID"
+// @Success 200 {object} response.Response
+// @Router /copies/{id} [delete]
+func (h *CopyHandler) DeleteCopy(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid copy ID", nil))
+		return
+	}
+
+	if err := h.copyService.DeleteCopy(actorFrom(c), uri.ID); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Copy deleted successfully", nil))
+}
@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"errors"
+	"library/handler/request"
+	"library/handler/response"
+	"library/model"
+	"library/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FineHandler struct {
+	fineService service.FineServiceInterface
+}
+
+func NewFineHandler(fineService service.FineServiceInterface) *FineHandler {
+	return &FineHandler{
+		fineService: fineService,
+	}
+}
+
+func (h *FineHandler) authCheck(c *gin.Context) (uint, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, response.NewResponse(http.StatusUnauthorized, "User not logged in", nil))
+		return 0, false
+	}
+	return userID.(uint), true
+}
+
+// errorStatus 将服务层错误映射为HTTP状态码
+func (h *FineHandler) errorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidAmount),
+		errors.Is(err, service.ErrInvalidParameter):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrAmountExceedsBalance),
+		errors.Is(err, service.ErrInvalidStatus):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrPaymentFailed):
+		return http.StatusPaymentRequired
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// GetMyBalance 获取当前用户的罚金余额
+// @Summary 获取罚金余额
+// @Description 获取当前用户未缴的罚金余额（罚款+退款-缴费-减免）
+// @Tags 罚金管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Success 200 {object} response.Response
+// @Router /fines/balance [get]
+func (h *FineHandler) GetMyBalance(c *gin.Context) {
+	userID, ok := h.authCheck(c)
+	if !ok {
+		return
+	}
+
+	balance, err := h.fineService.GetBalance(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", gin.H{
+		"user_id": userID,
+		"balance": balance,
+	}))
+}
+
+// ListMyTransactions 获取当前用户的罚金流水
+// @Summary 获取我的罚金流水
+// @Description 获取当前用户的罚款、缴费、减免和退款记录
+// @Tags 罚金管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request query request.FineSearchRequest true "查询条件"
+// @Success 200 {object} response.Response
+// @Router /fines [get]
+func (h *FineHandler) ListMyTransactions(c *gin.Context) {
+	userID, ok := h.authCheck(c)
+	if !ok {
+		return
+	}
+	h.listTransactions(c, userID)
+}
+
+// ListUserTransactions 获取指定用户的罚金流水（管理员接口）
+// @Summary 获取用户罚金流水
+// @Description 管理员获取指定用户的罚金流水及余额
+// @Tags 罚金管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "用户ID"
+// @Param request query request.FineSearchRequest true "查询条件"
+// @Success 200 {object} response.Response
+// @Router /fines/users/{id} [get]
+func (h *FineHandler) ListUserTransactions(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid user ID", nil))
+		return
+	}
+	h.listTransactions(c, uri.ID)
+}
+
+func (h *FineHandler) listTransactions(c *gin.Context, userID uint) {
+	var req request.FineSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	searchParams := &model.SearchParams{
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+	}
+	searchParams.Page = req.Page
+	searchParams.PageSize = req.PageSize
+
+	txs, total, err := h.fineService.GetUserTransactions(userID, searchParams)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewPaginationResponse(txs, total, req.Page, req.PageSize))
+}
+
+// PayFine 缴纳罚金
+// @Summary 缴纳罚金
+// @Description 当前用户通过支付渠道缴纳罚金，支持部分缴纳，金额不能超过未缴余额
+// @Tags 罚金管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param Idempotency-Key header string false "幂等键（最长64个字符），重试同一笔缴费时使用相同的值，只扣款一次"
+// @Param request body request.PayFineRequest true "缴费信息"
+// @Success 200 {object} response.Response{data=model.FineTransaction}
+// @Failure 402 {object} response.Response "支付渠道处理失败"
+// @Failure 409 {object} response.Response "金额超过未缴余额"
+// @Router /fines/payments [post]
+func (h *FineHandler) PayFine(c *gin.Context) {
+	userID, ok := h.authCheck(c)
+	if !ok {
+		return
+	}
+	var req request.PayFineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	tx, err := h.fineService.Pay(userID, req.Amount, c.GetHeader("Idempotency-Key"), actorFrom(c))
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Fine paid successfully", tx))
+}
+
+// ChargeFine 记录罚款（管理员接口）
+// @Summary 记录罚款
+// @Description 管理员为读者记录一笔罚款，如图书损坏、遗失
+// @Tags 罚金管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request body request.ChargeFineRequest true "罚款信息"
+// @Success 200 {object} response.Response{data=model.FineTransaction}
+// @Router /fines/charges [post]
+func (h *FineHandler) ChargeFine(c *gin.Context) {
+	_, ok := h.authCheck(c)
+	if !ok {
+		return
+	}
+	var req request.ChargeFineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	tx, err := h.fineService.Charge(req.UserID, req.BorrowID, req.Amount, req.Reason, actorFrom(c))
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Fine charged successfully", tx))
+}
+
+// WaiveFine 减免罚金（管理员接口）
+// @Summary 减免罚金
+// @Description 管理员减免读者的罚金，必须填写原因，金额不能超过未缴余额
+// @Tags 罚金管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request body request.WaiveFineRequest true "减免信息"
+// @Success 200 {object} response.Response{data=model.FineTransaction}
+// @Failure 409 {object} response.Response "金额超过未缴余额"
+// @Router /fines/waivers [post]
+func (h *FineHandler) WaiveFine(c *gin.Context) {
+	_, ok := h.authCheck(c)
+	if !ok {
+		return
+	}
+	var req request.WaiveFineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	tx, err := h.fineService.Waive(req.UserID, req.Amount, req.Reason, actorFrom(c))
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Fine waived successfully", tx))
+}
+
+// RefundFine 退还缴费（管理员接口）
+// @Summary 退还缴费
+// @Description 管理员通过原支付渠道退还一笔缴费，可部分退款，累计不超过原缴费金额
+// @Tags 罚金管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request body request.RefundFineRequest true "退款信息"
+// @Success 200 {object} response.Response{data=model.FineTransaction}
+// @Failure 402 {object} response.Response "支付渠道处理失败"
+// @Failure 409 {object} response.Response "不是缴费流水或超过可退金额"
+// @Router /fines/refunds [post]
+func (h *FineHandler) RefundFine(c *gin.Context) {
+	_, ok := h.authCheck(c)
+	if !ok {
+		return
+	}
+	var req request.RefundFineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	tx, err := h.fineService.Refund(req.PaymentID, req.Amount, req.Reason, actorFrom(c))
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Fine refunded successfully", tx))
+}
+
+// GetReport 罚金汇总报表（管理员接口）
+// @Summary 罚金汇总报表
+// @Description 管理员按用户汇总罚款、缴费、减免、退款及余额，按余额倒序
+// @Tags 罚金管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request query request.FineReportRequest true "查询条件"
+// @Success 200 {object} response.Response{data=[]model.FineSummary}
+// @Router /fines/report [get]
+func (h *FineHandler) GetReport(c *gin.Context) {
+	var req request.FineReportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	searchParams := &model.SearchParams{
+		Keyword:   req.Keyword,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+	}
+	searchParams.Page = req.Page
+	searchParams.PageSize = req.PageSize
+
+	summaries, total, err := h.fineService.Report(searchParams)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewPaginationResponse(summaries, total, req.Page, req.PageSize))
+}
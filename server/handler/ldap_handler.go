@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"errors"
+	"library/handler/response"
+	"library/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LDAPHandler struct {
+	ldapService service.LDAPServiceInterface
+}
+
+func NewLDAPHandler(ldapService service.LDAPServiceInterface) *LDAPHandler {
+	return &LDAPHandler{
+		ldapService: ldapService,
+	}
+}
+
+// errorResponse 将服务层错误映射为HTTP响应
+func (h *LDAPHandler) errorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, response.NewResponse(http.StatusNotFound, "Directory sync has not run yet", nil))
+	case errors.Is(err, service.ErrJobLocked):
+		c.JSON(http.StatusConflict, response.NewResponse(http.StatusConflict, "Directory sync is already running", nil))
+	default:
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+	}
+}
+
+// GetLDAPSync 获取最近一次目录同步结果（管理员接口）
+// @Summary 获取LDAP目录同步结果
+// @Description 管理员查看LDAP目录同步任务最近一次的执行结果：scanned-检查的账号数 affected-禁用数 updated-资料或角色更新数
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Success 200 {object} response.Response{data=model.JobRun}
+// @Failure 404 {object} response.Response "尚未执行过"
+// @Router /users/ldap-sync [get]
+func (h *LDAPHandler) GetLDAPSync(c *gin.Context) {
+	run, err := h.ldapService.GetLastSync()
+	if err != nil {
+		h.errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", run))
+}
+
+// RunLDAPSync 立即执行目录同步（管理员接口）
+// @Summary 立即执行LDAP目录同步
+// @Description 管理员手动触发目录同步，按目录更新已关联账号的资料和角色，禁用已从目录中删除的账号。如其他实例正在执行则返回409
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Success 200 {object} response.Response{data=model.JobRun}
+// @Failure 409 {object} response.Response "同步正在执行"
+// @Failure 500 {object} response.Response{data=model.JobRun} "同步失败"
+// @Router /users/ldap-sync [post]
+func (h *LDAPHandler) RunLDAPSync(c *gin.Context) {
+	run, err := h.ldapService.SyncDirectory()
+	if err != nil {
+		if run != nil {
+			c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), run))
+			return
+		}
+		h.errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Directory sync finished", run))
+}
@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"errors"
+	"library/handler/request"
+	"library/handler/response"
+	"library/model"
+	"library/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LoanPolicyHandler struct {
+	loanPolicyService service.LoanPolicyServiceInterface
+}
+
+func NewLoanPolicyHandler(loanPolicyService service.LoanPolicyServiceInterface) *LoanPolicyHandler {
+	return &LoanPolicyHandler{
+		loanPolicyService: loanPolicyService,
+	}
+}
+
+// errorStatus 将服务层错误映射为HTTP状态码
+func (h *LoanPolicyHandler) errorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrAlreadyExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (h *LoanPolicyHandler) toModel(req *request.LoanPolicyRequest) *model.LoanPolicy {
+	return &model.LoanPolicy{
+		Name:        req.Name,
+		Role:        req.Role,
+		Category:    req.Category,
+		LoanDays:    req.LoanDays,
+		MaxRenewals: req.MaxRenewals,
+		MaxLoans:    req.MaxLoans,
+		DailyFine:   req.DailyFine,
+		FineCap:     req.FineCap,
+		GraceDays:   req.GraceDays,
+		Status:      req.Status,
+	}
+}
+
+// CreatePolicy 创建借阅规则（管理员接口）
+// @Summary 创建借阅规则
+// @Description 管理员按读者角色和图书分类创建借阅规则，包括借期、续借次数、借阅上限、罚金及宽限期
+// @Tags 借阅规则
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request body request.LoanPolicyRequest true "借阅规则"
+// @Success 200 {object} response.Response{data=model.LoanPolicy}
+// @Failure 409 {object} response.Response "该角色和分类已有规则"
+// @Router /loan-policies [post]
+func (h *LoanPolicyHandler) CreatePolicy(c *gin.Context) {
+	var req request.LoanPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	policy := h.toModel(&req)
+	if err := h.loanPolicyService.CreatePolicy(actorFrom(c), policy); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Loan policy created successfully", policy))
+}
+
+// UpdatePolicy 更新借阅规则（管理员接口）
+// @Summary 更新借阅规则
+// @Description 管理员更新借阅规则，所有字段整体替换
+// @Tags 借阅规则
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "规则ID"
+// @Param request body request.LoanPolicyRequest true "借阅规则"
+// @Success 200 {object} response.Response{data=model.LoanPolicy}
+// @Router /loan-policies/{id} [put]
+func (h *LoanPolicyHandler) UpdatePolicy(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid policy ID", nil))
+		return
+	}
+
+	var req request.LoanPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	policy := h.toModel(&req)
+	policy.ID = uri.ID
+	if policy.Status == 0 {
+		policy.Status = 1
+	}
+	if err := h.loanPolicyService.UpdatePolicy(actorFrom(c), policy); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Loan policy updated successfully", policy))
+}
+
+// DeletePolicy 删除借阅规则（管理员接口）
+// @Summary 删除借阅规则
+// @Description 管理员删除借阅规则
+// @Tags 借阅规则
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "规则ID"
+// @Success 200 {object} response.Response
+// @Router /loan-policies/{id} [delete]
+func (h *LoanPolicyHandler) DeletePolicy(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid policy ID", nil))
+		return
+	}
+
+	if err := h.loanPolicyService.DeletePolicy(actorFrom(c), uri.ID); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Loan policy deleted successfully", nil))
+}
+
+// GetPolicy 获取借阅规则详情（管理员接口）
+// @Summary 获取借阅规则详情
+// @Description 管理员获取指定借阅规则
+// @Tags 借阅规则
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "规则ID"
+// @Success 200 {object} response.Response{data=model.LoanPolicy}
+// @Router /loan-policies/{id} [get]
+func (h *LoanPolicyHandler) GetPolicy(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid policy ID", nil))
+		return
+	}
+
+	policy, err := h.loanPolicyService.GetPolicy(uri.ID)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", policy))
+}
+
+// ListPolicies 获取借阅规则列表（管理员接口）
+// @Summary 获取借阅规则列表
+// @Description 管理员获取借阅规则列表
+// @Tags 借阅规则
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request query request.LoanPolicySearchRequest true "搜索条件"
+// @Success 200 {object} response.Response
+// @Router /loan-policies [get]
+func (h *LoanPolicyHandler) ListPolicies(c *gin.Context) {
+	var req request.LoanPolicySearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	searchParams := &model.SearchParams{
+		Keyword:  req.Keyword,
+		Category: req.Category,
+	}
+	searchParams.Page = req.Page
+	searchParams.PageSize = req.PageSize
+
+	policies, total, err := h.loanPolicyService.ListPolicies(searchParams)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewPaginationResponse(policies, total, req.Page, req.PageSize))
+}
+
+// ResolvePolicy 查询生效的借阅规则（管理员接口）
+// @Summary 查询生效的借阅规则
+// @Description 查询指定角色借阅指定分类图书时实际生效的规则，没有匹配规则时返回配置文件中的默认规则
+// @Tags 借阅规则
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request query request.ResolveLoanPolicyRequest true "角色和分类"
+// @Success 200 {object} response.Response{data=model.LoanPolicy}
+// @Router /loan-policies/resolve [get]
+func (h *LoanPolicyHandler) ResolvePolicy(c *gin.Context) {
+	var req request.ResolveLoanPolicyRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	policy, err := h.loanPolicyService.ResolvePolicy(req.Role, req.Category)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", policy))
+}
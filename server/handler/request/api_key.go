@@ -0,0 +1,19 @@
+package request
+
+import "time"
+
+// CreateAPIKeyRequest 签发API密钥请求
+// @Description 签发API密钥请求参数。作用域为权限名称，必须是所属用户的角色拥有的权限
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,min=1,max=64" example:"一楼自助借还机"`                   // 名称
+	Scopes    []string   `json:"scopes" binding:"required,min=1" example:"borrow:checkout,borrow:checkin"` // 作用域
+	UserID    uint       `json:"user_id" binding:"omitempty,min=1" example:"12"`                           // 所属用户ID，为空时属于当前管理员
+	RateLimit float64    `json:"rate_limit" binding:"omitempty,min=0" example:"5"`                         // 每秒允许的请求数，为空使用默认值
+	RateBurst int        `json:"rate_burst" binding:"omitempty,min=0" example:"10"`                        // 突发请求数，为空使用默认值
+	ExpiresAt *time.Time `json:"expires_at" binding:"omitempty" example:"2027-01-01T00:00:00+08:00"`       // 过期时间，为空时使用配置的有效期上限
+}
+
+// APIKeyListRequest API密钥列表查询请求
+type APIKeyListRequest struct {
+	UserID uint `form:"user_id" binding:"omitempty,min=1"` // 按所属用户筛选
+}
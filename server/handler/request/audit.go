@@ -0,0 +1,15 @@
+package request
+
+import "time"
+
+// AuditLogSearchRequest 审计记录查询请求
+type AuditLogSearchRequest struct {
+	ActorID    uint       `form:"actor_id" binding:"omitempty,min=1" example:"1"`                                                 // 操作者ID
+	Action     string     `form:"action" binding:"omitempty,max=64" example:"user.role"`                                          // 操作类型，以 . 结尾时按前缀匹配，如 book.
+	TargetType string     `form:"target_type" binding:"omitempty,max=32" example:"user"`                                          // 操作对象类型
+	TargetID   uint       `form:"target_id" binding:"omitempty,min=1" example:"1"`                                                // 操作对象ID
+	RequestID  string     `form:"request_id" binding:"omitempty,max=64"`                                                          // 请求ID
+	StartTime  *time.Time `form:"start_time" time_format:"2006-01-02 15:04:05" binding:"omitempty" example:"2024-01-01 00:00:00"` // 开始时间
+	EndTime    *time.Time `form:"end_time" time_format:"2006-01-02 15:04:05" binding:"omitempty" example:"2024-12-31 23:59:59"`   // 结束时间
+	PaginationRequest
+}
@@ -0,0 +1,13 @@
+package request
+
+// ProviderRequest 认证提供方路径参数
+type ProviderRequest struct {
+	Provider string `uri:"provider" binding:"required,max=64"` // 认证提供方标识
+}
+
+// SSOCallbackRequest 单点登录回调请求
+// @Description 身份提供方重定向回前端后，前端将地址中的 code 和 state 提交给该接口
+type SSOCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`  // 授权码
+	State string `json:"state" binding:"required"` // 发起登录时生成的state
+}
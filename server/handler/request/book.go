@@ -3,13 +3,13 @@ package request
 // CreateBookRequest 创建图书请求
 // @Description 创建新图书的请求参数
 type CreateBookRequest struct {
-	ISBN      string  `json:"isbn" binding:"required,min=10,max=13" example:"9787111111111"`
+	ISBN      string  `json:"isbn" binding:"required,isbn" example:"978-7-111-11111-5"` // ISBN-10或ISBN-13，可带连字符，统一以ISBN-13存储
 	Title     string  `json:"title" binding:"required,min=1,max=128" example:"The Catcher in the Rye"`
 	Author    string  `json:"author" binding:"required,min=1,max=64" example:"J.D. Salinger"`
 	Publisher string  `json:"publisher" binding:"required,min=1,max=64" example:"Little, Brown and Company"`
 	Category  string  `json:"category" binding:"required,min=1,max=32" example:"Fiction"`
 	Price     float64 `json:"price" binding:"required,min=0" example:"10.00"`
-	Total     int     `json:"total" binding:"required,min=1" example:"100"`
+	Total     int     `json:"total" binding:"required,min=1" example:"100"` // 副本数量，按数量自动生成条码
 	Location  string  `json:"location" binding:"required,min=1,max=32" example:"Shelf A1"`
 	Cover     string  `json:"cover" binding:"omitempty,url" example:"https://example.com/cover.jpg"`
 	Summary   string  `json:"summary" binding:"omitempty,max=1000" example:"This is a great book about life and love."`
@@ -32,7 +32,7 @@ type UpdateBookRequest struct {
 // UpdateBookStockRequest 更新图书库存请求
 // @Description 更新图书库存的请求参数
 type UpdateBookStockRequest struct {
-	Change int `json:"change" binding:"required" example:"10"` // 可以为负数，表示减少库存
+	Change int `json:"change" binding:"required" example:"10"` // 可以为负数，表示注销相应数量的在架副本
 }
 
 // BookSearchRequest 图书搜索请求
@@ -45,3 +45,31 @@ type BookSearchRequest struct {
 	Status    *int    `form:"status" binding:"omitempty,oneof=0 1" example:"0"`
 	SearchRequest
 }
+
+// ImportMARCRequest MARC批量导入请求，文件通过 multipart 表单的 file 字段上传
+// 记录中缺少出版社、分类、馆藏位置或价格时使用请求中的默认值，都没有时该记录导入失败
+type ImportMARCRequest struct {
+	Format    string  `form:"format" binding:"omitempty,oneof=iso2709 marcxml" example:"marcxml"` // 文件格式，为空时按内容自动识别
+	Copies    int     `form:"copies" binding:"omitempty,min=1,max=100" example:"1"`               // 每种图书生成的副本数量，默认1
+	Publisher string  `form:"publisher" binding:"omitempty,max=64" example:"Unknown"`             // 默认出版社
+	Category  string  `form:"category" binding:"omitempty,max=32" example:"Fiction"`              // 默认分类
+	Location  string  `form:"location" binding:"omitempty,max=32" example:"Shelf A1"`             // 默认馆藏位置
+	Price     float64 `form:"price" binding:"omitempty,min=0" example:"10.00"`                    // 默认价格
+}
+
+// ExportMARCRequest MARC导出请求，查询条件与图书列表相同
+type ExportMARCRequest struct {
+	Format   string `form:"format" binding:"omitempty,oneof=iso2709 marcxml" example:"iso2709"` // 导出格式，默认 iso2709
+	Keyword  string `form:"keyword" binding:"omitempty,min=1"`
+	Category string `form:"category" binding:"omitempty,min=1,max=32" example:"Fiction"`
+	Page     int    `form:"page" binding:"omitempty,min=1"`              // 为空时导出全部匹配的图书
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"` // 指定页码时的每页数量，默认100
+}
+
+// BookFullTextSearchRequest 图书全文检索请求
+// @Description 按书名、作者、出版社和简介全文检索图书
+type BookFullTextSearchRequest struct {
+	Q        string `form:"q" binding:"required,max=200" example:"数据库 \"系统概念\" prog*"`   // 查询，空格分隔的词需全部匹配，"..." 为短语，以 * 结尾为前缀查询，合法的ISBN按ISBN精确查找
+	Category string `form:"category" binding:"omitempty,min=1,max=32" example:"Fiction"` // 分类筛选
+	PaginationRequest
+}
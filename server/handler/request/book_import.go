@@ -0,0 +1,14 @@
+package request
+
+// CreateImportRequest 图书批量导入请求，文件通过 multipart 表单的 file 字段上传
+type CreateImportRequest struct {
+	Format  string `form:"format" binding:"omitempty,oneof=csv xlsx" example:"csv"`                              // 文件格式，为空时按扩展名判断
+	DryRun  bool   `form:"dry_run" example:"true"`                                                               // 试运行，只返回将会新建或更新的图书，不写入
+	Mapping string `form:"mapping" binding:"omitempty,max=2048" example:"{\"isbn\":\"ISBN号\",\"title\":\"书名\"}"` // 列映射（JSON），字段名到表头的对应关系，未指定的字段按与字段名相同的表头匹配
+}
+
+// ImportRowSearchRequest 导入数据行查询请求
+type ImportRowSearchRequest struct {
+	Action string `form:"action" binding:"omitempty,oneof=create update unchanged error" example:"error"` // 按处理结果筛选
+	PaginationRequest
+}
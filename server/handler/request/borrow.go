@@ -12,7 +12,7 @@ type CreateBorrowRequest struct {
 // ReturnBookRequest 归还图书请求
 type ReturnBookRequest struct {
 	BorrowID uint    `json:"borrow_id" binding:"required,min=1" example:"1"`
-	Fine     float64 `json:"fine" binding:"omitempty,min=0" example:"10.00"`
+	Fine     float64 `json:"fine" binding:"omitempty,min=0" example:"10.00"` // 归还时缴纳的罚金金额
 	Remark   string  `json:"remark" binding:"omitempty,max=256" example:"请尽快归还"`
 }
 
@@ -33,3 +33,14 @@ type BorrowSearchRequest struct {
 	EndTime    time.Time `form:"end_time" binding:"omitempty,gtefield=StartTime" example:"2024-01-01T00:00:00+08:00"`
 	SearchRequest
 }
+
+// CheckoutRequest 扫码借阅请求
+type CheckoutRequest struct {
+	UserID  uint   `json:"user_id" binding:"required,min=1" example:"1"`
+	Barcode string `json:"barcode" binding:"required,max=32" example:"B000001001"`
+}
+
+// CheckinRequest 扫码归还请求
+type CheckinRequest struct {
+	Barcode string `json:"barcode" binding:"required,max=32" example:"B000001001"`
+}
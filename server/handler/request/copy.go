@@ -0,0 +1,31 @@
+package request
+
+// CreateCopyRequest 添加副本请求
+type CreateCopyRequest struct {
+	Barcode   string `json:"barcode" binding:"omitempty,max=32" example:"B000001001"` // 为空时自动生成
+	Condition int    `json:"condition" binding:"omitempty,oneof=1 2 3" example:"1"`   // 1-良好 2-一般 3-破损
+	Branch    string `json:"branch" binding:"omitempty,max=64" example:"总馆"`
+	Location  string `json:"location" binding:"omitempty,max=64" example:"Shelf A1"` // 为空时使用图书的馆藏位置
+	Status    int    `json:"status" binding:"omitempty,oneof=1 4" example:"1"`       // 1-在架 4-维修中
+	Remark    string `json:"remark" binding:"omitempty,max=256" example:"捐赠"`
+}
+
+// UpdateCopyRequest 更新副本请求
+type UpdateCopyRequest struct {
+	Barcode   string `json:"barcode" binding:"omitempty,max=32" example:"B000001001"`
+	Condition int    `json:"condition" binding:"omitempty,oneof=1 2 3" example:"2"` // 1-良好 2-一般 3-破损
+	Branch    string `json:"branch" binding:"omitempty,max=64" example:"东区分馆"`
+	Location  string `json:"location" binding:"omitempty,max=64" example:"Shelf B2"`
+	Status    int    `json:"status" binding:"omitempty,oneof=1 4 5 6" example:"4"` // 1-在架 4-维修中 5-遗失 6-注销
+	Remark    string `json:"remark" binding:"omitempty,max=256" example:"封面破损"`
+}
+
+// CopySearchRequest 副本查询请求
+type CopySearchRequest struct {
+	Status int `form:"status" binding:"omitempty,oneof=1 2 3 4 5 6" example:"1"` // 1-在架 2-借出 3-预约保留 4-维修中 5-遗失 6-注销
+}
+
+// BarcodeRequest 通过条码查询的通用结构
+type BarcodeRequest struct {
+	Barcode string `uri:"barcode" binding:"required,max=32"`
+}
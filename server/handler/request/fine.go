@@ -0,0 +1,42 @@
+package request
+
+// PayFineRequest 缴纳罚金请求
+type PayFineRequest struct {
+	Amount float64 `json:"amount" binding:"required,gt=0" example:"5.50"` // 缴费金额，可部分缴纳
+}
+
+// ChargeFineRequest 记录罚款请求
+type ChargeFineRequest struct {
+	UserID   uint    `json:"user_id" binding:"required,min=1" example:"1"`
+	BorrowID *uint   `json:"borrow_id" binding:"omitempty,min=1" example:"1"` // 关联的借阅记录
+	Amount   float64 `json:"amount" binding:"required,gt=0" example:"20.00"`
+	Reason   string  `json:"reason" binding:"required,max=256" example:"图书损坏"`
+}
+
+// WaiveFineRequest 减免罚金请求
+type WaiveFineRequest struct {
+	UserID uint    `json:"user_id" binding:"required,min=1" example:"1"`
+	Amount float64 `json:"amount" binding:"required,gt=0" example:"5.00"`
+	Reason string  `json:"reason" binding:"required,max=256" example:"系统故障导致逾期"`
+}
+
+// RefundFineRequest 退款请求
+type RefundFineRequest struct {
+	PaymentID uint    `json:"payment_id" binding:"required,min=1" example:"1"` // 缴费流水ID
+	Amount    float64 `json:"amount" binding:"required,gt=0" example:"5.00"`
+	Reason    string  `json:"reason" binding:"required,max=256" example:"重复缴费"`
+}
+
+// FineSearchRequest 罚金流水查询请求
+type FineSearchRequest struct {
+	StartTime string `form:"start_time" binding:"omitempty" example:"2024-01-01 00:00:00"`
+	EndTime   string `form:"end_time" binding:"omitempty" example:"2024-12-31 23:59:59"`
+	PaginationRequest
+}
+
+// FineReportRequest 罚金汇总报表请求
+type FineReportRequest struct {
+	StartTime string `form:"start_time" binding:"omitempty" example:"2024-01-01 00:00:00"`
+	EndTime   string `form:"end_time" binding:"omitempty" example:"2024-12-31 23:59:59"`
+	SearchRequest
+}
@@ -0,0 +1,28 @@
+package request
+
+// LoanPolicyRequest 创建/更新借阅规则请求
+// @Description 借阅规则参数，角色或分类留空表示适用于全部
+type LoanPolicyRequest struct {
+	Name        string  `json:"name" binding:"required,min=1,max=64" example:"学生-小说"`               // 规则名称
+	Role        string  `json:"role" binding:"omitempty,oneof=user librarian admin" example:"user"` // 适用角色
+	Category    string  `json:"category" binding:"omitempty,max=32" example:"Fiction"`              // 适用图书分类
+	LoanDays    int     `json:"loan_days" binding:"required,min=1,max=365" example:"30"`            // 借期（天）
+	MaxRenewals int     `json:"max_renewals" binding:"omitempty,min=0,max=20" example:"1"`          // 最多续借次数
+	MaxLoans    int     `json:"max_loans" binding:"omitempty,min=0" example:"5"`                    // 最多同时借阅数量 0-不限
+	DailyFine   float64 `json:"daily_fine" binding:"omitempty,min=0" example:"0.5"`                 // 逾期每日罚金
+	FineCap     float64 `json:"fine_cap" binding:"omitempty,min=0" example:"50"`                    // 单次借阅罚金上限 0-不设上限
+	GraceDays   int     `json:"grace_days" binding:"omitempty,min=0" example:"2"`                   // 逾期宽限天数
+	Status      int     `json:"status" binding:"omitempty,oneof=1 2" example:"1"`                   // 状态 2-停用 1-启用
+}
+
+// LoanPolicySearchRequest 借阅规则查询请求
+type LoanPolicySearchRequest struct {
+	Category string `form:"category" binding:"omitempty,max=32" example:"Fiction"`
+	SearchRequest
+}
+
+// ResolveLoanPolicyRequest 查询生效借阅规则请求
+type ResolveLoanPolicyRequest struct {
+	Role     string `form:"role" binding:"required,oneof=user librarian admin" example:"user"`
+	Category string `form:"category" binding:"omitempty,max=32" example:"Fiction"`
+}
@@ -0,0 +1,11 @@
+package request
+
+// CreateReservationRequest 创建预约请求
+type CreateReservationRequest struct {
+	BookID uint `json:"book_id" binding:"required,min=1" example:"1"`
+}
+
+// ReservationSearchRequest 预约记录查询请求
+type ReservationSearchRequest struct {
+	Status int `form:"status" binding:"omitempty,oneof=1 2 3 4 5" example:"1"` // 1-排队中 2-待取书 3-已完成 4-已取消 5-已过期
+}
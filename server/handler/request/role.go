@@ -0,0 +1,12 @@
+package request
+
+// RoleURIRequest 角色路径参数
+type RoleURIRequest struct {
+	Role string `uri:"role" binding:"required,oneof=user librarian admin"` // 角色
+}
+
+// UpdateRolePermissionsRequest 修改角色权限请求
+// @Description 修改角色权限请求参数，用给定的权限替换角色现有的全部权限
+type UpdateRolePermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required" example:"borrow:checkout,borrow:checkin"` // 权限名称列表，为空表示移除全部权限
+}
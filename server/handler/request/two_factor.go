@@ -0,0 +1,20 @@
+package request
+
+// TwoFactorLoginRequest 登录第二步请求
+// @Description 提交登录第一步返回的临时令牌和验证码
+type TwoFactorLoginRequest struct {
+	InterimToken string `json:"interim_token" binding:"required"`                // 登录第一步返回的临时令牌
+	Code         string `json:"code" binding:"required,max=32" example:"123456"` // 验证器App中的6位验证码或恢复码
+}
+
+// TwoFactorEnrollRequest 登录时绑定验证器请求
+// @Description 角色要求启用双因素认证的账号，用登录第一步返回的临时令牌获取绑定信息
+type TwoFactorEnrollRequest struct {
+	InterimToken string `json:"interim_token" binding:"required"` // 登录第一步返回的临时令牌
+}
+
+// TwoFactorCodeRequest 双因素认证验证码请求
+// @Description 启用、关闭双因素认证或重新生成恢复码时提交的验证码
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required,max=32" example:"123456"` // 验证器App中的6位验证码，关闭时也可使用恢复码
+}
@@ -18,7 +18,7 @@ type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email" example:"zhangsan@example.com"`  // 邮箱地址
 	Phone    string `json:"phone" binding:"omitempty,len=11" example:"13800138000"`         // 手机号(11位)
 	Nickname string `json:"nickname" binding:"omitempty,min=2,max=32" example:"张三"`         // 昵称(2-32个字符)
-	Role     string `json:"role" binding:"required,oneof=user admin" example:"user"`        // 角色(user/admin)
+	Lang     string `json:"lang" binding:"omitempty,max=16" example:"zh"`                   // 验证邮件的语言 zh/en，默认取 Accept-Language
 }
 
 // LoginRequest 用户登录请求
@@ -26,6 +26,19 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=32" example:"zhangsan"`    // 用户名(3-32个字符)
 	Password string `json:"password" binding:"required,min=6,max=32" example:"password123"` // 密码(6-32个字符)
+	Provider string `json:"provider" binding:"omitempty,max=64" example:"local"`            // 认证提供方，默认为本地账号
+}
+
+// RefreshTokenRequest 刷新令牌请求
+// @Description 刷新令牌请求参数
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"` // 登录或上次刷新时获得的刷新令牌
+}
+
+// LogoutRequest 退出登录请求
+// @Description 退出登录请求参数
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"` // 同时注销的刷新令牌（可选）
 }
 
 // UpdateUserRequest 更新用户信息请求
@@ -46,7 +59,13 @@ type ChangePasswordRequest struct {
 // UpdateUserRoleRequest 更新用户角色请求
 // @Description 更新用户角色请求参数
 type UpdateUserRoleRequest struct {
-	Role string `json:"role" binding:"required,oneof=user admin" example:"user"` // user-普通用户 admin-管理员
+	Role string `json:"role" binding:"required,oneof=user librarian admin" example:"user"` // user-普通用户 librarian-馆员 admin-管理员
+}
+
+// UpdateUserStatusRequest 启用或禁用账号请求
+// @Description 启用或禁用账号请求参数
+type UpdateUserStatusRequest struct {
+	Status int `json:"status" binding:"required,oneof=1 2" example:"2"` // 1-启用 2-禁用
 }
 
 // UserSearchRequest 用户搜索请求
@@ -57,3 +76,36 @@ type UserSearchRequest struct {
 	Keyword string `form:"keyword" binding:"omitempty,min=1" example:"张三"`   // 关键词
 	SearchRequest
 }
+
+// LoginFailureSearchRequest 登录失败记录查询请求
+// @Description 登录失败记录查询参数
+type LoginFailureSearchRequest struct {
+	Username string `form:"username" binding:"omitempty,max=32" example:"zhangsan"` // 用户名
+	Limit    int    `form:"limit" binding:"omitempty,min=1,max=500" example:"50"`   // 返回条数，默认50
+}
+
+// ForgotPasswordRequest 忘记密码请求
+// @Description 忘记密码请求参数，向该邮箱发送重置密码邮件
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email" example:"zhangsan@example.com"` // 注册时填写的邮箱
+	Lang  string `json:"lang" binding:"omitempty,max=16" example:"zh"`                  // 邮件语言 zh/en，默认取 Accept-Language
+}
+
+// ResetPasswordRequest 重置密码请求
+// @Description 重置密码请求参数
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`                                              // 重置密码邮件中的令牌
+	NewPassword string `json:"new_password" binding:"required,min=6,max=32" example:"newpassword123"` // 新密码(6-32个字符)
+}
+
+// SendVerificationRequest 发送验证邮件请求
+// @Description 发送验证邮件请求参数
+type SendVerificationRequest struct {
+	Lang string `json:"lang" binding:"omitempty,max=16" example:"zh"` // 邮件语言 zh/en，默认取 Accept-Language
+}
+
+// VerifyEmailRequest 验证邮箱请求
+// @Description 验证邮箱请求参数
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"` // 验证邮件中的令牌
+}
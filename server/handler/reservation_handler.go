@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"errors"
+	"library/handler/request"
+	"library/handler/response"
+	"library/middleware"
+	"library/model"
+	"library/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReservationHandler struct {
+	reservationService service.ReservationServiceInterface
+}
+
+func NewReservationHandler(reservationService service.ReservationServiceInterface) *ReservationHandler {
+	return &ReservationHandler{
+		reservationService: reservationService,
+	}
+}
+
+func (h *ReservationHandler) authCheck(c *gin.Context) (uint, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, response.NewResponse(http.StatusUnauthorized, "User not logged in", nil))
+		return 0, false
+	}
+	return userID.(uint), true
+}
+
+// errorStatus 将服务层错误映射为HTTP状态码
+func (h *ReservationHandler) errorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrAlreadyExists),
+		errors.Is(err, service.ErrBookAvailable),
+		errors.Is(err, service.ErrBookNotAvailable),
+		errors.Is(err, service.ErrInvalidStatus):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CreateReservation 预约图书
+// @Summary 预约图书
+// @Description 图书无可借副本时，读者加入该书的预约队列（先到先得）
+// @Tags 预约管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request body request.CreateReservationRequest true "预约信息"
+// @Success 200 {object} response.Response{data=model.Reservation}
+// @Failure 409 {object} response.Response "图书可直接借阅或已预约"
+// @Router /reservations [post]
+func (h *ReservationHandler) CreateReservation(c *gin.Context) {
+	userID, ok := h.authCheck(c)
+	if !ok {
+		return
+	}
+	var req request.CreateReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	reservation, err := h.reservationService.CreateReservation(actorFrom(c), userID, req.BookID)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Book reserved successfully", reservation))
+}
+
+// CancelReservation 取消预约
+// @Summary 取消预约
+// @Description 读者取消自己的预约，管理员可取消任意预约；已保留的副本将转给队列中的下一位读者
+// @Tags 预约管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "预约ID"
+// @Success 200 {object} response.Response
+// @Router /reservations/{id} [delete]
+func (h *ReservationHandler) CancelReservation(c *gin.Context) {
+	userID, ok := h.authCheck(c)
+	if !ok {
+		return
+	}
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid reservation ID", nil))
+		return
+	}
+
+	if err := h.reservationService.CancelReservation(actorFrom(c), uri.ID, userID, middleware.HasPermission(c, model.PermReservationManage)); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Reservation cancelled successfully", nil))
+}
+
+// GetReservation 获取预约详情
+// @Summary 获取预约详情
+// @Description 获取指定预约的详细信息
+// @Tags 预约管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "预约ID"
+// @Success 200 {object} response.Response{data=model.Reservation}
+// @Router /reservations/{id} [get]
+func (h *ReservationHandler) GetReservation(c *gin.Context) {
+	userID, ok := h.authCheck(c)
+	if !ok {
+		return
+	}
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid reservation ID", nil))
+		return
+	}
+
+	reservation, err := h.reservationService.GetReservation(uri.ID)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	// 检查是否是管理员或预约者本人
+	if !middleware.HasPermission(c, model.PermReservationManage) && reservation.UserID != userID {
+		c.JSON(http.StatusForbidden, response.NewResponse(http.StatusForbidden, "Permission denied", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", reservation))
+}
+
+// ListMyReservations 获取当前用户的预约
+// @Summary 获取我的预约
+// @Description 获取当前登录用户的预约记录
+// @Tags 预约管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request query request.ReservationSearchRequest false "查询条件"
+// @Success 200 {object} response.Response
+// @Router /reservations [get]
+func (h *ReservationHandler) ListMyReservations(c *gin.Context) {
+	userID, ok := h.authCheck(c)
+	if !ok {
+		return
+	}
+	var req request.ReservationSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	reservations, err := h.reservationService.GetUserReservations(userID, req.Status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", gin.H{
+		"total": len(reservations),
+		"items": reservations,
+	}))
+}
+
+// GetBookQueue 获取图书预约队列（管理员接口）
+// @Summary 获取图书预约队列
+// @Description 管理员查看某本书的预约队列，待取书的预约排在前面，其余按排队先后排序
+// @Tags 预约管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "图书ID"
+// @Success 200 {object} response.Response
+// @Router /books/{id}/reservations [get]
+func (h *ReservationHandler) GetBookQueue(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid book ID", nil))
+		return
+	}
+
+	queue, err := h.reservationService.GetBookQueue(uri.ID)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", gin.H{
+		"total": len(queue),
+		"items": queue,
+	}))
+}
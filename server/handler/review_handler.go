@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"library/handler/request"
 	"library/handler/response"
 	"library/model"
@@ -59,7 +60,7 @@ func (h *ReviewHandler) CreateReview(c *gin.Context) {
 		Rating:  req.Rating,
 	}
 
-	if err := h.reviewService.CreateReview( review); err != nil {
+	if err := h.reviewService.CreateReview(actorFrom(c), review); err != nil {
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
 		return
 	}
@@ -112,7 +113,7 @@ func (h *ReviewHandler) UpdateReview(c *gin.Context) {
 	review.Content = req.Content
 	review.Rating = req.Rating
 
-	if err := h.reviewService.UpdateReview( review); err != nil {
+	if err := h.reviewService.UpdateReview(actorFrom(c), review); err != nil {
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
 		return
 	}
@@ -155,7 +156,7 @@ func (h *ReviewHandler) DeleteReview(c *gin.Context) {
 		return
 	}
 
-	if err := h.reviewService.DeleteReview( uri.ID, userID); err != nil {
+	if err := h.reviewService.DeleteReview(actorFrom(c), uri.ID, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
 		return
 	}
@@ -246,12 +247,6 @@ func (h *ReviewHandler) UpdateReviewStatus(c *gin.Context) {
 		return
 	}
 
-	role, _ := c.Get("role")
-	if role.(string) != "admin" {
-		c.JSON(http.StatusForbidden, response.NewResponse(http.StatusForbidden, "没有权限修改评论状态", nil))
-		return
-	}
-
 	var uri request.IDRequest
 	if err := c.ShouldBindUri(&uri); err != nil {
 		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "无效的评论ID", nil))
@@ -264,14 +259,11 @@ func (h *ReviewHandler) UpdateReviewStatus(c *gin.Context) {
 		return
 	}
 
-	review, err := h.reviewService.GetReview( uri.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
-		return
-	}
-
-	review.Status = req.Status
-	if err := h.reviewService.UpdateReview( review); err != nil {
+	if err := h.reviewService.UpdateReviewStatus(actorFrom(c), uri.ID, req.Status); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, response.NewResponse(http.StatusNotFound, "评论不存在", nil))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
 		return
 	}
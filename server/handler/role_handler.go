@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"errors"
+	"library/handler/request"
+	"library/handler/response"
+	"library/model"
+	"library/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleHandler struct {
+	permissionService service.PermissionServiceInterface
+}
+
+func NewRoleHandler(permissionService service.PermissionServiceInterface) *RoleHandler {
+	return &RoleHandler{
+		permissionService: permissionService,
+	}
+}
+
+// errorStatus 将服务层错误映射为HTTP状态码
+func (h *RoleHandler) errorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidParameter):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrPermissionDenied):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ListRoles 获取全部角色及其权限（管理员接口）
+// @Summary 获取角色权限
+// @Description 获取全部角色及其权限，以及系统支持的全部权限说明。管理员角色固定拥有全部权限
+// @Tags 角色权限
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Success 200 {object} response.Response
+// @Router /roles [get]
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.permissionService.ListRolePermissions()
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", gin.H{
+		"roles":       roles,
+		"permissions": model.Permissions,
+	}))
+}
+
+// UpdateRolePermissions 修改角色权限（管理员接口）
+// @Summary 修改角色权限
+// @Description 用给定的权限替换角色现有的全部权限并记录审计日志，管理员角色不能修改
+// @Tags 角色权限
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param role path string true "角色 user/librarian"
+// @Param request body request.UpdateRolePermissionsRequest true "权限列表"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "未知的权限名称"
+// @Failure 403 {object} response.Response "管理员角色不能修改"
+// @Router /roles/{role}/permissions [put]
+func (h *RoleHandler) UpdateRolePermissions(c *gin.Context) {
+	var uri request.RoleURIRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid role", nil))
+		return
+	}
+
+	var req request.UpdateRolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	if err := h.permissionService.UpdateRolePermissions(actorFrom(c), uri.Role, req.Permissions); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	permissions, err := h.permissionService.GetRolePermissions(uri.Role)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Role permissions updated successfully", gin.H{
+		"role":        uri.Role,
+		"permissions": permissions,
+	}))
+}
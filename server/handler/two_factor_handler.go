@@ -0,0 +1,321 @@
+package handler
+
+import (
+	"errors"
+	"library/handler/request"
+	"library/handler/response"
+	"library/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseInterimToken 解析登录第一步返回的临时令牌，失败时返回401或503
+func parseInterimToken(c *gin.Context, token, tokenType string) (*middleware.MyClaims, bool) {
+	mc, err := middleware.ParseInterimToken(token, tokenType)
+	if err != nil {
+		if errors.Is(err, middleware.ErrTokenStore) {
+			c.JSON(http.StatusServiceUnavailable, response.NewResponse(http.StatusServiceUnavailable, err.Error(), nil))
+			return nil, false
+		}
+		c.JSON(http.StatusUnauthorized, response.NewResponse(http.StatusUnauthorized, "Invalid interim token", nil))
+		return nil, false
+	}
+	return mc, true
+}
+
+// consumeInterimToken 作废临时令牌，令牌已被使用时返回401
+func consumeInterimToken(c *gin.Context, mc *middleware.MyClaims) bool {
+	if err := middleware.ConsumeInterimToken(mc); err != nil {
+		if errors.Is(err, middleware.ErrTokenStore) {
+			c.JSON(http.StatusServiceUnavailable, response.NewResponse(http.StatusServiceUnavailable, err.Error(), nil))
+			return false
+		}
+		c.JSON(http.StatusUnauthorized, response.NewResponse(http.StatusUnauthorized, "Invalid interim token", nil))
+		return false
+	}
+	return true
+}
+
+// LoginTwoFactor 登录第二步，提交验证码
+// @Summary 登录第二步：提交验证码
+// @Description 提交登录第一步返回的 type=mfa 临时令牌和验证器App中的验证码（或一个恢复码），验证通过后返回令牌对。
+// @Description 临时令牌只能提交一次，验证码错误时需从第一步重新登录。验证码错误计入登录失败次数，失败过多时账号被锁定
+// @Tags 双因素认证
+// @Accept json
+// @Produce json
+// @Param request body request.TwoFactorLoginRequest true "临时令牌和验证码"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response "临时令牌无效或验证码错误"
+// @Failure 423 {object} response.Response "失败次数过多，账号暂时锁定"
+// @Router /users/login/2fa [post]
+func (h *UserHandler) LoginTwoFactor(c *gin.Context) {
+	var req request.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	mc, ok := parseInterimToken(c, req.InterimToken, middleware.TokenTypeMFA)
+	if !ok {
+		return
+	}
+	// 先消费临时令牌再校验验证码，同一令牌的并发请求只有一个能进入校验，不能用来并行猜测验证码
+	if !consumeInterimToken(c, mc) {
+		return
+	}
+
+	user, err := h.twoFactorService.VerifyLogin(mc.UserID, req.Code, c.ClientIP())
+	if abortLocked(c, err) {
+		return
+	}
+	if err != nil {
+		status := h.errorStatus(err)
+		if status == http.StatusBadRequest || status == http.StatusNotFound {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	loginSucceeded(c, user, nil)
+}
+
+// LoginTwoFactorSetup 登录时绑定验证器
+// @Summary 登录时绑定验证器
+// @Description 角色要求启用双因素认证但尚未启用的账号，用登录第一步返回的 type=enroll 临时令牌获取密钥和二维码
+// @Tags 双因素认证
+// @Accept json
+// @Produce json
+// @Param request body request.TwoFactorEnrollRequest true "临时令牌"
+// @Success 200 {object} response.Response{data=service.TwoFactorSetup}
+// @Failure 401 {object} response.Response "临时令牌无效"
+// @Failure 403 {object} response.Response "账号已禁用"
+// @Router /users/login/2fa/setup [post]
+func (h *UserHandler) LoginTwoFactorSetup(c *gin.Context) {
+	var req request.TwoFactorEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	mc, ok := parseInterimToken(c, req.InterimToken, middleware.TokenTypeEnroll)
+	if !ok {
+		return
+	}
+
+	setup, err := h.twoFactorService.Setup(mc.UserID)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", setup))
+}
+
+// LoginTwoFactorEnable 登录时确认绑定
+// @Summary 登录时确认绑定
+// @Description 提交 type=enroll 临时令牌和验证器App中的验证码，启用双因素认证并完成登录。
+// @Description 返回令牌对和恢复码，恢复码只返回这一次
+// @Tags 双因素认证
+// @Accept json
+// @Produce json
+// @Param request body request.TwoFactorLoginRequest true "临时令牌和验证码"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "验证码错误"
+// @Failure 401 {object} response.Response "临时令牌无效"
+// @Failure 403 {object} response.Response "账号已禁用"
+// @Router /users/login/2fa/enable [post]
+func (h *UserHandler) LoginTwoFactorEnable(c *gin.Context) {
+	var req request.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	mc, ok := parseInterimToken(c, req.InterimToken, middleware.TokenTypeEnroll)
+	if !ok {
+		return
+	}
+
+	codes, err := h.twoFactorService.Enable(mc.UserID, req.Code)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+	if !consumeInterimToken(c, mc) {
+		return
+	}
+
+	user, err := h.userService.GetUserInfo(mc.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, response.NewResponse(http.StatusUnauthorized, "Invalid interim token", nil))
+		return
+	}
+
+	loginSucceeded(c, user, gin.H{"recovery_codes": codes})
+}
+
+// GetTwoFactorStatus 查询双因素认证状态
+// @Summary 查询双因素认证状态
+// @Description 查询当前用户是否已启用双因素认证、角色是否要求启用以及剩余恢复码数量
+// @Tags 双因素认证
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Success 200 {object} response.Response{data=service.TwoFactorStatus}
+// @Router /users/2fa [get]
+func (h *UserHandler) GetTwoFactorStatus(c *gin.Context) {
+	status, err := h.twoFactorService.Status(c.GetUint("userID"))
+	if err != nil {
+		code := h.errorStatus(err)
+		c.JSON(code, response.NewResponse(code, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", status))
+}
+
+// SetupTwoFactor 绑定验证器
+// @Summary 绑定验证器
+// @Description 生成新的TOTP密钥，返回密钥、otpauth:// 配置URI和二维码。调用 /users/2fa/enable 提交验证码后才生效
+// @Tags 双因素认证
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Success 200 {object} response.Response{data=service.TwoFactorSetup}
+// @Failure 409 {object} response.Response "已启用双因素认证"
+// @Router /users/2fa/setup [post]
+func (h *UserHandler) SetupTwoFactor(c *gin.Context) {
+	setup, err := h.twoFactorService.Setup(c.GetUint("userID"))
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", setup))
+}
+
+// EnableTwoFactor 启用双因素认证
+// @Summary 启用双因素认证
+// @Description 提交验证器App中的验证码确认绑定，返回恢复码，恢复码只返回这一次
+// @Tags 双因素认证
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request body request.TwoFactorCodeRequest true "验证码"
+// @Success 200 {object} response.Response{data=[]string}
+// @Failure 400 {object} response.Response "验证码错误"
+// @Router /users/2fa/enable [post]
+func (h *UserHandler) EnableTwoFactor(c *gin.Context) {
+	var req request.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	codes, err := h.twoFactorService.Enable(c.GetUint("userID"), req.Code)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Two-factor authentication enabled", codes))
+}
+
+// DisableTwoFactor 关闭双因素认证
+// @Summary 关闭双因素认证
+// @Description 提交验证码或恢复码关闭双因素认证。角色要求启用双因素认证时不能关闭
+// @Tags 双因素认证
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request body request.TwoFactorCodeRequest true "验证码"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "验证码错误"
+// @Failure 403 {object} response.Response "角色要求启用双因素认证"
+// @Router /users/2fa/disable [post]
+func (h *UserHandler) DisableTwoFactor(c *gin.Context) {
+	var req request.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	if err := h.twoFactorService.Disable(c.GetUint("userID"), req.Code); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Two-factor authentication disabled", nil))
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+// @Summary 重新生成恢复码
+// @Description 提交验证码后重新生成恢复码，之前的恢复码全部作废
+// @Tags 双因素认证
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request body request.TwoFactorCodeRequest true "验证码"
+// @Success 200 {object} response.Response{data=[]string}
+// @Failure 400 {object} response.Response "验证码错误"
+// @Router /users/2fa/recovery-codes [post]
+func (h *UserHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req request.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(c.GetUint("userID"), req.Code)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", codes))
+}
+
+// ResetTwoFactor 重置用户的双因素认证（管理员接口）
+// @Summary 重置双因素认证
+// @Description 用户丢失验证器和恢复码时由管理员关闭其双因素认证。角色要求启用的账号下次登录时需重新绑定
+// @Tags 双因素认证
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "用户ID"
+// @Success 200 {object} response.Response
+// @Failure 409 {object} response.Response "该用户未启用双因素认证"
+// @Router /users/{id}/2fa [delete]
+func (h *UserHandler) ResetTwoFactor(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid user ID", nil))
+		return
+	}
+
+	if err := h.twoFactorService.Reset(actorFrom(c), uri.ID); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Two-factor authentication reset", nil))
+}
@@ -1,29 +1,132 @@
 package handler
 
 import (
+	"errors"
 	"library/handler/request"
 	"library/handler/response"
 	"library/middleware"
 	"library/model"
 	"library/service"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type UserHandler struct {
-	userService service.UserServiceInterface
+	userService      service.UserServiceInterface
+	authService      service.AuthServiceInterface
+	twoFactorService service.TwoFactorServiceInterface
 }
 
-func NewUserHandler(userService service.UserServiceInterface) *UserHandler {
+func NewUserHandler(userService service.UserServiceInterface, authService service.AuthServiceInterface, twoFactorService service.TwoFactorServiceInterface) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:      userService,
+		authService:      authService,
+		twoFactorService: twoFactorService,
 	}
 }
 
+// errorStatus 将服务层错误映射为HTTP状态码
+func (h *UserHandler) errorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidParameter),
+		errors.Is(err, service.ErrInvalidToken),
+		errors.Is(err, service.ErrTokenExpired),
+		errors.Is(err, service.ErrInvalidCode):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrAccountDisabled),
+		errors.Is(err, service.ErrTwoFactorRequired):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrAlreadyExists),
+		errors.Is(err, service.ErrLastAdmin),
+		errors.Is(err, service.ErrInvalidStatus):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// abortLocked 账号被锁定时返回423，IP失败次数过多时返回429，并设置 Retry-After
+func abortLocked(c *gin.Context, err error) bool {
+	var lockedErr *service.LockedError
+	if !errors.As(err, &lockedErr) {
+		return false
+	}
+	status := http.StatusLocked
+	if errors.Is(err, service.ErrTooManyAttempts) {
+		status = http.StatusTooManyRequests
+	}
+	retryAfter := int(math.Ceil(time.Until(lockedErr.UnlockAt).Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(status, response.NewResponse(status, lockedErr.Err.Error(), gin.H{
+		"unlock_at":   lockedErr.UnlockAt,
+		"retry_after": retryAfter,
+	}))
+	return true
+}
+
+// loginSucceeded 签发令牌对并返回登录成功，extra中的字段一并返回
+func loginSucceeded(c *gin.Context, user *model.User, extra gin.H) {
+	pair, err := middleware.GenerateTokenPair(user.ID, user.Username, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, "Failed to generate token", nil))
+		return
+	}
+
+	data := gin.H{
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    pair.ExpiresIn,
+		"user":          user,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Login successful", data))
+}
+
+// completeLogin 认证通过后签发令牌。需要双因素认证时只返回临时令牌，提交验证码或完成绑定后才签发令牌对
+func completeLogin(c *gin.Context, twoFactorService service.TwoFactorServiceInterface, user *model.User) {
+	var tokenType string
+	switch twoFactorService.Requirement(user) {
+	case service.TwoFactorVerify:
+		tokenType = middleware.TokenTypeMFA
+	case service.TwoFactorEnroll:
+		tokenType = middleware.TokenTypeEnroll
+	default:
+		loginSucceeded(c, user, nil)
+		return
+	}
+
+	interim, err := middleware.GenerateInterimToken(user.ID, user.Username, user.Role, tokenType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, "Failed to generate token", nil))
+		return
+	}
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Two-factor authentication required", gin.H{
+		"two_factor_required": true,
+		"interim_token":       interim.Token,
+		"type":                interim.Type,
+		"expires_in":          interim.ExpiresIn,
+	}))
+}
+
+// requestLang 请求体中未指定语言时使用 Accept-Language
+func requestLang(c *gin.Context, lang string) string {
+	if lang != "" {
+		return lang
+	}
+	return c.GetHeader("Accept-Language")
+}
+
 // Register 用户注册
 // @Summary 用户注册
-// @Description 创建新用户账号
+// @Description 创建新的读者账号，公开注册的账号角色固定为普通用户，注册后向邮箱发送验证邮件
 // @Tags 用户管理
 // @Accept json
 // @Produce json
@@ -37,9 +140,10 @@ func (h *UserHandler) Register(c *gin.Context) {
 		return
 	}
 
-	err := h.userService.Register( req.Username, req.Password, req.Email, req.Role)
+	err := h.userService.Register(req.Username, req.Password, req.Email, requestLang(c, req.Lang))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
 		return
 	}
 
@@ -48,12 +152,16 @@ func (h *UserHandler) Register(c *gin.Context) {
 
 // Login 用户登录
 // @Summary 用户登录
-// @Description 用户登录并获取token
+// @Description 用户登录并获取token，provider 指定认证提供方，默认为本地账号。已启用双因素认证的账号返回 two_factor_required 和 type=mfa 的临时令牌，
+// @Description 需调用 /users/login/2fa 提交验证码；角色要求启用但尚未启用的账号返回 type=enroll 的临时令牌，需先完成绑定
 // @Tags 用户管理
 // @Accept json
 // @Produce json
 // @Param request body request.LoginRequest true "登录信息"
 // @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response "用户名或密码错误"
+// @Failure 423 {object} response.Response "失败次数过多，账号暂时锁定，data 中返回解锁时间"
+// @Failure 429 {object} response.Response "该IP失败次数过多或请求过于频繁"
 // @Router /users/login [post]
 func (h *UserHandler) Login(c *gin.Context) {
 	var req request.LoginRequest
@@ -62,23 +170,102 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.Login( req.Username, req.Password)
+	user, err := h.authService.Login(req.Provider, req.Username, req.Password, c.ClientIP())
+	if abortLocked(c, err) {
+		return
+	}
+	if errors.Is(err, service.ErrUnknownProvider) {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Unknown provider", nil))
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, response.NewResponse(http.StatusUnauthorized, err.Error(), nil))
 		return
 	}
 
-	// 生成JWT token
-	token, err := middleware.GenerateToken(user.ID, user.Username, string(user.Role))
+	completeLogin(c, h.twoFactorService, user)
+}
+
+// RefreshToken 刷新令牌
+// @Summary 刷新令牌
+// @Description 使用刷新令牌换取新的访问令牌和刷新令牌。每个刷新令牌只能使用一次，重复使用会注销该用户的全部会话
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param request body request.RefreshTokenRequest true "刷新令牌"
+// @Success 200 {object} response.Response{data=middleware.TokenPair}
+// @Failure 401 {object} response.Response "刷新令牌无效、已使用或已注销"
+// @Router /users/refresh [post]
+func (h *UserHandler) RefreshToken(c *gin.Context) {
+	var req request.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	claims, err := middleware.ConsumeRefreshToken(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, middleware.ErrTokenStore) {
+			c.JSON(http.StatusServiceUnavailable, response.NewResponse(http.StatusServiceUnavailable, err.Error(), nil))
+			return
+		}
+		c.JSON(http.StatusUnauthorized, response.NewResponse(http.StatusUnauthorized, "Invalid refresh token", nil))
+		return
+	}
+
+	// 重新读取用户，使角色变更立即生效，已禁用或删除的账号不能刷新
+	user, err := h.userService.GetUserInfo(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
+		return
+	}
+	if user == nil || user.Status != 1 {
+		c.JSON(http.StatusUnauthorized, response.NewResponse(http.StatusUnauthorized, "Invalid refresh token", nil))
+		return
+	}
+
+	pair, err := middleware.GenerateTokenPair(user.ID, user.Username, user.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, "Failed to generate token", nil))
 		return
 	}
 
-	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Login successful", gin.H{
-		"token": token,
-		"user":  user,
-	}))
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Token refreshed successfully", pair))
+}
+
+// Logout 退出登录
+// @Summary 退出登录
+// @Description 注销当前访问令牌，请求体中携带刷新令牌时一并注销
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request body request.LogoutRequest false "刷新令牌"
+// @Success 200 {object} response.Response
+// @Router /users/logout [post]
+func (h *UserHandler) Logout(c *gin.Context) {
+	var req request.LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+			return
+		}
+	}
+
+	claims := c.MustGet("claims").(*middleware.MyClaims)
+	if err := middleware.RevokeToken(claims); err != nil {
+		c.JSON(http.StatusServiceUnavailable, response.NewResponse(http.StatusServiceUnavailable, err.Error(), nil))
+		return
+	}
+	if req.RefreshToken != "" {
+		if err := middleware.RevokeRefreshToken(req.RefreshToken, claims.UserID); err != nil {
+			c.JSON(http.StatusServiceUnavailable, response.NewResponse(http.StatusServiceUnavailable, err.Error(), nil))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Logout successful", nil))
 }
 
 // GetProfile 获取用户信息
@@ -143,7 +330,7 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		user.Phone = req.Phone
 	}
 
-	if err := h.userService.UpdateUserInfo( user); err != nil {
+	if err := h.userService.UpdateUserInfo(actorFrom(c), user); err != nil {
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
 		return
 	}
@@ -170,7 +357,7 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 	}
 
 	userID, _ := c.Get("userID")
-	if err := h.userService.ChangePassword( userID.(uint), req.OldPassword, req.NewPassword); err != nil {
+	if err := h.userService.ChangePassword(actorFrom(c), userID.(uint), req.OldPassword, req.NewPassword); err != nil {
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
 		return
 	}
@@ -214,7 +401,7 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 
 // UpdateUserRole 更新用户角色（管理员接口）
 // @Summary 更新用户角色
-// @Description 管理员更新用户角色 "user" or "admin"
+// @Description 管理员更新用户角色 "user"、"librarian" 或 "admin"，不能降级最后一个启用的管理员。修改后该用户需重新登录
 // @Tags 用户管理
 // @Accept json
 // @Produce json
@@ -223,6 +410,7 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 // @Param id path int true "用户ID"
 // @Param request body request.UpdateUserRoleRequest true "角色信息"
 // @Success 200 {object} response.Response
+// @Failure 409 {object} response.Response "最后一个启用的管理员"
 // @Router /users/{id}/role [put]
 func (h *UserHandler) UpdateUserRole(c *gin.Context) {
 	var uri request.IDRequest
@@ -237,17 +425,262 @@ func (h *UserHandler) UpdateUserRole(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.GetUserInfo( uri.ID)
+	user, err := h.userService.UpdateRole(actorFrom(c), uri.ID, req.Role)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "User role updated successfully", user))
+}
+
+// UpdateUserStatus 启用或禁用账号（管理员接口）
+// @Summary 启用或禁用账号
+// @Description 管理员启用或禁用账号，禁用后该用户的全部会话立即失效，不能禁用最后一个启用的管理员
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "用户ID"
+// @Param request body request.UpdateUserStatusRequest true "状态"
+// @Success 200 {object} response.Response
+// @Failure 409 {object} response.Response "最后一个启用的管理员"
+// @Router /users/{id}/status [put]
+func (h *UserHandler) UpdateUserStatus(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid user ID", nil))
+		return
+	}
+
+	var req request.UpdateUserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	user, err := h.userService.UpdateStatus(actorFrom(c), uri.ID, req.Status)
+	if err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "User status updated successfully", user))
+}
+
+// DeleteUser 删除账号（管理员接口）
+// @Summary 删除账号
+// @Description 管理员删除账号，删除后该用户的全部会话立即失效，不能删除最后一个启用的管理员
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "用户ID"
+// @Success 200 {object} response.Response
+// @Failure 409 {object} response.Response "最后一个启用的管理员"
+// @Router /users/{id} [delete]
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid user ID", nil))
+		return
+	}
+
+	if err := h.userService.DeleteUser(actorFrom(c), uri.ID); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "User deleted successfully", nil))
+}
+
+// GetLegacyPasswordCount 统计旧版密码哈希的账号数量（管理员接口）
+// @Summary 统计旧版密码哈希账号
+// @Description 管理员查看仍使用旧版md5密码哈希的账号数量，这些账号会在下次登录成功时自动升级
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Success 200 {object} response.Response
+// @Router /users/legacy-passwords [get]
+func (h *UserHandler) GetLegacyPasswordCount(c *gin.Context) {
+	count, err := h.userService.CountLegacyPasswords()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
 		return
 	}
 
-	user.Role = req.Role
-	if err := h.userService.UpdateUserInfo( user); err != nil {
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", gin.H{
+		"legacy_count": count,
+	}))
+}
+
+// UnlockLogin 解除账号的登录锁定（管理员接口）
+// @Summary 解除登录锁定
+// @Description 管理员解除因登录失败次数过多而被锁定的账号，同时清除失败次数
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param id path int true "用户ID"
+// @Success 200 {object} response.Response
+// @Router /users/{id}/unlock [post]
+func (h *UserHandler) UnlockLogin(c *gin.Context) {
+	var uri request.IDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid user ID", nil))
+		return
+	}
+
+	if err := h.userService.UnlockLogin(actorFrom(c), uri.ID); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "User unlocked successfully", nil))
+}
+
+// ListLoginFailures 查看最近失败的登录尝试（管理员接口）
+// @Summary 查看登录失败记录
+// @Description 管理员查看最近失败的登录尝试，按时间倒序，可按用户名筛选
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request query request.LoginFailureSearchRequest false "筛选条件"
+// @Success 200 {object} response.Response{data=[]database.LoginFailure}
+// @Router /users/login-failures [get]
+func (h *UserHandler) ListLoginFailures(c *gin.Context) {
+	var req request.LoginFailureSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 50
+	}
+
+	failures, err := h.userService.ListLoginFailures(req.Username, req.Limit)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, response.NewResponse(http.StatusInternalServerError, err.Error(), nil))
 		return
 	}
 
-	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "User role updated successfully", user))
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Success", failures))
+}
+
+// ForgotPassword 忘记密码
+// @Summary 忘记密码
+// @Description 向邮箱发送重置密码邮件。为避免探测邮箱是否注册，邮箱不存在时同样返回成功
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param request body request.ForgotPasswordRequest true "邮箱"
+// @Success 200 {object} response.Response
+// @Router /users/password/forgot [post]
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var req request.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	if err := h.userService.RequestPasswordReset(req.Email, requestLang(c, req.Lang)); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "If the email is registered, a reset link has been sent", nil))
+}
+
+// ResetPassword 重置密码
+// @Summary 重置密码
+// @Description 使用重置密码邮件中的令牌设置新密码。令牌只能使用一次，重置后该用户的全部会话失效，登录锁定同时解除
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param request body request.ResetPasswordRequest true "令牌和新密码"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "令牌无效、已使用或已过期"
+// @Router /users/password/reset [post]
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var req request.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	if err := h.userService.ResetPassword(req.Token, req.NewPassword); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Password reset successfully", nil))
+}
+
+// SendVerificationEmail 发送邮箱验证邮件
+// @Summary 发送邮箱验证邮件
+// @Description 向当前用户的邮箱发送验证邮件，邮箱已验证时返回409
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer 用户的访问令牌"
+// @Param request body request.SendVerificationRequest false "邮件语言"
+// @Success 200 {object} response.Response
+// @Failure 409 {object} response.Response "邮箱已验证"
+// @Router /users/email/verification [post]
+func (h *UserHandler) SendVerificationEmail(c *gin.Context) {
+	var req request.SendVerificationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+			return
+		}
+	}
+
+	if err := h.userService.SendVerificationEmail(c.GetUint("userID"), requestLang(c, req.Lang)); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Verification email sent", nil))
+}
+
+// VerifyEmail 验证邮箱
+// @Summary 验证邮箱
+// @Description 使用验证邮件中的令牌完成邮箱验证，令牌只能使用一次，签发后修改过邮箱的令牌无效
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param request body request.VerifyEmailRequest true "令牌"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "令牌无效、已使用或已过期"
+// @Router /users/email/verify [post]
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var req request.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewResponse(http.StatusBadRequest, "Invalid request parameters", nil))
+		return
+	}
+
+	if err := h.userService.VerifyEmail(req.Token); err != nil {
+		status := h.errorStatus(err)
+		c.JSON(status, response.NewResponse(status, err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewResponse(http.StatusOK, "Email verified successfully", nil))
 }
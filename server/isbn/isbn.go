@@ -0,0 +1,150 @@
+// Package isbn 解析、校验ISBN并在ISBN-10和ISBN-13之间转换。
+// 图书统一以不带连字符的ISBN-13存储和查询
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrInvalidFormat 不是10位或13位的ISBN
+	ErrInvalidFormat = errors.New("invalid ISBN format")
+	// ErrChecksum 校验位不正确
+	ErrChecksum = errors.New("invalid ISBN check digit")
+	// ErrNoISBN10 978以外前缀的ISBN-13没有对应的ISBN-10
+	ErrNoISBN10 = errors.New("ISBN-13 has no ISBN-10 equivalent")
+)
+
+// Clean 去掉空白和连字符，校验位X统一为大写
+func Clean(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch r {
+		case '-', ' ', '\t', '\u00a0', '\u2010', '\u2011', '\u2013':
+			continue
+		case 'x':
+			b.WriteByte('X')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Normalize 解析带或不带连字符的ISBN-10或ISBN-13，校验后返回ISBN-13
+func Normalize(s string) (string, error) {
+	s = Clean(s)
+	switch len(s) {
+	case 10:
+		if err := check10(s); err != nil {
+			return "", err
+		}
+		return "978" + s[:9] + string(checkDigit13("978"+s[:9])), nil
+	case 13:
+		if err := check13(s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+	return "", ErrInvalidFormat
+}
+
+// Valid 判断是否为合法的ISBN-10或ISBN-13
+func Valid(s string) bool {
+	_, err := Normalize(s)
+	return err == nil
+}
+
+// To13 将ISBN-10或ISBN-13转换为ISBN-13，与 Normalize 相同
+func To13(s string) (string, error) {
+	return Normalize(s)
+}
+
+// To10 将ISBN转换为ISBN-10，只有978前缀的ISBN-13有对应的ISBN-10
+func To10(s string) (string, error) {
+	s13, err := Normalize(s)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(s13, "978") {
+		return "", ErrNoISBN10
+	}
+	body := s13[3:12]
+	return body + string(checkDigit10(body)), nil
+}
+
+// Variants 返回同一ISBN的全部写法（ISBN-13，以及存在时的ISBN-10），用于兼容尚未规范化的数据
+func Variants(s string) ([]string, error) {
+	s13, err := Normalize(s)
+	if err != nil {
+		return nil, err
+	}
+	if s10, err := To10(s13); err == nil {
+		return []string{s13, s10}, nil
+	}
+	return []string{s13}, nil
+}
+
+func check10(s string) error {
+	if !digits(s[:9]) || !(isDigit(s[9]) || s[9] == 'X') {
+		return ErrInvalidFormat
+	}
+	if checkDigit10(s[:9]) != s[9] {
+		return ErrChecksum
+	}
+	return nil
+}
+
+func check13(s string) error {
+	if !digits(s) {
+		return ErrInvalidFormat
+	}
+	if !strings.HasPrefix(s, "978") && !strings.HasPrefix(s, "979") {
+		return ErrInvalidFormat
+	}
+	if checkDigit13(s[:12]) != s[12] {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// checkDigit10 按模11计算ISBN-10校验位，权重依次为10到2
+func checkDigit10(body string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	c := (11 - sum%11) % 11
+	if c == 10 {
+		return 'X'
+	}
+	return byte('0' + c)
+}
+
+// checkDigit13 按模10计算ISBN-13校验位，权重交替为1和3
+func checkDigit13(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(body[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,109 @@
+package isbn
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+		err  error
+	}{
+		{name: "isbn-13", in: "9780306406157", want: "9780306406157"},
+		{name: "isbn-10", in: "0306406152", want: "9780306406157"},
+		{name: "hyphenated isbn-10", in: "0-306-40615-2", want: "9780306406157"},
+		{name: "hyphenated isbn-13", in: "978-7-111-21382-6", want: "9787111213826"},
+		{name: "spaces and unicode hyphens", in: " 978 7‐111–21382 6 ", want: "9787111213826"},
+		{name: "check digit X", in: "080442957X", want: "9780804429573"},
+		{name: "lowercase x", in: "0-8044-2957-x", want: "9780804429573"},
+		{name: "979 prefix", in: "979-10-323-0082-4", want: "9791032300824"},
+		{name: "bad isbn-10 checksum", in: "0306406153", err: ErrChecksum},
+		{name: "bad isbn-13 checksum", in: "9780306406158", err: ErrChecksum},
+		{name: "X in isbn-13", in: "978030640615X", err: ErrInvalidFormat},
+		{name: "X not last", in: "03064X6152", err: ErrInvalidFormat},
+		{name: "wrong prefix", in: "9770306406155", err: ErrInvalidFormat},
+		{name: "too short", in: "978030640615", err: ErrInvalidFormat},
+		{name: "too long", in: "97803064061570", err: ErrInvalidFormat},
+		{name: "empty", in: "", err: ErrInvalidFormat},
+		{name: "letters", in: "978-7-111-abcd-6", err: ErrInvalidFormat},
+		{name: "full-width digits", in: "９７８０３０６４０６１５７", err: ErrInvalidFormat},
+		{name: "isbn label", in: "ISBN 9780306406157", err: ErrInvalidFormat},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := Normalize(c.in)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("Normalize(%q) = %q, %v, want %v", c.in, got, err, c.err)
+				}
+				if Valid(c.in) {
+					t.Fatalf("Valid(%q) = true", c.in)
+				}
+				return
+			}
+			if err != nil || got != c.want {
+				t.Fatalf("Normalize(%q) = %q, %v, want %q", c.in, got, err, c.want)
+			}
+			if !Valid(c.in) {
+				t.Fatalf("Valid(%q) = false", c.in)
+			}
+		})
+	}
+}
+
+func TestTo10(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{in: "9780306406157", want: "0306406152"},
+		{in: "978-0-8044-2957-3", want: "080442957X"},
+		{in: "0306406152", want: "0306406152"},
+		{in: "9787501123452", want: "7501123454"},
+		{in: "9791032300824", err: ErrNoISBN10},
+		{in: "9780306406158", err: ErrChecksum},
+	}
+	for _, c := range cases {
+		got, err := To10(c.in)
+		if c.err != nil {
+			if !errors.Is(err, c.err) {
+				t.Errorf("To10(%q) = %q, %v, want %v", c.in, got, err, c.err)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("To10(%q) = %q, %v, want %q", c.in, got, err, c.want)
+			continue
+		}
+		// ISBN-10 转回 ISBN-13 得到原来的ISBN
+		back, err := To13(got)
+		if want, _ := Normalize(c.in); err != nil || back != want {
+			t.Errorf("To13(%q) = %q, %v, want %q", got, back, err, want)
+		}
+	}
+}
+
+func TestVariants(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{in: "0-306-40615-2", want: []string{"9780306406157", "0306406152"}},
+		{in: "9780804429573", want: []string{"9780804429573", "080442957X"}},
+		{in: "9791032300824", want: []string{"9791032300824"}},
+	}
+	for _, c := range cases {
+		got, err := Variants(c.in)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Variants(%q) = %v, %v, want %v", c.in, got, err, c.want)
+		}
+	}
+	if _, err := Variants("123"); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Variants(123) err = %v, want ErrInvalidFormat", err)
+	}
+}
@@ -0,0 +1,23 @@
+package isbn
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Tag 请求参数校验使用的标签，如 binding:"required,isbn"
+const Tag = "isbn"
+
+// RegisterValidator 在gin的校验器中注册 isbn 标签，覆盖 validator 内置的同名规则，
+// 使请求校验与存储时的规范化使用同一套规则
+func RegisterValidator() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return fmt.Errorf("unexpected validator engine %T", binding.Validator.Engine())
+	}
+	return v.RegisterValidation(Tag, func(fl validator.FieldLevel) bool {
+		return Valid(fl.Field().String())
+	})
+}
@@ -0,0 +1,78 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileMailer 将邮件保存为 .eml 文件，用于本地开发
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer 创建文件发信实现，dir为空时保存到 ./mail
+func NewFileMailer(dir, from string) *FileMailer {
+	if dir == "" {
+		dir = "./mail"
+	}
+	return &FileMailer{dir: dir, from: from}
+}
+
+// Name 发信方式名称
+func (m *FileMailer) Name() string {
+	return "file"
+}
+
+// Send 将邮件写入目录
+func (m *FileMailer) Send(msg *Message) error {
+	data, err := buildMIME(m.from, msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("create mail dir: %w", err)
+	}
+	name := fmt.Sprintf("%s_%s.eml", time.Now().Format("20060102T150405"), randomID()[:8])
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o644); err != nil {
+		return fmt.Errorf("write mail file: %w", err)
+	}
+	return nil
+}
+
+// MemoryMailer 将邮件保存在内存中，用于测试
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemoryMailer 创建内存发信实现
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Name 发信方式名称
+func (m *MemoryMailer) Name() string {
+	return "memory"
+}
+
+// Send 保存邮件
+func (m *MemoryMailer) Send(msg *Message) error {
+	if msg.To == "" {
+		return ErrNoRecipient
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, *msg)
+	return nil
+}
+
+// Messages 已发送的邮件
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
@@ -0,0 +1,142 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"time"
+)
+
+var (
+	// ErrUnknownDriver 未知的发信方式
+	ErrUnknownDriver = errors.New("unknown mail driver")
+	// ErrNoRecipient 邮件没有收件人
+	ErrNoRecipient = errors.New("mail has no recipient")
+)
+
+// Message 一封邮件，Text 和 HTML 至少有一个不为空，两者都有时以 multipart/alternative 发送
+type Message struct {
+	To      string // 收件人
+	Subject string // 主题
+	Text    string // 纯文本正文
+	HTML    string // HTML正文
+}
+
+// Mailer 发信接口，新的发信方式实现该接口并在 New 中注册
+type Mailer interface {
+	Name() string
+	Send(msg *Message) error
+}
+
+// Options 发信配置
+type Options struct {
+	Driver   string // 发信方式 smtp/file/memory
+	Host     string // SMTP服务器
+	Port     int    // SMTP端口，465使用TLS直连，其他端口在服务器支持时使用STARTTLS
+	Username string // SMTP用户名
+	Password string // SMTP密码
+	From     string // 发件人地址
+	Dir      string // file 方式保存邮件的目录
+}
+
+// New 根据配置创建发信实现
+func New(opts Options) (Mailer, error) {
+	switch opts.Driver {
+	case "smtp":
+		return NewSMTPMailer(opts), nil
+	case "", "file":
+		return NewFileMailer(opts.Dir, opts.From), nil
+	case "memory":
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, opts.Driver)
+	}
+}
+
+// MustNew 与 New 相同，但发信方式不存在时panic，用于启动阶段
+func MustNew(opts Options) Mailer {
+	m, err := New(opts)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// buildMIME 生成完整的RFC 5322邮件内容
+func buildMIME(from string, msg *Message) ([]byte, error) {
+	if msg.To == "" {
+		return nil, ErrNoRecipient
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@library>\r\n", randomID())
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	switch {
+	case msg.Text != "" && msg.HTML != "":
+		w := multipart.NewWriter(&buf)
+		fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", w.Boundary())
+		if err := writePart(w, "text/plain", msg.Text); err != nil {
+			return nil, err
+		}
+		if err := writePart(w, "text/html", msg.HTML); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	case msg.HTML != "":
+		writeBody(&buf, "text/html", msg.HTML)
+	default:
+		writeBody(&buf, "text/plain", msg.Text)
+	}
+	return buf.Bytes(), nil
+}
+
+func writePart(w *multipart.Writer, contentType, body string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + "; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(encodeBase64Lines(body))
+	return err
+}
+
+func writeBody(buf *bytes.Buffer, contentType, body string) {
+	fmt.Fprintf(buf, "Content-Type: %s; charset=UTF-8\r\n", contentType)
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	buf.Write(encodeBase64Lines(body))
+}
+
+// encodeBase64Lines base64编码并按76个字符换行
+func encodeBase64Lines(s string) []byte {
+	encoded := base64.StdEncoding.EncodeToString([]byte(s))
+	var buf bytes.Buffer
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
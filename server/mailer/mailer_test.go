@@ -0,0 +1,86 @@
+package mailer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeLang(t *testing.T) {
+	tests := []struct {
+		lang, fallback, want string
+	}{
+		{"en-US", LangZH, LangEN},
+		{" EN ", LangZH, LangEN},
+		{"zh-CN", LangEN, LangZH},
+		{"fr", LangEN, LangEN},
+		{"", LangZH, LangZH},
+	}
+	for _, tt := range tests {
+		if got := NormalizeLang(tt.lang, tt.fallback); got != tt.want {
+			t.Errorf("NormalizeLang(%q, %q) = %q, want %q", tt.lang, tt.fallback, got, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	data := struct {
+		Name          string
+		Link          string
+		ExpireMinutes int
+	}{"reader", "http://localhost/reset?token=abc", 30}
+
+	for _, lang := range []string{LangZH, LangEN} {
+		for _, name := range []string{TemplateResetPassword, TemplateVerifyEmail} {
+			msg, err := Render(name, lang, data)
+			if err != nil {
+				t.Fatalf("Render(%s, %s): %v", name, lang, err)
+			}
+			if msg.Subject == "" || !strings.Contains(msg.Text, data.Link) || !strings.Contains(msg.HTML, "reader") {
+				t.Errorf("Render(%s, %s) = %+v", name, lang, msg)
+			}
+		}
+	}
+	if _, err := Render("unknown", LangEN, data); err == nil {
+		t.Error("Render(unknown) succeeded, want error")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := NewFileMailer(dir, "library@example.com")
+	err := m.Send(&Message{To: "reader@example.com", Subject: "Hello", Text: "plain body", HTML: "<p>html body</p>"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("eml files = %v, %v, want 1", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("read eml: %v", err)
+	}
+	for _, want := range []string{"To: reader@example.com", "From: library@example.com", "multipart/alternative"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("eml missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	if err := m.Send(&Message{Subject: "no recipient"}); !errors.Is(err, ErrNoRecipient) {
+		t.Fatalf("Send without recipient err = %v, want ErrNoRecipient", err)
+	}
+	if err := m.Send(&Message{To: "reader@example.com", Subject: "Hello"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	msgs := m.Messages()
+	if len(msgs) != 1 || msgs[0].To != "reader@example.com" {
+		t.Fatalf("Messages() = %+v", msgs)
+	}
+}
@@ -0,0 +1,80 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTPMailer 通过SMTP服务器发信
+type SMTPMailer struct {
+	opts Options
+}
+
+// NewSMTPMailer 创建SMTP发信实现
+func NewSMTPMailer(opts Options) *SMTPMailer {
+	return &SMTPMailer{opts: opts}
+}
+
+// Name 发信方式名称
+func (m *SMTPMailer) Name() string {
+	return "smtp"
+}
+
+// Send 发送邮件
+func (m *SMTPMailer) Send(msg *Message) error {
+	data, err := buildMIME(m.opts.From, msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.opts.Host, strconv.Itoa(m.opts.Port))
+	var auth smtp.Auth
+	if m.opts.Username != "" {
+		auth = smtp.PlainAuth("", m.opts.Username, m.opts.Password, m.opts.Host)
+	}
+
+	// 非465端口由 SendMail 在服务器支持时自动升级为STARTTLS
+	if m.opts.Port != 465 {
+		if err := smtp.SendMail(addr, auth, m.opts.From, []string{msg.To}, data); err != nil {
+			return fmt.Errorf("smtp send to %s: %w", msg.To, err)
+		}
+		return nil
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: m.opts.Host})
+	if err != nil {
+		return fmt.Errorf("smtp dial %s: %w", addr, err)
+	}
+	c, err := smtp.NewClient(conn, m.opts.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp client %s: %w", addr, err)
+	}
+	defer c.Close()
+
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(m.opts.From); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("smtp rcpt to %s: %w", msg.To, err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp close data: %w", err)
+	}
+	return c.Quit()
+}
@@ -0,0 +1,88 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// 邮件模板名称
+const (
+	TemplateResetPassword = "reset_password" // 重置密码
+	TemplateVerifyEmail   = "verify_email"   // 验证邮箱
+)
+
+// 邮件语言
+const (
+	LangZH = "zh" // 中文
+	LangEN = "en" // 英文
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+type mailTemplate struct {
+	text *texttemplate.Template // 定义 subject 和 text 两个模板
+	html *htmltemplate.Template
+}
+
+// templates 语言 -> 模板名称 -> 模板，启动时解析，模板有误时panic
+var templates = mustParseTemplates()
+
+func mustParseTemplates() map[string]map[string]*mailTemplate {
+	result := make(map[string]map[string]*mailTemplate)
+	for _, lang := range []string{LangZH, LangEN} {
+		result[lang] = make(map[string]*mailTemplate)
+		for _, name := range []string{TemplateResetPassword, TemplateVerifyEmail} {
+			base := "templates/" + lang + "/" + name
+			text := texttemplate.Must(texttemplate.ParseFS(templateFS, base+".txt"))
+			html := htmltemplate.Must(htmltemplate.ParseFS(templateFS, base+".html"))
+			result[lang][name] = &mailTemplate{text: text, html: html}
+		}
+	}
+	return result
+}
+
+// NormalizeLang 将请求中的语言（如 en-US、zh-CN）转换为支持的邮件语言，不支持的语言使用 fallback
+func NormalizeLang(lang, fallback string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	switch {
+	case strings.HasPrefix(lang, LangEN):
+		return LangEN
+	case strings.HasPrefix(lang, LangZH):
+		return LangZH
+	default:
+		return fallback
+	}
+}
+
+// Render 用模板生成邮件的主题和正文，收件人由调用方填写。不支持的语言使用中文
+func Render(name, lang string, data interface{}) (*Message, error) {
+	byName, ok := templates[lang]
+	if !ok {
+		byName = templates[LangZH]
+	}
+	t, ok := byName[name]
+	if !ok {
+		return nil, fmt.Errorf("mail template %s not found", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("render %s subject: %w", name, err)
+	}
+	if err := t.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return nil, fmt.Errorf("render %s text: %w", name, err)
+	}
+	if err := t.html.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("render %s html: %w", name, err)
+	}
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,200 @@
+package marc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"library/isbn"
+	"library/model"
+)
+
+// 图书字段长度限制，与数据库列宽一致
+const (
+	maxTitleLength     = 128
+	maxAuthorLength    = 64
+	maxPublisherLength = 64
+	maxCategoryLength  = 32
+	maxLocationLength  = 32
+	maxCoverLength     = 256
+)
+
+var (
+	// ErrMissingISBN 记录中没有ISBN（020$a）
+	ErrMissingISBN = errors.New("missing ISBN (020$a)")
+	// ErrMissingTitle 记录中没有题名（245$a）
+	ErrMissingTitle = errors.New("missing title (245$a)")
+	// ErrMissingAuthor 记录中没有责任者（100/110/700$a 或 245$c）
+	ErrMissingAuthor = errors.New("missing author (100$a)")
+)
+
+// ToBook 按MARC21书目格式映射为图书：
+// 020$a ISBN，020$c 价格，245$a$b 题名，100/110/700$a 责任者（缺失时取245$c），
+// 264$b/260$b 出版者，084$a/082$a/650$a 分类，520$a 简介，852$c 馆藏位置，856$u 封面
+func ToBook(rec *Record) (*model.Book, error) {
+	book := &model.Book{}
+
+	if f := rec.Field("020"); f != nil {
+		book.ISBN = normalizeISBN(f.Subfield('a'))
+		book.Price = parsePrice(f.Subfield('c'))
+	}
+	if book.ISBN == "" {
+		return nil, ErrMissingISBN
+	}
+	code, err := isbn.Normalize(book.ISBN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ISBN %q: %w", book.ISBN, err)
+	}
+	book.ISBN = code
+
+	if f := rec.Field("245"); f != nil {
+		title := trimPunct(f.Subfield('a'))
+		if sub := trimPunct(f.Subfield('b')); sub != "" && title != "" {
+			title += " : " + sub
+		}
+		book.Title = title
+		// 副题名过长时只保留正题名
+		if utf8.RuneCountInString(book.Title) > maxTitleLength {
+			book.Title = trimPunct(f.Subfield('a'))
+		}
+	}
+	if book.Title == "" {
+		return nil, ErrMissingTitle
+	}
+
+	for _, tag := range []string{"100", "110", "700"} {
+		if f := rec.Field(tag); f != nil {
+			if book.Author = trimPunct(f.Subfield('a')); book.Author != "" {
+				break
+			}
+		}
+	}
+	if book.Author == "" {
+		if f := rec.Field("245"); f != nil {
+			book.Author = trimPunct(f.Subfield('c'))
+		}
+	}
+	if book.Author == "" {
+		return nil, ErrMissingAuthor
+	}
+
+	// RDA 记录使用 264 第二指示符 1 表示出版，旧记录使用 260
+	for _, f := range rec.FieldsByTag("264") {
+		if f.Ind2 == '1' {
+			book.Publisher = trimPunct(f.Subfield('b'))
+			break
+		}
+	}
+	if book.Publisher == "" {
+		if f := rec.Field("260"); f != nil {
+			book.Publisher = trimPunct(f.Subfield('b'))
+		}
+	}
+
+	for _, tag := range []string{"084", "082", "650"} {
+		if f := rec.Field(tag); f != nil {
+			if book.Category = trimPunct(f.Subfield('a')); book.Category != "" {
+				break
+			}
+		}
+	}
+
+	var summary []string
+	for _, f := range rec.FieldsByTag("520") {
+		if s := strings.TrimSpace(f.Subfield('a')); s != "" {
+			summary = append(summary, s)
+		}
+	}
+	book.Summary = strings.Join(summary, "\n")
+
+	if f := rec.Field("852"); f != nil {
+		book.Location = strings.TrimSpace(f.Subfield('c'))
+	}
+	for _, f := range rec.FieldsByTag("856") {
+		if u := strings.TrimSpace(f.Subfield('u')); strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+			book.Cover = u
+			break
+		}
+	}
+
+	if err := checkLength(book); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// FromBook 将图书映射为MARC21书目记录，字段对应关系与 ToBook 相同，001 为图书ID
+func FromBook(book *model.Book) *Record {
+	rec := &Record{Leader: defaultLeader}
+	rec.AddControl("001", strconv.FormatUint(uint64(book.ID), 10))
+	if !book.UpdatedAt.IsZero() {
+		rec.AddControl("005", book.UpdatedAt.Format("20060102150405")+".0")
+	}
+	price := ""
+	if book.Price > 0 {
+		price = strconv.FormatFloat(book.Price, 'f', 2, 64)
+	}
+	rec.AddData("020", ' ', ' ', "a", book.ISBN, "c", price)
+	rec.AddData("084", ' ', ' ', "a", book.Category)
+	rec.AddData("100", '1', ' ', "a", book.Author)
+	rec.AddData("245", '1', '0', "a", book.Title)
+	rec.AddData("264", ' ', '1', "b", book.Publisher)
+	rec.AddData("520", ' ', ' ', "a", book.Summary)
+	rec.AddData("852", ' ', ' ', "c", book.Location)
+	rec.AddData("856", '4', ' ', "u", book.Cover)
+	return rec
+}
+
+// normalizeISBN 020$a 常带有限定说明，如 "9787111111115 (pbk.)"，只取号码部分并去掉连字符
+func normalizeISBN(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, " (:;"); i >= 0 {
+		s = s[:i]
+	}
+	return isbn.Clean(s)
+}
+
+// parsePrice 从 "CNY25.00"、"$12.99" 等形式中取出金额，无法识别时返回0
+func parsePrice(s string) float64 {
+	start := strings.IndexAny(s, "0123456789")
+	if start < 0 {
+		return 0
+	}
+	end := start
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.') {
+		end++
+	}
+	price, err := strconv.ParseFloat(s[start:end], 64)
+	if err != nil {
+		return 0
+	}
+	return price
+}
+
+// trimPunct 去掉编目时加在子字段末尾的ISBD标点
+func trimPunct(s string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), " /:;,=."))
+}
+
+func checkLength(book *model.Book) error {
+	limits := []struct {
+		name  string
+		value string
+		max   int
+	}{
+		{"title", book.Title, maxTitleLength},
+		{"author", book.Author, maxAuthorLength},
+		{"publisher", book.Publisher, maxPublisherLength},
+		{"category", book.Category, maxCategoryLength},
+		{"location", book.Location, maxLocationLength},
+		{"cover", book.Cover, maxCoverLength},
+	}
+	for _, l := range limits {
+		if utf8.RuneCountInString(l.value) > l.max {
+			return fmt.Errorf("%s exceeds %d characters", l.name, l.max)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package marc
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"library/isbn"
+	"library/model"
+)
+
+func TestToBook(t *testing.T) {
+	full := testRecord("1")
+	full.AddData("084", ' ', ' ', "a", "TP312")
+	full.AddData("852", ' ', ' ', "c", "A区3架")
+	full.AddData("856", '4', '0', "u", "ftp://example.com/cover.jpg")
+	full.AddData("856", '4', '0', "u", "https://example.com/cover.jpg")
+	book, err := ToBook(full)
+	if err != nil {
+		t.Fatalf("ToBook: %v", err)
+	}
+	want := &model.Book{
+		ISBN:      "9787111213826",
+		Title:     "Go语言编程 : 入门与实践",
+		Author:    "张三",
+		Publisher: "机械工业出版社",
+		Category:  "TP312",
+		Price:     45,
+		Location:  "A区3架",
+		Cover:     "https://example.com/cover.jpg",
+		Summary:   "第一段简介\n第二段简介 & <更多>",
+	}
+	if !reflect.DeepEqual(book, want) {
+		t.Fatalf("ToBook =\n%+v\nwant\n%+v", book, want)
+	}
+
+	cases := []struct {
+		name  string
+		build func(rec *Record)
+		check func(book *model.Book) bool
+	}{
+		{"isbn-10 with hyphens", func(rec *Record) {
+			rec.AddData("020", ' ', ' ', "a", "0-306-40615-2 : $12.99")
+		}, func(b *model.Book) bool { return b.ISBN == "9780306406157" }},
+		{"price with currency symbol", func(rec *Record) {
+			rec.AddData("020", ' ', ' ', "a", "9780306406157", "c", "$12.99")
+		}, func(b *model.Book) bool { return b.Price == 12.99 }},
+		{"price without digits", func(rec *Record) {
+			rec.AddData("020", ' ', ' ', "a", "9780306406157", "c", "赠送")
+		}, func(b *model.Book) bool { return b.Price == 0 }},
+		{"author from 700", func(rec *Record) {
+			rec.AddData("020", ' ', ' ', "a", "9780306406157")
+			rec.AddData("700", '1', ' ', "a", "李四,")
+		}, func(b *model.Book) bool { return b.Author == "李四" }},
+		{"author from statement of responsibility", func(rec *Record) {
+			rec.AddData("020", ' ', ' ', "a", "9780306406157")
+			rec.Fields = append(rec.Fields, &Field{Tag: "245", Subfields: []Subfield{{'a', "Title /"}, {'c', "王五编."}}})
+		}, func(b *model.Book) bool { return b.Author == "王五编" }},
+		{"publisher from 260 when 264 is not publication", func(rec *Record) {
+			rec.AddData("020", ' ', ' ', "a", "9780306406157")
+			rec.AddData("264", ' ', '4', "b", "Copyright holder")
+			rec.AddData("260", ' ', ' ', "b", "Old Press,")
+		}, func(b *model.Book) bool { return b.Publisher == "Old Press" }},
+		{"category from 650", func(rec *Record) {
+			rec.AddData("020", ' ', ' ', "a", "9780306406157")
+			rec.AddData("650", ' ', '0', "a", "Programming languages.")
+		}, func(b *model.Book) bool { return b.Category == "Programming languages" }},
+		{"long subtitle dropped", func(rec *Record) {
+			rec.AddData("020", ' ', ' ', "a", "9780306406157")
+			rec.Fields = append(rec.Fields, &Field{Tag: "245", Subfields: []Subfield{{'a', "Title :"}, {'b', strings.Repeat("长", maxTitleLength)}}})
+		}, func(b *model.Book) bool { return b.Title == "Title" }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rec := &Record{}
+			c.build(rec)
+			if rec.Field("245") == nil {
+				rec.AddData("245", '1', '0', "a", "Title")
+			}
+			if rec.Field("100") == nil && rec.Field("700") == nil && rec.Field("245").Subfield('c') == "" {
+				rec.AddData("100", '1', ' ', "a", "Author")
+			}
+			book, err := ToBook(rec)
+			if err != nil {
+				t.Fatalf("ToBook: %v", err)
+			}
+			if !c.check(book) {
+				t.Fatalf("ToBook = %+v", book)
+			}
+		})
+	}
+}
+
+func TestToBookErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		build func(rec *Record)
+		err   error
+		msg   string
+	}{
+		{"missing isbn", func(rec *Record) {
+			rec.AddData("245", '1', '0', "a", "Title")
+		}, ErrMissingISBN, ""},
+		{"bad isbn checksum", func(rec *Record) {
+			rec.AddData("020", ' ', ' ', "a", "9780306406158")
+		}, isbn.ErrChecksum, "invalid ISBN"},
+		{"missing title", func(rec *Record) {
+			rec.AddData("020", ' ', ' ', "a", "9780306406157")
+			rec.AddData("100", '1', ' ', "a", "Author")
+		}, ErrMissingTitle, ""},
+		{"missing author", func(rec *Record) {
+			rec.AddData("020", ' ', ' ', "a", "9780306406157")
+			rec.AddData("245", '1', '0', "a", "Title")
+		}, ErrMissingAuthor, ""},
+		{"author too long", func(rec *Record) {
+			rec.AddData("020", ' ', ' ', "a", "9780306406157")
+			rec.AddData("100", '1', ' ', "a", strings.Repeat("作", maxAuthorLength+1))
+			rec.AddData("245", '1', '0', "a", "Title")
+		}, nil, "author exceeds 64 characters"},
+		{"location too long", func(rec *Record) {
+			rec.AddData("020", ' ', ' ', "a", "9780306406157")
+			rec.AddData("100", '1', ' ', "a", "Author")
+			rec.AddData("245", '1', '0', "a", "Title")
+			rec.AddData("852", ' ', ' ', "c", strings.Repeat("x", maxLocationLength+1))
+		}, nil, "location exceeds 32 characters"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rec := &Record{}
+			c.build(rec)
+			book, err := ToBook(rec)
+			if err == nil || book != nil {
+				t.Fatalf("ToBook = %+v, %v, want error", book, err)
+			}
+			if c.err != nil && !errors.Is(err, c.err) {
+				t.Fatalf("err = %v, want %v", err, c.err)
+			}
+			if !strings.Contains(err.Error(), c.msg) {
+				t.Fatalf("err = %v, want %q", err, c.msg)
+			}
+		})
+	}
+}
+
+// TestFromBook 导出的记录导入后得到相同的图书
+func TestFromBook(t *testing.T) {
+	book := &model.Book{
+		ID:        42,
+		ISBN:      "9787111213826",
+		Title:     "Go语言编程",
+		Author:    "张三",
+		Publisher: "机械工业出版社",
+		Category:  "TP312",
+		Price:     45.5,
+		Location:  "A区3架",
+		Cover:     "https://example.com/cover.jpg",
+		Summary:   "简介",
+	}
+	rec := FromBook(book)
+	if f := rec.Field("001"); f == nil || f.Value != "42" {
+		t.Fatalf("001 = %+v", f)
+	}
+	if rec.Field("005") != nil {
+		t.Fatalf("005 written for a book without an update time")
+	}
+	if got := rec.Field("020").Subfield('c'); got != "45.50" {
+		t.Fatalf("020$c = %q", got)
+	}
+
+	for _, format := range []string{FormatISO2709, FormatXML} {
+		entries, err := Decode(encode(t, []*Record{rec}, format), format)
+		if err != nil || len(entries) != 1 || entries[0].Err != nil {
+			t.Fatalf("Decode %s = %+v, %v", format, entries, err)
+		}
+		got, err := ToBook(entries[0].Record)
+		if err != nil {
+			t.Fatalf("ToBook %s: %v", format, err)
+		}
+		want := *book
+		want.ID = 0
+		if !reflect.DeepEqual(got, &want) {
+			t.Errorf("%s round trip =\n%+v\nwant\n%+v", format, got, &want)
+		}
+	}
+
+	// 空字段不输出
+	rec = FromBook(&model.Book{ISBN: "9787111213826", Title: "Title", Author: "Author"})
+	for _, tag := range []string{"084", "264", "520", "852", "856"} {
+		if rec.Field(tag) != nil {
+			t.Errorf("field %s written for an empty value", tag)
+		}
+	}
+	if f := rec.Field("020"); len(f.Subfields) != 1 {
+		t.Errorf("020 = %+v, want only $a", f)
+	}
+}
@@ -0,0 +1,165 @@
+package marc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ISO 2709 分隔符及头标、目录的定长格式
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+
+	leaderLength     = 24
+	directoryEntry   = 12
+	maxRecordLength  = 99999
+	maxFieldLength   = 9999
+	defaultLeader    = "00000nam a2200000 i 4500"
+	leaderTailFormat = "4500"
+)
+
+// decodeISO2709 按记录结束符切分后逐条解析
+func decodeISO2709(data []byte) []Entry {
+	var entries []Entry
+	for _, chunk := range bytes.Split(data, []byte{recordTerminator}) {
+		// 部分系统导出时在记录之间加换行
+		chunk = bytes.TrimLeft(chunk, "\r\n")
+		if len(bytes.TrimSpace(chunk)) == 0 {
+			continue
+		}
+		rec, err := parseISO2709(chunk)
+		entries = append(entries, Entry{Record: rec, Err: err})
+	}
+	return entries
+}
+
+// parseISO2709 解析一条不含记录结束符的记录
+func parseISO2709(data []byte) (*Record, error) {
+	if len(data) < leaderLength {
+		return nil, fmt.Errorf("record too short: %d bytes", len(data))
+	}
+	leader := string(data[:leaderLength])
+	if !utf8.Valid(data) {
+		if leader[9] != 'a' {
+			return nil, fmt.Errorf("unsupported character coding %q, only UTF-8 (leader/09 = a) is supported", leader[9])
+		}
+		return nil, fmt.Errorf("record is not valid UTF-8")
+	}
+	base, err := strconv.Atoi(leader[12:17])
+	if err != nil || base <= leaderLength || base > len(data) {
+		return nil, fmt.Errorf("invalid base address %q", leader[12:17])
+	}
+
+	dir := data[leaderLength : base-1]
+	if data[base-1] != fieldTerminator || len(dir)%directoryEntry != 0 {
+		return nil, fmt.Errorf("invalid directory")
+	}
+	body := data[base:]
+
+	rec := &Record{Leader: leader}
+	for i := 0; i < len(dir); i += directoryEntry {
+		entry := dir[i : i+directoryEntry]
+		tag := string(entry[:3])
+		if err := validTag(tag); err != nil {
+			return nil, err
+		}
+		length, err1 := strconv.Atoi(string(entry[3:7]))
+		start, err2 := strconv.Atoi(string(entry[7:12]))
+		if err1 != nil || err2 != nil || length < 1 || start+length > len(body) {
+			return nil, fmt.Errorf("invalid directory entry for field %s", tag)
+		}
+		raw := body[start : start+length]
+		if raw[len(raw)-1] != fieldTerminator {
+			return nil, fmt.Errorf("field %s is not terminated", tag)
+		}
+		raw = raw[:len(raw)-1]
+
+		field := &Field{Tag: tag}
+		if field.IsControl() {
+			field.Value = string(raw)
+			rec.Fields = append(rec.Fields, field)
+			continue
+		}
+		if len(raw) < 2 {
+			return nil, fmt.Errorf("field %s has no indicators", tag)
+		}
+		field.Ind1, field.Ind2 = raw[0], raw[1]
+		for _, part := range bytes.Split(raw[2:], []byte{subfieldDelimiter}) {
+			if len(part) == 0 {
+				continue
+			}
+			field.Subfields = append(field.Subfields, Subfield{Code: part[0], Value: string(part[1:])})
+		}
+		rec.Fields = append(rec.Fields, field)
+	}
+	return rec, nil
+}
+
+// encodeISO2709 逐条输出记录，记录长度、基地址和目录按内容重新计算
+func encodeISO2709(w io.Writer, records []*Record) error {
+	for _, rec := range records {
+		data, err := marshalISO2709(rec)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func marshalISO2709(rec *Record) ([]byte, error) {
+	var dir, body bytes.Buffer
+	for _, f := range rec.Fields {
+		if err := validTag(f.Tag); err != nil {
+			return nil, err
+		}
+		start := body.Len()
+		if f.IsControl() {
+			body.WriteString(f.Value)
+		} else {
+			body.WriteByte(indicator(f.Ind1))
+			body.WriteByte(indicator(f.Ind2))
+			for _, sf := range f.Subfields {
+				body.WriteByte(subfieldDelimiter)
+				body.WriteByte(sf.Code)
+				body.WriteString(sf.Value)
+			}
+		}
+		body.WriteByte(fieldTerminator)
+		length := body.Len() - start
+		if length > maxFieldLength {
+			return nil, fmt.Errorf("field %s exceeds %d bytes", f.Tag, maxFieldLength)
+		}
+		fmt.Fprintf(&dir, "%s%04d%05d", f.Tag, length, start)
+	}
+	dir.WriteByte(fieldTerminator)
+	body.WriteByte(recordTerminator)
+
+	base := leaderLength + dir.Len()
+	total := base + body.Len()
+	if total > maxRecordLength {
+		return nil, fmt.Errorf("record exceeds %d bytes", maxRecordLength)
+	}
+
+	leader := []byte(rec.Leader)
+	if len(leader) != leaderLength {
+		leader = []byte(defaultLeader)
+	}
+	copy(leader[0:5], fmt.Sprintf("%05d", total))
+	leader[9] = 'a' // UTF-8
+	copy(leader[10:12], "22")
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+	copy(leader[20:24], leaderTailFormat)
+
+	out := make([]byte, 0, total)
+	out = append(out, leader...)
+	out = append(out, dir.Bytes()...)
+	out = append(out, body.Bytes()...)
+	return out, nil
+}
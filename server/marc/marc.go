@@ -0,0 +1,180 @@
+// Package marc 实现MARC21书目记录的ISO 2709和MARCXML编解码，以及与 model.Book 的字段映射
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 支持的格式
+const (
+	FormatISO2709 = "iso2709" // MARC21交换格式（.mrc）
+	FormatXML     = "marcxml" // MARCXML（.xml）
+)
+
+var (
+	// ErrUnknownFormat 未知的MARC格式
+	ErrUnknownFormat = errors.New("unknown MARC format")
+	// ErrEmpty 没有可解析的记录
+	ErrEmpty = errors.New("no MARC records found")
+)
+
+// Subfield 子字段
+type Subfield struct {
+	Code  byte
+	Value string
+}
+
+// Field 字段。控制字段（00X）只有 Value，数据字段有指示符和子字段
+type Field struct {
+	Tag       string
+	Ind1      byte
+	Ind2      byte
+	Value     string
+	Subfields []Subfield
+}
+
+// IsControl 是否为控制字段
+func (f *Field) IsControl() bool {
+	return strings.HasPrefix(f.Tag, "00")
+}
+
+// Subfield 返回第一个指定代码的子字段值
+func (f *Field) Subfield(code byte) string {
+	for _, sf := range f.Subfields {
+		if sf.Code == code {
+			return sf.Value
+		}
+	}
+	return ""
+}
+
+// Record 一条MARC记录
+type Record struct {
+	Leader string
+	Fields []*Field
+}
+
+// Field 返回第一个指定标签的字段，不存在时返回nil
+func (r *Record) Field(tag string) *Field {
+	for _, f := range r.Fields {
+		if f.Tag == tag {
+			return f
+		}
+	}
+	return nil
+}
+
+// FieldsByTag 返回所有指定标签的字段
+func (r *Record) FieldsByTag(tag string) []*Field {
+	var fields []*Field
+	for _, f := range r.Fields {
+		if f.Tag == tag {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// AddControl 追加控制字段
+func (r *Record) AddControl(tag, value string) {
+	r.Fields = append(r.Fields, &Field{Tag: tag, Value: value})
+}
+
+// AddData 追加数据字段，code和value成对出现，值为空的子字段忽略，全部为空时不追加
+func (r *Record) AddData(tag string, ind1, ind2 byte, pairs ...string) {
+	f := &Field{Tag: tag, Ind1: ind1, Ind2: ind2}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" || pairs[i] == "" {
+			continue
+		}
+		f.Subfields = append(f.Subfields, Subfield{Code: pairs[i][0], Value: pairs[i+1]})
+	}
+	if len(f.Subfields) > 0 {
+		r.Fields = append(r.Fields, f)
+	}
+}
+
+// Entry 解析结果，记录格式错误时 Err 非空，Record 为nil
+type Entry struct {
+	Record *Record
+	Err    error
+}
+
+// DetectFormat 根据内容识别格式，以 < 开头的视为MARCXML
+func DetectFormat(data []byte) string {
+	trimmed := bytes.TrimLeft(data, " \t\r\n\ufeff")
+	if len(trimmed) > 0 && trimmed[0] == '<' {
+		return FormatXML
+	}
+	return FormatISO2709
+}
+
+// Decode 解析MARC数据，format为空时自动识别。单条记录格式错误不影响其他记录，按顺序返回每条记录的结果
+func Decode(data []byte, format string) ([]Entry, error) {
+	if format == "" {
+		format = DetectFormat(data)
+	}
+	var entries []Entry
+	var err error
+	switch format {
+	case FormatISO2709:
+		entries = decodeISO2709(data)
+	case FormatXML:
+		entries, err = decodeXML(data)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrEmpty
+	}
+	return entries, nil
+}
+
+// Encode 按格式输出记录
+func Encode(w io.Writer, records []*Record, format string) error {
+	switch format {
+	case FormatISO2709:
+		return encodeISO2709(w, records)
+	case FormatXML:
+		return encodeXML(w, records)
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+// ContentType 格式对应的MIME类型和文件扩展名
+func ContentType(format string) (string, string) {
+	if format == FormatXML {
+		return "application/marcxml+xml", ".xml"
+	}
+	return "application/marc", ".mrc"
+}
+
+// validTag 标签为3位字母数字
+func validTag(tag string) error {
+	if len(tag) != 3 {
+		return fmt.Errorf("invalid tag %q", tag)
+	}
+	for i := 0; i < 3; i++ {
+		c := tag[i]
+		if !(c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z') {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+	return nil
+}
+
+// indicator 空指示符用空格表示
+func indicator(b byte) byte {
+	if b == 0 {
+		return ' '
+	}
+	return b
+}
@@ -0,0 +1,202 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testRecord 一条包含控制字段、多值字段和中文内容的记录
+func testRecord(id string) *Record {
+	rec := &Record{Leader: defaultLeader}
+	rec.AddControl("001", id)
+	rec.AddControl("005", "20240101120000.0")
+	rec.AddData("020", ' ', ' ', "a", "9787111213826 (pbk.)", "c", "CNY45.00")
+	rec.AddData("100", '1', ' ', "a", "张三,")
+	rec.AddData("245", '1', '0', "a", "Go语言编程 :", "b", "入门与实践 /", "c", "张三著.")
+	rec.AddData("264", ' ', '1', "b", "机械工业出版社,")
+	rec.AddData("520", ' ', ' ', "a", "第一段简介")
+	rec.AddData("520", ' ', ' ', "a", "第二段简介 & <更多>")
+	return rec
+}
+
+func encode(t *testing.T, records []*Record, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encode(&buf, records, format); err != nil {
+		t.Fatalf("Encode %s: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	records := []*Record{testRecord("1"), testRecord("2")}
+	for _, format := range []string{FormatISO2709, FormatXML} {
+		t.Run(format, func(t *testing.T) {
+			data := encode(t, records, format)
+			if got := DetectFormat(data); got != format {
+				t.Fatalf("DetectFormat = %q, want %q", got, format)
+			}
+			entries, err := Decode(data, "")
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if len(entries) != len(records) {
+				t.Fatalf("decoded %d records, want %d", len(entries), len(records))
+			}
+			for i, e := range entries {
+				if e.Err != nil {
+					t.Fatalf("record %d: %v", i, e.Err)
+				}
+				if len(e.Record.Leader) != leaderLength || e.Record.Leader[9] != 'a' {
+					t.Errorf("record %d leader = %q", i, e.Record.Leader)
+				}
+				if !reflect.DeepEqual(e.Record.Fields, records[i].Fields) {
+					t.Errorf("record %d fields differ:\n got %s\nwant %s", i, dump(e.Record), dump(records[i]))
+				}
+			}
+		})
+	}
+}
+
+// TestISO2709Leader 头标中的记录长度和基地址按内容计算
+func TestISO2709Leader(t *testing.T) {
+	rec := testRecord("1")
+	rec.Leader = "short"
+	data := encode(t, []*Record{rec}, FormatISO2709)
+
+	if data[len(data)-1] != recordTerminator {
+		t.Fatalf("record does not end with the record terminator")
+	}
+	if n, _ := strconv.Atoi(string(data[0:5])); n != len(data) {
+		t.Fatalf("leader record length = %q, want %d", data[0:5], len(data))
+	}
+	// 目录每个字段12字节，以字段结束符结尾
+	base := leaderLength + len(rec.Fields)*directoryEntry + 1
+	if b, _ := strconv.Atoi(string(data[12:17])); b != base || data[base-1] != fieldTerminator {
+		t.Fatalf("leader base address = %q, want %d", data[12:17], base)
+	}
+	if got := string(data[20:24]); got != leaderTailFormat {
+		t.Fatalf("leader entry map = %q", got)
+	}
+}
+
+func TestDecodeISO2709Errors(t *testing.T) {
+	valid := encode(t, []*Record{testRecord("1")}, FormatISO2709)
+	valid = valid[:len(valid)-1] // 不含记录结束符
+	base, _ := strconv.Atoi(string(valid[12:17]))
+	firstLength, _ := strconv.Atoi(string(valid[27:31]))
+
+	cases := []struct {
+		name    string
+		corrupt func(b []byte) []byte
+		want    string
+	}{
+		{"too short", func(b []byte) []byte { return b[:20] }, "record too short"},
+		{"base address not a number", func(b []byte) []byte { copy(b[12:17], "00ab0"); return b }, "invalid base address"},
+		{"base address past the end", func(b []byte) []byte { copy(b[12:17], "99999"); return b }, "invalid base address"},
+		{"directory not terminated", func(b []byte) []byte { b[base-1] = '0'; return b }, "invalid directory"},
+		{"directory entry truncated", func(b []byte) []byte {
+			return append(append(append([]byte{}, b[:base-1]...), '0'), b[base-1:]...)
+		}, "invalid"},
+		{"field length past the end", func(b []byte) []byte { copy(b[27:31], "9999"); return b }, "invalid directory entry for field 001"},
+		{"invalid tag", func(b []byte) []byte { copy(b[24:27], "0#1"); return b }, "invalid tag"},
+		{"field not terminated", func(b []byte) []byte { b[base+firstLength-1] = 'x'; return b }, "field 001 is not terminated"},
+		{"not utf-8", func(b []byte) []byte { b[9] = ' '; b[base] = 0xff; return b }, "unsupported character coding"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// 损坏的记录夹在两条正常记录之间，不影响其他记录
+			bad := c.corrupt(append([]byte{}, valid...))
+			var data []byte
+			for _, rec := range [][]byte{valid, bad, valid} {
+				data = append(append(data, rec...), recordTerminator, '\n')
+			}
+			entries, err := Decode(data, FormatISO2709)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if len(entries) != 3 {
+				t.Fatalf("decoded %d records, want 3", len(entries))
+			}
+			if entries[0].Err != nil || entries[2].Err != nil {
+				t.Fatalf("valid records failed: %v, %v", entries[0].Err, entries[2].Err)
+			}
+			if entries[1].Err == nil || !strings.Contains(entries[1].Err.Error(), c.want) {
+				t.Fatalf("corrupt record err = %v, want %q", entries[1].Err, c.want)
+			}
+			if entries[1].Record != nil {
+				t.Fatalf("corrupt record returned %+v", entries[1].Record)
+			}
+		})
+	}
+}
+
+func TestDecodeXMLErrors(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<collection xmlns="http://www.loc.gov/MARC21/slim">
+  <record><leader>00000nam a2200000 i 4500</leader><controlfield tag="001">1</controlfield></record>
+  <record><controlfield tag="0011">2</controlfield></record>
+  <record><datafield tag="245" ind1="1" ind2="0"><subfield code="ab">Title</subfield></datafield></record>
+  <record><datafield tag="245"><subfield code="a">Title</subfield></datafield></record>
+</collection>`
+	entries, err := Decode([]byte("\ufeff\n"+doc), "")
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("decoded %d records, want 4", len(entries))
+	}
+	if entries[0].Err != nil || entries[0].Record.Field("001").Value != "1" {
+		t.Errorf("record 1 = %+v, %v", entries[0].Record, entries[0].Err)
+	}
+	if err := entries[1].Err; err == nil || !strings.Contains(err.Error(), "invalid tag") {
+		t.Errorf("record 2 err = %v, want invalid tag", err)
+	}
+	if err := entries[2].Err; err == nil || !strings.Contains(err.Error(), "invalid subfield code") {
+		t.Errorf("record 3 err = %v, want invalid subfield code", err)
+	}
+	// 缺少的指示符视为空格
+	if f := entries[3].Record.Field("245"); entries[3].Err != nil || f.Ind1 != ' ' || f.Ind2 != ' ' || f.Subfield('a') != "Title" {
+		t.Errorf("record 4 = %+v, %v", f, entries[3].Err)
+	}
+
+	if _, err := Decode([]byte(`<collection><record><leader>x</record>`), FormatXML); err == nil {
+		t.Errorf("Decode of malformed XML succeeded")
+	}
+	if _, err := Decode([]byte(`<collection xmlns="http://www.loc.gov/MARC21/slim"></collection>`), ""); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Decode of an empty collection err = %v, want ErrEmpty", err)
+	}
+}
+
+func TestDecodeFormat(t *testing.T) {
+	if _, err := Decode([]byte("data"), "json"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Decode(json) err = %v, want ErrUnknownFormat", err)
+	}
+	if err := Encode(&bytes.Buffer{}, nil, "json"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Encode(json) err = %v, want ErrUnknownFormat", err)
+	}
+	if _, err := Decode([]byte("\r\n \n"), FormatISO2709); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Decode of blank data err = %v, want ErrEmpty", err)
+	}
+	if err := Encode(&bytes.Buffer{}, []*Record{{Fields: []*Field{{Tag: "24"}}}}, FormatISO2709); err == nil {
+		t.Errorf("Encode with an invalid tag succeeded")
+	}
+	long := &Record{}
+	long.AddData("520", ' ', ' ', "a", strings.Repeat("x", maxFieldLength))
+	if err := Encode(&bytes.Buffer{}, []*Record{long}, FormatISO2709); err == nil {
+		t.Errorf("Encode of a field longer than %d bytes succeeded", maxFieldLength)
+	}
+}
+
+func dump(rec *Record) string {
+	var b strings.Builder
+	for _, f := range rec.Fields {
+		fmt.Fprintf(&b, "[%s %q%c%c %v] ", f.Tag, f.Value, f.Ind1, f.Ind2, f.Subfields)
+	}
+	return b.String()
+}
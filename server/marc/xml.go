@@ -0,0 +1,138 @@
+package marc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// xmlNamespace MARCXML 命名空间
+const xmlNamespace = "http://www.loc.gov/MARC21/slim"
+
+type xmlCollection struct {
+	XMLName xml.Name     `xml:"collection"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Records []*xmlRecord `xml:"record"`
+}
+
+type xmlRecord struct {
+	XMLName       xml.Name          `xml:"record"`
+	Leader        string            `xml:"leader"`
+	ControlFields []xmlControlField `xml:"controlfield"`
+	DataFields    []xmlDataField    `xml:"datafield"`
+}
+
+type xmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	Subfields []xmlSubfield `xml:"subfield"`
+}
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// decodeXML 流式读取所有 record 元素，单条记录内容错误时继续解析后续记录，XML本身不合法时返回错误
+func decodeXML(data []byte) ([]Entry, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var entries []Entry
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse MARCXML: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+		var xr xmlRecord
+		if err := dec.DecodeElement(&xr, &start); err != nil {
+			return nil, fmt.Errorf("parse MARCXML: %w", err)
+		}
+		rec, err := fromXMLRecord(&xr)
+		entries = append(entries, Entry{Record: rec, Err: err})
+	}
+	return entries, nil
+}
+
+// fromXMLRecord MARCXML中控制字段和数据字段分开存放，按标签顺序合并
+func fromXMLRecord(xr *xmlRecord) (*Record, error) {
+	rec := &Record{Leader: strings.TrimSpace(xr.Leader)}
+	for _, cf := range xr.ControlFields {
+		if err := validTag(cf.Tag); err != nil {
+			return nil, err
+		}
+		rec.Fields = append(rec.Fields, &Field{Tag: cf.Tag, Value: cf.Value})
+	}
+	for _, df := range xr.DataFields {
+		if err := validTag(df.Tag); err != nil {
+			return nil, err
+		}
+		field := &Field{Tag: df.Tag, Ind1: xmlIndicator(df.Ind1), Ind2: xmlIndicator(df.Ind2)}
+		for _, sf := range df.Subfields {
+			if len(sf.Code) != 1 {
+				return nil, fmt.Errorf("field %s has invalid subfield code %q", df.Tag, sf.Code)
+			}
+			field.Subfields = append(field.Subfields, Subfield{Code: sf.Code[0], Value: sf.Value})
+		}
+		rec.Fields = append(rec.Fields, field)
+	}
+	return rec, nil
+}
+
+func xmlIndicator(s string) byte {
+	if s == "" {
+		return ' '
+	}
+	return s[0]
+}
+
+// encodeXML 输出 collection 文档
+func encodeXML(w io.Writer, records []*Record) error {
+	coll := xmlCollection{Xmlns: xmlNamespace}
+	for _, rec := range records {
+		xr := &xmlRecord{Leader: rec.Leader}
+		if len(xr.Leader) != leaderLength {
+			xr.Leader = defaultLeader
+		}
+		for _, f := range rec.Fields {
+			if err := validTag(f.Tag); err != nil {
+				return err
+			}
+			if f.IsControl() {
+				xr.ControlFields = append(xr.ControlFields, xmlControlField{Tag: f.Tag, Value: f.Value})
+				continue
+			}
+			df := xmlDataField{Tag: f.Tag, Ind1: string(indicator(f.Ind1)), Ind2: string(indicator(f.Ind2))}
+			for _, sf := range f.Subfields {
+				df.Subfields = append(df.Subfields, xmlSubfield{Code: string(sf.Code), Value: sf.Value})
+			}
+			xr.DataFields = append(xr.DataFields, df)
+		}
+		coll.Records = append(coll.Records, xr)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(coll); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
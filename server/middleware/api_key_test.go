@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+
+	"library/model"
+)
+
+// stubAPIKeys 按密钥明文返回密钥和所属用户
+type stubAPIKeys map[string]*model.APIKey
+
+func (s stubAPIKeys) AuthenticateAPIKey(raw, ip string) (*model.APIKey, *model.User, error) {
+	key, ok := s[raw]
+	if !ok {
+		return nil, nil, nil
+	}
+	return key, &model.User{ID: key.UserID, Username: "kiosk", Role: model.RoleLibrarian}, nil
+}
+
+// stubPermissions 角色拥有的权限
+type stubPermissions map[string][]string
+
+func (s stubPermissions) HasPermission(role, permission string) (bool, error) {
+	return hasScope(s[role], permission), nil
+}
+
+// newAPIKeyTestRouter 默认每个密钥每秒100次请求，突发100次
+func newAPIKeyTestRouter(keys stubAPIKeys) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	limiter := NewIPRateLimiter(rate.Limit(100), 100, time.Minute)
+	r.Use(APIKeyMiddleware(keys, limiter, rate.Limit(100), 100))
+	r.Use(PermissionMiddleware(stubPermissions{
+		model.RoleLibrarian: {model.PermCopyRead, model.PermBorrowCheckout, model.PermFineRead},
+	}))
+
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	api := r.Group("/", AuthMiddleware())
+	api.GET("/copies", RequirePermission(model.PermCopyRead), ok)
+	api.GET("/fines", RequirePermission(model.PermFineRead), ok)
+	api.GET("/fines/manage", RequirePermission(model.PermFineManage), ok)
+	api.GET("/profile", RequireSession(), ok)
+	return r
+}
+
+func doAPIKeyRequest(r *gin.Engine, path, header, value string) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set(header, value)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAPIKeyScopeEnforcement(t *testing.T) {
+	r := newAPIKeyTestRouter(stubAPIKeys{
+		"lib_00000001_secret": {ID: 1, UserID: 7, Scopes: []string{model.PermCopyRead, model.PermFineManage}},
+	})
+
+	cases := []struct {
+		name   string
+		path   string
+		header string
+		value  string
+		want   int
+	}{
+		{name: "scope granted by role", path: "/copies", header: APIKeyHeader, value: "lib_00000001_secret", want: http.StatusOK},
+		{name: "bearer api key", path: "/copies", header: "Authorization", value: "Bearer lib_00000001_secret", want: http.StatusOK},
+		// 角色有该权限，但密钥没有该作用域
+		{name: "role permission outside scopes", path: "/fines", header: APIKeyHeader, value: "lib_00000001_secret", want: http.StatusForbidden},
+		// 密钥有该作用域，但所属用户的角色没有该权限
+		{name: "scope outside role", path: "/fines/manage", header: APIKeyHeader, value: "lib_00000001_secret", want: http.StatusForbidden},
+		{name: "session only", path: "/profile", header: APIKeyHeader, value: "lib_00000001_secret", want: http.StatusForbidden},
+		{name: "unknown key", path: "/copies", header: APIKeyHeader, value: "lib_00000002_secret", want: http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := doAPIKeyRequest(r, c.path, c.header, c.value); got != c.want {
+				t.Fatalf("GET %s = %d, want %d", c.path, got, c.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyRateLimit(t *testing.T) {
+	r := newAPIKeyTestRouter(stubAPIKeys{
+		// 每小时1次请求，突发2次
+		"lib_00000001_secret": {ID: 1, UserID: 7, Scopes: []string{model.PermCopyRead}, RateLimit: 1.0 / 3600, RateBurst: 2},
+		"lib_00000002_secret": {ID: 2, UserID: 7, Scopes: []string{model.PermCopyRead}},
+	})
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+		if got := doAPIKeyRequest(r, "/copies", APIKeyHeader, "lib_00000001_secret"); got != want {
+			t.Fatalf("request %d with the limited key = %d, want %d", i+1, got, want)
+		}
+	}
+	// 限流按密钥计算，同一用户的其他密钥不受影响，使用默认速率
+	for i := 0; i < 10; i++ {
+		if got := doAPIKeyRequest(r, "/copies", APIKeyHeader, "lib_00000002_secret"); got != http.StatusOK {
+			t.Fatalf("request %d with another key = %d, want %d", i+1, got, http.StatusOK)
+		}
+	}
+}
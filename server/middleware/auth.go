@@ -7,9 +7,15 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware 认证中间件
+// AuthMiddleware 认证中间件，接受JWT访问令牌，注册了 APIKeyMiddleware 时也接受API密钥
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// 机器客户端使用API密钥
+		if key := apiKeyFromRequest(c); key != "" {
+			authenticateAPIKey(c, key)
+			return
+		}
+
 		// 从请求头获取token
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -32,18 +38,15 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 解析JWT token
-		claims, err := ParseToken(parts[1])
+		// 解析JWT token，并检查是否已注销
+		claims, err := ParseAccessToken(parts[1])
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code": 401,
-				"msg":  "无效的Token",
-			})
-			c.Abort()
+			abortTokenError(c, err)
 			return
 		}
 
 		// 将用户信息保存到上下文
+		c.Set("claims", claims)
 		c.Set("userID", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
@@ -51,59 +54,3 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
-
-// AdminAuthMiddleware 管理员权限中间件
-func AdminAuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code": 401,
-				"msg":  "未登录",
-			})
-			c.Abort()
-			return
-		}
-
-		if role.(string) != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{
-				"code": 403,
-				"msg":  "权限不足",
-			})
-			c.Abort()
-			return
-		}
-
-		c.Next()
-	}
-}
-
-// OwnerAuthMiddleware 资源所有者权限中间件
-func OwnerAuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		currentUserID, exists := c.Get("userID")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code": 401,
-				"msg":  "未登录",
-			})
-			c.Abort()
-			return
-		}
-
-		resourceUserID := c.GetUint("resourceUserID") // 在路由处理函数中设置
-		role := c.GetString("role")
-
-		// 管理员或资源所有者可以访问
-		if role != "admin" && currentUserID != resourceUserID {
-			c.JSON(http.StatusForbidden, gin.H{
-				"code": 403,
-				"msg":  "权限不足",
-			})
-			c.Abort()
-			return
-		}
-
-		c.Next()
-	}
-}
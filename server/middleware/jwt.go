@@ -1,6 +1,11 @@
 package middleware
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -9,6 +14,26 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 
 	"library/config"
+	"library/database"
+)
+
+// 令牌类型
+const (
+	TokenTypeAccess  = "access"  // 访问令牌，用于调用接口
+	TokenTypeRefresh = "refresh" // 刷新令牌，只能用于换取新的令牌对
+	TokenTypeMFA     = "mfa"     // 登录第一步返回的临时令牌，需提交TOTP验证码换取令牌对
+	TokenTypeEnroll  = "enroll"  // 登录第一步返回的临时令牌，必须启用双因素认证的账号用它完成绑定
+)
+
+var (
+	// ErrTokenType 令牌类型不符，如用刷新令牌调用接口
+	ErrTokenType = errors.New("unexpected token type")
+	// ErrTokenRevoked 令牌已注销（退出登录、修改密码或账号被禁用）
+	ErrTokenRevoked = errors.New("token revoked")
+	// ErrRefreshTokenReused 刷新令牌被重复使用，视为令牌泄露
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+	// ErrTokenStore 令牌状态存储不可用，无法校验令牌
+	ErrTokenStore = errors.New("token store unavailable")
 )
 
 // JWTAuthMiddleware JWT认证中间件
@@ -37,17 +62,14 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 		}
 
 		// parts[1]是获取到的tokenString，我们使用之前定义好的解析JWT的函数来解析它
-		mc, err := ParseToken(parts[1])
+		mc, err := ParseAccessToken(parts[1])
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code": 401,
-				"msg":  "无效的Token",
-			})
-			c.Abort()
+			abortTokenError(c, err)
 			return
 		}
 
 		// 将当前请求的userID信息保存到请求的上下文c上
+		c.Set("claims", mc)
 		c.Set("userID", mc.UserID)
 		c.Set("username", mc.Username)
 		c.Set("role", mc.Role)
@@ -55,40 +77,120 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
-// MyClaims 自定义声明结构体并内嵌jwt.RegisteredClaims
+// MyClaims 自定义声明结构体并内嵌jwt.RegisteredClaims，jti（RegisteredClaims.ID）用于注销单个令牌
 type MyClaims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	Type     string `json:"typ"` // 令牌类型 access/refresh/mfa/enroll
+	Version  int64  `json:"ver"` // 签发时用户的令牌版本号，与当前版本号不一致的令牌已失效
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成JWT
-func GenerateToken(userID uint, username, role string) (string, error) {
-	// 创建一个我们自己的声明
+// TokenPair 登录或刷新后返回给客户端的令牌对
+type TokenPair struct {
+	AccessToken  string `json:"token"`         // 访问令牌
+	RefreshToken string `json:"refresh_token"` // 刷新令牌，只能使用一次
+	ExpiresIn    int64  `json:"expires_in"`    // 访问令牌有效期（秒）
+}
+
+// abortTokenError 按令牌校验错误返回401或503
+func abortTokenError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrTokenStore):
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code": 503,
+			"msg":  "无法校验Token状态",
+		})
+	case errors.Is(err, ErrTokenRevoked):
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code": 401,
+			"msg":  "Token已失效",
+		})
+	default:
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code": 401,
+			"msg":  "无效的Token",
+		})
+	}
+	c.Abort()
+}
+
+// newTokenID 生成随机的令牌ID（jti）
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// generateToken 签发指定类型的令牌，返回令牌和jti
+func generateToken(userID uint, username, role, tokenType string, version int64, ttl time.Duration) (string, string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", "", fmt.Errorf("generate token id: %w", err)
+	}
+	now := time.Now()
 	claims := MyClaims{
-		userID,
-		username,
-		role,
-		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(config.GlobalConfig.JWT.ExpireTime) * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+		UserID:   userID,
+		Username: username,
+		Role:     role,
+		Type:     tokenType,
+		Version:  version,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 	// 使用指定的签名方法创建签名对象
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	// 使用指定的secret签名并获得完整的编码后的字符串token
-	return token.SignedString([]byte(config.GlobalConfig.JWT.Secret))
+	signed, err := token.SignedString([]byte(config.GlobalConfig.JWT.Secret))
+	if err != nil {
+		return "", "", err
+	}
+	return signed, jti, nil
 }
 
-// ParseToken 解析JWT
+// GenerateTokenPair 签发短期访问令牌和刷新令牌，刷新令牌登记到令牌存储中
+func GenerateTokenPair(userID uint, username, role string) (*TokenPair, error) {
+	ctx := context.Background()
+	cfg := config.GlobalConfig.JWT
+
+	version, err := database.GetTokenVersion(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenStore, err)
+	}
+
+	access, _, err := generateToken(userID, username, role, TokenTypeAccess, version, cfg.AccessTTL())
+	if err != nil {
+		return nil, err
+	}
+	refresh, jti, err := generateToken(userID, username, role, TokenTypeRefresh, version, cfg.RefreshTTL())
+	if err != nil {
+		return nil, err
+	}
+	if err := database.SaveRefreshToken(ctx, jti, userID, cfg.RefreshTTL()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenStore, err)
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(cfg.AccessTTL() / time.Second),
+	}, nil
+}
+
+// ParseToken 解析JWT，只校验签名和有效期
 func ParseToken(tokenString string) (*MyClaims, error) {
 	// 解析token
 	var mc = new(MyClaims)
 	token, err := jwt.ParseWithClaims(tokenString, mc, func(token *jwt.Token) (interface{}, error) {
 		return []byte(config.GlobalConfig.JWT.Secret), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 	if err != nil {
 		return nil, err
 	}
@@ -97,3 +199,147 @@ func ParseToken(tokenString string) (*MyClaims, error) {
 	}
 	return nil, jwt.ErrInvalidKey
 }
+
+// checkVersion 令牌版本号落后于用户当前版本号时，说明该用户的会话已被全部注销
+func checkVersion(ctx context.Context, mc *MyClaims) error {
+	version, err := database.GetTokenVersion(ctx, mc.UserID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTokenStore, err)
+	}
+	if mc.Version != version {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// ParseAccessToken 解析访问令牌，并检查令牌本身或其所属用户的会话是否已注销
+func ParseAccessToken(tokenString string) (*MyClaims, error) {
+	mc, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if mc.Type != TokenTypeAccess {
+		return nil, ErrTokenType
+	}
+
+	ctx := context.Background()
+	revoked, err := database.IsTokenRevoked(ctx, mc.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenStore, err)
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	if err := checkVersion(ctx, mc); err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
+// ConsumeRefreshToken 校验并消费刷新令牌，每个刷新令牌只能换取一次新令牌。
+// 已使用过的刷新令牌再次出现说明令牌可能已泄露，此时注销该用户的全部会话
+func ConsumeRefreshToken(tokenString string) (*MyClaims, error) {
+	mc, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if mc.Type != TokenTypeRefresh {
+		return nil, ErrTokenType
+	}
+
+	ctx := context.Background()
+	if err := checkVersion(ctx, mc); err != nil {
+		return nil, err
+	}
+
+	owner, ok, err := database.ConsumeRefreshToken(ctx, mc.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenStore, err)
+	}
+	if !ok {
+		if err := database.RevokeUserTokens(ctx, mc.UserID); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrTokenStore, err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if owner != mc.UserID {
+		return nil, ErrTokenRevoked
+	}
+	return mc, nil
+}
+
+// RevokeToken 注销访问令牌，记录保留到令牌自然过期
+func RevokeToken(mc *MyClaims) error {
+	if mc.ExpiresAt == nil {
+		return nil
+	}
+	if err := database.RevokeToken(context.Background(), mc.ID, time.Until(mc.ExpiresAt.Time)); err != nil {
+		return fmt.Errorf("%w: %v", ErrTokenStore, err)
+	}
+	return nil
+}
+
+// RevokeRefreshToken 注销属于userID的刷新令牌，令牌已失效时忽略
+func RevokeRefreshToken(tokenString string, userID uint) error {
+	mc, err := ParseToken(tokenString)
+	if err != nil || mc.Type != TokenTypeRefresh || mc.UserID != userID {
+		return nil
+	}
+	if _, _, err := database.ConsumeRefreshToken(context.Background(), mc.ID); err != nil {
+		return fmt.Errorf("%w: %v", ErrTokenStore, err)
+	}
+	return nil
+}
+
+// InterimToken 登录第一步返回的临时令牌
+type InterimToken struct {
+	Token     string `json:"interim_token"` // 临时令牌
+	Type      string `json:"type"`          // mfa-提交验证码 enroll-先绑定验证器
+	ExpiresIn int64  `json:"expires_in"`    // 有效期（秒）
+}
+
+// GenerateInterimToken 签发登录第二步使用的临时令牌，令牌登记为一次性令牌
+func GenerateInterimToken(userID uint, username, role, tokenType string) (*InterimToken, error) {
+	ctx := context.Background()
+	ttl := config.GlobalConfig.TwoFactor.InterimTTL()
+
+	version, err := database.GetTokenVersion(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenStore, err)
+	}
+	token, jti, err := generateToken(userID, username, role, tokenType, version, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.SaveOneTimeToken(ctx, tokenType, jti, userID, ttl); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenStore, err)
+	}
+	return &InterimToken{Token: token, Type: tokenType, ExpiresIn: int64(ttl / time.Second)}, nil
+}
+
+// ParseInterimToken 解析指定类型的临时令牌，不消费令牌，由调用方通过 ConsumeInterimToken 作废
+func ParseInterimToken(tokenString, tokenType string) (*MyClaims, error) {
+	mc, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if mc.Type != tokenType {
+		return nil, ErrTokenType
+	}
+	if err := checkVersion(context.Background(), mc); err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
+// ConsumeInterimToken 原子地消费临时令牌，令牌已被使用时返回 ErrTokenRevoked
+func ConsumeInterimToken(mc *MyClaims) error {
+	owner, ok, err := database.ConsumeOneTimeToken(context.Background(), mc.Type, mc.ID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTokenStore, err)
+	}
+	if !ok || owner != mc.UserID {
+		return ErrTokenRevoked
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"library/config"
+)
+
+// setTestJWTConfig 设置测试用的令牌配置，测试结束后恢复
+func setTestJWTConfig(t *testing.T) {
+	t.Helper()
+	saved := config.GlobalConfig
+	t.Cleanup(func() { config.GlobalConfig = saved })
+	config.GlobalConfig.JWT.Secret = "test-secret"
+	config.GlobalConfig.JWT.AccessExpire = 15
+	config.GlobalConfig.JWT.RefreshExpire = 24
+	config.GlobalConfig.TwoFactor.InterimExpire = 5
+}
+
+func TestConsumeInterimTokenOnce(t *testing.T) {
+	setTestJWTConfig(t)
+
+	interim, err := GenerateInterimToken(1, "reader", "user", TokenTypeMFA)
+	if err != nil {
+		t.Fatalf("GenerateInterimToken: %v", err)
+	}
+	if _, err := ParseInterimToken(interim.Token, TokenTypeEnroll); !errors.Is(err, ErrTokenType) {
+		t.Fatalf("ParseInterimToken with wrong type err = %v, want ErrTokenType", err)
+	}
+
+	// 同一临时令牌的并发请求只有一个能消费成功
+	const requests = 8
+	results := make([]error, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			mc, err := ParseInterimToken(interim.Token, TokenTypeMFA)
+			if err != nil {
+				results[i] = err
+				return
+			}
+			results[i] = ConsumeInterimToken(mc)
+		}(i)
+	}
+	wg.Wait()
+
+	consumed := 0
+	for _, err := range results {
+		switch {
+		case err == nil:
+			consumed++
+		case !errors.Is(err, ErrTokenRevoked):
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if consumed != 1 {
+		t.Fatalf("consumed %d times, want 1", consumed)
+	}
+}
+
+// 令牌版本号保存在进程内，各测试使用不同的用户ID互不影响
+const (
+	rotationUserID = 101
+	logoutUserID   = 102
+	typeUserID     = 103
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	setTestJWTConfig(t)
+
+	first, err := GenerateTokenPair(rotationUserID, "reader", "user")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	mc, err := ConsumeRefreshToken(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("ConsumeRefreshToken: %v", err)
+	}
+	if mc.UserID != rotationUserID || mc.Username != "reader" || mc.Role != "user" {
+		t.Fatalf("refresh claims = %+v", mc)
+	}
+	second, err := GenerateTokenPair(mc.UserID, mc.Username, mc.Role)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatalf("rotated refresh token equals the old one")
+	}
+	for _, pair := range []*TokenPair{first, second} {
+		if _, err := ParseAccessToken(pair.AccessToken); err != nil {
+			t.Fatalf("ParseAccessToken before reuse: %v", err)
+		}
+	}
+
+	// 已使用的刷新令牌再次出现，注销该用户的全部会话
+	if _, err := ConsumeRefreshToken(first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused refresh token err = %v, want ErrRefreshTokenReused", err)
+	}
+	for _, pair := range []*TokenPair{first, second} {
+		if _, err := ParseAccessToken(pair.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+			t.Fatalf("ParseAccessToken after reuse err = %v, want ErrTokenRevoked", err)
+		}
+	}
+	if _, err := ConsumeRefreshToken(second.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("refresh after reuse err = %v, want ErrTokenRevoked", err)
+	}
+
+	// 注销后重新登录签发的令牌可以正常使用
+	third, err := GenerateTokenPair(rotationUserID, "reader", "user")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	if _, err := ParseAccessToken(third.AccessToken); err != nil {
+		t.Fatalf("ParseAccessToken after login: %v", err)
+	}
+	if _, err := ConsumeRefreshToken(third.RefreshToken); err != nil {
+		t.Fatalf("ConsumeRefreshToken after login: %v", err)
+	}
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	setTestJWTConfig(t)
+
+	pair, err := GenerateTokenPair(logoutUserID, "reader", "user")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	other, err := GenerateTokenPair(logoutUserID, "reader", "user")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	mc, err := ParseAccessToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+
+	// 退出登录按jti注销访问令牌和刷新令牌，不影响其他设备的会话
+	if err := RevokeToken(mc); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if err := RevokeRefreshToken(pair.RefreshToken, logoutUserID); err != nil {
+		t.Fatalf("RevokeRefreshToken: %v", err)
+	}
+	if _, err := ParseAccessToken(pair.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("revoked access token err = %v, want ErrTokenRevoked", err)
+	}
+	if _, err := ParseAccessToken(other.AccessToken); err != nil {
+		t.Fatalf("access token of another session: %v", err)
+	}
+
+	// 其他用户的刷新令牌不会被注销
+	if err := RevokeRefreshToken(other.RefreshToken, logoutUserID+1000); err != nil {
+		t.Fatalf("RevokeRefreshToken of another user: %v", err)
+	}
+	if _, err := ConsumeRefreshToken(other.RefreshToken); err != nil {
+		t.Fatalf("refresh token revoked by another user: %v", err)
+	}
+}
+
+func TestTokenTypes(t *testing.T) {
+	setTestJWTConfig(t)
+
+	pair, err := GenerateTokenPair(typeUserID, "reader", "user")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	if _, err := ParseAccessToken(pair.RefreshToken); !errors.Is(err, ErrTokenType) {
+		t.Fatalf("refresh token used as access token err = %v, want ErrTokenType", err)
+	}
+	if _, err := ConsumeRefreshToken(pair.AccessToken); !errors.Is(err, ErrTokenType) {
+		t.Fatalf("access token used as refresh token err = %v, want ErrTokenType", err)
+	}
+
+	config.GlobalConfig.JWT.Secret = "another-secret"
+	if _, err := ParseAccessToken(pair.AccessToken); err == nil {
+		t.Fatalf("ParseAccessToken accepted a token signed with another secret")
+	}
+}
+
+func TestJWTAuthMiddleware(t *testing.T) {
+	setTestJWTConfig(t)
+	gin.SetMode(gin.TestMode)
+
+	pair, err := GenerateTokenPair(typeUserID, "reader", "user")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	revoked, err := GenerateTokenPair(typeUserID, "reader", "user")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	mc, err := ParseAccessToken(revoked.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if err := RevokeToken(mc); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/me", JWTAuthMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, "%d", c.GetUint("userID"))
+	})
+
+	cases := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{name: "valid", header: "Bearer " + pair.AccessToken, status: http.StatusOK},
+		{name: "missing", header: "", status: http.StatusUnauthorized},
+		{name: "not bearer", header: "Token " + pair.AccessToken, status: http.StatusUnauthorized},
+		{name: "refresh token", header: "Bearer " + pair.RefreshToken, status: http.StatusUnauthorized},
+		{name: "revoked", header: "Bearer " + revoked.AccessToken, status: http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != c.status {
+			t.Errorf("%s: status = %d, want %d", c.name, w.Code, c.status)
+		}
+	}
+}
@@ -56,6 +56,9 @@ func LoggerMiddleware(logger *logrus.Logger) gin.HandlerFunc {
 			"method":       reqMethod,
 			"uri":          reqUri,
 		}
+		if requestID := c.GetString("requestID"); requestID != "" {
+			fields["request_id"] = requestID
+		}
 
 		if userID != nil {
 			fields["user_id"] = userID
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// permissionCheckerKey 权限检查器在上下文中的键
+const permissionCheckerKey = "permissionChecker"
+
+// PermissionChecker 判断角色是否拥有指定权限
+type PermissionChecker interface {
+	HasPermission(role, permission string) (bool, error)
+}
+
+// PermissionMiddleware 将权限检查器保存到上下文，供 RequirePermission 和 HasPermission 使用。
+// 需要在所有路由之前注册
+func PermissionMiddleware(checker PermissionChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(permissionCheckerKey, checker)
+		c.Next()
+	}
+}
+
+// RequirePermission 权限中间件，要求当前用户的角色拥有全部指定权限，需在 AuthMiddleware 之后使用
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get("role"); !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code": 401,
+				"msg":  "未登录",
+			})
+			c.Abort()
+			return
+		}
+
+		for _, p := range permissions {
+			ok, err := checkPermission(c, p)
+			if err != nil {
+				log.Printf("check permission %s: %v", p, err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"code": 500,
+					"msg":  "权限校验失败",
+				})
+				c.Abort()
+				return
+			}
+			if !ok {
+				c.JSON(http.StatusForbidden, gin.H{
+					"code": 403,
+					"msg":  "权限不足",
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// HasPermission 当前用户的角色是否拥有指定权限，用于处理函数中"本人或有权限的工作人员"一类的判断。
+// 未登录或校验出错时返回false
+func HasPermission(c *gin.Context, permission string) bool {
+	ok, err := checkPermission(c, permission)
+	if err != nil {
+		log.Printf("check permission %s: %v", permission, err)
+		return false
+	}
+	return ok
+}
+
+func checkPermission(c *gin.Context, permission string) (bool, error) {
+	role := c.GetString("role")
+	if role == "" {
+		return false, nil
+	}
+	// API密钥的权限为作用域与所属用户角色权限的交集
+	if scopes, ok := c.Get(apiKeyScopesKey); ok && !hasScope(scopes.([]string), permission) {
+		return false, nil
+	}
+	v, exists := c.Get(permissionCheckerKey)
+	if !exists {
+		return false, nil
+	}
+	return v.(PermissionChecker).HasPermission(role, permission)
+}
+
+func hasScope(scopes []string, permission string) bool {
+	for _, s := range scopes {
+		if s == permission {
+			return true
+		}
+	}
+	return false
+}
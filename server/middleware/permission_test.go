@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"library/model"
+)
+
+// failingPermissions 权限查询总是失败
+type failingPermissions struct{}
+
+func (failingPermissions) HasPermission(role, permission string) (bool, error) {
+	return false, errors.New("database unavailable")
+}
+
+// newPermissionTestRouter role为空时不设置登录信息
+func newPermissionTestRouter(checker PermissionChecker, role string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(PermissionMiddleware(checker))
+	r.Use(func(c *gin.Context) {
+		if role != "" {
+			c.Set("userID", uint(1))
+			c.Set("role", role)
+		}
+	})
+
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/copies", RequirePermission(model.PermCopyRead), ok)
+	r.GET("/checkout", RequirePermission(model.PermCopyRead, model.PermBorrowCheckout), ok)
+	r.GET("/fines", RequirePermission(model.PermCopyRead, model.PermFineManage), ok)
+	r.GET("/staff", func(c *gin.Context) {
+		if HasPermission(c, model.PermBorrowRead) {
+			c.Status(http.StatusOK)
+			return
+		}
+		c.Status(http.StatusForbidden)
+	})
+	return r
+}
+
+func TestRequirePermission(t *testing.T) {
+	checker := stubPermissions{
+		model.RoleLibrarian: {model.PermCopyRead, model.PermBorrowCheckout, model.PermBorrowRead},
+		model.RoleUser:      {},
+	}
+
+	cases := []struct {
+		name    string
+		checker PermissionChecker
+		role    string
+		path    string
+		status  int
+	}{
+		{name: "granted", checker: checker, role: model.RoleLibrarian, path: "/copies", status: http.StatusOK},
+		{name: "all granted", checker: checker, role: model.RoleLibrarian, path: "/checkout", status: http.StatusOK},
+		{name: "one of several missing", checker: checker, role: model.RoleLibrarian, path: "/fines", status: http.StatusForbidden},
+		{name: "patron", checker: checker, role: model.RoleUser, path: "/copies", status: http.StatusForbidden},
+		{name: "not logged in", checker: checker, path: "/copies", status: http.StatusUnauthorized},
+		{name: "checker error", checker: failingPermissions{}, role: model.RoleLibrarian, path: "/copies", status: http.StatusInternalServerError},
+		{name: "HasPermission granted", checker: checker, role: model.RoleLibrarian, path: "/staff", status: http.StatusOK},
+		{name: "HasPermission denied", checker: checker, role: model.RoleUser, path: "/staff", status: http.StatusForbidden},
+		{name: "HasPermission error", checker: failingPermissions{}, role: model.RoleLibrarian, path: "/staff", status: http.StatusForbidden},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newPermissionTestRouter(c.checker, c.role).ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.status {
+				t.Fatalf("GET %s as %q = %d, want %d", c.path, c.role, w.Code, c.status)
+			}
+		})
+	}
+}
@@ -63,6 +63,25 @@ func (i *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
 	return limiter
 }
 
+// GetLimiterWithRate 获取key对应的限流器，使用指定的速率和突发数。
+// 用于每个key速率不同的场景（如按API密钥限流），速率变化时更新已有的限流器
+func (i *IPRateLimiter) GetLimiterWithRate(key string, r rate.Limit, burst int) *rate.Limiter {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	limiter, exists := i.ips[key]
+	if !exists {
+		limiter = rate.NewLimiter(r, burst)
+		i.ips[key] = limiter
+	} else if limiter.Limit() != r || limiter.Burst() != burst {
+		limiter.SetLimit(r)
+		limiter.SetBurst(burst)
+	}
+	i.lastOp[key] = time.Now()
+
+	return limiter
+}
+
 // cleanupLoop 定期清理过期的限流器
 func (i *IPRateLimiter) cleanupLoop() {
 	ticker := time.NewTicker(i.ttl)
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+)
+
+// APIKey 机器客户端（自助借还机、编目同步脚本等）使用的API密钥
+// @Description 只保存密钥的SHA-256摘要，明文只在创建和轮换时返回一次。请求以所属用户的身份执行，权限为作用域与该用户角色权限的交集
+type APIKey struct {
+	ID        uint      `gorm:"primarykey" json:"id"` // 记录ID
+	CreatedAt time.Time `json:"created_at"`           // 创建时间
+	UpdatedAt time.Time `json:"updated_at"`           // 更新时间
+
+	Name       string     `gorm:"type:varchar(64);not null" json:"name"`                    // 名称，如"一楼自助借还机"
+	Prefix     string     `gorm:"type:varchar(16);not null;uniqueIndex" json:"prefix"`      // 密钥的公开部分，用于查找密钥和在列表中识别
+	KeyHash    string     `gorm:"type:char(64);not null" json:"-"`                          // 完整密钥的SHA-256摘要
+	Scopes     []string   `gorm:"type:json;serializer:json" json:"scopes"`                  // 作用域，取值为权限名称
+	UserID     uint       `gorm:"not null;index" json:"user_id"`                            // 所属用户，请求以该用户的身份执行
+	CreatedBy  uint       `gorm:"not null" json:"created_by"`                               // 签发的管理员
+	RateLimit  float64    `gorm:"not null;default:0" json:"rate_limit"`                     // 每秒允许的请求数，0 使用默认值
+	RateBurst  int        `gorm:"not null;default:0" json:"rate_burst"`                     // 突发请求数，0 使用默认值
+	ExpiresAt  *time.Time `gorm:"type:datetime" json:"expires_at"`                          // 过期时间，为空表示不过期
+	LastUsedAt *time.Time `gorm:"type:datetime" json:"last_used_at"`                        // 最近一次使用时间
+	LastUsedIP string     `gorm:"type:varchar(64);not null;default:''" json:"last_used_ip"` // 最近一次使用的IP
+	RotatedAt  *time.Time `gorm:"type:datetime" json:"rotated_at"`                          // 最近一次轮换时间
+	RevokedAt  *time.Time `gorm:"type:datetime" json:"revoked_at"`                          // 吊销时间，为空表示有效
+}
+
+// HasScope 密钥是否拥有指定作用域
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active 密钥在指定时间是否可用（未吊销且未过期）
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// APIKeyPrefix API密钥的固定开头，以 Bearer 方式传递时据此与JWT区分
+const APIKeyPrefix = "lib_"
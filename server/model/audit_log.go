@@ -0,0 +1,71 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditLog 审计记录
+// @Description 修改数据的操作记录，只能追加。每条记录的摘要包含上一条记录的摘要，组成哈希链，修改或删除任意一条记录都会使之后的校验失败。记录与业务变更在同一事务中写入，提交后由封链任务按提交顺序分配序号并计算摘要，封链前 seq 为0、hash 为空
+type AuditLog struct {
+	ID        uint      `gorm:"primarykey" json:"id"`    // 记录ID
+	CreatedAt time.Time `gorm:"index" json:"created_at"` // 操作时间
+
+	ActorID    uint   `gorm:"index" json:"actor_id"`                                               // 操作人ID，0表示系统
+	Action     string `gorm:"type:varchar(64);not null;index" json:"action"`                       // 操作 如 user.role、book.stock、borrow.update
+	TargetType string `gorm:"type:varchar(32);not null;index:idx_audit_target" json:"target_type"` // 操作对象类型 如 user、book
+	TargetID   uint   `gorm:"index:idx_audit_target" json:"target_id"`                             // 操作对象ID
+	Changes    string `gorm:"type:text" json:"changes"`                                            // 字段变更（JSON），格式为 {"字段": {"before": 旧值, "after": 新值}}
+	Detail     string `gorm:"type:text" json:"detail"`                                             // 其他详情（JSON）
+	IP         string `gorm:"type:varchar(64);not null;default:''" json:"ip"`                      // 操作人IP，系统任务为空
+	RequestID  string `gorm:"type:varchar(64);not null;default:'';index" json:"request_id"`        // 请求ID，与访问日志和响应头 X-Request-ID 对应
+	Seq        uint64 `gorm:"not null;default:0;index" json:"seq"`                                 // 在哈希链中的序号，0表示尚未封链
+	PrevHash   string `gorm:"type:char(64);not null;default:''" json:"prev_hash"`                  // 上一条记录的摘要
+	Hash       string `gorm:"type:char(64);not null;default:''" json:"hash"`                       // 本条记录的摘要，尚未封链时为空
+}
+
+// ComputeHash 计算记录的摘要，覆盖除ID、序号和摘要本身以外的全部字段。链中的顺序由 PrevHash 保证
+func (l *AuditLog) ComputeHash() string {
+	fields := []string{
+		l.PrevHash,
+		l.CreatedAt.UTC().Format(time.RFC3339Nano),
+		strconv.FormatUint(uint64(l.ActorID), 10),
+		l.Action,
+		l.TargetType,
+		strconv.FormatUint(uint64(l.TargetID), 10),
+		l.Changes,
+		l.Detail,
+		l.IP,
+		l.RequestID,
+	}
+	// 各字段前加长度，避免字段拼接产生歧义
+	var b strings.Builder
+	for _, f := range fields {
+		b.WriteString(strconv.Itoa(len(f)))
+		b.WriteByte(':')
+		b.WriteString(f)
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditChainHead 哈希链的链头，只有一行。只有封链任务读写该行，业务事务不会锁定它
+type AuditChainHead struct {
+	ID        uint `gorm:"primarykey"`
+	UpdatedAt time.Time
+	LastID    uint   `gorm:"not null;default:0"`                // 最后一条记录的ID
+	LastSeq   uint64 `gorm:"not null;default:0"`                // 最后一条记录的序号
+	LastHash  string `gorm:"type:char(64);not null;default:''"` // 最后一条记录的摘要
+}
+
+// AuditChainReport 哈希链校验结果
+type AuditChainReport struct {
+	Checked  int64  `json:"checked"`   // 校验的记录数
+	Pending  int64  `json:"pending"`   // 已提交但尚未封链的记录数，不参与校验
+	Valid    bool   `json:"valid"`     // 哈希链是否完整
+	BrokenAt uint   `json:"broken_at"` // 第一条校验失败的记录ID
+	Reason   string `json:"reason"`    // 校验失败的原因
+}
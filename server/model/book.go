@@ -20,10 +20,13 @@ type Book struct {
 	Publisher string  `gorm:"type:varchar(64)" json:"publisher"`                 // 出版社
 	Category  string  `gorm:"type:varchar(32)" json:"category"`                  // 分类
 	Price     float64 `gorm:"type:decimal(10,2)" json:"price"`                   // 价格
-	Total     int     `gorm:"type:int;not null" json:"total"`                    // 总数量
-	Available int     `gorm:"type:int;not null" json:"available"`                // 可借数量
+	Total     int     `gorm:"type:int;not null" json:"total"`                    // 总数量（由副本统计，不含遗失和注销的副本）
+	Available int     `gorm:"type:int;not null" json:"available"`                // 可借数量（在架副本数）
 	Location  string  `gorm:"type:varchar(32)" json:"location"`                  // 馆藏位置
 	Cover     string  `gorm:"type:varchar(256)" json:"cover"`                    // 封面图片URL
 	Summary   string  `gorm:"type:text" json:"summary"`                          // 简介
 	Status    int     `gorm:"type:tinyint;default:1;not null" json:"status"`     // 状态 2-下架 1-上架
+
+	TitlePinyin  string `gorm:"type:text" json:"-"` // 书名拼音检索串（全拼和首字母），由书名生成
+	AuthorPinyin string `gorm:"type:text" json:"-"` // 作者拼音检索串（全拼和首字母），由作者生成
 }
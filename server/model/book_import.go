@@ -0,0 +1,18 @@
+package model
+
+// BookImportResult 单条记录的导入结果
+type BookImportResult struct {
+	Index  int    `json:"index"`             // 记录在文件中的序号，从1开始
+	ISBN   string `json:"isbn,omitempty"`    // ISBN
+	Title  string `json:"title,omitempty"`   // 书名
+	BookID uint   `json:"book_id,omitempty"` // 创建的图书ID
+	Error  string `json:"error,omitempty"`   // 失败原因
+}
+
+// BookImportReport 批量导入结果
+type BookImportReport struct {
+	Total   int                 `json:"total"`   // 记录总数
+	Created int                 `json:"created"` // 成功创建的图书数
+	Failed  int                 `json:"failed"`  // 失败的记录数
+	Results []*BookImportResult `json:"results"` // 每条记录的结果
+}
@@ -0,0 +1,9 @@
+package model
+
+// BookSearchHit 图书全文检索结果
+// @Description 命中的图书、相关度和高亮文本
+type BookSearchHit struct {
+	Book       *Book             `json:"book"`       // 图书信息
+	Score      float64           `json:"score"`      // 相关度（BM25），按ISBN精确命中时为0，按拼音命中时为拼音匹配的得分
+	Highlights map[string]string `json:"highlights"` // 命中字段的高亮文本，键为 title、author、publisher、summary、isbn，按拼音命中时为 title、author，命中的词以 <em></em> 标记
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Copy 馆藏副本模型，每个实体副本对应一条记录
+// @Description 馆藏副本信息
+type Copy struct {
+	ID        uint           `gorm:"primarykey" json:"id"`                                                                                          // 副本ID
+	CreatedAt time.Time      `json:"created_at"`                                                                                                    // 创建时间
+	UpdatedAt time.Time      `json:"updated_at"`                                                                                                    // 更新时间
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggertype:"string" format:"date-time" example:"2024-01-01T00:00:00+08:00"` // 删除时间
+
+	BookID    uint   `gorm:"not null;index:idx_copy_book_status" json:"book_id"`                       // 图书ID
+	Barcode   string `gorm:"type:varchar(32);uniqueIndex;not null" json:"barcode"`                     // 条码
+	Condition int    `gorm:"type:tinyint;default:1;not null" json:"condition"`                         // 品相 1-良好 2-一般 3-破损
+	Branch    string `gorm:"type:varchar(64)" json:"branch"`                                           // 所属分馆
+	Location  string `gorm:"type:varchar(64)" json:"location"`                                         // 架位
+	Status    int    `gorm:"type:tinyint;default:1;not null;index:idx_copy_book_status" json:"status"` // 状态 1-在架 2-借出 3-预约保留 4-维修中 5-遗失 6-注销
+	Remark    string `gorm:"type:varchar(256)" json:"remark"`                                          // 备注
+
+	Book *Book `gorm:"foreignKey:BookID" json:"book,omitempty"` // 图书信息
+}
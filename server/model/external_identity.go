@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+// ExternalIdentity 外部身份
+// @Description 本地账号在外部身份提供方（如OIDC）的身份，通过 提供方+subject 唯一确定
+type ExternalIdentity struct {
+	ID        uint      `gorm:"primarykey" json:"id"` // 记录ID
+	CreatedAt time.Time `json:"created_at"`           // 关联时间
+	UpdatedAt time.Time `json:"updated_at"`           // 更新时间
+
+	UserID      uint      `gorm:"not null;index" json:"user_id"`                                              // 本地用户ID
+	Provider    string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_identity_subject" json:"provider"` // 身份提供方标识
+	Subject     string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_identity_subject" json:"subject"` // 提供方中的用户唯一标识（sub）
+	Email       string    `gorm:"type:varchar(100)" json:"email"`                                             // 最近一次登录时提供方返回的邮箱
+	LastLoginAt time.Time `json:"last_login_at"`                                                              // 最近一次通过该身份登录的时间
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+)
+
+// FineTransaction 罚金流水模型（只追加，不修改）
+// @Description 罚金账户流水：罚款、缴费、减免、退款
+type FineTransaction struct {
+	ID        uint      `gorm:"primarykey" json:"id"` // 流水ID
+	CreatedAt time.Time `json:"created_at"`           // 创建时间
+	UpdatedAt time.Time `json:"updated_at"`           // 更新时间
+
+	UserID      uint    `gorm:"not null;index" json:"user_id"`               // 用户ID
+	BorrowID    *uint   `gorm:"index" json:"borrow_id"`                      // 关联借阅记录ID
+	RefID       *uint   `gorm:"index" json:"ref_id"`                         // 关联流水ID（退款对应的缴费流水）
+	Type        int     `gorm:"type:tinyint;not null;index" json:"type"`     // 类型 1-罚款 2-缴费 3-减免 4-退款
+	Amount      float64 `gorm:"type:decimal(10,2);not null" json:"amount"`   // 金额（均为正数）
+	Reason      string  `gorm:"type:varchar(256)" json:"reason"`             // 原因/说明
+	OperatorID  uint    `gorm:"default:0" json:"operator_id"`                // 操作人ID 0-系统
+	Provider    string  `gorm:"type:varchar(32)" json:"provider"`            // 支付渠道（缴费/退款）
+	ProviderRef string  `gorm:"type:varchar(128);index" json:"provider_ref"` // 支付渠道流水号
+
+	IdempotencyKey string `gorm:"type:varchar(64);index" json:"-"` // 缴费请求的幂等键，重试同一笔缴费时返回已有的流水
+
+	User User `gorm:"foreignKey:UserID" json:"-"` // 用户信息
+}
+
+// FineSummary 用户罚金汇总
+// @Description 用户罚金账户汇总，余额=罚款+退款-缴费-减免
+type FineSummary struct {
+	UserID   uint    `json:"user_id"`  // 用户ID
+	Username string  `json:"username"` // 用户名
+	Charged  float64 `json:"charged"`  // 罚款合计
+	Paid     float64 `json:"paid"`     // 缴费合计
+	Waived   float64 `json:"waived"`   // 减免合计
+	Refunded float64 `json:"refunded"` // 退款合计
+	Balance  float64 `json:"balance"`  // 未缴余额
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+)
+
+// 导入任务状态
+const (
+	ImportStatusPending   = 1 // 排队中
+	ImportStatusRunning   = 2 // 执行中
+	ImportStatusSucceeded = 3 // 已完成
+	ImportStatusFailed    = 4 // 执行失败
+	ImportStatusCanceled  = 5 // 已取消
+)
+
+// 导入行的处理结果
+const (
+	ImportActionCreate    = "create"    // 新建图书（试运行时表示将会新建）
+	ImportActionUpdate    = "update"    // 按ISBN更新已有图书
+	ImportActionUnchanged = "unchanged" // 已有图书与导入数据一致，无需更新
+	ImportActionError     = "error"     // 校验或写入失败
+)
+
+// ImportJob 图书批量导入任务
+// @Description CSV/XLSX图书导入任务，上传时逐行校验，由后台任务按ISBN新建或更新图书
+type ImportJob struct {
+	ID        uint      `gorm:"primarykey" json:"id"` // 任务ID
+	CreatedAt time.Time `json:"created_at"`           // 创建时间
+	UpdatedAt time.Time `json:"updated_at"`           // 更新时间
+
+	UserID     uint       `gorm:"not null;index" json:"user_id"`                       // 发起人
+	IP         string     `gorm:"type:varchar(64)" json:"-"`                           // 发起请求的客户端IP，写入审计记录
+	RequestID  string     `gorm:"type:varchar(64)" json:"request_id"`                  // 发起请求的请求ID
+	FileName   string     `gorm:"type:varchar(255)" json:"file_name"`                  // 上传的文件名
+	Format     string     `gorm:"type:varchar(8);not null" json:"format"`              // 文件格式 csv、xlsx
+	DryRun     bool       `gorm:"not null;default:false" json:"dry_run"`               // 试运行，只比对不写入
+	Status     int        `gorm:"type:tinyint;default:1;not null;index" json:"status"` // 状态 1-排队中 2-执行中 3-已完成 4-执行失败 5-已取消
+	Instance   string     `gorm:"type:varchar(128)" json:"instance"`                   // 执行实例
+	TotalRows  int        `gorm:"type:int;default:0;not null" json:"total_rows"`       // 数据行数
+	Processed  int        `gorm:"type:int;default:0;not null" json:"processed"`        // 已处理行数，含校验失败的行
+	Created    int        `gorm:"type:int;default:0;not null" json:"created"`          // 新建的图书数
+	Updated    int        `gorm:"type:int;default:0;not null" json:"updated"`          // 更新的图书数
+	Unchanged  int        `gorm:"type:int;default:0;not null" json:"unchanged"`        // 无需更新的图书数
+	Failed     int        `gorm:"type:int;default:0;not null" json:"failed"`           // 失败的行数
+	StartedAt  *time.Time `gorm:"type:datetime" json:"started_at"`                     // 开始执行时间
+	FinishedAt *time.Time `gorm:"type:datetime" json:"finished_at"`                    // 结束时间
+	Error      string     `gorm:"type:varchar(512)" json:"error"`                      // 任务失败的原因
+}
+
+// ImportJobRow 导入任务的数据行
+// @Description 上传文件中的一行，校验失败的行在上传时即记录错误，其余行由后台任务处理
+type ImportJobRow struct {
+	ID        uint      `gorm:"primarykey" json:"id"` // 记录ID
+	CreatedAt time.Time `json:"created_at"`           // 创建时间
+	UpdatedAt time.Time `json:"updated_at"`           // 更新时间
+
+	JobID   uint   `gorm:"not null;index:idx_import_row,priority:1" json:"job_id"` // 任务ID
+	RowNo   int    `gorm:"not null;index:idx_import_row,priority:2" json:"row"`    // 文件中的行号，表头为第1行
+	ISBN    string `gorm:"type:varchar(20)" json:"isbn"`                           // ISBN
+	Title   string `gorm:"type:varchar(128)" json:"title"`                         // 书名
+	Data    string `gorm:"type:text" json:"-"`                                     // 校验通过的图书数据（JSON）
+	Action  string `gorm:"type:varchar(16);index" json:"action"`                   // 处理结果 create、update、unchanged、error，为空表示未处理
+	BookID  uint   `json:"book_id,omitempty"`                                      // 新建或更新的图书ID
+	Changes string `gorm:"type:text" json:"changes,omitempty"`                     // 更新时的字段变化（JSON），格式同审计记录
+	Error   string `gorm:"type:varchar(512)" json:"error,omitempty"`               // 失败原因
+}
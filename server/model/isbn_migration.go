@@ -0,0 +1,25 @@
+package model
+
+// ISBNMigrationReport ISBN规范化结果
+type ISBNMigrationReport struct {
+	Scanned    int           `json:"scanned"`    // 检查的图书数，不含已删除的图书
+	Normalized int           `json:"normalized"` // 改写为ISBN-13的图书数
+	Merged     int           `json:"merged"`     // 合并到同一ISBN其他图书的重复图书数
+	Changes    []*ISBNChange `json:"changes"`    // 每本图书的变更
+	Issues     []*ISBNIssue  `json:"issues"`     // 无法处理的图书
+}
+
+// ISBNChange 单本图书的ISBN变更
+type ISBNChange struct {
+	BookID     uint   `json:"book_id"`               // 图书ID
+	From       string `json:"from"`                  // 原ISBN
+	To         string `json:"to"`                    // 规范化后的ISBN
+	MergedInto uint   `json:"merged_into,omitempty"` // 合并到的图书ID，为0表示只改写ISBN
+}
+
+// ISBNIssue 无法规范化的图书
+type ISBNIssue struct {
+	BookID uint   `json:"book_id"` // 图书ID
+	ISBN   string `json:"isbn"`    // 原ISBN
+	Reason string `json:"reason"`  // 原因
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+)
+
+// JobRun 后台任务执行记录
+// @Description 后台定时任务的执行结果
+type JobRun struct {
+	ID        uint      `gorm:"primarykey" json:"id"` // 记录ID
+	CreatedAt time.Time `json:"created_at"`           // 创建时间
+	UpdatedAt time.Time `json:"updated_at"`           // 更新时间
+
+	Name       string     `gorm:"type:varchar(64);not null;index" json:"name"`   // 任务名称
+	Instance   string     `gorm:"type:varchar(128)" json:"instance"`             // 执行实例
+	StartedAt  time.Time  `gorm:"type:datetime;not null" json:"started_at"`      // 开始时间
+	FinishedAt *time.Time `gorm:"type:datetime" json:"finished_at"`              // 结束时间
+	Status     int        `gorm:"type:tinyint;default:1;not null" json:"status"` // 状态 1-执行中 2-成功 3-失败
+	Scanned    int        `gorm:"type:int;default:0" json:"scanned"`             // 扫描记录数
+	Affected   int        `gorm:"type:int;default:0" json:"affected"`            // 状态发生变更的记录数
+	Updated    int        `gorm:"type:int;default:0" json:"updated"`             // 数据被更新的记录数
+	Error      string     `gorm:"type:varchar(512)" json:"error"`                // 错误信息
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoanPolicy 借阅规则模型
+// @Description 按读者角色和图书分类配置的借阅规则，角色或分类为空表示适用于全部
+type LoanPolicy struct {
+	ID        uint           `gorm:"primarykey" json:"id"`                                                                                          // 规则ID
+	CreatedAt time.Time      `json:"created_at"`                                                                                                    // 创建时间
+	UpdatedAt time.Time      `json:"updated_at"`                                                                                                    // 更新时间
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggertype:"string" format:"date-time" example:"2024-01-01T00:00:00+08:00"` // 删除时间
+
+	Name        string  `gorm:"type:varchar(64);not null" json:"name"`                   // 规则名称
+	Role        string  `gorm:"type:varchar(32);index:idx_scope" json:"role"`            // 适用角色，空表示所有角色
+	Category    string  `gorm:"type:varchar(32);index:idx_scope" json:"category"`        // 适用图书分类，空表示所有分类
+	LoanDays    int     `gorm:"type:int;not null" json:"loan_days"`                      // 借期（天）
+	MaxRenewals int     `gorm:"type:int;not null;default:0" json:"max_renewals"`         // 最多续借次数
+	MaxLoans    int     `gorm:"type:int;not null;default:0" json:"max_loans"`            // 最多同时借阅数量 0-不限
+	DailyFine   float64 `gorm:"type:decimal(10,2);not null;default:0" json:"daily_fine"` // 逾期每日罚金
+	FineCap     float64 `gorm:"type:decimal(10,2);not null;default:0" json:"fine_cap"`   // 单次借阅罚金上限 0-不设上限
+	GraceDays   int     `gorm:"type:int;not null;default:0" json:"grace_days"`           // 逾期宽限天数，宽限期内归还不计罚金
+	Status      int     `gorm:"type:tinyint;default:1;not null" json:"status"`           // 状态 2-停用 1-启用
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+)
+
+// 待发邮件状态
+const (
+	MailStatusPending = 1 // 待发送
+	MailStatusSent    = 2 // 已发送
+	MailStatusFailed  = 3 // 多次重试后仍发送失败，不再重试
+)
+
+// MailOutbox 待发邮件
+// @Description 事务性发件箱，与业务数据在同一事务中写入，由后台任务投递
+type MailOutbox struct {
+	ID        uint      `gorm:"primarykey" json:"id"` // 记录ID
+	CreatedAt time.Time `json:"created_at"`           // 创建时间
+	UpdatedAt time.Time `json:"updated_at"`           // 更新时间
+
+	Template      string     `gorm:"type:varchar(64);not null" json:"template"`                                   // 模板名称
+	Recipient     string     `gorm:"type:varchar(128);not null" json:"recipient"`                                 // 收件人
+	Subject       string     `gorm:"type:varchar(255);not null" json:"subject"`                                   // 主题
+	TextBody      string     `gorm:"type:text" json:"-"`                                                          // 纯文本正文
+	HTMLBody      string     `gorm:"type:mediumtext" json:"-"`                                                    // HTML正文
+	Status        int        `gorm:"type:tinyint;default:1;not null;index:idx_mail_due,priority:1" json:"status"` // 状态 1-待发送 2-已发送 3-发送失败
+	Attempts      int        `gorm:"type:int;default:0;not null" json:"attempts"`                                 // 已尝试次数
+	NextAttemptAt time.Time  `gorm:"type:datetime;not null;index:idx_mail_due,priority:2" json:"next_attempt_at"` // 下次尝试时间
+	SentAt        *time.Time `gorm:"type:datetime" json:"sent_at"`                                                // 发送时间
+	LastError     string     `gorm:"type:varchar(512)" json:"last_error"`                                         // 最近一次发送失败的原因
+}
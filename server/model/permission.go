@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+)
+
+// 权限名称，格式为 资源:操作
+const (
+	PermUserRead          = "user:read"          // 查看用户列表
+	PermUserManage        = "user:manage"        // 修改用户角色、状态，删除用户
+	PermRoleManage        = "role:manage"        // 编辑角色的权限
+	PermBookWrite         = "book:write"         // 新增、修改图书和副本
+	PermCopyRead          = "copy:read"          // 扫码查询副本
+	PermBorrowRead        = "borrow:read"        // 查看全部借阅记录
+	PermBorrowCheckout    = "borrow:checkout"    // 柜台办理借阅、代读者续借
+	PermBorrowCheckin     = "borrow:checkin"     // 柜台办理归还
+	PermBorrowManage      = "borrow:manage"      // 修改借阅记录、执行逾期扫描
+	PermReservationManage = "reservation:manage" // 查看预约队列、取消任意预约
+	PermReviewModerate    = "review:moderate"    // 隐藏或显示评论
+	PermLoanPolicyManage  = "loan_policy:manage" // 管理借阅规则
+	PermFineRead          = "fine:read"          // 查看罚金报表和读者流水
+	PermFineManage        = "fine:manage"        // 记录罚款、减免、退款
+	PermAPIKeyManage      = "api_key:manage"     // 签发、轮换、吊销API密钥
+	PermAuditRead         = "audit:read"         // 查询审计记录、校验审计链
+)
+
+// Permissions 全部权限及说明
+var Permissions = map[string]string{
+	PermUserRead:          "查看用户列表",
+	PermUserManage:        "修改用户角色、状态，删除用户",
+	PermRoleManage:        "编辑角色的权限",
+	PermBookWrite:         "新增、修改图书和副本",
+	PermCopyRead:          "扫码查询副本",
+	PermBorrowRead:        "查看全部借阅记录",
+	PermBorrowCheckout:    "柜台办理借阅、代读者续借",
+	PermBorrowCheckin:     "柜台办理归还",
+	PermBorrowManage:      "修改借阅记录、执行逾期扫描",
+	PermReservationManage: "查看预约队列、取消任意预约",
+	PermReviewModerate:    "隐藏或显示评论",
+	PermLoanPolicyManage:  "管理借阅规则",
+	PermFineRead:          "查看罚金报表和读者流水",
+	PermFineManage:        "记录罚款、减免、退款",
+	PermAPIKeyManage:      "签发、轮换、吊销API密钥",
+	PermAuditRead:         "查询审计记录、校验审计链",
+}
+
+// RolePermission 角色权限
+// @Description 角色拥有的权限，管理员角色固定拥有全部权限，不在此表中维护
+type RolePermission struct {
+	ID        uint      `gorm:"primarykey" json:"id"` // 记录ID
+	CreatedAt time.Time `json:"created_at"`           // 创建时间
+
+	Role       string `gorm:"type:varchar(32);not null;uniqueIndex:idx_role_permission" json:"role"`       // 角色
+	Permission string `gorm:"type:varchar(64);not null;uniqueIndex:idx_role_permission" json:"permission"` // 权限名称
+}
+
+// RoleSeed 已写入默认权限的角色。默认权限只写入一次，之后即使管理员移除了全部权限也不再写入
+type RoleSeed struct {
+	Role      string    `gorm:"type:varchar(32);primaryKey" json:"role"` // 角色
+	CreatedAt time.Time `json:"created_at"`                              // 写入时间
+}
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+)
+
+// RecoveryCode 双因素认证恢复码
+// @Description 无法使用验证器App时代替TOTP验证码登录，每个恢复码只能使用一次
+type RecoveryCode struct {
+	ID        uint      `gorm:"primarykey" json:"id"` // 记录ID
+	CreatedAt time.Time `json:"created_at"`           // 创建时间
+
+	UserID   uint       `gorm:"not null;index" json:"user_id"`   // 用户ID
+	CodeHash string     `gorm:"type:char(64);not null" json:"-"` // 恢复码的SHA-256摘要
+	UsedAt   *time.Time `gorm:"type:datetime" json:"used_at"`    // 使用时间，为空表示未使用
+}
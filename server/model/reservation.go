@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Reservation 图书预约模型
+// @Description 预约信息
+type Reservation struct {
+	ID        uint           `gorm:"primarykey" json:"id"`                                                                                          // 预约ID
+	CreatedAt time.Time      `json:"created_at"`                                                                                                    // 创建时间
+	UpdatedAt time.Time      `json:"updated_at"`                                                                                                    // 更新时间
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggertype:"string" format:"date-time" example:"2024-01-01T00:00:00+08:00"` // 删除时间
+
+	UserID     uint       `gorm:"not null;index" json:"user_id"`                                                   // 用户ID
+	BookID     uint       `gorm:"not null;index:idx_reservation_book_status" json:"book_id"`                       // 图书ID
+	Status     int        `gorm:"type:tinyint;default:1;not null;index:idx_reservation_book_status" json:"status"` // 状态 1-排队中 2-待取书 3-已完成 4-已取消 5-已过期
+	CopyID     *uint      `gorm:"index" json:"copy_id"`                                                            // 到书后为读者保留的副本ID
+	ReadyAt    *time.Time `gorm:"type:datetime" json:"ready_at"`                                                   // 到书时间（开始为该用户保留）
+	ExpireAt   *time.Time `gorm:"type:datetime;index" json:"expire_at"`                                            // 取书截止时间
+	FinishedAt *time.Time `gorm:"type:datetime" json:"finished_at"`                                                // 完成/取消/过期时间
+	Remark     string     `gorm:"type:varchar(256)" json:"remark"`                                                 // 备注
+
+	User User `gorm:"foreignKey:UserID" json:"user"` // 用户信息
+	Book Book `gorm:"foreignKey:BookID" json:"book"` // 图书信息
+}
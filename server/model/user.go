@@ -6,6 +6,16 @@ import (
 	"gorm.io/gorm"
 )
 
+// 用户角色
+const (
+	RoleUser      = "user"      // 普通读者
+	RoleLibrarian = "librarian" // 馆员，在柜台办理借还
+	RoleAdmin     = "admin"     // 管理员，拥有全部权限
+)
+
+// Roles 全部角色
+var Roles = []string{RoleUser, RoleLibrarian, RoleAdmin}
+
 // User 用户模型
 // @Description 用户信息
 type User struct {
@@ -15,12 +25,18 @@ type User struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggertype:"string" format:"date-time" example:"2024-01-01T00:00:00+08:00"` // 删除时间
 
 	Username    string    `gorm:"type:varchar(32);uniqueIndex;not null" json:"username"` // 用户名
-	Password    string    `gorm:"type:varchar(128);not null" json:"-"`                   // 密码
-	Salt        string    `gorm:"type:varchar(128);not null" json:"-"`                   // 密码盐值
+	Password    string    `gorm:"type:varchar(128);not null" json:"-"`                   // 密码哈希（argon2id/bcrypt编码串，旧账号为md5）
+	Salt        string    `gorm:"type:varchar(128);not null" json:"-"`                   // 旧版md5密码的盐值，新格式哈希自带盐值，此处为空
 	Nickname    string    `gorm:"type:varchar(32)" json:"nickname"`                      // 昵称
 	Email       string    `gorm:"type:varchar(128);uniqueIndex" json:"email"`            // 邮箱
 	Phone       string    `gorm:"type:varchar(20)" json:"phone"`                         // 手机号
-	Role        string    `gorm:"type:varchar(32);default:0;not null" json:"role"`       // 角色 user-普通用户 admin-管理员
+	Role        string    `gorm:"type:varchar(32);default:0;not null" json:"role"`       // 角色 user-普通用户 librarian-馆员 admin-管理员
 	Status      int       `gorm:"type:tinyint;default:1;not null" json:"status"`         // 状态 2-禁用 1-启用
 	LastLoginAt time.Time `gorm:"type:datetime" json:"last_login_at"`                    // 最后登录时间
+
+	EmailVerifiedAt *time.Time `gorm:"type:datetime" json:"email_verified_at"` // 邮箱验证时间，为空表示未验证，修改邮箱后需重新验证
+
+	TOTPSecret    string     `gorm:"type:varchar(255)" json:"-"`           // 加密保存的TOTP密钥，未启用时为待确认的密钥
+	TOTPEnabledAt *time.Time `gorm:"type:datetime" json:"totp_enabled_at"` // 启用双因素认证的时间，为空表示未启用
+	TOTPLastStep  int64      `gorm:"default:0;not null" json:"-"`          // 最近一次通过验证的时间步，同一验证码不能重复使用
 }
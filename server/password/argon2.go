@@ -0,0 +1,113 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id 默认参数，参考 RFC 9106 的第二推荐配置
+const (
+	defaultMemory      = 64 * 1024
+	defaultIterations  = 3
+	defaultParallelism = 2
+	argon2SaltLen      = 16
+	argon2KeyLen       = 32
+)
+
+type argon2Hasher struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+func newArgon2Hasher(opts Options) *argon2Hasher {
+	h := &argon2Hasher{
+		memory:      opts.Memory,
+		iterations:  opts.Iterations,
+		parallelism: opts.Parallelism,
+	}
+	if h.memory == 0 {
+		h.memory = defaultMemory
+	}
+	if h.iterations == 0 {
+		h.iterations = defaultIterations
+	}
+	if h.parallelism == 0 {
+		h.parallelism = defaultParallelism
+	}
+	return h
+}
+
+// Hash 生成 PHC 格式的哈希串：$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func (h *argon2Hasher) Hash(plain string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(plain), salt, h.iterations, h.memory, h.parallelism, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.memory, h.iterations, h.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify 校验密码
+func (h *argon2Hasher) Verify(plain, encoded string) (bool, error) {
+	return verify(plain, encoded)
+}
+
+// NeedsRehash 哈希串不是argon2id或参数与当前配置不同时需要重新哈希
+func (h *argon2Hasher) NeedsRehash(encoded string) bool {
+	p, _, _, err := decodeArgon2(encoded)
+	if err != nil {
+		return true
+	}
+	return p.memory != h.memory || p.iterations != h.iterations || p.parallelism != h.parallelism
+}
+
+// decodeArgon2 解析 PHC 格式的 argon2id 哈希串
+func decodeArgon2(encoded string) (*argon2Hasher, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, nil, nil, ErrMalformedHash
+	}
+	if version != argon2.Version {
+		return nil, nil, nil, ErrMalformedHash
+	}
+
+	p := &argon2Hasher{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return nil, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrMalformedHash
+	}
+	return p, salt, key, nil
+}
+
+// verifyArgon2 使用哈希串中记录的参数重新计算并比较
+func verifyArgon2(plain, encoded string) (bool, error) {
+	p, salt, key, err := decodeArgon2(encoded)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(plain), salt, p.iterations, p.memory, p.parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
@@ -0,0 +1,85 @@
+package password
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrUnknownAlgorithm 未知的哈希算法
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+	// ErrMalformedHash 哈希串格式错误
+	ErrMalformedHash = errors.New("malformed password hash")
+)
+
+// 支持的哈希算法
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+// Options 哈希参数，零值字段使用默认值
+type Options struct {
+	Algorithm   string // argon2id 或 bcrypt
+	Memory      uint32 // argon2id 内存开销（KiB）
+	Iterations  uint32 // argon2id 迭代次数
+	Parallelism uint8  // argon2id 并行度
+	BcryptCost  int    // bcrypt 计算开销
+}
+
+// Hasher 密码哈希接口。Hash 生成自描述的哈希串（包含算法、参数和盐值），
+// Verify 根据哈希串自身的算法校验，因此切换算法后旧哈希仍然可以验证。
+// NeedsRehash 在哈希串不是当前算法和参数生成的（包括旧版md5哈希）时返回true
+type Hasher interface {
+	Hash(plain string) (string, error)
+	Verify(plain, encoded string) (bool, error)
+	NeedsRehash(encoded string) bool
+}
+
+// New 根据参数创建哈希器
+func New(opts Options) (Hasher, error) {
+	switch opts.Algorithm {
+	case "", Argon2id:
+		return newArgon2Hasher(opts), nil
+	case Bcrypt:
+		return newBcryptHasher(opts), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, opts.Algorithm)
+	}
+}
+
+// MustNew 与 New 相同，但算法不存在时panic，用于启动阶段
+func MustNew(opts Options) Hasher {
+	h, err := New(opts)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// verify 按哈希串前缀选择算法校验
+func verify(plain, encoded string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return verifyArgon2(plain, encoded)
+	case isBcryptHash(encoded):
+		return verifyBcrypt(plain, encoded)
+	default:
+		return false, ErrMalformedHash
+	}
+}
+
+// IsLegacy 是否为旧版 md5(password + salt) 哈希。新格式的哈希串都以 $ 开头
+func IsLegacy(encoded string) bool {
+	return !strings.HasPrefix(encoded, "$")
+}
+
+// VerifyLegacy 校验旧版 md5(password + salt) 哈希，仅用于登录时升级旧账号
+func VerifyLegacy(plain, salt, encoded string) bool {
+	sum := md5.Sum([]byte(plain + salt))
+	return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(encoded)) == 1
+}
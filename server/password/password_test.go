@@ -0,0 +1,180 @@
+package password
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 测试使用较低的开销，避免拖慢测试
+var (
+	testArgon2 = Options{Algorithm: Argon2id, Memory: 8 * 1024, Iterations: 1, Parallelism: 1}
+	testBcrypt = Options{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost}
+)
+
+func TestHashAndVerify(t *testing.T) {
+	cases := []struct {
+		name   string
+		opts   Options
+		prefix string
+	}{
+		{name: "argon2id", opts: testArgon2, prefix: "$argon2id$v=19$m=8192,t=1,p=1$"},
+		{name: "bcrypt", opts: testBcrypt, prefix: "$2a$04$"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h := MustNew(c.opts)
+			encoded, err := h.Hash("s3cret-密码")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			if !strings.HasPrefix(encoded, c.prefix) {
+				t.Fatalf("Hash = %q, want prefix %q", encoded, c.prefix)
+			}
+			if IsLegacy(encoded) {
+				t.Fatalf("IsLegacy(%q) = true", encoded)
+			}
+
+			// 每次哈希使用不同的盐值
+			again, err := h.Hash("s3cret-密码")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			if again == encoded {
+				t.Fatalf("two hashes of the same password are equal: %q", encoded)
+			}
+
+			for _, v := range []struct {
+				plain string
+				want  bool
+			}{
+				{"s3cret-密码", true},
+				{"s3cret-密", false},
+				{"S3cret-密码", false},
+				{"", false},
+			} {
+				ok, err := h.Verify(v.plain, encoded)
+				if err != nil || ok != v.want {
+					t.Errorf("Verify(%q) = %v, %v, want %v", v.plain, ok, err, v.want)
+				}
+			}
+			if h.NeedsRehash(encoded) {
+				t.Errorf("NeedsRehash of a fresh hash = true")
+			}
+		})
+	}
+}
+
+// TestVerifyOtherAlgorithm 切换算法后，旧算法生成的哈希仍可校验，但需要重新哈希
+func TestVerifyOtherAlgorithm(t *testing.T) {
+	argon := MustNew(testArgon2)
+	bc := MustNew(testBcrypt)
+
+	argonHash, err := argon.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	bcryptHash, err := bc.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	if ok, err := bc.Verify("secret", argonHash); !ok || err != nil {
+		t.Errorf("bcrypt hasher Verify(argon2id hash) = %v, %v", ok, err)
+	}
+	if ok, err := argon.Verify("secret", bcryptHash); !ok || err != nil {
+		t.Errorf("argon2id hasher Verify(bcrypt hash) = %v, %v", ok, err)
+	}
+	if !bc.NeedsRehash(argonHash) || !argon.NeedsRehash(bcryptHash) {
+		t.Errorf("NeedsRehash of a hash from the other algorithm = false")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argonHash, err := MustNew(testArgon2).Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	bcryptHash, err := MustNew(testBcrypt).Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	stronger := testArgon2
+	stronger.Iterations = 2
+	costlier := testBcrypt
+	costlier.BcryptCost = bcrypt.MinCost + 1
+
+	cases := []struct {
+		name    string
+		opts    Options
+		encoded string
+		want    bool
+	}{
+		{name: "argon2id same parameters", opts: testArgon2, encoded: argonHash, want: false},
+		{name: "argon2id changed parameters", opts: stronger, encoded: argonHash, want: true},
+		{name: "bcrypt same cost", opts: testBcrypt, encoded: bcryptHash, want: false},
+		{name: "bcrypt changed cost", opts: costlier, encoded: bcryptHash, want: true},
+		{name: "legacy md5 for argon2id", opts: testArgon2, encoded: "5f4dcc3b5aa765d61d8327deb882cf99", want: true},
+		{name: "legacy md5 for bcrypt", opts: testBcrypt, encoded: "5f4dcc3b5aa765d61d8327deb882cf99", want: true},
+		{name: "malformed", opts: testArgon2, encoded: "$argon2id$garbage", want: true},
+	}
+	for _, c := range cases {
+		if got := MustNew(c.opts).NeedsRehash(c.encoded); got != c.want {
+			t.Errorf("%s: NeedsRehash = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	h := MustNew(testArgon2)
+	for _, encoded := range []string{
+		"",
+		"$argon2id$v=19$m=8192,t=1,p=1$c2FsdA",
+		"$argon2id$v=18$m=8192,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=8192,t=1,p=1$!!!$a2V5",
+		"$argon2id$v=19$m=8192,t=1,p=1$c2FsdA$",
+		"$2a$04$short",
+		"$scrypt$whatever",
+	} {
+		if ok, err := h.Verify("secret", encoded); ok || !errors.Is(err, ErrMalformedHash) {
+			t.Errorf("Verify(%q) = %v, %v, want ErrMalformedHash", encoded, ok, err)
+		}
+	}
+}
+
+func TestLegacy(t *testing.T) {
+	sum := md5.Sum([]byte("secret" + "pepper"))
+	encoded := hex.EncodeToString(sum[:])
+
+	if !IsLegacy(encoded) {
+		t.Fatalf("IsLegacy(%q) = false", encoded)
+	}
+	if !VerifyLegacy("secret", "pepper", encoded) {
+		t.Errorf("VerifyLegacy with the right password and salt = false")
+	}
+	if VerifyLegacy("secret", "salt", encoded) {
+		t.Errorf("VerifyLegacy with a wrong salt = true")
+	}
+	if VerifyLegacy("Secret", "pepper", encoded) {
+		t.Errorf("VerifyLegacy with a wrong password = true")
+	}
+}
+
+func TestNewUnknownAlgorithm(t *testing.T) {
+	if _, err := New(Options{Algorithm: "md5"}); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Fatalf("New(md5) err = %v, want ErrUnknownAlgorithm", err)
+	}
+	h, err := New(Options{})
+	if err != nil {
+		t.Fatalf("New(default): %v", err)
+	}
+	if _, ok := h.(*argon2Hasher); !ok {
+		t.Fatalf("default hasher = %T, want argon2id", h)
+	}
+}
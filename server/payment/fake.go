@@ -0,0 +1,89 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrInvalidAmount 金额无效
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// FakeProvider 本地开发测试用的支付渠道，所有收款立即成功，数据仅保存在内存中
+type FakeProvider struct {
+	mu       sync.Mutex
+	seq      int
+	payments map[string]float64 // 流水号 -> 可退金额
+	charges  map[string]*charge // 幂等键 -> 首次收款
+}
+
+// charge 按幂等键记录的收款
+type charge struct {
+	userID uint
+	amount float64
+	result *Result
+}
+
+// NewFakeProvider 创建测试支付渠道
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		payments: make(map[string]float64),
+		charges:  make(map[string]*charge),
+	}
+}
+
+// Name 渠道名称
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// Charge 收款，幂等键相同的重复请求返回首次收款的流水号
+func (p *FakeProvider) Charge(req *ChargeRequest) (*Result, error) {
+	if req.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if req.IdempotencyKey != "" {
+		if c, ok := p.charges[req.IdempotencyKey]; ok {
+			if c.userID != req.UserID || c.amount != req.Amount {
+				return nil, ErrIdempotencyConflict
+			}
+			return c.result, nil
+		}
+	}
+
+	p.seq++
+	ref := fmt.Sprintf("fake_pay_%d_%d", req.UserID, p.seq)
+	p.payments[ref] = req.Amount
+	result := &Result{Reference: ref}
+	if req.IdempotencyKey != "" {
+		p.charges[req.IdempotencyKey] = &charge{userID: req.UserID, amount: req.Amount, result: result}
+	}
+	return result, nil
+}
+
+// Refund 退款，金额不能超过原收款的剩余可退金额
+func (p *FakeProvider) Refund(req *RefundRequest) (*Result, error) {
+	if req.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	remaining, ok := p.payments[req.Reference]
+	if !ok {
+		// 进程重启后内存数据丢失，测试渠道直接视为可退
+		remaining = req.Amount
+	}
+	if req.Amount > remaining {
+		return nil, ErrInvalidAmount
+	}
+	p.payments[req.Reference] = remaining - req.Amount
+
+	p.seq++
+	return &Result{Reference: fmt.Sprintf("fake_refund_%d", p.seq)}, nil
+}
@@ -0,0 +1,75 @@
+package payment
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFakeProviderChargeIdempotent(t *testing.T) {
+	p := NewFakeProvider()
+
+	first, err := p.Charge(&ChargeRequest{UserID: 1, Amount: 5, IdempotencyKey: "k1"})
+	if err != nil {
+		t.Fatalf("Charge: %v", err)
+	}
+	again, err := p.Charge(&ChargeRequest{UserID: 1, Amount: 5, IdempotencyKey: "k1"})
+	if err != nil {
+		t.Fatalf("Charge retry: %v", err)
+	}
+	if again.Reference != first.Reference {
+		t.Fatalf("retry reference = %s, want %s", again.Reference, first.Reference)
+	}
+
+	other, err := p.Charge(&ChargeRequest{UserID: 1, Amount: 5})
+	if err != nil {
+		t.Fatalf("Charge: %v", err)
+	}
+	if other.Reference == first.Reference {
+		t.Fatal("charge without idempotency key reused an earlier reference")
+	}
+
+	conflicts := []*ChargeRequest{
+		{UserID: 1, Amount: 6, IdempotencyKey: "k1"},
+		{UserID: 2, Amount: 5, IdempotencyKey: "k1"},
+	}
+	for _, req := range conflicts {
+		if _, err := p.Charge(req); !errors.Is(err, ErrIdempotencyConflict) {
+			t.Fatalf("Charge(%+v) err = %v, want ErrIdempotencyConflict", req, err)
+		}
+	}
+}
+
+func TestFakeProviderRefund(t *testing.T) {
+	p := NewFakeProvider()
+
+	paid, err := p.Charge(&ChargeRequest{UserID: 1, Amount: 5})
+	if err != nil {
+		t.Fatalf("Charge: %v", err)
+	}
+
+	tests := []struct {
+		amount  float64
+		wantErr error
+	}{
+		{0, ErrInvalidAmount},
+		{3, nil},
+		{2.5, ErrInvalidAmount}, // 只剩2可退
+		{2, nil},
+		{0.01, ErrInvalidAmount},
+	}
+	for _, tt := range tests {
+		_, err := p.Refund(&RefundRequest{Reference: paid.Reference, Amount: tt.amount})
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("Refund(%.2f) err = %v, want %v", tt.amount, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if p, err := New("fake"); err != nil || p.Name() != "fake" {
+		t.Fatalf("New(fake) = %v, %v", p, err)
+	}
+	if _, err := New("alipay"); !errors.Is(err, ErrUnknownProvider) {
+		t.Fatalf("New(alipay) err = %v, want ErrUnknownProvider", err)
+	}
+}
@@ -0,0 +1,60 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnknownProvider 未知的支付渠道
+	ErrUnknownProvider = errors.New("unknown payment provider")
+	// ErrIdempotencyConflict 同一幂等键的重复请求参数不一致
+	ErrIdempotencyConflict = errors.New("idempotency key reused with different parameters")
+)
+
+// ChargeRequest 收款请求
+type ChargeRequest struct {
+	UserID      uint    // 付款用户ID
+	Amount      float64 // 金额
+	Description string  // 说明
+	// IdempotencyKey 幂等键，相同的键重复请求时返回首次收款的结果，不会重复扣款
+	IdempotencyKey string
+}
+
+// RefundRequest 退款请求
+type RefundRequest struct {
+	Reference string  // 原收款流水号
+	Amount    float64 // 退款金额
+	Reason    string  // 退款原因
+}
+
+// Result 支付渠道处理结果
+type Result struct {
+	Reference string // 支付渠道流水号
+}
+
+// Provider 支付渠道接口，新的渠道实现该接口并在 New 中注册
+type Provider interface {
+	Name() string
+	Charge(req *ChargeRequest) (*Result, error)
+	Refund(req *RefundRequest) (*Result, error)
+}
+
+// New 根据名称创建支付渠道
+func New(name string) (Provider, error) {
+	switch name {
+	case "", "fake":
+		return NewFakeProvider(), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+}
+
+// MustNew 与 New 相同，但渠道不存在时panic，用于启动阶段
+func MustNew(name string) Provider {
+	p, err := New(name)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
@@ -0,0 +1,295 @@
+// Package pinyin 将汉字转换为不带声调的拼音，用于按全拼和首字母检索书名、作者。
+// 多音字按全部读音组合生成多个结果，组合数有上限
+package pinyin
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxVariants 一段文字最多生成的全拼（或首字母）组合数，多音字较多时只保留排在前面的组合
+const MaxVariants = 16
+
+//go:embed dict.txt
+var dictData string
+
+var (
+	dictOnce sync.Once
+	dict     map[rune][]string
+)
+
+// loadDict 解析内嵌的拼音表，首次使用时加载
+func loadDict() {
+	dict = make(map[rune][]string, 21000)
+	for _, line := range strings.Split(dictData, "\n") {
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		r := []rune(fields[0])
+		if len(r) != 1 {
+			continue
+		}
+		dict[r[0]] = fields[1:]
+	}
+}
+
+// Readings 返回汉字不带声调的读音，常用读音在前；不是汉字或拼音表中没有时返回 nil
+func Readings(r rune) []string {
+	dictOnce.Do(loadDict)
+	return dict[r]
+}
+
+// unit 参与转换的一个字或一个字母数字串
+type unit struct {
+	start, end int      // 在原文中的字节偏移
+	options    []string // 汉字的读音，字母数字串为其小写形式
+	han        bool
+}
+
+// units 切分文字：汉字逐字取读音，连续的字母和数字作为一个整体，其他字符（标点、空白和拼音表中没有的字）忽略
+func units(text string) []unit {
+	var us []unit
+	wordStart := -1
+	flush := func(end int) {
+		if wordStart >= 0 {
+			us = append(us, unit{start: wordStart, end: end, options: []string{strings.ToLower(text[wordStart:end])}})
+			wordStart = -1
+		}
+	}
+	for i, r := range text {
+		if readings := Readings(r); readings != nil {
+			flush(i)
+			us = append(us, unit{start: i, end: i + len(string(r)), options: readings, han: true})
+			continue
+		}
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if wordStart < 0 {
+				wordStart = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+	return us
+}
+
+// Convert 将文字转换为全拼和首字母，如 "红楼梦" 得到 ["hongloumeng"] 和 ["hlm"]。
+// 多音字的每个读音都会参与组合，如 "长安" 得到 ["changan" "zhangan"] 和 ["ca" "za"]。
+// 字母和数字原样保留（转为小写），其他字符忽略
+func Convert(text string) (full, initials []string) {
+	us := units(text)
+	if len(us) == 0 {
+		return nil, nil
+	}
+	fullSlots := make([][]string, len(us))
+	initialSlots := make([][]string, len(us))
+	for i, u := range us {
+		fullSlots[i] = u.options
+		if !u.han {
+			initialSlots[i] = u.options
+			continue
+		}
+		for _, o := range u.options {
+			if !contains(initialSlots[i], o[:1]) {
+				initialSlots[i] = append(initialSlots[i], o[:1])
+			}
+		}
+	}
+	return combine(fullSlots), combine(initialSlots)
+}
+
+// Index 生成存储用的拼音检索串：全拼和首字母的各个组合以空格分隔，
+// 可直接用 LIKE '%关键词%' 匹配。没有可转换的字符时返回空字符串
+func Index(text string) string {
+	full, initials := Convert(text)
+	if len(full) == 0 {
+		return ""
+	}
+	terms := full
+	for _, s := range initials {
+		if !contains(terms, s) {
+			terms = append(terms, s)
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// combine 按顺序拼接每个位置的候选，生成不超过 MaxVariants 个组合。
+// 全部取首选的组合在前，然后依次是改用一个、两个……非首选读音的组合，
+// 这样每个多音字的其他读音都能尽早出现在结果中
+func combine(slots [][]string) []string {
+	picks := make([]int, len(slots))
+	var results []string
+	emit := func() {
+		var b strings.Builder
+		for i, k := range picks {
+			b.WriteString(slots[i][k])
+		}
+		if s := b.String(); !contains(results, s) {
+			results = append(results, s)
+		}
+	}
+	// choose 从第 from 个位置起再选 n 个位置改用非首选候选
+	var choose func(from, n int)
+	choose = func(from, n int) {
+		if len(results) >= MaxVariants {
+			return
+		}
+		if n == 0 {
+			emit()
+			return
+		}
+		for i := from; i < len(slots); i++ {
+			for k := 1; k < len(slots[i]); k++ {
+				picks[i] = k
+				choose(i+1, n-1)
+				picks[i] = 0
+			}
+		}
+	}
+	for n := 0; n <= len(slots) && len(results) < MaxVariants; n++ {
+		before := len(results)
+		choose(0, n)
+		if n > 0 && len(results) == before {
+			break
+		}
+	}
+	return results
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize 将用户输入的拼音规范化为小写且不带声调的字母串：去掉声调符号和声调数字（如 hong2lou2），
+// ü 写作 v，去掉空格、隔音符号和连字符。包含其他字符（如汉字）时不是拼音，返回空字符串
+func Normalize(s string) string {
+	s = strings.NewReplacer("ü", "v", "Ü", "v", "ǖ", "v", "ǘ", "v", "ǚ", "v", "ǜ", "v").Replace(s)
+	var b strings.Builder
+	prevLetter := false
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			b.WriteRune(unicode.ToLower(r))
+			prevLetter = true
+			continue
+		case r >= '1' && r <= '5' && prevLetter:
+			// 声调数字
+		case r == ' ' || r == '\'' || r == '’' || r == '-' || r == '\t' || r == '　':
+		default:
+			return ""
+		}
+		prevLetter = false
+	}
+	return b.String()
+}
+
+// maxSyllableLen 最长音节的字母数，如 zhuang、chuang
+const maxSyllableLen = 6
+
+var (
+	syllablesOnce sync.Once
+	syllables     map[string]bool // 拼音表中的全部音节
+	prefixes      map[string]bool // 音节的全部开头（含音节本身），单个字母即可作为首字母的字母
+)
+
+func loadSyllables() {
+	syllables = make(map[string]bool)
+	prefixes = make(map[string]bool)
+	dictOnce.Do(loadDict)
+	for _, readings := range dict {
+		for _, o := range readings {
+			syllables[o] = true
+			for i := 1; i <= len(o); i++ {
+				prefixes[o[:i]] = true
+			}
+		}
+	}
+}
+
+// Plausible 判断规范化后的字母串是否可能是拼音输入：能依次切分为全拼音节或首字母，最后一段可以只是音节的开头，
+// 与 Match 的匹配规则一致。英文单词大多也能按首字母切分（如 go、python），该判断只能排除 iphone 之类
+// 明显不是拼音的输入，调用方应在普通匹配没有结果时才按拼音检索
+func Plausible(s string) bool {
+	if s == "" {
+		return false
+	}
+	syllablesOnce.Do(loadSyllables)
+	// ok[i] 表示 s[:i] 可以切分
+	ok := make([]bool, len(s)+1)
+	ok[0] = true
+	for i := 0; i < len(s); i++ {
+		if !ok[i] {
+			continue
+		}
+		if prefixes[s[i:]] {
+			return true
+		}
+		for j := i + 1; j <= len(s) && j-i <= maxSyllableLen; j++ {
+			if seg := s[i:j]; syllables[seg] || j == i+1 && prefixes[seg] {
+				ok[j] = true
+			}
+		}
+	}
+	return ok[len(s)]
+}
+
+// Match 查找文字中与拼音 query（已规范化）匹配的一段，返回其字节范围。
+// 每个字可以按全拼或首字母匹配，最后一个字允许只输入读音的开头，如 "honglm"、"hlmeng" 都能匹配 "红楼梦"
+func Match(text, query string) (start, end int, ok bool) {
+	if query == "" {
+		return 0, 0, false
+	}
+	us := units(text)
+	// failed 记录从第 i 个字开始无法匹配剩余 n 个字母的情况，避免重复搜索
+	failed := make(map[[2]int]bool)
+	var match func(i int, q string) int
+	match = func(i int, q string) int {
+		if q == "" {
+			return i - 1
+		}
+		if i >= len(us) || failed[[2]int{i, len(q)}] {
+			return -1
+		}
+		u := us[i]
+		for _, o := range u.options {
+			switch {
+			case strings.HasPrefix(q, o):
+				if last := match(i+1, q[len(o):]); last >= 0 {
+					return last
+				}
+			case strings.HasPrefix(o, q):
+				return i
+			}
+			if u.han && q[0] == o[0] && len(o) > 1 {
+				if last := match(i+1, q[1:]); last >= 0 {
+					return last
+				}
+			}
+		}
+		failed[[2]int{i, len(q)}] = true
+		return -1
+	}
+	for i := range us {
+		if last := match(i, query); last >= 0 {
+			return us[i].start, us[last].end, true
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,182 @@
+package pinyin
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		text     string
+		full     []string
+		initials []string
+	}{
+		{"红楼梦", []string{"hongloumeng"}, []string{"hlm"}},
+		// 多音字的每个读音都参与组合，常用读音在前
+		{"重庆", []string{"zhongqing", "chongqing"}, []string{"zq", "cq"}},
+		{"长安", []string{"changan", "zhangan"}, []string{"ca", "za"}},
+		// 两个多音字：先是全部首选，再依次改用一个、两个非首选读音
+		{"重行", []string{"zhongxing", "chongxing", "zhonghang", "chonghang"}, []string{"zx", "cx", "zh", "ch"}},
+		// 字母和数字原样保留并转为小写，标点和空白忽略
+		{"Go语言编程（第2版）", []string{"goyuyanbianchengdi2ban"}, []string{"goyybcd2b"}},
+		{"C++ Primer 中文版", []string{"cprimerzhongwenban"}, []string{"cprimerzwb"}},
+		{"ABC", []string{"abc"}, []string{"abc"}},
+		{"", nil, nil},
+		{"，。！ ★", nil, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.text, func(t *testing.T) {
+			full, initials := Convert(c.text)
+			if !reflect.DeepEqual(full, c.full) || !reflect.DeepEqual(initials, c.initials) {
+				t.Fatalf("Convert(%q) = %q, %q, want %q, %q", c.text, full, initials, c.full, c.initials)
+			}
+		})
+	}
+}
+
+func TestIndex(t *testing.T) {
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"红楼梦", "hongloumeng hlm"},
+		{"重庆", "zhongqing chongqing zq cq"},
+		// 首字母与全拼相同时不重复
+		{"ABC 123", "abc123"},
+		{"三体3", "santi3 st3"},
+		{"", ""},
+		{"…", ""},
+	}
+	for _, c := range cases {
+		if got := Index(c.text); got != c.want {
+			t.Errorf("Index(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"hongloumeng", "hongloumeng"},
+		{"HongLouMeng", "hongloumeng"},
+		{"hóng lóu mèng", "hongloumeng"},
+		{"hong2lou2meng4", "hongloumeng"},
+		{"xi'an", "xian"},
+		{"Xi’an", "xian"},
+		{"lǜ", "lv"},
+		{"nü", "nv"},
+		{"NÜ3", "nv"},
+		{"chong-qing", "chongqing"},
+		{"zhong　qing", "zhongqing"},
+		// 不跟在字母后的数字不是声调
+		{"2hong", ""},
+		{"hong6", ""},
+		{"红楼meng", ""},
+		{"hong_lou", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if got := Normalize(c.in); got != c.want {
+			t.Errorf("Normalize(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestPlausible(t *testing.T) {
+	cases := []struct {
+		in   string
+		want bool
+	}{
+		{"hongloumeng", true},
+		{"hlm", true},
+		{"honglm", true},
+		{"chongqing", true},
+		{"cq", true},
+		// 最后一段只是音节的开头
+		{"hongloumen", true},
+		{"zhuan", true},
+		{"zhuang", true},
+		{"go", true},
+		{"iphone", false},
+		{"vvv", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := Plausible(c.in); got != c.want {
+			t.Errorf("Plausible(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		text  string
+		query string
+		want  string
+		ok    bool
+	}{
+		{"红楼梦", "hongloumeng", "红楼梦", true},
+		{"红楼梦", "hlm", "红楼梦", true},
+		{"红楼梦", "honglm", "红楼梦", true},
+		{"红楼梦", "hlmeng", "红楼梦", true},
+		{"红楼梦", "hongl", "红楼", true},
+		{"重庆森林", "chongqing", "重庆", true},
+		{"重庆森林", "cqsl", "重庆森林", true},
+		{"走进重庆", "zq", "重庆", true},
+		{"Go语言编程", "goyy", "Go语言", true},
+		{"红楼梦", "xiyou", "", false},
+		{"红楼梦", "", "", false},
+	}
+	for _, c := range cases {
+		start, end, ok := Match(c.text, c.query)
+		if ok != c.ok || ok && c.text[start:end] != c.want {
+			t.Errorf("Match(%q, %q) = %d, %d, %v, want %q", c.text, c.query, start, end, ok, c.want)
+		}
+	}
+}
+
+func TestCombineLimit(t *testing.T) {
+	// 6个位置各有两个候选，共64种组合，只保留 MaxVariants 个
+	slots := make([][]string, 6)
+	for i := range slots {
+		slots[i] = []string{"a", "b"}
+	}
+	got := combine(slots)
+	if len(got) != MaxVariants {
+		t.Fatalf("combine returned %d variants, want %d", len(got), MaxVariants)
+	}
+	// 全部首选在前，然后是只改用一个候选的6种，再是改用两个候选的组合
+	want := []string{"aaaaaa", "baaaaa", "abaaaa", "aabaaa", "aaabaa", "aaaaba", "aaaaab", "bbaaaa"}
+	if !reflect.DeepEqual(got[:len(want)], want) {
+		t.Fatalf("combine = %q, want prefix %q", got, want)
+	}
+	for _, s := range got {
+		if strings.Count(s, "b") > 2 {
+			t.Fatalf("combine = %q, variant %q uses more than two alternatives", got, s)
+		}
+	}
+
+	// 重复的组合只保留一次
+	if got := combine([][]string{{"x", "x"}, {"y"}}); !reflect.DeepEqual(got, []string{"xy"}) {
+		t.Fatalf("combine with duplicates = %q", got)
+	}
+
+	// 多音字很多的长文字同样受限，且每个多音字的其他读音都出现在结果中
+	text := strings.Repeat("重", 5)
+	full, initials := Convert(text)
+	if len(full) != MaxVariants || len(initials) != MaxVariants {
+		t.Fatalf("Convert(%q) = %d, %d variants, want %d", text, len(full), len(initials), MaxVariants)
+	}
+	for i := 0; i < 5; i++ {
+		want := strings.Repeat("zhong", i) + "chong" + strings.Repeat("zhong", 4-i)
+		if full[i+1] != want {
+			t.Fatalf("Convert(%q)[%d] = %q, want %q", text, i+1, full[i+1], want)
+		}
+	}
+	if Index("重") != "zhong chong z c" {
+		t.Fatalf("Index(重) = %q", Index("重"))
+	}
+}
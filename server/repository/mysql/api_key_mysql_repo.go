@@ -0,0 +1,107 @@
+package mysql
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"library/model"
+)
+
+// APIKeyRepository API密钥仓库接口
+type APIKeyRepository interface {
+	Create(key *model.APIKey) error
+	GetByID(id uint) (*model.APIKey, error)
+	LockByID(id uint) (*model.APIKey, error)
+	GetByPrefix(prefix string) (*model.APIKey, error)
+	List(userID uint) ([]*model.APIKey, error)
+	Updates(id uint, fields map[string]interface{}) error
+	TouchLastUsed(id uint, at time.Time, ip string) error
+	Transaction(fc func(tx *gorm.DB) error) error
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository 创建API密钥仓库实例
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+// Transaction wraps the function in a database transaction
+func (r *apiKeyRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// Create 创建API密钥
+func (r *apiKeyRepository) Create(key *model.APIKey) error {
+	key.CreatedAt = r.db.NowFunc()
+	key.UpdatedAt = r.db.NowFunc()
+	return r.db.Create(key).Error
+}
+
+// GetByID 根据ID获取API密钥
+func (r *apiKeyRepository) GetByID(id uint) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.db.First(&key, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// LockByID 根据ID获取API密钥并加行锁（SELECT ... FOR UPDATE），需在事务中使用
+func (r *apiKeyRepository) LockByID(id uint) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&key, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetByPrefix 根据密钥的公开部分获取API密钥
+func (r *apiKeyRepository) GetByPrefix(prefix string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.db.Where("prefix = ?", prefix).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// List 获取API密钥列表，userID 为0时返回全部用户的密钥
+func (r *apiKeyRepository) List(userID uint) ([]*model.APIKey, error) {
+	var keys []*model.APIKey
+	query := r.db.Model(&model.APIKey{})
+	if userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.Order("id DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Updates 更新指定字段，可以写入零值（如清空过期时间）
+func (r *apiKeyRepository) Updates(id uint, fields map[string]interface{}) error {
+	fields["updated_at"] = r.db.NowFunc()
+	return r.db.Model(&model.APIKey{}).Where("id = ?", id).Updates(fields).Error
+}
+
+// TouchLastUsed 记录最近一次使用的时间和IP，不修改 updated_at
+func (r *apiKeyRepository) TouchLastUsed(id uint, at time.Time, ip string) error {
+	return r.db.Model(&model.APIKey{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"last_used_at": at, "last_used_ip": ip}).Error
+}
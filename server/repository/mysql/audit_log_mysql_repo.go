@@ -0,0 +1,200 @@
+package mysql
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"library/model"
+)
+
+// auditChainHeadID 链头记录的ID，链头只有一行
+const auditChainHeadID = 1
+
+// AuditLogFilter 审计记录查询条件，零值表示不限
+type AuditLogFilter struct {
+	ActorID    uint
+	Action     string
+	TargetType string
+	TargetID   uint
+	RequestID  string
+	StartTime  *time.Time
+	EndTime    *time.Time
+	Page       int
+	PageSize   int
+}
+
+// AuditLogRepository 审计记录仓库接口。审计记录只能追加，不提供修改和删除
+type AuditLogRepository interface {
+	Create(log *model.AuditLog) error
+	Seal(limit int) (int, error)
+	CountPending() (int64, error)
+	List(filter *AuditLogFilter) ([]*model.AuditLog, int64, error)
+	Walk(batchSize int, fn func(logs []*model.AuditLog) error) error
+	GetChainHead() (*model.AuditChainHead, error)
+	Transaction(fc func(tx *gorm.DB) error) error
+}
+
+type auditLogRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditLogRepository 创建审计记录仓库实例
+func NewAuditLogRepository(db *gorm.DB) AuditLogRepository {
+	return &auditLogRepository{db: db}
+}
+
+// Transaction wraps the function in a database transaction
+func (r *auditLogRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// Create 写入一条尚未封链的审计记录，不读写链头，与业务变更在同一事务中提交时不会与其他事务互相等待
+func (r *auditLogRepository) Create(log *model.AuditLog) error {
+	// 数据库保存的时间精度可能低于Go，截断到秒，保证封链和校验时读出的时间一致
+	log.CreatedAt = r.db.NowFunc().Truncate(time.Second)
+	log.Seq = 0
+	log.PrevHash = ""
+	log.Hash = ""
+	return r.db.Create(log).Error
+}
+
+// Seal 将最多 limit 条已提交、尚未封链的记录按ID顺序接到链尾，返回封链的数量。
+// 锁定链头后再读取待封链的记录，并发调用时依次执行，不会重复封链。
+// 事务提交晚于更大ID的记录时，该记录会在下一批接到链尾，链中的顺序以序号为准而不是ID
+func (r *auditLogRepository) Seal(limit int) (int, error) {
+	sealed := 0
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// 链头不存在时创建
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.AuditChainHead{ID: auditChainHeadID, UpdatedAt: tx.NowFunc()}).Error; err != nil {
+			return err
+		}
+		var head model.AuditChainHead
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&head, auditChainHeadID).Error; err != nil {
+			return err
+		}
+
+		// 不对待封链的记录加锁读，避免间隙锁阻塞业务事务写入新的审计记录；链头的行锁已保证只有一个封链事务
+		var logs []*model.AuditLog
+		if err := tx.Where("seq = ?", 0).Order("id ASC").Limit(limit).Find(&logs).Error; err != nil {
+			return err
+		}
+		for _, log := range logs {
+			log.Seq = head.LastSeq + 1
+			log.PrevHash = head.LastHash
+			log.Hash = log.ComputeHash()
+			result := tx.Model(&model.AuditLog{}).Where("id = ? AND seq = ?", log.ID, 0).Updates(map[string]interface{}{
+				"seq":       log.Seq,
+				"prev_hash": log.PrevHash,
+				"hash":      log.Hash,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("audit log %d was sealed concurrently", log.ID)
+			}
+			head.LastID = log.ID
+			head.LastSeq = log.Seq
+			head.LastHash = log.Hash
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+
+		sealed = len(logs)
+		return tx.Model(&model.AuditChainHead{}).Where("id = ?", auditChainHeadID).Updates(map[string]interface{}{
+			"last_id":    head.LastID,
+			"last_seq":   head.LastSeq,
+			"last_hash":  head.LastHash,
+			"updated_at": tx.NowFunc(),
+		}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return sealed, nil
+}
+
+// CountPending 统计尚未封链的记录数
+func (r *auditLogRepository) CountPending() (int64, error) {
+	var count int64
+	err := r.db.Model(&model.AuditLog{}).Where("seq = ?", 0).Count(&count).Error
+	return count, err
+}
+
+// List 按条件分页查询审计记录，按时间倒序
+func (r *auditLogRepository) List(filter *AuditLogFilter) ([]*model.AuditLog, int64, error) {
+	var logs []*model.AuditLog
+	var total int64
+
+	query := r.db.Model(&model.AuditLog{})
+	if filter.ActorID > 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		// 以 . 结尾时按前缀匹配，如 book. 匹配全部图书操作
+		if filter.Action[len(filter.Action)-1] == '.' {
+			query = query.Where("action LIKE ?", filter.Action+"%")
+		} else {
+			query = query.Where("action = ?", filter.Action)
+		}
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID > 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.StartTime != nil {
+		query = query.Where("created_at >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("created_at <= ?", *filter.EndTime)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (filter.Page - 1) * filter.PageSize
+	if err := query.Order("id DESC").Offset(offset).Limit(filter.PageSize).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
+
+// Walk 按序号顺序分批读取已封链的审计记录，用于校验哈希链
+func (r *auditLogRepository) Walk(batchSize int, fn func(logs []*model.AuditLog) error) error {
+	var lastSeq uint64
+	for {
+		var logs []*model.AuditLog
+		if err := r.db.Where("seq > ?", lastSeq).Order("seq ASC").Limit(batchSize).Find(&logs).Error; err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		if err := fn(logs); err != nil {
+			return err
+		}
+		lastSeq = logs[len(logs)-1].Seq
+	}
+}
+
+// GetChainHead 获取链头，尚未写入过带摘要的记录时返回 nil
+func (r *auditLogRepository) GetChainHead() (*model.AuditChainHead, error) {
+	var heads []*model.AuditChainHead
+	if err := r.db.Where("id = ?", auditChainHeadID).Limit(1).Find(&heads).Error; err != nil {
+		return nil, err
+	}
+	if len(heads) == 0 {
+		return nil, nil
+	}
+	return heads[0], nil
+}
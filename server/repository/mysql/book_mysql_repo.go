@@ -3,7 +3,10 @@ package mysql
 import (
 	"errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"library/isbn"
 	"library/model"
+	"library/pinyin"
 )
 
 type BookRepository interface {
@@ -11,9 +14,17 @@ type BookRepository interface {
 	Update( book *model.Book) error
 	Delete( id uint) error
 	GetByID( id uint) (*model.Book, error)
+	LockByID(id uint) (*model.Book, error)
+	GetByIDs(ids []uint) ([]*model.Book, error)
 	GetByISBN( isbn string) (*model.Book, error)
 	List( params *model.SearchParams) ([]*model.Book, int64, error)
-	UpdateStock( id uint, available int) error
+	SyncStock(id uint) error
+	ListISBNs() ([]*model.Book, error)
+	UpdateISBN(id uint, code string) error
+	Merge(fromID, toID uint) error
+	SearchPinyin(keyword, category string, limit int) ([]*model.Book, error)
+	ListPinyin(afterID uint, limit int) ([]*model.Book, error)
+	UpdatePinyin(id uint, titlePinyin, authorPinyin string) error
 	Transaction(fc func(tx *gorm.DB) error) error
 }
 
@@ -61,10 +72,21 @@ func (r *bookRepository) GetByID( id uint) (*model.Book, error) {
 	return &book, nil
 }
 
-// GetByISBN 根据ISBN获取图书
-func (r *bookRepository) GetByISBN( isbn string) (*model.Book, error) {
+// GetByIDs 根据ID批量获取图书，不存在或已删除的图书不返回
+func (r *bookRepository) GetByIDs(ids []uint) ([]*model.Book, error) {
+	var books []*model.Book
+	if len(ids) == 0 {
+		return books, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&books).Error
+	return books, err
+}
+
+// LockByID 根据ID获取图书并加行锁（SELECT ... FOR UPDATE），需在事务中使用。
+// 同一本书的借还操作通过该锁串行执行
+func (r *bookRepository) LockByID(id uint) (*model.Book, error) {
 	var book model.Book
-	err := r.db.Where("isbn = ?", isbn).First(&book).Error
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -74,20 +96,62 @@ func (r *bookRepository) GetByISBN( isbn string) (*model.Book, error) {
 	return &book, nil
 }
 
-// List 获取图书列表（支持模糊查询和分页）
+// GetByISBN 根据ISBN获取图书，ISBN-10、ISBN-13和带连字符的写法视为同一本书
+func (r *bookRepository) GetByISBN(code string) (*model.Book, error) {
+	var book model.Book
+	// 兼容尚未规范化为ISBN-13的旧数据，无法解析时按原值精确匹配
+	variants, err := isbn.Variants(code)
+	if err != nil {
+		variants = []string{code}
+	}
+	err = r.db.Where("isbn IN ?", variants).Order("id ASC").First(&book).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &book, nil
+}
+
+// List 获取图书列表（支持模糊查询和分页）。
+// 关键词没有匹配到图书且可能是拼音（如 hlm、honglou）时，再按书名和作者的全拼、首字母匹配。
+// 英文关键词大多也能按首字母切分为拼音，放在普通匹配之后，避免 go、c 之类的关键词匹配到无关的中文书名
 func (r *bookRepository) List( params *model.SearchParams) ([]*model.Book, int64, error) {
+	if params.Keyword == "" {
+		return r.list(params, nil)
+	}
+
+	variants, err := isbn.Variants(params.Keyword)
+	if err != nil {
+		variants = []string{params.Keyword}
+	}
+	// 关键词为ISBN时，ISBN-10和带连字符的写法也能匹配规范化后的ISBN-13
+	cond := r.db.Where("title LIKE ? OR author LIKE ? OR publisher LIKE ? OR isbn LIKE ? OR isbn IN ?",
+		"%"+params.Keyword+"%",
+		"%"+params.Keyword+"%",
+		"%"+params.Keyword+"%",
+		"%"+params.Keyword+"%",
+		variants)
+	books, total, err := r.list(params, cond)
+	if err != nil || total > 0 {
+		return books, total, err
+	}
+
+	if py := pinyin.Normalize(params.Keyword); py != "" && pinyin.Plausible(py) {
+		return r.list(params, r.pinyinCondition(py))
+	}
+	return books, total, nil
+}
+
+// list 按条件分页查询图书，cond 为 nil 时不限关键词
+func (r *bookRepository) list(params *model.SearchParams, cond *gorm.DB) ([]*model.Book, int64, error) {
 	var books []*model.Book
 	var total int64
-	
+
 	db := r.db.Model(&model.Book{})
-	
-	// 模糊查询条件
-	if params.Keyword != "" {
-		db = db.Where("title LIKE ? OR author LIKE ? OR publisher LIKE ? OR isbn LIKE ?",
-			"%"+params.Keyword+"%",
-			"%"+params.Keyword+"%",
-			"%"+params.Keyword+"%",
-			"%"+params.Keyword+"%")
+	if cond != nil {
+		db = db.Where(cond)
 	}
 	
 	// 分类筛选
@@ -119,9 +183,92 @@ func (r *bookRepository) List( params *model.SearchParams) ([]*model.Book, int64
 	return books, total, nil
 }
 
-// UpdateStock 更新图书库存
-func (r *bookRepository) UpdateStock( id uint, available int) error {
+// SyncStock 根据副本重新统计图书的总数量和可借数量
+// 总数量不含遗失和注销的副本，可借数量为在架副本数
+func (r *bookRepository) SyncStock(id uint) error {
+	total := r.db.Model(&model.Copy{}).Select("COUNT(*)").
+		Where("book_id = ? AND status IN ?", id, []int{1, 2, 3, 4})
+	available := r.db.Model(&model.Copy{}).Select("COUNT(*)").
+		Where("book_id = ? AND status = ?", id, 1)
 	return r.db.Model(&model.Book{}).
 		Where("id = ?", id).
-		Update("available", available).Error
+		Updates(map[string]interface{}{
+			"total":      total,
+			"available":  available,
+			"updated_at": r.db.NowFunc(),
+		}).Error
+}
+
+// ListISBNs 按ID顺序获取全部图书（含已删除）的ID和ISBN，用于ISBN规范化
+func (r *bookRepository) ListISBNs() ([]*model.Book, error) {
+	var books []*model.Book
+	err := r.db.Unscoped().Select("id", "isbn", "deleted_at").Order("id ASC").Find(&books).Error
+	return books, err
+}
+
+// UpdateISBN 修改图书的ISBN
+func (r *bookRepository) UpdateISBN(id uint, code string) error {
+	return r.db.Model(&model.Book{}).Unscoped().
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"isbn":       code,
+			"updated_at": r.db.NowFunc(),
+		}).Error
+}
+
+// SearchPinyin 按书名或作者的拼音检索串匹配图书，keyword 为规范化后的拼音，按ID顺序最多返回 limit 本
+func (r *bookRepository) SearchPinyin(keyword, category string, limit int) ([]*model.Book, error) {
+	var books []*model.Book
+	db := r.db.Where(r.pinyinCondition(keyword))
+	if category != "" {
+		db = db.Where("category = ?", category)
+	}
+	err := db.Order("id ASC").Limit(limit).Find(&books).Error
+	return books, err
+}
+
+// pinyinCondition 拼音检索串的匹配条件，keyword 为规范化后的拼音。
+// MySQL使用ngram全文索引 idx_books_pinyin：两个字母以上按短语匹配，效果与子串匹配相同；单个字母短于ngram的切分长度，按前缀匹配。
+// 其他数据库（测试使用的SQLite）没有全文索引，按子串匹配
+func (r *bookRepository) pinyinCondition(keyword string) *gorm.DB {
+	if r.db.Dialector.Name() != "mysql" {
+		return r.db.Where("title_pinyin LIKE ? OR author_pinyin LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+	expr := `"` + keyword + `"`
+	if len(keyword) < 2 {
+		expr = keyword + "*"
+	}
+	return r.db.Where("MATCH(title_pinyin, author_pinyin) AGAINST(? IN BOOLEAN MODE)", expr)
+}
+
+// ListPinyin 按ID顺序获取ID大于 afterID 的至多 limit 本图书的书名、作者和拼音检索串，用于分批重新生成拼音
+func (r *bookRepository) ListPinyin(afterID uint, limit int) ([]*model.Book, error) {
+	var books []*model.Book
+	err := r.db.Select("id", "title", "author", "title_pinyin", "author_pinyin").
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&books).Error
+	return books, err
+}
+
+// UpdatePinyin 修改图书的拼音检索串。拼音由书名和作者生成，不更新修改时间
+func (r *bookRepository) UpdatePinyin(id uint, titlePinyin, authorPinyin string) error {
+	return r.db.Model(&model.Book{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"title_pinyin":  titlePinyin,
+			"author_pinyin": authorPinyin,
+		}).Error
+}
+
+// Merge 将 fromID 的副本、借阅、预约、评论和导入记录转移到 toID，并物理删除 fromID，需在事务中使用。
+// 调用方负责随后重新统计 toID 的库存
+func (r *bookRepository) Merge(fromID, toID uint) error {
+	for _, m := range []interface{}{&model.Copy{}, &model.Borrow{}, &model.Reservation{}, &model.Review{}, &model.ImportJobRow{}} {
+		if err := r.db.Model(m).Unscoped().Where("book_id = ?", fromID).Update("book_id", toID).Error; err != nil {
+			return err
+		}
+	}
+	return r.db.Unscoped().Delete(&model.Book{}, fromID).Error
 }
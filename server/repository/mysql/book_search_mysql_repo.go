@@ -0,0 +1,180 @@
+package mysql
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"library/model"
+	"library/search"
+)
+
+const (
+	// bookFullTextColumns 全文索引 idx_books_fulltext 包含的列，MATCH 中的列必须与索引定义一致
+	bookFullTextColumns = "title, author, publisher, summary"
+	// maxSearchCandidates 按MySQL相关度取出参与BM25重新排序的候选图书上限，超出部分不返回，总数也按该上限截断
+	maxSearchCandidates = 1000
+	// searchStatsTTL 图书总数和子句文档频率的缓存时间，只影响相关度的权重，不要求精确
+	searchStatsTTL = 5 * time.Minute
+	// maxSearchStats 缓存的计数条数上限
+	maxSearchStats = 10000
+)
+
+// searchStatsEntry 缓存的计数
+type searchStatsEntry struct {
+	count    int
+	loadedAt time.Time
+}
+
+// bookSearchEngine 基于MySQL全文索引（ngram解析器）的检索引擎。
+// MySQL按布尔模式筛选出匹配的图书并给出初步的相关度，候选图书再按BM25重新排序
+type bookSearchEngine struct {
+	db *gorm.DB
+
+	mu    sync.Mutex
+	stats map[string]searchStatsEntry // 布尔查询 -> 匹配的图书数，空字符串为图书总数
+}
+
+// NewBookSearchEngine 创建MySQL全文检索引擎
+func NewBookSearchEngine(db *gorm.DB) search.Engine {
+	return &bookSearchEngine{db: db, stats: make(map[string]searchStatsEntry)}
+}
+
+// Name 引擎名称
+func (e *bookSearchEngine) Name() string {
+	return search.EngineMySQL
+}
+
+// Index 全文索引由MySQL在写入时维护，无需同步
+func (e *bookSearchEngine) Index(docs ...*search.Document) error {
+	return nil
+}
+
+// Remove 全文索引由MySQL在写入时维护，无需同步
+func (e *bookSearchEngine) Remove(ids ...uint) error {
+	return nil
+}
+
+// Rebuild 全文索引由MySQL在写入时维护，无需重建
+func (e *bookSearchEngine) Rebuild(source search.Source) error {
+	return nil
+}
+
+// Search 检索图书。只取按MySQL相关度排在前 maxSearchCandidates 的图书重新排序，
+// 匹配的图书更多时总数按该上限返回，与能翻到的页数一致
+func (e *bookSearchEngine) Search(req *search.Request) (*search.Result, error) {
+	q := req.Query
+	match := "MATCH(" + bookFullTextColumns + ") AGAINST(? IN BOOLEAN MODE)"
+	expr := booleanQuery(q.Clauses...)
+
+	db := e.db.Model(&model.Book{}).Where(match, expr)
+	if req.Category != "" {
+		db = db.Where("category = ?", req.Category)
+	}
+	var books []*model.Book
+	err := db.Select("id", "category", "title", "author", "publisher", "summary").
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: match + " DESC, id ASC", Vars: []interface{}{expr}, WithoutParentheses: true}}).
+		Limit(maxSearchCandidates).
+		Find(&books).Error
+	if err != nil {
+		return nil, err
+	}
+	result := &search.Result{Total: int64(len(books))}
+	if len(books) == 0 || req.Offset >= len(books) {
+		return result, nil
+	}
+
+	stats, err := e.searchStats(q, len(books))
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]*search.Document, len(books))
+	for i, book := range books {
+		docs[i] = search.BookDocument(book)
+	}
+	stats.AvgLen = search.AverageLengths(docs)
+
+	hits := search.Rank(docs, q, stats)[req.Offset:]
+	if req.Limit > 0 && len(hits) > req.Limit {
+		hits = hits[:req.Limit]
+	}
+	byID := make(map[uint]*search.Document, len(docs))
+	for _, doc := range docs {
+		byID[doc.ID] = doc
+	}
+	for _, hit := range hits {
+		hit.Highlights = search.Highlight(byID[hit.ID], q)
+	}
+	result.Hits = hits
+	return result, nil
+}
+
+// searchStats 统计计算IDF所需的图书总数和每个子句匹配的图书数，字段平均长度由调用方按候选图书估算。
+// 只有一个子句时IDF对所有候选图书相同，不影响排序，不查询数据库；多个子句时计数按 searchStatsTTL 缓存
+func (e *bookSearchEngine) searchStats(q *search.Query, candidates int) (*search.Stats, error) {
+	stats := &search.Stats{Docs: candidates, DocFreq: make([]int, len(q.Clauses))}
+	if len(q.Clauses) == 1 {
+		stats.DocFreq[0] = candidates
+		return stats, nil
+	}
+
+	docs, err := e.cachedCount("")
+	if err != nil {
+		return nil, err
+	}
+	stats.Docs = docs
+	for i, c := range q.Clauses {
+		if stats.DocFreq[i], err = e.cachedCount(booleanQuery(c)); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// cachedCount 返回匹配布尔查询的图书数，expr 为空时返回图书总数
+func (e *bookSearchEngine) cachedCount(expr string) (int, error) {
+	e.mu.Lock()
+	entry, ok := e.stats[expr]
+	e.mu.Unlock()
+	if ok && time.Since(entry.loadedAt) < searchStatsTTL {
+		return entry.count, nil
+	}
+
+	db := e.db.Model(&model.Book{})
+	if expr != "" {
+		db = db.Where("MATCH("+bookFullTextColumns+") AGAINST(? IN BOOLEAN MODE)", expr)
+	}
+	var count int64
+	if err := db.Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// 查询词不受限制，缓存满时整体清空，避免无限增长
+	if len(e.stats) >= maxSearchStats {
+		e.stats = make(map[string]searchStatsEntry)
+	}
+	e.stats[expr] = searchStatsEntry{count: int(count), loadedAt: time.Now()}
+	return int(count), nil
+}
+
+// booleanQuery 将子句转换为布尔模式的查询，每个子句都必须匹配。
+// 普通词和短语放在引号中，由ngram解析器切分后按短语匹配；前缀查询的最后一个词使用 * 通配
+func booleanQuery(clauses ...*search.Clause) string {
+	var parts []string
+	for _, c := range clauses {
+		if c.Prefix {
+			last := len(c.Terms) - 1
+			for _, term := range c.Terms[:last] {
+				parts = append(parts, `+"`+term+`"`)
+			}
+			parts = append(parts, "+"+c.Terms[last]+"*")
+			continue
+		}
+		parts = append(parts, `+"`+strings.ReplaceAll(c.Text, `"`, " ")+`"`)
+	}
+	return strings.Join(parts, " ")
+}
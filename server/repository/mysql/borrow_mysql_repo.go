@@ -14,7 +14,14 @@ type BorrowRepository interface {
 	GetByUserAndBookID( userID, bookID uint) (*model.Borrow, error)
 	List( params *model.SearchParams) ([]*model.Borrow, int64, error)
 	GetUserBorrows( userID uint, status int) ([]*model.Borrow, error)
+	GetUserActiveBorrows(userID uint) ([]*model.Borrow, error)
+	GetActiveByCopyID(copyID uint) (*model.Borrow, error)
+	GetActiveWithoutCopy(bookID uint) ([]*model.Borrow, error)
 	GetOverdueBorrows() ([]*model.Borrow, error)
+	MarkOverdue(id uint, fine float64, now time.Time) (bool, error)
+	UpdateFine(id uint, fine float64) error
+	CountUserOverdue(userID uint) (int64, error)
+	SumUserFines(userID uint) (float64, error)
 	Transaction(fc func(tx *gorm.DB) error) error
 }
 
@@ -52,6 +59,7 @@ func (r *borrowRepository) GetByID( id uint) (*model.Borrow, error) {
 	err := r.db.
 		Preload("User").
 		Preload("Book").
+		Preload("Copy").
 		First(&borrow, id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -62,10 +70,10 @@ func (r *borrowRepository) GetByID( id uint) (*model.Borrow, error) {
 	return &borrow, nil
 }
 
-// GetByUserAndBookID 根据用户ID和图书ID获取借阅记录
+// GetByUserAndBookID 根据用户ID和图书ID获取未归还（借阅中或已逾期）的借阅记录
 func (r *borrowRepository) GetByUserAndBookID(userID, bookID uint) (*model.Borrow, error) {
 	var borrow model.Borrow
-	err := r.db.Where("user_id = ? AND book_id = ?", userID, bookID).First(&borrow).Error
+	err := r.db.Where("user_id = ? AND book_id = ? AND status IN ?", userID, bookID, []int{1, 3}).First(&borrow).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -136,16 +144,99 @@ func (r *borrowRepository) GetUserBorrows( userID uint, status int) ([]*model.Bo
 	return borrows, nil
 }
 
-// GetOverdueBorrows 获取逾期的借阅记录
+// GetUserActiveBorrows 获取用户未归还（借阅中或已逾期）的借阅记录
+func (r *borrowRepository) GetUserActiveBorrows(userID uint) ([]*model.Borrow, error) {
+	var borrows []*model.Borrow
+	err := r.db.
+		Preload("Book").
+		Where("user_id = ? AND status IN ?", userID, []int{1, 3}).
+		Order("created_at DESC").
+		Find(&borrows).Error
+	if err != nil {
+		return nil, err
+	}
+	return borrows, nil
+}
+
+// GetActiveByCopyID 获取副本当前未归还的借阅记录
+func (r *borrowRepository) GetActiveByCopyID(copyID uint) (*model.Borrow, error) {
+	var borrow model.Borrow
+	err := r.db.Where("copy_id = ? AND status IN ?", copyID, []int{1, 3}).First(&borrow).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &borrow, nil
+}
+
+// GetActiveWithoutCopy 获取图书未关联副本的未归还借阅记录（副本功能上线前产生的借阅）
+func (r *borrowRepository) GetActiveWithoutCopy(bookID uint) ([]*model.Borrow, error) {
+	var borrows []*model.Borrow
+	err := r.db.
+		Where("book_id = ? AND copy_id = 0 AND status IN ?", bookID, []int{1, 3}).
+		Order("id ASC").
+		Find(&borrows).Error
+	if err != nil {
+		return nil, err
+	}
+	return borrows, nil
+}
+
+// GetOverdueBorrows 获取逾期的借阅记录（包括已到期但尚未标记为逾期的）
 func (r *borrowRepository) GetOverdueBorrows() ([]*model.Borrow, error) {
 	var borrows []*model.Borrow
 	err := r.db.
 		Preload("User").
 		Preload("Book").
-		Where("status = ? AND due_date < ?", 1, time.Now()).
+		Where("status IN ? AND due_date < ?", []int{1, 3}, time.Now()).
 		Find(&borrows).Error
 	if err != nil {
 		return nil, err
 	}
 	return borrows, nil
 }
+
+// MarkOverdue 将借阅记录标记为已逾期并更新累计罚金，返回是否更新。
+// 只更新在 now 时仍逾期未还的记录，扫描期间已归还或已续借到期日之后的记录保持不变
+func (r *borrowRepository) MarkOverdue(id uint, fine float64, now time.Time) (bool, error) {
+	result := r.db.Model(&model.Borrow{}).
+		Where("id = ? AND status IN ? AND due_date < ?", id, []int{1, 3}, now).
+		Updates(map[string]interface{}{
+			"status":     3,
+			"fine":       fine,
+			"updated_at": r.db.NowFunc(),
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// UpdateFine 修改借阅上正在累计的罚金，金额为0时同样写入
+func (r *borrowRepository) UpdateFine(id uint, fine float64) error {
+	return r.db.Model(&model.Borrow{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"fine":       fine,
+			"updated_at": r.db.NowFunc(),
+		}).Error
+}
+
+// CountUserOverdue 统计用户逾期未还的借阅数量
+func (r *borrowRepository) CountUserOverdue(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Borrow{}).
+		Where("user_id = ?", userID).
+		Where("status = ? OR (status = ? AND due_date < ?)", 3, 1, time.Now()).
+		Count(&count).Error
+	return count, err
+}
+
+// SumUserFines 统计用户未归还借阅上正在累计的罚金（归还后罚金计入罚金账户）
+func (r *borrowRepository) SumUserFines(userID uint) (float64, error) {
+	var total float64
+	err := r.db.Model(&model.Borrow{}).
+		Where("user_id = ? AND status IN ?", userID, []int{1, 3}).
+		Select("COALESCE(SUM(fine), 0)").
+		Scan(&total).Error
+	return total, err
+}
@@ -0,0 +1,165 @@
+package mysql
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"library/model"
+)
+
+// CopyRepository 馆藏副本仓库接口
+type CopyRepository interface {
+	Create(item *model.Copy) error
+	Update(item *model.Copy) error
+	Delete(id uint) error
+	GetByID(id uint) (*model.Copy, error)
+	GetByBarcode(barcode string) (*model.Copy, error)
+	BarcodeExists(barcode string) (bool, error)
+	GetBookCopies(bookID uint, status int) ([]*model.Copy, error)
+	GetFirstAvailable(bookID uint) (*model.Copy, error)
+	UpdateStatus(id uint, status int) error
+	UpdateStatusIf(id uint, from, to int) (bool, error)
+	CountByBook(bookID uint, statuses ...int) (int64, error)
+	CountAllByBook(bookID uint) (int64, error)
+	Transaction(fc func(tx *gorm.DB) error) error
+}
+
+type copyRepository struct {
+	db *gorm.DB
+}
+
+// NewCopyRepository 创建副本仓库实例
+func NewCopyRepository(db *gorm.DB) CopyRepository {
+	return &copyRepository{db: db}
+}
+
+// Transaction wraps the function in a database transaction
+func (r *copyRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// Create 创建副本
+func (r *copyRepository) Create(item *model.Copy) error {
+	item.CreatedAt = r.db.NowFunc()
+	item.UpdatedAt = r.db.NowFunc()
+	return r.db.Omit("Book").Create(item).Error
+}
+
+// Update 更新副本信息
+func (r *copyRepository) Update(item *model.Copy) error {
+	item.UpdatedAt = r.db.NowFunc()
+	return r.db.Omit("Book").Updates(item).Error
+}
+
+// Delete 删除副本（软删除）
+func (r *copyRepository) Delete(id uint) error {
+	return r.db.Delete(&model.Copy{}, id).Error
+}
+
+// GetByID 根据ID获取副本
+func (r *copyRepository) GetByID(id uint) (*model.Copy, error) {
+	var item model.Copy
+	err := r.db.First(&item, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetByBarcode 根据条码获取副本
+func (r *copyRepository) GetByBarcode(barcode string) (*model.Copy, error) {
+	var item model.Copy
+	err := r.db.Preload("Book").Where("barcode = ?", barcode).First(&item).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// BarcodeExists 条码是否已被使用，包括已删除的副本（条码的唯一索引不区分是否删除）
+func (r *copyRepository) BarcodeExists(barcode string) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&model.Copy{}).
+		Where("barcode = ?", barcode).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetBookCopies 获取图书的副本列表，status为0时返回全部
+func (r *copyRepository) GetBookCopies(bookID uint, status int) ([]*model.Copy, error) {
+	var copies []*model.Copy
+	db := r.db.Where("book_id = ?", bookID)
+	if status > 0 {
+		db = db.Where("status = ?", status)
+	}
+	err := db.Order("id ASC").Find(&copies).Error
+	if err != nil {
+		return nil, err
+	}
+	return copies, nil
+}
+
+// GetFirstAvailable 获取图书的第一个在架副本
+func (r *copyRepository) GetFirstAvailable(bookID uint) (*model.Copy, error) {
+	var item model.Copy
+	err := r.db.
+		Where("book_id = ? AND status = ?", bookID, 1).
+		Order("id ASC").
+		First(&item).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// UpdateStatus 更新副本状态
+func (r *copyRepository) UpdateStatus(id uint, status int) error {
+	return r.db.Model(&model.Copy{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"updated_at": r.db.NowFunc(),
+		}).Error
+}
+
+// UpdateStatusIf 仅当副本当前处于from状态时更新为to状态，返回是否更新成功。
+// 用于借出、保留副本时防止同一副本被并发分配两次
+func (r *copyRepository) UpdateStatusIf(id uint, from, to int) (bool, error) {
+	result := r.db.Model(&model.Copy{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]interface{}{
+			"status":     to,
+			"updated_at": r.db.NowFunc(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// CountByBook 统计图书处于指定状态的副本数量
+func (r *copyRepository) CountByBook(bookID uint, statuses ...int) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Copy{}).
+		Where("book_id = ? AND status IN ?", bookID, statuses).
+		Count(&count).Error
+	return count, err
+}
+
+// CountAllByBook 统计图书的全部副本数量（包括已删除的，用于生成条码序号）
+func (r *copyRepository) CountAllByBook(bookID uint) (int64, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&model.Copy{}).
+		Where("book_id = ?", bookID).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,91 @@
+package mysql
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"library/model"
+)
+
+// ExternalIdentityRepository 外部身份仓库接口
+type ExternalIdentityRepository interface {
+	Create(identity *model.ExternalIdentity) error
+	GetBySubject(provider, subject string) (*model.ExternalIdentity, error)
+	ListByUser(userID uint) ([]*model.ExternalIdentity, error)
+	ListByProvider(provider string) ([]*model.ExternalIdentity, error)
+	UpdateLogin(id uint, email string) error
+	Delete(id uint) error
+	Transaction(fc func(tx *gorm.DB) error) error
+}
+
+type externalIdentityRepository struct {
+	db *gorm.DB
+}
+
+// NewExternalIdentityRepository 创建外部身份仓库实例
+func NewExternalIdentityRepository(db *gorm.DB) ExternalIdentityRepository {
+	return &externalIdentityRepository{db: db}
+}
+
+// Transaction wraps the function in a database transaction
+func (r *externalIdentityRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// Create 关联外部身份
+func (r *externalIdentityRepository) Create(identity *model.ExternalIdentity) error {
+	identity.CreatedAt = r.db.NowFunc()
+	identity.UpdatedAt = r.db.NowFunc()
+	identity.LastLoginAt = r.db.NowFunc()
+	return r.db.Create(identity).Error
+}
+
+// GetBySubject 根据提供方和subject获取外部身份
+func (r *externalIdentityRepository) GetBySubject(provider, subject string) (*model.ExternalIdentity, error) {
+	var identity model.ExternalIdentity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// ListByUser 获取用户关联的全部外部身份
+func (r *externalIdentityRepository) ListByUser(userID uint) ([]*model.ExternalIdentity, error) {
+	var identities []*model.ExternalIdentity
+	err := r.db.Where("user_id = ?", userID).Order("id ASC").Find(&identities).Error
+	if err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// ListByProvider 获取某个提供方的全部外部身份
+func (r *externalIdentityRepository) ListByProvider(provider string) ([]*model.ExternalIdentity, error) {
+	var identities []*model.ExternalIdentity
+	err := r.db.Where("provider = ?", provider).Order("id ASC").Find(&identities).Error
+	if err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// UpdateLogin 记录通过外部身份登录的时间和提供方返回的邮箱
+func (r *externalIdentityRepository) UpdateLogin(id uint, email string) error {
+	now := r.db.NowFunc()
+	return r.db.Model(&model.ExternalIdentity{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"email":         email,
+			"last_login_at": now,
+			"updated_at":    now,
+		}).Error
+}
+
+// Delete 解除外部身份关联
+func (r *externalIdentityRepository) Delete(id uint) error {
+	return r.db.Delete(&model.ExternalIdentity{}, id).Error
+}
@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"gorm.io/gorm"
+	"library/search"
 )
 
 var (
@@ -17,6 +18,20 @@ type Factory interface {
 	GetReviewRepository() ReviewRepository
 	GetBorrowRepository() BorrowRepository
 	GetBookRepository() BookRepository
+	GetReservationRepository() ReservationRepository
+	GetLoanPolicyRepository() LoanPolicyRepository
+	GetJobRunRepository() JobRunRepository
+	GetFineRepository() FineRepository
+	GetCopyRepository() CopyRepository
+	GetAuditLogRepository() AuditLogRepository
+	GetRolePermissionRepository() RolePermissionRepository
+	GetMailOutboxRepository() MailOutboxRepository
+	GetRecoveryCodeRepository() RecoveryCodeRepository
+	GetExternalIdentityRepository() ExternalIdentityRepository
+	GetAPIKeyRepository() APIKeyRepository
+	GetImportJobRepository() ImportJobRepository
+	GetBookSearchEngine() search.Engine
+	GetUnitOfWork() UnitOfWork
 }
 
 // factory 实现Factory接口
@@ -26,6 +41,20 @@ type factory struct {
 	reviewRepo  ReviewRepository
 	borrowRepo  BorrowRepository
 	bookRepo    BookRepository
+	reservationRepo ReservationRepository
+	loanPolicyRepo  LoanPolicyRepository
+	jobRunRepo      JobRunRepository
+	fineRepo        FineRepository
+	copyRepo        CopyRepository
+	auditLogRepo    AuditLogRepository
+	rolePermissionRepo RolePermissionRepository
+	mailOutboxRepo     MailOutboxRepository
+	recoveryCodeRepo   RecoveryCodeRepository
+	externalIdentityRepo ExternalIdentityRepository
+	apiKeyRepo           APIKeyRepository
+	importJobRepo        ImportJobRepository
+	bookSearchEngine     search.Engine
+	uow             UnitOfWork
 	mu          sync.RWMutex
 }
 
@@ -103,3 +132,226 @@ func (f *factory) GetBookRepository() BookRepository {
 	return f.bookRepo
 }
 
+func (f *factory) GetReservationRepository() ReservationRepository {
+	f.mu.RLock()
+	if f.reservationRepo != nil {
+		defer f.mu.RUnlock()
+		return f.reservationRepo
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.reservationRepo == nil {
+		f.reservationRepo = NewReservationRepository(f.db)
+	}
+	return f.reservationRepo
+}
+
+func (f *factory) GetLoanPolicyRepository() LoanPolicyRepository {
+	f.mu.RLock()
+	if f.loanPolicyRepo != nil {
+		defer f.mu.RUnlock()
+		return f.loanPolicyRepo
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.loanPolicyRepo == nil {
+		f.loanPolicyRepo = NewLoanPolicyRepository(f.db)
+	}
+	return f.loanPolicyRepo
+}
+
+func (f *factory) GetJobRunRepository() JobRunRepository {
+	f.mu.RLock()
+	if f.jobRunRepo != nil {
+		defer f.mu.RUnlock()
+		return f.jobRunRepo
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.jobRunRepo == nil {
+		f.jobRunRepo = NewJobRunRepository(f.db)
+	}
+	return f.jobRunRepo
+}
+
+func (f *factory) GetFineRepository() FineRepository {
+	f.mu.RLock()
+	if f.fineRepo != nil {
+		defer f.mu.RUnlock()
+		return f.fineRepo
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fineRepo == nil {
+		f.fineRepo = NewFineRepository(f.db)
+	}
+	return f.fineRepo
+}
+
+func (f *factory) GetCopyRepository() CopyRepository {
+	f.mu.RLock()
+	if f.copyRepo != nil {
+		defer f.mu.RUnlock()
+		return f.copyRepo
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.copyRepo == nil {
+		f.copyRepo = NewCopyRepository(f.db)
+	}
+	return f.copyRepo
+}
+
+func (f *factory) GetAuditLogRepository() AuditLogRepository {
+	f.mu.RLock()
+	if f.auditLogRepo != nil {
+		defer f.mu.RUnlock()
+		return f.auditLogRepo
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.auditLogRepo == nil {
+		f.auditLogRepo = NewAuditLogRepository(f.db)
+	}
+	return f.auditLogRepo
+}
+
+func (f *factory) GetRolePermissionRepository() RolePermissionRepository {
+	f.mu.RLock()
+	if f.rolePermissionRepo != nil {
+		defer f.mu.RUnlock()
+		return f.rolePermissionRepo
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rolePermissionRepo == nil {
+		f.rolePermissionRepo = NewRolePermissionRepository(f.db)
+	}
+	return f.rolePermissionRepo
+}
+
+func (f *factory) GetMailOutboxRepository() MailOutboxRepository {
+	f.mu.RLock()
+	if f.mailOutboxRepo != nil {
+		defer f.mu.RUnlock()
+		return f.mailOutboxRepo
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.mailOutboxRepo == nil {
+		f.mailOutboxRepo = NewMailOutboxRepository(f.db)
+	}
+	return f.mailOutboxRepo
+}
+
+func (f *factory) GetRecoveryCodeRepository() RecoveryCodeRepository {
+	f.mu.RLock()
+	if f.recoveryCodeRepo != nil {
+		defer f.mu.RUnlock()
+		return f.recoveryCodeRepo
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.recoveryCodeRepo == nil {
+		f.recoveryCodeRepo = NewRecoveryCodeRepository(f.db)
+	}
+	return f.recoveryCodeRepo
+}
+
+func (f *factory) GetExternalIdentityRepository() ExternalIdentityRepository {
+	f.mu.RLock()
+	if f.externalIdentityRepo != nil {
+		defer f.mu.RUnlock()
+		return f.externalIdentityRepo
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.externalIdentityRepo == nil {
+		f.externalIdentityRepo = NewExternalIdentityRepository(f.db)
+	}
+	return f.externalIdentityRepo
+}
+
+func (f *factory) GetAPIKeyRepository() APIKeyRepository {
+	f.mu.RLock()
+	if f.apiKeyRepo != nil {
+		defer f.mu.RUnlock()
+		return f.apiKeyRepo
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.apiKeyRepo == nil {
+		f.apiKeyRepo = NewAPIKeyRepository(f.db)
+	}
+	return f.apiKeyRepo
+}
+
+func (f *factory) GetImportJobRepository() ImportJobRepository {
+	f.mu.RLock()
+	if f.importJobRepo != nil {
+		defer f.mu.RUnlock()
+		return f.importJobRepo
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.importJobRepo == nil {
+		f.importJobRepo = NewImportJobRepository(f.db)
+	}
+	return f.importJobRepo
+}
+
+func (f *factory) GetBookSearchEngine() search.Engine {
+	f.mu.RLock()
+	if f.bookSearchEngine != nil {
+		defer f.mu.RUnlock()
+		return f.bookSearchEngine
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.bookSearchEngine == nil {
+		f.bookSearchEngine = NewBookSearchEngine(f.db)
+	}
+	return f.bookSearchEngine
+}
+
+func (f *factory) GetUnitOfWork() UnitOfWork {
+	f.mu.RLock()
+	if f.uow != nil {
+		defer f.mu.RUnlock()
+		return f.uow
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.uow == nil {
+		f.uow = NewUnitOfWork(f.db)
+	}
+	return f.uow
+}
@@ -0,0 +1,175 @@
+package mysql
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"library/model"
+)
+
+// balanceExpr 余额计算表达式：罚款+退款-缴费-减免
+const balanceExpr = "COALESCE(SUM(CASE WHEN type IN (1, 4) THEN amount ELSE -amount END), 0)"
+
+// FineRepository 罚金流水仓库接口
+type FineRepository interface {
+	Create(tx *model.FineTransaction) error
+	GetByID(id uint) (*model.FineTransaction, error)
+	GetByIdempotencyKey(userID uint, key string) (*model.FineTransaction, error)
+	GetUserTransactions(userID uint, params *model.SearchParams) ([]*model.FineTransaction, int64, error)
+	GetBalance(userID uint) (float64, error)
+	SumRefunded(paymentID uint) (float64, error)
+	SumBorrowCharges(borrowID uint) (float64, error)
+	Report(params *model.SearchParams) ([]*model.FineSummary, int64, error)
+	Transaction(fc func(tx *gorm.DB) error) error
+}
+
+type fineRepository struct {
+	db *gorm.DB
+}
+
+// NewFineRepository 创建罚金流水仓库实例
+func NewFineRepository(db *gorm.DB) FineRepository {
+	return &fineRepository{db: db}
+}
+
+// Transaction wraps the function in a database transaction
+func (r *fineRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// Create 创建流水
+func (r *fineRepository) Create(tx *model.FineTransaction) error {
+	tx.CreatedAt = r.db.NowFunc()
+	tx.UpdatedAt = r.db.NowFunc()
+	return r.db.Omit("User").Create(tx).Error
+}
+
+// GetByID 根据ID获取流水
+func (r *fineRepository) GetByID(id uint) (*model.FineTransaction, error) {
+	var tx model.FineTransaction
+	err := r.db.First(&tx, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// GetByIdempotencyKey 根据幂等键获取用户的缴费流水
+func (r *fineRepository) GetByIdempotencyKey(userID uint, key string) (*model.FineTransaction, error) {
+	var tx model.FineTransaction
+	err := r.db.Where("user_id = ? AND idempotency_key = ?", userID, key).First(&tx).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// GetUserTransactions 获取用户的罚金流水（按时间倒序分页）
+func (r *fineRepository) GetUserTransactions(userID uint, params *model.SearchParams) ([]*model.FineTransaction, int64, error) {
+	var txs []*model.FineTransaction
+	var total int64
+
+	db := r.db.Model(&model.FineTransaction{}).Where("user_id = ?", userID)
+
+	// 时间范围查询
+	if params.StartTime != "" {
+		db = db.Where("created_at >= ?", params.StartTime)
+	}
+	if params.EndTime != "" {
+		db = db.Where("created_at <= ?", params.EndTime)
+	}
+
+	// 统计总数
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	offset := (params.Page - 1) * params.PageSize
+	err := db.Order("id DESC").Offset(offset).Limit(params.PageSize).Find(&txs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return txs, total, nil
+}
+
+// GetBalance 获取用户未缴罚金余额
+func (r *fineRepository) GetBalance(userID uint) (float64, error) {
+	var balance float64
+	err := r.db.Model(&model.FineTransaction{}).
+		Where("user_id = ?", userID).
+		Select(balanceExpr).
+		Scan(&balance).Error
+	return balance, err
+}
+
+// SumRefunded 统计某笔缴费已退款的金额
+func (r *fineRepository) SumRefunded(paymentID uint) (float64, error) {
+	var total float64
+	err := r.db.Model(&model.FineTransaction{}).
+		Where("ref_id = ? AND type = ?", paymentID, 4).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// SumBorrowCharges 统计借阅已记入的逾期罚款总额
+func (r *fineRepository) SumBorrowCharges(borrowID uint) (float64, error) {
+	var total float64
+	err := r.db.Model(&model.FineTransaction{}).
+		Where("borrow_id = ? AND type = ?", borrowID, 1).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// Report 按用户汇总罚金（支持按用户名模糊查询和分页，按余额倒序）
+func (r *fineRepository) Report(params *model.SearchParams) ([]*model.FineSummary, int64, error) {
+	var summaries []*model.FineSummary
+	var total int64
+
+	db := r.db.Table("fine_transactions AS f").
+		Joins("JOIN users AS u ON u.id = f.user_id")
+
+	if params.Keyword != "" {
+		db = db.Where("u.username LIKE ? OR u.nickname LIKE ?",
+			"%"+params.Keyword+"%",
+			"%"+params.Keyword+"%")
+	}
+	if params.StartTime != "" {
+		db = db.Where("f.created_at >= ?", params.StartTime)
+	}
+	if params.EndTime != "" {
+		db = db.Where("f.created_at <= ?", params.EndTime)
+	}
+
+	// 统计总数
+	if err := db.Distinct("f.user_id").Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	offset := (params.Page - 1) * params.PageSize
+	err := db.Select(`f.user_id AS user_id, u.username AS username,
+			COALESCE(SUM(CASE WHEN f.type = 1 THEN f.amount END), 0) AS charged,
+			COALESCE(SUM(CASE WHEN f.type = 2 THEN f.amount END), 0) AS paid,
+			COALESCE(SUM(CASE WHEN f.type = 3 THEN f.amount END), 0) AS waived,
+			COALESCE(SUM(CASE WHEN f.type = 4 THEN f.amount END), 0) AS refunded,
+			COALESCE(SUM(CASE WHEN f.type IN (1, 4) THEN f.amount ELSE -f.amount END), 0) AS balance`).
+		Group("f.user_id, u.username").
+		Order("balance DESC").
+		Offset(offset).Limit(params.PageSize).
+		Scan(&summaries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return summaries, total, nil
+}
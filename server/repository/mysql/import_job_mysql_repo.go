@@ -0,0 +1,207 @@
+package mysql
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"library/model"
+)
+
+// importRowBatchSize 批量写入数据行时每批的行数
+const importRowBatchSize = 200
+
+// ImportJobRepository 图书导入任务仓库接口
+type ImportJobRepository interface {
+	Create(job *model.ImportJob) error
+	CreateRows(rows []*model.ImportJobRow) error
+	GetByID(id uint) (*model.ImportJob, error)
+	List(params *model.SearchParams) ([]*model.ImportJob, int64, error)
+	ClaimNext(instance string, staleBefore time.Time) (*model.ImportJob, error)
+	UpdateStatus(id uint, from []int, fields map[string]interface{}) (bool, error)
+	PendingRows(jobID uint, limit int) ([]*model.ImportJobRow, error)
+	UpdateRow(row *model.ImportJobRow) error
+	WrittenRowsByISBN(jobID uint, isbn string, beforeRowNo int) ([]*model.ImportJobRow, error)
+	ListRows(jobID uint, action string, page, pageSize int) ([]*model.ImportJobRow, int64, error)
+	CountByAction(jobID uint) (map[string]int, error)
+	Transaction(fc func(tx *gorm.DB) error) error
+}
+
+type importJobRepository struct {
+	db *gorm.DB
+}
+
+// NewImportJobRepository 创建导入任务仓库实例
+func NewImportJobRepository(db *gorm.DB) ImportJobRepository {
+	return &importJobRepository{db: db}
+}
+
+// Transaction wraps the function in a database transaction
+func (r *importJobRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// Create 创建导入任务
+func (r *importJobRepository) Create(job *model.ImportJob) error {
+	job.CreatedAt = r.db.NowFunc()
+	job.UpdatedAt = r.db.NowFunc()
+	if job.Status == 0 {
+		job.Status = model.ImportStatusPending
+	}
+	return r.db.Create(job).Error
+}
+
+// CreateRows 批量写入数据行
+func (r *importJobRepository) CreateRows(rows []*model.ImportJobRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	now := r.db.NowFunc()
+	for _, row := range rows {
+		row.CreatedAt = now
+		row.UpdatedAt = now
+	}
+	return r.db.CreateInBatches(rows, importRowBatchSize).Error
+}
+
+// GetByID 获取导入任务
+func (r *importJobRepository) GetByID(id uint) (*model.ImportJob, error) {
+	var job model.ImportJob
+	err := r.db.First(&job, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// List 分页获取导入任务，最新的在前
+func (r *importJobRepository) List(params *model.SearchParams) ([]*model.ImportJob, int64, error) {
+	var jobs []*model.ImportJob
+	var total int64
+
+	db := r.db.Model(&model.ImportJob{})
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (params.Page - 1) * params.PageSize
+	err := db.Order("id DESC").Offset(offset).Limit(params.PageSize).Find(&jobs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return jobs, total, nil
+}
+
+// ClaimNext 领取一个排队中的任务，或执行实例已长时间无进展的任务，跳过已被其他实例锁定的记录
+func (r *importJobRepository) ClaimNext(instance string, staleBefore time.Time) (*model.ImportJob, error) {
+	var claimed *model.ImportJob
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var job model.ImportJob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND updated_at < ?)", model.ImportStatusPending, model.ImportStatusRunning, staleBefore).
+			Order("id ASC").
+			First(&job).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		now := tx.NowFunc()
+		job.Status = model.ImportStatusRunning
+		job.Instance = instance
+		job.UpdatedAt = now
+		if job.StartedAt == nil {
+			job.StartedAt = &now
+		}
+		if err := tx.Model(&job).Select("status", "instance", "started_at", "updated_at").Updates(&job).Error; err != nil {
+			return err
+		}
+		claimed = &job
+		return nil
+	})
+	return claimed, err
+}
+
+// UpdateStatus 仅当任务处于 from 中的某个状态时更新，返回是否更新成功。
+// 取消与执行结束可能同时发生，通过条件更新保证只有一方生效
+func (r *importJobRepository) UpdateStatus(id uint, from []int, fields map[string]interface{}) (bool, error) {
+	fields["updated_at"] = r.db.NowFunc()
+	result := r.db.Model(&model.ImportJob{}).
+		Where("id = ? AND status IN ?", id, from).
+		Updates(fields)
+	return result.RowsAffected > 0, result.Error
+}
+
+// PendingRows 按行号获取未处理的数据行
+func (r *importJobRepository) PendingRows(jobID uint, limit int) ([]*model.ImportJobRow, error) {
+	var rows []*model.ImportJobRow
+	err := r.db.Where("job_id = ? AND action = ?", jobID, "").
+		Order("row_no ASC").
+		Limit(limit).
+		Find(&rows).Error
+	return rows, err
+}
+
+// UpdateRow 保存数据行的处理结果
+func (r *importJobRepository) UpdateRow(row *model.ImportJobRow) error {
+	row.UpdatedAt = r.db.NowFunc()
+	return r.db.Model(row).Select("action", "book_id", "changes", "error", "updated_at").Updates(row).Error
+}
+
+// WrittenRowsByISBN 按行号顺序获取任务中在 beforeRowNo 之前已处理、新建或更新了该ISBN图书的数据行
+func (r *importJobRepository) WrittenRowsByISBN(jobID uint, isbn string, beforeRowNo int) ([]*model.ImportJobRow, error) {
+	var rows []*model.ImportJobRow
+	err := r.db.Where("job_id = ? AND isbn = ? AND row_no < ? AND action IN ?",
+		jobID, isbn, beforeRowNo, []string{model.ImportActionCreate, model.ImportActionUpdate}).
+		Order("row_no ASC").
+		Find(&rows).Error
+	return rows, err
+}
+
+// ListRows 按行号分页获取数据行，action 为空时不限
+func (r *importJobRepository) ListRows(jobID uint, action string, page, pageSize int) ([]*model.ImportJobRow, int64, error) {
+	var rows []*model.ImportJobRow
+	var total int64
+
+	db := r.db.Model(&model.ImportJobRow{}).Where("job_id = ?", jobID)
+	if action != "" {
+		db = db.Where("action = ?", action)
+	}
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := db.Order("row_no ASC").Offset(offset).Limit(pageSize).Find(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
+
+// CountByAction 按处理结果统计数据行，未处理的行计入空字符串
+func (r *importJobRepository) CountByAction(jobID uint) (map[string]int, error) {
+	var results []struct {
+		Action string
+		Count  int
+	}
+	err := r.db.Model(&model.ImportJobRow{}).
+		Select("action, COUNT(*) AS count").
+		Where("job_id = ?", jobID).
+		Group("action").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(results))
+	for _, res := range results {
+		counts[res.Action] = res.Count
+	}
+	return counts, nil
+}
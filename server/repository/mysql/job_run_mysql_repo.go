@@ -0,0 +1,56 @@
+package mysql
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"library/model"
+)
+
+// JobRunRepository 后台任务执行记录仓库接口
+type JobRunRepository interface {
+	Create(run *model.JobRun) error
+	Update(run *model.JobRun) error
+	GetLatest(name string) (*model.JobRun, error)
+	Transaction(fc func(tx *gorm.DB) error) error
+}
+
+type jobRunRepository struct {
+	db *gorm.DB
+}
+
+// NewJobRunRepository 创建任务执行记录仓库实例
+func NewJobRunRepository(db *gorm.DB) JobRunRepository {
+	return &jobRunRepository{db: db}
+}
+
+// Transaction wraps the function in a database transaction
+func (r *jobRunRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// Create 创建执行记录
+func (r *jobRunRepository) Create(run *model.JobRun) error {
+	run.CreatedAt = r.db.NowFunc()
+	run.UpdatedAt = r.db.NowFunc()
+	return r.db.Create(run).Error
+}
+
+// Update 更新执行记录
+func (r *jobRunRepository) Update(run *model.JobRun) error {
+	run.UpdatedAt = r.db.NowFunc()
+	return r.db.Save(run).Error
+}
+
+// GetLatest 获取任务最近一次执行记录
+func (r *jobRunRepository) GetLatest(name string) (*model.JobRun, error) {
+	var run model.JobRun
+	err := r.db.Where("name = ?", name).Order("id DESC").First(&run).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &run, nil
+}
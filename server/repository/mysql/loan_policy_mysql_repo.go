@@ -0,0 +1,126 @@
+package mysql
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"library/model"
+)
+
+// LoanPolicyRepository 借阅规则仓库接口
+type LoanPolicyRepository interface {
+	Create(policy *model.LoanPolicy) error
+	Update(policy *model.LoanPolicy) error
+	Delete(id uint) error
+	GetByID(id uint) (*model.LoanPolicy, error)
+	GetByScope(role, category string) (*model.LoanPolicy, error)
+	List(params *model.SearchParams) ([]*model.LoanPolicy, int64, error)
+	FindMatching(role, category string) ([]*model.LoanPolicy, error)
+	Transaction(fc func(tx *gorm.DB) error) error
+}
+
+type loanPolicyRepository struct {
+	db *gorm.DB
+}
+
+// NewLoanPolicyRepository 创建借阅规则仓库实例
+func NewLoanPolicyRepository(db *gorm.DB) LoanPolicyRepository {
+	return &loanPolicyRepository{db: db}
+}
+
+// Transaction wraps the function in a database transaction
+func (r *loanPolicyRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// Create 创建借阅规则
+func (r *loanPolicyRepository) Create(policy *model.LoanPolicy) error {
+	policy.CreatedAt = r.db.NowFunc()
+	policy.UpdatedAt = r.db.NowFunc()
+	return r.db.Create(policy).Error
+}
+
+// Update 更新借阅规则（零值字段同样会被写入，如罚金上限置0）
+func (r *loanPolicyRepository) Update(policy *model.LoanPolicy) error {
+	policy.UpdatedAt = r.db.NowFunc()
+	return r.db.Model(policy).Select("*").Omit("CreatedAt", "DeletedAt").Updates(policy).Error
+}
+
+// Delete 删除借阅规则（软删除）
+func (r *loanPolicyRepository) Delete(id uint) error {
+	return r.db.Delete(&model.LoanPolicy{}, id).Error
+}
+
+// GetByID 根据ID获取借阅规则
+func (r *loanPolicyRepository) GetByID(id uint) (*model.LoanPolicy, error) {
+	var policy model.LoanPolicy
+	err := r.db.First(&policy, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// GetByScope 根据角色和分类精确获取借阅规则
+func (r *loanPolicyRepository) GetByScope(role, category string) (*model.LoanPolicy, error) {
+	var policy model.LoanPolicy
+	err := r.db.Where("role = ? AND category = ?", role, category).First(&policy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// List 获取借阅规则列表（支持模糊查询和分页）
+func (r *loanPolicyRepository) List(params *model.SearchParams) ([]*model.LoanPolicy, int64, error) {
+	var policies []*model.LoanPolicy
+	var total int64
+
+	db := r.db.Model(&model.LoanPolicy{})
+
+	// 模糊查询条件
+	if params.Keyword != "" {
+		db = db.Where("name LIKE ? OR role LIKE ?",
+			"%"+params.Keyword+"%",
+			"%"+params.Keyword+"%")
+	}
+
+	// 分类筛选
+	if params.Category != "" {
+		db = db.Where("category = ?", params.Category)
+	}
+
+	// 统计总数
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	offset := (params.Page - 1) * params.PageSize
+	err := db.Order("role, category").Offset(offset).Limit(params.PageSize).Find(&policies).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return policies, total, nil
+}
+
+// FindMatching 获取适用于指定角色和分类的所有启用规则（包括通配规则）
+func (r *loanPolicyRepository) FindMatching(role, category string) ([]*model.LoanPolicy, error) {
+	var policies []*model.LoanPolicy
+	err := r.db.
+		Where("status = ?", 1).
+		Where("role = ? OR role = ''", role).
+		Where("category = ? OR category = ''", category).
+		Find(&policies).Error
+	if err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"library/model"
+)
+
+// MailOutboxRepository 待发邮件仓库接口
+type MailOutboxRepository interface {
+	Create(mail *model.MailOutbox) error
+	LockDue(now time.Time, limit int) ([]*model.MailOutbox, error)
+	Claim(id uint, now, until time.Time) (bool, error)
+	Update(mail *model.MailOutbox) error
+	Transaction(fc func(tx *gorm.DB) error) error
+}
+
+type mailOutboxRepository struct {
+	db *gorm.DB
+}
+
+// NewMailOutboxRepository 创建待发邮件仓库实例
+func NewMailOutboxRepository(db *gorm.DB) MailOutboxRepository {
+	return &mailOutboxRepository{db: db}
+}
+
+// Transaction wraps the function in a database transaction
+func (r *mailOutboxRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// Create 写入待发邮件，未设置下次尝试时间时立即可发
+func (r *mailOutboxRepository) Create(mail *model.MailOutbox) error {
+	mail.CreatedAt = r.db.NowFunc()
+	mail.UpdatedAt = r.db.NowFunc()
+	if mail.Status == 0 {
+		mail.Status = model.MailStatusPending
+	}
+	if mail.NextAttemptAt.IsZero() {
+		mail.NextAttemptAt = mail.CreatedAt
+	}
+	return r.db.Create(mail).Error
+}
+
+// LockDue 获取到期的待发邮件并加行锁，跳过已被其他实例锁定的记录，需在事务中使用
+func (r *mailOutboxRepository) LockDue(now time.Time, limit int) ([]*model.MailOutbox, error) {
+	var list []*model.MailOutbox
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND next_attempt_at <= ?", model.MailStatusPending, now).
+		Order("next_attempt_at ASC, id ASC").
+		Limit(limit).
+		Find(&list).Error
+	return list, err
+}
+
+// Claim 投递前占用邮件：仍为待发且已到期时把下次尝试时间推迟到 until，返回是否占用成功。
+// 按条件更新，其他实例已占用的邮件不会被重复发送
+func (r *mailOutboxRepository) Claim(id uint, now, until time.Time) (bool, error) {
+	result := r.db.Model(&model.MailOutbox{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", id, model.MailStatusPending, now).
+		Updates(map[string]interface{}{
+			"next_attempt_at": until,
+			"updated_at":      r.db.NowFunc(),
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// Update 保存投递结果
+func (r *mailOutboxRepository) Update(mail *model.MailOutbox) error {
+	mail.UpdatedAt = r.db.NowFunc()
+	return r.db.Model(mail).Select("status", "attempts", "next_attempt_at", "sent_at", "last_error", "updated_at").Updates(mail).Error
+}
@@ -0,0 +1,70 @@
+package mysql
+
+import (
+	"gorm.io/gorm"
+	"library/model"
+)
+
+// RecoveryCodeRepository 恢复码仓库接口
+type RecoveryCodeRepository interface {
+	ReplaceForUser(userID uint, hashes []string) error
+	Use(userID uint, hash string) (bool, error)
+	CountUnused(userID uint) (int64, error)
+	DeleteByUser(userID uint) error
+	Transaction(fc func(tx *gorm.DB) error) error
+}
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+// NewRecoveryCodeRepository 创建恢复码仓库实例
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: db}
+}
+
+// Transaction wraps the function in a database transaction
+func (r *recoveryCodeRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// ReplaceForUser 删除用户现有的恢复码并保存新的恢复码，调用方应在事务中执行
+func (r *recoveryCodeRepository) ReplaceForUser(userID uint, hashes []string) error {
+	if err := r.DeleteByUser(userID); err != nil {
+		return err
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+	now := r.db.NowFunc()
+	codes := make([]*model.RecoveryCode, 0, len(hashes))
+	for _, h := range hashes {
+		codes = append(codes, &model.RecoveryCode{CreatedAt: now, UserID: userID, CodeHash: h})
+	}
+	return r.db.Create(&codes).Error
+}
+
+// Use 将未使用的恢复码标记为已使用，恢复码不存在或已使用时返回false
+func (r *recoveryCodeRepository) Use(userID uint, hash string) (bool, error) {
+	result := r.db.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", r.db.NowFunc())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// CountUnused 统计用户未使用的恢复码数量
+func (r *recoveryCodeRepository) CountUnused(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// DeleteByUser 删除用户的全部恢复码
+func (r *recoveryCodeRepository) DeleteByUser(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error
+}
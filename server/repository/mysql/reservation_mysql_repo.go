@@ -0,0 +1,156 @@
+package mysql
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"library/model"
+)
+
+// ReservationRepository 预约仓库接口
+type ReservationRepository interface {
+	Create(reservation *model.Reservation) error
+	Update(reservation *model.Reservation) error
+	GetByID(id uint) (*model.Reservation, error)
+	GetActiveByUserAndBook(userID, bookID uint) (*model.Reservation, error)
+	GetFirstWaiting(bookID uint) (*model.Reservation, error)
+	GetBookQueue(bookID uint) ([]*model.Reservation, error)
+	CountWaiting(bookID uint, excludeUserID uint) (int64, error)
+	GetUserReservations(userID uint, status int) ([]*model.Reservation, error)
+	GetExpiredReady(bookID uint, now time.Time) ([]*model.Reservation, error)
+	Transaction(fc func(tx *gorm.DB) error) error
+}
+
+type reservationRepository struct {
+	db *gorm.DB
+}
+
+// NewReservationRepository 创建预约仓库实例
+func NewReservationRepository(db *gorm.DB) ReservationRepository {
+	return &reservationRepository{db: db}
+}
+
+// Transaction wraps the function in a database transaction
+func (r *reservationRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// Create 创建预约
+func (r *reservationRepository) Create(reservation *model.Reservation) error {
+	reservation.CreatedAt = r.db.NowFunc()
+	reservation.UpdatedAt = r.db.NowFunc()
+	return r.db.Create(reservation).Error
+}
+
+// Update 更新预约
+func (r *reservationRepository) Update(reservation *model.Reservation) error {
+	reservation.UpdatedAt = r.db.NowFunc()
+	return r.db.Model(reservation).Omit(clause.Associations).Updates(reservation).Error
+}
+
+// GetByID 根据ID获取预约
+func (r *reservationRepository) GetByID(id uint) (*model.Reservation, error) {
+	var reservation model.Reservation
+	err := r.db.
+		Preload("User").
+		Preload("Book").
+		First(&reservation, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// GetActiveByUserAndBook 获取用户对某本书仍然有效（排队中或待取书）的预约
+func (r *reservationRepository) GetActiveByUserAndBook(userID, bookID uint) (*model.Reservation, error) {
+	var reservation model.Reservation
+	err := r.db.
+		Where("user_id = ? AND book_id = ? AND status IN ?", userID, bookID, []int{1, 2}).
+		First(&reservation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// GetFirstWaiting 获取图书预约队列中排在最前面的预约（先进先出）
+func (r *reservationRepository) GetFirstWaiting(bookID uint) (*model.Reservation, error) {
+	var reservation model.Reservation
+	err := r.db.
+		Where("book_id = ? AND status = ?", bookID, 1).
+		Order("created_at ASC, id ASC").
+		First(&reservation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// GetBookQueue 获取图书当前的预约队列（待取书在前，其余按排队先后）
+func (r *reservationRepository) GetBookQueue(bookID uint) ([]*model.Reservation, error) {
+	var reservations []*model.Reservation
+	err := r.db.
+		Preload("User").
+		Where("book_id = ? AND status IN ?", bookID, []int{1, 2}).
+		Order("status DESC, created_at ASC, id ASC").
+		Find(&reservations).Error
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// CountWaiting 统计图书排队中的预约数量，可排除指定用户
+func (r *reservationRepository) CountWaiting(bookID uint, excludeUserID uint) (int64, error) {
+	var count int64
+	db := r.db.Model(&model.Reservation{}).Where("book_id = ? AND status = ?", bookID, 1)
+	if excludeUserID > 0 {
+		db = db.Where("user_id <> ?", excludeUserID)
+	}
+	err := db.Count(&count).Error
+	return count, err
+}
+
+// GetUserReservations 获取用户的预约记录
+func (r *reservationRepository) GetUserReservations(userID uint, status int) ([]*model.Reservation, error) {
+	var reservations []*model.Reservation
+	db := r.db.
+		Preload("Book").
+		Where("user_id = ?", userID)
+
+	if status > 0 {
+		db = db.Where("status = ?", status)
+	}
+
+	err := db.Order("created_at DESC").Find(&reservations).Error
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// GetExpiredReady 获取已超过取书期限仍未取书的预约，bookID 为 0 时查询所有图书
+func (r *reservationRepository) GetExpiredReady(bookID uint, now time.Time) ([]*model.Reservation, error) {
+	var reservations []*model.Reservation
+	db := r.db.Where("status = ? AND expire_at < ?", 2, now)
+	if bookID > 0 {
+		db = db.Where("book_id = ?", bookID)
+	}
+
+	err := db.Order("expire_at ASC").Find(&reservations).Error
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
@@ -0,0 +1,90 @@
+package mysql
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"library/model"
+)
+
+// RolePermissionRepository 角色权限仓库接口
+type RolePermissionRepository interface {
+	ListByRole(role string) ([]string, error)
+	List() ([]*model.RolePermission, error)
+	ReplaceRole(role string, permissions []string) error
+	CountByRole(role string) (int64, error)
+	IsSeeded(role string) (bool, error)
+	MarkSeeded(role string) error
+	Transaction(fc func(tx *gorm.DB) error) error
+}
+
+type rolePermissionRepository struct {
+	db *gorm.DB
+}
+
+// NewRolePermissionRepository 创建角色权限仓库实例
+func NewRolePermissionRepository(db *gorm.DB) RolePermissionRepository {
+	return &rolePermissionRepository{db: db}
+}
+
+// Transaction wraps the function in a database transaction
+func (r *rolePermissionRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// ListByRole 获取角色拥有的权限名称
+func (r *rolePermissionRepository) ListByRole(role string) ([]string, error) {
+	var permissions []string
+	err := r.db.Model(&model.RolePermission{}).
+		Where("role = ?", role).
+		Order("permission").
+		Pluck("permission", &permissions).Error
+	return permissions, err
+}
+
+// List 获取全部角色权限
+func (r *rolePermissionRepository) List() ([]*model.RolePermission, error) {
+	var list []*model.RolePermission
+	err := r.db.Order("role, permission").Find(&list).Error
+	return list, err
+}
+
+// ReplaceRole 用给定的权限替换角色现有的全部权限，调用方应在事务中执行
+func (r *rolePermissionRepository) ReplaceRole(role string, permissions []string) error {
+	if err := r.db.Where("role = ?", role).Delete(&model.RolePermission{}).Error; err != nil {
+		return err
+	}
+	if len(permissions) == 0 {
+		return nil
+	}
+	now := r.db.NowFunc()
+	rows := make([]*model.RolePermission, 0, len(permissions))
+	for _, p := range permissions {
+		rows = append(rows, &model.RolePermission{CreatedAt: now, Role: role, Permission: p})
+	}
+	return r.db.Create(&rows).Error
+}
+
+// CountByRole 统计角色的权限数量
+func (r *rolePermissionRepository) CountByRole(role string) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.RolePermission{}).Where("role = ?", role).Count(&count).Error
+	return count, err
+}
+
+// IsSeeded 角色的默认权限是否已写入过
+func (r *rolePermissionRepository) IsSeeded(role string) (bool, error) {
+	var seed model.RoleSeed
+	err := r.db.Where("role = ?", role).First(&seed).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// MarkSeeded 记录角色的默认权限已写入，已记录时忽略
+func (r *rolePermissionRepository) MarkSeeded(role string) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.RoleSeed{Role: role, CreatedAt: r.db.NowFunc()}).Error
+}
@@ -0,0 +1,69 @@
+package mysql
+
+import (
+	"gorm.io/gorm"
+)
+
+// Repositories 绑定到同一数据库连接（或同一事务）的一组仓库
+type Repositories struct {
+	User           UserRepository
+	Review         ReviewRepository
+	Borrow         BorrowRepository
+	Book           BookRepository
+	Reservation    ReservationRepository
+	LoanPolicy     LoanPolicyRepository
+	JobRun         JobRunRepository
+	Fine           FineRepository
+	Copy           CopyRepository
+	AuditLog       AuditLogRepository
+	RolePermission RolePermissionRepository
+	MailOutbox     MailOutboxRepository
+	RecoveryCode   RecoveryCodeRepository
+	Identity       ExternalIdentityRepository
+	APIKey         APIKeyRepository
+	ImportJob      ImportJobRepository
+}
+
+// newRepositories 创建在指定连接上执行的全部仓库
+func newRepositories(db *gorm.DB) *Repositories {
+	return &Repositories{
+		User:           NewUserRepository(db),
+		Review:         NewReviewRepository(db),
+		Borrow:         NewBorrowRepository(db),
+		Book:           NewBookRepository(db),
+		Reservation:    NewReservationRepository(db),
+		LoanPolicy:     NewLoanPolicyRepository(db),
+		JobRun:         NewJobRunRepository(db),
+		Fine:           NewFineRepository(db),
+		Copy:           NewCopyRepository(db),
+		AuditLog:       NewAuditLogRepository(db),
+		RolePermission: NewRolePermissionRepository(db),
+		MailOutbox:     NewMailOutboxRepository(db),
+		RecoveryCode:   NewRecoveryCodeRepository(db),
+		Identity:       NewExternalIdentityRepository(db),
+		APIKey:         NewAPIKeyRepository(db),
+		ImportJob:      NewImportJobRepository(db),
+	}
+}
+
+// UnitOfWork 工作单元接口。Do在一个数据库事务中执行fn，
+// 传给fn的仓库都绑定到该事务，fn返回错误或panic时整体回滚
+type UnitOfWork interface {
+	Do(fn func(repos *Repositories) error) error
+}
+
+type unitOfWork struct {
+	db *gorm.DB
+}
+
+// NewUnitOfWork 创建工作单元实例
+func NewUnitOfWork(db *gorm.DB) UnitOfWork {
+	return &unitOfWork{db: db}
+}
+
+// Do 在事务中执行fn
+func (u *unitOfWork) Do(fn func(repos *Repositories) error) error {
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		return fn(newRepositories(tx))
+	})
+}
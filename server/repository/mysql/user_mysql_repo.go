@@ -2,7 +2,10 @@ package mysql
 
 import (
 	"errors"
+	"time"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"library/model"
 )
 
@@ -12,8 +15,15 @@ type UserRepository interface {
 	Update(user *model.User) error
 	Delete(id uint) error
 	GetByID(id uint) (*model.User, error)
+	LockByID(id uint) (*model.User, error)
 	GetByUsername(username string) (*model.User, error)
+	GetByEmail(email string) (*model.User, error)
+	SetEmailVerifiedAt(id uint, at *time.Time) error
+	UpdateTOTP(id uint, secret string, enabledAt *time.Time, lastStep int64) error
 	List(params *model.SearchParams) ([]*model.User, int64, error)
+	UpdatePassword(id uint, hash string) error
+	CountLegacyPasswords() (int64, error)
+	LockActiveAdmins() ([]*model.User, error)
 	Transaction(fc func(tx *gorm.DB) error) error
 }
 
@@ -31,6 +41,10 @@ func (r *userRepository) Create(user *model.User) error {
 	user.CreatedAt = r.db.NowFunc()
 	user.UpdatedAt = r.db.NowFunc()
 	user.LastLoginAt = r.db.NowFunc()
+	// 邮箱为空时不写入该列，保存为NULL，多个未填写邮箱的账号不会违反邮箱的唯一索引
+	if user.Email == "" {
+		return r.db.Omit("Email").Create(user).Error
+	}
 	return r.db.Create(user).Error
 }
 
@@ -63,6 +77,20 @@ func (r *userRepository) GetByID(id uint) (*model.User, error) {
 	return &user, nil
 }
 
+// LockByID 根据ID获取用户并加行锁（SELECT ... FOR UPDATE），需在事务中使用。
+// 同一读者的借阅操作通过该锁串行执行，避免并发借阅绕过借阅数量限制
+func (r *userRepository) LockByID(id uint) (*model.User, error) {
+	var user model.User
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
 // GetByUsername 根据用户名获取用户
 func (r *userRepository) GetByUsername(username string) (*model.User, error) {
 	var user model.User
@@ -76,6 +104,41 @@ func (r *userRepository) GetByUsername(username string) (*model.User, error) {
 	return &user, nil
 }
 
+// GetByEmail 根据邮箱获取用户
+func (r *userRepository) GetByEmail(email string) (*model.User, error) {
+	var user model.User
+	err := r.db.Where("email = ?", email).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetEmailVerifiedAt 设置邮箱验证时间，at为nil时清除验证状态（Updates 会忽略零值，因此单独更新）
+func (r *userRepository) SetEmailVerifiedAt(id uint, at *time.Time) error {
+	return r.db.Model(&model.User{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"email_verified_at": at,
+			"updated_at":        r.db.NowFunc(),
+		}).Error
+}
+
+// UpdateTOTP 保存双因素认证状态，secret为空、enabledAt为nil表示关闭（Updates 会忽略零值，因此单独更新）
+func (r *userRepository) UpdateTOTP(id uint, secret string, enabledAt *time.Time, lastStep int64) error {
+	return r.db.Model(&model.User{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"totp_secret":     secret,
+			"totp_enabled_at": enabledAt,
+			"totp_last_step":  lastStep,
+			"updated_at":      r.db.NowFunc(),
+		}).Error
+}
+
 // List 获取用户列表（支持模糊查询和分页）
 func (r *userRepository) List(params *model.SearchParams) ([]*model.User, int64, error) {
 	var users []*model.User
@@ -105,3 +168,37 @@ func (r *userRepository) List(params *model.SearchParams) ([]*model.User, int64,
 	
 	return users, total, nil
 }
+
+// UpdatePassword 更新密码哈希并清空旧版盐值（Updates 会忽略零值，因此单独更新）
+func (r *userRepository) UpdatePassword(id uint, hash string) error {
+	return r.db.Model(&model.User{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"password":   hash,
+			"salt":       "",
+			"updated_at": r.db.NowFunc(),
+		}).Error
+}
+
+// CountLegacyPasswords 统计仍使用旧版md5密码哈希的用户数量（新格式哈希以$开头）
+func (r *userRepository) CountLegacyPasswords() (int64, error) {
+	var count int64
+	err := r.db.Model(&model.User{}).
+		Where("password NOT LIKE ?", "$%").
+		Count(&count).Error
+	return count, err
+}
+
+// LockActiveAdmins 获取全部启用状态的管理员并加行锁，需在事务中使用。
+// 降级、禁用、删除管理员时通过该锁串行执行，避免并发操作移除最后一个管理员
+func (r *userRepository) LockActiveAdmins() ([]*model.User, error) {
+	var users []*model.User
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("role = ? AND status = ?", "admin", 1).
+		Order("id ASC").
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
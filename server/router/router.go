@@ -1,14 +1,19 @@
 package router
 
 import (
+	"time"
+
+	"library/config"
 	_ "library/docs" // 导入 swagger docs
 	"library/handler"
 	"library/middleware"
+	"library/model"
 	"library/service"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"golang.org/x/time/rate"
 )
 
 // @title Library Management System API
@@ -30,41 +35,132 @@ import (
 // @in header
 // @name Authorization
 
+// @securityDefinitions.apikey MachineKeyAuth
+// @in header
+// @name X-API-Key
+
 // SetupRouter initializes the router and sets up all routes
 func SetupRouter(factory service.Factory) *gin.Engine {
 	r := gin.Default()
 
 	// Add middleware
+	r.Use(middleware.RequestIDMiddleware())
 	r.Use(middleware.CORSMiddleware())
+	r.Use(middleware.PermissionMiddleware(factory.GetPermissionService()))
+
+	// 机器客户端的API密钥按密钥限流，速率可按密钥单独设置
+	apiKeyCfg := config.GlobalConfig.APIKey
+	apiKeyLimiter := middleware.NewIPRateLimiter(rate.Limit(apiKeyCfg.RateLimit), apiKeyCfg.RateBurst, 10*time.Minute)
+	r.Use(middleware.APIKeyMiddleware(factory.GetAPIKeyService(), apiKeyLimiter, rate.Limit(apiKeyCfg.RateLimit), apiKeyCfg.RateBurst))
 
 	// Swagger documentation
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// Create handlers
-	userHandler := handler.NewUserHandler(factory.GetUserService())
+	userHandler := handler.NewUserHandler(factory.GetUserService(), factory.GetAuthService(), factory.GetTwoFactorService())
 	bookHandler := handler.NewBookHandler(factory.GetBookService())
-	borrowHandler := handler.NewBorrowHandler(factory.GetBorrowService())
+	borrowHandler := handler.NewBorrowHandler(factory.GetBorrowService(), factory.GetOverdueService(), factory.GetFineService())
 	reviewHandler := handler.NewReviewHandler(factory.GetReviewService())
+	reservationHandler := handler.NewReservationHandler(factory.GetReservationService())
+	loanPolicyHandler := handler.NewLoanPolicyHandler(factory.GetLoanPolicyService())
+	fineHandler := handler.NewFineHandler(factory.GetFineService())
+	copyHandler := handler.NewCopyHandler(factory.GetCopyService())
+	roleHandler := handler.NewRoleHandler(factory.GetPermissionService())
+	authHandler := handler.NewAuthHandler(factory.GetAuthService(), factory.GetTwoFactorService())
+	apiKeyHandler := handler.NewAPIKeyHandler(factory.GetAPIKeyService())
+	auditHandler := handler.NewAuditHandler(factory.GetAuditService())
+	bookImportHandler := handler.NewBookImportHandler(factory.GetBookImportService())
+	bookSearchHandler := handler.NewBookSearchHandler(factory.GetBookSearchService())
+
+	// 登录、注册、刷新令牌、找回密码等公开的账号接口按IP限流
+	loginLimiter := middleware.NewIPRateLimiter(rate.Limit(config.GlobalConfig.Login.RateLimit), config.GlobalConfig.Login.RateBurst, 10*time.Minute)
 
 	// API v1 routes
 	v1 := r.Group("/api/v1")
 	{
+		// Single sign-on routes
+		sso := v1.Group("/auth")
+		{
+			sso.GET("/providers", authHandler.ListProviders)
+			sso.GET("/oidc/:provider/authorize", middleware.RateLimitMiddleware(loginLimiter), authHandler.Authorize)
+			sso.POST("/oidc/:provider/callback", middleware.RateLimitMiddleware(loginLimiter), authHandler.Callback)
+		}
+
 		// User routes
 		users := v1.Group("/users")
 		{
-			users.POST("/register", userHandler.Register)
-			users.POST("/login", userHandler.Login)
+			users.POST("/register", middleware.RateLimitMiddleware(loginLimiter), userHandler.Register)
+			users.POST("/login", middleware.RateLimitMiddleware(loginLimiter), userHandler.Login)
+			users.POST("/login/2fa", middleware.RateLimitMiddleware(loginLimiter), userHandler.LoginTwoFactor)
+			users.POST("/login/2fa/setup", middleware.RateLimitMiddleware(loginLimiter), userHandler.LoginTwoFactorSetup)
+			users.POST("/login/2fa/enable", middleware.RateLimitMiddleware(loginLimiter), userHandler.LoginTwoFactorEnable)
+			users.POST("/refresh", middleware.RateLimitMiddleware(loginLimiter), userHandler.RefreshToken)
+			users.POST("/password/forgot", middleware.RateLimitMiddleware(loginLimiter), userHandler.ForgotPassword)
+			users.POST("/password/reset", middleware.RateLimitMiddleware(loginLimiter), userHandler.ResetPassword)
+			users.POST("/email/verify", middleware.RateLimitMiddleware(loginLimiter), userHandler.VerifyEmail)
 
 			auth := users.Use(middleware.AuthMiddleware())
 			{
+				// 账号本身的设置只能由本人登录后修改，不接受API密钥
+				session := middleware.RequireSession()
 				auth.GET("/profile", userHandler.GetProfile)
-				auth.PUT("/profile", userHandler.UpdateProfile)
-				auth.PUT("/password", userHandler.ChangePassword)
+				auth.PUT("/profile", session, userHandler.UpdateProfile)
+				auth.PUT("/password", session, userHandler.ChangePassword)
+				auth.POST("/logout", session, userHandler.Logout)
+				auth.POST("/email/verification", session, userHandler.SendVerificationEmail)
+				auth.GET("/2fa", session, userHandler.GetTwoFactorStatus)
+				auth.POST("/2fa/setup", session, userHandler.SetupTwoFactor)
+				auth.POST("/2fa/enable", session, userHandler.EnableTwoFactor)
+				auth.POST("/2fa/disable", session, userHandler.DisableTwoFactor)
+				auth.POST("/2fa/recovery-codes", session, userHandler.RegenerateRecoveryCodes)
+				auth.GET("/identities", session, authHandler.ListIdentities)
+
+				auth.GET("", middleware.RequirePermission(model.PermUserRead), userHandler.ListUsers)
+				auth.GET("/legacy-passwords", middleware.RequirePermission(model.PermUserManage), userHandler.GetLegacyPasswordCount)
+				auth.PUT("/:id/role", middleware.RequirePermission(model.PermUserManage), userHandler.UpdateUserRole)
+				auth.PUT("/:id/status", middleware.RequirePermission(model.PermUserManage), userHandler.UpdateUserStatus)
+				auth.DELETE("/:id", middleware.RequirePermission(model.PermUserManage), userHandler.DeleteUser)
+				auth.POST("/:id/unlock", middleware.RequirePermission(model.PermUserManage), userHandler.UnlockLogin)
+				auth.DELETE("/:id/2fa", middleware.RequirePermission(model.PermUserManage), userHandler.ResetTwoFactor)
+				auth.GET("/login-failures", middleware.RequirePermission(model.PermUserManage), userHandler.ListLoginFailures)
+				if config.GlobalConfig.LDAP.Enabled {
+					ldapHandler := handler.NewLDAPHandler(factory.GetLDAPService())
+					auth.GET("/ldap-sync", middleware.RequirePermission(model.PermUserManage), ldapHandler.GetLDAPSync)
+					auth.POST("/ldap-sync", middleware.RequirePermission(model.PermUserManage), ldapHandler.RunLDAPSync)
+				}
 			}
+		}
 
-			admin := auth.Use(middleware.AdminAuthMiddleware())
+		// API key routes
+		apiKeys := v1.Group("/api-keys")
+		{
+			auth := apiKeys.Use(middleware.AuthMiddleware(), middleware.RequireSession(), middleware.RequirePermission(model.PermAPIKeyManage))
 			{
-				admin.GET("", userHandler.ListUsers)
+				auth.GET("", apiKeyHandler.ListAPIKeys)
+				auth.POST("", apiKeyHandler.CreateAPIKey)
+				auth.GET("/:id", apiKeyHandler.GetAPIKey)
+				auth.POST("/:id/rotate", apiKeyHandler.RotateAPIKey)
+				auth.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
+			}
+		}
+
+		// Audit log routes
+		auditLogs := v1.Group("/audit-logs")
+		{
+			auth := auditLogs.Use(middleware.AuthMiddleware(), middleware.RequirePermission(model.PermAuditRead))
+			{
+				auth.GET("", auditHandler.ListAuditLogs)
+				auth.GET("/verify", auditHandler.VerifyAuditChain)
+			}
+		}
+
+		// Role routes
+		roles := v1.Group("/roles")
+		{
+			auth := roles.Use(middleware.AuthMiddleware(), middleware.RequirePermission(model.PermRoleManage))
+			{
+				auth.GET("", roleHandler.ListRoles)
+				auth.PUT("/:role/permissions", roleHandler.UpdateRolePermissions)
 			}
 		}
 
@@ -72,17 +168,33 @@ func SetupRouter(factory service.Factory) *gin.Engine {
 		books := v1.Group("/books")
 		{
 			books.GET("", bookHandler.ListBooks)
+			books.GET("/search", bookSearchHandler.SearchBooks)
 			books.GET("/:id", bookHandler.GetBook)
+			books.GET("/:id/copies", copyHandler.ListBookCopies)
 
 			auth := books.Use(middleware.AuthMiddleware())
 			{
-				admin := auth.Use(middleware.AdminAuthMiddleware())
-				{
-					admin.POST("", bookHandler.CreateBook)
-					admin.PUT("/:id", bookHandler.UpdateBook)
-					admin.PUT("/:id/status", bookHandler.UpdateBookStatus)
-					admin.PUT("/:id/stock", bookHandler.UpdateBookStock)
-				}
+				auth.POST("", middleware.RequirePermission(model.PermBookWrite), bookHandler.CreateBook)
+				auth.POST("/marc/import", middleware.RequirePermission(model.PermBookWrite), bookHandler.ImportMARC)
+				auth.GET("/marc/export", middleware.RequirePermission(model.PermBookWrite), bookHandler.ExportMARC)
+				auth.PUT("/:id", middleware.RequirePermission(model.PermBookWrite), bookHandler.UpdateBook)
+				auth.PUT("/:id/status", middleware.RequirePermission(model.PermBookWrite), bookHandler.UpdateBookStatus)
+				auth.PUT("/:id/stock", middleware.RequirePermission(model.PermBookWrite), bookHandler.UpdateBookStock)
+				auth.GET("/:id/reservations", middleware.RequirePermission(model.PermReservationManage), reservationHandler.GetBookQueue)
+				auth.POST("/:id/copies", middleware.RequirePermission(model.PermBookWrite), copyHandler.AddCopy)
+			}
+		}
+
+		// Book import routes
+		imports := v1.Group("/imports")
+		{
+			auth := imports.Use(middleware.AuthMiddleware(), middleware.RequirePermission(model.PermBookWrite))
+			{
+				auth.POST("", bookImportHandler.CreateImport)
+				auth.GET("", bookImportHandler.ListImports)
+				auth.GET("/:id", bookImportHandler.GetImport)
+				auth.GET("/:id/rows", bookImportHandler.ListImportRows)
+				auth.POST("/:id/cancel", bookImportHandler.CancelImport)
 			}
 		}
 
@@ -95,10 +207,13 @@ func SetupRouter(factory service.Factory) *gin.Engine {
 				auth.GET("/:id", borrowHandler.GetBorrow)
 				auth.POST("", borrowHandler.BorrowBook)
 				auth.POST("/return", borrowHandler.ReturnBook)
-			}
-			admin := auth.Use(middleware.AdminAuthMiddleware())
-			{
-				admin.PUT("/:id", borrowHandler.UpdateBorrow)
+				auth.POST("/:id/renew", borrowHandler.RenewBorrow)
+
+				auth.PUT("/:id", middleware.RequirePermission(model.PermBorrowManage), borrowHandler.UpdateBorrow)
+				auth.GET("/overdue-scan", middleware.RequirePermission(model.PermBorrowManage), borrowHandler.GetOverdueScan)
+				auth.POST("/overdue-scan", middleware.RequirePermission(model.PermBorrowManage), borrowHandler.RunOverdueScan)
+				auth.POST("/checkout", middleware.RequirePermission(model.PermBorrowCheckout), borrowHandler.Checkout)
+				auth.POST("/checkin", middleware.RequirePermission(model.PermBorrowCheckin), borrowHandler.Checkin)
 			}
 		}
 
@@ -113,16 +228,64 @@ func SetupRouter(factory service.Factory) *gin.Engine {
 				auth.POST("", reviewHandler.CreateReview)
 				auth.PUT("/:id", reviewHandler.UpdateReview)
 				auth.DELETE("/:id", reviewHandler.DeleteReview)
+				auth.PUT("/:id/status", middleware.RequirePermission(model.PermReviewModerate), reviewHandler.UpdateReviewStatus)
+			}
+		}
 
-				admin := auth.Use(middleware.AdminAuthMiddleware())
-				{
-					admin.PUT("/:id/status", reviewHandler.UpdateReviewStatus)
-				}
+		// Reservation routes
+		reservations := v1.Group("/reservations")
+		{
+			auth := reservations.Use(middleware.AuthMiddleware())
+			{
+				auth.GET("", reservationHandler.ListMyReservations)
+				auth.GET("/:id", reservationHandler.GetReservation)
+				auth.POST("", reservationHandler.CreateReservation)
+				auth.DELETE("/:id", reservationHandler.CancelReservation)
+			}
+		}
+
+		// Loan policy routes
+		loanPolicies := v1.Group("/loan-policies")
+		{
+			auth := loanPolicies.Use(middleware.AuthMiddleware(), middleware.RequirePermission(model.PermLoanPolicyManage))
+			{
+				auth.GET("", loanPolicyHandler.ListPolicies)
+				auth.GET("/resolve", loanPolicyHandler.ResolvePolicy)
+				auth.GET("/:id", loanPolicyHandler.GetPolicy)
+				auth.POST("", loanPolicyHandler.CreatePolicy)
+				auth.PUT("/:id", loanPolicyHandler.UpdatePolicy)
+				auth.DELETE("/:id", loanPolicyHandler.DeletePolicy)
 			}
 		}
 
+		// Copy routes
+		copies := v1.Group("/copies")
+		{
+			auth := copies.Use(middleware.AuthMiddleware())
+			{
+				auth.GET("/barcode/:barcode", middleware.RequirePermission(model.PermCopyRead), copyHandler.GetCopyByBarcode)
+				auth.GET("/:id", middleware.RequirePermission(model.PermCopyRead), copyHandler.GetCopy)
+				auth.PUT("/:id", middleware.RequirePermission(model.PermBookWrite), copyHandler.UpdateCopy)
+				auth.DELETE("/:id", middleware.RequirePermission(model.PermBookWrite), copyHandler.DeleteCopy)
+			}
+		}
 
+		// Fine routes
+		fines := v1.Group("/fines")
+		{
+			auth := fines.Use(middleware.AuthMiddleware())
+			{
+				auth.GET("", fineHandler.ListMyTransactions)
+				auth.GET("/balance", fineHandler.GetMyBalance)
+				auth.POST("/payments", fineHandler.PayFine)
 
+				auth.POST("/charges", middleware.RequirePermission(model.PermFineManage), fineHandler.ChargeFine)
+				auth.POST("/waivers", middleware.RequirePermission(model.PermFineManage), fineHandler.WaiveFine)
+				auth.POST("/refunds", middleware.RequirePermission(model.PermFineManage), fineHandler.RefundFine)
+				auth.GET("/report", middleware.RequirePermission(model.PermFineRead), fineHandler.GetReport)
+				auth.GET("/users/:id", middleware.RequirePermission(model.PermFineRead), fineHandler.ListUserTransactions)
+			}
+		}
 	}
 
 	return r
@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrSkipped 任务返回该错误表示本次无需执行（如已由其他实例执行），不记录为失败
+var ErrSkipped = errors.New("job skipped")
+
+type job struct {
+	name     string
+	interval time.Duration
+	fn       func() error
+}
+
+// Scheduler 定时任务调度器，每个任务在独立的goroutine中按固定间隔执行
+type Scheduler struct {
+	jobs   []job
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+// New 创建调度器
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every 注册一个按固定间隔执行的任务，间隔不大于0的任务会被忽略
+func (s *Scheduler) Every(name string, interval time.Duration, fn func() error) {
+	if interval <= 0 {
+		log.Printf("scheduler: job %s disabled (interval %v)", name, interval)
+		return
+	}
+	s.jobs = append(s.jobs, job{name: name, interval: interval, fn: fn})
+}
+
+// Start 启动所有任务，任务启动后立即执行一次
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, j)
+	}
+}
+
+// Stop 停止调度并等待正在执行的任务结束
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		s.run(j)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run 执行一次任务，捕获panic避免影响其他任务
+func (s *Scheduler) run(j job) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("scheduler: job %s panic: %v", j.name, err)
+		}
+	}()
+
+	start := time.Now()
+	err := j.fn()
+	switch {
+	case err == nil:
+		log.Printf("scheduler: job %s finished in %v", j.name, time.Since(start))
+	case errors.Is(err, ErrSkipped):
+		log.Printf("scheduler: job %s skipped", j.name)
+	default:
+		log.Printf("scheduler: job %s failed: %v", j.name, err)
+	}
+}
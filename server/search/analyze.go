@@ -0,0 +1,73 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token 分词结果
+type Token struct {
+	Term  string // 词，字母统一为小写
+	Start int    // 在原文中的起始字节偏移
+	End   int    // 在原文中的结束字节偏移
+}
+
+// Tokenize 分词：连续的字母和数字为一个词；中日韩文字按相邻两字切分（与MySQL ngram解析器的默认设置一致），
+// 单独的一个字作为一个词
+func Tokenize(text string) []Token {
+	var tokens []Token
+	emit := func(term string, start, end int) {
+		tokens = append(tokens, Token{Term: term, Start: start, End: end})
+	}
+
+	var cjk []int // 当前中日韩文字串中每个字的起始偏移
+	flushCJK := func(end int) {
+		switch len(cjk) {
+		case 0:
+			return
+		case 1:
+			emit(text[cjk[0]:end], cjk[0], end)
+		default:
+			for i := 0; i+1 < len(cjk); i++ {
+				stop := end
+				if i+2 < len(cjk) {
+					stop = cjk[i+2]
+				}
+				emit(text[cjk[i]:stop], cjk[i], stop)
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	wordStart := -1
+	flushWord := func(end int) {
+		if wordStart >= 0 {
+			emit(strings.ToLower(text[wordStart:end]), wordStart, end)
+			wordStart = -1
+		}
+	}
+
+	for i, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord(i)
+			cjk = append(cjk, i)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK(i)
+			if wordStart < 0 {
+				wordStart = i
+			}
+		default:
+			flushWord(i)
+			flushCJK(i)
+		}
+	}
+	flushWord(len(text))
+	flushCJK(len(text))
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
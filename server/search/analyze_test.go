@@ -0,0 +1,31 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	cases := []struct {
+		text string
+		want []Token
+	}{
+		{text: "Hello World", want: []Token{{"hello", 0, 5}, {"world", 6, 11}}},
+		{text: "C++ Primer, 5th", want: []Token{{"c", 0, 1}, {"primer", 4, 10}, {"5th", 12, 15}}},
+		// 中文按相邻两字切分，偏移为字节偏移
+		{text: "Go语言编程", want: []Token{{"go", 0, 2}, {"语言", 2, 8}, {"言编", 5, 11}, {"编程", 8, 14}}},
+		{text: "红", want: []Token{{"红", 0, 3}}},
+		{text: "三体 III", want: []Token{{"三体", 0, 6}, {"iii", 7, 10}}},
+		{text: "数据库2版", want: []Token{{"数据", 0, 6}, {"据库", 3, 9}, {"2", 9, 10}, {"版", 10, 13}}},
+		{text: "《红楼梦》", want: []Token{{"红楼", 3, 9}, {"楼梦", 6, 12}}},
+		{text: "한국어", want: []Token{{"한국", 0, 6}, {"국어", 3, 9}}},
+		{text: "Straße", want: []Token{{"straße", 0, 7}}},
+		{text: " ,. ", want: nil},
+		{text: "", want: nil},
+	}
+	for _, c := range cases {
+		if got := Tokenize(c.text); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Tokenize(%q) = %v, want %v", c.text, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// snippetRunes 简介摘要的最大字数
+	snippetRunes = 120
+	// snippetContext 摘要中第一处命中之前保留的字数
+	snippetContext = 30
+	// 高亮标记
+	highlightPre  = "<em>"
+	highlightPost = "</em>"
+)
+
+// Highlight 返回文档中命中字段的高亮文本，原文经过HTML转义，命中的词以 <em></em> 标记。
+// 标题、作者、出版社返回全文，简介只返回第一处命中附近的片段
+func Highlight(doc *Document, q *Query) map[string]string {
+	a := analyze(doc)
+	result := make(map[string]string)
+	for _, f := range fields {
+		var spans [][2]int
+		for _, c := range q.Clauses {
+			spans = append(spans, c.occurrences(a.tokens[f.Name])...)
+		}
+		if len(spans) == 0 {
+			continue
+		}
+		result[f.Name] = highlightText(doc.Field(f.Name), mergeSpans(spans), f.Name == FieldSummary)
+	}
+	return result
+}
+
+// mergeSpans 合并重叠或相邻的范围（两字切分的中文词语相互重叠）
+func mergeSpans(spans [][2]int) [][2]int {
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	merged := spans[:1]
+	for _, s := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if s[0] <= last[1] {
+			if s[1] > last[1] {
+				last[1] = s[1]
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+func highlightText(text string, spans [][2]int, snippet bool) string {
+	start, end := 0, len(text)
+	if snippet && utf8.RuneCountInString(text) > snippetRunes {
+		start = spans[0][0]
+		for i := 0; i < snippetContext && start > 0; i++ {
+			_, size := utf8.DecodeLastRuneInString(text[:start])
+			start -= size
+		}
+		end = start
+		for i := 0; i < snippetRunes && end < len(text); i++ {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			end += size
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, s := range spans {
+		if s[1] <= pos || s[0] >= end {
+			continue
+		}
+		from, to := max(s[0], pos), min(s[1], end)
+		b.WriteString(html.EscapeString(text[pos:from]))
+		b.WriteString(highlightPre)
+		b.WriteString(html.EscapeString(text[from:to]))
+		b.WriteString(highlightPost)
+		pos = to
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
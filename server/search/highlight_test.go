@@ -0,0 +1,93 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	longSummary := strings.Repeat("a ", 100) + "target " + strings.Repeat("b ", 100)
+
+	cases := []struct {
+		name  string
+		doc   *Document
+		query string
+		want  map[string]string
+	}{
+		{
+			name:  "cjk bigram",
+			doc:   &Document{Title: "Go语言编程", Author: "许式伟"},
+			query: "语言",
+			want:  map[string]string{FieldTitle: "Go<em>语言</em>编程"},
+		},
+		{
+			name:  "cjk phrase",
+			doc:   &Document{Title: "Go语言编程"},
+			query: "语言编程",
+			want:  map[string]string{FieldTitle: "Go<em>语言编程</em>"},
+		},
+		{
+			// 两个子句的命中范围重叠时合并为一处
+			name:  "overlapping clauses",
+			doc:   &Document{Title: "Go语言编程"},
+			query: "语言 言编",
+			want:  map[string]string{FieldTitle: "Go<em>语言编</em>程"},
+		},
+		{
+			name:  "single cjk character",
+			doc:   &Document{Title: "红楼梦"},
+			query: "红",
+			want:  map[string]string{FieldTitle: "<em>红楼</em>梦"},
+		},
+		{
+			name:  "prefix",
+			doc:   &Document{Title: "Programming Go", Publisher: "Prentice Hall"},
+			query: "prog*",
+			want:  map[string]string{FieldTitle: "<em>Programming</em> Go"},
+		},
+		{
+			name:  "multiple fields and escaping",
+			doc:   &Document{Title: "C & <Go>", Author: "Go Team", Summary: "no match"},
+			query: "go",
+			want: map[string]string{
+				FieldTitle:  "C &amp; &lt;<em>Go</em>&gt;",
+				FieldAuthor: "<em>Go</em> Team",
+			},
+		},
+		{
+			// 简介较长时只返回第一处命中前30字起的120字
+			name:  "summary snippet",
+			doc:   &Document{Summary: longSummary},
+			query: "target",
+			want: map[string]string{
+				FieldSummary: "…" + strings.Repeat("a ", 15) + "<em>target</em> " + strings.Repeat("b ", 41) + "b…",
+			},
+		},
+		{
+			name:  "no hits",
+			doc:   &Document{Title: "Go语言编程"},
+			query: "python",
+			want:  map[string]string{},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			q, err := ParseQuery(c.query)
+			if err != nil {
+				t.Fatalf("ParseQuery: %v", err)
+			}
+			if got := Highlight(c.doc, q); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("Highlight = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestMergeSpans(t *testing.T) {
+	got := mergeSpans([][2]int{{10, 12}, {0, 6}, {3, 9}, {9, 10}, {20, 21}})
+	want := [][2]int{{0, 12}, {20, 21}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("mergeSpans = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,207 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryEngine 内存倒排索引，用于测试和单实例的开发环境。
+// 索引只在本进程内维护，启动时需要重建，多实例部署时各实例的索引互不同步
+type MemoryEngine struct {
+	mu       sync.RWMutex
+	docs     map[uint]*analyzedDoc
+	postings map[string]map[uint]struct{} // 词 -> 包含该词的文档
+	terms    []string                     // 有序的词表，用于前缀查询
+	lengths  map[string]int               // 各字段的总词数
+}
+
+// NewMemoryEngine 创建内存检索引擎
+func NewMemoryEngine() *MemoryEngine {
+	return &MemoryEngine{
+		docs:     make(map[uint]*analyzedDoc),
+		postings: make(map[string]map[uint]struct{}),
+		lengths:  make(map[string]int),
+	}
+}
+
+// Name 引擎名称
+func (e *MemoryEngine) Name() string {
+	return EngineMemory
+}
+
+// Index 新增或更新文档
+func (e *MemoryEngine) Index(docs ...*Document) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, doc := range docs {
+		e.remove(doc.ID)
+		e.add(doc)
+	}
+	return nil
+}
+
+// Remove 删除文档
+func (e *MemoryEngine) Remove(ids ...uint) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, id := range ids {
+		e.remove(id)
+	}
+	return nil
+}
+
+// Rebuild 清空索引后重新加载全部文档
+func (e *MemoryEngine) Rebuild(source Source) error {
+	fresh := NewMemoryEngine()
+	if err := source(func(docs []*Document) error {
+		for _, doc := range docs {
+			fresh.add(doc)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.docs, e.postings, e.terms, e.lengths = fresh.docs, fresh.postings, fresh.terms, fresh.lengths
+	return nil
+}
+
+func (e *MemoryEngine) add(doc *Document) {
+	a := analyze(doc)
+	e.docs[doc.ID] = a
+	for name, tokens := range a.tokens {
+		e.lengths[name] += len(tokens)
+		for _, t := range tokens {
+			docs, ok := e.postings[t.Term]
+			if !ok {
+				docs = make(map[uint]struct{})
+				e.postings[t.Term] = docs
+				i := sort.SearchStrings(e.terms, t.Term)
+				e.terms = append(e.terms, "")
+				copy(e.terms[i+1:], e.terms[i:])
+				e.terms[i] = t.Term
+			}
+			docs[doc.ID] = struct{}{}
+		}
+	}
+}
+
+func (e *MemoryEngine) remove(id uint) {
+	a, ok := e.docs[id]
+	if !ok {
+		return
+	}
+	delete(e.docs, id)
+	for name, tokens := range a.tokens {
+		e.lengths[name] -= len(tokens)
+		for _, t := range tokens {
+			docs := e.postings[t.Term]
+			delete(docs, id)
+			if len(docs) == 0 {
+				delete(e.postings, t.Term)
+				if i := sort.SearchStrings(e.terms, t.Term); i < len(e.terms) && e.terms[i] == t.Term {
+					e.terms = append(e.terms[:i], e.terms[i+1:]...)
+				}
+			}
+		}
+	}
+}
+
+// Search 检索：先由倒排索引取出包含子句全部词的候选文档，再逐一校验词的位置，最后按BM25排序
+func (e *MemoryEngine) Search(req *Request) (*Result, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	q := req.Query
+	stats := &Stats{
+		Docs:    len(e.docs),
+		DocFreq: make([]int, len(q.Clauses)),
+		AvgLen:  make(map[string]float64, len(e.lengths)),
+	}
+	if len(e.docs) > 0 {
+		for name, n := range e.lengths {
+			stats.AvgLen[name] = float64(n) / float64(len(e.docs))
+		}
+	}
+
+	var matched map[uint]*analyzedDoc
+	for i, c := range q.Clauses {
+		docs := make(map[uint]*analyzedDoc)
+		for id := range e.candidates(c) {
+			if a := e.docs[id]; a.matchClause(c) {
+				docs[id] = a
+			}
+		}
+		stats.DocFreq[i] = len(docs)
+
+		if matched == nil {
+			matched = docs
+			continue
+		}
+		for id := range matched {
+			if _, ok := docs[id]; !ok {
+				delete(matched, id)
+			}
+		}
+	}
+
+	hits := make([]*Hit, 0, len(matched))
+	for id, a := range matched {
+		if req.Category != "" && a.doc.Category != req.Category {
+			continue
+		}
+		hits = append(hits, &Hit{ID: id, Score: a.score(q, stats)})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	result := &Result{Total: int64(len(hits))}
+	if req.Offset < len(hits) {
+		hits = hits[req.Offset:]
+		if req.Limit > 0 && len(hits) > req.Limit {
+			hits = hits[:req.Limit]
+		}
+		for _, hit := range hits {
+			hit.Highlights = Highlight(e.docs[hit.ID].doc, q)
+		}
+		result.Hits = hits
+	}
+	return result, nil
+}
+
+// candidates 返回包含子句全部词的文档，前缀查询的最后一个词取所有以其开头的词
+func (e *MemoryEngine) candidates(c *Clause) map[uint]struct{} {
+	var result map[uint]struct{}
+	for i, term := range c.Terms {
+		docs := make(map[uint]struct{})
+		if c.Prefix && i == len(c.Terms)-1 {
+			for j := sort.SearchStrings(e.terms, term); j < len(e.terms) && strings.HasPrefix(e.terms[j], term); j++ {
+				for id := range e.postings[e.terms[j]] {
+					docs[id] = struct{}{}
+				}
+			}
+		} else {
+			for id := range e.postings[term] {
+				docs[id] = struct{}{}
+			}
+		}
+
+		if result == nil {
+			result = docs
+			continue
+		}
+		for id := range result {
+			if _, ok := docs[id]; !ok {
+				delete(result, id)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,115 @@
+package search
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// newTestMemoryEngine 索引 n 本标题为 "Go book N" 的图书，偶数ID的分类为 even
+func newTestMemoryEngine(t *testing.T, n int) *MemoryEngine {
+	t.Helper()
+	e := NewMemoryEngine()
+	for i := 1; i <= n; i++ {
+		category := "odd"
+		if i%2 == 0 {
+			category = "even"
+		}
+		if err := e.Index(&Document{ID: uint(i), Category: category, Title: fmt.Sprintf("Go book %d", i)}); err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+	}
+	return e
+}
+
+func searchIDs(t *testing.T, e *MemoryEngine, query, category string, offset, limit int) ([]uint, int64) {
+	t.Helper()
+	q, err := ParseQuery(query)
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	result, err := e.Search(&Request{Query: q, Category: category, Offset: offset, Limit: limit})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	var ids []uint
+	for _, hit := range result.Hits {
+		if hit.Highlights[FieldTitle] == "" {
+			t.Fatalf("hit %d has no title highlight", hit.ID)
+		}
+		ids = append(ids, hit.ID)
+	}
+	return ids, result.Total
+}
+
+func idRange(from, to uint) []uint {
+	var ids []uint
+	for id := from; id <= to; id++ {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestMemoryEnginePagination(t *testing.T) {
+	e := newTestMemoryEngine(t, 25)
+
+	// 相关度相同时按ID排序，总数不受分页影响
+	cases := []struct {
+		name     string
+		category string
+		offset   int
+		limit    int
+		want     []uint
+		total    int64
+	}{
+		{name: "first page", offset: 0, limit: 10, want: idRange(1, 10), total: 25},
+		{name: "middle page", offset: 10, limit: 10, want: idRange(11, 20), total: 25},
+		{name: "last partial page", offset: 20, limit: 10, want: idRange(21, 25), total: 25},
+		{name: "past the end", offset: 30, limit: 10, want: nil, total: 25},
+		{name: "no limit", offset: 22, limit: 0, want: idRange(23, 25), total: 25},
+		{name: "category", category: "even", offset: 5, limit: 3, want: []uint{12, 14, 16}, total: 12},
+		{name: "unknown category", category: "none", offset: 0, limit: 10, want: nil, total: 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ids, total := searchIDs(t, e, "go", c.category, c.offset, c.limit)
+			if !reflect.DeepEqual(ids, c.want) || total != c.total {
+				t.Fatalf("Search = %v (total %d), want %v (total %d)", ids, total, c.want, c.total)
+			}
+		})
+	}
+}
+
+func TestMemoryEngineUpdates(t *testing.T) {
+	e := newTestMemoryEngine(t, 5)
+
+	if err := e.Remove(2); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := e.Index(&Document{ID: 3, Title: "Rust book"}); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	if ids, total := searchIDs(t, e, "go", "", 0, 10); !reflect.DeepEqual(ids, []uint{1, 4, 5}) || total != 3 {
+		t.Fatalf("Search after updates = %v (total %d), want [1 4 5]", ids, total)
+	}
+	if ids, _ := searchIDs(t, e, "rust", "", 0, 10); !reflect.DeepEqual(ids, []uint{3}) {
+		t.Fatalf("Search updated document = %v, want [3]", ids)
+	}
+	// 前缀查询只匹配仍在索引中的词
+	if ids, _ := searchIDs(t, e, "ru*", "", 0, 10); !reflect.DeepEqual(ids, []uint{3}) {
+		t.Fatalf("prefix Search = %v, want [3]", ids)
+	}
+
+	err := e.Rebuild(func(yield func(docs []*Document) error) error {
+		return yield([]*Document{{ID: 7, Title: "Go语言编程"}, {ID: 8, Title: "Go并发编程"}})
+	})
+	if err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+	if ids, total := searchIDs(t, e, "go", "", 0, 10); !reflect.DeepEqual(ids, []uint{7, 8}) || total != 2 {
+		t.Fatalf("Search after rebuild = %v (total %d), want [7 8]", ids, total)
+	}
+	if ids, _ := searchIDs(t, e, "语言编程", "", 0, 10); !reflect.DeepEqual(ids, []uint{7}) {
+		t.Fatalf("phrase Search after rebuild = %v, want [7]", ids)
+	}
+}
@@ -0,0 +1,109 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxClauses 单个查询的子句上限，超出的部分忽略
+const maxClauses = 16
+
+// Query 解析后的查询，文档需匹配全部子句
+type Query struct {
+	Raw     string    // 原始查询
+	Clauses []*Clause // 子句
+}
+
+// Clause 查询中的一个子句。分词后的多个词需在同一字段中连续出现，
+// 因此中文词语（按两字切分）和带引号的短语都按短语匹配
+type Clause struct {
+	Text   string   // 去掉引号和通配符后的原文
+	Terms  []string // 分词结果
+	Phrase bool     // 是否为带引号的短语
+	Prefix bool     // 是否为前缀查询，最后一个词按前缀匹配
+}
+
+// ParseQuery 解析查询：空格分隔的词需全部匹配，"..." 为短语，以 * 结尾的词为前缀查询。
+// 单个汉字无法与两字切分的索引精确匹配，按前缀查询处理
+func ParseQuery(s string) (*Query, error) {
+	q := &Query{Raw: s}
+	add := func(text string, phrase, prefix bool) {
+		if len(q.Clauses) >= maxClauses {
+			return
+		}
+		tokens := Tokenize(text)
+		if len(tokens) == 0 {
+			return
+		}
+		c := &Clause{Text: text, Phrase: phrase, Prefix: prefix}
+		for _, t := range tokens {
+			c.Terms = append(c.Terms, t.Term)
+		}
+		if len(tokens) == 1 {
+			if r, _ := utf8.DecodeRuneInString(c.Terms[0]); isCJK(r) && utf8.RuneCountInString(c.Terms[0]) == 1 {
+				c.Prefix = true
+			}
+		}
+		q.Clauses = append(q.Clauses, c)
+	}
+
+	for s != "" {
+		s = strings.TrimLeft(s, " \t\r\n　")
+		if s == "" {
+			break
+		}
+		if s[0] == '"' {
+			s = s[1:]
+			end := strings.IndexByte(s, '"')
+			if end < 0 {
+				end = len(s)
+			}
+			add(s[:end], true, false)
+			if end < len(s) {
+				end++
+			}
+			s = s[end:]
+			continue
+		}
+		end := strings.IndexAny(s, " \t\r\n　\"")
+		if end < 0 {
+			end = len(s)
+		}
+		word := s[:end]
+		s = s[end:]
+		prefix := strings.HasSuffix(word, "*")
+		add(strings.Trim(word, "*"), false, prefix)
+	}
+
+	if len(q.Clauses) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	return q, nil
+}
+
+// matchTerm 判断第 i 个词是否匹配，前缀查询的最后一个词按前缀匹配
+func (c *Clause) matchTerm(i int, term string) bool {
+	if c.Prefix && i == len(c.Terms)-1 {
+		return strings.HasPrefix(term, c.Terms[i])
+	}
+	return term == c.Terms[i]
+}
+
+// occurrences 返回子句在字段中每次出现的字节范围
+func (c *Clause) occurrences(tokens []Token) [][2]int {
+	var spans [][2]int
+	n := len(c.Terms)
+	for i := 0; i+n <= len(tokens); i++ {
+		ok := true
+		for j := 0; j < n; j++ {
+			if !c.matchTerm(j, tokens[i+j].Term) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			spans = append(spans, [2]int{tokens[i].Start, tokens[i+n-1].End})
+		}
+	}
+	return spans
+}
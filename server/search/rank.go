@@ -0,0 +1,105 @@
+package search
+
+import (
+	"math"
+	"sort"
+)
+
+// BM25参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Stats 计算BM25所需的语料统计
+type Stats struct {
+	Docs    int                // 文档总数
+	DocFreq []int              // 匹配每个子句的文档数，与 Query.Clauses 一一对应
+	AvgLen  map[string]float64 // 各字段的平均词数
+}
+
+// analyzedDoc 分词后的文档
+type analyzedDoc struct {
+	doc    *Document
+	tokens map[string][]Token
+}
+
+func analyze(doc *Document) *analyzedDoc {
+	a := &analyzedDoc{doc: doc, tokens: make(map[string][]Token, len(fields))}
+	for _, f := range fields {
+		a.tokens[f.Name] = Tokenize(doc.Field(f.Name))
+	}
+	return a
+}
+
+// matchClause 判断文档是否有字段匹配子句
+func (a *analyzedDoc) matchClause(c *Clause) bool {
+	for _, f := range fields {
+		if len(c.occurrences(a.tokens[f.Name])) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// score 按字段加权的BM25：每个子句在各字段中的出现次数作为词频，子句的文档频率计算IDF
+func (a *analyzedDoc) score(q *Query, stats *Stats) float64 {
+	total := 0.0
+	for i, c := range q.Clauses {
+		df := 0
+		if i < len(stats.DocFreq) {
+			df = stats.DocFreq[i]
+		}
+		w := idf(stats.Docs, df)
+		for _, f := range fields {
+			tokens := a.tokens[f.Name]
+			tf := float64(len(c.occurrences(tokens)))
+			if tf == 0 {
+				continue
+			}
+			norm := 1.0
+			if avg := stats.AvgLen[f.Name]; avg > 0 {
+				norm = 1 - bm25B + bm25B*float64(len(tokens))/avg
+			}
+			total += f.Weight * w * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+	return total
+}
+
+func idf(docs, df int) float64 {
+	if df > docs {
+		docs = df
+	}
+	return math.Log(1 + (float64(docs-df)+0.5)/(float64(df)+0.5))
+}
+
+// AverageLengths 统计文档各字段的平均词数
+func AverageLengths(docs []*Document) map[string]float64 {
+	avg := make(map[string]float64, len(fields))
+	if len(docs) == 0 {
+		return avg
+	}
+	for _, doc := range docs {
+		for _, f := range fields {
+			avg[f.Name] += float64(len(Tokenize(doc.Field(f.Name))))
+		}
+	}
+	for name := range avg {
+		avg[name] /= float64(len(docs))
+	}
+	return avg
+}
+
+// Rank 按BM25对文档重新排序，相关度相同时保持原有顺序。
+// 用于对外部引擎（如MySQL全文索引）返回的候选文档统一排序
+func Rank(docs []*Document, q *Query, stats *Stats) []*Hit {
+	hits := make([]*Hit, len(docs))
+	for i, doc := range docs {
+		hits[i] = &Hit{ID: doc.ID, Score: analyze(doc).score(q, stats)}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+	return hits
+}
@@ -0,0 +1,121 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRank(t *testing.T) {
+	cases := []struct {
+		name    string
+		docs    []*Document
+		query   string
+		corpus  int   // 文档总数，0 表示候选文档数
+		docFreq []int // 各子句的文档频率，为空时取候选文档数
+		want    []uint
+	}{
+		{
+			name: "title outweighs summary",
+			docs: []*Document{
+				{ID: 1, Title: "Learning", Summary: "go"},
+				{ID: 2, Title: "go", Summary: "Learning"},
+			},
+			query: "go",
+			want:  []uint{2, 1},
+		},
+		{
+			name: "author outweighs publisher",
+			docs: []*Document{
+				{ID: 1, Title: "Tour", Publisher: "Pike"},
+				{ID: 2, Title: "Tour", Author: "Pike"},
+			},
+			query: "pike",
+			want:  []uint{2, 1},
+		},
+		{
+			name: "term frequency",
+			docs: []*Document{
+				{ID: 1, Summary: "go and more tips"},
+				{ID: 2, Summary: "go go go tips"},
+			},
+			query: "go",
+			want:  []uint{2, 1},
+		},
+		{
+			name: "shorter field",
+			docs: []*Document{
+				{ID: 1, Title: "go programming language guide"},
+				{ID: 2, Title: "go"},
+			},
+			query: "go",
+			want:  []uint{2, 1},
+		},
+		{
+			// 罕见词在标题中命中的文档排在常见词在标题中命中的文档之前
+			name: "inverse document frequency",
+			docs: []*Document{
+				{ID: 1, Title: "common", Summary: "rare"},
+				{ID: 2, Title: "rare", Summary: "common"},
+			},
+			query:   "rare common",
+			corpus:  100,
+			docFreq: []int{1, 90},
+			want:    []uint{2, 1},
+		},
+		{
+			name: "phrase",
+			docs: []*Document{
+				{ID: 1, Title: "语言学编程"},
+				{ID: 2, Title: "编程语言"},
+			},
+			query: "编程语言",
+			want:  []uint{2, 1},
+		},
+		{
+			name: "ties keep the input order",
+			docs: []*Document{
+				{ID: 3, Title: "go"},
+				{ID: 1, Title: "go"},
+				{ID: 2, Title: "go"},
+			},
+			query: "go",
+			want:  []uint{3, 1, 2},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			q, err := ParseQuery(c.query)
+			if err != nil {
+				t.Fatalf("ParseQuery: %v", err)
+			}
+			stats := &Stats{Docs: c.corpus, DocFreq: c.docFreq, AvgLen: AverageLengths(c.docs)}
+			if stats.Docs == 0 {
+				stats.Docs = len(c.docs)
+			}
+			if stats.DocFreq == nil {
+				for range q.Clauses {
+					stats.DocFreq = append(stats.DocFreq, len(c.docs))
+				}
+			}
+
+			hits := Rank(c.docs, q, stats)
+			got := make([]uint, len(hits))
+			for i, hit := range hits {
+				got[i] = hit.ID
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("Rank order = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestIDF(t *testing.T) {
+	if rare, common := idf(100, 1), idf(100, 90); rare <= common || common <= 0 {
+		t.Fatalf("idf(100, 1) = %v, idf(100, 90) = %v, want rare > common > 0", rare, common)
+	}
+	// 文档频率超过总数（统计不同步）时仍为正数
+	if v := idf(10, 20); v <= 0 {
+		t.Fatalf("idf(10, 20) = %v, want > 0", v)
+	}
+}
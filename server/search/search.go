@@ -0,0 +1,114 @@
+// Package search 图书全文检索：查询解析、分词、BM25排序和摘要高亮。
+// 检索引擎实现 Engine 接口，MySQL实现基于ngram全文索引，内存实现为自带的倒排索引，用于测试和单实例开发环境
+package search
+
+import (
+	"errors"
+
+	"library/model"
+)
+
+// 引擎名称
+const (
+	EngineMySQL  = "mysql"
+	EngineMemory = "memory"
+)
+
+var (
+	// ErrEmptyQuery 查询中没有可检索的词
+	ErrEmptyQuery = errors.New("search query has no searchable terms")
+	// ErrUnknownEngine 未知的检索引擎
+	ErrUnknownEngine = errors.New("unknown search engine")
+)
+
+// 参与检索的字段
+const (
+	FieldTitle     = "title"
+	FieldAuthor    = "author"
+	FieldPublisher = "publisher"
+	FieldSummary   = "summary"
+)
+
+// fields 参与检索的字段及其在排序中的权重
+var fields = []struct {
+	Name   string
+	Weight float64
+}{
+	{FieldTitle, 3},
+	{FieldAuthor, 2},
+	{FieldPublisher, 0.5},
+	{FieldSummary, 1},
+}
+
+// Document 被检索的图书文本
+type Document struct {
+	ID        uint
+	Category  string
+	Title     string
+	Author    string
+	Publisher string
+	Summary   string
+}
+
+// BookDocument 由图书生成检索文档
+func BookDocument(book *model.Book) *Document {
+	return &Document{
+		ID:        book.ID,
+		Category:  book.Category,
+		Title:     book.Title,
+		Author:    book.Author,
+		Publisher: book.Publisher,
+		Summary:   book.Summary,
+	}
+}
+
+// Field 返回字段的文本
+func (d *Document) Field(name string) string {
+	switch name {
+	case FieldTitle:
+		return d.Title
+	case FieldAuthor:
+		return d.Author
+	case FieldPublisher:
+		return d.Publisher
+	case FieldSummary:
+		return d.Summary
+	}
+	return ""
+}
+
+// Request 检索请求
+type Request struct {
+	Query    *Query // 解析后的查询
+	Category string // 分类筛选，为空时不限
+	Offset   int
+	Limit    int
+}
+
+// Hit 一条检索结果
+type Hit struct {
+	ID         uint              // 图书ID
+	Score      float64           // 相关度
+	Highlights map[string]string // 命中字段的高亮文本，命中的词以 <em></em> 标记
+}
+
+// Result 检索结果
+type Result struct {
+	Total int64  // 匹配的文档数
+	Hits  []*Hit // 当前页的结果，按相关度从高到低排列
+}
+
+// Source 按批提供全部文档，用于重建索引
+type Source func(yield func(docs []*Document) error) error
+
+// Engine 检索引擎接口，新的实现在服务工厂中按配置选择
+type Engine interface {
+	Name() string
+	Search(req *Request) (*Result, error)
+	// Index 新增或更新文档
+	Index(docs ...*Document) error
+	// Remove 删除文档
+	Remove(ids ...uint) error
+	// Rebuild 用 source 提供的文档重建索引，由数据库维护索引的实现可以忽略
+	Rebuild(source Source) error
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"library/config"
+	"library/database"
+)
+
+// 一次性令牌的用途
+const (
+	TokenPurposeResetPassword = "reset_password" // 重置密码
+	TokenPurposeVerifyEmail   = "verify_email"   // 验证邮箱
+)
+
+// accountClaims 一次性令牌的声明。令牌签名防止篡改，jti登记在令牌存储中保证只能使用一次
+type accountClaims struct {
+	Purpose     string `json:"pur"`           // 用途
+	Email       string `json:"email"`         // 签发时的邮箱，验证邮箱时要求与当前邮箱一致
+	Fingerprint string `json:"fpr,omitempty"` // 签发时密码哈希的摘要，密码修改后之前的重置令牌全部失效
+	jwt.RegisteredClaims
+}
+
+// accountTokenKey 一次性令牌的签名密钥，由JWT密钥派生，与登录令牌的密钥不同
+func accountTokenKey() []byte {
+	mac := hmac.New(sha256.New, []byte(config.GlobalConfig.JWT.Secret))
+	mac.Write([]byte("account-token"))
+	return mac.Sum(nil)
+}
+
+// passwordFingerprint 密码哈希的摘要，写入重置令牌，不暴露哈希本身
+func passwordFingerprint(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return hex.EncodeToString(sum[:8])
+}
+
+// issueAccountToken 签发一次性令牌并登记
+func issueAccountToken(purpose string, userID uint, email, fingerprint string, ttl time.Duration) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate token id: %w", err)
+	}
+	jti := hex.EncodeToString(b)
+	now := time.Now()
+
+	claims := accountClaims{
+		Purpose:     purpose,
+		Email:       email,
+		Fingerprint: fingerprint,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			Issuer:    "library",
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(accountTokenKey())
+	if err != nil {
+		return "", fmt.Errorf("sign %s token: %w", purpose, err)
+	}
+	if err := database.SaveOneTimeToken(context.Background(), purpose, jti, userID, ttl); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeAccountToken 校验签名、用途和有效期，并作废令牌。令牌无效、已使用或已过期时返回 ErrInvalidToken
+func consumeAccountToken(purpose, token string) (uint, *accountClaims, error) {
+	claims := new(accountClaims)
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return accountTokenKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return 0, nil, ErrTokenExpired
+		}
+		return 0, nil, ErrInvalidToken
+	}
+	if claims.Purpose != purpose {
+		return 0, nil, ErrInvalidToken
+	}
+
+	userID, ok, err := database.ConsumeOneTimeToken(context.Background(), purpose, claims.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !ok || strconv.FormatUint(uint64(userID), 10) != claims.Subject {
+		return 0, nil, ErrInvalidToken
+	}
+	return userID, claims, nil
+}
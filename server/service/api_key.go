@@ -0,0 +1,295 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"library/config"
+	"library/model"
+	"library/repository/mysql"
+)
+
+type APIKeyServiceInterface interface {
+	CreateKey(actor Actor, key *model.APIKey) (string, error)
+	ListKeys(userID uint) ([]*model.APIKey, error)
+	GetKey(id uint) (*model.APIKey, error)
+	RotateKey(actor Actor, id uint) (*model.APIKey, string, error)
+	RevokeKey(actor Actor, id uint) error
+	AuthenticateAPIKey(key, ip string) (*model.APIKey, *model.User, error)
+}
+
+type APIKeyService struct {
+	apiKeyRepo  mysql.APIKeyRepository
+	userRepo    mysql.UserRepository
+	uow         mysql.UnitOfWork
+	permissions PermissionServiceInterface
+}
+
+func NewAPIKeyService(apiKeyRepo mysql.APIKeyRepository, userRepo mysql.UserRepository, uow mysql.UnitOfWork, permissions PermissionServiceInterface) APIKeyServiceInterface {
+	return &APIKeyService{
+		apiKeyRepo:  apiKeyRepo,
+		userRepo:    userRepo,
+		uow:         uow,
+		permissions: permissions,
+	}
+}
+
+// CreateKey 签发API密钥，返回密钥明文，明文只在此时返回一次。
+// 未指定所属用户时属于签发的管理员；作用域必须是所属用户的角色拥有的权限
+func (s *APIKeyService) CreateKey(actor Actor, key *model.APIKey) (string, error) {
+	key.Name = strings.TrimSpace(key.Name)
+	if key.Name == "" || key.RateLimit < 0 || key.RateBurst < 0 {
+		return "", ErrInvalidParameter
+	}
+	scopes, err := normalizeScopes(key.Scopes)
+	if err != nil {
+		return "", err
+	}
+	key.Scopes = scopes
+	if key.UserID == 0 {
+		key.UserID = actor.ID
+	}
+
+	now := time.Now()
+	maxTTL := config.GlobalConfig.APIKey.MaxTTL()
+	switch {
+	case key.ExpiresAt == nil && maxTTL > 0:
+		expiresAt := now.Add(maxTTL)
+		key.ExpiresAt = &expiresAt
+	case key.ExpiresAt != nil && !key.ExpiresAt.After(now):
+		return "", fmt.Errorf("%w: expires_at must be in the future", ErrInvalidParameter)
+	case key.ExpiresAt != nil && maxTTL > 0 && key.ExpiresAt.After(now.Add(maxTTL)):
+		return "", fmt.Errorf("%w: expires_at exceeds the maximum of %d days", ErrInvalidParameter, config.GlobalConfig.APIKey.MaxExpireDays)
+	}
+
+	prefix, plain, hash, err := generateAPIKey()
+	if err != nil {
+		return "", err
+	}
+	key.Prefix = prefix
+	key.KeyHash = hash
+	key.CreatedBy = actor.ID
+	key.LastUsedAt = nil
+	key.RotatedAt = nil
+	key.RevokedAt = nil
+
+	err = s.uow.Do(func(repos *mysql.Repositories) error {
+		owner, err := repos.User.GetByID(key.UserID)
+		if err != nil {
+			return fmt.Errorf("get user by id: %w", err)
+		}
+		if owner == nil {
+			return ErrNotFound
+		}
+		if owner.Status != 1 {
+			return ErrInvalidStatus
+		}
+		for _, scope := range key.Scopes {
+			ok, err := s.permissions.HasPermission(owner.Role, scope)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("%w: role %s does not have permission %s", ErrInvalidParameter, owner.Role, scope)
+			}
+		}
+
+		if err := repos.APIKey.Create(key); err != nil {
+			return fmt.Errorf("create api key: %w", err)
+		}
+		return writeAudit(repos.AuditLog, actor, AuditAPIKeyCreate, AuditTargetAPIKey, key.ID, map[string]interface{}{
+			"name":       key.Name,
+			"prefix":     key.Prefix,
+			"user_id":    key.UserID,
+			"scopes":     key.Scopes,
+			"expires_at": key.ExpiresAt,
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// ListKeys 获取API密钥列表，userID 为0时返回全部
+func (s *APIKeyService) ListKeys(userID uint) ([]*model.APIKey, error) {
+	return s.apiKeyRepo.List(userID)
+}
+
+// GetKey 获取API密钥
+func (s *APIKeyService) GetKey(id uint) (*model.APIKey, error) {
+	key, err := s.apiKeyRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrNotFound
+	}
+	return key, nil
+}
+
+// RotateKey 轮换API密钥：生成新的密钥明文，旧密钥立即失效，名称、作用域和有效期保持不变
+func (s *APIKeyService) RotateKey(actor Actor, id uint) (*model.APIKey, string, error) {
+	prefix, plain, hash, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var key *model.APIKey
+	err = s.uow.Do(func(repos *mysql.Repositories) error {
+		var err error
+		key, err = repos.APIKey.LockByID(id)
+		if err != nil {
+			return fmt.Errorf("get api key: %w", err)
+		}
+		if key == nil {
+			return ErrNotFound
+		}
+		now := time.Now()
+		if !key.Active(now) {
+			return ErrInvalidStatus
+		}
+
+		oldPrefix := key.Prefix
+		if err := repos.APIKey.Updates(id, map[string]interface{}{
+			"prefix":     prefix,
+			"key_hash":   hash,
+			"rotated_at": now,
+		}); err != nil {
+			return fmt.Errorf("rotate api key: %w", err)
+		}
+		key.Prefix = prefix
+		key.KeyHash = hash
+		key.RotatedAt = &now
+		return writeAudit(repos.AuditLog, actor, AuditAPIKeyRotate, AuditTargetAPIKey, id, map[string]interface{}{
+			"old_prefix": oldPrefix,
+			"prefix":     prefix,
+		})
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return key, plain, nil
+}
+
+// RevokeKey 吊销API密钥，已吊销的密钥再次吊销不做任何操作
+func (s *APIKeyService) RevokeKey(actor Actor, id uint) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		key, err := repos.APIKey.LockByID(id)
+		if err != nil {
+			return fmt.Errorf("get api key: %w", err)
+		}
+		if key == nil {
+			return ErrNotFound
+		}
+		if key.RevokedAt != nil {
+			return nil
+		}
+		if err := repos.APIKey.Updates(id, map[string]interface{}{"revoked_at": time.Now()}); err != nil {
+			return fmt.Errorf("revoke api key: %w", err)
+		}
+		return writeAudit(repos.AuditLog, actor, AuditAPIKeyRevoke, AuditTargetAPIKey, id, map[string]interface{}{
+			"name":   key.Name,
+			"prefix": key.Prefix,
+		})
+	})
+}
+
+// AuthenticateAPIKey 校验请求携带的API密钥，返回密钥和所属用户。
+// 密钥格式错误、不存在、已过期、已吊销或所属用户已禁用时返回 nil, nil, nil
+func (s *APIKeyService) AuthenticateAPIKey(raw, ip string) (*model.APIKey, *model.User, error) {
+	prefix, ok := parseAPIKey(raw)
+	if !ok {
+		return nil, nil, nil
+	}
+	key, err := s.apiKeyRepo.GetByPrefix(prefix)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get api key: %w", err)
+	}
+	if key == nil || subtle.ConstantTimeCompare([]byte(hashAPIKey(raw)), []byte(key.KeyHash)) != 1 {
+		return nil, nil, nil
+	}
+	now := time.Now()
+	if !key.Active(now) {
+		return nil, nil, nil
+	}
+
+	user, err := s.userRepo.GetByID(key.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get user by id: %w", err)
+	}
+	if user == nil || user.Status != 1 {
+		return nil, nil, nil
+	}
+
+	// 最近使用时间只用于管理员识别闲置的密钥，按间隔更新即可，失败不影响本次请求
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= config.GlobalConfig.APIKey.TouchDuration() {
+		if err := s.apiKeyRepo.TouchLastUsed(key.ID, now, ip); err != nil {
+			log.Printf("touch api key %d: %v", key.ID, err)
+		} else {
+			key.LastUsedAt = &now
+			key.LastUsedIP = ip
+		}
+	}
+	return key, user, nil
+}
+
+// normalizeScopes 校验作用域并去重排序。API密钥不能用于管理API密钥
+func normalizeScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool, len(scopes))
+	list := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if _, ok := model.Permissions[scope]; !ok || scope == model.PermAPIKeyManage {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidParameter, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			list = append(list, scope)
+		}
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidParameter)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// generateAPIKey 生成API密钥，格式为 lib_<公开部分>_<随机部分>，返回公开部分、明文和摘要
+func generateAPIKey() (prefix, plain, hash string, err error) {
+	id := make([]byte, 4)
+	secret := make([]byte, 24)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", "", fmt.Errorf("generate api key: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", fmt.Errorf("generate api key: %w", err)
+	}
+	prefix = model.APIKeyPrefix + hex.EncodeToString(id)
+	plain = prefix + "_" + hex.EncodeToString(secret)
+	return prefix, plain, hashAPIKey(plain), nil
+}
+
+// parseAPIKey 从密钥明文中取出公开部分
+func parseAPIKey(raw string) (string, bool) {
+	if !strings.HasPrefix(raw, model.APIKeyPrefix) {
+		return "", false
+	}
+	i := strings.LastIndex(raw, "_")
+	if i <= len(model.APIKeyPrefix) || i == len(raw)-1 {
+		return "", false
+	}
+	return raw[:i], true
+}
+
+// hashAPIKey 密钥明文的SHA-256摘要。密钥是高熵的随机值，不需要慢哈希
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"library/config"
+	"library/model"
+	"library/repository/mysql"
+)
+
+// newTestAPIKeyService 创建API密钥服务，角色权限为默认权限
+func newTestAPIKeyService(t *testing.T, db *gorm.DB) APIKeyServiceInterface {
+	t.Helper()
+	permissions := NewPermissionService(mysql.NewRolePermissionRepository(db), mysql.NewUnitOfWork(db))
+	if err := permissions.SeedDefaults(); err != nil {
+		t.Fatalf("SeedDefaults: %v", err)
+	}
+	return NewAPIKeyService(mysql.NewAPIKeyRepository(db), mysql.NewUserRepository(db), mysql.NewUnitOfWork(db), permissions)
+}
+
+// createTestLibrarian 创建一个启用的馆员
+func createTestLibrarian(t *testing.T, db *gorm.DB, username string) *model.User {
+	t.Helper()
+	user := createTestUser(t, db, username)
+	if err := db.Model(user).Update("role", model.RoleLibrarian).Error; err != nil {
+		t.Fatalf("set role of %s: %v", username, err)
+	}
+	user.Role = model.RoleLibrarian
+	return user
+}
+
+// assertAuthenticated 校验密钥明文可以通过认证，返回密钥
+func assertAuthenticated(t *testing.T, svc APIKeyServiceInterface, plain string) *model.APIKey {
+	t.Helper()
+	key, user, err := svc.AuthenticateAPIKey(plain, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("AuthenticateAPIKey: %v", err)
+	}
+	if key == nil || user == nil {
+		t.Fatalf("AuthenticateAPIKey(%q) rejected a valid key", plain)
+	}
+	return key
+}
+
+// assertRejected 校验密钥明文不能通过认证
+func assertRejected(t *testing.T, svc APIKeyServiceInterface, plain, reason string) {
+	t.Helper()
+	key, user, err := svc.AuthenticateAPIKey(plain, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("AuthenticateAPIKey (%s): %v", reason, err)
+	}
+	if key != nil || user != nil {
+		t.Fatalf("AuthenticateAPIKey accepted %s", reason)
+	}
+}
+
+func TestParseAPIKey(t *testing.T) {
+	cases := []struct {
+		raw    string
+		prefix string
+		ok     bool
+	}{
+		{raw: "lib_0a1b2c3d_secret", prefix: "lib_0a1b2c3d", ok: true},
+		{raw: "lib_0a1b_2c3d_secret", prefix: "lib_0a1b_2c3d", ok: true}, // 公开部分取到最后一个下划线
+		{raw: "lib_0a1b2c3d_", ok: false},                                // 缺少随机部分
+		{raw: "lib__secret", ok: false},                                  // 缺少公开部分
+		{raw: "lib_secret", ok: false},                                   // 只有固定开头
+		{raw: "LIB_0a1b2c3d_secret", ok: false},
+		{raw: "eyJhbGciOiJIUzI1NiJ9.e30.sig", ok: false},
+		{raw: "", ok: false},
+	}
+	for _, c := range cases {
+		prefix, ok := parseAPIKey(c.raw)
+		if ok != c.ok || prefix != c.prefix {
+			t.Errorf("parseAPIKey(%q) = %q, %v, want %q, %v", c.raw, prefix, ok, c.prefix, c.ok)
+		}
+	}
+
+	prefix, plain, hash, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("generateAPIKey: %v", err)
+	}
+	if parsed, ok := parseAPIKey(plain); !ok || parsed != prefix {
+		t.Fatalf("parseAPIKey(generated) = %q, %v, want %q", parsed, ok, prefix)
+	}
+	if len(prefix) > 16 {
+		t.Fatalf("prefix %q does not fit the prefix column", prefix)
+	}
+	if hash != hashAPIKey(plain) || len(hash) != 64 {
+		t.Fatalf("hash = %q, want the SHA-256 hex of the plain key", hash)
+	}
+}
+
+func TestAPIKeyAuthenticate(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestLibrarian(t, db, "kiosk")
+	svc := newTestAPIKeyService(t, db)
+
+	key := &model.APIKey{Name: "一楼自助借还机", UserID: owner.ID, Scopes: []string{model.PermCopyRead, model.PermBorrowCheckout}}
+	plain, err := svc.CreateKey(SystemActor, key)
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	if !strings.HasPrefix(plain, key.Prefix+"_") {
+		t.Fatalf("plain key %q does not start with prefix %q", plain, key.Prefix)
+	}
+
+	// 只保存摘要，按公开部分查找
+	stored, err := mysql.NewAPIKeyRepository(db).GetByPrefix(key.Prefix)
+	if err != nil || stored == nil {
+		t.Fatalf("GetByPrefix = %v, %v", stored, err)
+	}
+	if stored.KeyHash != hashAPIKey(plain) || strings.Contains(stored.KeyHash, plain) {
+		t.Fatalf("stored hash %q is not the digest of the key", stored.KeyHash)
+	}
+	if stored.UserID != owner.ID {
+		t.Fatalf("key owner = %d, want %d", stored.UserID, owner.ID)
+	}
+
+	got := assertAuthenticated(t, svc, plain)
+	if got.ID != key.ID || got.LastUsedAt == nil || got.LastUsedIP != "192.0.2.1" {
+		t.Fatalf("authenticated key = %+v, want id %d with last use recorded", got, key.ID)
+	}
+
+	secret := plain[len(key.Prefix)+1:]
+	assertRejected(t, svc, key.Prefix+"_"+strings.Repeat("0", len(secret)), "a wrong secret with a known prefix")
+	assertRejected(t, svc, "lib_ffffffff_"+secret, "an unknown prefix")
+	assertRejected(t, svc, plain[len(model.APIKeyPrefix):], "a key without the fixed prefix")
+	assertRejected(t, svc, key.Prefix+"_", "a key without a secret")
+
+	// 所属用户禁用后密钥不可用
+	if err := db.Model(owner).Update("status", 0).Error; err != nil {
+		t.Fatalf("disable owner: %v", err)
+	}
+	assertRejected(t, svc, plain, "a key of a disabled user")
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestLibrarian(t, db, "kiosk")
+	reader := createTestUser(t, db, "reader")
+	svc := newTestAPIKeyService(t, db)
+
+	cases := []struct {
+		name   string
+		userID uint
+		scopes []string
+		want   []string
+		err    error
+	}{
+		{name: "deduplicated and sorted", userID: owner.ID,
+			scopes: []string{model.PermCopyRead, model.PermBorrowCheckout, model.PermCopyRead},
+			want:   []string{model.PermBorrowCheckout, model.PermCopyRead}},
+		{name: "empty", userID: owner.ID, err: ErrInvalidParameter},
+		{name: "unknown scope", userID: owner.ID, scopes: []string{"book:burn"}, err: ErrInvalidParameter},
+		{name: "api key management", userID: owner.ID, scopes: []string{model.PermAPIKeyManage}, err: ErrInvalidParameter},
+		{name: "beyond the owner's role", userID: owner.ID, scopes: []string{model.PermFineManage}, err: ErrInvalidParameter},
+		{name: "reader without permissions", userID: reader.ID, scopes: []string{model.PermCopyRead}, err: ErrInvalidParameter},
+		{name: "unknown owner", userID: 9999, scopes: []string{model.PermCopyRead}, err: ErrNotFound},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			key := &model.APIKey{Name: c.name, UserID: c.userID, Scopes: c.scopes}
+			_, err := svc.CreateKey(SystemActor, key)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("CreateKey err = %v, want %v", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateKey: %v", err)
+			}
+			if strings.Join(key.Scopes, ",") != strings.Join(c.want, ",") {
+				t.Fatalf("scopes = %v, want %v", key.Scopes, c.want)
+			}
+			if !key.HasScope(model.PermCopyRead) || key.HasScope(model.PermBookWrite) {
+				t.Fatalf("HasScope does not match scopes %v", key.Scopes)
+			}
+		})
+	}
+}
+
+func TestAPIKeyExpiry(t *testing.T) {
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.APIKey.MaxExpireDays = 30
+	})
+	db := newTestDB(t)
+	owner := createTestLibrarian(t, db, "kiosk")
+	svc := newTestAPIKeyService(t, db)
+	newKey := func() *model.APIKey {
+		return &model.APIKey{Name: "kiosk", UserID: owner.ID, Scopes: []string{model.PermCopyRead}}
+	}
+
+	// 未指定过期时间时使用有效期上限
+	key := newKey()
+	plain, err := svc.CreateKey(SystemActor, key)
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	if key.ExpiresAt == nil || key.ExpiresAt.Sub(time.Now()) < 29*24*time.Hour || key.ExpiresAt.Sub(time.Now()) > 30*24*time.Hour {
+		t.Fatalf("ExpiresAt = %v, want about 30 days from now", key.ExpiresAt)
+	}
+	assertAuthenticated(t, svc, plain)
+
+	past := time.Now().Add(-time.Minute)
+	tooLate := time.Now().Add(31 * 24 * time.Hour)
+	for _, expiresAt := range []time.Time{past, tooLate} {
+		invalid := newKey()
+		invalid.ExpiresAt = &expiresAt
+		if _, err := svc.CreateKey(SystemActor, invalid); !errors.Is(err, ErrInvalidParameter) {
+			t.Fatalf("CreateKey expiring at %v err = %v, want ErrInvalidParameter", expiresAt, err)
+		}
+	}
+
+	// 过期后不可用，也不能轮换
+	if err := db.Model(&model.APIKey{}).Where("id = ?", key.ID).Update("expires_at", past).Error; err != nil {
+		t.Fatalf("expire key: %v", err)
+	}
+	assertRejected(t, svc, plain, "an expired key")
+	if _, _, err := svc.RotateKey(SystemActor, key.ID); !errors.Is(err, ErrInvalidStatus) {
+		t.Fatalf("RotateKey of an expired key err = %v, want ErrInvalidStatus", err)
+	}
+}
+
+func TestAPIKeyRotateAndRevoke(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestLibrarian(t, db, "kiosk")
+	svc := newTestAPIKeyService(t, db)
+
+	key := &model.APIKey{Name: "kiosk", UserID: owner.ID, Scopes: []string{model.PermCopyRead}}
+	oldPlain, err := svc.CreateKey(SystemActor, key)
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+
+	// 轮换后旧密钥立即失效
+	rotated, newPlain, err := svc.RotateKey(SystemActor, key.ID)
+	if err != nil {
+		t.Fatalf("RotateKey: %v", err)
+	}
+	if rotated.Prefix == key.Prefix || rotated.RotatedAt == nil {
+		t.Fatalf("rotated key = %+v, want a new prefix and rotation time", rotated)
+	}
+	assertRejected(t, svc, oldPlain, "a rotated key")
+	assertAuthenticated(t, svc, newPlain)
+
+	if err := svc.RevokeKey(SystemActor, key.ID); err != nil {
+		t.Fatalf("RevokeKey: %v", err)
+	}
+	assertRejected(t, svc, newPlain, "a revoked key")
+	// 重复吊销不报错，已吊销的密钥不能轮换
+	if err := svc.RevokeKey(SystemActor, key.ID); err != nil {
+		t.Fatalf("RevokeKey again: %v", err)
+	}
+	if _, _, err := svc.RotateKey(SystemActor, key.ID); !errors.Is(err, ErrInvalidStatus) {
+		t.Fatalf("RotateKey of a revoked key err = %v, want ErrInvalidStatus", err)
+	}
+	if err := svc.RevokeKey(SystemActor, 9999); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("RevokeKey of an unknown key err = %v, want ErrNotFound", err)
+	}
+}
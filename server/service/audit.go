@@ -0,0 +1,297 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"library/model"
+	"library/repository/mysql"
+)
+
+// 审计记录的操作对象类型
+const (
+	AuditTargetUser        = "user"
+	AuditTargetRole        = "role"
+	AuditTargetAPIKey      = "api_key"
+	AuditTargetBook        = "book"
+	AuditTargetCopy        = "copy"
+	AuditTargetBorrow      = "borrow"
+	AuditTargetReview      = "review"
+	AuditTargetReservation = "reservation"
+	AuditTargetLoanPolicy  = "loan_policy"
+	AuditTargetFine        = "fine"
+)
+
+// 审计记录的操作
+const (
+	AuditUserRole           = "user.role"        // 修改角色
+	AuditUserStatus         = "user.status"      // 启用或禁用账号
+	AuditUserDelete         = "user.delete"      // 删除账号
+	AuditUserBootstrap      = "user.bootstrap"   // 初始化管理员
+	AuditUserUnlock         = "user.unlock"      // 解除登录锁定
+	AuditUserTwoFactorReset = "user.2fa_reset"   // 重置双因素认证
+	AuditUserProfile        = "user.profile"     // 修改个人资料
+	AuditUserPassword       = "user.password"    // 修改密码
+	AuditRolePermissions    = "role.permissions" // 修改角色权限
+	AuditAPIKeyCreate       = "api_key.create"   // 签发API密钥
+	AuditAPIKeyRotate       = "api_key.rotate"   // 轮换API密钥
+	AuditAPIKeyRevoke       = "api_key.revoke"   // 吊销API密钥
+
+	AuditBookCreate        = "book.create"        // 新增图书
+	AuditBookUpdate        = "book.update"        // 修改图书信息
+	AuditBookDelete        = "book.delete"        // 删除图书
+	AuditBookStatus        = "book.status"        // 上架或下架
+	AuditBookStock         = "book.stock"         // 调整库存
+	AuditBookMerge         = "book.merge"         // ISBN规范化时合并重复图书
+	AuditCopyCreate        = "copy.create"        // 新增副本
+	AuditCopyUpdate        = "copy.update"        // 修改副本
+	AuditCopyDelete        = "copy.delete"        // 删除副本
+	AuditBorrowCreate      = "borrow.create"      // 借阅
+	AuditBorrowReturn      = "borrow.return"      // 归还
+	AuditBorrowRenew       = "borrow.renew"       // 续借
+	AuditBorrowUpdate      = "borrow.update"      // 修改借阅记录（如应还日期）
+	AuditReviewCreate      = "review.create"      // 发表评论
+	AuditReviewUpdate      = "review.update"      // 修改评论
+	AuditReviewDelete      = "review.delete"      // 删除评论
+	AuditReviewStatus      = "review.status"      // 隐藏或显示评论
+	AuditReservationCreate = "reservation.create" // 预约
+	AuditReservationCancel = "reservation.cancel" // 取消预约
+	AuditLoanPolicyCreate  = "loan_policy.create" // 新增借阅规则
+	AuditLoanPolicyUpdate  = "loan_policy.update" // 修改借阅规则
+	AuditLoanPolicyDelete  = "loan_policy.delete" // 删除借阅规则
+	AuditFineTransaction   = "fine.transaction"   // 罚款、缴费、减免、退款
+)
+
+// Actor 发起操作的用户和请求，写入审计记录
+type Actor struct {
+	ID        uint   // 操作人ID，0表示系统
+	IP        string // 操作人IP
+	RequestID string // 请求ID
+}
+
+// SystemActor 后台任务等系统操作
+var SystemActor = Actor{}
+
+// auditChange 字段变更
+type auditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// auditIgnoredFields 不记录变更的字段
+var auditIgnoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"deleted_at": true,
+}
+
+// writeAudit 写入一条审计记录，detail 序列化为JSON保存。
+// 与被审计的变更在同一事务中写入，变更回滚时审计记录一并回滚；提交后由封链任务串入哈希链
+func writeAudit(auditRepo mysql.AuditLogRepository, actor Actor, action, targetType string, targetID uint, detail interface{}) error {
+	return appendAudit(auditRepo, actor, action, targetType, targetID, "", detail)
+}
+
+// writeAuditChange 比较变更前后的对象，写入字段变更和详情。before 为 nil 表示新建，after 为 nil 表示删除。
+// 没有字段变化时不写入。对象按JSON序列化后比较，json:"-" 的字段（如密码）不会被记录
+func writeAuditChange(auditRepo mysql.AuditLogRepository, actor Actor, action, targetType string, targetID uint, before, after, detail interface{}) error {
+	changes, err := auditDiff(before, after)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("marshal audit changes: %w", err)
+	}
+	return appendAudit(auditRepo, actor, action, targetType, targetID, string(data), detail)
+}
+
+func appendAudit(auditRepo mysql.AuditLogRepository, actor Actor, action, targetType string, targetID uint, changes string, detail interface{}) error {
+	log := &model.AuditLog{
+		ActorID:    actor.ID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Changes:    changes,
+		IP:         actor.IP,
+		RequestID:  actor.RequestID,
+	}
+	if detail != nil {
+		data, err := json.Marshal(detail)
+		if err != nil {
+			return fmt.Errorf("marshal audit detail: %w", err)
+		}
+		log.Detail = string(data)
+	}
+	if err := auditRepo.Create(log); err != nil {
+		return fmt.Errorf("create audit log: %w", err)
+	}
+	return nil
+}
+
+// auditDiff 比较两个对象的顶层字段，忽略时间戳和关联对象
+func auditDiff(before, after interface{}) (map[string]auditChange, error) {
+	b, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]auditChange)
+	for k, av := range a {
+		if bv, ok := b[k]; !ok || !reflect.DeepEqual(av, bv) {
+			changes[k] = auditChange{Before: b[k], After: av}
+		}
+	}
+	for k, bv := range b {
+		if _, ok := a[k]; !ok {
+			changes[k] = auditChange{Before: bv}
+		}
+	}
+	return changes, nil
+}
+
+// auditFields 将对象序列化为字段表
+func auditFields(v interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil() {
+		return fields, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal audit object: %w", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("unmarshal audit object: %w", err)
+	}
+	for k, val := range raw {
+		if auditIgnoredFields[k] {
+			continue
+		}
+		// 预加载的关联对象（如借阅记录中的图书）不属于本对象的字段
+		if _, nested := val.(map[string]interface{}); nested {
+			continue
+		}
+		fields[k] = val
+	}
+	return fields, nil
+}
+
+// auditLogBatchSize 校验哈希链时每批读取的记录数，也是封链时每个事务处理的记录数
+const auditLogBatchSize = 1000
+
+// JobAuditSeal 审计记录封链任务名称
+const JobAuditSeal = "audit_seal"
+
+type AuditServiceInterface interface {
+	ListLogs(filter *mysql.AuditLogFilter) ([]*model.AuditLog, int64, error)
+	SealChain() (int, error)
+	VerifyChain() (*model.AuditChainReport, error)
+}
+
+type AuditService struct {
+	auditLogRepo mysql.AuditLogRepository
+}
+
+func NewAuditService(auditLogRepo mysql.AuditLogRepository) AuditServiceInterface {
+	return &AuditService{
+		auditLogRepo: auditLogRepo,
+	}
+}
+
+// ListLogs 按条件分页查询审计记录
+func (s *AuditService) ListLogs(filter *mysql.AuditLogFilter) ([]*model.AuditLog, int64, error) {
+	if filter.Page < 1 || filter.PageSize < 1 {
+		return nil, 0, ErrInvalidParameter
+	}
+	return s.auditLogRepo.List(filter)
+}
+
+// SealChain 将已提交、尚未封链的审计记录依次串入哈希链，返回封链的数量。
+// 摘要只由该任务计算，业务事务写入审计记录时不锁定链头，互相之间不会等待。
+// 封链是单一写入者，每个事务逐条更新最多 auditLogBatchSize 条记录，吞吐量受单个连接的写入速度限制。
+// 记录在提交后最多延迟一个任务间隔才串入哈希链，期间对记录的修改不会被校验发现。
+// 多实例部署时由任务锁保证只有一个实例执行，未配置Redis时依靠链头的行锁串行执行
+func (s *AuditService) SealChain() (int, error) {
+	total := 0
+	for {
+		n, err := s.auditLogRepo.Seal(auditLogBatchSize)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("seal audit logs: %w", err)
+		}
+		if n < auditLogBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// VerifyChain 按序号顺序重新计算每条记录的摘要，检查记录是否被修改、删除或插入。
+// 最后一条记录与链头比对，以发现末尾记录被删除的情况。尚未封链的记录没有摘要，不参与校验
+func (s *AuditService) VerifyChain() (*model.AuditChainReport, error) {
+	report := &model.AuditChainReport{Valid: true}
+	var prevHash string
+	var lastID uint
+	var lastSeq uint64
+	chained := false
+
+	broken := func(log *model.AuditLog, reason string) {
+		report.Valid = false
+		report.BrokenAt = log.ID
+		report.Reason = reason
+	}
+	err := s.auditLogRepo.Walk(auditLogBatchSize, func(logs []*model.AuditLog) error {
+		for _, log := range logs {
+			if !report.Valid {
+				return nil
+			}
+			chained = true
+			report.Checked++
+			switch {
+			case log.Hash == "":
+				broken(log, "missing hash")
+			case log.PrevHash != prevHash:
+				broken(log, "previous hash mismatch, a record before it was changed, removed or inserted")
+			case log.ComputeHash() != log.Hash:
+				broken(log, "hash mismatch, the record was changed")
+			}
+			prevHash = log.Hash
+			lastID = log.ID
+			lastSeq = log.Seq
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk audit logs: %w", err)
+	}
+	if !report.Valid {
+		return report, nil
+	}
+
+	report.Pending, err = s.auditLogRepo.CountPending()
+	if err != nil {
+		return nil, fmt.Errorf("count pending audit logs: %w", err)
+	}
+
+	head, err := s.auditLogRepo.GetChainHead()
+	if err != nil {
+		return nil, fmt.Errorf("get audit chain head: %w", err)
+	}
+	switch {
+	case head == nil && chained:
+		report.Valid = false
+		report.Reason = "chain head missing"
+	case head != nil && (head.LastHash != prevHash || head.LastID != lastID || head.LastSeq != lastSeq):
+		report.Valid = false
+		report.BrokenAt = lastID
+		report.Reason = "chain head mismatch, records at the end were removed"
+	}
+	return report, nil
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"library/model"
+	"library/repository/mysql"
+)
+
+// verifyAuditChain 校验哈希链，检查结果与期望一致
+func verifyAuditChain(t *testing.T, svc AuditServiceInterface, want model.AuditChainReport) {
+	t.Helper()
+	report, err := svc.VerifyChain()
+	if err != nil {
+		t.Fatalf("VerifyChain: %v", err)
+	}
+	if report.Valid != want.Valid || report.Checked != want.Checked || report.Pending != want.Pending ||
+		report.BrokenAt != want.BrokenAt {
+		t.Fatalf("VerifyChain = %+v, want %+v", *report, want)
+	}
+}
+
+func sealAuditChain(t *testing.T, svc AuditServiceInterface, want int) {
+	t.Helper()
+	n, err := svc.SealChain()
+	if err != nil {
+		t.Fatalf("SealChain: %v", err)
+	}
+	if n != want {
+		t.Fatalf("SealChain sealed %d records, want %d", n, want)
+	}
+}
+
+func TestAuditSealChain(t *testing.T) {
+	db := newTestDB(t)
+	repo := mysql.NewAuditLogRepository(db)
+	svc := NewAuditService(repo)
+	verifyAuditChain(t, svc, model.AuditChainReport{Valid: true})
+
+	// 写入时不计算摘要，等待封链
+	for i := 0; i < 3; i++ {
+		if err := writeAudit(repo, SystemActor, AuditBookCreate, AuditTargetBook, uint(i+1), nil); err != nil {
+			t.Fatalf("writeAudit: %v", err)
+		}
+	}
+	verifyAuditChain(t, svc, model.AuditChainReport{Valid: true, Pending: 3})
+
+	sealAuditChain(t, svc, 3)
+	verifyAuditChain(t, svc, model.AuditChainReport{Valid: true, Checked: 3})
+	sealAuditChain(t, svc, 0)
+
+	// 事务提交晚于更大ID的记录时，在之后的批次中接到链尾
+	late := &model.AuditLog{ID: 100, Action: AuditBookUpdate, TargetType: AuditTargetBook, TargetID: 1}
+	if err := repo.Create(late); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	sealAuditChain(t, svc, 1)
+	early := &model.AuditLog{ID: 50, Action: AuditBookUpdate, TargetType: AuditTargetBook, TargetID: 2}
+	if err := repo.Create(early); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	sealAuditChain(t, svc, 1)
+	verifyAuditChain(t, svc, model.AuditChainReport{Valid: true, Checked: 5})
+
+	var sealed model.AuditLog
+	if err := db.First(&sealed, early.ID).Error; err != nil {
+		t.Fatalf("load audit log: %v", err)
+	}
+	if sealed.Seq != 5 || sealed.Hash == "" {
+		t.Fatalf("late committed record seq = %d, hash = %q, want seq 5 with a hash", sealed.Seq, sealed.Hash)
+	}
+	head, err := repo.GetChainHead()
+	if err != nil || head == nil {
+		t.Fatalf("GetChainHead = %v, %v", head, err)
+	}
+	if head.LastID != early.ID || head.LastSeq != 5 || head.LastHash != sealed.Hash {
+		t.Fatalf("chain head = %+v, want the late committed record", *head)
+	}
+}
+
+func TestAuditChainDetectsTampering(t *testing.T) {
+	cases := []struct {
+		name     string
+		tamper   func(db *gorm.DB) error
+		brokenAt uint
+	}{
+		{
+			name: "record changed",
+			tamper: func(db *gorm.DB) error {
+				return db.Model(&model.AuditLog{}).Where("id = ?", 2).Update("detail", `{"forged":true}`).Error
+			},
+			brokenAt: 2,
+		},
+		{
+			name: "record removed",
+			tamper: func(db *gorm.DB) error {
+				return db.Delete(&model.AuditLog{}, 2).Error
+			},
+			brokenAt: 3,
+		},
+		{
+			name: "last record removed",
+			tamper: func(db *gorm.DB) error {
+				return db.Delete(&model.AuditLog{}, 3).Error
+			},
+			brokenAt: 2,
+		},
+		{
+			name: "records reordered",
+			tamper: func(db *gorm.DB) error {
+				if err := db.Model(&model.AuditLog{}).Where("id = ?", 1).Update("seq", 10).Error; err != nil {
+					return err
+				}
+				return db.Model(&model.AuditLog{}).Where("id = ?", 2).Update("seq", 1).Error
+			},
+			brokenAt: 2,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			db := newTestDB(t)
+			repo := mysql.NewAuditLogRepository(db)
+			svc := NewAuditService(repo)
+			for i := 0; i < 3; i++ {
+				if err := writeAudit(repo, SystemActor, AuditBookCreate, AuditTargetBook, uint(i+1), map[string]int{"n": i}); err != nil {
+					t.Fatalf("writeAudit: %v", err)
+				}
+			}
+			sealAuditChain(t, svc, 3)
+
+			if err := c.tamper(db); err != nil {
+				t.Fatalf("tamper: %v", err)
+			}
+			report, err := svc.VerifyChain()
+			if err != nil {
+				t.Fatalf("VerifyChain: %v", err)
+			}
+			if report.Valid || report.BrokenAt != c.brokenAt {
+				t.Fatalf("VerifyChain = %+v, want broken at %d", *report, c.brokenAt)
+			}
+		})
+	}
+}
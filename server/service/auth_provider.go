@@ -0,0 +1,395 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"library/config"
+	"library/database"
+	"library/model"
+	"library/password"
+	"library/repository/mysql"
+)
+
+// 登录方式
+const (
+	ProviderKindPassword = "password" // 用户名密码登录
+	ProviderKindRedirect = "redirect" // 跳转到身份提供方登录（OIDC）
+)
+
+// ProviderLocal 本地账号密码登录
+const ProviderLocal = "local"
+
+// AuthProvider 认证提供方
+type AuthProvider interface {
+	Name() string        // 提供方标识
+	DisplayName() string // 登录页面显示的名称
+	Kind() string        // 登录方式 password/redirect
+}
+
+// PasswordProvider 用户名密码方式的认证提供方，认证成功返回本地用户
+type PasswordProvider interface {
+	AuthProvider
+	Authenticate(username, password, ip string) (*model.User, error)
+}
+
+// RedirectProvider 跳转方式的认证提供方（授权码+PKCE），回调后返回外部身份，由 AuthService 关联或创建本地用户
+type RedirectProvider interface {
+	AuthProvider
+	AuthCodeURL(state, nonce, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*ExternalUser, error)
+}
+
+// ExternalUser 身份提供方返回的用户信息
+type ExternalUser struct {
+	Subject       string // 提供方中的用户唯一标识
+	Email         string // 邮箱
+	EmailVerified bool   // 提供方是否已验证邮箱
+	Username      string // 建议的用户名
+	Nickname      string // 显示名称
+	Phone         string // 电话
+}
+
+// ProviderInfo 登录页面展示的认证提供方
+type ProviderInfo struct {
+	Name        string `json:"name"`         // 提供方标识
+	DisplayName string `json:"display_name"` // 显示名称
+	Kind        string `json:"kind"`         // 登录方式 password/redirect
+}
+
+// oauthState 发起单点登录时保存的参数，回调时按state取出
+type oauthState struct {
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+}
+
+type AuthServiceInterface interface {
+	Providers() []ProviderInfo
+	Login(provider, username, password, ip string) (*model.User, error)
+	StartLogin(provider string) (string, error)
+	FinishLogin(provider, state, code string) (*model.User, error)
+	ListIdentities(userID uint) ([]*model.ExternalIdentity, error)
+}
+
+type authService struct {
+	providers []AuthProvider
+	uow       mysql.UnitOfWork
+	hasher    password.Hasher
+}
+
+// NewAuthService 创建认证服务，providers 按登录页面展示的顺序排列
+func NewAuthService(uow mysql.UnitOfWork, hasher password.Hasher, providers ...AuthProvider) AuthServiceInterface {
+	return &authService{
+		providers: providers,
+		uow:       uow,
+		hasher:    hasher,
+	}
+}
+
+// Providers 已配置的认证提供方
+func (s *authService) Providers() []ProviderInfo {
+	list := make([]ProviderInfo, 0, len(s.providers))
+	for _, p := range s.providers {
+		list = append(list, ProviderInfo{Name: p.Name(), DisplayName: p.DisplayName(), Kind: p.Kind()})
+	}
+	return list
+}
+
+// provider 按标识查找认证提供方，未指定时使用本地账号
+func (s *authService) provider(name string) (AuthProvider, error) {
+	if name == "" {
+		name = ProviderLocal
+	}
+	for _, p := range s.providers {
+		if p.Name() == name {
+			return p, nil
+		}
+	}
+	return nil, ErrUnknownProvider
+}
+
+// Login 用户名密码登录
+func (s *authService) Login(provider, username, password, ip string) (*model.User, error) {
+	p, err := s.provider(provider)
+	if err != nil {
+		return nil, err
+	}
+	pp, ok := p.(PasswordProvider)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return pp.Authenticate(username, password, ip)
+}
+
+// StartLogin 发起单点登录，生成state、nonce和PKCE verifier并保存，返回身份提供方的登录地址
+func (s *authService) StartLogin(provider string) (string, error) {
+	p, err := s.redirectProvider(provider)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	data, err := json.Marshal(oauthState{Provider: p.Name(), Verifier: verifier, Nonce: nonce})
+	if err != nil {
+		return "", fmt.Errorf("marshal oauth state: %w", err)
+	}
+	if err := database.SaveOAuthState(context.Background(), state, string(data), config.GlobalConfig.SSO.StateTTL()); err != nil {
+		return "", err
+	}
+	return p.AuthCodeURL(state, nonce, oauth2.S256ChallengeFromVerifier(verifier))
+}
+
+// FinishLogin 处理身份提供方的回调：校验state，用授权码换取身份，
+// 已关联的外部身份直接登录，首次登录时关联或创建本地账号
+func (s *authService) FinishLogin(provider, state, code string) (*model.User, error) {
+	p, err := s.redirectProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	value, ok, err := database.ConsumeOAuthState(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	var st oauthState
+	if err := json.Unmarshal([]byte(value), &st); err != nil || st.Provider != p.Name() {
+		return nil, ErrInvalidToken
+	}
+
+	ext, err := p.Exchange(ctx, code, st.Verifier, st.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *model.User
+	err = s.uow.Do(func(repos *mysql.Repositories) error {
+		var err error
+		user, err = s.resolveUser(repos, p.Name(), ext)
+		if err != nil {
+			return err
+		}
+		if user.Status != 1 {
+			return ErrAccountDisabled
+		}
+		user.LastLoginAt = time.Now()
+		if err := repos.User.Update(user); err != nil {
+			return fmt.Errorf("update last login time: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ListIdentities 用户关联的外部身份
+func (s *authService) ListIdentities(userID uint) ([]*model.ExternalIdentity, error) {
+	var identities []*model.ExternalIdentity
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		var err error
+		identities, err = repos.Identity.ListByUser(userID)
+		return err
+	})
+	return identities, err
+}
+
+// redirectProvider 按标识查找跳转方式的认证提供方
+func (s *authService) redirectProvider(name string) (RedirectProvider, error) {
+	p, err := s.provider(name)
+	if err != nil {
+		return nil, err
+	}
+	rp, ok := p.(RedirectProvider)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return rp, nil
+}
+
+// resolveUser 查找外部身份关联的本地用户，首次登录时按配置关联同邮箱的账号或创建新账号
+func (s *authService) resolveUser(repos *mysql.Repositories, provider string, ext *ExternalUser) (*model.User, error) {
+	identity, err := repos.Identity.GetBySubject(provider, ext.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("get external identity: %w", err)
+	}
+	if identity != nil {
+		user, err := repos.User.LockByID(identity.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("get user by id: %w", err)
+		}
+		if user == nil {
+			return nil, ErrAccountDisabled
+		}
+		if err := repos.Identity.UpdateLogin(identity.ID, ext.Email); err != nil {
+			return nil, fmt.Errorf("update external identity: %w", err)
+		}
+		return user, nil
+	}
+
+	var user *model.User
+	if ext.Email != "" && ext.EmailVerified && linkByEmail(provider) {
+		user, err = repos.User.GetByEmail(ext.Email)
+		if err != nil {
+			return nil, fmt.Errorf("get user by email: %w", err)
+		}
+	}
+	if user == nil {
+		user, err = provisionUser(repos.User, s.hasher, ext, config.GlobalConfig.SSO.DefaultRole)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := repos.Identity.Create(&model.ExternalIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  ext.Subject,
+		Email:    ext.Email,
+	}); err != nil {
+		return nil, fmt.Errorf("create external identity: %w", err)
+	}
+	return user, nil
+}
+
+// provisionUser 为首次登录的外部身份创建本地账号。
+// 密码为随机值，用户只能通过外部身份登录，或通过找回密码设置本地密码
+func provisionUser(userRepo mysql.UserRepository, hasher password.Hasher, ext *ExternalUser, role string) (*model.User, error) {
+	username, err := availableUsername(userRepo, ext)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := hasher.Hash(plain)
+	if err != nil {
+		return nil, fmt.Errorf("hash password: %w", err)
+	}
+
+	user := &model.User{
+		Username: username,
+		Password: hash,
+		Nickname: ext.Nickname,
+		Phone:    ext.Phone,
+		Role:     role,
+		Status:   1,
+	}
+	// 邮箱已被其他账号使用时不填写，避免两个账号共用同一邮箱
+	if ext.Email != "" {
+		exist, err := userRepo.GetByEmail(ext.Email)
+		if err != nil {
+			return nil, fmt.Errorf("get user by email: %w", err)
+		}
+		if exist == nil {
+			user.Email = ext.Email
+		}
+	}
+	if err := userRepo.Create(user); err != nil {
+		return nil, fmt.Errorf("create user: %w", err)
+	}
+	if ext.EmailVerified && user.Email != "" {
+		now := time.Now()
+		if err := userRepo.SetEmailVerifiedAt(user.ID, &now); err != nil {
+			return nil, fmt.Errorf("set email verified: %w", err)
+		}
+		user.EmailVerifiedAt = &now
+	}
+	return user, nil
+}
+
+// usernameInvalid 用户名中不允许的字符
+var usernameInvalid = regexp.MustCompile(`[^A-Za-z0-9_.\-]+`)
+
+// availableUsername 由外部身份建议的用户名生成未被使用的用户名，已被使用时追加随机后缀
+func availableUsername(userRepo mysql.UserRepository, ext *ExternalUser) (string, error) {
+	base := ext.Username
+	if base == "" && ext.Email != "" {
+		base = strings.SplitN(ext.Email, "@", 2)[0]
+	}
+	base = usernameInvalid.ReplaceAllString(base, "")
+	if len(base) > 24 {
+		base = base[:24]
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		exist, err := userRepo.GetByUsername(candidate)
+		if err != nil {
+			return "", fmt.Errorf("check username exists: %w", err)
+		}
+		if exist == nil {
+			return candidate, nil
+		}
+		b := make([]byte, 3)
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("generate username suffix: %w", err)
+		}
+		candidate = base + "_" + hex.EncodeToString(b)
+	}
+	return "", ErrAlreadyExists
+}
+
+// linkByEmail 提供方是否允许按已验证的邮箱关联已有账号
+func linkByEmail(provider string) bool {
+	for _, c := range config.GlobalConfig.SSO.OIDC {
+		if c.Name == provider {
+			return c.LinkByEmail
+		}
+	}
+	return false
+}
+
+// randomToken 生成随机的state、nonce等
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate random token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// localProvider 本地账号密码登录，保持原有的登录流程（失败计数、账号锁定、密码升级）
+type localProvider struct {
+	users UserServiceInterface
+}
+
+// NewLocalProvider 创建本地账号认证提供方
+func NewLocalProvider(users UserServiceInterface) PasswordProvider {
+	return &localProvider{users: users}
+}
+
+func (p *localProvider) Name() string        { return ProviderLocal }
+func (p *localProvider) DisplayName() string { return "用户名密码" }
+func (p *localProvider) Kind() string        { return ProviderKindPassword }
+
+// Authenticate 校验本地账号的用户名和密码
+func (p *localProvider) Authenticate(username, password, ip string) (*model.User, error) {
+	return p.users.Login(username, password, ip)
+}
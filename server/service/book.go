@@ -2,38 +2,71 @@ package service
 
 import (
 	"fmt"
-	"gorm.io/gorm"
+	"io"
+	"library/isbn"
 	"library/model"
 	"library/repository/mysql"
 )
 
 // BookServiceInterface 图书服务接口
 type BookServiceInterface interface {
-	CreateBook( book *model.Book) error
-	UpdateBook( book *model.Book) error
-	DeleteBook( id uint) error
+	CreateBook(actor Actor, book *model.Book) error
+	UpdateBook(actor Actor, book *model.Book) error
+	DeleteBook(actor Actor, id uint) error
 	GetBook( id uint) (*model.Book, error)
 	ListBooks( params *model.SearchParams) ([]*model.Book, int64, error)
-	UpdateBookStatus( id uint, status int) error
-	UpdateBookStock( id uint, change int) error
+	UpdateBookStatus(actor Actor, id uint, status int) error
+	UpdateBookStock(actor Actor, id uint, change int) error
+	ImportMARC(actor Actor, data []byte, format string, opts MARCImportOptions) (*model.BookImportReport, error)
+	ExportMARC(w io.Writer, params *model.SearchParams, format string) (int, error)
+	NormalizeISBNs(actor Actor, dryRun bool) (*model.ISBNMigrationReport, error)
+	RebuildPinyin() (scanned, updated int, err error)
 }
 
 
 type BookService struct {
-	bookRepo mysql.BookRepository
+	bookRepo        mysql.BookRepository
+	copyRepo        mysql.CopyRepository
+	reservationRepo mysql.ReservationRepository
+	indexer         BookIndexer
+	uow             mysql.UnitOfWork
 }
 
-func NewBookService(bookRepo mysql.BookRepository) BookServiceInterface {
+func NewBookService(bookRepo mysql.BookRepository, copyRepo mysql.CopyRepository, reservationRepo mysql.ReservationRepository, indexer BookIndexer, uow mysql.UnitOfWork) BookServiceInterface {
 	return &BookService{
-		bookRepo: bookRepo,
+		bookRepo:        bookRepo,
+		copyRepo:        copyRepo,
+		reservationRepo: reservationRepo,
+		indexer:         indexer,
+		uow:             uow,
+	}
+}
+
+// withRepos 返回使用工作单元仓库的服务副本
+func (s *BookService) withRepos(repos *mysql.Repositories) *BookService {
+	return &BookService{
+		bookRepo:        repos.Book,
+		copyRepo:        repos.Copy,
+		reservationRepo: repos.Reservation,
+		indexer:         s.indexer,
+		uow:             s.uow,
 	}
 }
 
 // CreateBook 创建图书
-func (s *BookService) CreateBook( book *model.Book) error {
-	return s.bookRepo.Transaction(func(tx *gorm.DB) error {
+func (s *BookService) CreateBook(actor Actor, book *model.Book) error {
+	// ISBN统一以ISBN-13存储
+	code, err := isbn.Normalize(book.ISBN)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidParameter, err)
+	}
+	book.ISBN = code
+	fillPinyin(book)
+
+	err = s.uow.Do(func(repos *mysql.Repositories) error {
+		txs := s.withRepos(repos)
 		// 检查ISBN是否已存在
-		existBook, err := s.bookRepo.GetByISBN( book.ISBN)
+		existBook, err := txs.bookRepo.GetByISBN( book.ISBN)
 		if err != nil {
 			return fmt.Errorf("check ISBN exists: %w", err)
 		}
@@ -41,21 +74,38 @@ func (s *BookService) CreateBook( book *model.Book) error {
 			return ErrAlreadyExists
 		}
 
-		// 设置初始可借数量
-		book.Available = book.Total
+		// 按总数量生成副本，库存由副本统计
+		total := book.Total
+		book.Total = 0
+		book.Available = 0
 		book.Status = 1 // 默认上架
 
-		if err := s.bookRepo.Create( book); err != nil {
+		if err := txs.bookRepo.Create( book); err != nil {
 			return fmt.Errorf("create book: %w", err)
 		}
-		return nil
+		if _, err := addCopies(txs.copyRepo, book, total); err != nil {
+			return err
+		}
+		if err := txs.bookRepo.SyncStock(book.ID); err != nil {
+			return fmt.Errorf("sync book stock: %w", err)
+		}
+		book.Total = total
+		book.Available = total
+		return writeAuditChange(repos.AuditLog, actor, AuditBookCreate, AuditTargetBook, book.ID, nil, book, nil)
 	})
+	if err != nil {
+		return err
+	}
+	s.indexer.IndexBooks(book)
+	return nil
 }
 
 // UpdateBook 更新图书信息
-func (s *BookService) UpdateBook( book *model.Book) error {
-	return s.bookRepo.Transaction(func(tx *gorm.DB) error {
-		existBook, err := s.bookRepo.GetByID( book.ID)
+func (s *BookService) UpdateBook(actor Actor, book *model.Book) error {
+	fillPinyin(book)
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		txs := s.withRepos(repos)
+		existBook, err := txs.bookRepo.LockByID(book.ID)
 		if err != nil {
 			return fmt.Errorf("get book by id: %w", err)
 		}
@@ -63,26 +113,33 @@ func (s *BookService) UpdateBook( book *model.Book) error {
 			return ErrNotFound
 		}
 
-		// 如果修改了总数量，同步更新可借数量
-		if book.Total != existBook.Total {
-			diff := book.Total - existBook.Total
-			book.Available = existBook.Available + diff
-			if book.Available < 0 {
-				return fmt.Errorf("invalid total books: would result in negative available books")
-			}
-		}
+		// 库存由副本统计，修改总数量时相应增加或注销副本
+		diff := book.Total - existBook.Total
+		book.Total = existBook.Total
+		book.Available = existBook.Available
 
-		if err := s.bookRepo.Update( book); err != nil {
+		if err := txs.bookRepo.Update( book); err != nil {
 			return fmt.Errorf("update book: %w", err)
 		}
-		return nil
+		if diff != 0 {
+			if err := txs.adjustCopies(book, diff); err != nil {
+				return err
+			}
+		}
+		return writeAuditChange(repos.AuditLog, actor, AuditBookUpdate, AuditTargetBook, book.ID, existBook, book, nil)
 	})
+	if err != nil {
+		return err
+	}
+	s.indexer.IndexBooks(book)
+	return nil
 }
 
 // DeleteBook 删除图书
-func (s *BookService) DeleteBook( id uint) error {
-	return s.bookRepo.Transaction(func(tx *gorm.DB) error {
-		book, err := s.bookRepo.GetByID( id)
+func (s *BookService) DeleteBook(actor Actor, id uint) error {
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		txs := s.withRepos(repos)
+		book, err := txs.bookRepo.LockByID(id)
 		if err != nil {
 			return fmt.Errorf("get book by id: %w", err)
 		}
@@ -90,16 +147,25 @@ func (s *BookService) DeleteBook( id uint) error {
 			return ErrNotFound
 		}
 
-		// 检查是否有未归还的借阅记录
-		if book.Available != book.Total {
+		// 检查是否有借出或预约保留中的副本
+		count, err := txs.copyRepo.CountByBook(id, 2, 3)
+		if err != nil {
+			return fmt.Errorf("count unreturned copies: %w", err)
+		}
+		if count > 0 {
 			return fmt.Errorf("cannot delete book: there are unreturned copies")
 		}
 
-		if err := s.bookRepo.Delete( id); err != nil {
+		if err := txs.bookRepo.Delete( id); err != nil {
 			return fmt.Errorf("delete book: %w", err)
 		}
-		return nil
+		return writeAuditChange(repos.AuditLog, actor, AuditBookDelete, AuditTargetBook, id, book, nil, nil)
 	})
+	if err != nil {
+		return err
+	}
+	s.indexer.RemoveBooks(id)
+	return nil
 }
 
 // GetBook 获取图书信息
@@ -124,9 +190,10 @@ func (s *BookService) ListBooks( params *model.SearchParams) ([]*model.Book, int
 }
 
 // UpdateBookStatus 更新图书状态
-func (s *BookService) UpdateBookStatus( id uint, status int) error {
-	return s.bookRepo.Transaction(func(tx *gorm.DB) error {
-		book, err := s.bookRepo.GetByID( id)
+func (s *BookService) UpdateBookStatus(actor Actor, id uint, status int) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		txs := s.withRepos(repos)
+		book, err := txs.bookRepo.LockByID(id)
 		if err != nil {
 			return fmt.Errorf("get book by id: %w", err)
 		}
@@ -134,18 +201,21 @@ func (s *BookService) UpdateBookStatus( id uint, status int) error {
 			return ErrNotFound
 		}
 
+		from := book.Status
 		book.Status = status
-		if err := s.bookRepo.Update( book); err != nil {
+		if err := txs.bookRepo.Update( book); err != nil {
 			return fmt.Errorf("update book status: %w", err)
 		}
-		return nil
+		return writeAuditChange(repos.AuditLog, actor, AuditBookStatus, AuditTargetBook, id,
+			map[string]int{"status": from}, map[string]int{"status": status}, nil)
 	})
 }
 
 // UpdateBookStock 更新图书库存
-func (s *BookService) UpdateBookStock( id uint, change int) error {
-	return s.bookRepo.Transaction(func(tx *gorm.DB) error {
-		book, err := s.bookRepo.GetByID( id)
+func (s *BookService) UpdateBookStock(actor Actor, id uint, change int) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		txs := s.withRepos(repos)
+		book, err := txs.bookRepo.LockByID(id)
 		if err != nil {
 			return fmt.Errorf("get book by id: %w", err)
 		}
@@ -153,15 +223,50 @@ func (s *BookService) UpdateBookStock( id uint, change int) error {
 			return ErrNotFound
 		}
 
-		book.Total += change
-		book.Available += change
-		if book.Available < 0 || book.Total < 0 {
-			return fmt.Errorf("invalid stock change: would result in negative books")
+		before := map[string]int{"total": book.Total, "available": book.Available}
+		if err := txs.adjustCopies(book, change); err != nil {
+			return err
 		}
+		return writeAuditChange(repos.AuditLog, actor, AuditBookStock, AuditTargetBook, id,
+			before, map[string]int{"total": book.Total, "available": book.Available}, map[string]int{"change": change})
+	})
+}
 
-		if err := s.bookRepo.Update( book); err != nil {
-			return fmt.Errorf("update book stock: %w", err)
+// adjustCopies 按数量变化增加副本或注销在架副本，并重新统计库存
+func (s *BookService) adjustCopies(book *model.Book, change int) error {
+	if change > 0 {
+		if _, err := addCopies(s.copyRepo, book, change); err != nil {
+			return err
 		}
-		return nil
-	})
+	} else if change < 0 {
+		onShelf, err := s.copyRepo.GetBookCopies(book.ID, 1)
+		if err != nil {
+			return fmt.Errorf("get copies on shelf: %w", err)
+		}
+		if len(onShelf) < -change {
+			return fmt.Errorf("invalid stock change: would result in negative books")
+		}
+		// 优先注销最后加入的副本
+		for i := 0; i < -change; i++ {
+			item := onShelf[len(onShelf)-1-i]
+			if err := s.copyRepo.UpdateStatus(item.ID, 6); err != nil { // 注销
+				return fmt.Errorf("withdraw copy: %w", err)
+			}
+		}
+	}
+
+	// 新增的副本优先分配给预约队列
+	if err := processHolds(s.reservationRepo, s.bookRepo, s.copyRepo, book.ID); err != nil {
+		return fmt.Errorf("sync book stock: %w", err)
+	}
+
+	updated, err := s.bookRepo.GetByID(book.ID)
+	if err != nil {
+		return fmt.Errorf("get book by id: %w", err)
+	}
+	if updated != nil {
+		book.Total = updated.Total
+		book.Available = updated.Available
+	}
+	return nil
 }
@@ -0,0 +1,332 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"library/model"
+	"library/repository/mysql"
+)
+
+// JobBookImport 图书批量导入任务名称
+const JobBookImport = "book_import"
+
+const (
+	// importBatchSize 每批处理的数据行数，每批结束时更新进度并检查任务是否已取消
+	importBatchSize = 20
+	// importStaleAfter 执行中的任务超过该时间没有进展时视为执行实例已退出，可被重新领取
+	importStaleAfter = 10 * time.Minute
+)
+
+// BookImportServiceInterface 图书批量导入服务接口
+type BookImportServiceInterface interface {
+	CreateJob(actor Actor, job *model.ImportJob, rows []*model.ImportJobRow) error
+	GetJob(id uint) (*model.ImportJob, error)
+	ListJobs(params *model.SearchParams) ([]*model.ImportJob, int64, error)
+	ListRows(jobID uint, action string, page, pageSize int) ([]*model.ImportJobRow, int64, error)
+	CancelJob(id uint) (*model.ImportJob, error)
+	RunPending() (int, error)
+}
+
+type BookImportService struct {
+	importJobRepo mysql.ImportJobRepository
+	bookRepo      mysql.BookRepository
+	bookService   BookServiceInterface
+	uow           mysql.UnitOfWork
+	instance      string
+}
+
+func NewBookImportService(importJobRepo mysql.ImportJobRepository, bookRepo mysql.BookRepository, bookService BookServiceInterface, uow mysql.UnitOfWork) BookImportServiceInterface {
+	hostname, _ := os.Hostname()
+	return &BookImportService{
+		importJobRepo: importJobRepo,
+		bookRepo:      bookRepo,
+		bookService:   bookService,
+		uow:           uow,
+		instance:      fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+// CreateJob 保存导入任务和已校验的数据行，校验失败的行（Action 为 error）直接计入失败数。
+// 任务提交后立即在后台开始处理，定时任务兜底处理遗留的任务
+func (s *BookImportService) CreateJob(actor Actor, job *model.ImportJob, rows []*model.ImportJobRow) error {
+	if len(rows) == 0 {
+		return ErrInvalidParameter
+	}
+	job.UserID = actor.ID
+	job.IP = actor.IP
+	job.RequestID = actor.RequestID
+	job.Status = model.ImportStatusPending
+	job.TotalRows = len(rows)
+	for _, row := range rows {
+		if row.Action == model.ImportActionError {
+			job.Failed++
+			job.Processed++
+		}
+	}
+
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		if err := repos.ImportJob.Create(job); err != nil {
+			return fmt.Errorf("create import job: %w", err)
+		}
+		for _, row := range rows {
+			row.JobID = job.ID
+		}
+		if err := repos.ImportJob.CreateRows(rows); err != nil {
+			return fmt.Errorf("create import rows: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("book import: panic: %v", r)
+			}
+		}()
+		if _, err := s.RunPending(); err != nil {
+			log.Printf("book import: %v", err)
+		}
+	}()
+	return nil
+}
+
+// GetJob 获取导入任务
+func (s *BookImportService) GetJob(id uint) (*model.ImportJob, error) {
+	job, err := s.importJobRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, ErrNotFound
+	}
+	return job, nil
+}
+
+// ListJobs 分页获取导入任务
+func (s *BookImportService) ListJobs(params *model.SearchParams) ([]*model.ImportJob, int64, error) {
+	return s.importJobRepo.List(params)
+}
+
+// ListRows 分页获取任务的数据行，可按处理结果筛选
+func (s *BookImportService) ListRows(jobID uint, action string, page, pageSize int) ([]*model.ImportJobRow, int64, error) {
+	if _, err := s.GetJob(jobID); err != nil {
+		return nil, 0, err
+	}
+	return s.importJobRepo.ListRows(jobID, action, page, pageSize)
+}
+
+// CancelJob 取消排队中或执行中的任务。执行中的任务在处理完当前批次后停止，已写入的图书不会回滚
+func (s *BookImportService) CancelJob(id uint) (*model.ImportJob, error) {
+	if _, err := s.GetJob(id); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ok, err := s.importJobRepo.UpdateStatus(id, []int{model.ImportStatusPending, model.ImportStatusRunning}, map[string]interface{}{
+		"status":      model.ImportStatusCanceled,
+		"finished_at": &now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidStatus
+	}
+	return s.GetJob(id)
+}
+
+// RunPending 依次领取并执行排队中的任务，返回执行的任务数。
+// 任务通过行锁领取，多实例同时执行时每个任务只会被一个实例处理
+func (s *BookImportService) RunPending() (int, error) {
+	count := 0
+	for {
+		job, err := s.importJobRepo.ClaimNext(s.instance, time.Now().Add(-importStaleAfter))
+		if err != nil {
+			return count, fmt.Errorf("claim import job: %w", err)
+		}
+		if job == nil {
+			return count, nil
+		}
+		count++
+		if err := s.runJob(job); err != nil {
+			log.Printf("book import: job %d failed: %v", job.ID, err)
+			now := time.Now()
+			if _, uerr := s.importJobRepo.UpdateStatus(job.ID, []int{model.ImportStatusRunning}, map[string]interface{}{
+				"status":      model.ImportStatusFailed,
+				"finished_at": &now,
+				"error":       truncate(err.Error(), 512),
+			}); uerr != nil {
+				return count, uerr
+			}
+		}
+	}
+}
+
+// runJob 分批处理未处理的数据行。中断后重新领取时从未处理的行继续
+func (s *BookImportService) runJob(job *model.ImportJob) error {
+	actor := Actor{ID: job.UserID, IP: job.IP, RequestID: job.RequestID}
+	for {
+		rows, err := s.importJobRepo.PendingRows(job.ID, importBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for _, row := range rows {
+			if err := s.processRow(job, actor, row); err != nil {
+				return err
+			}
+		}
+
+		// 进度只在任务仍在执行时更新，更新失败说明任务已被取消
+		fields, err := s.progress(job.ID)
+		if err != nil {
+			return err
+		}
+		ok, err := s.importJobRepo.UpdateStatus(job.ID, []int{model.ImportStatusRunning}, fields)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	fields, err := s.progress(job.ID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	fields["status"] = model.ImportStatusSucceeded
+	fields["finished_at"] = &now
+	_, err = s.importJobRepo.UpdateStatus(job.ID, []int{model.ImportStatusRunning}, fields)
+	return err
+}
+
+// progress 按数据行的处理结果重新统计任务进度，重复执行不会重复计数
+func (s *BookImportService) progress(jobID uint) (map[string]interface{}, error) {
+	counts, err := s.importJobRepo.CountByAction(jobID)
+	if err != nil {
+		return nil, err
+	}
+	processed := 0
+	for action, n := range counts {
+		if action != "" {
+			processed += n
+		}
+	}
+	return map[string]interface{}{
+		"processed": processed,
+		"created":   counts[model.ImportActionCreate],
+		"updated":   counts[model.ImportActionUpdate],
+		"unchanged": counts[model.ImportActionUnchanged],
+		"failed":    counts[model.ImportActionError],
+	}, nil
+}
+
+// processRow 按ISBN新建或更新图书。试运行时只比对，不写入图书。
+// 单行写入失败记录在该行，只有读写导入任务本身失败时返回错误
+func (s *BookImportService) processRow(job *model.ImportJob, actor Actor, row *model.ImportJobRow) error {
+	if err := s.applyRow(job, actor, row); err != nil {
+		row.Action = model.ImportActionError
+		row.Error = truncate(err.Error(), 512)
+	}
+	return s.importJobRepo.UpdateRow(row)
+}
+
+func (s *BookImportService) applyRow(job *model.ImportJob, actor Actor, row *model.ImportJobRow) error {
+	var book model.Book
+	if err := json.Unmarshal([]byte(row.Data), &book); err != nil {
+		return fmt.Errorf("invalid row data: %w", err)
+	}
+
+	existing, err := s.bookRepo.GetByISBN(book.ISBN)
+	if err != nil {
+		return fmt.Errorf("get book by ISBN: %w", err)
+	}
+	if job.DryRun {
+		if existing, err = s.previewBook(job, row, existing); err != nil {
+			return err
+		}
+	}
+	if existing == nil {
+		row.Action = model.ImportActionCreate
+		if job.DryRun {
+			return nil
+		}
+		if err := s.bookService.CreateBook(actor, &book); err != nil {
+			return err
+		}
+		row.BookID = book.ID
+		return nil
+	}
+
+	mergeImportedBook(existing, &book)
+	changes, err := auditDiff(existing, &book)
+	if err != nil {
+		return err
+	}
+	row.BookID = existing.ID
+	if len(changes) == 0 {
+		row.Action = model.ImportActionUnchanged
+		return nil
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	row.Action = model.ImportActionUpdate
+	row.Changes = string(data)
+	if job.DryRun {
+		return nil
+	}
+	if err := s.bookService.UpdateBook(actor, &book); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("book %d was deleted during import", existing.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// mergeImportedBook 用已有图书补全导入的数据：状态和可借数量不由导入数据决定，封面和简介为空时保留原值
+func mergeImportedBook(existing, book *model.Book) {
+	book.ID = existing.ID
+	book.Status = existing.Status
+	book.Available = existing.Available
+	book.CreatedAt = existing.CreatedAt
+	if book.Cover == "" {
+		book.Cover = existing.Cover
+	}
+	if book.Summary == "" {
+		book.Summary = existing.Summary
+	}
+}
+
+// previewBook 试运行不写入图书，本任务中同一ISBN在前面已新建或更新的行按顺序叠加到已有图书上，
+// 使预览与实际运行一致：第一行新建，其后的行与前面的行写入后的图书比对
+func (s *BookImportService) previewBook(job *model.ImportJob, row *model.ImportJobRow, existing *model.Book) (*model.Book, error) {
+	written, err := s.importJobRepo.WrittenRowsByISBN(job.ID, row.ISBN, row.RowNo)
+	if err != nil {
+		return nil, fmt.Errorf("get previous rows: %w", err)
+	}
+	for _, prev := range written {
+		var book model.Book
+		if err := json.Unmarshal([]byte(prev.Data), &book); err != nil {
+			return nil, fmt.Errorf("invalid data of row %d: %w", prev.RowNo, err)
+		}
+		if existing != nil {
+			mergeImportedBook(existing, &book)
+		}
+		existing = &book
+	}
+	return existing, nil
+}
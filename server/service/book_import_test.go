@@ -0,0 +1,252 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"library/model"
+	"library/repository/mysql"
+)
+
+// importRow 校验通过的数据行，与上传时生成的数据相同
+func importRow(t *testing.T, rowNo int, book *model.Book) *model.ImportJobRow {
+	t.Helper()
+	book.Status = 1
+	data, err := json.Marshal(book)
+	if err != nil {
+		t.Fatalf("marshal book: %v", err)
+	}
+	return &model.ImportJobRow{RowNo: rowNo, ISBN: book.ISBN, Title: book.Title, Data: string(data)}
+}
+
+func newTestBookImportService(db *gorm.DB, bookService BookServiceInterface) BookImportServiceInterface {
+	if bookService == nil {
+		bookService = NewBookService(mysql.NewBookRepository(db), mysql.NewCopyRepository(db), mysql.NewReservationRepository(db), &recordingIndexer{}, mysql.NewUnitOfWork(db))
+	}
+	return NewBookImportService(mysql.NewImportJobRepository(db), mysql.NewBookRepository(db), bookService, mysql.NewUnitOfWork(db))
+}
+
+// waitImportJob 等待后台执行的任务结束
+func waitImportJob(t *testing.T, svc BookImportServiceInterface, id uint) *model.ImportJob {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		job, err := svc.GetJob(id)
+		if err != nil {
+			t.Fatalf("GetJob: %v", err)
+		}
+		if job.Status != model.ImportStatusPending && job.Status != model.ImportStatusRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("import job %d still has status %d", id, job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func importActions(t *testing.T, svc BookImportServiceInterface, jobID uint) []string {
+	t.Helper()
+	rows, _, err := svc.ListRows(jobID, "", 1, 100)
+	if err != nil {
+		t.Fatalf("ListRows: %v", err)
+	}
+	actions := make([]string, len(rows))
+	for i, row := range rows {
+		actions[i] = row.Action
+	}
+	return actions
+}
+
+// TestBookImportDryRunMatchesRun 试运行的预览与实际运行的结果一致，同一ISBN的行第一行新建、其后更新
+func TestBookImportDryRunMatchesRun(t *testing.T) {
+	db := newTestDB(t)
+	existing := createTestBook(t, db, "9787111213826")
+	svc := newTestBookImportService(db, nil)
+
+	newRows := func() []*model.ImportJobRow {
+		updated := &model.Book{ISBN: existing.ISBN, Title: "Renamed", Author: "Author", Publisher: "Press", Category: "TP", Price: 10, Total: 1, Location: "A1"}
+		same := *updated
+		return []*model.ImportJobRow{
+			importRow(t, 2, &model.Book{ISBN: "9780306406157", Title: "New", Author: "Author", Publisher: "Press", Category: "TP", Price: 10, Total: 2, Location: "A1"}),
+			importRow(t, 3, &model.Book{ISBN: "9780306406157", Title: "New, 2nd ed.", Author: "Author", Publisher: "Press", Category: "TP", Price: 12, Total: 2, Location: "A1"}),
+			importRow(t, 4, updated),
+			importRow(t, 5, &same),
+			{RowNo: 6, ISBN: "123", Action: model.ImportActionError, Error: "isbn: invalid ISBN format"},
+		}
+	}
+	want := []string{model.ImportActionCreate, model.ImportActionUpdate, model.ImportActionUpdate, model.ImportActionUnchanged, model.ImportActionError}
+	checkJob := func(job *model.ImportJob) {
+		t.Helper()
+		if job.Status != model.ImportStatusSucceeded || job.TotalRows != 5 || job.Processed != 5 ||
+			job.Created != 1 || job.Updated != 2 || job.Unchanged != 1 || job.Failed != 1 || job.FinishedAt == nil {
+			t.Fatalf("job = %+v", job)
+		}
+		if got := importActions(t, svc, job.ID); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("actions = %v, want %v", got, want)
+		}
+	}
+
+	preview := &model.ImportJob{FileName: "books.csv", Format: "csv", DryRun: true}
+	if err := svc.CreateJob(SystemActor, preview, newRows()); err != nil {
+		t.Fatalf("CreateJob dry run: %v", err)
+	}
+	checkJob(waitImportJob(t, svc, preview.ID))
+	if n := countBooks(t, db); n != 1 {
+		t.Fatalf("dry run wrote books: %d, want 1", n)
+	}
+	book, err := mysql.NewBookRepository(db).GetByID(existing.ID)
+	if err != nil || book.Title != existing.Title {
+		t.Fatalf("dry run updated book: %+v, %v", book, err)
+	}
+	rows, _, err := svc.ListRows(preview.ID, model.ImportActionUpdate, 1, 10)
+	if err != nil || len(rows) != 2 || rows[0].Changes == "" {
+		t.Fatalf("dry run update rows = %+v, %v", rows, err)
+	}
+
+	job := &model.ImportJob{FileName: "books.csv", Format: "csv"}
+	if err := svc.CreateJob(SystemActor, job, newRows()); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	checkJob(waitImportJob(t, svc, job.ID))
+	if n := countBooks(t, db); n != 2 {
+		t.Fatalf("books = %d, want 2", n)
+	}
+	created, err := mysql.NewBookRepository(db).GetByISBN("9780306406157")
+	if err != nil || created == nil || created.Title != "New, 2nd ed." || created.Price != 12 || created.Total != 2 {
+		t.Fatalf("imported book = %+v, %v", created, err)
+	}
+	book, err = mysql.NewBookRepository(db).GetByID(existing.ID)
+	if err != nil || book.Title != "Renamed" || book.Category != "TP" || book.Status != existing.Status {
+		t.Fatalf("updated book = %+v, %v", book, err)
+	}
+
+	// 再次导入相同的数据，已有图书都无需更新
+	again := &model.ImportJob{FileName: "books.csv", Format: "csv"}
+	if err := svc.CreateJob(SystemActor, again, newRows()[2:4]); err != nil {
+		t.Fatalf("CreateJob again: %v", err)
+	}
+	if job := waitImportJob(t, svc, again.ID); job.Unchanged != 2 || job.Updated != 0 || job.Created != 0 {
+		t.Fatalf("second import = %+v", job)
+	}
+
+	if err := svc.CreateJob(SystemActor, &model.ImportJob{Format: "csv"}, nil); !errors.Is(err, ErrInvalidParameter) {
+		t.Fatalf("CreateJob without rows err = %v, want ErrInvalidParameter", err)
+	}
+}
+
+func countBooks(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+	var n int64
+	if err := db.Model(&model.Book{}).Count(&n).Error; err != nil {
+		t.Fatalf("count books: %v", err)
+	}
+	return n
+}
+
+// createPendingImportJob 直接保存排队中的任务，不启动后台执行
+func createPendingImportJob(t *testing.T, db *gorm.DB, count int) *model.ImportJob {
+	t.Helper()
+	repo := mysql.NewImportJobRepository(db)
+	job := &model.ImportJob{Format: "csv", Status: model.ImportStatusPending, TotalRows: count}
+	if err := repo.Create(job); err != nil {
+		t.Fatalf("create import job: %v", err)
+	}
+	rows := make([]*model.ImportJobRow, count)
+	for i := range rows {
+		rows[i] = importRow(t, i+2, &model.Book{ISBN: testISBN(i), Title: fmt.Sprintf("Book %d", i), Total: 1})
+		rows[i].JobID = job.ID
+	}
+	if err := repo.CreateRows(rows); err != nil {
+		t.Fatalf("create import rows: %v", err)
+	}
+	return job
+}
+
+// testISBN 第n个校验位正确的ISBN-13
+func testISBN(n int) string {
+	body := fmt.Sprintf("978711100%03d", n)
+	sum := 0
+	for i, c := range body {
+		d := int(c - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return fmt.Sprintf("%s%d", body, (10-sum%10)%10)
+}
+
+// cancelingBookService 新建第一本图书时取消导入任务，模拟执行期间的取消
+type cancelingBookService struct {
+	BookServiceInterface
+	cancel func()
+}
+
+func (s *cancelingBookService) CreateBook(actor Actor, book *model.Book) error {
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	return s.BookServiceInterface.CreateBook(actor, book)
+}
+
+func TestCancelBookImport(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestBookImportService(db, nil)
+
+	pending := createPendingImportJob(t, db, 3)
+	job, err := svc.CancelJob(pending.ID)
+	if err != nil {
+		t.Fatalf("CancelJob: %v", err)
+	}
+	if job.Status != model.ImportStatusCanceled || job.FinishedAt == nil {
+		t.Fatalf("canceled job = %+v", job)
+	}
+	if n, err := svc.RunPending(); err != nil || n != 0 {
+		t.Fatalf("RunPending = %d, %v, want no jobs", n, err)
+	}
+	if _, err := svc.CancelJob(pending.ID); !errors.Is(err, ErrInvalidStatus) {
+		t.Fatalf("CancelJob of a canceled job err = %v, want ErrInvalidStatus", err)
+	}
+	if _, err := svc.CancelJob(9999); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("CancelJob of an unknown job err = %v, want ErrNotFound", err)
+	}
+
+	// 执行中取消时处理完当前批次后停止，已写入的图书保留
+	running := createPendingImportJob(t, db, importBatchSize+5)
+	books := NewBookService(mysql.NewBookRepository(db), mysql.NewCopyRepository(db), mysql.NewReservationRepository(db), &recordingIndexer{}, mysql.NewUnitOfWork(db))
+	svc = newTestBookImportService(db, &cancelingBookService{BookServiceInterface: books, cancel: func() {
+		if _, err := svc.CancelJob(running.ID); err != nil {
+			t.Errorf("CancelJob while running: %v", err)
+		}
+	}})
+	if n, err := svc.RunPending(); err != nil || n != 1 {
+		t.Fatalf("RunPending = %d, %v, want 1 job", n, err)
+	}
+	job, err = svc.GetJob(running.ID)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if job.Status != model.ImportStatusCanceled {
+		t.Fatalf("job status = %d, want canceled", job.Status)
+	}
+	actions := importActions(t, svc, running.ID)
+	processed := 0
+	for _, action := range actions {
+		if action != "" {
+			processed++
+		}
+	}
+	if processed != importBatchSize {
+		t.Fatalf("processed rows = %d, want %d", processed, importBatchSize)
+	}
+	if n := countBooks(t, db); n != importBatchSize {
+		t.Fatalf("books = %d, want %d", n, importBatchSize)
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"library/isbn"
+	"library/model"
+	"library/repository/mysql"
+)
+
+// NormalizeISBNs 将已有图书的ISBN改写为ISBN-13，并合并规范化后ISBN相同的图书：
+// 保留ID最小的图书，其余图书的副本、借阅、预约和评论转移到保留的图书后删除。
+// 无法解析的ISBN只记录在结果中，不做修改。每组ISBN在单独的事务中处理，试运行时只返回将要进行的变更
+func (s *BookService) NormalizeISBNs(actor Actor, dryRun bool) (*model.ISBNMigrationReport, error) {
+	books, err := s.bookRepo.ListISBNs()
+	if err != nil {
+		return nil, fmt.Errorf("list books: %w", err)
+	}
+
+	report := &model.ISBNMigrationReport{}
+	var order []string
+	groups := make(map[string][]*model.Book)
+	// 已删除的图书仍占用ISBN唯一索引，规范化后的ISBN已被占用时无法改写
+	deleted := make(map[string]uint)
+	for _, book := range books {
+		if book.DeletedAt.Valid {
+			deleted[book.ISBN] = book.ID
+			continue
+		}
+		report.Scanned++
+		code, err := isbn.Normalize(book.ISBN)
+		if err != nil {
+			report.Issues = append(report.Issues, &model.ISBNIssue{BookID: book.ID, ISBN: book.ISBN, Reason: err.Error()})
+			continue
+		}
+		if _, ok := groups[code]; !ok {
+			order = append(order, code)
+		}
+		groups[code] = append(groups[code], book)
+	}
+
+	for _, code := range order {
+		group := groups[code]
+		keeper := group[0]
+		if keeper.ISBN != code {
+			if id, ok := deleted[code]; ok {
+				report.Issues = append(report.Issues, &model.ISBNIssue{
+					BookID: keeper.ID,
+					ISBN:   keeper.ISBN,
+					Reason: fmt.Sprintf("ISBN %s is held by deleted book %d", code, id),
+				})
+				continue
+			}
+		}
+
+		var changes []*model.ISBNChange
+		for _, dup := range group[1:] {
+			changes = append(changes, &model.ISBNChange{BookID: dup.ID, From: dup.ISBN, To: code, MergedInto: keeper.ID})
+		}
+		if keeper.ISBN != code {
+			changes = append(changes, &model.ISBNChange{BookID: keeper.ID, From: keeper.ISBN, To: code})
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		if !dryRun {
+			if err := s.normalizeGroup(actor, code, keeper.ID, group[1:]); err != nil {
+				report.Issues = append(report.Issues, &model.ISBNIssue{BookID: keeper.ID, ISBN: keeper.ISBN, Reason: err.Error()})
+				continue
+			}
+			for _, dup := range group[1:] {
+				s.indexer.RemoveBooks(dup.ID)
+			}
+		}
+		report.Changes = append(report.Changes, changes...)
+		report.Merged += len(group) - 1
+		if keeper.ISBN != code {
+			report.Normalized++
+		}
+	}
+	return report, nil
+}
+
+// normalizeGroup 将重复图书合并到 keeperID，再把 keeperID 的ISBN改写为 code。
+// 重复图书需先删除，否则其ISBN可能与 code 冲突
+func (s *BookService) normalizeGroup(actor Actor, code string, keeperID uint, dups []*model.Book) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		before, err := repos.Book.LockByID(keeperID)
+		if err != nil {
+			return fmt.Errorf("get book by id: %w", err)
+		}
+		if before == nil {
+			return ErrNotFound
+		}
+
+		for _, dup := range dups {
+			book, err := repos.Book.LockByID(dup.ID)
+			if err != nil {
+				return fmt.Errorf("get book by id: %w", err)
+			}
+			if book == nil {
+				continue
+			}
+			if err := repos.Book.Merge(book.ID, keeperID); err != nil {
+				return fmt.Errorf("merge book %d: %w", book.ID, err)
+			}
+			if err := writeAuditChange(repos.AuditLog, actor, AuditBookMerge, AuditTargetBook, book.ID, book, nil,
+				map[string]interface{}{"merged_into": keeperID, "isbn": code}); err != nil {
+				return err
+			}
+		}
+
+		if before.ISBN != code {
+			if err := repos.Book.UpdateISBN(keeperID, code); err != nil {
+				return fmt.Errorf("update ISBN: %w", err)
+			}
+		}
+		if len(dups) > 0 {
+			if err := cancelDuplicateHolds(repos, actor, keeperID); err != nil {
+				return err
+			}
+			// 合并后的副本和预约重新分配保留架，并重新统计库存
+			if err := processHolds(repos.Reservation, repos.Book, repos.Copy, keeperID); err != nil {
+				return fmt.Errorf("process holds: %w", err)
+			}
+		}
+
+		after, err := repos.Book.GetByID(keeperID)
+		if err != nil {
+			return fmt.Errorf("get book by id: %w", err)
+		}
+		return writeAuditChange(repos.AuditLog, actor, AuditBookUpdate, AuditTargetBook, keeperID, before, after, nil)
+	})
+}
+
+// cancelDuplicateHolds 合并后同一读者在图书上可能有多个有效预约，每人只保留一个（待取书的优先，其次排队最早的），
+// 其余取消并释放为其保留的副本
+func cancelDuplicateHolds(repos *mysql.Repositories, actor Actor, bookID uint) error {
+	queue, err := repos.Reservation.GetBookQueue(bookID)
+	if err != nil {
+		return fmt.Errorf("get book queue: %w", err)
+	}
+	now := time.Now()
+	kept := make(map[uint]bool)
+	for _, r := range queue {
+		if !kept[r.UserID] {
+			kept[r.UserID] = true
+			continue
+		}
+		before := *r
+		r.Status = 4 // 已取消
+		r.FinishedAt = &now
+		if err := repos.Reservation.Update(r); err != nil {
+			return fmt.Errorf("cancel reservation %d: %w", r.ID, err)
+		}
+		if err := writeAuditChange(repos.AuditLog, actor, AuditReservationCancel, AuditTargetReservation, r.ID, &before, r,
+			map[string]interface{}{"reason": "duplicate after book merge"}); err != nil {
+			return err
+		}
+		if err := releaseHeldCopy(repos.Copy, r); err != nil {
+			return fmt.Errorf("release held copy: %w", err)
+		}
+	}
+	return nil
+}
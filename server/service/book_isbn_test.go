@@ -0,0 +1,133 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"library/model"
+	"library/repository/mysql"
+)
+
+// recordingIndexer 记录同步检索索引的调用
+type recordingIndexer struct {
+	removed []uint
+}
+
+func (r *recordingIndexer) IndexBooks(books ...*model.Book) {}
+
+func (r *recordingIndexer) RemoveBooks(ids ...uint) {
+	r.removed = append(r.removed, ids...)
+}
+
+// TestNormalizeISBNs 试运行只报告变更；实际运行改写ISBN、合并重复图书，
+// 同一读者在两本图书上的预约只保留一个，合并来的在架副本分配给排队的读者
+func TestNormalizeISBNs(t *testing.T) {
+	db := newTestDB(t)
+	keeper := createTestBook(t, db, "0-306-40615-2")
+	dup := createTestBook(t, db, "9780306406157")
+	createTestBook(t, db, "9787111213826")
+	bad := createTestBook(t, db, "9787111213827")
+	reader1 := createTestUser(t, db, "reader1")
+	reader2 := createTestUser(t, db, "reader2")
+
+	held := &model.Copy{BookID: dup.ID, Barcode: "LIB0001", Status: 3}
+	shelved := &model.Copy{BookID: dup.ID, Barcode: "LIB0002", Status: 1}
+	for _, c := range []*model.Copy{held, shelved} {
+		if err := db.Create(c).Error; err != nil {
+			t.Fatalf("create copy: %v", err)
+		}
+	}
+	now := time.Now()
+	expire := now.AddDate(0, 0, 3)
+	waiting1 := &model.Reservation{UserID: reader1.ID, BookID: keeper.ID, Status: 1, CreatedAt: now.Add(-3 * time.Hour)}
+	waiting2 := &model.Reservation{UserID: reader2.ID, BookID: keeper.ID, Status: 1, CreatedAt: now.Add(-2 * time.Hour)}
+	ready1 := &model.Reservation{UserID: reader1.ID, BookID: dup.ID, Status: 2, CopyID: &held.ID, ReadyAt: &now, ExpireAt: &expire, CreatedAt: now.Add(-time.Hour)}
+	for _, r := range []*model.Reservation{waiting1, waiting2, ready1} {
+		if err := db.Create(r).Error; err != nil {
+			t.Fatalf("create reservation: %v", err)
+		}
+	}
+
+	indexer := &recordingIndexer{}
+	svc := NewBookService(mysql.NewBookRepository(db), mysql.NewCopyRepository(db), mysql.NewReservationRepository(db), indexer, mysql.NewUnitOfWork(db))
+
+	checkReport := func(report *model.ISBNMigrationReport) {
+		t.Helper()
+		if report.Scanned != 4 || report.Normalized != 1 || report.Merged != 1 || len(report.Changes) != 2 {
+			t.Fatalf("report = scanned %d, normalized %d, merged %d, changes %d, want 4, 1, 1, 2",
+				report.Scanned, report.Normalized, report.Merged, len(report.Changes))
+		}
+		if c := report.Changes[0]; c.BookID != dup.ID || c.MergedInto != keeper.ID || c.To != "9780306406157" {
+			t.Fatalf("merge change = %+v", c)
+		}
+		if c := report.Changes[1]; c.BookID != keeper.ID || c.From != "0-306-40615-2" || c.To != "9780306406157" || c.MergedInto != 0 {
+			t.Fatalf("normalize change = %+v", c)
+		}
+		if len(report.Issues) != 1 || report.Issues[0].BookID != bad.ID {
+			t.Fatalf("issues = %+v, want only book %d", report.Issues, bad.ID)
+		}
+	}
+
+	report, err := svc.NormalizeISBNs(SystemActor, true)
+	if err != nil {
+		t.Fatalf("NormalizeISBNs dry run: %v", err)
+	}
+	checkReport(report)
+	var n int64
+	if err := db.Model(&model.Book{}).Where("id = ? OR isbn = ?", dup.ID, "0-306-40615-2").Count(&n).Error; err != nil {
+		t.Fatalf("count books: %v", err)
+	}
+	if n != 2 || len(indexer.removed) != 0 {
+		t.Fatalf("dry run changed books: %d remaining, removed from index %v", n, indexer.removed)
+	}
+
+	report, err = svc.NormalizeISBNs(SystemActor, false)
+	if err != nil {
+		t.Fatalf("NormalizeISBNs: %v", err)
+	}
+	checkReport(report)
+
+	var merged model.Book
+	if err := db.First(&merged, keeper.ID).Error; err != nil {
+		t.Fatalf("get book: %v", err)
+	}
+	if merged.ISBN != "9780306406157" {
+		t.Fatalf("keeper ISBN = %q", merged.ISBN)
+	}
+	if err := db.Unscoped().Model(&model.Book{}).Where("id = ?", dup.ID).Count(&n).Error; err != nil || n != 0 {
+		t.Fatalf("duplicate book still exists: %d, %v", n, err)
+	}
+	if len(indexer.removed) != 1 || indexer.removed[0] != dup.ID {
+		t.Fatalf("removed from index = %v, want [%d]", indexer.removed, dup.ID)
+	}
+
+	// reader1 保留待取书的预约，排队的预约被取消；reader2 分到合并来的在架副本
+	for _, c := range []struct {
+		reservation *model.Reservation
+		status      int
+		copyID      *uint
+	}{
+		{ready1, 2, &held.ID},
+		{waiting1, 4, nil},
+		{waiting2, 2, &shelved.ID},
+	} {
+		var got model.Reservation
+		if err := db.First(&got, c.reservation.ID).Error; err != nil {
+			t.Fatalf("get reservation: %v", err)
+		}
+		if got.BookID != keeper.ID || got.Status != c.status || (c.copyID != nil && (got.CopyID == nil || *got.CopyID != *c.copyID)) {
+			t.Errorf("reservation %d = book %d, status %d, copy %v, want book %d, status %d, copy %v",
+				got.ID, got.BookID, got.Status, got.CopyID, keeper.ID, c.status, c.copyID)
+		}
+	}
+	assertStock(t, db, keeper.ID, 0, 2)
+
+	// 再次运行没有需要处理的图书
+	report, err = svc.NormalizeISBNs(SystemActor, false)
+	if err != nil {
+		t.Fatalf("second NormalizeISBNs: %v", err)
+	}
+	if report.Scanned != 3 || len(report.Changes) != 0 || len(report.Issues) != 1 {
+		t.Fatalf("second report = %+v", report)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"library/marc"
+	"library/model"
+)
+
+const (
+	// marcExportPageSize 导出时每次查询的图书数
+	marcExportPageSize = 100
+	// marcExportLimit 单次导出的最大记录数，超出时需缩小查询范围
+	marcExportLimit = 10000
+)
+
+// MARCImportOptions MARC导入的副本数量，以及记录中缺少出版社、分类、馆藏位置或价格时使用的默认值
+type MARCImportOptions struct {
+	Copies    int     // 每种图书生成的副本数量
+	Publisher string  // 默认出版社
+	Category  string  // 默认分类
+	Location  string  // 默认馆藏位置
+	Price     float64 // 默认价格
+}
+
+// ImportMARC 从MARC数据批量创建图书，每条记录单独创建并按总数量生成副本。
+// 单条记录解析或创建失败不影响其他记录，逐条返回结果
+func (s *BookService) ImportMARC(actor Actor, data []byte, format string, opts MARCImportOptions) (*model.BookImportReport, error) {
+	if opts.Copies < 1 {
+		return nil, ErrInvalidParameter
+	}
+	entries, err := marc.Decode(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParameter, err)
+	}
+
+	report := &model.BookImportReport{Total: len(entries)}
+	for i, entry := range entries {
+		result := &model.BookImportResult{Index: i + 1}
+		report.Results = append(report.Results, result)
+
+		book, err := s.importRecord(actor, entry, opts)
+		if book != nil {
+			result.ISBN = book.ISBN
+			result.Title = book.Title
+		}
+		if err != nil {
+			result.Error = err.Error()
+			report.Failed++
+			continue
+		}
+		result.BookID = book.ID
+		report.Created++
+	}
+	return report, nil
+}
+
+// importRecord 导入一条记录，返回映射出的图书以便在结果中标识记录
+func (s *BookService) importRecord(actor Actor, entry marc.Entry, opts MARCImportOptions) (*model.Book, error) {
+	if entry.Err != nil {
+		return nil, entry.Err
+	}
+	book, err := marc.ToBook(entry.Record)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyMARCDefaults(book, opts); err != nil {
+		return book, err
+	}
+	book.Total = opts.Copies
+	if err := s.CreateBook(actor, book); err != nil {
+		if errors.Is(err, ErrAlreadyExists) {
+			return book, fmt.Errorf("ISBN %s already exists", book.ISBN)
+		}
+		return book, err
+	}
+	return book, nil
+}
+
+// applyMARCDefaults 用默认值补全记录中缺少的字段。与创建图书的请求规则一致，出版社、分类、馆藏位置和价格是必需的
+func applyMARCDefaults(book *model.Book, opts MARCImportOptions) error {
+	if book.Publisher == "" {
+		book.Publisher = opts.Publisher
+	}
+	if book.Category == "" {
+		book.Category = opts.Category
+	}
+	if book.Location == "" {
+		book.Location = opts.Location
+	}
+	if book.Price <= 0 {
+		book.Price = opts.Price
+	}
+
+	var missing []string
+	if book.Publisher == "" {
+		missing = append(missing, "publisher (264$b)")
+	}
+	if book.Category == "" {
+		missing = append(missing, "category (084$a)")
+	}
+	if book.Location == "" {
+		missing = append(missing, "location (852$c)")
+	}
+	if book.Price <= 0 {
+		missing = append(missing, "price (020$c)")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s and no default given", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ExportMARC 按图书列表的查询条件导出MARC记录。params.Page 大于0时只导出该页，否则导出全部匹配的图书
+func (s *BookService) ExportMARC(w io.Writer, params *model.SearchParams, format string) (int, error) {
+	if format != marc.FormatISO2709 && format != marc.FormatXML {
+		return 0, ErrInvalidParameter
+	}
+	// 按ID排序，保证分页查询不重复不遗漏
+	query := *params
+	query.OrderBy = "id"
+	query.OrderType = "asc"
+
+	var books []*model.Book
+	if query.Page > 0 {
+		page, _, err := s.bookRepo.List(&query)
+		if err != nil {
+			return 0, fmt.Errorf("list books: %w", err)
+		}
+		books = page
+	} else {
+		query.Page = 1
+		query.PageSize = marcExportPageSize
+		for {
+			page, total, err := s.bookRepo.List(&query)
+			if err != nil {
+				return 0, fmt.Errorf("list books: %w", err)
+			}
+			if total > marcExportLimit {
+				return 0, fmt.Errorf("%w: %d books match, export at most %d at a time", ErrInvalidParameter, total, marcExportLimit)
+			}
+			books = append(books, page...)
+			if len(page) < marcExportPageSize || int64(len(books)) >= total {
+				break
+			}
+			query.Page++
+		}
+	}
+
+	records := make([]*marc.Record, 0, len(books))
+	for _, book := range books {
+		records = append(records, marc.FromBook(book))
+	}
+	if err := marc.Encode(w, records, format); err != nil {
+		return 0, fmt.Errorf("encode MARC: %w", err)
+	}
+	return len(records), nil
+}
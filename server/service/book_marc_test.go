@@ -0,0 +1,101 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"library/marc"
+	"library/model"
+	"library/repository/mysql"
+)
+
+// testMARCRecord 含ISBN、价格、题名和责任者的记录，fields 追加其他数据字段（标签、子字段代码和值）
+func testMARCRecord(code, price, title string, fields ...[]string) *marc.Record {
+	rec := &marc.Record{}
+	rec.AddData("020", ' ', ' ', "a", code, "c", price)
+	rec.AddData("100", '1', ' ', "a", "Author")
+	rec.AddData("245", '1', '0', "a", title)
+	for _, f := range fields {
+		rec.AddData(f[0], ' ', ' ', f[1:]...)
+	}
+	return rec
+}
+
+func TestImportMARC(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewBookService(mysql.NewBookRepository(db), mysql.NewCopyRepository(db), mysql.NewReservationRepository(db), &recordingIndexer{}, mysql.NewUnitOfWork(db))
+	complete := [][]string{{"084", "a", "TP312"}, {"260", "b", "Press"}, {"852", "c", "A1"}}
+
+	records := []*marc.Record{
+		testMARCRecord("9787111213826", "CNY45.00", "Complete", complete...),
+		testMARCRecord("9780306406157", "", "Bare"),
+		testMARCRecord("978-7-111-21382-6", "CNY45.00", "Duplicate", complete...),
+		testMARCRecord("", "", "No ISBN", complete...),
+	}
+	var buf bytes.Buffer
+	if err := marc.Encode(&buf, records, marc.FormatXML); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	data := buf.Bytes()
+
+	report, err := svc.ImportMARC(SystemActor, data, "", MARCImportOptions{Copies: 2})
+	if err != nil {
+		t.Fatalf("ImportMARC: %v", err)
+	}
+	if report.Total != 4 || report.Created != 1 || report.Failed != 3 || len(report.Results) != 4 {
+		t.Fatalf("report = total %d, created %d, failed %d, want 4, 1, 3", report.Total, report.Created, report.Failed)
+	}
+	for i, want := range []struct {
+		isbn  string
+		error string
+	}{
+		{"9787111213826", ""},
+		// 没有默认值时缺少创建图书所需的字段，与创建图书接口的规则一致
+		{"9780306406157", "missing publisher (264$b), category (084$a), location (852$c), price (020$c) and no default given"},
+		{"9787111213826", "ISBN 9787111213826 already exists"},
+		{"", marc.ErrMissingISBN.Error()},
+	} {
+		r := report.Results[i]
+		if r.Index != i+1 || r.ISBN != want.isbn || r.Error != want.error || (want.error == "") != (r.BookID != 0) {
+			t.Errorf("result %d = %+v, want ISBN %q, error %q", i+1, r, want.isbn, want.error)
+		}
+	}
+
+	created, err := mysql.NewBookRepository(db).GetByISBN("9787111213826")
+	if err != nil || created == nil {
+		t.Fatalf("GetByISBN = %+v, %v", created, err)
+	}
+	if created.Price != 45 || created.Category != "TP312" || created.Total != 2 {
+		t.Fatalf("created book = %+v", created)
+	}
+	var copies int64
+	if err := db.Model(&model.Copy{}).Where("book_id = ?", created.ID).Count(&copies).Error; err != nil || copies != 2 {
+		t.Fatalf("copies = %d, %v, want 2", copies, err)
+	}
+
+	// 请求中的默认值补全缺少的字段，记录中已有的值不被覆盖
+	report, err = svc.ImportMARC(SystemActor, data, marc.FormatXML, MARCImportOptions{
+		Copies: 1, Publisher: "Default Press", Category: "General", Location: "Stacks", Price: 9.9,
+	})
+	if err != nil {
+		t.Fatalf("ImportMARC with defaults: %v", err)
+	}
+	if report.Created != 1 || report.Results[1].Error != "" {
+		t.Fatalf("report with defaults = %+v", report.Results[1])
+	}
+	bare, err := mysql.NewBookRepository(db).GetByISBN("9780306406157")
+	if err != nil || bare == nil {
+		t.Fatalf("GetByISBN = %+v, %v", bare, err)
+	}
+	if bare.Publisher != "Default Press" || bare.Category != "General" || bare.Location != "Stacks" || bare.Price != 9.9 {
+		t.Fatalf("book with defaults = %+v", bare)
+	}
+
+	if _, err := svc.ImportMARC(SystemActor, data, "", MARCImportOptions{}); err != ErrInvalidParameter {
+		t.Fatalf("ImportMARC without copies err = %v, want ErrInvalidParameter", err)
+	}
+	if _, err := svc.ImportMARC(SystemActor, []byte("<collection"), "", MARCImportOptions{Copies: 1}); err == nil || !strings.Contains(err.Error(), "parse MARCXML") {
+		t.Fatalf("ImportMARC of malformed data err = %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"fmt"
+
+	"library/model"
+	"library/pinyin"
+)
+
+// fillPinyin 根据书名和作者生成拼音检索串。更新图书时未修改的字段为空，对应的拼音保持不变
+func fillPinyin(book *model.Book) {
+	if book.Title != "" {
+		book.TitlePinyin = pinyin.Index(book.Title)
+	}
+	if book.Author != "" {
+		book.AuthorPinyin = pinyin.Index(book.Author)
+	}
+}
+
+// pinyinBatchSize 重新生成拼音时每批读取的图书数量
+const pinyinBatchSize = 500
+
+// RebuildPinyin 按当前的拼音表重新生成全部图书的拼音检索串，只写入有变化的图书。
+// 按ID分批读取，不一次加载全部图书。用于为拼音检索上线前的图书生成拼音，或在拼音表更新后刷新
+func (s *BookService) RebuildPinyin() (scanned, updated int, err error) {
+	var lastID uint
+	for {
+		books, err := s.bookRepo.ListPinyin(lastID, pinyinBatchSize)
+		if err != nil {
+			return scanned, updated, fmt.Errorf("list books after %d: %w", lastID, err)
+		}
+		if len(books) == 0 {
+			return scanned, updated, nil
+		}
+		for _, book := range books {
+			scanned++
+			title, author := pinyin.Index(book.Title), pinyin.Index(book.Author)
+			if title == book.TitlePinyin && author == book.AuthorPinyin {
+				continue
+			}
+			if err := s.bookRepo.UpdatePinyin(book.ID, title, author); err != nil {
+				return scanned, updated, fmt.Errorf("update pinyin of book %d: %w", book.ID, err)
+			}
+			updated++
+		}
+		lastID = books[len(books)-1].ID
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"library/model"
+	"library/pinyin"
+	"library/repository/mysql"
+)
+
+// pagingBookRepository 记录每次分批读取的起始ID
+type pagingBookRepository struct {
+	mysql.BookRepository
+	afterIDs []uint
+}
+
+func (r *pagingBookRepository) ListPinyin(afterID uint, limit int) ([]*model.Book, error) {
+	r.afterIDs = append(r.afterIDs, afterID)
+	return r.BookRepository.ListPinyin(afterID, limit)
+}
+
+func TestRebuildPinyin(t *testing.T) {
+	db := newTestDB(t)
+	books := make([]*model.Book, pinyinBatchSize+1)
+	for i := range books {
+		books[i] = &model.Book{ISBN: testISBN(i), Title: "红楼梦", Author: "曹雪芹", Status: 1}
+	}
+	if err := db.CreateInBatches(books, 100).Error; err != nil {
+		t.Fatalf("create books: %v", err)
+	}
+	// 已有正确拼音的图书不写入，已删除的图书不处理
+	current := books[1]
+	if err := db.Model(current).UpdateColumns(map[string]interface{}{
+		"title_pinyin":  pinyin.Index(current.Title),
+		"author_pinyin": pinyin.Index(current.Author),
+	}).Error; err != nil {
+		t.Fatalf("set pinyin: %v", err)
+	}
+	if err := db.Delete(books[0]).Error; err != nil {
+		t.Fatalf("delete book: %v", err)
+	}
+
+	repo := &pagingBookRepository{BookRepository: mysql.NewBookRepository(db)}
+	svc := NewBookService(repo, mysql.NewCopyRepository(db), mysql.NewReservationRepository(db), &recordingIndexer{}, mysql.NewUnitOfWork(db))
+	scanned, updated, err := svc.RebuildPinyin()
+	if err != nil {
+		t.Fatalf("RebuildPinyin: %v", err)
+	}
+	if scanned != pinyinBatchSize || updated != pinyinBatchSize-1 {
+		t.Fatalf("RebuildPinyin = scanned %d, updated %d, want %d, %d", scanned, updated, pinyinBatchSize, pinyinBatchSize-1)
+	}
+	if want := []uint{0, books[pinyinBatchSize].ID}; !reflect.DeepEqual(repo.afterIDs, want) {
+		t.Fatalf("batches after IDs %v, want %v", repo.afterIDs, want)
+	}
+
+	var book model.Book
+	if err := db.First(&book, books[pinyinBatchSize].ID).Error; err != nil {
+		t.Fatalf("get book: %v", err)
+	}
+	if book.TitlePinyin != "hongloumeng hlm" || book.AuthorPinyin != "caoxueqin cxq" {
+		t.Fatalf("pinyin = %q, %q", book.TitlePinyin, book.AuthorPinyin)
+	}
+
+	scanned, updated, err = svc.RebuildPinyin()
+	if err != nil || scanned != pinyinBatchSize || updated != 0 {
+		t.Fatalf("second RebuildPinyin = %d, %d, %v, want %d, 0", scanned, updated, err, pinyinBatchSize)
+	}
+}
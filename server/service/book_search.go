@@ -0,0 +1,226 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strings"
+
+	"library/isbn"
+	"library/model"
+	"library/pinyin"
+	"library/repository/mysql"
+	"library/search"
+)
+
+const (
+	// rebuildPageSize 重建检索索引时每批读取的图书数
+	rebuildPageSize = 500
+	// maxPinyinCandidates 按拼音检索时参与排序的图书上限，超出部分不返回
+	maxPinyinCandidates = 1000
+)
+
+// BookIndexer 图书变更后同步检索索引。MySQL全文索引由数据库维护，同步对其没有影响
+type BookIndexer interface {
+	IndexBooks(books ...*model.Book)
+	RemoveBooks(ids ...uint)
+}
+
+// BookSearchServiceInterface 图书全文检索服务接口
+type BookSearchServiceInterface interface {
+	BookIndexer
+	Search(keyword, category string, page, pageSize int) ([]*model.BookSearchHit, int64, error)
+	Rebuild() (int, error)
+}
+
+type BookSearchService struct {
+	engine   search.Engine
+	bookRepo mysql.BookRepository
+}
+
+func NewBookSearchService(engine search.Engine, bookRepo mysql.BookRepository) BookSearchServiceInterface {
+	return &BookSearchService{
+		engine:   engine,
+		bookRepo: bookRepo,
+	}
+}
+
+// mustNewSearchEngine 根据配置创建检索引擎，引擎不存在时panic，用于启动阶段
+func mustNewSearchEngine(name string, mysqlFactory mysql.Factory) search.Engine {
+	switch name {
+	case "", search.EngineMySQL:
+		return mysqlFactory.GetBookSearchEngine()
+	case search.EngineMemory:
+		return search.NewMemoryEngine()
+	default:
+		panic(fmt.Errorf("%w: %s", search.ErrUnknownEngine, name))
+	}
+}
+
+// Search 全文检索图书，按相关度排序。关键词为合法的ISBN时直接按ISBN查找；
+// 全文检索没有结果且关键词为拼音（如 hlm、honglou）时，按书名和作者的全拼、首字母检索
+func (s *BookSearchService) Search(keyword, category string, page, pageSize int) ([]*model.BookSearchHit, int64, error) {
+	if isbn.Valid(keyword) {
+		book, err := s.bookRepo.GetByISBN(keyword)
+		if err != nil {
+			return nil, 0, fmt.Errorf("get book by ISBN: %w", err)
+		}
+		if book != nil && (category == "" || book.Category == category) {
+			if page > 1 {
+				return []*model.BookSearchHit{}, 1, nil
+			}
+			return []*model.BookSearchHit{{
+				Book:       book,
+				Highlights: map[string]string{"isbn": "<em>" + html.EscapeString(book.ISBN) + "</em>"},
+			}}, 1, nil
+		}
+	}
+
+	q, err := search.ParseQuery(keyword)
+	if err != nil {
+		if errors.Is(err, search.ErrEmptyQuery) {
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidParameter, err)
+		}
+		return nil, 0, err
+	}
+	result, err := s.engine.Search(&search.Request{
+		Query:    q,
+		Category: category,
+		Offset:   (page - 1) * pageSize,
+		Limit:    pageSize,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("search books: %w", err)
+	}
+	if result.Total == 0 {
+		if py := pinyin.Normalize(keyword); py != "" && pinyin.Plausible(py) {
+			return s.searchPinyin(py, category, page, pageSize)
+		}
+	}
+
+	ids := make([]uint, len(result.Hits))
+	for i, hit := range result.Hits {
+		ids[i] = hit.ID
+	}
+	books, err := s.bookRepo.GetByIDs(ids)
+	if err != nil {
+		return nil, 0, fmt.Errorf("get books: %w", err)
+	}
+	byID := make(map[uint]*model.Book, len(books))
+	for _, book := range books {
+		byID[book.ID] = book
+	}
+
+	// 内存索引可能还包含刚被删除的图书，按数据库中的结果过滤
+	hits := make([]*model.BookSearchHit, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		book, ok := byID[hit.ID]
+		if !ok {
+			continue
+		}
+		hits = append(hits, &model.BookSearchHit{Book: book, Score: hit.Score, Highlights: hit.Highlights})
+	}
+	return hits, result.Total, nil
+}
+
+// searchPinyin 按拼音检索图书：书名匹配优先于作者匹配，从书名开头匹配的排在最前，得分相同时按ID排序
+func (s *BookSearchService) searchPinyin(keyword, category string, page, pageSize int) ([]*model.BookSearchHit, int64, error) {
+	books, err := s.bookRepo.SearchPinyin(keyword, category, maxPinyinCandidates)
+	if err != nil {
+		return nil, 0, fmt.Errorf("search books by pinyin: %w", err)
+	}
+
+	hits := make([]*model.BookSearchHit, 0, len(books))
+	for _, book := range books {
+		hit := &model.BookSearchHit{Book: book, Highlights: map[string]string{}}
+		if strings.Contains(book.TitlePinyin, keyword) {
+			hit.Score += 2
+			for _, term := range strings.Fields(book.TitlePinyin) {
+				if strings.HasPrefix(term, keyword) {
+					hit.Score++
+					break
+				}
+			}
+		}
+		if strings.Contains(book.AuthorPinyin, keyword) {
+			hit.Score++
+		}
+		if text, ok := pinyinHighlight(book.Title, keyword); ok {
+			hit.Highlights["title"] = text
+		}
+		if text, ok := pinyinHighlight(book.Author, keyword); ok {
+			hit.Highlights["author"] = text
+		}
+		hits = append(hits, hit)
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+
+	total := int64(len(hits))
+	offset := (page - 1) * pageSize
+	if offset >= len(hits) {
+		return []*model.BookSearchHit{}, total, nil
+	}
+	hits = hits[offset:]
+	if len(hits) > pageSize {
+		hits = hits[:pageSize]
+	}
+	return hits, total, nil
+}
+
+// pinyinHighlight 以 <em></em> 标记文字中与拼音匹配的一段，其余部分做HTML转义
+func pinyinHighlight(text, keyword string) (string, bool) {
+	start, end, ok := pinyin.Match(text, keyword)
+	if !ok {
+		return "", false
+	}
+	return html.EscapeString(text[:start]) + "<em>" + html.EscapeString(text[start:end]) + "</em>" + html.EscapeString(text[end:]), true
+}
+
+// Rebuild 从数据库重新加载全部图书建立索引，返回加载的图书数。MySQL全文索引无需重建，返回0
+func (s *BookSearchService) Rebuild() (int, error) {
+	count := 0
+	err := s.engine.Rebuild(func(yield func(docs []*search.Document) error) error {
+		params := &model.SearchParams{OrderBy: "id", OrderType: "asc"}
+		params.PageSize = rebuildPageSize
+		for params.Page = 1; ; params.Page++ {
+			books, _, err := s.bookRepo.List(params)
+			if err != nil {
+				return fmt.Errorf("list books: %w", err)
+			}
+			docs := make([]*search.Document, len(books))
+			for i, book := range books {
+				docs[i] = search.BookDocument(book)
+			}
+			if err := yield(docs); err != nil {
+				return err
+			}
+			count += len(docs)
+			if len(books) < rebuildPageSize {
+				return nil
+			}
+		}
+	})
+	return count, err
+}
+
+// IndexBooks 新增或更新图书的索引，失败只记录日志，不影响图书本身的写入
+func (s *BookSearchService) IndexBooks(books ...*model.Book) {
+	docs := make([]*search.Document, len(books))
+	for i, book := range books {
+		docs[i] = search.BookDocument(book)
+	}
+	if err := s.engine.Index(docs...); err != nil {
+		log.Printf("search: index books: %v", err)
+	}
+}
+
+// RemoveBooks 删除图书的索引
+func (s *BookSearchService) RemoveBooks(ids ...uint) {
+	if err := s.engine.Remove(ids...); err != nil {
+		log.Printf("search: remove books: %v", err)
+	}
+}
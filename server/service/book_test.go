@@ -0,0 +1,59 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"library/model"
+	"library/repository/mysql"
+)
+
+func TestListBooksPinyinFallback(t *testing.T) {
+	db := newTestDB(t)
+	for i, item := range []struct{ title, author string }{
+		{"红楼梦", "曹雪芹"},
+		{"Go语言编程", "许式伟"},
+		{"公共管理导论", "张成福"},
+		{"The C Programming Language", "Kernighan"},
+		{"长安的荔枝", "马伯庸"},
+	} {
+		book := &model.Book{ISBN: "97870000000" + string(rune('1'+i)), Title: item.title, Author: item.author, Status: 1}
+		fillPinyin(book)
+		if err := db.Create(book).Error; err != nil {
+			t.Fatalf("create book %s: %v", item.title, err)
+		}
+	}
+	svc := NewBookService(mysql.NewBookRepository(db), mysql.NewCopyRepository(db), mysql.NewReservationRepository(db), nil, mysql.NewUnitOfWork(db))
+
+	cases := []struct {
+		keyword string
+		want    []string
+	}{
+		// 普通匹配有结果时不按拼音匹配，go 不会匹配 gonggong，c 不会匹配 changan、caoxueqin
+		{keyword: "go", want: []string{"Go语言编程"}},
+		{keyword: "c", want: []string{"The C Programming Language"}},
+		{keyword: "红楼", want: []string{"红楼梦"}},
+		// 普通匹配没有结果时按全拼、首字母匹配
+		{keyword: "hlm", want: []string{"红楼梦"}},
+		{keyword: "Hong2lou2", want: []string{"红楼梦"}},
+		{keyword: "caoxueqin", want: []string{"红楼梦"}},
+		{keyword: "gonggong", want: []string{"公共管理导论"}},
+		{keyword: "iphone", want: nil},
+	}
+	for _, c := range cases {
+		t.Run(c.keyword, func(t *testing.T) {
+			params := &model.SearchParams{Keyword: c.keyword, OrderBy: "id", Pagination: model.Pagination{Page: 1, PageSize: 10}}
+			books, total, err := svc.ListBooks(params)
+			if err != nil {
+				t.Fatalf("ListBooks: %v", err)
+			}
+			var titles []string
+			for _, book := range books {
+				titles = append(titles, book.Title)
+			}
+			if !reflect.DeepEqual(titles, c.want) || total != int64(len(c.want)) {
+				t.Fatalf("ListBooks(%q) = %v (total %d), want %v", c.keyword, titles, total, c.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"library/config"
 	"library/model"
 	"library/repository/mysql"
 	"time"
@@ -8,87 +9,302 @@ import (
 
 // BorrowServiceInterface 借阅服务接口
 type BorrowServiceInterface interface {
-	BorrowBook(userID, bookID uint) error
-	ReturnBook(userID, bookID uint) error
-	RenewBook(id uint) error
+	BorrowBook(actor Actor, userID, bookID uint) error
+	ReturnBook(actor Actor, userID, bookID uint) error
+	CheckoutByBarcode(actor Actor, userID uint, barcode string) (*model.Borrow, error)
+	CheckinByBarcode(actor Actor, barcode string) (*model.Borrow, error)
+	RenewBook(actor Actor, id uint, userID uint, isAdmin bool) (*model.Borrow, error)
 	GetBorrow(id uint) (*model.Borrow, error)
 	GetBorrowInfo(id uint) (*model.Borrow, error)
 	ListBorrows(params *model.SearchParams) ([]*model.Borrow, int64, error)
 	GetUserBorrows(userID uint, status int) ([]*model.Borrow, error)
 	GetOverdueBorrows() ([]*model.Borrow, error)
-	UpdateBorrow(borrow *model.Borrow) error
+	UpdateBorrow(actor Actor, borrow *model.Borrow) error
 }
 
 type BorrowService struct {
-	borrowRepo mysql.BorrowRepository
-	bookRepo   mysql.BookRepository
-	userRepo   mysql.UserRepository
+	borrowRepo      mysql.BorrowRepository
+	bookRepo        mysql.BookRepository
+	userRepo        mysql.UserRepository
+	reservationRepo mysql.ReservationRepository
+	loanPolicyRepo  mysql.LoanPolicyRepository
+	fineRepo        mysql.FineRepository
+	copyRepo        mysql.CopyRepository
+	auditLogRepo    mysql.AuditLogRepository
+	uow             mysql.UnitOfWork
 }
 
-func NewBorrowService(borrowRepo mysql.BorrowRepository, bookRepo mysql.BookRepository, userRepo mysql.UserRepository) BorrowServiceInterface {
+func NewBorrowService(borrowRepo mysql.BorrowRepository, bookRepo mysql.BookRepository, userRepo mysql.UserRepository, reservationRepo mysql.ReservationRepository, loanPolicyRepo mysql.LoanPolicyRepository, fineRepo mysql.FineRepository, copyRepo mysql.CopyRepository, auditLogRepo mysql.AuditLogRepository, uow mysql.UnitOfWork) BorrowServiceInterface {
 	return &BorrowService{
-		borrowRepo: borrowRepo,
-		bookRepo:   bookRepo,
-		userRepo:   userRepo,
+		borrowRepo:      borrowRepo,
+		bookRepo:        bookRepo,
+		userRepo:        userRepo,
+		reservationRepo: reservationRepo,
+		loanPolicyRepo:  loanPolicyRepo,
+		fineRepo:        fineRepo,
+		copyRepo:        copyRepo,
+		auditLogRepo:    auditLogRepo,
+		uow:             uow,
 	}
 }
 
-// BorrowBook 借阅图书
-func (s *BorrowService) BorrowBook(userID, bookID uint) error {
+// withRepos 返回使用工作单元仓库的服务副本
+func (s *BorrowService) withRepos(repos *mysql.Repositories) *BorrowService {
+	return &BorrowService{
+		borrowRepo:      repos.Borrow,
+		bookRepo:        repos.Book,
+		userRepo:        repos.User,
+		reservationRepo: repos.Reservation,
+		loanPolicyRepo:  repos.LoanPolicy,
+		fineRepo:        repos.Fine,
+		copyRepo:        repos.Copy,
+		auditLogRepo:    repos.AuditLog,
+		uow:             s.uow,
+	}
+}
+
+// BorrowBook 借阅图书，由系统分配副本
+func (s *BorrowService) BorrowBook(actor Actor, userID, bookID uint) error {
+	_, err := s.checkout(actor, userID, bookID, "")
+	return err
+}
+
+// CheckoutByBarcode 扫描副本条码为读者办理借阅
+func (s *BorrowService) CheckoutByBarcode(actor Actor, userID uint, barcode string) (*model.Borrow, error) {
+	item, err := s.copyRepo.GetByBarcode(barcode)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrNotFound
+	}
+	return s.checkout(actor, userID, item.BookID, barcode)
+}
+
+// checkout 在一个事务中办理借阅，任何一步失败都整体回滚
+func (s *BorrowService) checkout(actor Actor, userID, bookID uint, barcode string) (*model.Borrow, error) {
+	var borrow *model.Borrow
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		var err error
+		borrow, err = s.withRepos(repos).checkoutTx(userID, bookID, barcode)
+		if err != nil {
+			return err
+		}
+		return writeAuditChange(repos.AuditLog, actor, AuditBorrowCreate, AuditTargetBorrow, borrow.ID, nil, borrow, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return borrow, nil
+}
+
+// checkoutTx 办理借阅，barcode为空时自动分配副本：
+// 预约到书的读者优先取走为其保留的副本，否则分配第一个在架副本。
+// 依次锁定读者和图书行，同一读者或同一本书的并发借阅会串行执行。
+func (s *BorrowService) checkoutTx(userID, bookID uint, barcode string) (*model.Borrow, error) {
 	// 检查用户是否存在
-	user, err := s.userRepo.GetByID(userID)
+	user, err := s.userRepo.LockByID(userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if user == nil {
-		return ErrNotFound
+		return nil, ErrNotFound
 	}
 
 	// 检查图书是否存在且可借
-	book, err := s.bookRepo.GetByID(bookID)
+	book, err := s.bookRepo.LockByID(bookID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if book == nil {
-		return ErrNotFound
+		return nil, ErrNotFound
 	}
 	if book.Status != 1 {
-		return ErrBookNotAvailable
+		return nil, ErrBookNotAvailable
 	}
-	if book.Available <= 0 {
-		return ErrBookNotAvailable
+
+	// 处理过期的预约保留，使副本状态保持最新
+	if err := processHolds(s.reservationRepo, s.bookRepo, s.copyRepo, bookID); err != nil {
+		return nil, err
+	}
+
+	// 已到书的预约读者直接取走为其保留的副本
+	reservation, err := s.reservationRepo.GetActiveByUserAndBook(userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	onHold := reservation != nil && reservation.Status == 2
+	item, err := s.pickCopy(bookID, barcode, reservation, onHold)
+	if err != nil {
+		return nil, err
 	}
 
 	// 检查用户是否有未归还的同一本书
-	borrows, err := s.borrowRepo.GetUserBorrows(userID, 1)
+	borrows, err := s.borrowRepo.GetUserActiveBorrows(userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	for _, b := range borrows {
 		if b.BookID == bookID {
-			return ErrAlreadyExists
+			return nil, ErrAlreadyExists
 		}
 	}
 
+	// 按读者角色和图书分类确定借阅规则
+	policy, err := resolveLoanPolicy(s.loanPolicyRepo, user.Role, book.Category)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBorrowLimits(user, len(borrows), policy); err != nil {
+		return nil, err
+	}
+
 	// 创建借阅记录
+	now := time.Now()
 	borrow := &model.Borrow{
 		UserID:     userID,
 		BookID:     bookID,
-		BorrowDate: time.Now(),
-		DueDate:    time.Now().AddDate(0, 0, 30), // 默认借期30天
-		Status:     1,                            // 借阅中
+		CopyID:     item.ID,
+		BorrowDate: now,
+		DueDate:    now.AddDate(0, 0, policy.LoanDays),
+		Status:     1, // 借阅中
 	}
 
-	// 更新图书库存
-	if err := s.bookRepo.UpdateStock(bookID, book.Available-1); err != nil {
+	// 仅当副本仍处于选中时的状态才借出，防止同一副本被借出两次
+	claimed, err := s.copyRepo.UpdateStatusIf(item.ID, item.Status, 2) // 借出
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrBookNotAvailable
+	}
+
+	if err := s.borrowRepo.Create(borrow); err != nil {
+		return nil, err
+	}
+
+	// 完成该读者的预约
+	if reservation != nil {
+		now := time.Now()
+		reservation.Status = 3 // 已完成
+		reservation.FinishedAt = &now
+		if err := s.reservationRepo.Update(reservation); err != nil {
+			return nil, err
+		}
+
+		// 读者取走的不是为其保留的副本时，保留的副本转给队列中的下一位
+		if onHold && (reservation.CopyID == nil || *reservation.CopyID != item.ID) {
+			if err := releaseHeldCopy(s.copyRepo, reservation); err != nil {
+				return nil, err
+			}
+			if err := processHolds(s.reservationRepo, s.bookRepo, s.copyRepo, bookID); err != nil {
+				return nil, err
+			}
+			return borrow, nil
+		}
+	}
+
+	// 根据副本更新图书库存
+	if err := s.bookRepo.SyncStock(bookID); err != nil {
+		return nil, err
+	}
+	return borrow, nil
+}
+
+// pickCopy 确定借出的副本
+func (s *BorrowService) pickCopy(bookID uint, barcode string, reservation *model.Reservation, onHold bool) (*model.Copy, error) {
+	heldFor := func(item *model.Copy) bool {
+		return onHold && reservation.CopyID != nil && *reservation.CopyID == item.ID
+	}
+
+	// 扫码借阅：副本须在架，或是为该读者保留的副本
+	if barcode != "" {
+		item, err := s.copyRepo.GetByBarcode(barcode)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil || item.BookID != bookID {
+			return nil, ErrNotFound
+		}
+		if item.Status == 1 || (item.Status == 3 && heldFor(item)) {
+			return item, nil
+		}
+		return nil, ErrBookNotAvailable
+	}
+
+	if onHold && reservation.CopyID != nil {
+		item, err := s.copyRepo.GetByID(*reservation.CopyID)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil && item.Status == 3 {
+			return item, nil
+		}
+	}
+
+	item, err := s.copyRepo.GetFirstAvailable(bookID)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrBookNotAvailable
+	}
+	return item, nil
+}
+
+// checkBorrowLimits 检查读者是否满足借阅条件：账号状态、同时借阅数量、逾期数量和未缴罚金
+func (s *BorrowService) checkBorrowLimits(user *model.User, activeLoans int, policy *model.LoanPolicy) error {
+	if user.Status != 1 {
+		return &LimitError{Err: ErrBorrowLimitExceeded, Reason: ReasonAccountDisabled}
+	}
+
+	if policy.MaxLoans > 0 && activeLoans >= policy.MaxLoans {
+		return &LimitError{
+			Err:     ErrBorrowLimitExceeded,
+			Reason:  ReasonMaxLoans,
+			Limit:   float64(policy.MaxLoans),
+			Current: float64(activeLoans),
+		}
+	}
+
+	overdue, err := s.borrowRepo.CountUserOverdue(user.ID)
+	if err != nil {
 		return err
 	}
+	maxOverdue := config.GlobalConfig.Borrow.MaxOverdue
+	if overdue > int64(maxOverdue) {
+		return &LimitError{
+			Err:     ErrBorrowLimitExceeded,
+			Reason:  ReasonOverdueItems,
+			Limit:   float64(maxOverdue),
+			Current: float64(overdue),
+		}
+	}
 
-	return s.borrowRepo.Create(borrow)
+	// 未缴罚金 = 罚金账户余额 + 未归还借阅上正在累计的罚金
+	balance, err := s.fineRepo.GetBalance(user.ID)
+	if err != nil {
+		return err
+	}
+	accruing, err := s.borrowRepo.SumUserFines(user.ID)
+	if err != nil {
+		return err
+	}
+	fines := roundAmount(balance + accruing)
+	threshold := config.GlobalConfig.Borrow.FineThreshold
+	if fines > threshold {
+		return &LimitError{
+			Err:     ErrBorrowLimitExceeded,
+			Reason:  ReasonUnpaidFines,
+			Limit:   threshold,
+			Current: fines,
+		}
+	}
+	return nil
 }
 
 // ReturnBook 归还图书
-func (s *BorrowService) ReturnBook(userID, bookID uint) error {
+func (s *BorrowService) ReturnBook(actor Actor, userID, bookID uint) error {
 	// 获取借阅记录
 	borrow, err := s.borrowRepo.GetByUserAndBookID(userID, bookID)
 	if err != nil {
@@ -97,48 +313,254 @@ func (s *BorrowService) ReturnBook(userID, bookID uint) error {
 	if borrow == nil {
 		return ErrNotFound
 	}
-	if borrow.Status != 1 {
-		return ErrNotBorrowed
+	_, err = s.returnBorrow(actor, borrow.ID, borrow.BookID)
+	return err
+}
+
+// CheckinByBarcode 扫描副本条码办理归还
+func (s *BorrowService) CheckinByBarcode(actor Actor, barcode string) (*model.Borrow, error) {
+	item, err := s.copyRepo.GetByBarcode(barcode)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrNotFound
 	}
 
+	borrow, err := s.borrowRepo.GetActiveByCopyID(item.ID)
+	if err != nil {
+		return nil, err
+	}
+	if borrow == nil {
+		return nil, ErrNotBorrowed
+	}
+	return s.returnBorrow(actor, borrow.ID, borrow.BookID)
+}
+
+// returnBorrow 在一个事务中办理归还，任何一步失败都整体回滚
+func (s *BorrowService) returnBorrow(actor Actor, borrowID, bookID uint) (*model.Borrow, error) {
+	var borrow *model.Borrow
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		var err error
+		borrow, err = s.withRepos(repos).returnBorrowTx(actor, borrowID, bookID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return borrow, nil
+}
+
+// returnBorrowTx 归还借阅：计算罚金，副本放回书架并优先分配给预约队列。
+// 先锁定图书行再读取借阅记录，同一借阅的并发归还只有一次生效。
+func (s *BorrowService) returnBorrowTx(actor Actor, borrowID, bookID uint) (*model.Borrow, error) {
+	book, err := s.bookRepo.LockByID(bookID)
+	if err != nil {
+		return nil, err
+	}
+	if book == nil {
+		return nil, ErrNotFound
+	}
+	borrow, err := s.borrowRepo.GetByID(borrowID)
+	if err != nil {
+		return nil, err
+	}
+	if borrow == nil {
+		return nil, ErrNotFound
+	}
+	if borrow.Status != 1 && borrow.Status != 3 {
+		return nil, ErrNotBorrowed
+	}
+	before := *borrow
+
 	// 更新借阅状态
 	borrow.Status = 2 // 已归还
 	borrow.ReturnDate = time.Now()
 
-	// 计算是否逾期及罚金
-	if borrow.ReturnDate.After(borrow.DueDate) {
-		days := int(borrow.ReturnDate.Sub(borrow.DueDate).Hours() / 24)
-		borrow.Fine = float64(days) * 0.5 // 每天罚款0.5元
+	// 按借阅规则计算逾期罚金
+	user, err := s.userRepo.GetByID(borrow.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrNotFound
+	}
+	policy, err := resolveLoanPolicy(s.loanPolicyRepo, user.Role, book.Category)
+	if err != nil {
+		return nil, err
+	}
+	borrow.Fine, err = capBorrowFine(s.fineRepo, policy, borrow.ID, calculateFine(policy, borrow.DueDate, borrow.ReturnDate))
+	if err != nil {
+		return nil, err
+	}
+
+	// 副本放回书架
+	if borrow.CopyID > 0 {
+		if err := s.copyRepo.UpdateStatus(borrow.CopyID, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.borrowRepo.Update(borrow); err != nil {
+		return nil, err
+	}
+	// 逾期扫描累计的罚金多于按归还时间计算的结果（如续借后按时归还）时，Updates 会跳过零值，需单独写入
+	if borrow.Fine == 0 && before.Fine != 0 {
+		if err := s.borrowRepo.UpdateFine(borrow.ID, 0); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeAuditChange(s.auditLogRepo, actor, AuditBorrowReturn, AuditTargetBorrow, borrow.ID, &before, borrow, nil); err != nil {
+		return nil, err
+	}
+
+	// 逾期罚金记入读者的罚金账户
+	if borrow.Fine > 0 {
+		charge := &model.FineTransaction{
+			UserID:   borrow.UserID,
+			BorrowID: &borrow.ID,
+			Type:     FineTypeCharge,
+			Amount:   borrow.Fine,
+			Reason:   "overdue",
+		}
+		if err := s.fineRepo.Create(charge); err != nil {
+			return nil, err
+		}
 	}
 
-	// 更新图书库存
-	book, err := s.bookRepo.GetByID(borrow.BookID)
+	// 归还的副本优先留给预约队列中的第一位读者，并重新统计库存
+	if err := processHolds(s.reservationRepo, s.bookRepo, s.copyRepo, borrow.BookID); err != nil {
+		return nil, err
+	}
+	return borrow, nil
+}
+
+// RenewBook 续借图书，读者只能续借自己的借阅，管理员可续借任意借阅
+func (s *BorrowService) RenewBook(actor Actor, borrowID uint, userID uint, isAdmin bool) (*model.Borrow, error) {
+	current, err := s.borrowRepo.GetByID(borrowID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := s.bookRepo.UpdateStock(borrow.BookID, book.Available+1); err != nil {
-		return err
+	if current == nil {
+		return nil, ErrNotFound
 	}
 
-	return s.borrowRepo.Update(borrow)
+	var borrow *model.Borrow
+	err = s.uow.Do(func(repos *mysql.Repositories) error {
+		var err error
+		borrow, err = s.withRepos(repos).renewBookTx(actor, borrowID, current.BookID, userID, isAdmin)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return borrow, nil
 }
 
-// RenewBook 续借图书
-func (s *BorrowService) RenewBook(borrowID uint) error {
+// renewBookTx 锁定图书行后检查续借条件并顺延到期时间，防止并发续借超过次数限制
+func (s *BorrowService) renewBookTx(actor Actor, borrowID, bookID uint, userID uint, isAdmin bool) (*model.Borrow, error) {
+	if _, err := s.bookRepo.LockByID(bookID); err != nil {
+		return nil, err
+	}
+
 	borrow, err := s.borrowRepo.GetByID(borrowID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if borrow == nil {
-		return ErrNotFound
+		return nil, ErrNotFound
+	}
+	if !isAdmin && borrow.UserID != userID {
+		return nil, ErrPermissionDenied
+	}
+	if borrow.Status != 1 && borrow.Status != 3 {
+		return nil, ErrNotBorrowed
+	}
+
+	policy, err := resolveLoanPolicy(s.loanPolicyRepo, borrow.User.Role, borrow.Book.Category)
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查续借次数
+	if borrow.RenewCount >= policy.MaxRenewals {
+		return nil, &LimitError{
+			Err:     ErrRenewNotAllowed,
+			Reason:  ReasonMaxRenewals,
+			Limit:   float64(policy.MaxRenewals),
+			Current: float64(borrow.RenewCount),
+		}
 	}
-	if borrow.Status != 1 {
-		return ErrNotBorrowed
+
+	// 有其他读者排队预约时不允许续借
+	waiting, err := s.reservationRepo.CountWaiting(borrow.BookID, borrow.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if waiting > 0 {
+		return nil, &LimitError{
+			Err:     ErrRenewNotAllowed,
+			Reason:  ReasonHoldPending,
+			Current: float64(waiting),
+		}
+	}
+
+	// 逾期过久不允许续借
+	now := time.Now()
+	if now.After(borrow.DueDate) {
+		overdueDays := int(now.Sub(borrow.DueDate).Hours() / 24)
+		maxDays := config.GlobalConfig.Borrow.RenewOverdue
+		if overdueDays > maxDays {
+			return nil, &LimitError{
+				Err:     ErrRenewNotAllowed,
+				Reason:  ReasonOverdueTooLong,
+				Limit:   float64(maxDays),
+				Current: float64(overdueDays),
+			}
+		}
 	}
 
-	// 更新到期时间（从当前时间起再借30天）
-	borrow.DueDate = time.Now().AddDate(0, 0, 30)
-	return s.borrowRepo.Update(borrow)
+	// 从原到期时间起顺延一个借期，已逾期的从当前时间起计算
+	before := *borrow
+	base := borrow.DueDate
+	if now.After(base) {
+		base = now
+	}
+	// 续借前逾期产生的罚金按续借时间结算，归还时只计算新到期时间之后的逾期
+	accrued, err := capBorrowFine(s.fineRepo, policy, borrow.ID, calculateFine(policy, borrow.DueDate, now))
+	if err != nil {
+		return nil, err
+	}
+	borrow.DueDate = base.AddDate(0, 0, policy.LoanDays)
+	borrow.RenewCount++
+	borrow.Status = 1 // 续借后恢复为借阅中
+	borrow.Fine = 0
+	if err := s.borrowRepo.Update(borrow); err != nil {
+		return nil, err
+	}
+	// Updates 会跳过零值，罚金需单独清零
+	if before.Fine != 0 {
+		if err := s.borrowRepo.UpdateFine(borrow.ID, 0); err != nil {
+			return nil, err
+		}
+	}
+	// 结算的逾期罚金记入读者的罚金账户
+	if accrued > 0 {
+		charge := &model.FineTransaction{
+			UserID:   borrow.UserID,
+			BorrowID: &borrow.ID,
+			Type:     FineTypeCharge,
+			Amount:   accrued,
+			Reason:   "overdue",
+		}
+		if err := s.fineRepo.Create(charge); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeAuditChange(s.auditLogRepo, actor, AuditBorrowRenew, AuditTargetBorrow, borrow.ID, &before, borrow, nil); err != nil {
+		return nil, err
+	}
+	return borrow, nil
 }
 
 // GetBorrow 获取借阅记录
@@ -166,7 +588,19 @@ func (s *BorrowService) GetOverdueBorrows() ([]*model.Borrow, error) {
 	return s.borrowRepo.GetOverdueBorrows()
 }
 
-// UpdateBorrow 更新借阅记录
-func (s *BorrowService) UpdateBorrow(borrow *model.Borrow) error {
-	return s.borrowRepo.Update(borrow)
+// UpdateBorrow 更新借阅记录，如修改应还日期、状态、罚金
+func (s *BorrowService) UpdateBorrow(actor Actor, borrow *model.Borrow) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		exist, err := repos.Borrow.GetByID(borrow.ID)
+		if err != nil {
+			return err
+		}
+		if exist == nil {
+			return ErrNotFound
+		}
+		if err := repos.Borrow.Update(borrow); err != nil {
+			return err
+		}
+		return writeAuditChange(repos.AuditLog, actor, AuditBorrowUpdate, AuditTargetBorrow, borrow.ID, exist, borrow, nil)
+	})
 }
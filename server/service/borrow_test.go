@@ -0,0 +1,408 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"library/config"
+	"library/model"
+	"library/repository/mysql"
+)
+
+func newTestBorrowService(db *gorm.DB) BorrowServiceInterface {
+	return NewBorrowService(mysql.NewBorrowRepository(db), mysql.NewBookRepository(db), mysql.NewUserRepository(db),
+		mysql.NewReservationRepository(db), mysql.NewLoanPolicyRepository(db), mysql.NewFineRepository(db),
+		mysql.NewCopyRepository(db), mysql.NewAuditLogRepository(db), mysql.NewUnitOfWork(db))
+}
+
+// TestConcurrentCheckoutLastCopy 多位读者同时借阅只剩一个副本的图书，只有一人成功
+func TestConcurrentCheckoutLastCopy(t *testing.T) {
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.Borrow.DefaultPolicy = config.LoanPolicyConfig{LoanDays: 30, MaxRenewals: 1, DailyFine: 0.5}
+		cfg.Borrow.FineThreshold = 10
+	})
+
+	tests := []struct {
+		name      string
+		byBarcode bool
+	}{
+		{"auto assign", false},
+		{"by barcode", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			book := createTestBook(t, db, "9787000000001")
+			item := &model.Copy{BookID: book.ID, Barcode: "LIB0001"}
+			if err := newTestCopyService(db).AddCopy(SystemActor, item); err != nil {
+				t.Fatalf("AddCopy: %v", err)
+			}
+
+			const readers = 8
+			users := make([]*model.User, readers)
+			for i := range users {
+				users[i] = createTestUser(t, db, fmt.Sprintf("reader%d", i))
+			}
+
+			svc := newTestBorrowService(db)
+			errs := make([]error, readers)
+			start := make(chan struct{})
+			var wg sync.WaitGroup
+			for i, user := range users {
+				wg.Add(1)
+				go func(i int, userID uint) {
+					defer wg.Done()
+					<-start
+					if tt.byBarcode {
+						_, errs[i] = svc.CheckoutByBarcode(SystemActor, userID, item.Barcode)
+					} else {
+						errs[i] = svc.BorrowBook(SystemActor, userID, book.ID)
+					}
+				}(i, user.ID)
+			}
+			close(start)
+			wg.Wait()
+
+			succeeded, unavailable := 0, 0
+			for i, err := range errs {
+				switch {
+				case err == nil:
+					succeeded++
+				case errors.Is(err, ErrBookNotAvailable):
+					unavailable++
+				default:
+					t.Errorf("reader%d: unexpected error %v", i, err)
+				}
+			}
+			if succeeded != 1 || unavailable != readers-1 {
+				t.Fatalf("succeeded = %d, not available = %d, want 1 and %d", succeeded, unavailable, readers-1)
+			}
+
+			var borrows int64
+			if err := db.Model(&model.Borrow{}).Where("book_id = ? AND status = 1", book.ID).Count(&borrows).Error; err != nil {
+				t.Fatalf("count borrows: %v", err)
+			}
+			if borrows != 1 {
+				t.Fatalf("active borrows = %d, want 1", borrows)
+			}
+
+			bookRepo := mysql.NewBookRepository(db)
+			if err := bookRepo.SyncStock(book.ID); err != nil {
+				t.Fatalf("SyncStock: %v", err)
+			}
+			got, err := mysql.NewCopyRepository(db).GetByID(item.ID)
+			if err != nil {
+				t.Fatalf("GetByID copy: %v", err)
+			}
+			if got.Status != 2 {
+				t.Fatalf("copy status = %d, want 2 (borrowed)", got.Status)
+			}
+			current, err := bookRepo.GetByID(book.ID)
+			if err != nil {
+				t.Fatalf("GetByID book: %v", err)
+			}
+			if current.Total != 1 || current.Available != 0 {
+				t.Fatalf("book stock = %d/%d, want 0/1", current.Available, current.Total)
+			}
+		})
+	}
+}
+
+// TestRenewFineCap 多次续借和归还时记入的逾期罚款总额不超过单次借阅的罚金上限
+func TestRenewFineCap(t *testing.T) {
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.Borrow.DefaultPolicy = config.LoanPolicyConfig{LoanDays: 30, MaxRenewals: 2, DailyFine: 0.5, FineCap: 3}
+		cfg.Borrow.RenewOverdue = 30
+		cfg.Borrow.FineThreshold = 100
+	})
+	db := newTestDB(t)
+	book := createTestBook(t, db, "9787000000001")
+	if err := newTestCopyService(db).AddCopy(SystemActor, &model.Copy{BookID: book.ID, Barcode: "LIB0001"}); err != nil {
+		t.Fatalf("AddCopy: %v", err)
+	}
+	user := createTestUser(t, db, "reader")
+	svc := newTestBorrowService(db)
+	borrow, err := svc.CheckoutByBarcode(SystemActor, user.ID, "LIB0001")
+	if err != nil {
+		t.Fatalf("CheckoutByBarcode: %v", err)
+	}
+
+	// overdue 将借阅改为已逾期 days 天
+	overdue := func(days int) {
+		t.Helper()
+		dueDate := time.Now().Add(-time.Duration(days)*24*time.Hour - time.Hour)
+		if err := db.Model(&model.Borrow{}).Where("id = ?", borrow.ID).Update("due_date", dueDate).Error; err != nil {
+			t.Fatalf("set due date: %v", err)
+		}
+	}
+	charged := func() float64 {
+		t.Helper()
+		total, err := mysql.NewFineRepository(db).SumBorrowCharges(borrow.ID)
+		if err != nil {
+			t.Fatalf("SumBorrowCharges: %v", err)
+		}
+		return total
+	}
+
+	overdue(5)
+	if _, err := svc.RenewBook(SystemActor, borrow.ID, user.ID, true); err != nil {
+		t.Fatalf("first RenewBook: %v", err)
+	}
+	if got := charged(); got != 2.5 {
+		t.Fatalf("charged after first renewal = %v, want 2.5", got)
+	}
+
+	overdue(5)
+	if _, err := svc.RenewBook(SystemActor, borrow.ID, user.ID, true); err != nil {
+		t.Fatalf("second RenewBook: %v", err)
+	}
+	if got := charged(); got != 3 {
+		t.Fatalf("charged after second renewal = %v, want the cap 3", got)
+	}
+
+	overdue(5)
+	if err := svc.ReturnBook(SystemActor, user.ID, book.ID); err != nil {
+		t.Fatalf("ReturnBook: %v", err)
+	}
+	if got := charged(); got != 3 {
+		t.Fatalf("charged after return = %v, want the cap 3", got)
+	}
+	var returned model.Borrow
+	if err := db.First(&returned, borrow.ID).Error; err != nil {
+		t.Fatalf("get borrow: %v", err)
+	}
+	if returned.Status != 2 || returned.Fine != 0 {
+		t.Fatalf("returned borrow = status %d, fine %v, want 2, 0", returned.Status, returned.Fine)
+	}
+}
+
+// TestConcurrentReturnAndRenew 同一借阅同时归还和续借，续借不会使已归还的借阅恢复为借阅中
+func TestConcurrentReturnAndRenew(t *testing.T) {
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.Borrow.DefaultPolicy = config.LoanPolicyConfig{LoanDays: 30, MaxRenewals: 100, DailyFine: 0.5}
+		cfg.Borrow.FineThreshold = 10
+	})
+	db := newTestDB(t)
+	book := createTestBook(t, db, "9787000000001")
+	item := &model.Copy{BookID: book.ID, Barcode: "LIB0001"}
+	if err := newTestCopyService(db).AddCopy(SystemActor, item); err != nil {
+		t.Fatalf("AddCopy: %v", err)
+	}
+	user := createTestUser(t, db, "reader")
+	svc := newTestBorrowService(db)
+
+	for round := 0; round < 10; round++ {
+		borrow, err := svc.CheckoutByBarcode(SystemActor, user.ID, item.Barcode)
+		if err != nil {
+			t.Fatalf("round %d: CheckoutByBarcode: %v", round, err)
+		}
+
+		var returnErr, renewErr error
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			<-start
+			returnErr = svc.ReturnBook(SystemActor, user.ID, book.ID)
+		}()
+		go func() {
+			defer wg.Done()
+			<-start
+			_, renewErr = svc.RenewBook(SystemActor, borrow.ID, user.ID, false)
+		}()
+		close(start)
+		wg.Wait()
+
+		if returnErr != nil {
+			t.Fatalf("round %d: ReturnBook: %v", round, returnErr)
+		}
+		if renewErr != nil && !errors.Is(renewErr, ErrNotBorrowed) {
+			t.Fatalf("round %d: RenewBook: unexpected error %v", round, renewErr)
+		}
+
+		var got model.Borrow
+		if err := db.First(&got, borrow.ID).Error; err != nil {
+			t.Fatalf("round %d: get borrow: %v", round, err)
+		}
+		if got.Status != 2 {
+			t.Fatalf("round %d: borrow status = %d, want 2 (returned)", round, got.Status)
+		}
+		if renewErr == nil && got.RenewCount != 1 {
+			t.Fatalf("round %d: renew count = %d, want 1 after a successful renewal", round, got.RenewCount)
+		}
+		assertStock(t, db, book.ID, 1, 1)
+	}
+}
+
+// TestConcurrentCheckoutBorrowLimit 同一读者同时借阅多本图书，不会超过同时借阅数量上限
+func TestConcurrentCheckoutBorrowLimit(t *testing.T) {
+	const maxLoans, books = 2, 6
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.Borrow.DefaultPolicy = config.LoanPolicyConfig{LoanDays: 30, MaxRenewals: 1, MaxLoans: maxLoans, DailyFine: 0.5}
+		cfg.Borrow.FineThreshold = 10
+	})
+	db := newTestDB(t)
+	copies := newTestCopyService(db)
+	bookIDs := make([]uint, books)
+	for i := range bookIDs {
+		book := createTestBook(t, db, fmt.Sprintf("978700000000%d", i))
+		if err := copies.AddCopy(SystemActor, &model.Copy{BookID: book.ID}); err != nil {
+			t.Fatalf("AddCopy: %v", err)
+		}
+		bookIDs[i] = book.ID
+	}
+	user := createTestUser(t, db, "reader")
+	svc := newTestBorrowService(db)
+
+	errs := make([]error, books)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, bookID := range bookIDs {
+		wg.Add(1)
+		go func(i int, bookID uint) {
+			defer wg.Done()
+			<-start
+			errs[i] = svc.BorrowBook(SystemActor, user.ID, bookID)
+		}(i, bookID)
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded, limited := 0, 0
+	for i, err := range errs {
+		var limitErr *LimitError
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.As(err, &limitErr) && limitErr.Reason == ReasonMaxLoans:
+			limited++
+		default:
+			t.Errorf("book %d: unexpected error %v", bookIDs[i], err)
+		}
+	}
+	if succeeded != maxLoans || limited != books-maxLoans {
+		t.Fatalf("succeeded = %d, limited = %d, want %d and %d", succeeded, limited, maxLoans, books-maxLoans)
+	}
+
+	var active int64
+	if err := db.Model(&model.Borrow{}).Where("user_id = ? AND status = 1", user.ID).Count(&active).Error; err != nil {
+		t.Fatalf("count borrows: %v", err)
+	}
+	if active != maxLoans {
+		t.Fatalf("active borrows = %d, want %d", active, maxLoans)
+	}
+}
+
+// TestStockAdjustmentDuringCheckout 借阅的同时调整库存，副本不会被重复借出或注销，库存与副本一致
+func TestStockAdjustmentDuringCheckout(t *testing.T) {
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.Borrow.DefaultPolicy = config.LoanPolicyConfig{LoanDays: 30, MaxRenewals: 1, DailyFine: 0.5}
+		cfg.Borrow.FineThreshold = 10
+	})
+	db := newTestDB(t)
+	book := createTestBook(t, db, "9787000000001")
+	books := NewBookService(mysql.NewBookRepository(db), mysql.NewCopyRepository(db), mysql.NewReservationRepository(db), nil, mysql.NewUnitOfWork(db))
+	if err := books.UpdateBookStock(SystemActor, book.ID, 4); err != nil {
+		t.Fatalf("UpdateBookStock: %v", err)
+	}
+
+	const readers = 6
+	users := make([]*model.User, readers)
+	for i := range users {
+		users[i] = createTestUser(t, db, fmt.Sprintf("reader%d", i))
+	}
+	svc := newTestBorrowService(db)
+
+	changes := []int{-1, -1, 2, -2}
+	borrowErrs := make([]error, readers)
+	stockErrs := make([]error, len(changes))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, user := range users {
+		wg.Add(1)
+		go func(i int, userID uint) {
+			defer wg.Done()
+			<-start
+			borrowErrs[i] = svc.BorrowBook(SystemActor, userID, book.ID)
+		}(i, user.ID)
+	}
+	for i, change := range changes {
+		wg.Add(1)
+		go func(i, change int) {
+			defer wg.Done()
+			<-start
+			stockErrs[i] = books.UpdateBookStock(SystemActor, book.ID, change)
+		}(i, change)
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for i, err := range borrowErrs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, ErrBookNotAvailable):
+		default:
+			t.Errorf("reader%d: unexpected error %v", i, err)
+		}
+	}
+	// 在架副本不足时注销失败，其余调整都应成功
+	for i, err := range stockErrs {
+		if err != nil && !strings.Contains(err.Error(), "negative") {
+			t.Errorf("stock change %d: unexpected error %v", changes[i], err)
+		}
+	}
+
+	var items []*model.Copy
+	if err := db.Where("book_id = ?", book.ID).Find(&items).Error; err != nil {
+		t.Fatalf("list copies: %v", err)
+	}
+	status := make(map[uint]int, len(items))
+	counts := make(map[int]int)
+	for _, item := range items {
+		status[item.ID] = item.Status
+		counts[item.Status]++
+	}
+
+	var borrows []*model.Borrow
+	if err := db.Where("book_id = ? AND status = 1", book.ID).Find(&borrows).Error; err != nil {
+		t.Fatalf("list borrows: %v", err)
+	}
+	if len(borrows) != succeeded {
+		t.Fatalf("active borrows = %d, want %d", len(borrows), succeeded)
+	}
+	lent := make(map[uint]bool)
+	for _, b := range borrows {
+		if lent[b.CopyID] {
+			t.Fatalf("copy %d lent twice", b.CopyID)
+		}
+		lent[b.CopyID] = true
+		if status[b.CopyID] != 2 {
+			t.Fatalf("lent copy %d has status %d, want 2", b.CopyID, status[b.CopyID])
+		}
+	}
+	if counts[2] != succeeded {
+		t.Fatalf("borrowed copies = %d, want %d", counts[2], succeeded)
+	}
+	assertStock(t, db, book.ID, counts[1], counts[1]+counts[2])
+}
+
+// assertStock 校验图书的在架数量和总数量
+func assertStock(t *testing.T, db *gorm.DB, bookID uint, available, total int) {
+	t.Helper()
+	book, err := mysql.NewBookRepository(db).GetByID(bookID)
+	if err != nil {
+		t.Fatalf("GetByID book: %v", err)
+	}
+	if book.Available != available || book.Total != total {
+		t.Fatalf("book stock = %d/%d, want %d/%d", book.Available, book.Total, available, total)
+	}
+}
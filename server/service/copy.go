@@ -0,0 +1,390 @@
+package service
+
+import (
+	"fmt"
+
+	"library/model"
+	"library/repository/mysql"
+)
+
+// CopyServiceInterface 馆藏副本服务接口
+type CopyServiceInterface interface {
+	AddCopy(actor Actor, item *model.Copy) error
+	UpdateCopy(actor Actor, item *model.Copy) error
+	DeleteCopy(actor Actor, id uint) error
+	GetCopy(id uint) (*model.Copy, error)
+	GetCopyByBarcode(barcode string) (*model.Copy, error)
+	ListBookCopies(bookID uint, status int) ([]*model.Copy, error)
+	BackfillCopies() (int, error)
+}
+
+type CopyService struct {
+	copyRepo        mysql.CopyRepository
+	bookRepo        mysql.BookRepository
+	borrowRepo      mysql.BorrowRepository
+	reservationRepo mysql.ReservationRepository
+	auditLogRepo    mysql.AuditLogRepository
+	uow             mysql.UnitOfWork
+}
+
+func NewCopyService(copyRepo mysql.CopyRepository, bookRepo mysql.BookRepository, borrowRepo mysql.BorrowRepository, reservationRepo mysql.ReservationRepository, auditLogRepo mysql.AuditLogRepository, uow mysql.UnitOfWork) CopyServiceInterface {
+	return &CopyService{
+		copyRepo:        copyRepo,
+		bookRepo:        bookRepo,
+		borrowRepo:      borrowRepo,
+		reservationRepo: reservationRepo,
+		auditLogRepo:    auditLogRepo,
+		uow:             uow,
+	}
+}
+
+// withRepos 返回使用工作单元仓库的服务副本
+func (s *CopyService) withRepos(repos *mysql.Repositories) *CopyService {
+	return &CopyService{
+		copyRepo:        repos.Copy,
+		bookRepo:        repos.Book,
+		borrowRepo:      repos.Borrow,
+		reservationRepo: repos.Reservation,
+		auditLogRepo:    repos.AuditLog,
+		uow:             s.uow,
+	}
+}
+
+// withBookLock 在事务中锁定图书行后执行fn，与同一本书的借还操作串行
+func (s *CopyService) withBookLock(bookID uint, fn func(txs *CopyService) error) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		txs := s.withRepos(repos)
+		if _, err := txs.bookRepo.LockByID(bookID); err != nil {
+			return err
+		}
+		return fn(txs)
+	})
+}
+
+// AddCopy 为图书添加副本，未填写条码时自动生成
+func (s *CopyService) AddCopy(actor Actor, item *model.Copy) error {
+	return s.withBookLock(item.BookID, func(txs *CopyService) error {
+		return txs.addCopyTx(actor, item)
+	})
+}
+
+// addCopyTx 在已锁定图书行的事务中添加副本
+func (s *CopyService) addCopyTx(actor Actor, item *model.Copy) error {
+	book, err := s.bookRepo.GetByID(item.BookID)
+	if err != nil {
+		return fmt.Errorf("get book by id: %w", err)
+	}
+	if book == nil {
+		return ErrNotFound
+	}
+
+	if item.Barcode == "" {
+		barcode, err := nextBarcode(s.copyRepo, book.ID)
+		if err != nil {
+			return err
+		}
+		item.Barcode = barcode
+	} else {
+		// 已删除副本的条码也不能再使用
+		exist, err := s.copyRepo.BarcodeExists(item.Barcode)
+		if err != nil {
+			return fmt.Errorf("check barcode exists: %w", err)
+		}
+		if exist {
+			return ErrAlreadyExists
+		}
+	}
+
+	// 新副本只能是在架或维修中
+	if item.Status == 0 {
+		item.Status = 1
+	}
+	if item.Status != 1 && item.Status != 4 {
+		return ErrInvalidStatus
+	}
+	if item.Condition == 0 {
+		item.Condition = 1
+	}
+	if item.Location == "" {
+		item.Location = book.Location
+	}
+
+	if err := s.copyRepo.Create(item); err != nil {
+		return fmt.Errorf("create copy: %w", err)
+	}
+	if err := writeAuditChange(s.auditLogRepo, actor, AuditCopyCreate, AuditTargetCopy, item.ID, nil, item, nil); err != nil {
+		return err
+	}
+
+	// 新上架的副本优先分配给预约队列，并重新统计库存
+	return processHolds(s.reservationRepo, s.bookRepo, s.copyRepo, book.ID)
+}
+
+// UpdateCopy 更新副本信息。借出和预约保留状态由借还流程维护，不能手工修改
+func (s *CopyService) UpdateCopy(actor Actor, item *model.Copy) error {
+	current, err := s.copyRepo.GetByID(item.ID)
+	if err != nil {
+		return fmt.Errorf("get copy by id: %w", err)
+	}
+	if current == nil {
+		return ErrNotFound
+	}
+	return s.withBookLock(current.BookID, func(txs *CopyService) error {
+		return txs.updateCopyTx(actor, item)
+	})
+}
+
+// updateCopyTx 在已锁定图书行的事务中更新副本
+func (s *CopyService) updateCopyTx(actor Actor, item *model.Copy) error {
+	exist, err := s.copyRepo.GetByID(item.ID)
+	if err != nil {
+		return fmt.Errorf("get copy by id: %w", err)
+	}
+	if exist == nil {
+		return ErrNotFound
+	}
+
+	if item.Barcode != "" && item.Barcode != exist.Barcode {
+		used, err := s.copyRepo.BarcodeExists(item.Barcode)
+		if err != nil {
+			return fmt.Errorf("check barcode exists: %w", err)
+		}
+		if used {
+			return ErrAlreadyExists
+		}
+	}
+
+	if item.Status != 0 && item.Status != exist.Status {
+		if exist.Status == 2 || exist.Status == 3 {
+			return ErrInvalidStatus
+		}
+		if item.Status == 2 || item.Status == 3 {
+			return ErrInvalidStatus
+		}
+	}
+
+	item.BookID = exist.BookID
+	if err := s.copyRepo.Update(item); err != nil {
+		return fmt.Errorf("update copy: %w", err)
+	}
+	updated, err := s.copyRepo.GetByID(item.ID)
+	if err != nil {
+		return fmt.Errorf("get copy by id: %w", err)
+	}
+	if err := writeAuditChange(s.auditLogRepo, actor, AuditCopyUpdate, AuditTargetCopy, item.ID, exist, updated, nil); err != nil {
+		return err
+	}
+	return processHolds(s.reservationRepo, s.bookRepo, s.copyRepo, exist.BookID)
+}
+
+// DeleteCopy 删除副本，借出或预约保留中的副本不能删除
+func (s *CopyService) DeleteCopy(actor Actor, id uint) error {
+	current, err := s.copyRepo.GetByID(id)
+	if err != nil {
+		return fmt.Errorf("get copy by id: %w", err)
+	}
+	if current == nil {
+		return ErrNotFound
+	}
+	return s.withBookLock(current.BookID, func(txs *CopyService) error {
+		return txs.deleteCopyTx(actor, id)
+	})
+}
+
+// deleteCopyTx 在已锁定图书行的事务中删除副本
+func (s *CopyService) deleteCopyTx(actor Actor, id uint) error {
+	item, err := s.copyRepo.GetByID(id)
+	if err != nil {
+		return fmt.Errorf("get copy by id: %w", err)
+	}
+	if item == nil {
+		return ErrNotFound
+	}
+	if item.Status == 2 || item.Status == 3 {
+		return ErrInvalidStatus
+	}
+
+	if err := s.copyRepo.Delete(id); err != nil {
+		return fmt.Errorf("delete copy: %w", err)
+	}
+	if err := writeAuditChange(s.auditLogRepo, actor, AuditCopyDelete, AuditTargetCopy, id, item, nil, nil); err != nil {
+		return err
+	}
+	return s.bookRepo.SyncStock(item.BookID)
+}
+
+// GetCopy 获取副本
+func (s *CopyService) GetCopy(id uint) (*model.Copy, error) {
+	item, err := s.copyRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("get copy by id: %w", err)
+	}
+	if item == nil {
+		return nil, ErrNotFound
+	}
+	return item, nil
+}
+
+// GetCopyByBarcode 根据条码获取副本
+func (s *CopyService) GetCopyByBarcode(barcode string) (*model.Copy, error) {
+	item, err := s.copyRepo.GetByBarcode(barcode)
+	if err != nil {
+		return nil, fmt.Errorf("get copy by barcode: %w", err)
+	}
+	if item == nil {
+		return nil, ErrNotFound
+	}
+	return item, nil
+}
+
+// ListBookCopies 获取图书的副本列表
+func (s *CopyService) ListBookCopies(bookID uint, status int) ([]*model.Copy, error) {
+	book, err := s.bookRepo.GetByID(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("get book by id: %w", err)
+	}
+	if book == nil {
+		return nil, ErrNotFound
+	}
+	return s.copyRepo.GetBookCopies(bookID, status)
+}
+
+// BackfillCopies 为副本功能上线前录入的图书按原总数量生成副本，
+// 并把未归还的借阅和已到书的预约关联到生成的副本上。返回处理的图书数量。
+func (s *CopyService) BackfillCopies() (int, error) {
+	params := &model.SearchParams{OrderBy: "id"}
+	params.PageSize = 100
+
+	processed := 0
+	for page := 1; ; page++ {
+		params.Page = page
+		books, _, err := s.bookRepo.List(params)
+		if err != nil {
+			return processed, fmt.Errorf("list books: %w", err)
+		}
+
+		for _, book := range books {
+			var done bool
+			err := s.withBookLock(book.ID, func(txs *CopyService) error {
+				var err error
+				done, err = txs.backfillBook(book)
+				return err
+			})
+			if err != nil {
+				return processed, fmt.Errorf("backfill book %d: %w", book.ID, err)
+			}
+			if done {
+				processed++
+			}
+		}
+
+		if len(books) < params.PageSize {
+			return processed, nil
+		}
+	}
+}
+
+// backfillBook 在已锁定图书行的事务中为单本图书生成副本，已有副本的图书跳过
+func (s *CopyService) backfillBook(book *model.Book) (bool, error) {
+	count, err := s.copyRepo.CountAllByBook(book.ID)
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	borrows, err := s.borrowRepo.GetActiveWithoutCopy(book.ID)
+	if err != nil {
+		return false, err
+	}
+	queue, err := s.reservationRepo.GetBookQueue(book.ID)
+	if err != nil {
+		return false, err
+	}
+	var ready []*model.Reservation
+	for _, r := range queue {
+		if r.Status == 2 && r.CopyID == nil {
+			ready = append(ready, r)
+		}
+	}
+
+	n := book.Total
+	if n < len(borrows)+len(ready) {
+		n = len(borrows) + len(ready)
+	}
+	if n == 0 {
+		return false, nil
+	}
+
+	copies, err := addCopies(s.copyRepo, book, n)
+	if err != nil {
+		return false, err
+	}
+
+	// 依次关联未归还的借阅和待取书的预约，剩余副本在架
+	i := 0
+	for _, b := range borrows {
+		if err := s.copyRepo.UpdateStatus(copies[i].ID, 2); err != nil {
+			return false, err
+		}
+		b.CopyID = copies[i].ID
+		if err := s.borrowRepo.Update(b); err != nil {
+			return false, err
+		}
+		i++
+	}
+	for _, r := range ready {
+		if err := s.copyRepo.UpdateStatus(copies[i].ID, 3); err != nil {
+			return false, err
+		}
+		r.CopyID = &copies[i].ID
+		if err := s.reservationRepo.Update(r); err != nil {
+			return false, err
+		}
+		i++
+	}
+
+	return true, processHolds(s.reservationRepo, s.bookRepo, s.copyRepo, book.ID)
+}
+
+// addCopies 为图书批量生成在架副本，条码自动生成，架位取图书的馆藏位置
+func addCopies(copyRepo mysql.CopyRepository, book *model.Book, n int) ([]*model.Copy, error) {
+	copies := make([]*model.Copy, 0, n)
+	for i := 0; i < n; i++ {
+		barcode, err := nextBarcode(copyRepo, book.ID)
+		if err != nil {
+			return nil, err
+		}
+		item := &model.Copy{
+			BookID:    book.ID,
+			Barcode:   barcode,
+			Condition: 1, // 良好
+			Location:  book.Location,
+			Status:    1, // 在架
+		}
+		if err := copyRepo.Create(item); err != nil {
+			return nil, fmt.Errorf("create copy: %w", err)
+		}
+		copies = append(copies, item)
+	}
+	return copies, nil
+}
+
+// nextBarcode 生成图书的下一个副本条码：B + 6位图书ID + 3位序号，已被占用时顺延
+func nextBarcode(copyRepo mysql.CopyRepository, bookID uint) (string, error) {
+	count, err := copyRepo.CountAllByBook(bookID)
+	if err != nil {
+		return "", err
+	}
+	for seq := count + 1; ; seq++ {
+		barcode := fmt.Sprintf("B%06d%03d", bookID, seq)
+		used, err := copyRepo.BarcodeExists(barcode)
+		if err != nil {
+			return "", err
+		}
+		if !used {
+			return barcode, nil
+		}
+	}
+}
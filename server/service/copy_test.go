@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"library/model"
+	"library/repository/mysql"
+)
+
+func newTestCopyService(db *gorm.DB) CopyServiceInterface {
+	return NewCopyService(mysql.NewCopyRepository(db), mysql.NewBookRepository(db), mysql.NewBorrowRepository(db),
+		mysql.NewReservationRepository(db), mysql.NewAuditLogRepository(db), mysql.NewUnitOfWork(db))
+}
+
+func TestCopyBarcodeOfDeletedCopy(t *testing.T) {
+	db := newTestDB(t)
+	book := createTestBook(t, db, "9787000000001")
+	svc := newTestCopyService(db)
+
+	deleted := &model.Copy{BookID: book.ID, Barcode: "LIB0001"}
+	if err := svc.AddCopy(SystemActor, deleted); err != nil {
+		t.Fatalf("AddCopy: %v", err)
+	}
+	if err := svc.DeleteCopy(SystemActor, deleted.ID); err != nil {
+		t.Fatalf("DeleteCopy: %v", err)
+	}
+
+	// 已删除副本的条码仍占用唯一索引，应返回冲突而不是数据库错误
+	err := svc.AddCopy(SystemActor, &model.Copy{BookID: book.ID, Barcode: "LIB0001"})
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("AddCopy with deleted barcode err = %v, want ErrAlreadyExists", err)
+	}
+
+	other := &model.Copy{BookID: book.ID, Barcode: "LIB0002"}
+	if err := svc.AddCopy(SystemActor, other); err != nil {
+		t.Fatalf("AddCopy: %v", err)
+	}
+	err = svc.UpdateCopy(SystemActor, &model.Copy{ID: other.ID, Barcode: "LIB0001"})
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("UpdateCopy to deleted barcode err = %v, want ErrAlreadyExists", err)
+	}
+
+	// 自动生成的条码跳过已删除副本占用的序号
+	generated := []*model.Copy{{BookID: book.ID}, {BookID: book.ID}}
+	seen := map[string]bool{"LIB0001": true, "LIB0002": true}
+	for _, item := range generated {
+		if err := svc.AddCopy(SystemActor, item); err != nil {
+			t.Fatalf("AddCopy generated: %v", err)
+		}
+		if seen[item.Barcode] {
+			t.Fatalf("generated barcode %s is already used", item.Barcode)
+		}
+		seen[item.Barcode] = true
+	}
+
+	current, err := mysql.NewBookRepository(db).GetByID(book.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if current.Total != 3 || current.Available != 3 {
+		t.Fatalf("book stock = %d/%d, want 3/3", current.Available, current.Total)
+	}
+}
@@ -1,6 +1,9 @@
 package service
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrInvalidParameter 参数错误
@@ -11,6 +14,26 @@ var (
 	ErrAlreadyExists = errors.New("resource already exists")
 	// ErrPasswordIncorrect 密码错误
 	ErrPasswordIncorrect = errors.New("password incorrect")
+	// ErrAccountDisabled 账号已禁用
+	ErrAccountDisabled = errors.New("account disabled")
+	// ErrAccountLocked 登录失败次数过多，账号暂时锁定
+	ErrAccountLocked = errors.New("account temporarily locked")
+	// ErrTooManyAttempts 同一IP登录失败次数过多，暂时拒绝登录
+	ErrTooManyAttempts = errors.New("too many failed login attempts")
+	// ErrInvalidToken 一次性令牌无效或已使用
+	ErrInvalidToken = errors.New("invalid or used token")
+	// ErrTokenExpired 一次性令牌已过期
+	ErrTokenExpired = errors.New("token expired")
+	// ErrInvalidCode 双因素认证验证码或恢复码错误
+	ErrInvalidCode = errors.New("invalid verification code")
+	// ErrTwoFactorRequired 角色要求启用双因素认证，不能关闭
+	ErrTwoFactorRequired = errors.New("two-factor authentication is required for this role")
+	// ErrUnknownProvider 认证提供方不存在或不支持该登录方式
+	ErrUnknownProvider = errors.New("unknown authentication provider")
+	// ErrProviderUnavailable 身份提供方无法访问或返回了无效的响应
+	ErrProviderUnavailable = errors.New("identity provider unavailable")
+	// ErrLastAdmin 不能降级、禁用或删除最后一个启用的管理员
+	ErrLastAdmin = errors.New("cannot remove the last active admin")
 	// ErrBookNotAvailable 图书不可借
 	ErrBookNotAvailable = errors.New("book not available")
 	// ErrBorrowLimitExceeded 超出借阅限制
@@ -19,4 +42,46 @@ var (
 	ErrNotBorrowed = errors.New("book not borrowed")
 	// ErrPermissionDenied 权限不足
 	ErrPermissionDenied = errors.New("permission denied")
+	// ErrBookAvailable 图书有可借副本，无需预约
+	ErrBookAvailable = errors.New("book is available, no reservation needed")
+	// ErrInvalidStatus 当前状态不允许该操作
+	ErrInvalidStatus = errors.New("invalid status for this operation")
+	// ErrRenewNotAllowed 不允许续借
+	ErrRenewNotAllowed = errors.New("renewal not allowed")
+	// ErrJobLocked 任务正在其他实例上执行
+	ErrJobLocked = errors.New("job is running on another instance")
+	// ErrInvalidAmount 金额无效
+	ErrInvalidAmount = errors.New("invalid amount")
+	// ErrAmountExceedsBalance 金额超过可用余额
+	ErrAmountExceedsBalance = errors.New("amount exceeds balance")
+	// ErrPaymentFailed 支付渠道处理失败
+	ErrPaymentFailed = errors.New("payment failed")
+)
+
+// 借阅或续借被拒绝的原因代码
+const (
+	ReasonAccountDisabled = "account_disabled"     // 账号已禁用
+	ReasonMaxLoans        = "max_loans_reached"    // 已达到同时借阅数量上限
+	ReasonOverdueItems    = "overdue_items"        // 有逾期未还的图书
+	ReasonUnpaidFines     = "unpaid_fines"         // 未缴罚金超过限额
+	ReasonMaxRenewals     = "max_renewals_reached" // 已达到续借次数上限
+	ReasonHoldPending     = "hold_pending"         // 有其他读者在排队预约
+	ReasonOverdueTooLong  = "overdue_too_long"     // 逾期天数超过允许续借的范围
 )
+
+// LimitError 借阅规则拒绝操作时返回，携带结构化的原因代码。
+// errors.Is 可以匹配到 Err 中的哨兵错误（如 ErrBorrowLimitExceeded）。
+type LimitError struct {
+	Err     error   // 哨兵错误
+	Reason  string  // 原因代码
+	Limit   float64 // 规则限制值
+	Current float64 // 当前值
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s (current %v, limit %v)", e.Err, e.Reason, e.Current, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
@@ -3,6 +3,10 @@ package service
 import (
 	"sync"
 
+	"library/config"
+	"library/mailer"
+	"library/password"
+	"library/payment"
 	"library/repository/mysql"
 )
 
@@ -17,16 +21,44 @@ type Factory interface {
 	GetReviewService() ReviewServiceInterface
 	GetBorrowService() BorrowServiceInterface
 	GetBookService() BookServiceInterface
+	GetReservationService() ReservationServiceInterface
+	GetLoanPolicyService() LoanPolicyServiceInterface
+	GetOverdueService() OverdueServiceInterface
+	GetFineService() FineServiceInterface
+	GetCopyService() CopyServiceInterface
+	GetPermissionService() PermissionServiceInterface
+	GetMailService() MailServiceInterface
+	GetTwoFactorService() TwoFactorServiceInterface
+	GetAuthService() AuthServiceInterface
+	GetLDAPService() LDAPServiceInterface
+	GetAPIKeyService() APIKeyServiceInterface
+	GetAuditService() AuditServiceInterface
+	GetBookImportService() BookImportServiceInterface
+	GetBookSearchService() BookSearchServiceInterface
 }
 
 // factory 实现Factory接口
 type factory struct {
-	mysqlFactory mysql.Factory
-	userSrv      UserServiceInterface
-	reviewSrv    ReviewServiceInterface
-	borrowSrv    BorrowServiceInterface
-	bookSrv      BookServiceInterface
-	mu           sync.RWMutex
+	mysqlFactory   mysql.Factory
+	userSrv        UserServiceInterface
+	reviewSrv      ReviewServiceInterface
+	borrowSrv      BorrowServiceInterface
+	bookSrv        BookServiceInterface
+	reservationSrv ReservationServiceInterface
+	loanPolicySrv  LoanPolicyServiceInterface
+	overdueSrv     OverdueServiceInterface
+	fineSrv        FineServiceInterface
+	copySrv        CopyServiceInterface
+	permissionSrv  PermissionServiceInterface
+	mailSrv        MailServiceInterface
+	twoFactorSrv   TwoFactorServiceInterface
+	authSrv        AuthServiceInterface
+	ldapSrv        LDAPServiceInterface
+	apiKeySrv      APIKeyServiceInterface
+	auditSrv       AuditServiceInterface
+	bookImportSrv  BookImportServiceInterface
+	bookSearchSrv  BookSearchServiceInterface
+	mu             sync.RWMutex
 }
 
 // NewFactory 创建服务工厂实例（单例))
@@ -50,7 +82,12 @@ func (f *factory) GetUserService() UserServiceInterface {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	if f.userSrv == nil {
-		f.userSrv = NewUserService(f.mysqlFactory.GetUserRepository())
+		f.userSrv = NewUserService(
+			f.mysqlFactory.GetUserRepository(),
+			f.mysqlFactory.GetUnitOfWork(),
+			newPasswordHasher(),
+			newLoginGuard(),
+		)
 	}
 	return f.userSrv
 }
@@ -70,6 +107,7 @@ func (f *factory) GetReviewService() ReviewServiceInterface {
 			f.mysqlFactory.GetReviewRepository(),
 			f.mysqlFactory.GetBookRepository(),
 			f.mysqlFactory.GetUserRepository(),
+			f.mysqlFactory.GetUnitOfWork(),
 		)
 	}
 	return f.reviewSrv
@@ -86,12 +124,15 @@ func (f *factory) GetBorrowService() BorrowServiceInterface {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	if f.borrowSrv == nil {
-		f.borrowSrv = NewBorrowService(f.mysqlFactory.GetBorrowRepository(), f.mysqlFactory.GetBookRepository(), f.mysqlFactory.GetUserRepository())
+		f.borrowSrv = NewBorrowService(f.mysqlFactory.GetBorrowRepository(), f.mysqlFactory.GetBookRepository(), f.mysqlFactory.GetUserRepository(), f.mysqlFactory.GetReservationRepository(), f.mysqlFactory.GetLoanPolicyRepository(), f.mysqlFactory.GetFineRepository(), f.mysqlFactory.GetCopyRepository(), f.mysqlFactory.GetAuditLogRepository(), f.mysqlFactory.GetUnitOfWork())
 	}
 	return f.borrowSrv
 }
 
 func (f *factory) GetBookService() BookServiceInterface {
+	// 检索服务与图书服务共用工厂的锁，需在加锁前获取
+	searchSrv := f.GetBookSearchService()
+
 	f.mu.RLock()
 	if f.bookSrv != nil {
 		defer f.mu.RUnlock()
@@ -104,7 +145,334 @@ func (f *factory) GetBookService() BookServiceInterface {
 	if f.bookSrv == nil {
 		f.bookSrv = NewBookService(
 			f.mysqlFactory.GetBookRepository(),
+			f.mysqlFactory.GetCopyRepository(),
+			f.mysqlFactory.GetReservationRepository(),
+			searchSrv,
+			f.mysqlFactory.GetUnitOfWork(),
 		)
 	}
 	return f.bookSrv
 }
+
+func (f *factory) GetReservationService() ReservationServiceInterface {
+	f.mu.RLock()
+	if f.reservationSrv != nil {
+		defer f.mu.RUnlock()
+		return f.reservationSrv
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.reservationSrv == nil {
+		f.reservationSrv = NewReservationService(
+			f.mysqlFactory.GetReservationRepository(),
+			f.mysqlFactory.GetBorrowRepository(),
+			f.mysqlFactory.GetBookRepository(),
+			f.mysqlFactory.GetUserRepository(),
+			f.mysqlFactory.GetCopyRepository(),
+			f.mysqlFactory.GetAuditLogRepository(),
+			f.mysqlFactory.GetUnitOfWork(),
+		)
+	}
+	return f.reservationSrv
+}
+
+func (f *factory) GetLoanPolicyService() LoanPolicyServiceInterface {
+	f.mu.RLock()
+	if f.loanPolicySrv != nil {
+		defer f.mu.RUnlock()
+		return f.loanPolicySrv
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.loanPolicySrv == nil {
+		f.loanPolicySrv = NewLoanPolicyService(f.mysqlFactory.GetLoanPolicyRepository(), f.mysqlFactory.GetUnitOfWork())
+	}
+	return f.loanPolicySrv
+}
+
+func (f *factory) GetOverdueService() OverdueServiceInterface {
+	f.mu.RLock()
+	if f.overdueSrv != nil {
+		defer f.mu.RUnlock()
+		return f.overdueSrv
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.overdueSrv == nil {
+		f.overdueSrv = NewOverdueService(
+			f.mysqlFactory.GetBorrowRepository(),
+			f.mysqlFactory.GetLoanPolicyRepository(),
+			f.mysqlFactory.GetJobRunRepository(),
+		)
+	}
+	return f.overdueSrv
+}
+
+func (f *factory) GetFineService() FineServiceInterface {
+	f.mu.RLock()
+	if f.fineSrv != nil {
+		defer f.mu.RUnlock()
+		return f.fineSrv
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fineSrv == nil {
+		f.fineSrv = NewFineService(
+			f.mysqlFactory.GetFineRepository(),
+			f.mysqlFactory.GetUserRepository(),
+			f.mysqlFactory.GetAuditLogRepository(),
+			payment.MustNew(config.GlobalConfig.Payment.Provider),
+			f.mysqlFactory.GetUnitOfWork(),
+		)
+	}
+	return f.fineSrv
+}
+
+func (f *factory) GetCopyService() CopyServiceInterface {
+	f.mu.RLock()
+	if f.copySrv != nil {
+		defer f.mu.RUnlock()
+		return f.copySrv
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.copySrv == nil {
+		f.copySrv = NewCopyService(
+			f.mysqlFactory.GetCopyRepository(),
+			f.mysqlFactory.GetBookRepository(),
+			f.mysqlFactory.GetBorrowRepository(),
+			f.mysqlFactory.GetReservationRepository(),
+			f.mysqlFactory.GetAuditLogRepository(),
+			f.mysqlFactory.GetUnitOfWork(),
+		)
+	}
+	return f.copySrv
+}
+
+func (f *factory) GetPermissionService() PermissionServiceInterface {
+	f.mu.RLock()
+	if f.permissionSrv != nil {
+		defer f.mu.RUnlock()
+		return f.permissionSrv
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.permissionSrv == nil {
+		f.permissionSrv = NewPermissionService(f.mysqlFactory.GetRolePermissionRepository(), f.mysqlFactory.GetUnitOfWork())
+	}
+	return f.permissionSrv
+}
+
+func (f *factory) GetMailService() MailServiceInterface {
+	f.mu.RLock()
+	if f.mailSrv != nil {
+		defer f.mu.RUnlock()
+		return f.mailSrv
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.mailSrv == nil {
+		cfg := config.GlobalConfig.Mail
+		f.mailSrv = NewMailService(
+			f.mysqlFactory.GetMailOutboxRepository(),
+			mailer.MustNew(mailer.Options{
+				Driver:   cfg.Driver,
+				Host:     cfg.Host,
+				Port:     cfg.Port,
+				Username: cfg.Username,
+				Password: cfg.Password,
+				From:     cfg.From,
+				Dir:      cfg.Dir,
+			}),
+			f.mysqlFactory.GetUnitOfWork(),
+		)
+	}
+	return f.mailSrv
+}
+
+func (f *factory) GetTwoFactorService() TwoFactorServiceInterface {
+	f.mu.RLock()
+	if f.twoFactorSrv != nil {
+		defer f.mu.RUnlock()
+		return f.twoFactorSrv
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.twoFactorSrv == nil {
+		f.twoFactorSrv = NewTwoFactorService(
+			f.mysqlFactory.GetUserRepository(),
+			f.mysqlFactory.GetUnitOfWork(),
+			newLoginGuard(),
+		)
+	}
+	return f.twoFactorSrv
+}
+
+func (f *factory) GetAuthService() AuthServiceInterface {
+	// 提供方由其他服务实现，需在加锁前获取
+	providers := []AuthProvider{NewLocalProvider(f.GetUserService())}
+	if config.GlobalConfig.LDAP.Enabled {
+		providers = append(providers, f.GetLDAPService())
+	}
+
+	f.mu.RLock()
+	if f.authSrv != nil {
+		defer f.mu.RUnlock()
+		return f.authSrv
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.authSrv == nil {
+		for _, cfg := range config.GlobalConfig.SSO.OIDC {
+			providers = append(providers, NewOIDCProvider(cfg, nil))
+		}
+		f.authSrv = NewAuthService(f.mysqlFactory.GetUnitOfWork(), newPasswordHasher(), providers...)
+	}
+	return f.authSrv
+}
+
+func (f *factory) GetLDAPService() LDAPServiceInterface {
+	f.mu.RLock()
+	if f.ldapSrv != nil {
+		defer f.mu.RUnlock()
+		return f.ldapSrv
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.ldapSrv == nil {
+		f.ldapSrv = NewLDAPService(
+			config.GlobalConfig.LDAP,
+			nil,
+			f.mysqlFactory.GetUnitOfWork(),
+			f.mysqlFactory.GetJobRunRepository(),
+			newPasswordHasher(),
+			newLoginGuard(),
+		)
+	}
+	return f.ldapSrv
+}
+
+func (f *factory) GetAPIKeyService() APIKeyServiceInterface {
+	// 权限服务有自己的锁，需在加锁前获取
+	permissionSrv := f.GetPermissionService()
+
+	f.mu.RLock()
+	if f.apiKeySrv != nil {
+		defer f.mu.RUnlock()
+		return f.apiKeySrv
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.apiKeySrv == nil {
+		f.apiKeySrv = NewAPIKeyService(
+			f.mysqlFactory.GetAPIKeyRepository(),
+			f.mysqlFactory.GetUserRepository(),
+			f.mysqlFactory.GetUnitOfWork(),
+			permissionSrv,
+		)
+	}
+	return f.apiKeySrv
+}
+
+func (f *factory) GetAuditService() AuditServiceInterface {
+	f.mu.RLock()
+	if f.auditSrv != nil {
+		defer f.mu.RUnlock()
+		return f.auditSrv
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.auditSrv == nil {
+		f.auditSrv = NewAuditService(f.mysqlFactory.GetAuditLogRepository())
+	}
+	return f.auditSrv
+}
+
+func (f *factory) GetBookImportService() BookImportServiceInterface {
+	// 图书服务有自己的锁，需在加锁前获取
+	bookSrv := f.GetBookService()
+
+	f.mu.RLock()
+	if f.bookImportSrv != nil {
+		defer f.mu.RUnlock()
+		return f.bookImportSrv
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.bookImportSrv == nil {
+		f.bookImportSrv = NewBookImportService(
+			f.mysqlFactory.GetImportJobRepository(),
+			f.mysqlFactory.GetBookRepository(),
+			bookSrv,
+			f.mysqlFactory.GetUnitOfWork(),
+		)
+	}
+	return f.bookImportSrv
+}
+
+func (f *factory) GetBookSearchService() BookSearchServiceInterface {
+	f.mu.RLock()
+	if f.bookSearchSrv != nil {
+		defer f.mu.RUnlock()
+		return f.bookSearchSrv
+	}
+	f.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.bookSearchSrv == nil {
+		f.bookSearchSrv = NewBookSearchService(
+			mustNewSearchEngine(config.GlobalConfig.Search.Engine, f.mysqlFactory),
+			f.mysqlFactory.GetBookRepository(),
+		)
+	}
+	return f.bookSearchSrv
+}
+
+// newPasswordHasher 按配置创建密码哈希器
+func newPasswordHasher() password.Hasher {
+	return password.MustNew(password.Options{
+		Algorithm:   config.GlobalConfig.Password.Algorithm,
+		Memory:      config.GlobalConfig.Password.Memory,
+		Iterations:  config.GlobalConfig.Password.Iterations,
+		Parallelism: config.GlobalConfig.Password.Parallelism,
+		BcryptCost:  config.GlobalConfig.Password.BcryptCost,
+	})
+}
+
+// newLoginGuard 按配置创建登录保护，状态保存在令牌存储中，多个服务可以各自持有实例
+func newLoginGuard() *LoginGuard {
+	return NewLoginGuard(LoginGuardOptions{
+		MaxFailures:   config.GlobalConfig.Login.MaxFailures,
+		Window:        config.GlobalConfig.Login.WindowDuration(),
+		LockBase:      config.GlobalConfig.Login.LockBaseDuration(),
+		LockMax:       config.GlobalConfig.Login.LockMaxDuration(),
+		IPMaxFailures: config.GlobalConfig.Login.IPMaxFailures,
+	})
+}
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"library/model"
+	"library/payment"
+	"library/repository/mysql"
+)
+
+// 罚金流水类型
+const (
+	FineTypeCharge = 1 // 罚款
+	FineTypePay    = 2 // 缴费
+	FineTypeWaive  = 3 // 减免
+	FineTypeRefund = 4 // 退款
+)
+
+// FineServiceInterface 罚金服务接口
+type FineServiceInterface interface {
+	Charge(userID uint, borrowID *uint, amount float64, reason string, actor Actor) (*model.FineTransaction, error)
+	Pay(userID uint, amount float64, idempotencyKey string, actor Actor) (*model.FineTransaction, error)
+	Waive(userID uint, amount float64, reason string, actor Actor) (*model.FineTransaction, error)
+	Refund(paymentID uint, amount float64, reason string, actor Actor) (*model.FineTransaction, error)
+	GetBalance(userID uint) (float64, error)
+	GetUserTransactions(userID uint, params *model.SearchParams) ([]*model.FineTransaction, int64, error)
+	Report(params *model.SearchParams) ([]*model.FineSummary, int64, error)
+}
+
+type FineService struct {
+	fineRepo     mysql.FineRepository
+	userRepo     mysql.UserRepository
+	auditLogRepo mysql.AuditLogRepository
+	provider     payment.Provider
+	uow          mysql.UnitOfWork
+}
+
+func NewFineService(fineRepo mysql.FineRepository, userRepo mysql.UserRepository, auditLogRepo mysql.AuditLogRepository, provider payment.Provider, uow mysql.UnitOfWork) FineServiceInterface {
+	return &FineService{
+		fineRepo:     fineRepo,
+		userRepo:     userRepo,
+		auditLogRepo: auditLogRepo,
+		provider:     provider,
+		uow:          uow,
+	}
+}
+
+// withRepos 返回使用工作单元仓库的服务副本
+func (s *FineService) withRepos(repos *mysql.Repositories) *FineService {
+	return &FineService{
+		fineRepo:     repos.Fine,
+		userRepo:     repos.User,
+		auditLogRepo: repos.AuditLog,
+		provider:     s.provider,
+		uow:          s.uow,
+	}
+}
+
+// withUserLock 在事务中锁定用户行后执行fn，同一用户的缴费、减免和退款串行执行，
+// 避免并发请求都通过余额校验
+func (s *FineService) withUserLock(userID uint, fn func(txs *FineService) error) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		txs := s.withRepos(repos)
+		user, err := txs.userRepo.LockByID(userID)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return ErrNotFound
+		}
+		return fn(txs)
+	})
+}
+
+// roundAmount 金额保留两位小数
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// checkUser 检查用户是否存在
+func (s *FineService) checkUser(userID uint) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Charge 记一笔罚款
+func (s *FineService) Charge(userID uint, borrowID *uint, amount float64, reason string, actor Actor) (*model.FineTransaction, error) {
+	amount = roundAmount(amount)
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if err := s.checkUser(userID); err != nil {
+		return nil, err
+	}
+
+	tx := &model.FineTransaction{
+		UserID:     userID,
+		BorrowID:   borrowID,
+		Type:       FineTypeCharge,
+		Amount:     amount,
+		Reason:     reason,
+		OperatorID: actor.ID,
+	}
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		if err := repos.Fine.Create(tx); err != nil {
+			return fmt.Errorf("create charge: %w", err)
+		}
+		return writeAuditChange(repos.AuditLog, actor, AuditFineTransaction, AuditTargetFine, tx.ID, nil, tx, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// maxIdempotencyKeyLen 缴费幂等键的最大长度
+const maxIdempotencyKeyLen = 64
+
+// Pay 缴纳罚金，支持部分缴费，金额不能超过未缴余额。
+// 客户端重试时传入相同的幂等键，已记账的缴费直接返回原流水，支付渠道也不会重复扣款；
+// 未传幂等键时每次调用视为一笔新的缴费
+func (s *FineService) Pay(userID uint, amount float64, idempotencyKey string, actor Actor) (*model.FineTransaction, error) {
+	amount = roundAmount(amount)
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if len(idempotencyKey) > maxIdempotencyKeyLen {
+		return nil, fmt.Errorf("%w: idempotency key too long", ErrInvalidParameter)
+	}
+	if idempotencyKey == "" {
+		key, err := randomToken()
+		if err != nil {
+			return nil, err
+		}
+		idempotencyKey = key
+	}
+
+	var tx *model.FineTransaction
+	var charged *payment.Result
+	err := s.withUserLock(userID, func(txs *FineService) error {
+		var err error
+		tx, charged, err = txs.payTx(userID, amount, idempotencyKey, actor)
+		return err
+	})
+	if err != nil {
+		// 已扣款但流水未能写入（记账、审计或提交失败）时原路退回，避免读者被扣款而账上没有记录
+		if charged != nil {
+			s.reverseCharge(userID, amount, charged, err)
+		}
+		return nil, err
+	}
+	return tx, nil
+}
+
+// payTx 在已锁定用户行的事务中校验余额、扣款并记账，返回支付渠道的扣款结果供记账失败时退款。
+// 幂等键已有对应的缴费时直接返回该流水，不再扣款
+func (s *FineService) payTx(userID uint, amount float64, idempotencyKey string, actor Actor) (*model.FineTransaction, *payment.Result, error) {
+	paid, err := s.fineRepo.GetByIdempotencyKey(userID, idempotencyKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if paid != nil {
+		if paid.Amount != amount {
+			return nil, nil, fmt.Errorf("%w: idempotency key reused with a different amount", ErrInvalidParameter)
+		}
+		return paid, nil, nil
+	}
+
+	balance, err := s.fineRepo.GetBalance(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if amount > roundAmount(balance) {
+		return nil, nil, ErrAmountExceedsBalance
+	}
+
+	result, err := s.provider.Charge(&payment.ChargeRequest{
+		UserID:         userID,
+		Amount:         amount,
+		Description:    "library fine",
+		IdempotencyKey: fmt.Sprintf("fine-pay-%d-%s", userID, idempotencyKey),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrPaymentFailed, err)
+	}
+
+	tx := &model.FineTransaction{
+		UserID:         userID,
+		Type:           FineTypePay,
+		Amount:         amount,
+		OperatorID:     actor.ID,
+		Provider:       s.provider.Name(),
+		ProviderRef:    result.Reference,
+		IdempotencyKey: idempotencyKey,
+	}
+	if err := s.fineRepo.Create(tx); err != nil {
+		return nil, result, fmt.Errorf("create payment: %w", err)
+	}
+	if err := writeAuditChange(s.auditLogRepo, actor, AuditFineTransaction, AuditTargetFine, tx.ID, nil, tx, nil); err != nil {
+		return nil, result, err
+	}
+	return tx, result, nil
+}
+
+// reverseCharge 退回未能记账的扣款。退款失败只能记录日志，由人工按支付渠道流水号核对
+func (s *FineService) reverseCharge(userID uint, amount float64, charged *payment.Result, cause error) {
+	_, err := s.provider.Refund(&payment.RefundRequest{
+		Reference: charged.Reference,
+		Amount:    amount,
+		Reason:    "payment not recorded",
+	})
+	if err != nil {
+		log.Printf("fine: refund unrecorded payment %s (user %d, amount %.2f) failed: %v, cause: %v",
+			charged.Reference, userID, amount, err, cause)
+		return
+	}
+	log.Printf("fine: refunded unrecorded payment %s (user %d, amount %.2f), cause: %v",
+		charged.Reference, userID, amount, cause)
+}
+
+// Waive 管理员减免罚金，必须填写原因，金额不能超过未缴余额
+func (s *FineService) Waive(userID uint, amount float64, reason string, actor Actor) (*model.FineTransaction, error) {
+	amount = roundAmount(amount)
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if reason == "" {
+		return nil, ErrInvalidParameter
+	}
+
+	var tx *model.FineTransaction
+	err := s.withUserLock(userID, func(txs *FineService) error {
+		var err error
+		tx, err = txs.waiveTx(userID, amount, reason, actor)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// waiveTx 在已锁定用户行的事务中校验余额并记录减免
+func (s *FineService) waiveTx(userID uint, amount float64, reason string, actor Actor) (*model.FineTransaction, error) {
+	balance, err := s.fineRepo.GetBalance(userID)
+	if err != nil {
+		return nil, err
+	}
+	if amount > roundAmount(balance) {
+		return nil, ErrAmountExceedsBalance
+	}
+
+	tx := &model.FineTransaction{
+		UserID:     userID,
+		Type:       FineTypeWaive,
+		Amount:     amount,
+		Reason:     reason,
+		OperatorID: actor.ID,
+	}
+	if err := s.fineRepo.Create(tx); err != nil {
+		return nil, fmt.Errorf("create waiver: %w", err)
+	}
+	if err := writeAuditChange(s.auditLogRepo, actor, AuditFineTransaction, AuditTargetFine, tx.ID, nil, tx, nil); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Refund 退还一笔缴费，可分多次退款，累计金额不能超过原缴费金额
+func (s *FineService) Refund(paymentID uint, amount float64, reason string, actor Actor) (*model.FineTransaction, error) {
+	amount = roundAmount(amount)
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	paid, err := s.fineRepo.GetByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if paid == nil {
+		return nil, ErrNotFound
+	}
+	if paid.Type != FineTypePay {
+		return nil, ErrInvalidStatus
+	}
+
+	var tx *model.FineTransaction
+	err = s.withUserLock(paid.UserID, func(txs *FineService) error {
+		var err error
+		tx, err = txs.refundTx(paid, amount, reason, actor)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// refundTx 在已锁定用户行的事务中校验可退金额、原路退款并记账
+func (s *FineService) refundTx(paid *model.FineTransaction, amount float64, reason string, actor Actor) (*model.FineTransaction, error) {
+	refunded, err := s.fineRepo.SumRefunded(paid.ID)
+	if err != nil {
+		return nil, err
+	}
+	if amount > roundAmount(paid.Amount-refunded) {
+		return nil, ErrAmountExceedsBalance
+	}
+
+	result, err := s.provider.Refund(&payment.RefundRequest{
+		Reference: paid.ProviderRef,
+		Amount:    amount,
+		Reason:    reason,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPaymentFailed, err)
+	}
+
+	tx := &model.FineTransaction{
+		UserID:      paid.UserID,
+		RefID:       &paid.ID,
+		Type:        FineTypeRefund,
+		Amount:      amount,
+		Reason:      reason,
+		OperatorID:  actor.ID,
+		Provider:    s.provider.Name(),
+		ProviderRef: result.Reference,
+	}
+	if err := s.fineRepo.Create(tx); err != nil {
+		return nil, fmt.Errorf("create refund: %w", err)
+	}
+	if err := writeAuditChange(s.auditLogRepo, actor, AuditFineTransaction, AuditTargetFine, tx.ID, nil, tx, nil); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// GetBalance 获取用户未缴罚金余额
+func (s *FineService) GetBalance(userID uint) (float64, error) {
+	balance, err := s.fineRepo.GetBalance(userID)
+	if err != nil {
+		return 0, err
+	}
+	return roundAmount(balance), nil
+}
+
+// GetUserTransactions 获取用户的罚金流水
+func (s *FineService) GetUserTransactions(userID uint, params *model.SearchParams) ([]*model.FineTransaction, int64, error) {
+	return s.fineRepo.GetUserTransactions(userID, params)
+}
+
+// Report 按用户汇总罚金
+func (s *FineService) Report(params *model.SearchParams) ([]*model.FineSummary, int64, error) {
+	return s.fineRepo.Report(params)
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"library/model"
+	"library/payment"
+	"library/repository/mysql"
+)
+
+// recordingProvider 记录收款和退款请求的测试支付渠道
+type recordingProvider struct {
+	*payment.FakeProvider
+	charges []*payment.ChargeRequest
+	refunds []*payment.RefundRequest
+}
+
+func (p *recordingProvider) Charge(req *payment.ChargeRequest) (*payment.Result, error) {
+	p.charges = append(p.charges, req)
+	return p.FakeProvider.Charge(req)
+}
+
+func (p *recordingProvider) Refund(req *payment.RefundRequest) (*payment.Result, error) {
+	p.refunds = append(p.refunds, req)
+	return p.FakeProvider.Refund(req)
+}
+
+func newTestFineService(db *gorm.DB, provider payment.Provider) FineServiceInterface {
+	return NewFineService(mysql.NewFineRepository(db), mysql.NewUserRepository(db), mysql.NewAuditLogRepository(db), provider, mysql.NewUnitOfWork(db))
+}
+
+func assertBalance(t *testing.T, svc FineServiceInterface, userID uint, want float64) {
+	t.Helper()
+	got, err := svc.GetBalance(userID)
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if got != want {
+		t.Fatalf("balance = %.2f, want %.2f", got, want)
+	}
+}
+
+func TestFineBalance(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db, "reader")
+	svc := newTestFineService(db, payment.NewFakeProvider())
+	admin := Actor{ID: 1}
+
+	if _, err := svc.Charge(user.ID, nil, 10, "overdue", SystemActor); err != nil {
+		t.Fatalf("Charge: %v", err)
+	}
+	assertBalance(t, svc, user.ID, 10)
+
+	paid, err := svc.Pay(user.ID, 4.005, "", Actor{ID: user.ID})
+	if err != nil {
+		t.Fatalf("Pay: %v", err)
+	}
+	if paid.Amount != 4.01 || paid.Type != FineTypePay || paid.ProviderRef == "" {
+		t.Fatalf("payment = %+v, want amount 4.01 with provider reference", paid)
+	}
+	assertBalance(t, svc, user.ID, 5.99)
+
+	if _, err := svc.Waive(user.ID, 1.99, "damaged by flood", admin); err != nil {
+		t.Fatalf("Waive: %v", err)
+	}
+	assertBalance(t, svc, user.ID, 4)
+
+	refund, err := svc.Refund(paid.ID, 2, "duplicate payment", admin)
+	if err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if refund.RefID == nil || *refund.RefID != paid.ID {
+		t.Fatalf("refund.RefID = %v, want %d", refund.RefID, paid.ID)
+	}
+	assertBalance(t, svc, user.ID, 6)
+
+	// 累计退款不能超过原缴费金额
+	if _, err := svc.Refund(paid.ID, 2.02, "too much", admin); !errors.Is(err, ErrAmountExceedsBalance) {
+		t.Fatalf("over-refund err = %v, want ErrAmountExceedsBalance", err)
+	}
+	if _, err := svc.Refund(paid.ID, 2.01, "rest", admin); err != nil {
+		t.Fatalf("Refund rest: %v", err)
+	}
+	assertBalance(t, svc, user.ID, 8.01)
+
+	_, total, err := svc.GetUserTransactions(user.ID, &model.SearchParams{Pagination: model.Pagination{Page: 1, PageSize: 10}})
+	if err != nil {
+		t.Fatalf("GetUserTransactions: %v", err)
+	}
+	if total != 5 {
+		t.Fatalf("transactions = %d, want 5", total)
+	}
+}
+
+func TestFineRejectsInvalidAmounts(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db, "reader")
+	provider := &recordingProvider{FakeProvider: payment.NewFakeProvider()}
+	svc := newTestFineService(db, provider)
+
+	if _, err := svc.Charge(user.ID, nil, 10, "overdue", SystemActor); err != nil {
+		t.Fatalf("Charge: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"pay zero", func() error { _, err := svc.Pay(user.ID, 0, "", SystemActor); return err }, ErrInvalidAmount},
+		{"pay rounds to zero", func() error { _, err := svc.Pay(user.ID, 0.004, "", SystemActor); return err }, ErrInvalidAmount},
+		{"pay over balance", func() error { _, err := svc.Pay(user.ID, 10.01, "", SystemActor); return err }, ErrAmountExceedsBalance},
+		{"waive over balance", func() error { _, err := svc.Waive(user.ID, 10.01, "reason", SystemActor); return err }, ErrAmountExceedsBalance},
+		{"waive without reason", func() error { _, err := svc.Waive(user.ID, 1, "", SystemActor); return err }, ErrInvalidParameter},
+		{"charge negative", func() error { _, err := svc.Charge(user.ID, nil, -1, "x", SystemActor); return err }, ErrInvalidAmount},
+		{"pay unknown user", func() error { _, err := svc.Pay(user.ID+100, 1, "", SystemActor); return err }, ErrNotFound},
+		{"refund unknown payment", func() error { _, err := svc.Refund(999, 1, "x", SystemActor); return err }, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// 被拒绝的缴费不能扣款
+	if len(provider.charges) != 0 {
+		t.Fatalf("provider charged %d times, want 0", len(provider.charges))
+	}
+	assertBalance(t, svc, user.ID, 10)
+}
+
+func TestFinePayIdempotent(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db, "reader")
+	provider := &recordingProvider{FakeProvider: payment.NewFakeProvider()}
+	svc := newTestFineService(db, provider)
+
+	if _, err := svc.Charge(user.ID, nil, 10, "overdue", SystemActor); err != nil {
+		t.Fatalf("Charge: %v", err)
+	}
+
+	first, err := svc.Pay(user.ID, 3, "retry-key", SystemActor)
+	if err != nil {
+		t.Fatalf("Pay: %v", err)
+	}
+	again, err := svc.Pay(user.ID, 3, "retry-key", SystemActor)
+	if err != nil {
+		t.Fatalf("Pay retry: %v", err)
+	}
+	if again.ID != first.ID {
+		t.Fatalf("retry created transaction %d, want original %d", again.ID, first.ID)
+	}
+	if len(provider.charges) != 1 {
+		t.Fatalf("provider charged %d times, want 1", len(provider.charges))
+	}
+	if provider.charges[0].IdempotencyKey == "" {
+		t.Fatal("charge request has no idempotency key")
+	}
+	assertBalance(t, svc, user.ID, 7)
+
+	if _, err := svc.Pay(user.ID, 4, "retry-key", SystemActor); !errors.Is(err, ErrInvalidParameter) {
+		t.Fatalf("reused key with different amount err = %v, want ErrInvalidParameter", err)
+	}
+
+	// 未传幂等键时每次都是新的缴费
+	if _, err := svc.Pay(user.ID, 1, "", SystemActor); err != nil {
+		t.Fatalf("Pay: %v", err)
+	}
+	if _, err := svc.Pay(user.ID, 1, "", SystemActor); err != nil {
+		t.Fatalf("Pay: %v", err)
+	}
+	if len(provider.charges) != 3 {
+		t.Fatalf("provider charged %d times, want 3", len(provider.charges))
+	}
+	assertBalance(t, svc, user.ID, 5)
+}
+
+func TestFinePayRefundsUnrecordedCharge(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db, "reader")
+	provider := &recordingProvider{FakeProvider: payment.NewFakeProvider()}
+	svc := newTestFineService(db, provider)
+
+	if _, err := svc.Charge(user.ID, nil, 10, "overdue", SystemActor); err != nil {
+		t.Fatalf("Charge: %v", err)
+	}
+
+	// 扣款成功后写入缴费流水失败
+	errDisk := errors.New("disk full")
+	err := db.Callback().Create().Before("gorm:create").Register("test:fail_payment", func(tx *gorm.DB) {
+		if ft, ok := tx.Statement.Dest.(*model.FineTransaction); ok && ft.Type == FineTypePay {
+			tx.AddError(errDisk)
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	if _, err := svc.Pay(user.ID, 6, "", SystemActor); !errors.Is(err, errDisk) {
+		t.Fatalf("Pay err = %v, want %v", err, errDisk)
+	}
+	if len(provider.charges) != 1 || len(provider.refunds) != 1 {
+		t.Fatalf("charges = %d, refunds = %d, want 1 and 1", len(provider.charges), len(provider.refunds))
+	}
+	if refund := provider.refunds[0]; refund.Amount != 6 {
+		t.Fatalf("refund amount = %.2f, want 6", refund.Amount)
+	}
+	assertBalance(t, svc, user.ID, 10)
+}
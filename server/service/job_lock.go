@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"library/database"
+)
+
+// jobLockTTL 定时任务锁的过期时间，防止实例崩溃后锁无法释放
+const jobLockTTL = 10 * time.Minute
+
+// jobInstance 当前实例的标识，作为分布式锁的持有者
+var jobInstance = func() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}()
+
+// RunExclusive 持有任务的分布式锁执行fn，多实例部署时同一任务同一时间只在一个实例执行。
+// 锁已被其他实例持有时不执行，返回 ErrJobLocked
+func RunExclusive(job string, fn func() error) error {
+	release, ok, err := database.AcquireLock(context.Background(), "lock:job:"+job, jobInstance, jobLockTTL)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrJobLocked
+	}
+	defer release()
+	return fn()
+}
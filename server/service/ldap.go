@@ -0,0 +1,586 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"library/config"
+	"library/database"
+	"library/model"
+	"library/password"
+	"library/repository/mysql"
+)
+
+// JobLDAPSync LDAP目录同步任务名称
+const JobLDAPSync = "ldap_sync"
+
+const (
+	ldapSyncLockTTL = 30 * time.Minute // 目录同步锁的过期时间
+	ldapPageSize    = 500              // 分页查询目录时每页的条数
+)
+
+// LDAPEntry 目录中的用户
+type LDAPEntry struct {
+	DN       string   // 用户的DN
+	Username string   // 用户名属性
+	Nickname string   // 昵称属性
+	Email    string   // 邮箱属性
+	Phone    string   // 电话属性
+	Groups   []string // 所属组的DN
+}
+
+// LDAPDirectory 访问LDAP目录，测试时可替换为进程内的实现
+type LDAPDirectory interface {
+	// Authenticate 查找用户并用其密码绑定。用户不存在时返回 nil, nil，密码错误时返回 ErrPasswordIncorrect
+	Authenticate(username, password string) (*LDAPEntry, error)
+	// ListUsers 列出过滤器匹配的全部用户
+	ListUsers() ([]*LDAPEntry, error)
+}
+
+// LDAPServiceInterface LDAP认证和目录同步服务
+type LDAPServiceInterface interface {
+	PasswordProvider
+	SyncDirectory() (*model.JobRun, error)
+	GetLastSync() (*model.JobRun, error)
+}
+
+type ldapService struct {
+	cfg        config.LDAPConfig
+	dir        LDAPDirectory
+	uow        mysql.UnitOfWork
+	jobRunRepo mysql.JobRunRepository
+	hasher     password.Hasher
+	guard      *LoginGuard
+	instance   string
+}
+
+// NewLDAPService 创建LDAP服务，dir为nil时按配置连接目录服务器
+func NewLDAPService(cfg config.LDAPConfig, dir LDAPDirectory, uow mysql.UnitOfWork, jobRunRepo mysql.JobRunRepository, hasher password.Hasher, guard *LoginGuard) LDAPServiceInterface {
+	if dir == nil {
+		dir = NewLDAPClient(cfg)
+	}
+	hostname, _ := os.Hostname()
+	return &ldapService{
+		cfg:        cfg,
+		dir:        dir,
+		uow:        uow,
+		jobRunRepo: jobRunRepo,
+		hasher:     hasher,
+		guard:      guard,
+		instance:   fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+func (s *ldapService) Name() string        { return s.cfg.Name }
+func (s *ldapService) DisplayName() string { return s.cfg.DisplayName }
+func (s *ldapService) Kind() string        { return ProviderKindPassword }
+
+// Authenticate 用目录中的账号登录。首次登录时创建本地账号，之后每次登录按目录更新昵称、邮箱、电话和角色。
+// 与本地账号共用失败计数和账号锁定
+func (s *ldapService) Authenticate(username, password, ip string) (*model.User, error) {
+	if err := s.guard.Check(username, ip); err != nil {
+		return nil, err
+	}
+
+	entry, err := s.dir.Authenticate(username, password)
+	switch {
+	case errors.Is(err, ErrPasswordIncorrect):
+		return nil, s.loginFailed(username, ip, LoginFailBadPassword, err)
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	case entry == nil:
+		return nil, s.loginFailed(username, ip, LoginFailUnknownUser, ErrNotFound)
+	}
+
+	var user *model.User
+	err = s.uow.Do(func(repos *mysql.Repositories) error {
+		var err error
+		user, _, err = s.syncUser(repos, entry, true)
+		if err != nil {
+			return err
+		}
+		if user.Status != 1 {
+			return ErrAccountDisabled
+		}
+		user.LastLoginAt = time.Now()
+		if err := repos.User.Update(user); err != nil {
+			return fmt.Errorf("update last login time: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 已启用双因素认证的账号在提交验证码后才算登录成功
+	if twoFactorRequirement(user) != TwoFactorVerify {
+		if err := s.guard.Succeed(username); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// loginFailed 记录失败的登录，本次失败触发锁定时返回锁定错误，否则返回原错误
+func (s *ldapService) loginFailed(username, ip, reason string, cause error) error {
+	if err := s.guard.Fail(username, ip, reason); err != nil {
+		return err
+	}
+	return cause
+}
+
+// SyncDirectory 按目录更新已关联的本地账号，禁用已从目录中删除的账号并注销其会话。
+// 多实例部署时通过Redis锁保证同一时间只有一个实例执行
+func (s *ldapService) SyncDirectory() (*model.JobRun, error) {
+	release, ok, err := database.AcquireLock(context.Background(), "lock:job:"+JobLDAPSync, s.instance, ldapSyncLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrJobLocked
+	}
+	defer release()
+
+	run := &model.JobRun{
+		Name:      JobLDAPSync,
+		Instance:  s.instance,
+		StartedAt: time.Now(),
+		Status:    1, // 执行中
+	}
+	if err := s.jobRunRepo.Create(run); err != nil {
+		return nil, fmt.Errorf("create job run: %w", err)
+	}
+
+	syncErr := s.sync(run)
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Status = 2 // 成功
+	if syncErr != nil {
+		run.Status = 3 // 失败
+		run.Error = truncate(syncErr.Error(), 512)
+	}
+	if err := s.jobRunRepo.Update(run); err != nil {
+		return run, fmt.Errorf("update job run: %w", err)
+	}
+	return run, syncErr
+}
+
+// sync 执行目录同步，统计结果写入run：scanned-检查的账号数 affected-禁用数 updated-资料或角色更新数
+func (s *ldapService) sync(run *model.JobRun) error {
+	entries, err := s.dir.ListUsers()
+	if err != nil {
+		return fmt.Errorf("list directory users: %w", err)
+	}
+	// 目录返回空结果多半是配置错误或目录故障，此时不禁用任何账号
+	if len(entries) == 0 {
+		return errors.New("directory returned no users")
+	}
+	byName := make(map[string]*LDAPEntry, len(entries))
+	for _, e := range entries {
+		byName[strings.ToLower(e.Username)] = e
+	}
+
+	var identities []*model.ExternalIdentity
+	if err := s.uow.Do(func(repos *mysql.Repositories) error {
+		var err error
+		identities, err = repos.Identity.ListByProvider(s.cfg.Name)
+		return err
+	}); err != nil {
+		return fmt.Errorf("list ldap identities: %w", err)
+	}
+
+	for _, identity := range identities {
+		run.Scanned++
+
+		if entry, ok := byName[identity.Subject]; ok {
+			var changed bool
+			err := s.uow.Do(func(repos *mysql.Repositories) error {
+				var err error
+				_, changed, err = s.syncUser(repos, entry, false)
+				return err
+			})
+			// 本地账号已删除
+			if errors.Is(err, ErrAccountDisabled) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("sync user %d: %w", identity.UserID, err)
+			}
+			if changed {
+				run.Updated++
+			}
+			continue
+		}
+
+		disabled, err := s.disableRemoved(identity)
+		if errors.Is(err, ErrLastAdmin) {
+			log.Printf("ldap sync: user %d removed from directory but is the last active admin, not disabled", identity.UserID)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("disable user %d: %w", identity.UserID, err)
+		}
+		if disabled {
+			run.Affected++
+		}
+	}
+	return nil
+}
+
+// disableRemoved 禁用已从目录中删除的账号并注销其会话，账号已禁用或已删除时返回false
+func (s *ldapService) disableRemoved(identity *model.ExternalIdentity) (bool, error) {
+	var disabled bool
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		user, err := lockUserForAdminChange(repos.User, identity.UserID)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if user.Status != 1 {
+			return nil
+		}
+		if err := ensureNotLastAdmin(repos.User, user); err != nil {
+			return err
+		}
+
+		user.Status = 2
+		if err := repos.User.Update(user); err != nil {
+			return fmt.Errorf("update user status: %w", err)
+		}
+		disabled = true
+		return writeAuditChange(repos.AuditLog, SystemActor, AuditUserStatus, AuditTargetUser, user.ID,
+			map[string]int{"status": 1}, map[string]int{"status": 2},
+			map[string]string{"source": s.cfg.Name, "reason": "removed from directory"})
+	})
+	if err != nil || !disabled {
+		return false, err
+	}
+	if err := database.RevokeUserTokens(context.Background(), identity.UserID); err != nil {
+		return true, fmt.Errorf("revoke sessions: %w", err)
+	}
+	return true, nil
+}
+
+// syncUser 按目录条目更新关联的本地账号，create为true时为尚未关联的条目创建账号。
+// 返回本地账号以及资料或角色是否有变化；条目未关联且不创建时返回 nil
+func (s *ldapService) syncUser(repos *mysql.Repositories, entry *LDAPEntry, create bool) (*model.User, bool, error) {
+	subject := strings.ToLower(entry.Username)
+	identity, err := repos.Identity.GetBySubject(s.cfg.Name, subject)
+	if err != nil {
+		return nil, false, fmt.Errorf("get external identity: %w", err)
+	}
+
+	if identity == nil {
+		if !create {
+			return nil, false, nil
+		}
+		user, err := provisionUser(repos.User, s.hasher, &ExternalUser{
+			Subject:       subject,
+			Email:         entry.Email,
+			EmailVerified: entry.Email != "", // 目录由机构维护，视为已验证
+			Username:      entry.Username,
+			Nickname:      entry.Nickname,
+			Phone:         truncate(entry.Phone, 20),
+		}, s.mapRole(entry.Groups, s.cfg.DefaultRole))
+		if err != nil {
+			return nil, false, err
+		}
+		if err := repos.Identity.Create(&model.ExternalIdentity{
+			UserID:   user.ID,
+			Provider: s.cfg.Name,
+			Subject:  subject,
+			Email:    entry.Email,
+		}); err != nil {
+			return nil, false, fmt.Errorf("create external identity: %w", err)
+		}
+		return user, true, nil
+	}
+
+	// 管理角色时可能降级管理员，与管理员修改角色相同，先锁定全部管理员再锁定该用户
+	var user *model.User
+	if len(s.cfg.GroupRoles) > 0 {
+		user, err = lockUserForAdminChange(repos.User, identity.UserID)
+	} else {
+		user, err = repos.User.LockByID(identity.UserID)
+		if err == nil && user == nil {
+			err = ErrNotFound
+		}
+	}
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, ErrAccountDisabled
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("lock user: %w", err)
+	}
+
+	changed, err := s.applyProfile(repos, user, entry)
+	if err != nil {
+		return nil, false, err
+	}
+	roleChanged, err := s.applyRole(repos, user, entry)
+	if err != nil {
+		return nil, false, err
+	}
+	if changed {
+		if err := repos.User.Update(user); err != nil {
+			return nil, false, fmt.Errorf("update user: %w", err)
+		}
+	}
+	if err := repos.Identity.UpdateLogin(identity.ID, entry.Email); err != nil {
+		return nil, false, fmt.Errorf("update external identity: %w", err)
+	}
+	return user, changed || roleChanged, nil
+}
+
+// applyProfile 用目录中的昵称、邮箱、电话覆盖本地资料，目录中为空的属性保留本地值。
+// 邮箱已被其他账号使用时不覆盖
+func (s *ldapService) applyProfile(repos *mysql.Repositories, user *model.User, entry *LDAPEntry) (bool, error) {
+	var changed bool
+	before := *user
+	if entry.Nickname != "" && entry.Nickname != user.Nickname {
+		user.Nickname = entry.Nickname
+		changed = true
+	}
+	if phone := truncate(entry.Phone, 20); phone != "" && phone != user.Phone {
+		user.Phone = phone
+		changed = true
+	}
+	if entry.Email != "" && !strings.EqualFold(entry.Email, user.Email) {
+		exist, err := repos.User.GetByEmail(entry.Email)
+		if err != nil {
+			return false, fmt.Errorf("get user by email: %w", err)
+		}
+		if exist == nil {
+			user.Email = entry.Email
+			changed = true
+			now := time.Now()
+			if err := repos.User.SetEmailVerifiedAt(user.ID, &now); err != nil {
+				return false, fmt.Errorf("set email verified: %w", err)
+			}
+			user.EmailVerifiedAt = &now
+		}
+	}
+	if changed {
+		if err := writeAuditChange(repos.AuditLog, SystemActor, AuditUserProfile, AuditTargetUser, user.ID,
+			&before, user, map[string]string{"source": s.cfg.Name}); err != nil {
+			return false, err
+		}
+	}
+	return changed, nil
+}
+
+// applyRole 按目录中的组更新角色。未配置组映射时不管理角色；降级最后一个管理员时保留原角色。
+// 需在已锁定管理员的事务中调用
+func (s *ldapService) applyRole(repos *mysql.Repositories, user *model.User, entry *LDAPEntry) (bool, error) {
+	if len(s.cfg.GroupRoles) == 0 {
+		return false, nil
+	}
+	role := s.mapRole(entry.Groups, s.cfg.DefaultRole)
+	if role == user.Role {
+		return false, nil
+	}
+
+	if role != model.RoleAdmin {
+		err := ensureNotLastAdmin(repos.User, user)
+		if errors.Is(err, ErrLastAdmin) {
+			log.Printf("ldap: user %s is the last active admin, role %s from directory ignored", user.Username, role)
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	from := user.Role
+	user.Role = role
+	if err := repos.User.Update(user); err != nil {
+		return false, fmt.Errorf("update user role: %w", err)
+	}
+	if err := writeAuditChange(repos.AuditLog, SystemActor, AuditUserRole, AuditTargetUser, user.ID,
+		map[string]string{"role": from}, map[string]string{"role": role},
+		map[string]string{"source": s.cfg.Name}); err != nil {
+		return false, err
+	}
+	// 角色变化后注销已签发的令牌，使新角色立即生效
+	if err := database.RevokeUserTokens(context.Background(), user.ID); err != nil {
+		return false, fmt.Errorf("revoke sessions: %w", err)
+	}
+	return true, nil
+}
+
+// mapRole 按配置的顺序取第一个匹配的组对应的角色，都不匹配时返回def
+func (s *ldapService) mapRole(groups []string, def string) string {
+	for _, m := range s.cfg.GroupRoles {
+		for _, g := range groups {
+			if strings.EqualFold(normalizeDN(g), normalizeDN(m.Group)) {
+				return m.Role
+			}
+		}
+	}
+	return def
+}
+
+// normalizeDN 去掉DN各部分之间的空格，便于比较
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, p := range parts {
+		parts[i] = strings.TrimSpace(p)
+	}
+	return strings.Join(parts, ",")
+}
+
+// GetLastSync 获取最近一次目录同步的结果
+func (s *ldapService) GetLastSync() (*model.JobRun, error) {
+	run, err := s.jobRunRepo.GetLatest(JobLDAPSync)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, ErrNotFound
+	}
+	return run, nil
+}
+
+// ldapClient 通过网络访问LDAP目录，每次操作使用新的连接
+type ldapClient struct {
+	cfg config.LDAPConfig
+}
+
+// NewLDAPClient 创建LDAP目录客户端
+func NewLDAPClient(cfg config.LDAPConfig) LDAPDirectory {
+	return &ldapClient{cfg: cfg}
+}
+
+// Authenticate 用服务账号查找用户，再用用户的DN和密码绑定
+func (c *ldapClient) Authenticate(username, password string) (*LDAPEntry, error) {
+	// 空密码会被目录当作匿名绑定而成功，必须拒绝
+	if password == "" {
+		return nil, ErrPasswordIncorrect
+	}
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	result, err := conn.Search(c.searchRequest(fmt.Sprintf(c.cfg.UserFilter, ldap.EscapeFilter(username)), 2))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("search user: %w", err)
+	}
+	if result == nil || len(result.Entries) == 0 {
+		return nil, nil
+	}
+	if len(result.Entries) > 1 {
+		return nil, fmt.Errorf("user filter matched more than one entry for %q", username)
+	}
+
+	entry := result.Entries[0]
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrPasswordIncorrect
+		}
+		return nil, fmt.Errorf("bind user: %w", err)
+	}
+	return c.toEntry(entry), nil
+}
+
+// ListUsers 分页列出过滤器匹配的全部用户
+func (c *ldapClient) ListUsers() ([]*LDAPEntry, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	result, err := conn.SearchWithPaging(c.searchRequest(fmt.Sprintf(c.cfg.UserFilter, "*"), 0), ldapPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("search users: %w", err)
+	}
+	entries := make([]*LDAPEntry, 0, len(result.Entries))
+	for _, e := range result.Entries {
+		if entry := c.toEntry(e); entry.Username != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// connect 连接目录并用服务账号绑定
+func (c *ldapClient) connect() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.cfg.InsecureSkipVerify}
+	if u, err := url.Parse(c.cfg.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+	conn, err := ldap.DialURL(c.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: c.cfg.TimeoutDuration()}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", c.cfg.URL, err)
+	}
+	conn.SetTimeout(c.cfg.TimeoutDuration())
+
+	if c.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("start tls: %w", err)
+		}
+	}
+	if c.cfg.BindDN != "" {
+		if err := conn.Bind(c.cfg.BindDN, c.cfg.BindPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("bind service account: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// searchRequest 在 BaseDN 下查找用户，只返回映射需要的属性
+func (c *ldapClient) searchRequest(filter string, sizeLimit int) *ldap.SearchRequest {
+	attrs := []string{c.cfg.UsernameAttr}
+	for _, a := range []string{c.cfg.NicknameAttr, c.cfg.EmailAttr, c.cfg.PhoneAttr, c.cfg.GroupAttr} {
+		if a != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	return ldap.NewSearchRequest(
+		c.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		sizeLimit, int(c.cfg.Timeout), false,
+		filter, attrs, nil,
+	)
+}
+
+// toEntry 按配置的属性名读取用户信息
+func (c *ldapClient) toEntry(e *ldap.Entry) *LDAPEntry {
+	entry := &LDAPEntry{
+		DN:       e.DN,
+		Username: e.GetAttributeValue(c.cfg.UsernameAttr),
+	}
+	if c.cfg.NicknameAttr != "" {
+		entry.Nickname = e.GetAttributeValue(c.cfg.NicknameAttr)
+	}
+	if c.cfg.EmailAttr != "" {
+		entry.Email = e.GetAttributeValue(c.cfg.EmailAttr)
+	}
+	if c.cfg.PhoneAttr != "" {
+		entry.Phone = e.GetAttributeValue(c.cfg.PhoneAttr)
+	}
+	if c.cfg.GroupAttr != "" {
+		entry.Groups = e.GetAttributeValues(c.cfg.GroupAttr)
+	}
+	return entry
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ldapTestServer 进程内的LDAP目录，只实现测试需要的简单绑定、查找（含分页控制）和解绑，
+// 过滤器支持 and、or、not、等值匹配和存在性匹配
+type ldapTestServer struct {
+	t        *testing.T
+	listener net.Listener
+	URL      string
+
+	mu      sync.Mutex
+	entries []*ldapTestEntry
+	binds   map[string]string // DN -> 密码，包括服务账号和用户
+}
+
+// ldapTestEntry 目录中的条目
+type ldapTestEntry struct {
+	dn    string
+	attrs map[string][]string
+}
+
+// values 按属性名（不区分大小写）读取属性值
+func (e *ldapTestEntry) values(name string) []string {
+	for k, v := range e.attrs {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+func newLDAPTestServer(t *testing.T) *ldapTestServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &ldapTestServer{
+		t:        t,
+		listener: listener,
+		URL:      "ldap://" + listener.Addr().String(),
+		binds:    make(map[string]string),
+	}
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		listener.Close()
+		wg.Wait()
+	})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.serve(conn)
+			}()
+		}
+	}()
+	return s
+}
+
+// addAccount 添加可以绑定但不作为用户返回的账号，如服务账号
+func (s *ldapTestServer) addAccount(dn, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.binds[strings.ToLower(dn)] = password
+}
+
+// addEntry 添加条目，password 不为空时该条目可以绑定
+func (s *ldapTestServer) addEntry(dn, password string, attrs map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, &ldapTestEntry{dn: dn, attrs: attrs})
+	if password != "" {
+		s.binds[strings.ToLower(dn)] = password
+	}
+}
+
+// updateEntry 替换条目的属性
+func (s *ldapTestServer) updateEntry(dn string, attrs map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if strings.EqualFold(e.dn, dn) {
+			e.attrs = attrs
+		}
+	}
+}
+
+// removeEntry 删除条目
+func (s *ldapTestServer) removeEntry(dn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.entries {
+		if strings.EqualFold(e.dn, dn) {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			break
+		}
+	}
+	delete(s.binds, strings.ToLower(dn))
+}
+
+// serve 处理一个连接上的请求，直到客户端解绑或断开
+func (s *ldapTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	boundDN := ""
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.t.Logf("ldap test server: read request: %v", err)
+			}
+			return
+		}
+		if len(packet.Children) < 2 {
+			return
+		}
+		id, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		var controls []*ber.Packet
+		if len(packet.Children) > 2 {
+			controls = packet.Children[2].Children
+		}
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, code := s.bind(op)
+			if code == ldap.LDAPResultSuccess {
+				boundDN = dn
+			}
+			s.write(conn, ldapResponse(id, ldap.ApplicationBindResponse, code, nil))
+		case ldap.ApplicationUnbindRequest:
+			return
+		case ldap.ApplicationSearchRequest:
+			if boundDN == "" {
+				s.write(conn, ldapResponse(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights, nil))
+				continue
+			}
+			s.search(conn, id, op, controls)
+		default:
+			s.write(conn, ldapResponse(id, ldap.ApplicationExtendedResponse, ldap.LDAPResultUnwillingToPerform, nil))
+		}
+	}
+}
+
+// bind 处理简单绑定，返回绑定的DN和结果码。匿名绑定成功但不能查找
+func (s *ldapTestServer) bind(op *ber.Packet) (string, uint16) {
+	if len(op.Children) < 3 {
+		return "", ldap.LDAPResultProtocolError
+	}
+	dn, _ := op.Children[1].Value.(string)
+	password := op.Children[2].Data.String()
+	if dn == "" && password == "" {
+		return "", ldap.LDAPResultSuccess
+	}
+
+	s.mu.Lock()
+	want, ok := s.binds[strings.ToLower(dn)]
+	s.mu.Unlock()
+	if !ok || password == "" || password != want {
+		return "", ldap.LDAPResultInvalidCredentials
+	}
+	return dn, ldap.LDAPResultSuccess
+}
+
+// search 返回基准DN下与过滤器匹配的条目，支持条数限制和分页控制
+func (s *ldapTestServer) search(conn net.Conn, id int64, op *ber.Packet, controls []*ber.Packet) {
+	if len(op.Children) < 8 {
+		s.write(conn, ldapResponse(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError, nil))
+		return
+	}
+	base, _ := op.Children[0].Value.(string)
+	sizeLimit, _ := op.Children[3].Value.(int64)
+	filter := op.Children[6]
+	var attrs []string
+	for _, a := range op.Children[7].Children {
+		if name, ok := a.Value.(string); ok {
+			attrs = append(attrs, strings.ToLower(name))
+		}
+	}
+
+	var paging *ldap.ControlPaging
+	for _, c := range controls {
+		if decoded, err := ldap.DecodeControl(c); err == nil {
+			if p, ok := decoded.(*ldap.ControlPaging); ok {
+				paging = p
+			}
+		}
+	}
+
+	s.mu.Lock()
+	var matched []*ldapTestEntry
+	for _, e := range s.entries {
+		if inBase(e.dn, base) && matchFilter(e, filter) {
+			matched = append(matched, e)
+		}
+	}
+	s.mu.Unlock()
+
+	code := uint16(ldap.LDAPResultSuccess)
+	var respControls []ldap.Control
+	if paging != nil {
+		offset, _ := strconv.Atoi(string(paging.Cookie))
+		if offset > len(matched) {
+			offset = len(matched)
+		}
+		matched = matched[offset:]
+		next := &ldap.ControlPaging{}
+		if paging.PagingSize > 0 && len(matched) > int(paging.PagingSize) {
+			matched = matched[:paging.PagingSize]
+			next.SetCookie([]byte(strconv.Itoa(offset + int(paging.PagingSize))))
+		}
+		respControls = append(respControls, next)
+	}
+	if sizeLimit > 0 && len(matched) > int(sizeLimit) {
+		matched = matched[:sizeLimit]
+		code = ldap.LDAPResultSizeLimitExceeded
+	}
+
+	for _, e := range matched {
+		s.write(conn, ldapSearchEntry(id, e, attrs))
+	}
+	s.write(conn, ldapResponse(id, ldap.ApplicationSearchResultDone, code, respControls))
+}
+
+func (s *ldapTestServer) write(conn net.Conn, packet *ber.Packet) {
+	if _, err := conn.Write(packet.Bytes()); err != nil {
+		s.t.Logf("ldap test server: write response: %v", err)
+	}
+}
+
+// inBase 条目是否在基准DN下（含基准DN本身）
+func inBase(dn, base string) bool {
+	dn, base = strings.ToLower(normalizeDN(dn)), strings.ToLower(normalizeDN(base))
+	return base == "" || dn == base || strings.HasSuffix(dn, ","+base)
+}
+
+// matchFilter 计算过滤器，不支持的过滤器类型视为不匹配
+func matchFilter(e *ldapTestEntry, f *ber.Packet) bool {
+	switch f.Tag {
+	case ldap.FilterAnd:
+		for _, c := range f.Children {
+			if !matchFilter(e, c) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, c := range f.Children {
+			if matchFilter(e, c) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return len(f.Children) == 1 && !matchFilter(e, f.Children[0])
+	case ldap.FilterEqualityMatch:
+		if len(f.Children) != 2 {
+			return false
+		}
+		attr, _ := f.Children[0].Value.(string)
+		value, _ := f.Children[1].Value.(string)
+		for _, v := range e.values(attr) {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		return len(e.values(f.Data.String())) > 0
+	default:
+		return false
+	}
+}
+
+// ldapResponse 生成带结果码的响应
+func ldapResponse(id int64, tag ber.Tag, code uint16, controls []ldap.Control) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	packet.AppendChild(op)
+	if len(controls) > 0 {
+		packet.AppendChild(encodeControls(controls))
+	}
+	return packet
+}
+
+func encodeControls(controls []ldap.Control) *ber.Packet {
+	packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+	for _, c := range controls {
+		packet.AppendChild(c.Encode())
+	}
+	return packet
+}
+
+// ldapSearchEntry 生成查找结果中的一个条目，attrs 为空时返回全部属性
+func ldapSearchEntry(id int64, e *ldapTestEntry, attrs []string) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "Object Name"))
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, values := range e.attrs {
+		if len(attrs) > 0 && !containsString(attrs, strings.ToLower(name)) {
+			continue
+		}
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, v := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+		}
+		attr.AppendChild(set)
+		list.AppendChild(attr)
+	}
+	op.AppendChild(list)
+	packet.AppendChild(op)
+	return packet
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
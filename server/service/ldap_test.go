@@ -0,0 +1,278 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"library/config"
+	"library/model"
+	"library/password"
+	"library/repository/mysql"
+)
+
+const (
+	testLDAPBase       = "ou=people,dc=example,dc=org"
+	testLDAPAdmins     = "cn=admins,ou=groups,dc=example,dc=org"
+	testLDAPLibrarians = "cn=librarians,ou=groups,dc=example,dc=org"
+)
+
+// testLDAPPerson 目录中用户条目的属性
+func testLDAPPerson(uid, name, mail string, groups ...string) map[string][]string {
+	return map[string][]string{
+		"objectClass":     {"top", "person", "inetOrgPerson"},
+		"uid":             {uid},
+		"displayName":     {name},
+		"mail":            {mail},
+		"telephoneNumber": {"+86 10 5555 0000"},
+		"memberOf":        groups,
+	}
+}
+
+// newTestLDAPDirectory 启动包含服务账号和 alice（馆员）、bob（无组）、carol（管理员）的目录
+func newTestLDAPDirectory(t *testing.T) *ldapTestServer {
+	t.Helper()
+	srv := newLDAPTestServer(t)
+	srv.addAccount("cn=svc,dc=example,dc=org", "svc-secret")
+	srv.addEntry("uid=alice,"+testLDAPBase, "alice-pw", testLDAPPerson("alice", "Alice", "alice@example.org", testLDAPLibrarians))
+	srv.addEntry("uid=bob,"+testLDAPBase, "bob-pw", testLDAPPerson("bob", "Bob", "bob@example.org"))
+	// 组DN的写法与配置不同（带空格），属于多个映射组时取配置中靠前的
+	srv.addEntry("uid=carol,"+testLDAPBase, "carol-pw", testLDAPPerson("carol", "Carol", "carol@example.org",
+		"cn=librarians, ou=groups, dc=example, dc=org", "CN=Admins,OU=Groups,DC=example,DC=org"))
+	// 基准DN之外的条目不能登录
+	srv.addEntry("uid=dave,ou=partners,dc=example,dc=org", "dave-pw", testLDAPPerson("dave", "Dave", "dave@example.org"))
+	return srv
+}
+
+func testLDAPConfig(srv *ldapTestServer) config.LDAPConfig {
+	return config.LDAPConfig{
+		Enabled:      true,
+		Name:         "ldap",
+		DisplayName:  "LDAP",
+		URL:          srv.URL,
+		Timeout:      5,
+		BindDN:       "cn=svc,dc=example,dc=org",
+		BindPassword: "svc-secret",
+		BaseDN:       testLDAPBase,
+		UserFilter:   "(&(objectClass=person)(uid=%s))",
+		UsernameAttr: "uid",
+		NicknameAttr: "displayName",
+		EmailAttr:    "mail",
+		PhoneAttr:    "telephoneNumber",
+		GroupAttr:    "memberOf",
+		GroupRoles: []config.LDAPGroupRole{
+			{Group: testLDAPAdmins, Role: model.RoleAdmin},
+			{Group: testLDAPLibrarians, Role: model.RoleLibrarian},
+		},
+		DefaultRole: model.RoleUser,
+	}
+}
+
+func newTestLDAPService(db *gorm.DB, cfg config.LDAPConfig) LDAPServiceInterface {
+	hasher := password.MustNew(password.Options{Algorithm: password.Bcrypt, BcryptCost: 4})
+	return NewLDAPService(cfg, nil, mysql.NewUnitOfWork(db), mysql.NewJobRunRepository(db), hasher, NewLoginGuard(LoginGuardOptions{}))
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	db := newTestDB(t)
+	srv := newTestLDAPDirectory(t)
+	svc := newTestLDAPService(db, testLDAPConfig(srv))
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantErr  error
+		wantRole string
+	}{
+		{"librarian group", "alice", "alice-pw", nil, model.RoleLibrarian},
+		{"no mapped group", "bob", "bob-pw", nil, model.RoleUser},
+		{"first mapped group wins", "carol", "carol-pw", nil, model.RoleAdmin},
+		{"username case", "ALICE", "alice-pw", nil, model.RoleLibrarian},
+		{"wrong password", "alice", "bob-pw", ErrPasswordIncorrect, ""},
+		{"empty password", "alice", "", ErrPasswordIncorrect, ""},
+		{"user not found", "nobody", "x", ErrNotFound, ""},
+		{"outside base dn", "dave", "dave-pw", ErrNotFound, ""},
+		{"filter injection", "*", "alice-pw", ErrNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := svc.Authenticate(tt.username, tt.password, "127.0.0.1")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if user.Role != tt.wantRole {
+				t.Fatalf("role = %s, want %s", user.Role, tt.wantRole)
+			}
+		})
+	}
+
+	// 首次登录创建的账号使用目录中的资料，再次登录关联同一账号
+	var alice model.User
+	if err := db.Where("username = ?", "alice").First(&alice).Error; err != nil {
+		t.Fatalf("load alice: %v", err)
+	}
+	if alice.Nickname != "Alice" || alice.Email != "alice@example.org" || alice.EmailVerifiedAt == nil || alice.Phone == "" {
+		t.Fatalf("alice = %+v", alice)
+	}
+	if n := countUsers(t, db); n != 3 {
+		t.Fatalf("users = %d, want 3", n)
+	}
+
+	// 组变化后再次登录更新角色
+	srv.updateEntry("uid=alice,"+testLDAPBase, testLDAPPerson("alice", "Alice", "alice@example.org"))
+	user, err := svc.Authenticate("alice", "alice-pw", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Authenticate after group change: %v", err)
+	}
+	if user.ID != alice.ID || user.Role != model.RoleUser {
+		t.Fatalf("after group change: user %d role %s, want %d role user", user.ID, user.Role, alice.ID)
+	}
+}
+
+// TestLDAPProvisionWithoutMail 目录中没有邮箱属性的用户可以创建多个账号，邮箱保存为NULL
+func TestLDAPProvisionWithoutMail(t *testing.T) {
+	db := newTestDB(t)
+	srv := newTestLDAPDirectory(t)
+	for _, uid := range []string{"erin", "frank"} {
+		attrs := testLDAPPerson(uid, uid, "")
+		delete(attrs, "mail")
+		srv.addEntry("uid="+uid+","+testLDAPBase, uid+"-pw", attrs)
+	}
+	svc := newTestLDAPService(db, testLDAPConfig(srv))
+
+	for _, uid := range []string{"erin", "frank"} {
+		user, err := svc.Authenticate(uid, uid+"-pw", "127.0.0.1")
+		if err != nil {
+			t.Fatalf("Authenticate %s: %v", uid, err)
+		}
+		if user.Email != "" || user.EmailVerifiedAt != nil {
+			t.Fatalf("%s email = %q, verified at %v, want none", uid, user.Email, user.EmailVerifiedAt)
+		}
+	}
+	var n int64
+	if err := db.Model(&model.User{}).Where("email IS NULL").Count(&n).Error; err != nil {
+		t.Fatalf("count users: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("users with NULL email = %d, want 2", n)
+	}
+
+	// 再次登录和同步目录时没有邮箱的账号保持不变
+	if _, err := svc.Authenticate("erin", "erin-pw", "127.0.0.1"); err != nil {
+		t.Fatalf("second Authenticate: %v", err)
+	}
+	if _, err := svc.SyncDirectory(); err != nil {
+		t.Fatalf("SyncDirectory: %v", err)
+	}
+	var erin model.User
+	if err := db.Where("username = ?", "erin").First(&erin).Error; err != nil {
+		t.Fatalf("load erin: %v", err)
+	}
+	if erin.Status != 1 || erin.Email != "" {
+		t.Fatalf("erin = status %d email %q", erin.Status, erin.Email)
+	}
+}
+
+func TestLDAPAuthenticateServiceBindFailure(t *testing.T) {
+	db := newTestDB(t)
+	srv := newTestLDAPDirectory(t)
+	cfg := testLDAPConfig(srv)
+	cfg.BindPassword = "wrong"
+	svc := newTestLDAPService(db, cfg)
+
+	if _, err := svc.Authenticate("alice", "alice-pw", "127.0.0.1"); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("err = %v, want ErrProviderUnavailable", err)
+	}
+}
+
+func TestLDAPSyncDirectory(t *testing.T) {
+	db := newTestDB(t)
+	srv := newTestLDAPDirectory(t)
+	svc := newTestLDAPService(db, testLDAPConfig(srv))
+
+	users := make(map[string]*model.User)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user, err := svc.Authenticate(name, name+"-pw", "127.0.0.1")
+		if err != nil {
+			t.Fatalf("Authenticate %s: %v", name, err)
+		}
+		users[name] = user
+	}
+
+	// bob 离职从目录删除；carol 是唯一的管理员，删除后仍保留；alice 改了显示名称
+	srv.removeEntry("uid=bob," + testLDAPBase)
+	srv.removeEntry("uid=carol," + testLDAPBase)
+	srv.updateEntry("uid=alice,"+testLDAPBase, testLDAPPerson("alice", "Alice Liddell", "alice@example.org", testLDAPLibrarians))
+
+	run, err := svc.SyncDirectory()
+	if err != nil {
+		t.Fatalf("SyncDirectory: %v", err)
+	}
+	if run.Status != 2 || run.Scanned != 3 || run.Affected != 1 || run.Updated != 1 {
+		t.Fatalf("run = status %d scanned %d affected %d updated %d, want 2/3/1/1", run.Status, run.Scanned, run.Affected, run.Updated)
+	}
+
+	status := func(name string) *model.User {
+		t.Helper()
+		var u model.User
+		if err := db.First(&u, users[name].ID).Error; err != nil {
+			t.Fatalf("load %s: %v", name, err)
+		}
+		return &u
+	}
+	if u := status("bob"); u.Status != 2 {
+		t.Fatalf("bob status = %d, want 2 (disabled)", u.Status)
+	}
+	if u := status("carol"); u.Status != 1 {
+		t.Fatalf("carol status = %d, want 1 (last admin kept)", u.Status)
+	}
+	if u := status("alice"); u.Status != 1 || u.Nickname != "Alice Liddell" {
+		t.Fatalf("alice = status %d nickname %q", u.Status, u.Nickname)
+	}
+
+	// 被禁用的账号不能再通过目录登录
+	srv.addEntry("uid=bob,"+testLDAPBase, "bob-pw", testLDAPPerson("bob", "Bob", "bob@example.org"))
+	if _, err := svc.Authenticate("bob", "bob-pw", "127.0.0.1"); !errors.Is(err, ErrAccountDisabled) {
+		t.Fatalf("disabled bob err = %v, want ErrAccountDisabled", err)
+	}
+
+	last, err := svc.GetLastSync()
+	if err != nil || last.ID != run.ID {
+		t.Fatalf("GetLastSync = %+v, %v", last, err)
+	}
+}
+
+func TestLDAPSyncEmptyDirectory(t *testing.T) {
+	db := newTestDB(t)
+	srv := newTestLDAPDirectory(t)
+	svc := newTestLDAPService(db, testLDAPConfig(srv))
+
+	user, err := svc.Authenticate("bob", "bob-pw", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	for _, uid := range []string{"alice", "bob", "carol"} {
+		srv.removeEntry("uid=" + uid + "," + testLDAPBase)
+	}
+
+	// 目录返回空结果时视为故障，不禁用任何账号
+	run, err := svc.SyncDirectory()
+	if err == nil || run == nil || run.Status != 3 {
+		t.Fatalf("SyncDirectory = %+v, %v, want failed run", run, err)
+	}
+	var bob model.User
+	if err := db.First(&bob, user.ID).Error; err != nil {
+		t.Fatalf("load bob: %v", err)
+	}
+	if bob.Status != 1 {
+		t.Fatalf("bob status = %d, want 1", bob.Status)
+	}
+}
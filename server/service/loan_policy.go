@@ -0,0 +1,195 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"library/config"
+	"library/model"
+	"library/repository/mysql"
+)
+
+// LoanPolicyServiceInterface 借阅规则服务接口
+type LoanPolicyServiceInterface interface {
+	CreatePolicy(actor Actor, policy *model.LoanPolicy) error
+	UpdatePolicy(actor Actor, policy *model.LoanPolicy) error
+	DeletePolicy(actor Actor, id uint) error
+	GetPolicy(id uint) (*model.LoanPolicy, error)
+	ListPolicies(params *model.SearchParams) ([]*model.LoanPolicy, int64, error)
+	ResolvePolicy(role, category string) (*model.LoanPolicy, error)
+}
+
+type LoanPolicyService struct {
+	loanPolicyRepo mysql.LoanPolicyRepository
+	uow            mysql.UnitOfWork
+}
+
+func NewLoanPolicyService(loanPolicyRepo mysql.LoanPolicyRepository, uow mysql.UnitOfWork) LoanPolicyServiceInterface {
+	return &LoanPolicyService{
+		loanPolicyRepo: loanPolicyRepo,
+		uow:            uow,
+	}
+}
+
+// CreatePolicy 创建借阅规则，同一角色和分类组合只能有一条规则
+func (s *LoanPolicyService) CreatePolicy(actor Actor, policy *model.LoanPolicy) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		exist, err := repos.LoanPolicy.GetByScope(policy.Role, policy.Category)
+		if err != nil {
+			return fmt.Errorf("check policy scope: %w", err)
+		}
+		if exist != nil {
+			return ErrAlreadyExists
+		}
+
+		if policy.Status == 0 {
+			policy.Status = 1 // 默认启用
+		}
+		if err := repos.LoanPolicy.Create(policy); err != nil {
+			return fmt.Errorf("create loan policy: %w", err)
+		}
+		return writeAuditChange(repos.AuditLog, actor, AuditLoanPolicyCreate, AuditTargetLoanPolicy, policy.ID, nil, policy, nil)
+	})
+}
+
+// UpdatePolicy 更新借阅规则
+func (s *LoanPolicyService) UpdatePolicy(actor Actor, policy *model.LoanPolicy) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		exist, err := repos.LoanPolicy.GetByID(policy.ID)
+		if err != nil {
+			return fmt.Errorf("get loan policy by id: %w", err)
+		}
+		if exist == nil {
+			return ErrNotFound
+		}
+
+		other, err := repos.LoanPolicy.GetByScope(policy.Role, policy.Category)
+		if err != nil {
+			return fmt.Errorf("check policy scope: %w", err)
+		}
+		if other != nil && other.ID != policy.ID {
+			return ErrAlreadyExists
+		}
+
+		policy.CreatedAt = exist.CreatedAt
+		if err := repos.LoanPolicy.Update(policy); err != nil {
+			return fmt.Errorf("update loan policy: %w", err)
+		}
+		return writeAuditChange(repos.AuditLog, actor, AuditLoanPolicyUpdate, AuditTargetLoanPolicy, policy.ID, exist, policy, nil)
+	})
+}
+
+// DeletePolicy 删除借阅规则
+func (s *LoanPolicyService) DeletePolicy(actor Actor, id uint) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		policy, err := repos.LoanPolicy.GetByID(id)
+		if err != nil {
+			return fmt.Errorf("get loan policy by id: %w", err)
+		}
+		if policy == nil {
+			return ErrNotFound
+		}
+		if err := repos.LoanPolicy.Delete(id); err != nil {
+			return err
+		}
+		return writeAuditChange(repos.AuditLog, actor, AuditLoanPolicyDelete, AuditTargetLoanPolicy, id, policy, nil, nil)
+	})
+}
+
+// GetPolicy 获取借阅规则
+func (s *LoanPolicyService) GetPolicy(id uint) (*model.LoanPolicy, error) {
+	policy, err := s.loanPolicyRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("get loan policy by id: %w", err)
+	}
+	if policy == nil {
+		return nil, ErrNotFound
+	}
+	return policy, nil
+}
+
+// ListPolicies 获取借阅规则列表
+func (s *LoanPolicyService) ListPolicies(params *model.SearchParams) ([]*model.LoanPolicy, int64, error) {
+	return s.loanPolicyRepo.List(params)
+}
+
+// ResolvePolicy 获取对指定角色和分类生效的借阅规则
+func (s *LoanPolicyService) ResolvePolicy(role, category string) (*model.LoanPolicy, error) {
+	return resolveLoanPolicy(s.loanPolicyRepo, role, category)
+}
+
+// resolveLoanPolicy 按匹配程度选出生效的借阅规则：
+// 角色+分类 > 仅角色 > 仅分类 > 通配规则 > 配置文件中的默认规则。
+func resolveLoanPolicy(loanPolicyRepo mysql.LoanPolicyRepository, role, category string) (*model.LoanPolicy, error) {
+	policies, err := loanPolicyRepo.FindMatching(role, category)
+	if err != nil {
+		return nil, fmt.Errorf("find loan policies: %w", err)
+	}
+
+	var best *model.LoanPolicy
+	bestScore := -1
+	for _, p := range policies {
+		score := 0
+		if p.Role != "" {
+			score += 2
+		}
+		if p.Category != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = p, score
+		}
+	}
+	if best != nil {
+		return best, nil
+	}
+	return defaultLoanPolicy(), nil
+}
+
+// defaultLoanPolicy 配置文件中的默认借阅规则
+func defaultLoanPolicy() *model.LoanPolicy {
+	cfg := config.GlobalConfig.Borrow.DefaultPolicy
+	return &model.LoanPolicy{
+		Name:        "default",
+		LoanDays:    cfg.LoanDays,
+		MaxRenewals: cfg.MaxRenewals,
+		MaxLoans:    cfg.MaxLoans,
+		DailyFine:   cfg.DailyFine,
+		FineCap:     cfg.FineCap,
+		GraceDays:   cfg.GraceDays,
+		Status:      1,
+	}
+}
+
+// capBorrowFine 扣除借阅在续借时已记入的罚款，使同一借阅的罚款总额不超过借阅规则的罚金上限
+func capBorrowFine(fineRepo mysql.FineRepository, policy *model.LoanPolicy, borrowID uint, fine float64) (float64, error) {
+	if policy.FineCap <= 0 || fine <= 0 {
+		return fine, nil
+	}
+	charged, err := fineRepo.SumBorrowCharges(borrowID)
+	if err != nil {
+		return 0, err
+	}
+	if remaining := math.Round((policy.FineCap-charged)*100) / 100; fine > remaining {
+		fine = math.Max(remaining, 0)
+	}
+	return fine, nil
+}
+
+// calculateFine 按借阅规则计算逾期罚金，宽限期内归还不计罚金
+func calculateFine(policy *model.LoanPolicy, dueDate, returnDate time.Time) float64 {
+	if !returnDate.After(dueDate) {
+		return 0
+	}
+	days := int(returnDate.Sub(dueDate).Hours() / 24)
+	if days <= policy.GraceDays {
+		return 0
+	}
+
+	fine := float64(days) * policy.DailyFine
+	if policy.FineCap > 0 && fine > policy.FineCap {
+		fine = policy.FineCap
+	}
+	return math.Round(fine*100) / 100
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"library/database"
+)
+
+// loginLockLevelTTL 在该时间内再次被锁定时，锁定时长翻倍
+const loginLockLevelTTL = 24 * time.Hour
+
+// 登录失败的原因
+const (
+	LoginFailUnknownUser = "unknown_user" // 用户名不存在
+	LoginFailBadPassword = "bad_password" // 密码错误
+)
+
+// LoginGuardOptions 登录保护参数
+type LoginGuardOptions struct {
+	MaxFailures   int           // 窗口内同一用户名失败多少次后锁定账号，0表示不锁定
+	Window        time.Duration // 统计失败次数的窗口
+	LockBase      time.Duration // 首次锁定时长
+	LockMax       time.Duration // 锁定时长上限
+	IPMaxFailures int           // 窗口内同一IP的失败次数上限，0表示不限制
+}
+
+// LoginGuard 按用户名和IP统计登录失败次数，失败过多时锁定账号或暂时拒绝该IP。
+// 状态保存在Redis中，未配置Redis时保存在进程内
+type LoginGuard struct {
+	opts LoginGuardOptions
+}
+
+// NewLoginGuard 创建登录保护
+func NewLoginGuard(opts LoginGuardOptions) *LoginGuard {
+	return &LoginGuard{opts: opts}
+}
+
+// LockedError 账号被锁定或IP被暂时拒绝登录时返回，携带解锁时间。
+// errors.Is 可以匹配到 Err 中的哨兵错误（ErrAccountLocked 或 ErrTooManyAttempts）
+type LockedError struct {
+	Err      error     // 哨兵错误
+	UnlockAt time.Time // 解锁时间
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s until %s", e.Err, e.UnlockAt.Format(time.RFC3339))
+}
+
+func (e *LockedError) Unwrap() error {
+	return e.Err
+}
+
+// Check 登录前检查IP和账号是否被锁定
+func (g *LoginGuard) Check(username, ip string) error {
+	ctx := context.Background()
+	if g.opts.IPMaxFailures > 0 {
+		n, ttl, err := database.GetLoginFailures(ctx, database.LoginIPKey(ip))
+		if err != nil {
+			return err
+		}
+		if n >= int64(g.opts.IPMaxFailures) && ttl > 0 {
+			return &LockedError{Err: ErrTooManyAttempts, UnlockAt: time.Now().Add(ttl)}
+		}
+	}
+
+	ttl, err := database.GetLoginLock(ctx, username)
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		return &LockedError{Err: ErrAccountLocked, UnlockAt: time.Now().Add(ttl)}
+	}
+	return nil
+}
+
+// Fail 记录一次失败的登录。同一用户名在窗口内失败达到上限时锁定账号并返回 LockedError，
+// 锁定时长从 LockBase 开始，24小时内每次再被锁定翻倍，不超过 LockMax
+func (g *LoginGuard) Fail(username, ip, reason string) error {
+	ctx := context.Background()
+	if err := database.AppendLoginFailure(ctx, database.LoginFailure{
+		Username: username,
+		IP:       ip,
+		Reason:   reason,
+		At:       time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	if _, err := database.IncrLoginFailures(ctx, database.LoginIPKey(ip), g.opts.Window); err != nil {
+		return err
+	}
+	if g.opts.MaxFailures <= 0 {
+		return nil
+	}
+
+	userKey := database.LoginUserKey(username)
+	n, err := database.IncrLoginFailures(ctx, userKey, g.opts.Window)
+	if err != nil {
+		return err
+	}
+	if n < int64(g.opts.MaxFailures) {
+		return nil
+	}
+
+	level, err := database.IncrLoginLockLevel(ctx, username, loginLockLevelTTL)
+	if err != nil {
+		return err
+	}
+	d := g.lockDuration(level)
+	if err := database.LockLogin(ctx, username, d); err != nil {
+		return err
+	}
+	// 解锁后重新开始计数
+	if err := database.ClearLoginFailures(ctx, userKey); err != nil {
+		return err
+	}
+	return &LockedError{Err: ErrAccountLocked, UnlockAt: time.Now().Add(d)}
+}
+
+// Succeed 登录成功后清除该用户名的失败次数和退避等级
+func (g *LoginGuard) Succeed(username string) error {
+	return database.UnlockLogin(context.Background(), username)
+}
+
+// Unlock 解除账号锁定
+func (g *LoginGuard) Unlock(username string) error {
+	return database.UnlockLogin(context.Background(), username)
+}
+
+// LockedUntil 账号的解锁时间，未锁定时为零值
+func (g *LoginGuard) LockedUntil(username string) (time.Time, error) {
+	ttl, err := database.GetLoginLock(context.Background(), username)
+	if err != nil || ttl <= 0 {
+		return time.Time{}, err
+	}
+	return time.Now().Add(ttl), nil
+}
+
+// Failures 最近的失败记录，username不为空时只返回该用户名的记录
+func (g *LoginGuard) Failures(username string, limit int) ([]database.LoginFailure, error) {
+	list, err := database.ListLoginFailures(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	result := make([]database.LoginFailure, 0, limit)
+	for _, f := range list {
+		if len(result) >= limit {
+			break
+		}
+		if username != "" && !strings.EqualFold(f.Username, username) {
+			continue
+		}
+		result = append(result, f)
+	}
+	return result, nil
+}
+
+// lockDuration 第level次锁定的时长
+func (g *LoginGuard) lockDuration(level int64) time.Duration {
+	d := g.opts.LockBase
+	for i := int64(1); i < level; i++ {
+		d *= 2
+		if g.opts.LockMax > 0 && d >= g.opts.LockMax {
+			return g.opts.LockMax
+		}
+	}
+	if g.opts.LockMax > 0 && d > g.opts.LockMax {
+		return g.opts.LockMax
+	}
+	return d
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// 登录保护状态保存在进程内，各测试使用不同的用户名和IP互不影响
+
+// assertLocked 校验err为锁定错误，解锁时间约为当前时间之后d
+func assertLocked(t *testing.T, err, want error, d time.Duration) {
+	t.Helper()
+	var locked *LockedError
+	if !errors.As(err, &locked) || !errors.Is(err, want) {
+		t.Fatalf("err = %v, want %v", err, want)
+	}
+	if remaining := time.Until(locked.UnlockAt); remaining > d || remaining < d-time.Minute/2 {
+		t.Fatalf("unlocks in %v, want %v", remaining, d)
+	}
+}
+
+func TestLoginGuardBackoff(t *testing.T) {
+	guard := NewLoginGuard(LoginGuardOptions{MaxFailures: 3, Window: time.Hour, LockBase: time.Minute, LockMax: 5 * time.Minute})
+	const username, ip = "Backoff", "198.51.100.1"
+
+	// 每次锁定前失败两次不锁定，第三次锁定，锁定时长翻倍直到上限
+	for _, d := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		for i := 0; i < 2; i++ {
+			if err := guard.Fail(username, ip, LoginFailBadPassword); err != nil {
+				t.Fatalf("Fail before the threshold: %v", err)
+			}
+		}
+		assertLocked(t, guard.Fail(username, ip, LoginFailBadPassword), ErrAccountLocked, d)
+		// 用户名不区分大小写
+		assertLocked(t, guard.Check("backoff", "198.51.100.2"), ErrAccountLocked, d)
+	}
+
+	until, err := guard.LockedUntil(username)
+	if err != nil || until.IsZero() {
+		t.Fatalf("LockedUntil = %v, %v, want a time", until, err)
+	}
+	if err := guard.Unlock(username); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := guard.Check(username, ip); err != nil {
+		t.Fatalf("Check after unlock: %v", err)
+	}
+	// 解锁同时清除退避等级，再次锁定从首次锁定时长开始
+	for i := 0; i < 2; i++ {
+		if err := guard.Fail(username, ip, LoginFailBadPassword); err != nil {
+			t.Fatalf("Fail after unlock: %v", err)
+		}
+	}
+	assertLocked(t, guard.Fail(username, ip, LoginFailBadPassword), ErrAccountLocked, time.Minute)
+
+	failures, err := guard.Failures("BACKOFF", 3)
+	if err != nil {
+		t.Fatalf("Failures: %v", err)
+	}
+	if len(failures) != 3 || failures[0].IP != ip || failures[0].Reason != LoginFailBadPassword {
+		t.Fatalf("Failures = %+v, want the 3 latest failures of %s", failures, username)
+	}
+}
+
+func TestLoginGuardSucceedResetsFailures(t *testing.T) {
+	guard := NewLoginGuard(LoginGuardOptions{MaxFailures: 3, Window: time.Hour, LockBase: time.Minute})
+	const username, ip = "succeed", "198.51.100.10"
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 2; i++ {
+			if err := guard.Fail(username, ip, LoginFailBadPassword); err != nil {
+				t.Fatalf("round %d: Fail: %v", round, err)
+			}
+		}
+		if err := guard.Succeed(username); err != nil {
+			t.Fatalf("Succeed: %v", err)
+		}
+	}
+	if err := guard.Check(username, ip); err != nil {
+		t.Fatalf("Check = %v, want no lock after successful logins", err)
+	}
+}
+
+func TestLoginGuardIPLimit(t *testing.T) {
+	guard := NewLoginGuard(LoginGuardOptions{Window: time.Hour, IPMaxFailures: 3})
+	const ip = "198.51.100.20"
+
+	// 同一IP尝试不同的用户名，达到上限后该IP的所有登录被拒绝
+	for _, username := range []string{"ip-a", "ip-b"} {
+		if err := guard.Fail(username, ip, LoginFailUnknownUser); err != nil {
+			t.Fatalf("Fail: %v", err)
+		}
+		if err := guard.Check("ip-c", ip); err != nil {
+			t.Fatalf("Check before the limit: %v", err)
+		}
+	}
+	if err := guard.Fail("ip-c", ip, LoginFailUnknownUser); err != nil {
+		t.Fatalf("Fail without account lockout = %v, want nil", err)
+	}
+	assertLocked(t, guard.Check("ip-d", ip), ErrTooManyAttempts, time.Hour)
+	if err := guard.Check("ip-d", "198.51.100.21"); err != nil {
+		t.Fatalf("Check from another IP: %v", err)
+	}
+	// 用户名没有被锁定
+	if until, err := guard.LockedUntil("ip-a"); err != nil || !until.IsZero() {
+		t.Fatalf("LockedUntil(ip-a) = %v, %v, want not locked", until, err)
+	}
+}
+
+func TestLockDuration(t *testing.T) {
+	capped := NewLoginGuard(LoginGuardOptions{LockBase: time.Minute, LockMax: 10 * time.Minute})
+	uncapped := NewLoginGuard(LoginGuardOptions{LockBase: time.Minute})
+	cases := []struct {
+		level    int64
+		capped   time.Duration
+		uncapped time.Duration
+	}{
+		{1, time.Minute, time.Minute},
+		{2, 2 * time.Minute, 2 * time.Minute},
+		{4, 8 * time.Minute, 8 * time.Minute},
+		{5, 10 * time.Minute, 16 * time.Minute},
+	}
+	for _, c := range cases {
+		if got := capped.lockDuration(c.level); got != c.capped {
+			t.Errorf("capped lockDuration(%d) = %v, want %v", c.level, got, c.capped)
+		}
+		if got := uncapped.lockDuration(c.level); got != c.uncapped {
+			t.Errorf("uncapped lockDuration(%d) = %v, want %v", c.level, got, c.uncapped)
+		}
+	}
+}
+
+// TestLoginLockoutAndAdminUnlock 锁定期间正确的密码也不能登录，管理员解锁后可以登录
+func TestLoginLockoutAndAdminUnlock(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestUserService(db, NewLoginGuard(LoginGuardOptions{MaxFailures: 2, Window: time.Hour, LockBase: time.Hour}))
+	hash, err := testHasher.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	user := createTestUserWithPassword(t, db, "lockout", hash, "")
+	const ip = "198.51.100.30"
+
+	if _, err := svc.Login("lockout", "wrong", ip); !errors.Is(err, ErrPasswordIncorrect) {
+		t.Fatalf("first failure err = %v, want ErrPasswordIncorrect", err)
+	}
+	assertLockedErr := func(err error) {
+		t.Helper()
+		assertLocked(t, err, ErrAccountLocked, time.Hour)
+	}
+	_, err = svc.Login("lockout", "wrong", ip)
+	assertLockedErr(err)
+	_, err = svc.Login("lockout", "secret", ip)
+	assertLockedErr(err)
+
+	if err := svc.UnlockLogin(SystemActor, user.ID); err != nil {
+		t.Fatalf("UnlockLogin: %v", err)
+	}
+	if _, err := svc.Login("lockout", "secret", ip); err != nil {
+		t.Fatalf("Login after unlock: %v", err)
+	}
+	if err := svc.UnlockLogin(SystemActor, 9999); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("UnlockLogin of an unknown user err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"library/config"
+	"library/mailer"
+	"library/model"
+	"library/repository/mysql"
+)
+
+// JobMailDelivery 发件箱投递任务名称
+const JobMailDelivery = "mail_delivery"
+
+// mailDeliveryLease 投递前占用邮件的时长，实例在投递中途退出时，邮件在该时间后由其他实例重新投递
+const mailDeliveryLease = 5 * time.Minute
+
+// mailRetryMax 投递失败后重试间隔的上限
+const mailRetryMax = time.Hour
+
+// MailServiceInterface 邮件投递服务接口
+type MailServiceInterface interface {
+	DeliverPending() (int, error)
+}
+
+type MailService struct {
+	mailOutboxRepo mysql.MailOutboxRepository
+	mailer         mailer.Mailer
+	uow            mysql.UnitOfWork
+}
+
+func NewMailService(mailOutboxRepo mysql.MailOutboxRepository, m mailer.Mailer, uow mysql.UnitOfWork) MailServiceInterface {
+	return &MailService{
+		mailOutboxRepo: mailOutboxRepo,
+		mailer:         m,
+		uow:            uow,
+	}
+}
+
+// mailData 邮件模板的数据
+type mailData struct {
+	Name          string // 称呼
+	Link          string // 操作链接
+	ExpireMinutes int    // 链接有效期（分钟）
+}
+
+// mailLang 将请求中的语言转换为支持的邮件语言，未指定或不支持时使用配置的默认语言
+func mailLang(lang string) string {
+	return mailer.NormalizeLang(lang, mailer.NormalizeLang(config.GlobalConfig.Mail.Lang, mailer.LangZH))
+}
+
+// enqueueMail 渲染模板并写入发件箱。与业务变更在同一事务中写入，业务回滚时邮件不会发出
+func enqueueMail(outboxRepo mysql.MailOutboxRepository, template, lang, to string, data interface{}) error {
+	msg, err := mailer.Render(template, mailLang(lang), data)
+	if err != nil {
+		return err
+	}
+	mail := &model.MailOutbox{
+		Template:  template,
+		Recipient: to,
+		Subject:   msg.Subject,
+		TextBody:  msg.Text,
+		HTMLBody:  msg.HTML,
+	}
+	if err := outboxRepo.Create(mail); err != nil {
+		return fmt.Errorf("enqueue mail: %w", err)
+	}
+	return nil
+}
+
+// DeliverPending 投递到期的待发邮件，返回发送成功的数量。
+// 先在事务中锁定并占用一批邮件（跳过其他实例已锁定的），提交后再逐封发送，避免发信期间长时间持有行锁。
+// 占用按条件更新，只发送本实例占用成功的邮件
+func (s *MailService) DeliverPending() (int, error) {
+	cfg := config.GlobalConfig.Mail
+	var due []*model.MailOutbox
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		now := time.Now()
+		locked, err := repos.MailOutbox.LockDue(now, cfg.BatchSize)
+		if err != nil {
+			return fmt.Errorf("lock due mails: %w", err)
+		}
+		for _, m := range locked {
+			claimed, err := repos.MailOutbox.Claim(m.ID, now, now.Add(mailDeliveryLease))
+			if err != nil {
+				return fmt.Errorf("claim mail %d: %w", m.ID, err)
+			}
+			if claimed {
+				due = append(due, m)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, m := range due {
+		sendErr := s.mailer.Send(&mailer.Message{
+			To:      m.Recipient,
+			Subject: m.Subject,
+			Text:    m.TextBody,
+			HTML:    m.HTMLBody,
+		})
+
+		m.Attempts++
+		now := time.Now()
+		if sendErr == nil {
+			m.Status = model.MailStatusSent
+			m.SentAt = &now
+			m.LastError = ""
+			sent++
+		} else {
+			log.Printf("mail: deliver %d to %s failed (attempt %d): %v", m.ID, m.Recipient, m.Attempts, sendErr)
+			m.LastError = truncate(sendErr.Error(), 512)
+			if m.Attempts >= cfg.MaxAttempts {
+				m.Status = model.MailStatusFailed
+			} else {
+				m.NextAttemptAt = now.Add(mailRetryDelay(m.Attempts))
+			}
+		}
+		if err := s.mailOutboxRepo.Update(m); err != nil {
+			return sent, fmt.Errorf("update mail %d: %w", m.ID, err)
+		}
+	}
+	return sent, nil
+}
+
+// mailRetryDelay 第attempts次失败后的重试间隔，从1分钟开始每次翻倍
+func mailRetryDelay(attempts int) time.Duration {
+	d := time.Minute
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= mailRetryMax {
+			return mailRetryMax
+		}
+	}
+	return d
+}
+
+// truncate 按字符截断字符串
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
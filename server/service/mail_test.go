@@ -0,0 +1,210 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"library/config"
+	"library/mailer"
+	"library/model"
+	"library/repository/mysql"
+)
+
+// flakyMailer 前 failures 次发送失败，之后发送成功
+type flakyMailer struct {
+	failures int
+	calls    int
+	sent     []mailer.Message
+}
+
+func (m *flakyMailer) Name() string {
+	return "flaky"
+}
+
+func (m *flakyMailer) Send(msg *mailer.Message) error {
+	m.calls++
+	if m.calls <= m.failures {
+		return errors.New("connection refused")
+	}
+	m.sent = append(m.sent, *msg)
+	return nil
+}
+
+func TestMailRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{6, 32 * time.Minute},
+		{7, mailRetryMax},
+		{50, mailRetryMax},
+	}
+	for _, tt := range tests {
+		if got := mailRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("mailRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// enqueueTestMail 写入一封待发邮件
+func enqueueTestMail(t *testing.T, db *gorm.DB, to string) *model.MailOutbox {
+	t.Helper()
+	repo := mysql.NewMailOutboxRepository(db)
+	if err := enqueueMail(repo, mailer.TemplateVerifyEmail, "en", to, mailData{Name: "reader", Link: "http://localhost/verify"}); err != nil {
+		t.Fatalf("enqueueMail: %v", err)
+	}
+	var mail model.MailOutbox
+	if err := db.Where("recipient = ?", to).Order("id DESC").First(&mail).Error; err != nil {
+		t.Fatalf("load mail: %v", err)
+	}
+	return &mail
+}
+
+// loadMail 读取邮件的最新状态
+func loadMail(t *testing.T, db *gorm.DB, id uint) *model.MailOutbox {
+	t.Helper()
+	var mail model.MailOutbox
+	if err := db.First(&mail, id).Error; err != nil {
+		t.Fatalf("load mail %d: %v", id, err)
+	}
+	return &mail
+}
+
+// makeDue 将邮件的下次尝试时间提前，模拟重试间隔已过
+func makeDue(t *testing.T, db *gorm.DB, id uint) {
+	t.Helper()
+	if err := db.Model(&model.MailOutbox{}).Where("id = ?", id).Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatalf("make mail %d due: %v", id, err)
+	}
+}
+
+func deliver(t *testing.T, svc MailServiceInterface) int {
+	t.Helper()
+	sent, err := svc.DeliverPending()
+	if err != nil {
+		t.Fatalf("DeliverPending: %v", err)
+	}
+	return sent
+}
+
+func TestDeliverPendingRetriesThenDeadLetters(t *testing.T) {
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.Mail.MaxAttempts = 3
+		cfg.Mail.BatchSize = 10
+	})
+	db := newTestDB(t)
+	m := &flakyMailer{failures: 100}
+	svc := NewMailService(mysql.NewMailOutboxRepository(db), m, mysql.NewUnitOfWork(db))
+	mail := enqueueTestMail(t, db, "reader@example.com")
+
+	before := time.Now()
+	if sent := deliver(t, svc); sent != 0 {
+		t.Fatalf("sent = %d, want 0", sent)
+	}
+	got := loadMail(t, db, mail.ID)
+	if got.Status != model.MailStatusPending || got.Attempts != 1 || got.LastError == "" {
+		t.Fatalf("after first failure: status %d, attempts %d, last error %q", got.Status, got.Attempts, got.LastError)
+	}
+	if wait := got.NextAttemptAt.Sub(before); wait < time.Minute-time.Second || wait > time.Minute+5*time.Second {
+		t.Fatalf("next attempt in %v, want about 1m", wait)
+	}
+
+	// 重试间隔未到时不投递
+	deliver(t, svc)
+	if m.calls != 1 {
+		t.Fatalf("mailer called %d times before the retry was due, want 1", m.calls)
+	}
+
+	makeDue(t, db, mail.ID)
+	before = time.Now()
+	deliver(t, svc)
+	got = loadMail(t, db, mail.ID)
+	if got.Status != model.MailStatusPending || got.Attempts != 2 {
+		t.Fatalf("after second failure: status %d, attempts %d", got.Status, got.Attempts)
+	}
+	if wait := got.NextAttemptAt.Sub(before); wait < 2*time.Minute-time.Second || wait > 2*time.Minute+5*time.Second {
+		t.Fatalf("next attempt in %v, want about 2m", wait)
+	}
+
+	// 达到最多投递次数后标记为发送失败，不再重试
+	makeDue(t, db, mail.ID)
+	deliver(t, svc)
+	got = loadMail(t, db, mail.ID)
+	if got.Status != model.MailStatusFailed || got.Attempts != 3 {
+		t.Fatalf("after last failure: status %d, attempts %d, want failed after 3", got.Status, got.Attempts)
+	}
+	makeDue(t, db, mail.ID)
+	deliver(t, svc)
+	if m.calls != 3 {
+		t.Fatalf("mailer called %d times, want 3", m.calls)
+	}
+}
+
+func TestDeliverPendingSendsAfterRetry(t *testing.T) {
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.Mail.MaxAttempts = 3
+		cfg.Mail.BatchSize = 10
+	})
+	db := newTestDB(t)
+	m := &flakyMailer{failures: 1}
+	svc := NewMailService(mysql.NewMailOutboxRepository(db), m, mysql.NewUnitOfWork(db))
+	mail := enqueueTestMail(t, db, "reader@example.com")
+
+	deliver(t, svc)
+	makeDue(t, db, mail.ID)
+	if sent := deliver(t, svc); sent != 1 {
+		t.Fatalf("sent = %d, want 1", sent)
+	}
+	got := loadMail(t, db, mail.ID)
+	if got.Status != model.MailStatusSent || got.SentAt == nil || got.Attempts != 2 || got.LastError != "" {
+		t.Fatalf("after retry: status %d, sent at %v, attempts %d, last error %q", got.Status, got.SentAt, got.Attempts, got.LastError)
+	}
+	if len(m.sent) != 1 || m.sent[0].To != "reader@example.com" || m.sent[0].Subject == "" {
+		t.Fatalf("sent messages = %+v", m.sent)
+	}
+
+	// 已发送的邮件不会再次投递
+	deliver(t, svc)
+	if m.calls != 2 {
+		t.Fatalf("mailer called %d times, want 2", m.calls)
+	}
+}
+
+func TestDeliverPendingSkipsClaimedMail(t *testing.T) {
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.Mail.MaxAttempts = 3
+		cfg.Mail.BatchSize = 10
+	})
+	db := newTestDB(t)
+	repo := mysql.NewMailOutboxRepository(db)
+	m := &flakyMailer{}
+	svc := NewMailService(repo, m, mysql.NewUnitOfWork(db))
+	claimed := enqueueTestMail(t, db, "first@example.com")
+	enqueueTestMail(t, db, "second@example.com")
+
+	// 另一实例已占用第一封邮件
+	now := time.Now()
+	ok, err := repo.Claim(claimed.ID, now, now.Add(mailDeliveryLease))
+	if err != nil || !ok {
+		t.Fatalf("Claim = %v, %v", ok, err)
+	}
+	if ok, err := repo.Claim(claimed.ID, now, now.Add(mailDeliveryLease)); err != nil || ok {
+		t.Fatalf("second Claim = %v, %v, want false", ok, err)
+	}
+
+	if sent := deliver(t, svc); sent != 1 {
+		t.Fatalf("sent = %d, want 1", sent)
+	}
+	if m.sent[0].To != "second@example.com" {
+		t.Fatalf("sent to %s, want second@example.com", m.sent[0].To)
+	}
+	if got := loadMail(t, db, claimed.ID); got.Status != model.MailStatusPending || got.Attempts != 0 {
+		t.Fatalf("claimed mail: status %d, attempts %d, want untouched", got.Status, got.Attempts)
+	}
+}
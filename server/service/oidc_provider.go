@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"library/config"
+)
+
+// oidcHTTPTimeout 访问身份提供方（发现文档、JWKS、令牌端点）的超时时间
+const oidcHTTPTimeout = 10 * time.Second
+
+// OIDCProvider 通用的 OpenID Connect 认证提供方，使用授权码+PKCE流程。
+// 端点和签名公钥从发行方的发现文档读取，首次使用时才访问身份提供方，启动时不依赖其可用
+type OIDCProvider struct {
+	cfg    config.OIDCProviderConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewOIDCProvider 创建OIDC认证提供方，client为nil时使用带超时的默认客户端。
+// 本地测试时可将 Issuer 指向模拟的身份提供方，并传入对应的 client
+func NewOIDCProvider(cfg config.OIDCProviderConfig, client *http.Client) *OIDCProvider {
+	if client == nil {
+		client = &http.Client{Timeout: oidcHTTPTimeout}
+	}
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "preferred_username"
+	}
+	if cfg.DisplayName == "" {
+		cfg.DisplayName = cfg.Name
+	}
+	return &OIDCProvider{cfg: cfg, client: client}
+}
+
+func (p *OIDCProvider) Name() string        { return p.cfg.Name }
+func (p *OIDCProvider) DisplayName() string { return p.cfg.DisplayName }
+func (p *OIDCProvider) Kind() string        { return ProviderKindRedirect }
+
+// AuthCodeURL 身份提供方的登录地址
+func (p *OIDCProvider) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	conf, _, err := p.discover(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return conf.AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.SetAuthURLParam("code_challenge", codeChallenge),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	), nil
+}
+
+// Exchange 用授权码换取令牌，校验ID令牌的签名、受众、有效期和nonce后返回用户信息
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*ExternalUser, error) {
+	conf, verifier, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = oidc.ClientContext(ctx, p.client)
+	token, err := conf.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		// 授权码无效、已使用或verifier不匹配
+		if _, ok := err.(*oauth2.RetrieveError); ok {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("%w: exchange code: %v", ErrProviderUnavailable, err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, fmt.Errorf("%w: no id_token in token response", ErrProviderUnavailable)
+	}
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if idToken.Nonce != nonce {
+		return nil, ErrInvalidToken
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: parse id_token claims: %v", ErrProviderUnavailable, err)
+	}
+	ext := &ExternalUser{
+		Subject:  idToken.Subject,
+		Email:    stringClaim(claims, "email"),
+		Username: stringClaim(claims, p.cfg.UsernameClaim),
+		Nickname: stringClaim(claims, "name"),
+	}
+	// 部分提供方将 email_verified 返回为字符串
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		ext.EmailVerified = v
+	case string:
+		ext.EmailVerified = v == "true"
+	}
+	return ext, nil
+}
+
+// discover 读取发现文档，成功后缓存；失败时下次调用重试
+func (p *OIDCProvider) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, p.client), p.cfg.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: discover %s: %v", ErrProviderUnavailable, p.cfg.Issuer, err)
+	}
+
+	scopes := []string{oidc.ScopeOpenID}
+	for _, s := range p.cfg.Scopes {
+		if s != oidc.ScopeOpenID {
+			scopes = append(scopes, s)
+		}
+	}
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       scopes,
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+	return p.oauth, p.verifier, nil
+}
+
+// stringClaim 读取字符串类型的声明，不存在或类型不符时返回空字符串
+func stringClaim(claims map[string]interface{}, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"library/config"
+	"library/model"
+	"library/password"
+	"library/repository/mysql"
+)
+
+const (
+	mockIdPKeyID    = "mock-key"
+	mockIdPClientID = "library"
+)
+
+// mockIdP 模拟的OpenID Connect身份提供方，提供发现文档、JWKS和令牌端点。
+// 测试调用 authorize 模拟用户在提供方登录并同意授权，得到回调的 state 和 code
+type mockIdP struct {
+	t   *testing.T
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]*mockAuthCode
+}
+
+// mockAuthCode 已签发的授权码
+type mockAuthCode struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	idp := &mockIdP{t: t, key: key, codes: make(map[string]*mockAuthCode)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/keys", idp.keys)
+	mux.HandleFunc("/token", idp.token)
+	idp.srv = httptest.NewServer(mux)
+	t.Cleanup(idp.srv.Close)
+	return idp
+}
+
+func (idp *mockIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                idp.srv.URL,
+		"authorization_endpoint":                idp.srv.URL + "/authorize",
+		"token_endpoint":                        idp.srv.URL + "/token",
+		"jwks_uri":                              idp.srv.URL + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (idp *mockIdP) keys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+		Key:       &idp.key.PublicKey,
+		KeyID:     mockIdPKeyID,
+		Algorithm: string(jose.RS256),
+		Use:       "sig",
+	}}})
+}
+
+// token 令牌端点：授权码只能使用一次，code_verifier 必须与授权请求的 code_challenge 匹配
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	idp.mu.Lock()
+	code, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, code.claims)
+	token.Header["kid"] = mockIdPKeyID
+	idToken, err := token.SignedString(idp.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+// authorize 模拟用户在提供方完成登录：解析登录地址中的参数，按 claims 签发授权码。
+// ID令牌默认包含正确的发行方、受众、有效期和nonce，modify 可修改这些声明
+func (idp *mockIdP) authorize(authURL string, claims jwt.MapClaims, modify func(claims jwt.MapClaims)) (state, code string) {
+	idp.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		idp.t.Fatalf("parse auth url: %v", err)
+	}
+	q := u.Query()
+	if q.Get("client_id") != mockIdPClientID || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		idp.t.Fatalf("unexpected auth url %s", authURL)
+	}
+
+	now := time.Now()
+	all := jwt.MapClaims{
+		"iss":   idp.srv.URL,
+		"aud":   mockIdPClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": q.Get("nonce"),
+	}
+	for k, v := range claims {
+		all[k] = v
+	}
+	if modify != nil {
+		modify(all)
+	}
+
+	code, err = randomToken()
+	if err != nil {
+		idp.t.Fatalf("generate code: %v", err)
+	}
+	idp.mu.Lock()
+	idp.codes[code] = &mockAuthCode{challenge: q.Get("code_challenge"), claims: all}
+	idp.mu.Unlock()
+	return q.Get("state"), code
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// newTestOIDCAuth 创建只配置了模拟身份提供方的认证服务
+func newTestOIDCAuth(t *testing.T, db *gorm.DB, idp *mockIdP, linkByEmail bool) AuthServiceInterface {
+	t.Helper()
+	cfg := config.OIDCProviderConfig{
+		Name:        "mock",
+		Issuer:      idp.srv.URL,
+		ClientID:    mockIdPClientID,
+		RedirectURL: "http://localhost:3000/sso/callback",
+		Scopes:      []string{"email", "profile"},
+		LinkByEmail: linkByEmail,
+	}
+	setTestConfig(t, func(c *config.Config) {
+		c.SSO = config.SSOConfig{StateExpire: 10, DefaultRole: "user", OIDC: []config.OIDCProviderConfig{cfg}}
+	})
+	hasher := password.MustNew(password.Options{Algorithm: password.Bcrypt, BcryptCost: 4})
+	return NewAuthService(mysql.NewUnitOfWork(db), hasher, NewOIDCProvider(cfg, idp.srv.Client()))
+}
+
+// oidcLogin 走完一次单点登录：发起登录、在模拟提供方授权、处理回调
+func oidcLogin(t *testing.T, auth AuthServiceInterface, idp *mockIdP, claims jwt.MapClaims, modify func(claims jwt.MapClaims)) (*model.User, error) {
+	t.Helper()
+	authURL, err := auth.StartLogin("mock")
+	if err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+	state, code := idp.authorize(authURL, claims, modify)
+	return auth.FinishLogin("mock", state, code)
+}
+
+func countUsers(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+	var n int64
+	if err := db.Model(&model.User{}).Count(&n).Error; err != nil {
+		t.Fatalf("count users: %v", err)
+	}
+	return n
+}
+
+func TestOIDCLoginLinksAccount(t *testing.T) {
+	db := newTestDB(t)
+	idp := newMockIdP(t)
+	auth := newTestOIDCAuth(t, db, idp, true)
+	alice := createTestUser(t, db, "alice")
+
+	// 首次登录按已验证的邮箱关联已有账号
+	user, err := oidcLogin(t, auth, idp, jwt.MapClaims{
+		"sub":                "sub-alice",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice.idp",
+	}, nil)
+	if err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if user.ID != alice.ID {
+		t.Fatalf("logged in as user %d, want linked user %d", user.ID, alice.ID)
+	}
+	identities, err := auth.ListIdentities(alice.ID)
+	if err != nil {
+		t.Fatalf("ListIdentities: %v", err)
+	}
+	if len(identities) != 1 || identities[0].Provider != "mock" || identities[0].Subject != "sub-alice" {
+		t.Fatalf("identities = %+v", identities)
+	}
+
+	// 再次登录按subject找到关联的账号，即使提供方中的邮箱已修改
+	user, err = oidcLogin(t, auth, idp, jwt.MapClaims{"sub": "sub-alice", "email": "alice@new.example.com", "email_verified": true}, nil)
+	if err != nil {
+		t.Fatalf("second FinishLogin: %v", err)
+	}
+	if user.ID != alice.ID {
+		t.Fatalf("second login as user %d, want %d", user.ID, alice.ID)
+	}
+
+	// 未验证的邮箱不能关联已有账号，创建新账号且不占用该邮箱
+	user, err = oidcLogin(t, auth, idp, jwt.MapClaims{
+		"sub":                "sub-mallory",
+		"email":              "alice@example.com",
+		"email_verified":     false,
+		"preferred_username": "mallory",
+	}, nil)
+	if err != nil {
+		t.Fatalf("unverified FinishLogin: %v", err)
+	}
+	if user.ID == alice.ID || user.Username != "mallory" || user.Email != "" {
+		t.Fatalf("unverified email login = user %d %q <%s>, want a new account without email", user.ID, user.Username, user.Email)
+	}
+
+	// 新用户首次登录自动创建账号
+	user, err = oidcLogin(t, auth, idp, jwt.MapClaims{
+		"sub":            "sub-bob",
+		"email":          "bob@example.com",
+		"email_verified": "true",
+		"name":           "Bob",
+	}, nil)
+	if err != nil {
+		t.Fatalf("new user FinishLogin: %v", err)
+	}
+	if user.Username != "bob" || user.Nickname != "Bob" || user.Role != "user" || user.EmailVerifiedAt == nil {
+		t.Fatalf("provisioned user = %+v", user)
+	}
+	if n := countUsers(t, db); n != 3 {
+		t.Fatalf("users = %d, want 3", n)
+	}
+}
+
+// TestOIDCProvisionWithoutEmail 提供方未返回邮箱的用户可以创建多个账号，邮箱保存为NULL
+func TestOIDCProvisionWithoutEmail(t *testing.T) {
+	db := newTestDB(t)
+	idp := newMockIdP(t)
+	auth := newTestOIDCAuth(t, db, idp, true)
+
+	for _, sub := range []string{"sub-carol", "sub-dave"} {
+		user, err := oidcLogin(t, auth, idp, jwt.MapClaims{"sub": sub, "preferred_username": sub[4:]}, nil)
+		if err != nil {
+			t.Fatalf("FinishLogin(%s): %v", sub, err)
+		}
+		if user.Email != "" || user.EmailVerifiedAt != nil {
+			t.Fatalf("provisioned user %q email = %q, verified at %v, want none", user.Username, user.Email, user.EmailVerifiedAt)
+		}
+	}
+
+	var n int64
+	if err := db.Model(&model.User{}).Where("email IS NULL").Count(&n).Error; err != nil {
+		t.Fatalf("count users: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("users with NULL email = %d, want 2", n)
+	}
+	user, err := mysql.NewUserRepository(db).GetByUsername("dave")
+	if err != nil || user == nil || user.Email != "" {
+		t.Fatalf("GetByUsername(dave) = %+v, %v", user, err)
+	}
+}
+
+func TestOIDCRejectsInvalidIDToken(t *testing.T) {
+	db := newTestDB(t)
+	idp := newMockIdP(t)
+	auth := newTestOIDCAuth(t, db, idp, true)
+	createTestUser(t, db, "alice")
+	claims := jwt.MapClaims{"sub": "sub-alice", "email": "alice@example.com", "email_verified": true}
+
+	tests := []struct {
+		name   string
+		modify func(claims jwt.MapClaims)
+	}{
+		{"bad nonce", func(c jwt.MapClaims) { c["nonce"] = "replayed-nonce" }},
+		{"missing nonce", func(c jwt.MapClaims) { delete(c, "nonce") }},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "another-client" }},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{"expired", func(c jwt.MapClaims) {
+			c["iat"] = time.Now().Add(-2 * time.Hour).Unix()
+			c["exp"] = time.Now().Add(-time.Hour).Unix()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := oidcLogin(t, auth, idp, claims, tt.modify); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("FinishLogin err = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+
+	var identities int64
+	if err := db.Model(&model.ExternalIdentity{}).Count(&identities).Error; err != nil {
+		t.Fatalf("count identities: %v", err)
+	}
+	if identities != 0 {
+		t.Fatalf("identities = %d, want 0 after rejected logins", identities)
+	}
+}
+
+func TestOIDCStateAndCodeSingleUse(t *testing.T) {
+	db := newTestDB(t)
+	idp := newMockIdP(t)
+	auth := newTestOIDCAuth(t, db, idp, false)
+
+	authURL, err := auth.StartLogin("mock")
+	if err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+	state, code := idp.authorize(authURL, jwt.MapClaims{"sub": "sub-carol", "preferred_username": "carol"}, nil)
+	if _, err := auth.FinishLogin("mock", state, code); err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if _, err := auth.FinishLogin("mock", state, code); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("replayed state err = %v, want ErrInvalidToken", err)
+	}
+
+	// 授权码与发起登录时的PKCE verifier绑定，换到另一次登录的state下不能使用
+	authURL, err = auth.StartLogin("mock")
+	if err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+	_, stolen := idp.authorize(authURL, jwt.MapClaims{"sub": "sub-carol"}, nil)
+	otherURL, err := auth.StartLogin("mock")
+	if err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+	otherState, _ := idp.authorize(otherURL, jwt.MapClaims{"sub": "sub-carol"}, nil)
+	if _, err := auth.FinishLogin("mock", otherState, stolen); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("code with another verifier err = %v, want ErrInvalidToken", err)
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"library/database"
+	"library/model"
+	"library/repository/mysql"
+)
+
+// JobOverdueScan 逾期扫描任务名称
+const JobOverdueScan = "overdue_scan"
+
+// overdueScanLockTTL 逾期扫描锁的过期时间，防止实例崩溃后锁无法释放
+const overdueScanLockTTL = 10 * time.Minute
+
+// OverdueServiceInterface 逾期处理服务接口
+type OverdueServiceInterface interface {
+	ScanOverdue() (*model.JobRun, error)
+	GetLastRun() (*model.JobRun, error)
+}
+
+type OverdueService struct {
+	borrowRepo     mysql.BorrowRepository
+	loanPolicyRepo mysql.LoanPolicyRepository
+	jobRunRepo     mysql.JobRunRepository
+	instance       string
+}
+
+func NewOverdueService(borrowRepo mysql.BorrowRepository, loanPolicyRepo mysql.LoanPolicyRepository, jobRunRepo mysql.JobRunRepository) OverdueServiceInterface {
+	hostname, _ := os.Hostname()
+	return &OverdueService{
+		borrowRepo:     borrowRepo,
+		loanPolicyRepo: loanPolicyRepo,
+		jobRunRepo:     jobRunRepo,
+		instance:       fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+// ScanOverdue 扫描已到期未归还的借阅，标记为已逾期并按借阅规则累计罚金。
+// 多实例部署时通过Redis锁保证同一时间只有一个实例执行。
+func (s *OverdueService) ScanOverdue() (*model.JobRun, error) {
+	release, ok, err := database.AcquireLock(context.Background(), "lock:job:"+JobOverdueScan, s.instance, overdueScanLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrJobLocked
+	}
+	defer release()
+
+	run := &model.JobRun{
+		Name:      JobOverdueScan,
+		Instance:  s.instance,
+		StartedAt: time.Now(),
+		Status:    1, // 执行中
+	}
+	if err := s.jobRunRepo.Create(run); err != nil {
+		return nil, fmt.Errorf("create job run: %w", err)
+	}
+
+	scanErr := s.scan(run)
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Status = 2 // 成功
+	if scanErr != nil {
+		run.Status = 3 // 失败
+		run.Error = scanErr.Error()
+	}
+	if err := s.jobRunRepo.Update(run); err != nil {
+		return run, fmt.Errorf("update job run: %w", err)
+	}
+	return run, scanErr
+}
+
+// scan 执行逾期扫描，统计结果写入run
+func (s *OverdueService) scan(run *model.JobRun) error {
+	borrows, err := s.borrowRepo.GetOverdueBorrows()
+	if err != nil {
+		return fmt.Errorf("get overdue borrows: %w", err)
+	}
+
+	now := time.Now()
+	for _, b := range borrows {
+		run.Scanned++
+
+		policy, err := resolveLoanPolicy(s.loanPolicyRepo, b.User.Role, b.Book.Category)
+		if err != nil {
+			return err
+		}
+		fine := calculateFine(policy, b.DueDate, now)
+		if b.Status == 3 && fine == b.Fine {
+			continue
+		}
+
+		marked, err := s.borrowRepo.MarkOverdue(b.ID, fine, now)
+		if err != nil {
+			return fmt.Errorf("mark borrow %d overdue: %w", b.ID, err)
+		}
+		if !marked {
+			continue // 读取后已归还或续借
+		}
+		if b.Status != 3 {
+			run.Affected++
+		}
+		if fine != b.Fine {
+			run.Updated++
+		}
+	}
+	return nil
+}
+
+// GetLastRun 获取最近一次逾期扫描的结果
+func (s *OverdueService) GetLastRun() (*model.JobRun, error) {
+	run, err := s.jobRunRepo.GetLatest(JobOverdueScan)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, ErrNotFound
+	}
+	return run, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"library/config"
+	"library/model"
+	"library/repository/mysql"
+)
+
+// racingBorrowRepository 读取逾期借阅后执行 afterRead，模拟扫描期间的续借或归还
+type racingBorrowRepository struct {
+	mysql.BorrowRepository
+	afterRead func()
+}
+
+func (r *racingBorrowRepository) GetOverdueBorrows() ([]*model.Borrow, error) {
+	borrows, err := r.BorrowRepository.GetOverdueBorrows()
+	if err == nil && r.afterRead != nil {
+		r.afterRead()
+	}
+	return borrows, err
+}
+
+// TestScanOverdueSkipsRenewedBorrows 扫描读取后续借或归还的借阅不会被标记为逾期
+func TestScanOverdueSkipsRenewedBorrows(t *testing.T) {
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.Borrow.DefaultPolicy = config.LoanPolicyConfig{LoanDays: 30, MaxRenewals: 1, DailyFine: 0.5}
+	})
+	db := newTestDB(t)
+	user := createTestUser(t, db, "reader")
+	now := time.Now()
+
+	newBorrow := func(isbn string) *model.Borrow {
+		book := createTestBook(t, db, isbn)
+		borrow := &model.Borrow{UserID: user.ID, BookID: book.ID, BorrowDate: now.AddDate(0, 0, -40), DueDate: now.AddDate(0, 0, -10), Status: 1}
+		if err := db.Create(borrow).Error; err != nil {
+			t.Fatalf("create borrow: %v", err)
+		}
+		return borrow
+	}
+	overdue := newBorrow("9787000000001")
+	renewed := newBorrow("9787000000002")
+	returned := newBorrow("9787000000003")
+
+	repo := &racingBorrowRepository{BorrowRepository: mysql.NewBorrowRepository(db), afterRead: func() {
+		if err := db.Model(renewed).Updates(map[string]interface{}{"due_date": now.AddDate(0, 0, 14), "fine": 0, "renew_count": 1}).Error; err != nil {
+			t.Errorf("renew borrow: %v", err)
+		}
+		if err := db.Model(returned).Updates(map[string]interface{}{"status": 2, "return_date": now}).Error; err != nil {
+			t.Errorf("return borrow: %v", err)
+		}
+	}}
+	svc := NewOverdueService(repo, mysql.NewLoanPolicyRepository(db), mysql.NewJobRunRepository(db))
+
+	run, err := svc.ScanOverdue()
+	if err != nil {
+		t.Fatalf("ScanOverdue: %v", err)
+	}
+	if run.Scanned != 3 || run.Affected != 1 || run.Updated != 1 {
+		t.Fatalf("run = scanned %d, affected %d, updated %d, want 3, 1, 1", run.Scanned, run.Affected, run.Updated)
+	}
+
+	for _, c := range []struct {
+		borrow *model.Borrow
+		status int
+		fine   float64
+	}{
+		{overdue, 3, 5},
+		{renewed, 1, 0},
+		{returned, 2, 0},
+	} {
+		var got model.Borrow
+		if err := db.First(&got, c.borrow.ID).Error; err != nil {
+			t.Fatalf("get borrow: %v", err)
+		}
+		if got.Status != c.status || got.Fine != c.fine {
+			t.Errorf("borrow %d = status %d, fine %v, want %d, %v", got.ID, got.Status, got.Fine, c.status, c.fine)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"library/model"
+	"library/repository/mysql"
+)
+
+// permissionCacheTTL 角色权限在进程内缓存的时间。本实例修改权限时立即失效，
+// 多实例部署时其他实例最多延迟该时间生效
+const permissionCacheTTL = 30 * time.Second
+
+// defaultRolePermissions 角色的默认权限，首次启动时写入数据库，之后由管理员维护
+var defaultRolePermissions = map[string][]string{
+	model.RoleLibrarian: {
+		model.PermBookWrite,
+		model.PermCopyRead,
+		model.PermBorrowRead,
+		model.PermBorrowCheckout,
+		model.PermBorrowCheckin,
+		model.PermReservationManage,
+		model.PermReviewModerate,
+		model.PermFineRead,
+	},
+}
+
+// RolePermissions 角色及其权限
+type RolePermissions struct {
+	Role        string   `json:"role"`        // 角色
+	Permissions []string `json:"permissions"` // 权限名称
+	Editable    bool     `json:"editable"`    // 是否可以修改，管理员固定拥有全部权限
+}
+
+// PermissionServiceInterface 权限服务接口
+type PermissionServiceInterface interface {
+	HasPermission(role, permission string) (bool, error)
+	GetRolePermissions(role string) ([]string, error)
+	ListRolePermissions() ([]*RolePermissions, error)
+	UpdateRolePermissions(actor Actor, role string, permissions []string) error
+	SeedDefaults() error
+}
+
+type permissionEntry struct {
+	permissions map[string]bool
+	loadedAt    time.Time
+}
+
+type PermissionService struct {
+	rolePermissionRepo mysql.RolePermissionRepository
+	uow                mysql.UnitOfWork
+
+	mu    sync.RWMutex
+	cache map[string]permissionEntry
+}
+
+func NewPermissionService(rolePermissionRepo mysql.RolePermissionRepository, uow mysql.UnitOfWork) PermissionServiceInterface {
+	return &PermissionService{
+		rolePermissionRepo: rolePermissionRepo,
+		uow:                uow,
+		cache:              make(map[string]permissionEntry),
+	}
+}
+
+// isValidRole 角色是否存在
+func isValidRole(role string) bool {
+	for _, r := range model.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission 角色是否拥有指定权限，管理员拥有全部权限
+func (s *PermissionService) HasPermission(role, permission string) (bool, error) {
+	if role == model.RoleAdmin {
+		return true, nil
+	}
+	if role == "" {
+		return false, nil
+	}
+
+	s.mu.RLock()
+	entry, ok := s.cache[role]
+	s.mu.RUnlock()
+	if ok && time.Since(entry.loadedAt) < permissionCacheTTL {
+		return entry.permissions[permission], nil
+	}
+
+	list, err := s.rolePermissionRepo.ListByRole(role)
+	if err != nil {
+		return false, fmt.Errorf("list permissions of role %s: %w", role, err)
+	}
+	entry = permissionEntry{permissions: make(map[string]bool, len(list)), loadedAt: time.Now()}
+	for _, p := range list {
+		entry.permissions[p] = true
+	}
+
+	s.mu.Lock()
+	s.cache[role] = entry
+	s.mu.Unlock()
+	return entry.permissions[permission], nil
+}
+
+// GetRolePermissions 获取角色拥有的权限
+func (s *PermissionService) GetRolePermissions(role string) ([]string, error) {
+	if !isValidRole(role) {
+		return nil, ErrNotFound
+	}
+	if role == model.RoleAdmin {
+		return allPermissions(), nil
+	}
+	list, err := s.rolePermissionRepo.ListByRole(role)
+	if err != nil {
+		return nil, fmt.Errorf("list permissions of role %s: %w", role, err)
+	}
+	return list, nil
+}
+
+// ListRolePermissions 获取全部角色及其权限
+func (s *PermissionService) ListRolePermissions() ([]*RolePermissions, error) {
+	rows, err := s.rolePermissionRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("list role permissions: %w", err)
+	}
+	byRole := make(map[string][]string)
+	for _, row := range rows {
+		byRole[row.Role] = append(byRole[row.Role], row.Permission)
+	}
+
+	result := make([]*RolePermissions, 0, len(model.Roles))
+	for _, role := range model.Roles {
+		item := &RolePermissions{Role: role, Permissions: byRole[role], Editable: role != model.RoleAdmin}
+		if role == model.RoleAdmin {
+			item.Permissions = allPermissions()
+		}
+		if item.Permissions == nil {
+			item.Permissions = []string{}
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// UpdateRolePermissions 替换角色的全部权限并记录审计日志。管理员角色固定拥有全部权限，不能修改
+func (s *PermissionService) UpdateRolePermissions(actor Actor, role string, permissions []string) error {
+	if !isValidRole(role) {
+		return ErrNotFound
+	}
+	if role == model.RoleAdmin {
+		return ErrPermissionDenied
+	}
+
+	seen := make(map[string]bool, len(permissions))
+	normalized := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		if _, ok := model.Permissions[p]; !ok {
+			return fmt.Errorf("%w: unknown permission %s", ErrInvalidParameter, p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			normalized = append(normalized, p)
+		}
+	}
+	sort.Strings(normalized)
+
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		before, err := repos.RolePermission.ListByRole(role)
+		if err != nil {
+			return fmt.Errorf("list permissions of role %s: %w", role, err)
+		}
+		if err := repos.RolePermission.ReplaceRole(role, normalized); err != nil {
+			return fmt.Errorf("replace permissions of role %s: %w", role, err)
+		}
+		return writeAudit(repos.AuditLog, actor, AuditRolePermissions, AuditTargetRole, 0, map[string]interface{}{
+			"role":   role,
+			"before": before,
+			"after":  normalized,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.cache, role)
+	s.mu.Unlock()
+	return nil
+}
+
+// SeedDefaults 为从未写入过默认权限的角色写入默认权限并记录，之后由管理员维护，
+// 管理员移除了角色的全部权限后重启也不会恢复。已有权限但没有记录的角色（记录引入之前配置的）只补充记录
+func (s *PermissionService) SeedDefaults() error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		for role, permissions := range defaultRolePermissions {
+			seeded, err := repos.RolePermission.IsSeeded(role)
+			if err != nil {
+				return fmt.Errorf("check seed of role %s: %w", role, err)
+			}
+			if seeded {
+				continue
+			}
+			count, err := repos.RolePermission.CountByRole(role)
+			if err != nil {
+				return fmt.Errorf("count permissions of role %s: %w", role, err)
+			}
+			if count == 0 {
+				if err := repos.RolePermission.ReplaceRole(role, permissions); err != nil {
+					return fmt.Errorf("seed permissions of role %s: %w", role, err)
+				}
+			}
+			if err := repos.RolePermission.MarkSeeded(role); err != nil {
+				return fmt.Errorf("mark role %s seeded: %w", role, err)
+			}
+		}
+		return nil
+	})
+}
+
+// allPermissions 全部权限名称，按名称排序
+func allPermissions() []string {
+	list := make([]string, 0, len(model.Permissions))
+	for p := range model.Permissions {
+		list = append(list, p)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"library/model"
+	"library/repository/mysql"
+)
+
+func newTestPermissionService(db *gorm.DB) PermissionServiceInterface {
+	return NewPermissionService(mysql.NewRolePermissionRepository(db), mysql.NewUnitOfWork(db))
+}
+
+// TestSeedDefaultsOnce 默认权限只写入一次，管理员移除全部权限后重启不会恢复
+func TestSeedDefaultsOnce(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestPermissionService(db)
+
+	if err := svc.SeedDefaults(); err != nil {
+		t.Fatalf("SeedDefaults: %v", err)
+	}
+	got, err := svc.GetRolePermissions(model.RoleLibrarian)
+	if err != nil {
+		t.Fatalf("GetRolePermissions: %v", err)
+	}
+	if len(got) != len(defaultRolePermissions[model.RoleLibrarian]) {
+		t.Fatalf("seeded permissions = %v, want the defaults", got)
+	}
+
+	if err := svc.UpdateRolePermissions(SystemActor, model.RoleLibrarian, nil); err != nil {
+		t.Fatalf("UpdateRolePermissions: %v", err)
+	}
+	// 模拟重启
+	if err := newTestPermissionService(db).SeedDefaults(); err != nil {
+		t.Fatalf("SeedDefaults after restart: %v", err)
+	}
+	if got, err := svc.GetRolePermissions(model.RoleLibrarian); err != nil || len(got) != 0 {
+		t.Fatalf("permissions after restart = %v, %v, want none", got, err)
+	}
+}
+
+// TestSeedDefaultsKeepsConfiguredRoles 记录引入之前已配置权限的角色只补充记录，权限保持不变
+func TestSeedDefaultsKeepsConfiguredRoles(t *testing.T) {
+	db := newTestDB(t)
+	repo := mysql.NewRolePermissionRepository(db)
+	if err := repo.ReplaceRole(model.RoleLibrarian, []string{model.PermCopyRead}); err != nil {
+		t.Fatalf("ReplaceRole: %v", err)
+	}
+
+	if err := newTestPermissionService(db).SeedDefaults(); err != nil {
+		t.Fatalf("SeedDefaults: %v", err)
+	}
+	got, err := repo.ListByRole(model.RoleLibrarian)
+	if err != nil {
+		t.Fatalf("ListByRole: %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{model.PermCopyRead}) {
+		t.Fatalf("permissions = %v, want [%s]", got, model.PermCopyRead)
+	}
+	if seeded, err := repo.IsSeeded(model.RoleLibrarian); err != nil || !seeded {
+		t.Fatalf("IsSeeded = %v, %v, want true", seeded, err)
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestPermissionService(db)
+	if err := svc.SeedDefaults(); err != nil {
+		t.Fatalf("SeedDefaults: %v", err)
+	}
+
+	cases := []struct {
+		role       string
+		permission string
+		want       bool
+	}{
+		{model.RoleAdmin, model.PermAuditRead, true},
+		{model.RoleLibrarian, model.PermBookWrite, true},
+		{model.RoleLibrarian, model.PermFineManage, false},
+		{model.RoleUser, model.PermCopyRead, false},
+		{"", model.PermCopyRead, false},
+		{"ghost", model.PermCopyRead, false},
+	}
+	for _, c := range cases {
+		got, err := svc.HasPermission(c.role, c.permission)
+		if err != nil || got != c.want {
+			t.Errorf("HasPermission(%q, %q) = %v, %v, want %v", c.role, c.permission, got, err, c.want)
+		}
+	}
+}
+
+// TestPermissionCacheInvalidation 本实例修改权限后缓存立即失效，其他实例的修改在缓存过期后生效
+func TestPermissionCacheInvalidation(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestPermissionService(db)
+	if err := svc.SeedDefaults(); err != nil {
+		t.Fatalf("SeedDefaults: %v", err)
+	}
+	has := func(permission string) bool {
+		t.Helper()
+		ok, err := svc.HasPermission(model.RoleLibrarian, permission)
+		if err != nil {
+			t.Fatalf("HasPermission: %v", err)
+		}
+		return ok
+	}
+
+	if !has(model.PermBookWrite) || has(model.PermFineManage) {
+		t.Fatalf("default librarian permissions not loaded")
+	}
+	if err := svc.UpdateRolePermissions(SystemActor, model.RoleLibrarian, []string{model.PermFineManage, model.PermFineManage}); err != nil {
+		t.Fatalf("UpdateRolePermissions: %v", err)
+	}
+	if has(model.PermBookWrite) || !has(model.PermFineManage) {
+		t.Fatalf("cached permissions not invalidated after update")
+	}
+
+	// 直接修改数据库（相当于其他实例修改），缓存期内仍使用缓存的权限
+	if err := mysql.NewRolePermissionRepository(db).ReplaceRole(model.RoleLibrarian, []string{model.PermBookWrite}); err != nil {
+		t.Fatalf("ReplaceRole: %v", err)
+	}
+	if has(model.PermBookWrite) || !has(model.PermFineManage) {
+		t.Fatalf("permissions reloaded before the cache expired")
+	}
+	svc.(*PermissionService).cache[model.RoleLibrarian] = permissionEntry{}
+	if !has(model.PermBookWrite) || has(model.PermFineManage) {
+		t.Fatalf("permissions not reloaded after the cache expired")
+	}
+}
+
+func TestUpdateRolePermissionsRejects(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestPermissionService(db)
+
+	cases := []struct {
+		role        string
+		permissions []string
+		err         error
+	}{
+		{model.RoleAdmin, []string{model.PermCopyRead}, ErrPermissionDenied},
+		{"ghost", []string{model.PermCopyRead}, ErrNotFound},
+		{model.RoleLibrarian, []string{"book:burn"}, ErrInvalidParameter},
+	}
+	for _, c := range cases {
+		if err := svc.UpdateRolePermissions(SystemActor, c.role, c.permissions); !errors.Is(err, c.err) {
+			t.Errorf("UpdateRolePermissions(%q, %v) err = %v, want %v", c.role, c.permissions, err, c.err)
+		}
+	}
+}
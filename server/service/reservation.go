@@ -0,0 +1,331 @@
+package service
+
+import (
+	"time"
+
+	"library/config"
+	"library/model"
+	"library/repository/mysql"
+)
+
+// JobHoldExpiry 预约保留过期处理任务名称
+const JobHoldExpiry = "hold_expiry"
+
+// ReservationServiceInterface 预约服务接口
+type ReservationServiceInterface interface {
+	CreateReservation(actor Actor, userID, bookID uint) (*model.Reservation, error)
+	CancelReservation(actor Actor, id uint, userID uint, isAdmin bool) error
+	GetReservation(id uint) (*model.Reservation, error)
+	GetUserReservations(userID uint, status int) ([]*model.Reservation, error)
+	GetBookQueue(bookID uint) ([]*model.Reservation, error)
+	ProcessExpiredReservations() error
+}
+
+type ReservationService struct {
+	reservationRepo mysql.ReservationRepository
+	borrowRepo      mysql.BorrowRepository
+	bookRepo        mysql.BookRepository
+	userRepo        mysql.UserRepository
+	copyRepo        mysql.CopyRepository
+	auditLogRepo    mysql.AuditLogRepository
+	uow             mysql.UnitOfWork
+}
+
+func NewReservationService(reservationRepo mysql.ReservationRepository, borrowRepo mysql.BorrowRepository, bookRepo mysql.BookRepository, userRepo mysql.UserRepository, copyRepo mysql.CopyRepository, auditLogRepo mysql.AuditLogRepository, uow mysql.UnitOfWork) ReservationServiceInterface {
+	return &ReservationService{
+		reservationRepo: reservationRepo,
+		borrowRepo:      borrowRepo,
+		bookRepo:        bookRepo,
+		userRepo:        userRepo,
+		copyRepo:        copyRepo,
+		auditLogRepo:    auditLogRepo,
+		uow:             uow,
+	}
+}
+
+// withRepos 返回使用工作单元仓库的服务副本
+func (s *ReservationService) withRepos(repos *mysql.Repositories) *ReservationService {
+	return &ReservationService{
+		reservationRepo: repos.Reservation,
+		borrowRepo:      repos.Borrow,
+		bookRepo:        repos.Book,
+		userRepo:        repos.User,
+		copyRepo:        repos.Copy,
+		auditLogRepo:    repos.AuditLog,
+		uow:             s.uow,
+	}
+}
+
+// withBookLock 在事务中锁定图书行后执行fn，与同一本书的借还操作串行
+func (s *ReservationService) withBookLock(bookID uint, fn func(txs *ReservationService) error) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		txs := s.withRepos(repos)
+		if _, err := txs.bookRepo.LockByID(bookID); err != nil {
+			return err
+		}
+		return fn(txs)
+	})
+}
+
+// CreateReservation 预约图书（仅在图书没有可借副本时允许排队）
+func (s *ReservationService) CreateReservation(actor Actor, userID, bookID uint) (*model.Reservation, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrNotFound
+	}
+
+	var reservation *model.Reservation
+	err = s.withBookLock(bookID, func(txs *ReservationService) error {
+		var err error
+		reservation, err = txs.createReservationTx(userID, bookID)
+		if err != nil {
+			return err
+		}
+		return writeAuditChange(txs.auditLogRepo, actor, AuditReservationCreate, AuditTargetReservation, reservation.ID, nil, reservation, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// createReservationTx 在已锁定图书行的事务中创建预约
+func (s *ReservationService) createReservationTx(userID, bookID uint) (*model.Reservation, error) {
+	// 先处理过期的保留，使库存和队列保持最新
+	if err := processHolds(s.reservationRepo, s.bookRepo, s.copyRepo, bookID); err != nil {
+		return nil, err
+	}
+
+	book, err := s.bookRepo.GetByID(bookID)
+	if err != nil {
+		return nil, err
+	}
+	if book == nil {
+		return nil, ErrNotFound
+	}
+	if book.Status != 1 {
+		return nil, ErrBookNotAvailable
+	}
+	if book.Available > 0 {
+		return nil, ErrBookAvailable
+	}
+
+	// 同一本书只能有一个有效预约
+	exist, err := s.reservationRepo.GetActiveByUserAndBook(userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	if exist != nil {
+		return nil, ErrAlreadyExists
+	}
+
+	// 正在借阅该书的用户无需预约
+	borrows, err := s.borrowRepo.GetUserActiveBorrows(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range borrows {
+		if b.BookID == bookID {
+			return nil, ErrAlreadyExists
+		}
+	}
+
+	reservation := &model.Reservation{
+		UserID: userID,
+		BookID: bookID,
+		Status: 1, // 排队中
+	}
+	if err := s.reservationRepo.Create(reservation); err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// CancelReservation 取消预约，已为读者保留的副本会转给队列中的下一位
+func (s *ReservationService) CancelReservation(actor Actor, id uint, userID uint, isAdmin bool) error {
+	current, err := s.reservationRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return ErrNotFound
+	}
+	if !isAdmin && current.UserID != userID {
+		return ErrPermissionDenied
+	}
+
+	return s.withBookLock(current.BookID, func(txs *ReservationService) error {
+		return txs.cancelReservationTx(actor, id)
+	})
+}
+
+// cancelReservationTx 在已锁定图书行的事务中取消预约
+func (s *ReservationService) cancelReservationTx(actor Actor, id uint) error {
+	reservation, err := s.reservationRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if reservation == nil {
+		return ErrNotFound
+	}
+	if reservation.Status != 1 && reservation.Status != 2 {
+		return ErrInvalidStatus
+	}
+
+	before := *reservation
+	wasReady := reservation.Status == 2
+	now := time.Now()
+	reservation.Status = 4 // 已取消
+	reservation.FinishedAt = &now
+	if err := s.reservationRepo.Update(reservation); err != nil {
+		return err
+	}
+	if err := writeAuditChange(s.auditLogRepo, actor, AuditReservationCancel, AuditTargetReservation, reservation.ID, &before, reservation, nil); err != nil {
+		return err
+	}
+
+	if !wasReady {
+		return nil
+	}
+
+	// 释放保留的副本，转给队列中的下一位
+	if err := releaseHeldCopy(s.copyRepo, reservation); err != nil {
+		return err
+	}
+	return processHolds(s.reservationRepo, s.bookRepo, s.copyRepo, reservation.BookID)
+}
+
+// GetReservation 获取预约
+func (s *ReservationService) GetReservation(id uint) (*model.Reservation, error) {
+	reservation, err := s.reservationRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if reservation == nil {
+		return nil, ErrNotFound
+	}
+	return reservation, nil
+}
+
+// GetUserReservations 获取用户的预约记录
+func (s *ReservationService) GetUserReservations(userID uint, status int) ([]*model.Reservation, error) {
+	return s.reservationRepo.GetUserReservations(userID, status)
+}
+
+// GetBookQueue 获取图书的预约队列
+func (s *ReservationService) GetBookQueue(bookID uint) ([]*model.Reservation, error) {
+	err := s.withBookLock(bookID, func(txs *ReservationService) error {
+		return processHolds(txs.reservationRepo, txs.bookRepo, txs.copyRepo, bookID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.reservationRepo.GetBookQueue(bookID)
+}
+
+// ProcessExpiredReservations 处理所有超过取书期限的预约
+func (s *ReservationService) ProcessExpiredReservations() error {
+	expired, err := s.reservationRepo.GetExpiredReady(0, time.Now())
+	if err != nil {
+		return err
+	}
+
+	processed := make(map[uint]bool)
+	for _, r := range expired {
+		if processed[r.BookID] {
+			continue
+		}
+		processed[r.BookID] = true
+		bookID := r.BookID
+		err := s.withBookLock(bookID, func(txs *ReservationService) error {
+			return processHolds(txs.reservationRepo, txs.bookRepo, txs.copyRepo, bookID)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// processHolds 维护图书的预约保留架：
+// 先将超过取书期限的预约置为过期并释放保留的副本，再把在架副本按先进先出分配给排队中的读者，
+// 最后根据副本重新统计图书的可借数量。调用方应在事务中先锁定图书行。
+func processHolds(reservationRepo mysql.ReservationRepository, bookRepo mysql.BookRepository, copyRepo mysql.CopyRepository, bookID uint) error {
+	book, err := bookRepo.GetByID(bookID)
+	if err != nil {
+		return err
+	}
+	if book == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	expired, err := reservationRepo.GetExpiredReady(bookID, now)
+	if err != nil {
+		return err
+	}
+	for _, r := range expired {
+		r.Status = 5 // 已过期
+		r.FinishedAt = &now
+		if err := reservationRepo.Update(r); err != nil {
+			return err
+		}
+		if err := releaseHeldCopy(copyRepo, r); err != nil {
+			return err
+		}
+	}
+
+	for {
+		item, err := copyRepo.GetFirstAvailable(bookID)
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			break
+		}
+		next, err := reservationRepo.GetFirstWaiting(bookID)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			break
+		}
+
+		claimed, err := copyRepo.UpdateStatusIf(item.ID, 1, 3) // 预约保留
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			return ErrBookNotAvailable
+		}
+		expireAt := now.AddDate(0, 0, config.GlobalConfig.Borrow.HoldPickupDays)
+		next.Status = 2 // 待取书
+		next.CopyID = &item.ID
+		next.ReadyAt = &now
+		next.ExpireAt = &expireAt
+		if err := reservationRepo.Update(next); err != nil {
+			return err
+		}
+	}
+
+	return bookRepo.SyncStock(bookID)
+}
+
+// releaseHeldCopy 将为预约保留的副本放回书架
+func releaseHeldCopy(copyRepo mysql.CopyRepository, reservation *model.Reservation) error {
+	if reservation.CopyID == nil {
+		return nil
+	}
+	item, err := copyRepo.GetByID(*reservation.CopyID)
+	if err != nil {
+		return err
+	}
+	if item == nil || item.Status != 3 {
+		return nil
+	}
+	return copyRepo.UpdateStatus(item.ID, 1) // 在架
+}
@@ -7,9 +7,10 @@ import (
 
 // ReviewServiceInterface 评论服务接口
 type ReviewServiceInterface interface {
-	CreateReview(review *model.Review) error
-	UpdateReview(review *model.Review) error
-	DeleteReview(id uint, userID uint) error
+	CreateReview(actor Actor, review *model.Review) error
+	UpdateReview(actor Actor, review *model.Review) error
+	DeleteReview(actor Actor, id uint, userID uint) error
+	UpdateReviewStatus(actor Actor, id uint, status int) error
 	GetReview(id uint) (*model.Review, error)
 	ListReviews(params *model.SearchParams) ([]*model.Review, int64, error)
 	GetBookReviews(bookID uint, params *model.SearchParams) ([]*model.Review, int64, error)
@@ -21,84 +22,122 @@ type ReviewService struct {
 	reviewRepo mysql.ReviewRepository
 	bookRepo   mysql.BookRepository
 	userRepo   mysql.UserRepository
+	uow        mysql.UnitOfWork
 }
 
-func NewReviewService(reviewRepo mysql.ReviewRepository, bookRepo mysql.BookRepository, userRepo mysql.UserRepository) ReviewServiceInterface {
+func NewReviewService(reviewRepo mysql.ReviewRepository, bookRepo mysql.BookRepository, userRepo mysql.UserRepository, uow mysql.UnitOfWork) ReviewServiceInterface {
 	return &ReviewService{
 		reviewRepo: reviewRepo,
 		bookRepo:   bookRepo,	
 		userRepo:   userRepo,
+		uow:        uow,
 	}
 }
 
 // CreateReview 创建评论
-func (s *ReviewService) CreateReview(review *model.Review) error {
-	// 检查用户是否存在
-	user, err := s.userRepo.GetByID( review.UserID)
-	if err != nil {
-		return err
-	}
-	if user == nil {
-		return ErrNotFound
-	}
-
-	// 检查图书是否存在
-	book, err := s.bookRepo.GetByID( review.BookID)
-	if err != nil {
-		return err
-	}
-	if book == nil {
-		return ErrNotFound
-	}
-
+func (s *ReviewService) CreateReview(actor Actor, review *model.Review) error {
 	// 验证评分范围
 	if review.Rating < 1 || review.Rating > 5 {
 		return ErrInvalidParameter
 	}
 
-	review.Status = 1 // 默认显示
-	return s.reviewRepo.Create( review)
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		// 检查用户是否存在
+		user, err := repos.User.GetByID(review.UserID)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return ErrNotFound
+		}
+
+		// 检查图书是否存在
+		book, err := repos.Book.GetByID(review.BookID)
+		if err != nil {
+			return err
+		}
+		if book == nil {
+			return ErrNotFound
+		}
+
+		review.Status = 1 // 默认显示
+		if err := repos.Review.Create(review); err != nil {
+			return err
+		}
+		return writeAuditChange(repos.AuditLog, actor, AuditReviewCreate, AuditTargetReview, review.ID, nil, review, nil)
+	})
 }
 
 // UpdateReview 更新评论
-func (s *ReviewService) UpdateReview(review *model.Review) error {
-	existReview, err := s.reviewRepo.GetByID( review.ID)
-	if err != nil {
-		return err
-	}
-	if existReview == nil {
-		return ErrNotFound
-	}
-
-	// 只允许更新自己的评论
-	if existReview.UserID != review.UserID {
-		return ErrPermissionDenied
-	}
-
+func (s *ReviewService) UpdateReview(actor Actor, review *model.Review) error {
 	// 验证评分范围
 	if review.Rating < 1 || review.Rating > 5 {
 		return ErrInvalidParameter
 	}
 
-	return s.reviewRepo.Update( review)
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		existReview, err := repos.Review.GetByID(review.ID)
+		if err != nil {
+			return err
+		}
+		if existReview == nil {
+			return ErrNotFound
+		}
+
+		// 只允许更新自己的评论
+		if existReview.UserID != review.UserID {
+			return ErrPermissionDenied
+		}
+
+		if err := repos.Review.Update(review); err != nil {
+			return err
+		}
+		return writeAuditChange(repos.AuditLog, actor, AuditReviewUpdate, AuditTargetReview, review.ID, existReview, review, nil)
+	})
 }
 
 // DeleteReview 删除评论
-func (s *ReviewService) DeleteReview(id uint, userID uint) error {
-	review, err := s.reviewRepo.GetByID( id)
-	if err != nil {
-		return err
-	}
-	if review == nil {
-		return ErrNotFound
-	}
-
-	// 只允许删除自己的评论
-	if review.UserID != userID {
-		return ErrPermissionDenied
-	}
+func (s *ReviewService) DeleteReview(actor Actor, id uint, userID uint) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		review, err := repos.Review.GetByID(id)
+		if err != nil {
+			return err
+		}
+		if review == nil {
+			return ErrNotFound
+		}
+
+		// 只允许删除自己的评论
+		if review.UserID != userID {
+			return ErrPermissionDenied
+		}
+
+		if err := repos.Review.Delete(id); err != nil {
+			return err
+		}
+		return writeAuditChange(repos.AuditLog, actor, AuditReviewDelete, AuditTargetReview, id, review, nil, nil)
+	})
+}
 
-	return s.reviewRepo.Delete( id)
+// UpdateReviewStatus 隐藏或显示评论
+func (s *ReviewService) UpdateReviewStatus(actor Actor, id uint, status int) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		review, err := repos.Review.GetByID(id)
+		if err != nil {
+			return err
+		}
+		if review == nil {
+			return ErrNotFound
+		}
+
+		from := review.Status
+		review.Status = status
+		if err := repos.Review.Update(review); err != nil {
+			return err
+		}
+		return writeAuditChange(repos.AuditLog, actor, AuditReviewStatus, AuditTargetReview, id,
+			map[string]int{"status": from}, map[string]int{"status": status}, nil)
+	})
 }
 
 // GetReview 获取评论
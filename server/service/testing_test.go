@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mysqldriver "gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"library/config"
+	"library/database"
+	"library/model"
+)
+
+// testMySQLDSNEnv 设置后测试连接该MySQL库（需为空库，会执行迁移并写入数据），否则使用临时的SQLite文件。
+// SQLite不支持 SELECT ... FOR UPDATE，并发测试依靠写事务串行执行
+const testMySQLDSNEnv = "LIBRARY_TEST_MYSQL_DSN"
+
+// newTestDB 创建迁移完成的测试数据库
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	cfg := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+
+	var db *gorm.DB
+	var err error
+	if dsn := os.Getenv(testMySQLDSNEnv); dsn != "" {
+		db, err = gorm.Open(mysqldriver.Open(dsn), cfg)
+	} else {
+		// 写事务以 BEGIN IMMEDIATE 开始，并发事务排队等待而不是在升级写锁时报错
+		path := filepath.Join(t.TempDir(), "library.db")
+		db, err = gorm.Open(sqlite.Open(path+"?_journal_mode=WAL&_busy_timeout=10000&_txlock=immediate"), cfg)
+	}
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// createTestUser 创建一个启用的普通用户
+func createTestUser(t *testing.T, db *gorm.DB, username string) *model.User {
+	t.Helper()
+	user := &model.User{
+		Username: username,
+		Password: "-",
+		Email:    fmt.Sprintf("%s@example.com", username),
+		Role:     "user",
+		Status:   1,
+	}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return user
+}
+
+// createTestBook 创建一本上架的图书，不含副本
+func createTestBook(t *testing.T, db *gorm.DB, isbn string) *model.Book {
+	t.Helper()
+	book := &model.Book{
+		ISBN:   isbn,
+		Title:  "Book " + isbn,
+		Author: "Author",
+		Status: 1,
+	}
+	if err := db.Create(book).Error; err != nil {
+		t.Fatalf("create book %s: %v", isbn, err)
+	}
+	return book
+}
+
+// setTestConfig 修改全局配置，测试结束后恢复
+func setTestConfig(t *testing.T, fn func(cfg *config.Config)) {
+	t.Helper()
+	saved := config.GlobalConfig
+	t.Cleanup(func() { config.GlobalConfig = saved })
+	fn(&config.GlobalConfig)
+}
@@ -0,0 +1,511 @@
+package service
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+
+	"library/config"
+	"library/model"
+	"library/repository/mysql"
+)
+
+// 登录时对双因素认证的要求
+const (
+	TwoFactorNone   = ""       // 无需双因素认证
+	TwoFactorVerify = "verify" // 已启用，需提交验证码
+	TwoFactorEnroll = "enroll" // 角色要求启用但尚未启用，需先绑定验证器
+)
+
+const (
+	totpPeriod        = 30 // 时间步长（秒）
+	totpSkew          = 1  // 允许前后各偏差一个时间步
+	recoveryCodeCount = 10 // 每次生成的恢复码数量
+	qrCodeSize        = 256
+)
+
+// LoginFailBadCode 登录第二步验证码错误
+const LoginFailBadCode = "bad_code"
+
+// TwoFactorSetup 绑定验证器时返回给客户端的信息，只在确认启用前返回
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`  // Base32编码的密钥，无法扫码时手动输入
+	URI    string `json:"uri"`     // otpauth:// 格式的配置URI
+	QRCode string `json:"qr_code"` // 配置URI的二维码（PNG data URI）
+}
+
+// TwoFactorStatus 用户的双因素认证状态
+type TwoFactorStatus struct {
+	Enabled       bool       `json:"enabled"`        // 是否已启用
+	EnabledAt     *time.Time `json:"enabled_at"`     // 启用时间
+	Required      bool       `json:"required"`       // 角色是否要求启用
+	RecoveryCodes int64      `json:"recovery_codes"` // 剩余可用的恢复码数量
+}
+
+type TwoFactorServiceInterface interface {
+	Requirement(user *model.User) string
+	Status(userID uint) (*TwoFactorStatus, error)
+	Setup(userID uint) (*TwoFactorSetup, error)
+	Enable(userID uint, code string) ([]string, error)
+	Disable(userID uint, code string) error
+	RegenerateRecoveryCodes(userID uint, code string) ([]string, error)
+	VerifyLogin(userID uint, code, ip string) (*model.User, error)
+	Reset(actor Actor, userID uint) error
+}
+
+type twoFactorService struct {
+	userRepo mysql.UserRepository
+	uow      mysql.UnitOfWork
+	guard    *LoginGuard
+}
+
+func NewTwoFactorService(userRepo mysql.UserRepository, uow mysql.UnitOfWork, guard *LoginGuard) TwoFactorServiceInterface {
+	return &twoFactorService{
+		userRepo: userRepo,
+		uow:      uow,
+		guard:    guard,
+	}
+}
+
+// Requirement 登录时对该用户的双因素认证要求
+func (s *twoFactorService) Requirement(user *model.User) string {
+	return twoFactorRequirement(user)
+}
+
+// twoFactorRequirement 已启用的账号需提交验证码，角色要求启用但尚未启用的账号需先绑定
+func twoFactorRequirement(user *model.User) string {
+	if user.TOTPEnabledAt != nil {
+		return TwoFactorVerify
+	}
+	if twoFactorRequired(user.Role) {
+		return TwoFactorEnroll
+	}
+	return TwoFactorNone
+}
+
+// twoFactorRequired 角色是否必须启用双因素认证
+func twoFactorRequired(role string) bool {
+	for _, r := range config.GlobalConfig.TwoFactor.RequiredRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Status 查询双因素认证状态
+func (s *twoFactorService) Status(userID uint) (*TwoFactorStatus, error) {
+	status := new(TwoFactorStatus)
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		user, err := repos.User.GetByID(userID)
+		if err != nil {
+			return fmt.Errorf("get user by id: %w", err)
+		}
+		if user == nil {
+			return ErrNotFound
+		}
+		status.Enabled = user.TOTPEnabledAt != nil
+		status.EnabledAt = user.TOTPEnabledAt
+		status.Required = twoFactorRequired(user.Role)
+		if status.Enabled {
+			status.RecoveryCodes, err = repos.RecoveryCode.CountUnused(userID)
+			if err != nil {
+				return fmt.Errorf("count recovery codes: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Setup 生成新的TOTP密钥并保存为待确认状态，提交验证码调用 Enable 后才生效。
+// 已启用时返回 ErrInvalidStatus，需先关闭再重新绑定；账号已禁用时返回 ErrAccountDisabled
+func (s *twoFactorService) Setup(userID uint) (*TwoFactorSetup, error) {
+	var setup *TwoFactorSetup
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		user, err := repos.User.LockByID(userID)
+		if err != nil {
+			return fmt.Errorf("lock user: %w", err)
+		}
+		if user == nil {
+			return ErrNotFound
+		}
+		if user.Status != 1 {
+			return ErrAccountDisabled
+		}
+		if user.TOTPEnabledAt != nil {
+			return ErrInvalidStatus
+		}
+
+		key, err := totp.Generate(totp.GenerateOpts{
+			Issuer:      config.GlobalConfig.TwoFactor.Issuer,
+			AccountName: user.Username,
+			Period:      totpPeriod,
+		})
+		if err != nil {
+			return fmt.Errorf("generate totp key: %w", err)
+		}
+		encrypted, err := encryptTOTPSecret(key.Secret())
+		if err != nil {
+			return err
+		}
+		if err := repos.User.UpdateTOTP(user.ID, encrypted, nil, 0); err != nil {
+			return fmt.Errorf("save totp secret: %w", err)
+		}
+
+		qr, err := qrCodeDataURI(key)
+		if err != nil {
+			return err
+		}
+		setup = &TwoFactorSetup{Secret: key.Secret(), URI: key.URL(), QRCode: qr}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return setup, nil
+}
+
+// Enable 用验证码确认待启用的密钥，启用后返回一组新的恢复码（明文只返回这一次）。账号已禁用时返回 ErrAccountDisabled
+func (s *twoFactorService) Enable(userID uint, code string) ([]string, error) {
+	var codes []string
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		user, err := repos.User.LockByID(userID)
+		if err != nil {
+			return fmt.Errorf("lock user: %w", err)
+		}
+		if user == nil {
+			return ErrNotFound
+		}
+		// 登录时绑定验证器后直接完成登录，与 VerifyLogin 一样拒绝已禁用的账号
+		if user.Status != 1 {
+			return ErrAccountDisabled
+		}
+		if user.TOTPEnabledAt != nil || user.TOTPSecret == "" {
+			return ErrInvalidStatus
+		}
+
+		step, err := validateTOTP(user, code)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := repos.User.UpdateTOTP(user.ID, user.TOTPSecret, &now, step); err != nil {
+			return fmt.Errorf("enable totp: %w", err)
+		}
+		codes, err = replaceRecoveryCodes(repos.RecoveryCode, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable 用验证码或恢复码关闭双因素认证。角色要求启用时返回 ErrTwoFactorRequired
+func (s *twoFactorService) Disable(userID uint, code string) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		user, err := s.lockEnabledUser(repos, userID)
+		if err != nil {
+			return err
+		}
+		if twoFactorRequired(user.Role) {
+			return ErrTwoFactorRequired
+		}
+		if err := verifyCode(repos, user, code); err != nil {
+			return err
+		}
+		return clearTwoFactor(repos, user.ID)
+	})
+}
+
+// RegenerateRecoveryCodes 用验证码确认后重新生成恢复码，之前的恢复码全部作废
+func (s *twoFactorService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	var codes []string
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		user, err := s.lockEnabledUser(repos, userID)
+		if err != nil {
+			return err
+		}
+		step, err := validateTOTP(user, code)
+		if err != nil {
+			return err
+		}
+		if err := repos.User.UpdateTOTP(user.ID, user.TOTPSecret, user.TOTPEnabledAt, step); err != nil {
+			return fmt.Errorf("update totp step: %w", err)
+		}
+		codes, err = replaceRecoveryCodes(repos.RecoveryCode, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyLogin 登录第二步，校验TOTP验证码或恢复码。
+// 验证码错误与密码错误一样计入失败次数，失败过多时返回 *LockedError
+func (s *twoFactorService) VerifyLogin(userID uint, code, ip string) (*model.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+	if user == nil {
+		return nil, ErrNotFound
+	}
+	if err := s.guard.Check(user.Username, ip); err != nil {
+		return nil, err
+	}
+
+	err = s.uow.Do(func(repos *mysql.Repositories) error {
+		locked, err := s.lockEnabledUser(repos, userID)
+		if err != nil {
+			return err
+		}
+		if locked.Status != 1 {
+			return ErrAccountDisabled
+		}
+		user = locked
+		return verifyCode(repos, locked, code)
+	})
+	if errors.Is(err, ErrInvalidCode) {
+		if err := s.guard.Fail(user.Username, ip, LoginFailBadCode); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.guard.Succeed(user.Username); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Reset 管理员为丢失验证器的用户关闭双因素认证，角色要求启用时用户下次登录需重新绑定
+func (s *twoFactorService) Reset(actor Actor, userID uint) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		user, err := repos.User.LockByID(userID)
+		if err != nil {
+			return fmt.Errorf("lock user: %w", err)
+		}
+		if user == nil {
+			return ErrNotFound
+		}
+		if user.TOTPEnabledAt == nil {
+			return ErrInvalidStatus
+		}
+		if err := writeAudit(repos.AuditLog, actor, AuditUserTwoFactorReset, AuditTargetUser, user.ID, map[string]interface{}{
+			"username":   user.Username,
+			"enabled_at": user.TOTPEnabledAt,
+		}); err != nil {
+			return err
+		}
+		return clearTwoFactor(repos, user.ID)
+	})
+}
+
+// lockEnabledUser 获取已启用双因素认证的用户并加行锁，未启用时返回 ErrInvalidStatus
+func (s *twoFactorService) lockEnabledUser(repos *mysql.Repositories, userID uint) (*model.User, error) {
+	user, err := repos.User.LockByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("lock user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrNotFound
+	}
+	if user.TOTPEnabledAt == nil {
+		return nil, ErrInvalidStatus
+	}
+	return user, nil
+}
+
+// verifyCode 校验TOTP验证码，不是6位数字时按恢复码校验。需在锁定用户的事务中调用
+func verifyCode(repos *mysql.Repositories, user *model.User, code string) error {
+	code = strings.TrimSpace(code)
+	if !isTOTPCode(code) {
+		ok, err := repos.RecoveryCode.Use(user.ID, hashRecoveryCode(code))
+		if err != nil {
+			return fmt.Errorf("use recovery code: %w", err)
+		}
+		if !ok {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+
+	step, err := validateTOTP(user, code)
+	if err != nil {
+		return err
+	}
+	if err := repos.User.UpdateTOTP(user.ID, user.TOTPSecret, user.TOTPEnabledAt, step); err != nil {
+		return fmt.Errorf("update totp step: %w", err)
+	}
+	return nil
+}
+
+// clearTwoFactor 清除密钥和恢复码
+func clearTwoFactor(repos *mysql.Repositories, userID uint) error {
+	if err := repos.User.UpdateTOTP(userID, "", nil, 0); err != nil {
+		return fmt.Errorf("clear totp: %w", err)
+	}
+	if err := repos.RecoveryCode.DeleteByUser(userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	return nil
+}
+
+// validateTOTP 校验验证码，返回通过验证的时间步。
+// 不接受早于或等于上次通过验证的时间步，同一验证码不能使用两次
+func validateTOTP(user *model.User, code string) (int64, error) {
+	code = strings.TrimSpace(code)
+	if !isTOTPCode(code) {
+		return 0, ErrInvalidCode
+	}
+	secret, err := decryptTOTPSecret(user.TOTPSecret)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	current := now.Unix() / totpPeriod
+	for i := -totpSkew; i <= totpSkew; i++ {
+		step := current + int64(i)
+		if step <= user.TOTPLastStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("generate totp code: %w", err)
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, nil
+		}
+	}
+	return 0, ErrInvalidCode
+}
+
+// isTOTPCode 是否为6位数字验证码
+func isTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// replaceRecoveryCodes 生成新的恢复码并替换旧的，返回明文
+func replaceRecoveryCodes(repo mysql.RecoveryCodeRepository, userID uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 4)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+		h := hex.EncodeToString(b)
+		codes[i] = h[:4] + "-" + h[4:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	if err := repo.ReplaceForUser(userID, hashes); err != nil {
+		return nil, fmt.Errorf("save recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode 恢复码的摘要，忽略大小写和分隔符
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// qrCodeDataURI 将配置URI编码为PNG二维码
+func qrCodeDataURI(key *otp.Key) (string, error) {
+	img, err := key.Image(qrCodeSize, qrCodeSize)
+	if err != nil {
+		return "", fmt.Errorf("render qr code: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("encode qr code: %w", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// totpKey 加密TOTP密钥的AES-256密钥，未配置时由JWT密钥派生
+func totpKey() []byte {
+	secret := config.GlobalConfig.TwoFactor.SecretKey
+	if secret == "" {
+		secret = config.GlobalConfig.JWT.Secret
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("totp-secret"))
+	return mac.Sum(nil)
+}
+
+// encryptTOTPSecret 用AES-GCM加密TOTP密钥，结果为 base64(nonce|密文)
+func encryptTOTPSecret(secret string) (string, error) {
+	gcm, err := newTOTPCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(secret), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptTOTPSecret 解密TOTP密钥
+func decryptTOTPSecret(encrypted string) (string, error) {
+	gcm, err := newTOTPCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", errors.New("malformed totp secret")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt totp secret: %w", err)
+	}
+	return string(plain), nil
+}
+
+func newTOTPCipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(totpKey())
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+
+	"library/config"
+	"library/model"
+	"library/repository/mysql"
+)
+
+// totpCodeAt 生成密钥在指定时间步的验证码
+func totpCodeAt(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totp.ValidateOpts{
+		Period:    totpPeriod,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		t.Fatalf("generate totp code: %v", err)
+	}
+	return code
+}
+
+func currentStep() int64 {
+	return time.Now().Unix() / totpPeriod
+}
+
+func newTestTwoFactorService(t *testing.T, db *gorm.DB, guard *LoginGuard) TwoFactorServiceInterface {
+	t.Helper()
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.TwoFactor = config.TwoFactorConfig{Issuer: "Library", SecretKey: "test-totp-key"}
+	})
+	if guard == nil {
+		guard = NewLoginGuard(LoginGuardOptions{})
+	}
+	return NewTwoFactorService(mysql.NewUserRepository(db), mysql.NewUnitOfWork(db), guard)
+}
+
+// enableTestTwoFactor 为用户绑定并启用验证器，返回密钥和恢复码
+func enableTestTwoFactor(t *testing.T, svc TwoFactorServiceInterface, userID uint) (string, []string) {
+	t.Helper()
+	setup, err := svc.Setup(userID)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	codes, err := svc.Enable(userID, totpCodeAt(t, setup.Secret, currentStep()))
+	if err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	return setup.Secret, codes
+}
+
+func TestValidateTOTP(t *testing.T) {
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.TwoFactor.SecretKey = "test-totp-key"
+	})
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "Library", AccountName: "reader", Period: totpPeriod})
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	encrypted, err := encryptTOTPSecret(key.Secret())
+	if err != nil {
+		t.Fatalf("encrypt secret: %v", err)
+	}
+	now := currentStep()
+
+	cases := []struct {
+		name     string
+		code     string
+		lastStep int64
+		ok       bool
+	}{
+		{name: "current step", code: totpCodeAt(t, key.Secret(), now), ok: true},
+		{name: "next step within skew", code: totpCodeAt(t, key.Secret(), now+1), ok: true},
+		{name: "with spaces", code: " " + totpCodeAt(t, key.Secret(), now+1) + " ", ok: true},
+		{name: "outside skew", code: totpCodeAt(t, key.Secret(), now+3), ok: false},
+		{name: "long expired", code: totpCodeAt(t, key.Secret(), now-3), ok: false},
+		{name: "step already used", code: totpCodeAt(t, key.Secret(), now), lastStep: now + 1, ok: false},
+		{name: "same step reused", code: totpCodeAt(t, key.Secret(), now+1), lastStep: now + 1, ok: false},
+		{name: "five digits", code: totpCodeAt(t, key.Secret(), now)[:5], ok: false},
+		{name: "not digits", code: "12a456", ok: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			user := &model.User{TOTPSecret: encrypted, TOTPLastStep: c.lastStep}
+			step, err := validateTOTP(user, c.code)
+			if !c.ok {
+				if !errors.Is(err, ErrInvalidCode) {
+					t.Fatalf("validateTOTP(%q) = %d, %v, want ErrInvalidCode", c.code, step, err)
+				}
+				return
+			}
+			if err != nil || step <= c.lastStep {
+				t.Fatalf("validateTOTP(%q) = %d, %v", c.code, step, err)
+			}
+		})
+	}
+
+	// 密钥使用其他密钥加密时无法解密
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.TwoFactor.SecretKey = "rotated-key"
+	})
+	if _, err := validateTOTP(&model.User{TOTPSecret: encrypted}, totpCodeAt(t, key.Secret(), now)); err == nil || errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("validateTOTP with another key err = %v, want a decryption error", err)
+	}
+}
+
+// TestTwoFactorLogin 登录第二步的验证码和恢复码都只能使用一次
+func TestTwoFactorLogin(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestTwoFactorService(t, db, nil)
+	user := createTestUser(t, db, "mfa-reader")
+	secret, recovery := enableTestTwoFactor(t, svc, user.ID)
+	if len(recovery) != recoveryCodeCount {
+		t.Fatalf("recovery codes = %d, want %d", len(recovery), recoveryCodeCount)
+	}
+	const ip = "198.51.100.30"
+
+	// 启用时使用的验证码所在的时间步不能再用于登录
+	if _, err := svc.VerifyLogin(user.ID, totpCodeAt(t, secret, currentStep()), ip); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("VerifyLogin with the enable code err = %v, want ErrInvalidCode", err)
+	}
+	next := totpCodeAt(t, secret, currentStep()+1)
+	if got, err := svc.VerifyLogin(user.ID, next, ip); err != nil || got.ID != user.ID {
+		t.Fatalf("VerifyLogin = %+v, %v", got, err)
+	}
+	if _, err := svc.VerifyLogin(user.ID, next, ip); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("reused code err = %v, want ErrInvalidCode", err)
+	}
+
+	// 恢复码忽略大小写和分隔符，每个只能使用一次
+	code := strings.ToUpper(strings.ReplaceAll(recovery[0], "-", ""))
+	if _, err := svc.VerifyLogin(user.ID, code, ip); err != nil {
+		t.Fatalf("VerifyLogin with recovery code: %v", err)
+	}
+	if _, err := svc.VerifyLogin(user.ID, recovery[0], ip); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("reused recovery code err = %v, want ErrInvalidCode", err)
+	}
+	if _, err := svc.VerifyLogin(user.ID, "0000-0000", ip); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("unknown recovery code err = %v, want ErrInvalidCode", err)
+	}
+	status, err := svc.Status(user.ID)
+	if err != nil || !status.Enabled || status.RecoveryCodes != recoveryCodeCount-1 {
+		t.Fatalf("Status = %+v, %v, want enabled with %d recovery codes", status, err, recoveryCodeCount-1)
+	}
+
+	// 重新生成后之前的恢复码作废。回退上次通过验证的时间步，模拟过了一个时间步
+	if err := db.Model(user).Update("totp_last_step", currentStep()-totpSkew-1).Error; err != nil {
+		t.Fatalf("rewind totp step: %v", err)
+	}
+	fresh, err := svc.RegenerateRecoveryCodes(user.ID, totpCodeAt(t, secret, currentStep()))
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes: %v", err)
+	}
+	if _, err := svc.VerifyLogin(user.ID, recovery[1], ip); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("old recovery code err = %v, want ErrInvalidCode", err)
+	}
+	if _, err := svc.VerifyLogin(user.ID, fresh[0], ip); err != nil {
+		t.Fatalf("VerifyLogin with regenerated recovery code: %v", err)
+	}
+
+	// 账号被禁用后不能完成第二步
+	if err := db.Model(user).Update("status", 2).Error; err != nil {
+		t.Fatalf("disable user: %v", err)
+	}
+	if _, err := svc.VerifyLogin(user.ID, fresh[1], ip); !errors.Is(err, ErrAccountDisabled) {
+		t.Fatalf("VerifyLogin of a disabled user err = %v, want ErrAccountDisabled", err)
+	}
+}
+
+// TestTwoFactorBadCodeLocksAccount 第二步的错误验证码与错误密码一样计入失败次数
+func TestTwoFactorBadCodeLocksAccount(t *testing.T) {
+	db := newTestDB(t)
+	guard := NewLoginGuard(LoginGuardOptions{MaxFailures: 2, Window: time.Hour, LockBase: time.Minute})
+	svc := newTestTwoFactorService(t, db, guard)
+	user := createTestUser(t, db, "mfa-locked")
+	t.Cleanup(func() { guard.Unlock(user.Username) })
+	secret, _ := enableTestTwoFactor(t, svc, user.ID)
+	const ip = "198.51.100.31"
+
+	if _, err := svc.VerifyLogin(user.ID, "000000", ip); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("first bad code err = %v, want ErrInvalidCode", err)
+	}
+	_, err := svc.VerifyLogin(user.ID, "000001", ip)
+	assertLocked(t, err, ErrAccountLocked, time.Minute)
+	_, err = svc.VerifyLogin(user.ID, totpCodeAt(t, secret, currentStep()+1), ip)
+	assertLocked(t, err, ErrAccountLocked, time.Minute)
+}
+
+// TestTwoFactorEnrollAtLogin 角色要求启用时，未启用的账号登录后需先绑定验证器，启用后不能自行关闭
+func TestTwoFactorEnrollAtLogin(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestTwoFactorService(t, db, nil)
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.TwoFactor.RequiredRoles = []string{model.RoleLibrarian}
+	})
+	hash, err := testHasher.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	librarian := createTestUserWithPassword(t, db, "mfa-librarian", hash, "")
+	if err := db.Model(librarian).Update("role", model.RoleLibrarian).Error; err != nil {
+		t.Fatalf("set role: %v", err)
+	}
+	reader := createTestUserWithPassword(t, db, "mfa-patron", hash, "")
+	users := newTestUserService(db, nil)
+
+	login := func(username string) *model.User {
+		t.Helper()
+		user, err := users.Login(username, "secret", "198.51.100.32")
+		if err != nil {
+			t.Fatalf("Login %s: %v", username, err)
+		}
+		return user
+	}
+	if got := svc.Requirement(login(reader.Username)); got != TwoFactorNone {
+		t.Fatalf("patron requirement = %q, want none", got)
+	}
+	if got := svc.Requirement(login(librarian.Username)); got != TwoFactorEnroll {
+		t.Fatalf("librarian requirement = %q, want %q", got, TwoFactorEnroll)
+	}
+	if status, err := svc.Status(librarian.ID); err != nil || status.Enabled || !status.Required {
+		t.Fatalf("Status = %+v, %v, want required and not enabled", status, err)
+	}
+
+	// 绑定：未确认的密钥不生效，错误的验证码不能启用
+	setup, err := svc.Setup(librarian.ID)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if setup.Secret == "" || !strings.HasPrefix(setup.URI, "otpauth://totp/") || !strings.HasPrefix(setup.QRCode, "data:image/png;base64,") {
+		t.Fatalf("setup = %+v", setup)
+	}
+	if got := svc.Requirement(login(librarian.Username)); got != TwoFactorEnroll {
+		t.Fatalf("requirement before confirming = %q, want %q", got, TwoFactorEnroll)
+	}
+	if _, err := svc.Enable(librarian.ID, "000000"); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("Enable with a bad code err = %v, want ErrInvalidCode", err)
+	}
+	if _, err := svc.Enable(librarian.ID, totpCodeAt(t, setup.Secret, currentStep())); err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	if _, err := svc.Setup(librarian.ID); !errors.Is(err, ErrInvalidStatus) {
+		t.Fatalf("Setup when enabled err = %v, want ErrInvalidStatus", err)
+	}
+
+	// 启用后登录需提交验证码，角色要求启用时不能关闭
+	if got := svc.Requirement(login(librarian.Username)); got != TwoFactorVerify {
+		t.Fatalf("requirement after enabling = %q, want %q", got, TwoFactorVerify)
+	}
+	if err := svc.Disable(librarian.ID, totpCodeAt(t, setup.Secret, currentStep()+1)); !errors.Is(err, ErrTwoFactorRequired) {
+		t.Fatalf("Disable err = %v, want ErrTwoFactorRequired", err)
+	}
+
+	// 管理员重置后下次登录需重新绑定
+	if err := svc.Reset(SystemActor, librarian.ID); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if got := svc.Requirement(login(librarian.Username)); got != TwoFactorEnroll {
+		t.Fatalf("requirement after reset = %q, want %q", got, TwoFactorEnroll)
+	}
+
+	// 不要求启用的角色可以关闭
+	secret, _ := enableTestTwoFactor(t, svc, reader.ID)
+	if err := svc.Disable(reader.ID, totpCodeAt(t, secret, currentStep()+1)); err != nil {
+		t.Fatalf("Disable patron: %v", err)
+	}
+	if got := svc.Requirement(login(reader.Username)); got != TwoFactorNone {
+		t.Fatalf("patron requirement after disabling = %q, want none", got)
+	}
+}
+
+// TestTwoFactorEnrollDisabledAccount 登录时绑定验证器期间账号被禁用，不能完成绑定
+func TestTwoFactorEnrollDisabledAccount(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestTwoFactorService(t, db, nil)
+	user := createTestUser(t, db, "mfa-disabled")
+
+	setup, err := svc.Setup(user.ID)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := db.Model(user).Update("status", 2).Error; err != nil {
+		t.Fatalf("disable user: %v", err)
+	}
+	if _, err := svc.Enable(user.ID, totpCodeAt(t, setup.Secret, currentStep())); !errors.Is(err, ErrAccountDisabled) {
+		t.Fatalf("Enable err = %v, want ErrAccountDisabled", err)
+	}
+	if _, err := svc.Setup(user.ID); !errors.Is(err, ErrAccountDisabled) {
+		t.Fatalf("Setup err = %v, want ErrAccountDisabled", err)
+	}
+	if status, err := svc.Status(user.ID); err != nil || status.Enabled {
+		t.Fatalf("Status = %+v, %v, want not enabled", status, err)
+	}
+}
@@ -1,73 +1,110 @@
 package service
 
 import (
-	"crypto/md5"
-	"encoding/hex"
+	"context"
+	"errors"
 	"fmt"
-	"gorm.io/gorm"
+	"library/config"
+	"library/database"
+	"library/mailer"
 	"library/model"
+	"library/password"
 	"library/repository/mysql"
+	"net/url"
+	"strings"
 	"time"
 )
 
 type UserServiceInterface interface {
-	Register(username, password, email string , role string) error
-	Login(username, password string) (*model.User, error)
+	Register(username, password, email, lang string) error
+	Login(username, password, ip string) (*model.User, error)
 	GetUserInfo(id uint) (*model.User, error)
-	UpdateUserInfo(user *model.User) error
-	ChangePassword(id uint, oldPassword, newPassword string) error
+	UpdateUserInfo(actor Actor, user *model.User) error
+	ChangePassword(actor Actor, id uint, oldPassword, newPassword string) error
 	ListUsers(params *model.SearchParams) ([]*model.User, int64, error)
+	CountLegacyPasswords() (int64, error)
+	UpdateRole(actor Actor, id uint, role string) (*model.User, error)
+	UpdateStatus(actor Actor, id uint, status int) (*model.User, error)
+	DeleteUser(actor Actor, id uint) error
+	BootstrapAdmin(username, password, email string) (bool, error)
+	UnlockLogin(actor Actor, id uint) error
+	ListLoginFailures(username string, limit int) ([]database.LoginFailure, error)
+	RequestPasswordReset(email, lang string) error
+	ResetPassword(token, newPassword string) error
+	SendVerificationEmail(id uint, lang string) error
+	VerifyEmail(token string) error
 }
 
 
 type userService struct {
 	userRepo mysql.UserRepository
+	uow      mysql.UnitOfWork
+	hasher   password.Hasher
+	guard    *LoginGuard
 }
 
-func NewUserService(userRepo mysql.UserRepository) UserServiceInterface {
+func NewUserService(userRepo mysql.UserRepository, uow mysql.UnitOfWork, hasher password.Hasher, guard *LoginGuard) UserServiceInterface {
 	return &userService{
 		userRepo: userRepo,
+		uow:      uow,
+		hasher:   hasher,
+		guard:    guard,
 	}
 }
 
-// Register 用户注册
-func (s *userService) Register(username, password, email string, role string) error {
-	return s.userRepo.Transaction(func(tx *gorm.DB) error {
-		// 检查用户名是否已存在
-		existUser, err := s.userRepo.GetByUsername( username)
+// Register 用户注册，公开注册的账号一律为普通读者，注册后发送邮箱验证邮件
+func (s *userService) Register(username, password, email, lang string) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		user, err := s.createUser(repos.User, username, password, email, model.RoleUser)
 		if err != nil {
-			return fmt.Errorf("check username exists: %w", err)
-		}
-		if existUser != nil {
-			return ErrAlreadyExists
+			return err
 		}
+		return enqueueVerificationMail(repos.MailOutbox, user, lang)
+	})
+}
 
-		// 生成密码盐值和加密密码
-		salt := generateSalt()
-		encryptedPass := encryptPassword(password, salt)
+// createUser 检查用户名后创建启用状态的账号
+func (s *userService) createUser(userRepo mysql.UserRepository, username, password, email, role string) (*model.User, error) {
+	// 检查用户名是否已存在
+	existUser, err := userRepo.GetByUsername(username)
+	if err != nil {
+		return nil, fmt.Errorf("check username exists: %w", err)
+	}
+	if existUser != nil {
+		return nil, ErrAlreadyExists
+	}
 
-		user := &model.User{
-			Username: username,
-			Password: encryptedPass,
-			Salt:     salt,
-			Email:    email,
-			Role:     role, // 默认普通用户
-			Status:   1, // 默认启用
-		}
+	// 生成密码哈希，盐值编码在哈希串中
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return nil, fmt.Errorf("hash password: %w", err)
+	}
 
-		if err := s.userRepo.Create( user); err != nil {
-			return fmt.Errorf("create user: %w", err)
-		}
-		return nil
-	})
+	user := &model.User{
+		Username: username,
+		Password: hash,
+		Email:    email,
+		Role:     role,
+		Status:   1, // 默认启用
+	}
+	if err := userRepo.Create(user); err != nil {
+		return nil, fmt.Errorf("create user: %w", err)
+	}
+	return user, nil
 }
 
-// Login 用户登录
-func (s *userService) Login(username, password string) (*model.User, error) {
+// Login 用户登录。按用户名和IP统计失败次数，失败过多时返回 *LockedError。
+// 需要双因素认证时由调用方根据 TwoFactorService.Requirement 进入第二步
+func (s *userService) Login(username, password, ip string) (*model.User, error) {
+	// 账号被锁定或IP失败次数过多时直接拒绝，不再校验密码
+	if err := s.guard.Check(username, ip); err != nil {
+		return nil, err
+	}
+
 	var user *model.User
-	err := s.userRepo.Transaction(func(tx *gorm.DB) error {
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
 		var err error
-		user, err = s.userRepo.GetByUsername( username)
+		user, err = repos.User.GetByUsername( username)
 		if err != nil {
 			return fmt.Errorf("get user by username: %w", err)
 		}
@@ -76,84 +113,527 @@ func (s *userService) Login(username, password string) (*model.User, error) {
 		}
 
 		// 验证密码
-		if encryptPassword(password, user.Salt) != user.Password {
+		ok, err := s.verifyPassword(user, password)
+		if err != nil {
+			return err
+		}
+		if !ok {
 			return ErrPasswordIncorrect
 		}
+		if user.Status != 1 {
+			return ErrAccountDisabled
+		}
+
+		// 旧版md5哈希或哈希参数已过时的，用本次登录的明文重新哈希
+		if s.hasher.NeedsRehash(user.Password) {
+			if err := s.setPassword(repos.User, user, password); err != nil {
+				return err
+			}
+		}
 
 		// 更新最后登录时间
 		user.LastLoginAt = time.Now()
-		if err := s.userRepo.Update(user); err != nil {
+		if err := repos.User.Update(user); err != nil {
 			return fmt.Errorf("update last login time: %w", err)
 		}
 
 		return nil
 	})
 
-	if err != nil {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil, s.loginFailed(username, ip, LoginFailUnknownUser, err)
+	case errors.Is(err, ErrPasswordIncorrect):
+		return nil, s.loginFailed(username, ip, LoginFailBadPassword, err)
+	case err != nil:
 		return nil, err
 	}
+	// 已启用双因素认证的账号在提交验证码后才算登录成功
+	if twoFactorRequirement(user) != TwoFactorVerify {
+		if err := s.guard.Succeed(username); err != nil {
+			return nil, err
+		}
+	}
 	return user, nil
 }
 
+// loginFailed 记录失败的登录，本次失败触发锁定时返回锁定错误，否则返回原错误
+func (s *userService) loginFailed(username, ip, reason string, cause error) error {
+	if err := s.guard.Fail(username, ip, reason); err != nil {
+		return err
+	}
+	return cause
+}
+
 // GetUserInfo 获取用户信息
 func (s *userService) GetUserInfo(id uint) (*model.User, error) {
 	return s.userRepo.GetByID( id)
 }
 
 // UpdateUserInfo 更新用户信息
-func (s *userService) UpdateUserInfo(user *model.User) error {
-	existUser, err := s.userRepo.GetByID( user.ID)
+func (s *userService) UpdateUserInfo(actor Actor, user *model.User) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		existUser, err := repos.User.LockByID(user.ID)
+		if err != nil {
+			return err
+		}
+		if existUser == nil {
+			return ErrNotFound
+		}
+		before := *existUser
+
+		// 保持原有的敏感信息不变
+		user.Password = existUser.Password
+		user.Salt = existUser.Salt
+		user.Role = existUser.Role
+		user.Status = existUser.Status
+		user.EmailVerifiedAt = existUser.EmailVerifiedAt
+		user.TOTPSecret = existUser.TOTPSecret
+		user.TOTPEnabledAt = existUser.TOTPEnabledAt
+		user.TOTPLastStep = existUser.TOTPLastStep
+
+		if err := repos.User.Update(user); err != nil {
+			return err
+		}
+		// 修改邮箱后需要重新验证
+		if user.Email != "" && user.Email != existUser.Email && existUser.EmailVerifiedAt != nil {
+			user.EmailVerifiedAt = nil
+			if err := repos.User.SetEmailVerifiedAt(user.ID, nil); err != nil {
+				return fmt.Errorf("reset email verification: %w", err)
+			}
+		}
+		return writeAuditChange(repos.AuditLog, actor, AuditUserProfile, AuditTargetUser, user.ID, &before, user, nil)
+	})
+}
+
+// ChangePassword 修改密码
+func (s *userService) ChangePassword(actor Actor, id uint, oldPassword, newPassword string) error {
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		user, err := repos.User.LockByID(id)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return ErrNotFound
+		}
+
+		// 验证旧密码
+		ok, err := s.verifyPassword(user, oldPassword)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrPasswordIncorrect
+		}
+
+		// 更新密码
+		if err := s.setPassword(repos.User, user, newPassword); err != nil {
+			return err
+		}
+		return writeAudit(repos.AuditLog, actor, AuditUserPassword, AuditTargetUser, user.ID, nil)
+	})
 	if err != nil {
 		return err
 	}
-	if existUser == nil {
-		return ErrNotFound
+
+	// 修改密码后注销该用户的全部会话，需要重新登录
+	return s.revokeSessions(id)
+}
+
+// ListUsers 获取用户列表
+func (s *userService) ListUsers(params *model.SearchParams) ([]*model.User, int64, error) {
+	return s.userRepo.List( params)
+}
+
+// CountLegacyPasswords 统计仍使用旧版md5密码哈希的用户数量
+func (s *userService) CountLegacyPasswords() (int64, error) {
+	return s.userRepo.CountLegacyPasswords()
+}
+
+// UpdateRole 管理员修改用户角色，不能降级最后一个启用的管理员。
+// 令牌中携带角色，修改后注销该用户的全部会话使新角色立即生效
+func (s *userService) UpdateRole(actor Actor, id uint, role string) (*model.User, error) {
+	if !isValidRole(role) {
+		return nil, ErrInvalidParameter
 	}
 
-	// 保持原有的敏感信息不变
-	user.Password = existUser.Password
-	user.Salt = existUser.Salt
-	user.Role = existUser.Role
+	var user *model.User
+	var changed bool
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		var err error
+		user, err = lockUserForAdminChange(repos.User, id)
+		if err != nil {
+			return err
+		}
+		if user.Role == role {
+			return nil
+		}
+		if user.Role == model.RoleAdmin {
+			if err := ensureNotLastAdmin(repos.User, user); err != nil {
+				return err
+			}
+		}
+
+		from := user.Role
+		user.Role = role
+		if err := repos.User.Update(user); err != nil {
+			return fmt.Errorf("update user role: %w", err)
+		}
+		changed = true
+		return writeAuditChange(repos.AuditLog, actor, AuditUserRole, AuditTargetUser, user.ID,
+			map[string]string{"role": from}, map[string]string{"role": role}, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	return s.userRepo.Update( user)
+	if changed {
+		if err := s.revokeSessions(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
 }
 
-// ChangePassword 修改密码
-func (s *userService) ChangePassword(id uint, oldPassword, newPassword string) error {
-	user, err := s.userRepo.GetByID( id)
+// UpdateStatus 管理员启用或禁用账号，不能禁用最后一个启用的管理员。禁用后注销该用户的全部会话
+func (s *userService) UpdateStatus(actor Actor, id uint, status int) (*model.User, error) {
+	if status != 1 && status != 2 {
+		return nil, ErrInvalidParameter
+	}
+
+	var user *model.User
+	var disabled bool
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		var err error
+		user, err = lockUserForAdminChange(repos.User, id)
+		if err != nil {
+			return err
+		}
+		if user.Status == status {
+			return nil
+		}
+		if status == 2 {
+			if err := ensureNotLastAdmin(repos.User, user); err != nil {
+				return err
+			}
+		}
+
+		from := user.Status
+		user.Status = status
+		if err := repos.User.Update(user); err != nil {
+			return fmt.Errorf("update user status: %w", err)
+		}
+		disabled = status == 2
+		return writeAuditChange(repos.AuditLog, actor, AuditUserStatus, AuditTargetUser, user.ID,
+			map[string]int{"status": from}, map[string]int{"status": status}, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if disabled {
+		if err := s.revokeSessions(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// DeleteUser 管理员删除账号（软删除），不能删除最后一个启用的管理员
+func (s *userService) DeleteUser(actor Actor, id uint) error {
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		user, err := lockUserForAdminChange(repos.User, id)
+		if err != nil {
+			return err
+		}
+		if err := ensureNotLastAdmin(repos.User, user); err != nil {
+			return err
+		}
+
+		if err := repos.User.Delete(user.ID); err != nil {
+			return fmt.Errorf("delete user: %w", err)
+		}
+		return writeAudit(repos.AuditLog, actor, AuditUserDelete, AuditTargetUser, user.ID, map[string]string{
+			"username": user.Username,
+			"role":     user.Role,
+		})
+	})
 	if err != nil {
 		return err
 	}
-	if user == nil {
-		return ErrNotFound
+	return s.revokeSessions(id)
+}
+
+// UnlockLogin 管理员解除账号的登录锁定，同时清除失败次数
+func (s *userService) UnlockLogin(actor Actor, id uint) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		user, err := repos.User.GetByID(id)
+		if err != nil {
+			return fmt.Errorf("get user by id: %w", err)
+		}
+		if user == nil {
+			return ErrNotFound
+		}
+
+		lockedUntil, err := s.guard.LockedUntil(user.Username)
+		if err != nil {
+			return err
+		}
+		if err := writeAudit(repos.AuditLog, actor, AuditUserUnlock, AuditTargetUser, user.ID, map[string]interface{}{
+			"username":     user.Username,
+			"locked_until": lockedUntil,
+		}); err != nil {
+			return err
+		}
+		// 审计记录写入成功后再解锁，解锁失败时审计记录随事务回滚
+		return s.guard.Unlock(user.Username)
+	})
+}
+
+// ListLoginFailures 最近失败的登录尝试，username不为空时只返回该用户名的记录
+func (s *userService) ListLoginFailures(username string, limit int) ([]database.LoginFailure, error) {
+	return s.guard.Failures(username, limit)
+}
+
+// RequestPasswordReset 向邮箱对应的账号发送重置密码邮件。
+// 邮箱不存在或账号已禁用时同样返回成功，避免通过该接口探测邮箱是否注册
+func (s *userService) RequestPasswordReset(email, lang string) error {
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		return fmt.Errorf("get user by email: %w", err)
 	}
+	if user == nil || user.Status != 1 {
+		return nil
+	}
+
+	ttl := time.Duration(config.GlobalConfig.Mail.ResetExpire) * time.Minute
+	token, err := issueAccountToken(TokenPurposeResetPassword, user.ID, user.Email, passwordFingerprint(user.Password), ttl)
+	if err != nil {
+		return err
+	}
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		return enqueueMail(repos.MailOutbox, mailer.TemplateResetPassword, lang, user.Email, mailData{
+			Name:          displayName(user),
+			Link:          accountLink("/reset-password", token),
+			ExpireMinutes: int(ttl / time.Minute),
+		})
+	})
+}
 
-	// 验证旧密码
-	if encryptPassword(oldPassword, user.Salt) != user.Password {
-		return ErrPasswordIncorrect
+// ResetPassword 使用重置密码邮件中的令牌设置新密码。
+// 令牌只能使用一次，签发后密码被修改过的令牌失效。重置后注销全部会话并解除登录锁定
+func (s *userService) ResetPassword(token, newPassword string) error {
+	userID, claims, err := consumeAccountToken(TokenPurposeResetPassword, token)
+	if err != nil {
+		return err
 	}
 
-	// 更新密码
-	user.Salt = generateSalt()
-	user.Password = encryptPassword(newPassword, user.Salt)
+	var username string
+	err = s.uow.Do(func(repos *mysql.Repositories) error {
+		user, err := repos.User.LockByID(userID)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return ErrInvalidToken
+		}
+		if user.Status != 1 {
+			return ErrAccountDisabled
+		}
+		if claims.Fingerprint != passwordFingerprint(user.Password) {
+			return ErrInvalidToken
+		}
+		username = user.Username
+		return s.setPassword(repos.User, user, newPassword)
+	})
+	if err != nil {
+		return err
+	}
 
-	return s.userRepo.Update( user)
+	if err := s.revokeSessions(userID); err != nil {
+		return err
+	}
+	return s.guard.Unlock(username)
 }
 
-// ListUsers 获取用户列表
-func (s *userService) ListUsers(params *model.SearchParams) ([]*model.User, int64, error) {
-	return s.userRepo.List( params)
+// SendVerificationEmail 向用户当前的邮箱发送验证邮件，已验证过的邮箱返回 ErrInvalidStatus
+func (s *userService) SendVerificationEmail(id uint, lang string) error {
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		user, err := repos.User.GetByID(id)
+		if err != nil {
+			return fmt.Errorf("get user by id: %w", err)
+		}
+		if user == nil {
+			return ErrNotFound
+		}
+		if user.Email == "" || user.EmailVerifiedAt != nil {
+			return ErrInvalidStatus
+		}
+		return enqueueVerificationMail(repos.MailOutbox, user, lang)
+	})
 }
 
-// 生成随机盐值
-func generateSalt() string {
-	// 这里简单使用时间戳作为盐值，实际应用中应使用更安全的随机数
-	return hex.EncodeToString([]byte(time.Now().String()))
+// VerifyEmail 使用验证邮件中的令牌完成邮箱验证，签发后邮箱被修改过的令牌无效
+func (s *userService) VerifyEmail(token string) error {
+	userID, claims, err := consumeAccountToken(TokenPurposeVerifyEmail, token)
+	if err != nil {
+		return err
+	}
+
+	return s.uow.Do(func(repos *mysql.Repositories) error {
+		user, err := repos.User.LockByID(userID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.Email != claims.Email {
+			return ErrInvalidToken
+		}
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		if err := repos.User.SetEmailVerifiedAt(user.ID, &now); err != nil {
+			return fmt.Errorf("set email verified: %w", err)
+		}
+		return nil
+	})
+}
+
+// enqueueVerificationMail 签发验证邮箱令牌并写入发件箱
+func enqueueVerificationMail(outboxRepo mysql.MailOutboxRepository, user *model.User, lang string) error {
+	if user.Email == "" {
+		return nil
+	}
+	ttl := time.Duration(config.GlobalConfig.Mail.VerifyExpire) * time.Minute
+	token, err := issueAccountToken(TokenPurposeVerifyEmail, user.ID, user.Email, "", ttl)
+	if err != nil {
+		return err
+	}
+	return enqueueMail(outboxRepo, mailer.TemplateVerifyEmail, lang, user.Email, mailData{
+		Name:          displayName(user),
+		Link:          accountLink("/verify-email", token),
+		ExpireMinutes: int(ttl / time.Minute),
+	})
+}
+
+// accountLink 生成邮件中指向前端页面的链接
+func accountLink(path, token string) string {
+	return strings.TrimRight(config.GlobalConfig.Mail.LinkBase, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// displayName 邮件中对用户的称呼，优先使用昵称
+func displayName(user *model.User) string {
+	if user.Nickname != "" {
+		return user.Nickname
+	}
+	return user.Username
 }
 
-// 加密密码
-func encryptPassword(password, salt string) string {
-	hash := md5.New()
-	hash.Write([]byte(password + salt))
-	return hex.EncodeToString(hash.Sum(nil))
+// BootstrapAdmin 系统中没有启用的管理员时创建初始管理员，用户名已存在时将其设为启用的管理员。
+// 已有启用的管理员时不做任何修改，返回false
+func (s *userService) BootstrapAdmin(username, password, email string) (bool, error) {
+	var created bool
+	err := s.uow.Do(func(repos *mysql.Repositories) error {
+		admins, err := repos.User.LockActiveAdmins()
+		if err != nil {
+			return fmt.Errorf("lock admins: %w", err)
+		}
+		if len(admins) > 0 {
+			return nil
+		}
+
+		user, err := repos.User.GetByUsername(username)
+		if err != nil {
+			return fmt.Errorf("get user by username: %w", err)
+		}
+		if user == nil {
+			if password == "" {
+				return ErrInvalidParameter
+			}
+			user, err = s.createUser(repos.User, username, password, email, model.RoleAdmin)
+			if err != nil {
+				return err
+			}
+		} else {
+			user.Role = model.RoleAdmin
+			user.Status = 1
+			if err := repos.User.Update(user); err != nil {
+				return fmt.Errorf("promote user: %w", err)
+			}
+		}
+
+		created = true
+		return writeAudit(repos.AuditLog, SystemActor, AuditUserBootstrap, AuditTargetUser, user.ID, map[string]string{
+			"username": user.Username,
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	return created, nil
+}
+
+// lockUserForAdminChange 在事务中先锁定全部启用的管理员再锁定目标用户，
+// 所有修改角色、状态的操作按相同顺序加锁，避免死锁
+func lockUserForAdminChange(userRepo mysql.UserRepository, id uint) (*model.User, error) {
+	if _, err := userRepo.LockActiveAdmins(); err != nil {
+		return nil, fmt.Errorf("lock admins: %w", err)
+	}
+	user, err := userRepo.LockByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+	if user == nil {
+		return nil, ErrNotFound
+	}
+	return user, nil
+}
+
+// ensureNotLastAdmin 目标用户是唯一启用的管理员时拒绝操作，需在已锁定管理员的事务中调用
+func ensureNotLastAdmin(userRepo mysql.UserRepository, target *model.User) error {
+	if target.Role != model.RoleAdmin || target.Status != 1 {
+		return nil
+	}
+	admins, err := userRepo.LockActiveAdmins()
+	if err != nil {
+		return fmt.Errorf("lock admins: %w", err)
+	}
+	if len(admins) <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// revokeSessions 注销用户已签发的全部访问令牌和刷新令牌
+func (s *userService) revokeSessions(userID uint) error {
+	if err := database.RevokeUserTokens(context.Background(), userID); err != nil {
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// verifyPassword 校验用户密码，兼容旧版md5哈希
+func (s *userService) verifyPassword(user *model.User, plain string) (bool, error) {
+	if password.IsLegacy(user.Password) {
+		return password.VerifyLegacy(plain, user.Salt, user.Password), nil
+	}
+	ok, err := s.hasher.Verify(plain, user.Password)
+	if err != nil {
+		return false, fmt.Errorf("verify password: %w", err)
+	}
+	return ok, nil
+}
+
+// setPassword 用当前配置的算法哈希新密码并保存，同时清空旧版盐值
+func (s *userService) setPassword(userRepo mysql.UserRepository, user *model.User, plain string) error {
+	hash, err := s.hasher.Hash(plain)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+	if err := userRepo.UpdatePassword(user.ID, hash); err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+	user.Password = hash
+	user.Salt = ""
+	return nil
 }
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"library/config"
+	"library/middleware"
+	"library/model"
+	"library/password"
+	"library/repository/mysql"
+)
+
+// testHasher 测试使用开销较低的argon2id参数
+var testHasher = password.MustNew(password.Options{Algorithm: password.Argon2id, Memory: 8 * 1024, Iterations: 1, Parallelism: 1})
+
+func newTestUserService(db *gorm.DB, guard *LoginGuard) UserServiceInterface {
+	if guard == nil {
+		guard = NewLoginGuard(LoginGuardOptions{})
+	}
+	return NewUserService(mysql.NewUserRepository(db), mysql.NewUnitOfWork(db), testHasher, guard)
+}
+
+// createTestUserWithPassword 创建使用指定密码哈希的启用用户
+func createTestUserWithPassword(t *testing.T, db *gorm.DB, username, hash, salt string) *model.User {
+	t.Helper()
+	user := createTestUser(t, db, username)
+	if err := db.Model(user).Updates(map[string]interface{}{"password": hash, "salt": salt}).Error; err != nil {
+		t.Fatalf("set password of %s: %v", username, err)
+	}
+	return user
+}
+
+// storedPassword 读取数据库中保存的密码哈希和盐值
+func storedPassword(t *testing.T, db *gorm.DB, id uint) (string, string) {
+	t.Helper()
+	var user model.User
+	if err := db.First(&user, id).Error; err != nil {
+		t.Fatalf("get user %d: %v", id, err)
+	}
+	return user.Password, user.Salt
+}
+
+// TestLoginUpgradesPasswordHash 登录成功时旧版md5哈希和过时的哈希升级为当前算法和参数
+func TestLoginUpgradesPasswordHash(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestUserService(db, nil)
+
+	sum := md5.Sum([]byte("secret" + "pepper"))
+	legacy := createTestUserWithPassword(t, db, "legacy", hex.EncodeToString(sum[:]), "pepper")
+	bcryptHash, err := password.MustNew(password.Options{Algorithm: password.Bcrypt, BcryptCost: 4}).Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	outdated := createTestUserWithPassword(t, db, "outdated", bcryptHash, "")
+	currentHash, err := testHasher.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	current := createTestUserWithPassword(t, db, "current", currentHash, "")
+
+	for _, user := range []*model.User{legacy, outdated, current} {
+		t.Run(user.Username, func(t *testing.T) {
+			before, _ := storedPassword(t, db, user.ID)
+
+			// 密码错误时不升级
+			if _, err := svc.Login(user.Username, "wrong", "192.0.2.1"); !errors.Is(err, ErrPasswordIncorrect) {
+				t.Fatalf("Login with a wrong password err = %v, want ErrPasswordIncorrect", err)
+			}
+			if hash, _ := storedPassword(t, db, user.ID); hash != before {
+				t.Fatalf("password hash changed after a failed login")
+			}
+
+			if _, err := svc.Login(user.Username, "secret", "192.0.2.1"); err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+			hash, salt := storedPassword(t, db, user.ID)
+			if !strings.HasPrefix(hash, "$argon2id$") || salt != "" || testHasher.NeedsRehash(hash) {
+				t.Fatalf("stored password = %q (salt %q), want a current argon2id hash without salt", hash, salt)
+			}
+			if user == current && hash != before {
+				t.Fatalf("a current hash was rehashed")
+			}
+
+			// 升级后仍可使用原密码登录
+			if _, err := svc.Login(user.Username, "secret", "192.0.2.1"); err != nil {
+				t.Fatalf("Login after upgrade: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoginRejects(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestUserService(db, nil)
+	hash, err := testHasher.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	disabled := createTestUserWithPassword(t, db, "disabled", hash, "")
+	if err := db.Model(disabled).Update("status", 2).Error; err != nil {
+		t.Fatalf("disable user: %v", err)
+	}
+
+	if _, err := svc.Login("nobody", "secret", "192.0.2.1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Login of an unknown user err = %v, want ErrNotFound", err)
+	}
+	if _, err := svc.Login("disabled", "secret", "192.0.2.1"); !errors.Is(err, ErrAccountDisabled) {
+		t.Fatalf("Login of a disabled user err = %v, want ErrAccountDisabled", err)
+	}
+}
+
+// TestAccountChangesRevokeSessions 修改密码、禁用、删除账号和修改角色后，该用户已签发的令牌全部失效
+func TestAccountChangesRevokeSessions(t *testing.T) {
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.JWT.Secret = "test-secret"
+		cfg.JWT.AccessExpire = 15
+		cfg.JWT.RefreshExpire = 24
+	})
+	db := newTestDB(t)
+	svc := newTestUserService(db, nil)
+	hash, err := testHasher.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		change func(user *model.User) error
+	}{
+		{name: "change password", change: func(user *model.User) error {
+			return svc.ChangePassword(SystemActor, user.ID, "secret", "new-secret")
+		}},
+		{name: "disable", change: func(user *model.User) error {
+			_, err := svc.UpdateStatus(SystemActor, user.ID, 2)
+			return err
+		}},
+		{name: "delete", change: func(user *model.User) error {
+			return svc.DeleteUser(SystemActor, user.ID)
+		}},
+		{name: "change role", change: func(user *model.User) error {
+			_, err := svc.UpdateRole(SystemActor, user.ID, model.RoleLibrarian)
+			return err
+		}},
+	}
+	for i, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			user := createTestUserWithPassword(t, db, fmt.Sprintf("session%d", i), hash, "")
+			pair, err := middleware.GenerateTokenPair(user.ID, user.Username, user.Role)
+			if err != nil {
+				t.Fatalf("GenerateTokenPair: %v", err)
+			}
+			if _, err := middleware.ParseAccessToken(pair.AccessToken); err != nil {
+				t.Fatalf("ParseAccessToken before change: %v", err)
+			}
+
+			if err := c.change(user); err != nil {
+				t.Fatalf("change: %v", err)
+			}
+			if _, err := middleware.ParseAccessToken(pair.AccessToken); !errors.Is(err, middleware.ErrTokenRevoked) {
+				t.Fatalf("ParseAccessToken after change err = %v, want ErrTokenRevoked", err)
+			}
+			if _, err := middleware.ConsumeRefreshToken(pair.RefreshToken); !errors.Is(err, middleware.ErrTokenRevoked) {
+				t.Fatalf("ConsumeRefreshToken after change err = %v, want ErrTokenRevoked", err)
+			}
+		})
+	}
+
+	// 旧密码错误时不修改密码，也不注销会话
+	user := createTestUserWithPassword(t, db, "session-kept", hash, "")
+	pair, err := middleware.GenerateTokenPair(user.ID, user.Username, user.Role)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	if err := svc.ChangePassword(SystemActor, user.ID, "wrong", "new-secret"); !errors.Is(err, ErrPasswordIncorrect) {
+		t.Fatalf("ChangePassword with a wrong password err = %v, want ErrPasswordIncorrect", err)
+	}
+	if _, err := middleware.ParseAccessToken(pair.AccessToken); err != nil {
+		t.Fatalf("ParseAccessToken after a failed change: %v", err)
+	}
+}
+
+// createTestAdmin 创建管理员，status为2时为已禁用的管理员
+func createTestAdmin(t *testing.T, db *gorm.DB, username string, status int) *model.User {
+	t.Helper()
+	user := createTestUser(t, db, username)
+	if err := db.Model(user).Updates(map[string]interface{}{"role": model.RoleAdmin, "status": status}).Error; err != nil {
+		t.Fatalf("make %s admin: %v", username, err)
+	}
+	user.Role, user.Status = model.RoleAdmin, status
+	return user
+}
+
+func TestLastAdminGuard(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestUserService(db, nil)
+	admin := createTestAdmin(t, db, "admin", 1)
+	createTestAdmin(t, db, "retired", 2) // 已禁用的管理员不算在内
+
+	demote := func(id uint) error {
+		_, err := svc.UpdateRole(SystemActor, id, model.RoleUser)
+		return err
+	}
+	disable := func(id uint) error {
+		_, err := svc.UpdateStatus(SystemActor, id, 2)
+		return err
+	}
+	remove := func(id uint) error {
+		return svc.DeleteUser(SystemActor, id)
+	}
+	for name, op := range map[string]func(id uint) error{"demote": demote, "disable": disable, "delete": remove} {
+		if err := op(admin.ID); !errors.Is(err, ErrLastAdmin) {
+			t.Fatalf("%s the last admin err = %v, want ErrLastAdmin", name, err)
+		}
+	}
+	if _, err := svc.UpdateRole(SystemActor, admin.ID, model.RoleAdmin); err != nil {
+		t.Fatalf("UpdateRole to the same role: %v", err)
+	}
+
+	// 有其他启用的管理员时可以降级，之后剩下的管理员成为最后一个
+	other := createTestAdmin(t, db, "other", 1)
+	if err := demote(admin.ID); err != nil {
+		t.Fatalf("demote with another admin: %v", err)
+	}
+	if err := remove(other.ID); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("delete the remaining admin err = %v, want ErrLastAdmin", err)
+	}
+	if _, err := svc.UpdateRole(SystemActor, other.ID, "superuser"); !errors.Is(err, ErrInvalidParameter) {
+		t.Fatalf("UpdateRole to an unknown role err = %v, want ErrInvalidParameter", err)
+	}
+
+	var admins int64
+	if err := db.Model(&model.User{}).Where("role = ? AND status = 1", model.RoleAdmin).Count(&admins).Error; err != nil {
+		t.Fatalf("count admins: %v", err)
+	}
+	if admins != 1 {
+		t.Fatalf("active admins = %d, want 1", admins)
+	}
+}
+
+// TestConcurrentAdminRemoval 两位管理员同时禁用对方，只有一个操作成功
+func TestConcurrentAdminRemoval(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestUserService(db, nil)
+	first := createTestAdmin(t, db, "first", 1)
+	second := createTestAdmin(t, db, "second", 1)
+
+	errs := make([]error, 2)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, id := range []uint{second.ID, first.ID} {
+		wg.Add(1)
+		go func(i int, id uint) {
+			defer wg.Done()
+			<-start
+			_, errs[i] = svc.UpdateStatus(SystemActor, id, 2)
+		}(i, id)
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrLastAdmin):
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("succeeded = %d, want 1", succeeded)
+	}
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestUserService(db, nil)
+
+	if _, err := svc.BootstrapAdmin("root", "", ""); !errors.Is(err, ErrInvalidParameter) {
+		t.Fatalf("BootstrapAdmin without a password err = %v, want ErrInvalidParameter", err)
+	}
+	created, err := svc.BootstrapAdmin("root", "secret", "root@example.com")
+	if err != nil || !created {
+		t.Fatalf("BootstrapAdmin = %v, %v, want true", created, err)
+	}
+	root, err := mysql.NewUserRepository(db).GetByUsername("root")
+	if err != nil || root == nil {
+		t.Fatalf("GetByUsername(root) = %v, %v", root, err)
+	}
+	if root.Role != model.RoleAdmin || root.Status != 1 {
+		t.Fatalf("bootstrap admin = role %s, status %d", root.Role, root.Status)
+	}
+	if _, err := svc.Login("root", "secret", "192.0.2.1"); err != nil {
+		t.Fatalf("Login as bootstrap admin: %v", err)
+	}
+
+	// 已有启用的管理员时不做任何修改
+	if created, err := svc.BootstrapAdmin("another", "secret", ""); err != nil || created {
+		t.Fatalf("BootstrapAdmin with an active admin = %v, %v, want false", created, err)
+	}
+	if user, _ := mysql.NewUserRepository(db).GetByUsername("another"); user != nil {
+		t.Fatalf("BootstrapAdmin created a second admin")
+	}
+
+	// 管理员都被禁用后，已存在的用户名被提升为启用的管理员，密码不变
+	if err := db.Model(root).Update("status", 2).Error; err != nil {
+		t.Fatalf("disable root: %v", err)
+	}
+	reader := createTestUser(t, db, "reader")
+	if created, err := svc.BootstrapAdmin("reader", "", ""); err != nil || !created {
+		t.Fatalf("BootstrapAdmin of an existing user = %v, %v, want true", created, err)
+	}
+	promoted, err := mysql.NewUserRepository(db).GetByID(reader.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if promoted.Role != model.RoleAdmin || promoted.Status != 1 || promoted.Password != reader.Password {
+		t.Fatalf("promoted user = role %s, status %d", promoted.Role, promoted.Status)
+	}
+}
+
+func TestRegisterCreatesPatrons(t *testing.T) {
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.JWT.Secret = "test-secret"
+		cfg.Mail.VerifyExpire = 60
+	})
+	db := newTestDB(t)
+	svc := newTestUserService(db, nil)
+	createTestAdmin(t, db, "admin", 1)
+
+	if err := svc.Register("alice", "secret", "alice@example.com", "zh"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	alice, err := mysql.NewUserRepository(db).GetByUsername("alice")
+	if err != nil || alice == nil {
+		t.Fatalf("GetByUsername(alice) = %v, %v", alice, err)
+	}
+	if alice.Role != model.RoleUser || alice.Status != 1 || alice.EmailVerifiedAt != nil {
+		t.Fatalf("registered user = role %s, status %d, verified %v, want an unverified patron", alice.Role, alice.Status, alice.EmailVerifiedAt)
+	}
+	if testHasher.NeedsRehash(alice.Password) {
+		t.Fatalf("registered password is not hashed with the current algorithm: %q", alice.Password)
+	}
+
+	var mails int64
+	if err := db.Model(&model.MailOutbox{}).Where("recipient = ?", "alice@example.com").Count(&mails).Error; err != nil {
+		t.Fatalf("count mails: %v", err)
+	}
+	if mails != 1 {
+		t.Fatalf("verification mails = %d, want 1", mails)
+	}
+
+	if err := svc.Register("alice", "other", "", "zh"); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("Register with a taken username err = %v, want ErrAlreadyExists", err)
+	}
+}
@@ -0,0 +1,122 @@
+// Package sheet 读取CSV和XLSX表格，统一返回按行排列的单元格文本
+package sheet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+// 支持的格式
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+var (
+	// ErrUnknownFormat 未知的表格格式
+	ErrUnknownFormat = errors.New("unknown spreadsheet format")
+	// ErrEmpty 表格没有数据
+	ErrEmpty = errors.New("spreadsheet is empty")
+)
+
+// FormatFromName 根据文件扩展名判断格式，无法判断时返回空字符串
+func FormatFromName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV
+	case ".xlsx":
+		return FormatXLSX
+	}
+	return ""
+}
+
+// Read 读取表格的全部行，XLSX只读取第一个工作表。行尾的空单元格会被去掉，空行保留以保证行号与文件一致
+func Read(data []byte, format string) ([][]string, error) {
+	var rows [][]string
+	var err error
+	switch format {
+	case FormatCSV:
+		rows, err = readCSV(data)
+	case FormatXLSX:
+		rows, err = readXLSX(data)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrEmpty
+	}
+	return rows, nil
+}
+
+// readCSV 读取CSV，兼容带BOM的UTF-8和Excel在中文系统下默认保存的GB18030编码
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		decoded, err := io.ReadAll(transform.NewReader(bytes.NewReader(data), simplifiedchinese.GB18030.NewDecoder()))
+		if err != nil {
+			return nil, fmt.Errorf("decode CSV: %w", err)
+		}
+		data = decoded
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	var rows [][]string
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse CSV: %w", err)
+		}
+		rows = append(rows, trimRow(record))
+	}
+	return rows, nil
+}
+
+func readXLSX(data []byte) ([][]string, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("open XLSX: %w", err)
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, ErrEmpty
+	}
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("read XLSX: %w", err)
+	}
+	for i, row := range rows {
+		rows[i] = trimRow(row)
+	}
+	return rows, nil
+}
+
+// trimRow 去掉单元格首尾空白和行尾的空单元格
+func trimRow(row []string) []string {
+	for i := range row {
+		row[i] = strings.TrimSpace(row[i])
+	}
+	for len(row) > 0 && row[len(row)-1] == "" {
+		row = row[:len(row)-1]
+	}
+	return row
+}
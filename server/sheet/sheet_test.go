@@ -0,0 +1,131 @@
+package sheet
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestFormatFromName(t *testing.T) {
+	cases := map[string]string{
+		"books.csv":      FormatCSV,
+		"图书.CSV":         FormatCSV,
+		"books.xlsx":     FormatXLSX,
+		"dir/Books.XLSX": FormatXLSX,
+		"books.xls":      "",
+		"books":          "",
+	}
+	for name, want := range cases {
+		if got := FormatFromName(name); got != want {
+			t.Errorf("FormatFromName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	want := [][]string{
+		{"isbn", "title", "author"},
+		{"9787111213826", "Go语言编程", "张三"},
+		{},
+		{"9780306406157", "Title, with comma"},
+	}
+	text := "isbn,title,author\n9787111213826, Go语言编程 ,张三\n,,\n9780306406157,\"Title, with comma\",\n"
+
+	gb, err := simplifiedchinese.GB18030.NewEncoder().String(text)
+	if err != nil {
+		t.Fatalf("encode GB18030: %v", err)
+	}
+	cases := map[string]string{
+		"utf-8":     text,
+		"utf-8 bom": "\ufeff" + text,
+		"gb18030":   gb,
+		"crlf":      strings.ReplaceAll(text, "\n", "\r\n"),
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			rows, err := Read([]byte(data), FormatCSV)
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			// 空行保留为空切片，行号与文件一致
+			if !reflect.DeepEqual(rows, want) {
+				t.Fatalf("rows = %q, want %q", rows, want)
+			}
+		})
+	}
+
+	if _, err := Read([]byte("a,\"b\n"), FormatCSV); err == nil || !strings.Contains(err.Error(), "parse CSV") {
+		t.Errorf("Read of malformed CSV err = %v", err)
+	}
+	if _, err := Read([]byte("\ufeff"), FormatCSV); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Read of empty CSV err = %v, want ErrEmpty", err)
+	}
+}
+
+func TestReadXLSX(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+	first := f.GetSheetName(0)
+	for cell, value := range map[string]interface{}{
+		"A1": "isbn", "B1": "title", "C1": "price",
+		"A2": "9787111213826", "B2": " Go语言编程 ", "C2": 45.5,
+		"A4": "9780306406157", "B4": "Title", "D4": " ",
+	} {
+		if err := f.SetCellValue(first, cell, value); err != nil {
+			t.Fatalf("SetCellValue: %v", err)
+		}
+	}
+	// 只读取第一个工作表
+	if _, err := f.NewSheet("Other"); err != nil {
+		t.Fatalf("NewSheet: %v", err)
+	}
+	if err := f.SetCellValue("Other", "A1", "ignored"); err != nil {
+		t.Fatalf("SetCellValue: %v", err)
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatalf("WriteToBuffer: %v", err)
+	}
+
+	rows, err := Read(buf.Bytes(), FormatXLSX)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := [][]string{
+		{"isbn", "title", "price"},
+		{"9787111213826", "Go语言编程", "45.5"},
+		nil,
+		{"9780306406157", "Title"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("rows = %q, want %q", rows, want)
+	}
+	for i := range want {
+		if len(rows[i]) != len(want[i]) || (len(want[i]) > 0 && !reflect.DeepEqual(rows[i], want[i])) {
+			t.Errorf("row %d = %q, want %q", i+1, rows[i], want[i])
+		}
+	}
+
+	empty := excelize.NewFile()
+	defer empty.Close()
+	buf, err = empty.WriteToBuffer()
+	if err != nil {
+		t.Fatalf("WriteToBuffer: %v", err)
+	}
+	if _, err := Read(buf.Bytes(), FormatXLSX); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Read of empty XLSX err = %v, want ErrEmpty", err)
+	}
+	if _, err := Read([]byte("isbn,title"), FormatXLSX); err == nil || !strings.Contains(err.Error(), "open XLSX") {
+		t.Errorf("Read of CSV as XLSX err = %v", err)
+	}
+}
+
+func TestReadUnknownFormat(t *testing.T) {
+	if _, err := Read([]byte("isbn"), "xls"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Read(xls) err = %v, want ErrUnknownFormat", err)
+	}
+}